@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"ectorus/internal/ecscan"
+)
+
+func main() {
+	cfg, err := ecscan.ParseAPFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ecscan.RunAP(cfg); err != nil {
+		log.Fatal(err)
+	}
+}
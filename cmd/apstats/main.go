@@ -0,0 +1,56 @@
+// apstats: Sato–Tate statistics for a stream of a_p values (apscan CSV or
+// ecscan --summary records).
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"ectorus/internal/stats"
+)
+
+func main() {
+	in := flag.String("in", "-", "input file (apscan CSV or ecscan summary NDJSON), - for stdin")
+	bins := flag.Int("bins", 20, "histogram bins over x = a_p/2√p ∈ [-1,1]")
+	moments := flag.Int("moments", 8, "report moments E[(a_p/√p)^k] for k ≤ this")
+	format := flag.String("format", "ascii", "output: ascii|csv|json")
+	width := flag.Int("width", 60, "bar width for ascii output")
+	flag.Parse()
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+	xs, err := stats.ReadSamples(r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rep, err := stats.Analyze(xs, *bins, *moments)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch *format {
+	case "ascii":
+		err = rep.WriteASCII(os.Stdout, *width)
+	case "csv":
+		err = rep.WriteCSV(os.Stdout)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(rep)
+	default:
+		err = fmt.Errorf("unknown --format %q (want ascii|csv|json)", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
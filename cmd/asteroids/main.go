@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"ectorus/internal/crosscheck"
+	"ectorus/internal/generator"
+	"ectorus/internal/pointsrpc"
+	"ectorus/internal/serve"
+)
+
+const usage = `usage: asteroids <command> [flags]
+
+commands:
+  serve       run ectorus and ecscan jobs behind a live web dashboard of the torus
+  grpc        stream a curve's points over gRPC (EnumeratePoints, see internal/pointsrpc/points.proto)
+  gen         find a point of maximal order, or of a given prime order, on a curve (text or JSON, with SEC1 encodings)
+  crosscheck  walk a curve with ectorus and enumerate it with ecscan, and compare the points`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "serve":
+		cfg, err := serve.ParseFlags(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := serve.Run(cfg); err != nil {
+			log.Fatal(err)
+		}
+	case "grpc":
+		cfg, err := pointsrpc.ParseFlags(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := pointsrpc.Run(cfg); err != nil {
+			log.Fatal(err)
+		}
+	case "gen":
+		cfg, err := generator.ParseFlags(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := generator.Run(cfg, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	case "crosscheck":
+		cfg, err := crosscheck.ParseFlags(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := crosscheck.Run(cfg, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "asteroids: unknown command %q\n%s\n", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"time"
 )
@@ -27,6 +29,9 @@ type ectorusOut struct {
 	Complete   bool   `json:"complete"`
 	Found      []pt   `json:"found"`
 	Lines      int    `json:"linesProcessed"`
+	Coverage   *struct {
+		Fraction float64 `json:"fraction"`
+	} `json:"coverage,omitempty"` // -grid only
 }
 
 type scenario struct {
@@ -34,12 +39,108 @@ type scenario struct {
 	A, B, P string
 	Args    []string // extra flags, e.g. {"-grid","-count_first"}
 	Timeout time.Duration
+	Expect  expect
 }
 
-func runScenario(path string, sc scenario, reps int) (time.Duration, ectorusOut, error) {
-	var best time.Duration
+// expect is what a scenario asserts of every run; empty fields are not
+// checked.
+type expect struct {
+	Count string `json:"expectCount,omitempty"` // ectorus's pointCount, #E with O
+	Found *int   `json:"expectFound,omitempty"` // affine points found
+}
+
+func (e expect) any() bool { return e.Count != "" || e.Found != nil }
+
+// check returns how out departs from e.
+func (e expect) check(out ectorusOut) []string {
+	var bad []string
+	if e.Count != "" && out.PointCount != e.Count {
+		bad = append(bad, fmt.Sprintf("pointCount %q, want %s", out.PointCount, e.Count))
+	}
+	if e.Found != nil && len(out.Found) != *e.Found {
+		bad = append(bad, fmt.Sprintf("found %d points, want %d", len(out.Found), *e.Found))
+	}
+	return bad
+}
+
+// scenarioFile is one entry of a -scenarios file. Timeout is a duration
+// string ("30s"); left out, it takes -timeout.
+type scenarioFile struct {
+	Name    string   `json:"name"`
+	A       string   `json:"A"`
+	B       string   `json:"B"`
+	P       string   `json:"p"`
+	Args    []string `json:"args"`
+	Timeout string   `json:"timeout"`
+	expect
+}
+
+func found(n int) *int { return &n }
+
+// builtinScenarios are run when no -scenarios file is given. Each walk
+// completes, so it finds all #E - 1 affine points. The -group -orders pair
+// times the affine and Jacobian scalar multiplications (-coords).
+var builtinScenarios = []scenarioFile{
+	{Name: "supersingular p=101 y^2=x^3+1 (grid)", A: "0", B: "1", P: "101", Args: []string{"-grid", "-count_first"}, expect: expect{"102", found(101)}},
+	{Name: "ordinary-ish p=101 A=1,B=1 (grid)", A: "1", B: "1", P: "101", Args: []string{"-grid", "-count_first"}, expect: expect{"105", found(104)}},
+	{Name: "implicit p=1009 A=0,B=7 (count_first)", A: "0", B: "7", P: "1009", Args: []string{"-count_first"}, expect: expect{"1029", found(1028)}},
+	{Name: "implicit p=10007 A=2,B=3 (count_first)", A: "2", B: "3", P: "10007", Args: []string{"-count_first"}, expect: expect{"9846", found(9845)}},
+	{Name: "group p=1009 A=0,B=7 (affine)", A: "0", B: "7", P: "1009", Args: []string{"-group", "-orders", "-coords", "affine"}, expect: expect{"1029", found(1028)}},
+	{Name: "group p=1009 A=0,B=7 (jacobian)", A: "0", B: "7", P: "1009", Args: []string{"-group", "-orders", "-coords", "jacobian"}, expect: expect{"1029", found(1028)}},
+}
+
+// loadScenarios reads a JSON array of scenarioFile from path ("" for the
+// built-in ones), putting passArgs before each scenario's own args.
+func loadScenarios(path string, passArgs []string, timeout time.Duration) ([]scenario, error) {
+	fs := builtinScenarios
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fs = nil
+		if err := json.Unmarshal(b, &fs); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if len(fs) == 0 {
+			return nil, fmt.Errorf("%s: no scenarios", path)
+		}
+	}
+	var scs []scenario
+	for i, f := range fs {
+		if f.P == "" {
+			return nil, fmt.Errorf("scenario %d (%q): missing p", i+1, f.Name)
+		}
+		sc := scenario{Name: f.Name, A: f.A, B: f.B, P: f.P, Timeout: timeout, Expect: f.expect}
+		if sc.A == "" {
+			sc.A = "0"
+		}
+		if sc.B == "" {
+			sc.B = "0"
+		}
+		if sc.Name == "" {
+			sc.Name = strings.TrimSpace(fmt.Sprintf("p=%s A=%s,B=%s %s", sc.P, sc.A, sc.B, strings.Join(f.Args, " ")))
+		}
+		if f.Timeout != "" {
+			d, err := time.ParseDuration(f.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("scenario %d (%q): timeout: %v", i+1, sc.Name, err)
+			}
+			sc.Timeout = d
+		}
+		sc.Args = append(slices.Clone(passArgs), f.Args...)
+		scs = append(scs, sc)
+	}
+	return scs, nil
+}
+
+// runScenario runs sc reps times and returns each run's time and the last
+// run's output, with sc.Expect's complaints about any of the runs.
+func runScenario(path string, sc scenario, reps int) ([]time.Duration, ectorusOut, []string, error) {
+	var durs []time.Duration
 	var last ectorusOut
-	for i := range reps {
+	var bad []string
+	for range reps {
 		ctx, cancel := context.WithTimeout(context.Background(), sc.Timeout)
 		defer cancel()
 		args := []string{"-A", sc.A, "-B", sc.B, "-p", sc.P, "-json"}
@@ -52,21 +153,87 @@ func runScenario(path string, sc scenario, reps int) (time.Duration, ectorusOut,
 		err := cmd.Run()
 		dur := time.Since(t0)
 		if ctx.Err() == context.DeadlineExceeded {
-			return dur, last, fmt.Errorf("timeout: %s", sc.Name)
+			return durs, last, bad, fmt.Errorf("timeout: %s", sc.Name)
 		}
 		if err != nil {
-			return dur, last, fmt.Errorf("%s failed: %v\n%s", sc.Name, err, stderr.String())
+			return durs, last, bad, fmt.Errorf("%s failed: %v\n%s", sc.Name, err, stderr.String())
 		}
 		var out ectorusOut
 		if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
-			return dur, last, fmt.Errorf("%s parse json: %v\nraw=%s", sc.Name, err, stdout.String())
+			return durs, last, bad, fmt.Errorf("%s parse json: %v\nraw=%s", sc.Name, err, stdout.String())
 		}
 		last = out
-		if i == 0 || dur < best {
-			best = dur
+		durs = append(durs, dur)
+		for _, b := range sc.Expect.check(out) {
+			bad = append(bad, fmt.Sprintf("rep %d: %s", len(durs), b))
 		}
 	}
-	return best, last, nil
+	return durs, last, bad, nil
+}
+
+// timing is the repetition statistics of one scenario, in seconds.
+type timing struct {
+	Runs   []float64 `json:"runs"`
+	Best   float64   `json:"best"`
+	Mean   float64   `json:"mean"`
+	Median float64   `json:"median"`
+	Stddev float64   `json:"stddev"` // sample standard deviation; 0 for one run
+}
+
+func newTiming(durs []time.Duration) timing {
+	var t timing
+	for _, d := range durs {
+		t.Runs = append(t.Runs, d.Seconds())
+	}
+	if len(t.Runs) == 0 {
+		return t
+	}
+	s := slices.Sorted(slices.Values(t.Runs))
+	n := len(s)
+	t.Best = s[0]
+	for _, v := range s {
+		t.Mean += v
+	}
+	t.Mean /= float64(n)
+	t.Median = s[n/2]
+	if n%2 == 0 {
+		t.Median = (s[n/2-1] + s[n/2]) / 2
+	}
+	if n > 1 {
+		ss := 0.0
+		for _, v := range s {
+			ss += (v - t.Mean) * (v - t.Mean)
+		}
+		t.Stddev = math.Sqrt(ss / float64(n-1))
+	}
+	return t
+}
+
+// scenarioReport is one scenario in the -out report.
+type scenarioReport struct {
+	Name       string   `json:"name"`
+	P          string   `json:"p"`
+	A          string   `json:"A"`
+	B          string   `json:"B"`
+	Args       []string `json:"args"`
+	Seconds    timing   `json:"seconds"`
+	Points     int      `json:"points"` // found, from the last run
+	PointCount string   `json:"pointCount,omitempty"`
+	Lines      int      `json:"linesProcessed"`
+	Complete   bool     `json:"complete"`
+	Coverage   *float64 `json:"coverage,omitempty"` // classified fraction of the grid
+	expect
+	Failures []string `json:"failures,omitempty"` // broken expectations
+	Error    string   `json:"error,omitempty"`
+}
+
+// report is what -out writes.
+type report struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Ectorus   string           `json:"ectorus"`
+	Reps      int              `json:"reps"`
+	Failed    int              `json:"failed"` // scenarios whose expectations broke or could not be checked
+	Scenarios []scenarioReport `json:"scenarios"`
 }
 
 func main() {
@@ -74,29 +241,42 @@ func main() {
 	var reps int
 	var timeout time.Duration
 	var passArgs string
+	var scenariosPath, outPath string
 	flag.StringVar(&ectorusPath, "ectorus", "./ectorus", "path to ectorus binary")
-	flag.IntVar(&reps, "reps", 1, "repetitions per scenario (report best)")
+	flag.IntVar(&reps, "reps", 1, "repetitions per scenario (report best, mean, median and stddev)")
 	flag.DurationVar(&timeout, "timeout", 30*time.Second, "per-run timeout")
 	flag.StringVar(&passArgs, "args", "", "extra args to pass through to the child (ectorus/ecscan)")
+	flag.StringVar(&scenariosPath, "scenarios", "", "JSON file of scenarios to run instead of the built-in ones")
+	flag.StringVar(&outPath, "out", "", "also write a JSON report of every scenario to this file")
 	flag.Parse()
 
 	if _, err := os.Stat(ectorusPath); err != nil {
 		fmt.Fprintf(os.Stderr, "ectorus not found at %s (build it first)\n", ectorusPath)
 		os.Exit(2)
 	}
+	if reps < 1 {
+		fmt.Fprintln(os.Stderr, "-reps must be at least 1")
+		os.Exit(2)
+	}
 
-	scenarios := []scenario{
-		{Name: "supersingular p=101 y^2=x^3+1 (grid)", A: "0", B: "1", P: "101", Args: append(strings.Fields(passArgs), []string{"-grid", "-count_first"}...), Timeout: timeout},
-		{Name: "ordinary-ish p=101 A=1,B=1 (grid)", A: "1", B: "1", P: "101", Args: append(strings.Fields(passArgs), []string{"-grid", "-count_first"}...), Timeout: timeout},
-		{Name: "implicit p=1009 A=0,B=7 (count_first)", A: "0", B: "7", P: "1009", Args: append(strings.Fields(passArgs), []string{"-count_first"}...), Timeout: timeout},
-		{Name: "implicit p=10007 A=2,B=3 (count_first)", A: "2", B: "3", P: "10007", Args: append(strings.Fields(passArgs), []string{"-count_first"}...), Timeout: timeout},
+	scenarios, err := loadScenarios(scenariosPath, strings.Fields(passArgs), timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "scenarios: %v\n", err)
+		os.Exit(2)
 	}
 
+	rep := report{Timestamp: time.Now().UTC(), Ectorus: ectorusPath, Reps: reps}
 	fmt.Println("asteroids bench — running scenarios")
 	for _, sc := range scenarios {
-		dur, out, err := runScenario(ectorusPath, sc, reps)
+		durs, out, bad, err := runScenario(ectorusPath, sc, reps)
+		sr := scenarioReport{Name: sc.Name, P: sc.P, A: sc.A, B: sc.B, Args: sc.Args, Seconds: newTiming(durs), expect: sc.Expect, Failures: bad}
 		if err != nil {
 			fmt.Printf("%-40s : ERROR: %v\n", sc.Name, err)
+			sr.Error = err.Error()
+			if sc.Expect.any() {
+				rep.Failed++
+			}
+			rep.Scenarios = append(rep.Scenarios, sr)
 			continue
 		}
 		pts := len(out.Found)
@@ -104,9 +284,42 @@ func main() {
 		if pc == "" {
 			pc = "?"
 		}
-		fmt.Printf("%-40s : %8s  points=%-6d  lines=%-6d  complete=%v\n",
-			sc.Name, dur.Truncate(time.Microsecond), pts, out.Lines, out.Complete)
+		sr.Points, sr.PointCount, sr.Lines, sr.Complete = pts, out.PointCount, out.Lines, out.Complete
+		cov := ""
+		if out.Coverage != nil {
+			sr.Coverage = &out.Coverage.Fraction
+			cov = fmt.Sprintf("  coverage=%.4f", out.Coverage.Fraction)
+		}
+		rep.Scenarios = append(rep.Scenarios, sr)
+
+		best := time.Duration(sr.Seconds.Best * float64(time.Second))
+		fmt.Printf("%-40s : %8s  points=%-6d  lines=%-6d  complete=%v%s\n",
+			sc.Name, best.Truncate(time.Microsecond), pts, out.Lines, out.Complete, cov)
+		if reps > 1 {
+			t := sr.Seconds
+			fmt.Printf("    time: best=%.6fs  mean=%.6fs  median=%.6fs  stddev=%.6fs  (%d reps)\n", t.Best, t.Mean, t.Median, t.Stddev, reps)
+		}
 		fmt.Printf("    curve: p=%s  A=%s  B=%s  count=%s\n", out.P, out.A, out.B, pc)
+		if len(bad) > 0 {
+			rep.Failed++
+			for _, b := range bad {
+				fmt.Printf("    FAIL: %s\n", b)
+			}
+		}
 	}
 
+	if outPath != "" {
+		b, err := json.MarshalIndent(rep, "", "  ")
+		if err == nil {
+			err = os.WriteFile(outPath, append(b, '\n'), 0o644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-out: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if rep.Failed > 0 {
+		fmt.Fprintf(os.Stderr, "FAIL: %d of %d scenarios did not meet their expectations\n", rep.Failed, len(scenarios))
+		os.Exit(1)
+	}
 }
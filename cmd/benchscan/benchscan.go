@@ -3,33 +3,61 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 )
 
 type runResult struct {
 	points   int64
-	duration time.Duration
+	duration time.Duration // wall clock, process start to exit
+	scan     time.Duration // ecscan's own, from its summary
+	peakRSS  int64         // bytes, 0 if unknown
 	err      error
 }
 
-// detectInfinitySentinel returns true if the line is the "point at infinity" marker.
-func detectInfinitySentinel(line string) bool {
-	s := strings.TrimSpace(line)
-	// big.Int path: "-1 -1"
-	if s == "-1 -1" {
-		return true
-	}
-	// uint64 path: MaxUint64 MaxUint64
-	if s == "18446744073709551615 18446744073709551615" {
-		return true
+// scanSummary is the part of ecscan's --summary record that benchscan uses.
+type scanSummary struct {
+	Type    string  `json:"type"`
+	Affine  uint64  `json:"affinePoints"`
+	Seconds float64 `json:"seconds"`
+}
+
+// readSummary picks ecscan's summary record out of its stderr, logging the
+// other lines unless quiet. ecscan writes the record there with --summary=-,
+// so it is there whatever --out and --format the points went to.
+func readSummary(r io.Reader, quiet bool) (*scanSummary, error) {
+	var sum *scanSummary
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, `{"type":"summary"`) {
+			var s scanSummary
+			if err := json.Unmarshal([]byte(line), &s); err != nil {
+				return nil, fmt.Errorf("summary record: %w", err)
+			}
+			sum = &s
+			continue
+		}
+		if !quiet {
+			log.Printf("[ecscan] %s", line)
+		}
 	}
-	return false
+	return sum, sc.Err()
 }
 
 func runOnce(ecscan string, args []string, timeout time.Duration, quiet bool) runResult {
@@ -55,42 +83,491 @@ func runOnce(ecscan string, args []string, timeout time.Duration, quiet bool) ru
 		return runResult{err: fmt.Errorf("start: %w", err)}
 	}
 
-	// Stream-count stdout
-	var points int64
-	var lastLine string
-	sc := bufio.NewScanner(stdout)
-	// lines are tiny ("x y"), default buffer is fine; set larger if needed:
-	// buf := make([]byte, 0, 64*1024); sc.Buffer(buf, 1024*1024)
-	for sc.Scan() {
-		lastLine = sc.Text()
-		points++
-	}
-	if err := sc.Err(); err != nil {
-		// keep reading stderr for context
-		slurp, _ := bufio.NewReader(stderr).ReadString(0)
-		return runResult{err: fmt.Errorf("scan stdout: %w (stderr: %q)", err, slurp)}
+	// Read stderr alongside stdout, so neither pipe fills and stalls ecscan.
+	type summaryResult struct {
+		sum *scanSummary
+		err error
 	}
+	sumc := make(chan summaryResult, 1)
+	go func() {
+		s, err := readSummary(stderr, quiet)
+		sumc <- summaryResult{s, err}
+	}()
 
-	// Drain stderr (log lines from ecscan) without failing the run.
-	if !quiet {
-		se := bufio.NewScanner(stderr)
-		for se.Scan() {
-			log.Printf("[ecscan] %s", se.Text())
-		}
-	}
+	// Points on stdout (--out=-) are discarded: the count is the summary's.
+	_, copyErr := io.Copy(io.Discard, stdout)
+	sr := <-sumc
 
 	// Wait for exit
 	if err := cmd.Wait(); err != nil {
 		return runResult{err: fmt.Errorf("wait: %w", err)}
 	}
 	dur := time.Since(start)
+	switch {
+	case copyErr != nil:
+		return runResult{err: fmt.Errorf("read stdout: %w", copyErr)}
+	case sr.err != nil:
+		return runResult{err: fmt.Errorf("read stderr: %w", sr.err)}
+	case sr.sum == nil:
+		return runResult{err: fmt.Errorf("no summary record on stderr (does %s take --summary?)", ecscan)}
+	}
+
+	return runResult{points: int64(sr.sum.Affine), duration: dur, scan: secs(sr.sum.Seconds), peakRSS: peakRSS(cmd.ProcessState), err: nil}
+}
+
+// benchConfig is the scenario a results file was measured on.
+type benchConfig struct {
+	P       string `json:"p"`
+	A       string `json:"A"`
+	B       string `json:"B"`
+	Mode    string `json:"mode"`
+	MaxMem  string `json:"maxMem"`
+	Workers int    `json:"workers,omitempty"`
+	Runs    int    `json:"runs"`
+	Warmup  int    `json:"warmup"`
+
+	// Where and how ecscan writes the points: "-" discards them from
+	// stdout, a file is rewritten by every run.
+	Format   string `json:"format,omitempty"`
+	Compress string `json:"compress,omitempty"`
+	ScanOut  string `json:"scanOut,omitempty"`
+}
+
+type benchRun struct {
+	Run          int     `json:"run"`
+	Seconds      float64 `json:"seconds"`
+	ScanSeconds  float64 `json:"scanSeconds"` // ecscan's own timing, without process startup
+	Points       int64   `json:"points"`
+	PointsPerSec float64 `json:"pointsPerSec"`
+	PeakRSS      int64   `json:"peakRSSBytes,omitempty"` // Linux only
+}
+
+type benchSummary struct {
+	Points         int64   `json:"points"`
+	AvgSeconds     float64 `json:"avgSeconds"`
+	MinSeconds     float64 `json:"minSeconds"`
+	MaxSeconds     float64 `json:"maxSeconds"`
+	StddevSeconds  float64 `json:"stddevSeconds"`          // sample standard deviation
+	P95Seconds     float64 `json:"p95Seconds"`             // nearest rank
+	AvgScanSeconds float64 `json:"avgScanSeconds"`         // ecscan's own, as reported in its summary
+	PointsPerSec   float64 `json:"pointsPerSec"`           // from the average run
+	PeakRSS        int64   `json:"peakRSSBytes,omitempty"` // largest over the runs
+}
+
+// args builds the ecscan command line for c. The point count and ecscan's
+// timing come from its --summary record on stderr, so the points can go to
+// stdout or a file in any format.
+func (c benchConfig) args() []string {
+	out := c.ScanOut
+	if out == "" {
+		out = "-"
+	}
+	args := []string{
+		"--p=" + c.P,
+		"--A=" + c.A,
+		"--B=" + c.B,
+		"--mode=" + c.Mode,
+		"--max-mem=" + c.MaxMem,
+		"--out=" + out,
+		"--summary=-",
+	}
+	if c.Format != "" {
+		args = append(args, "--format="+c.Format)
+	}
+	if c.Compress != "" && c.Compress != "none" {
+		args = append(args, "--compress="+c.Compress)
+	}
+	if c.Workers > 0 {
+		args = append(args, fmt.Sprintf("--workers=%d", c.Workers))
+	}
+	return args
+}
+
+// toFile reports whether ecscan writes the points to a file rather than
+// to the pipe benchscan discards them from.
+func (c benchConfig) toFile() bool { return c.ScanOut != "" && c.ScanOut != "-" }
+
+// secs converts the reports' float seconds back to a Duration.
+func secs(s float64) time.Duration { return time.Duration(s * float64(time.Second)) }
+
+// benchResults is what -out writes as JSON.
+type benchResults struct {
+	Label     string       `json:"label,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+	Ecscan    string       `json:"ecscan"`
+	Args      []string     `json:"args"`
+	Config    benchConfig  `json:"config"`
+	Runs      []benchRun   `json:"runs"`
+	Summary   benchSummary `json:"summary"`
+	Profiles  *profiles    `json:"profiles,omitempty"`
+}
+
+// profiles are the files of the extra -profile run.
+type profiles struct {
+	Args []string `json:"args"` // the flags added to ecscan's
+	CPU  string   `json:"cpu"`
+	Mem  string   `json:"mem"`
+}
 
-	// Adjust for infinity sentinel if present
-	if points > 0 && detectInfinitySentinel(lastLine) {
-		points--
+func newBenchRun(i int, res runResult) benchRun {
+	r := benchRun{Run: i, Seconds: res.duration.Seconds(), ScanSeconds: res.scan.Seconds(), Points: res.points, PeakRSS: res.peakRSS}
+	if r.Seconds > 0 {
+		r.PointsPerSec = float64(res.points) / r.Seconds
 	}
+	return r
+}
+
+// csvHeader is the column line of a -out .csv file: one row per timed run,
+// with the scenario repeated on each so rows from different invocations
+// can share a file.
+var csvHeader = []string{"timestamp", "label", "p", "A", "B", "mode", "max_mem", "workers", "format", "compress", "run", "seconds", "scan_seconds", "points", "points_per_sec", "peak_rss_bytes"}
 
-	return runResult{points: points, duration: dur, err: nil}
+// writeResults writes res to path: a JSON document (an array when a
+// -matrix gave several scenarios), or with a .csv extension, one row per run
+// appended to the file (the header is written only when the file is new or
+// empty), so a history builds up run after run.
+func writeResults(path string, res ...benchResults) error {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return appendCSV(path, res)
+	}
+	var v any = res
+	if len(res) == 1 {
+		v = res[0]
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func appendCSV(path string, rs []benchResults) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if st.Size() > 0 {
+		// rows are only appended under the same columns
+		head, err := csv.NewReader(f).Read()
+		if err == nil && !slices.Equal(head, csvHeader) {
+			err = fmt.Errorf("%s has other columns than benchscan writes (%s)", path, strings.Join(head, ","))
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	w := csv.NewWriter(f)
+	if st.Size() == 0 {
+		w.Write(csvHeader)
+	}
+	for _, res := range rs {
+		c := res.Config
+		ts := res.Timestamp.Format(time.RFC3339)
+		for _, r := range res.Runs {
+			w.Write([]string{
+				ts, res.Label, c.P, c.A, c.B, c.Mode, c.MaxMem, strconv.Itoa(c.Workers), c.Format, c.Compress,
+				strconv.Itoa(r.Run),
+				strconv.FormatFloat(r.Seconds, 'f', 6, 64),
+				strconv.FormatFloat(r.ScanSeconds, 'f', 6, 64),
+				strconv.FormatInt(r.Points, 10),
+				strconv.FormatFloat(r.PointsPerSec, 'f', 1, 64),
+				strconv.FormatInt(r.PeakRSS, 10),
+			})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readBaseline loads a JSON results file written by -out.
+func readBaseline(path string) (*benchResults, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var res benchResults
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, fmt.Errorf("%s: %w (only JSON results can be a baseline)", path, err)
+	}
+	if res.Summary.AvgSeconds <= 0 {
+		return nil, fmt.Errorf("%s: no timed runs in the summary", path)
+	}
+	return &res, nil
+}
+
+// parsePercent reads "10%" (or a bare "10") as 0.10.
+func parsePercent(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("want a non-negative percentage such as 10%%, got %q", s)
+	}
+	return v / 100, nil
+}
+
+// compareBaseline returns the relative change of cur's average run time
+// against base's (0.1 is 10% slower), warning when the two were not
+// measured on the same scenario.
+func compareBaseline(base, cur benchResults) float64 {
+	b, c := base.Config, cur.Config
+	if b.P != c.P || b.A != c.A || b.B != c.B {
+		log.Printf("warning: baseline curve (p=%s A=%s B=%s) differs from this one", b.P, b.A, b.B)
+	} else if b.Mode != c.Mode || b.MaxMem != c.MaxMem || b.Workers != c.Workers {
+		log.Printf("warning: baseline mode/max-mem/workers (%s/%s/%d) differ from this run's", b.Mode, b.MaxMem, b.Workers)
+	} else if b.Format != c.Format || b.Compress != c.Compress || b.toFile() != c.toFile() {
+		log.Printf("warning: baseline output (format %q, compress %q, out %q) differs from this run's", b.Format, b.Compress, b.ScanOut)
+	}
+	if base.Summary.Points != cur.Summary.Points {
+		log.Printf("warning: point count differs from the baseline (%d -> %d)", base.Summary.Points, cur.Summary.Points)
+	}
+	return cur.Summary.AvgSeconds/base.Summary.AvgSeconds - 1
+}
+
+// summarize computes the statistics over the timed runs.
+func summarize(runs []benchRun) benchSummary {
+	var sum benchSummary
+	if len(runs) == 0 {
+		return sum
+	}
+	secs := make([]float64, len(runs))
+	total, scan := 0.0, 0.0
+	for i, r := range runs {
+		secs[i] = r.Seconds
+		total += r.Seconds
+		scan += r.ScanSeconds
+	}
+	for _, r := range runs {
+		sum.PeakRSS = max(sum.PeakRSS, r.PeakRSS)
+	}
+	slices.Sort(secs)
+	n := float64(len(secs))
+	sum.Points = runs[len(runs)-1].Points
+	sum.AvgSeconds = total / n
+	sum.AvgScanSeconds = scan / n
+	sum.MinSeconds, sum.MaxSeconds = secs[0], secs[len(secs)-1]
+	if len(secs) > 1 {
+		ss := 0.0
+		for _, v := range secs {
+			ss += (v - sum.AvgSeconds) * (v - sum.AvgSeconds)
+		}
+		sum.StddevSeconds = math.Sqrt(ss / (n - 1))
+	}
+	sum.P95Seconds = secs[int(math.Ceil(0.95*n))-1]
+	if sum.AvgSeconds > 0 {
+		sum.PointsPerSec = float64(sum.Points) / sum.AvgSeconds
+	}
+	return sum
+}
+
+// bench runs c's warmups and timed runs and gathers the results. With a
+// profileDir, one more run follows with ecscan's --cpuprofile and
+// --memprofile writing into it; it is kept out of the timings.
+func bench(bin string, c benchConfig, label string, timeout time.Duration, quiet bool, profileDir string) (benchResults, error) {
+	args := c.args()
+	title := "ecscan bench"
+	if label != "" {
+		title += " - " + label
+	}
+	log.Printf("%s", title)
+	log.Printf("cmd: %s %s", bin, strings.Join(args, " "))
+
+	// Warmups
+	for i := 0; i < c.Warmup; i++ {
+		if !quiet {
+			log.Printf("warmup %d/%d ...", i+1, c.Warmup)
+		}
+		_ = runOnce(bin, args, timeout, quiet) // ignore results
+	}
+
+	// Timed runs
+	res := benchResults{Label: label, Timestamp: time.Now().UTC(), Ecscan: bin, Args: args, Config: c}
+	var lastPoints int64 = -1
+	for i := 0; i < c.Runs; i++ {
+		r := runOnce(bin, args, timeout, quiet)
+		if r.err != nil {
+			return res, fmt.Errorf("run %d/%d failed: %v", i+1, c.Runs, r.err)
+		}
+		if lastPoints >= 0 && r.points != lastPoints {
+			log.Printf("warning: point count changed between runs (%d -> %d)", lastPoints, r.points)
+		}
+		lastPoints = r.points
+
+		if !quiet {
+			log.Printf("run %d/%d: %v (ecscan %v), points=%d", i+1, c.Runs, r.duration, r.scan, r.points)
+		}
+		res.Runs = append(res.Runs, newBenchRun(i+1, r))
+	}
+	res.Summary = summarize(res.Runs)
+
+	if profileDir != "" {
+		base := filepath.Join(profileDir, c.slug(label))
+		pr := &profiles{CPU: base + "-cpu.pprof", Mem: base + "-mem.pprof"}
+		pr.Args = []string{"--cpuprofile=" + pr.CPU, "--memprofile=" + pr.Mem}
+		log.Printf("profiling run: %s", strings.Join(pr.Args, " "))
+		if r := runOnce(bin, append(slices.Clip(args), pr.Args...), timeout, quiet); r.err != nil {
+			return res, fmt.Errorf("profiling run failed (does %s take --cpuprofile and --memprofile?): %v", bin, r.err)
+		}
+		res.Profiles = pr
+	}
+	return res, nil
+}
+
+// slug names c's profile files: the label (if any), p, mode and workers.
+func (c benchConfig) slug(label string) string {
+	s := fmt.Sprintf("p%s-%s-w%d", c.P, c.Mode, c.Workers)
+	if c.Format != "" && c.Format != "text" {
+		s += "-" + c.Format
+	}
+	if label != "" {
+		s = label + "-" + s
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' || r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// matrixSpec is a -matrix scenario file: benchscan runs every combination
+// of P, Modes, Workers and Formats. Fields left out take the command-line
+// flags.
+type matrixSpec struct {
+	A        string   `json:"A"`
+	B        string   `json:"B"`
+	P        []string `json:"p"`
+	Modes    []string `json:"modes"`
+	Workers  []int    `json:"workers"` // 0 is ecscan's default
+	Formats  []string `json:"formats"`
+	Compress string   `json:"compress"`
+	ScanOut  string   `json:"scanOut"`
+	MaxMem   string   `json:"maxMem"`
+	Runs     int      `json:"runs"`
+	Warmup   *int     `json:"warmup"`
+	Timeout  string   `json:"timeout"` // per run, e.g. "10m"
+}
+
+// readMatrix expands the scenario file at path into its cross-product,
+// filling what it leaves out from def.
+func readMatrix(path string, def benchConfig, timeout *time.Duration) ([]benchConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m matrixSpec
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.A != "" {
+		def.A = m.A
+	}
+	if m.B != "" {
+		def.B = m.B
+	}
+	if m.MaxMem != "" {
+		def.MaxMem = m.MaxMem
+	}
+	if m.Compress != "" {
+		def.Compress = m.Compress
+	}
+	if m.ScanOut != "" {
+		def.ScanOut = m.ScanOut
+	}
+	if m.Runs > 0 {
+		def.Runs = m.Runs
+	}
+	if m.Warmup != nil {
+		def.Warmup = *m.Warmup
+	}
+	if m.Timeout != "" {
+		if *timeout, err = time.ParseDuration(m.Timeout); err != nil {
+			return nil, fmt.Errorf("%s: timeout: %w", path, err)
+		}
+	}
+	ps, modes, workers, formats := m.P, m.Modes, m.Workers, m.Formats
+	if len(ps) == 0 {
+		if def.P == "" {
+			return nil, fmt.Errorf("%s: no p given (in the file or by -p)", path)
+		}
+		ps = []string{def.P}
+	}
+	if len(modes) == 0 {
+		modes = []string{def.Mode}
+	}
+	if len(workers) == 0 {
+		workers = []int{def.Workers}
+	}
+	if len(formats) == 0 {
+		formats = []string{def.Format}
+	}
+	var cs []benchConfig
+	for _, p := range ps {
+		for _, mode := range modes {
+			for _, w := range workers {
+				for _, f := range formats {
+					c := def
+					c.P, c.Mode, c.Workers, c.Format = p, mode, w, f
+					cs = append(cs, c)
+				}
+			}
+		}
+	}
+	return cs, nil
+}
+
+// printMatrix prints one row per scenario; "vs best" is the mean time
+// against the fastest scenario with the same p.
+func printMatrix(rs []benchResults) {
+	best := map[string]float64{}
+	for _, r := range rs {
+		if b, ok := best[r.Config.P]; !ok || r.Summary.AvgSeconds < b {
+			best[r.Config.P] = r.Summary.AvgSeconds
+		}
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "p\tmode\tworkers\tformat\tpoints\tmean s\tstddev s\tp95 s\tmin s\tecscan s\tpoints/s\tpeak RSS\tvs best\t")
+	for _, r := range rs {
+		c, s := r.Config, r.Summary
+		w := "default"
+		if c.Workers > 0 {
+			w = strconv.Itoa(c.Workers)
+		}
+		vs := "-"
+		if b := best[c.P]; b > 0 {
+			vs = fmt.Sprintf("x%.2f", s.AvgSeconds/b)
+		}
+		f := c.Format
+		if c.Compress != "" && c.Compress != "none" {
+			f += "+" + c.Compress
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4g\t%s\t%s\t\n",
+			c.P, c.Mode, w, f, s.Points, s.AvgSeconds, s.StddevSeconds, s.P95Seconds, s.MinSeconds, s.AvgScanSeconds, s.PointsPerSec, mib(s.PeakRSS), vs)
+	}
+	tw.Flush()
+	for _, r := range rs {
+		if r.Profiles != nil {
+			fmt.Printf("profiles p=%s mode=%s workers=%d format=%s: %s %s\n", r.Config.P, r.Config.Mode, r.Config.Workers, r.Config.Format, r.Profiles.CPU, r.Profiles.Mem)
+		}
+	}
+}
+
+// mib formats a byte count for the reports; 0 is unknown.
+func mib(b int64) string {
+	if b == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f MiB", float64(b)/(1<<20))
 }
 
 func main() {
@@ -105,6 +582,9 @@ func main() {
 		mode    = flag.String("mode", "auto", "ecscan mode: auto|table|onthefly")
 		maxMem  = flag.String("max-mem", "48GB", "memory cap for table-mode decision")
 		workers = flag.Int("workers", 0, "worker override (0 => GOMAXPROCS*4)")
+		format  = flag.String("format", "text", "ecscan output format: text|csv|ndjson|compressed|sage|gp")
+		comp    = flag.String("compress", "none", "ecscan output compression: none|gzip|zstd")
+		scanOut = flag.String("scan-out", "-", "ecscan --out for the points: - (stdout, discarded) or a file, rewritten by every run")
 
 		// bench controls
 		runs    = flag.Int("runs", 3, "number of timed runs")
@@ -112,72 +592,87 @@ func main() {
 		timeout = flag.Duration("timeout", 0, "per-run timeout (e.g. 10m, 0 = none)")
 		label   = flag.String("label", "", "optional label for this scenario")
 		quiet   = flag.Bool("quiet", false, "suppress ecscan stderr logs")
+		out     = flag.String("out", "", "also write the results to this file: JSON, or CSV rows appended if it ends in .csv")
+		matrix  = flag.String("matrix", "", "JSON scenario file: sweep the cross-product of its p, modes and workers lists")
+		profile = flag.String("profile", "", "directory for CPU and heap profiles from one extra ecscan run per scenario (needs ecscan's --cpuprofile/--memprofile)")
+
+		// regression gate
+		baseline  = flag.String("baseline", "", "JSON results (from -out) to compare the average run time against")
+		failAbove = flag.String("fail-above", "10%", "with -baseline, exit non-zero if the average run time grows by more than this")
 	)
 	flag.Parse()
 
-	if strings.TrimSpace(*p) == "" {
-		log.Fatal("benchscan: missing required -p")
-	}
-
-	// Build ecscan args – output to stdout so we can count lines.
-	args := []string{
-		"--p=" + *p,
-		"--A=" + *A,
-		"--B=" + *B,
-		"--mode=" + *mode,
-		"--max-mem=" + *maxMem,
-		"--out=-",
-	}
-	if *workers > 0 {
-		args = append(args, fmt.Sprintf("--workers=%d", *workers))
-	}
-
-	title := "ecscan bench"
-	if *label != "" {
-		title += " - " + *label
+	cfg := benchConfig{
+		P: *p, A: *A, B: *B, Mode: *mode, MaxMem: *maxMem,
+		Workers: *workers, Runs: *runs, Warmup: *warmup,
+		Format: *format, Compress: *comp, ScanOut: *scanOut,
 	}
-	log.Printf("%s", title)
-	log.Printf("cmd: %s %s", *bin, strings.Join(args, " "))
 
-	// Warmups
-	for i := 0; i < *warmup; i++ {
-		if !*quiet {
-			log.Printf("warmup %d/%d ...", i+1, *warmup)
+	if *profile != "" {
+		if err := os.MkdirAll(*profile, 0o755); err != nil {
+			log.Fatalf("benchscan: -profile: %v", err)
 		}
-		_ = runOnce(*bin, args, *timeout, *quiet) // ignore results
 	}
 
-	// Timed runs
-	var total time.Duration
-	var min, max time.Duration
-	var lastPoints int64 = -1
-	for i := 0; i < *runs; i++ {
-		res := runOnce(*bin, args, *timeout, *quiet)
-		if res.err != nil {
-			log.Fatalf("run %d/%d failed: %v", i+1, *runs, res.err)
+	if *matrix != "" {
+		if *baseline != "" {
+			log.Fatal("benchscan: -baseline compares a single scenario, not a -matrix")
 		}
-		if lastPoints >= 0 && res.points != lastPoints {
-			log.Printf("warning: point count changed between runs (%d -> %d)", lastPoints, res.points)
+		cs, err := readMatrix(*matrix, cfg, timeout)
+		if err != nil {
+			log.Fatalf("benchscan: -matrix: %v", err)
 		}
-		lastPoints = res.points
+		log.Printf("matrix: %d scenarios", len(cs))
+		var rs []benchResults
+		for i, c := range cs {
+			log.Printf("scenario %d/%d: p=%s mode=%s workers=%d format=%s", i+1, len(cs), c.P, c.Mode, c.Workers, c.Format)
+			res, err := bench(*bin, c, *label, *timeout, *quiet, *profile)
+			if err != nil {
+				log.Fatalf("benchscan: scenario %d/%d: %v", i+1, len(cs), err)
+			}
+			rs = append(rs, res)
+		}
+		fmt.Println("---- matrix ----")
+		printMatrix(rs)
+		if *out != "" {
+			if err := writeResults(*out, rs...); err != nil {
+				log.Fatalf("benchscan: -out: %v", err)
+			}
+			log.Printf("results written to %s", *out)
+		}
+		return
+	}
 
-		if !*quiet {
-			log.Printf("run %d/%d: %v, points=%d", i+1, *runs, res.duration, res.points)
+	if strings.TrimSpace(*p) == "" {
+		log.Fatal("benchscan: missing required -p")
+	}
+
+	// Read the baseline up front, so a bad path fails before the runs.
+	var base *benchResults
+	var limit float64
+	if *baseline != "" {
+		var err error
+		if base, err = readBaseline(*baseline); err != nil {
+			log.Fatalf("benchscan: -baseline: %v", err)
 		}
-		if i == 0 || res.duration < min {
-			min = res.duration
+		if limit, err = parsePercent(*failAbove); err != nil {
+			log.Fatalf("benchscan: -fail-above: %v", err)
 		}
-		if res.duration > max {
-			max = res.duration
+		if *runs < 1 {
+			log.Fatal("benchscan: -baseline needs at least one timed run")
 		}
-		total += res.duration
 	}
 
-	avg := time.Duration(0)
-	if *runs > 0 {
-		avg = time.Duration(int64(total) / int64(*runs))
+	res, err := bench(*bin, cfg, *label, *timeout, *quiet, *profile)
+	if err != nil {
+		log.Fatal(err)
 	}
+	sum := res.Summary
 
+	title := "ecscan bench"
+	if *label != "" {
+		title += " - " + *label
+	}
 	fmt.Println("---- summary ----")
 	fmt.Printf("label:    %s\n", title)
 	fmt.Printf("p:        %s\n", *p)
@@ -187,7 +682,37 @@ func main() {
 	if *workers > 0 {
 		fmt.Printf("workers:  %d\n", *workers)
 	}
+	fmt.Printf("output:   %s", *format)
+	if *comp != "none" {
+		fmt.Printf("+%s", *comp)
+	}
+	fmt.Printf(" to %s\n", *scanOut)
 	fmt.Printf("runs:     %d (warmup=%d)\n", *runs, *warmup)
-	fmt.Printf("points:   %d (affine; infinity sentinel excluded if present)\n", lastPoints)
-	fmt.Printf("time:     avg=%v  min=%v  max=%v\n", avg, min, max)
+	if len(res.Runs) == 0 {
+		sum.Points = -1
+	}
+	fmt.Printf("points:   %d (affine, from ecscan's summary)\n", sum.Points)
+	fmt.Printf("time:     avg=%v  min=%v  max=%v\n", secs(sum.AvgSeconds), secs(sum.MinSeconds), secs(sum.MaxSeconds))
+	fmt.Printf("ecscan:   avg=%v (its own timing, without process startup)\n", secs(sum.AvgScanSeconds))
+	fmt.Printf("spread:   stddev=%v  p95=%v\n", secs(sum.StddevSeconds), secs(sum.P95Seconds))
+	fmt.Printf("peak RSS: %s\n", mib(sum.PeakRSS))
+	if pr := res.Profiles; pr != nil {
+		fmt.Printf("profiles: %s %s\n", pr.CPU, pr.Mem)
+	}
+
+	if *out != "" {
+		if err := writeResults(*out, res); err != nil {
+			log.Fatalf("benchscan: -out: %v", err)
+		}
+		log.Printf("results written to %s", *out)
+	}
+
+	if base != nil {
+		change := compareBaseline(*base, res)
+		fmt.Printf("baseline: avg=%.6gs -> %.6gs (%+.1f%%, limit +%g%%)\n",
+			base.Summary.AvgSeconds, sum.AvgSeconds, 100*change, 100*limit)
+		if change > limit {
+			log.Fatalf("benchscan: regression: avg time %+.1f%% against %s (limit +%g%%)", 100*change, *baseline, 100*limit)
+		}
+	}
 }
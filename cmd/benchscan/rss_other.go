@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// peakRSS is only measured on Linux; elsewhere it is 0 (unknown).
+func peakRSS(ps *os.ProcessState) int64 { return 0 }
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"ectorus/internal/ecdecompress"
+)
+
+func main() {
+	cfg, err := ecdecompress.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ecdecompress.Run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
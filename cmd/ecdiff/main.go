@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"ectorus/internal/ecdiff"
+)
+
+func main() {
+	cfg, err := ecdiff.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ecdiff.Run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"ectorus/internal/ecdlp"
+)
+
+func main() {
+	cfg, err := ecdlp.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ecdlp.Run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"ectorus/internal/ecfactor"
+)
+
+func main() {
+	cfg, err := ecfactor.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ecfactor.Run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"ectorus/internal/ecgen"
+)
+
+func main() {
+	cfg, err := ecgen.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ecgen.Run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
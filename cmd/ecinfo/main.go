@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"ectorus/internal/ecinfo"
+)
+
+func main() {
+	cfg, err := ecinfo.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ecinfo.Run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
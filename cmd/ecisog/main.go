@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"ectorus/internal/ecisog"
+)
+
+func main() {
+	cfg, err := ecisog.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ecisog.Run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
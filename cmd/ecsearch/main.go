@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"ectorus/internal/ecsearch"
+)
+
+func main() {
+	cfg, err := ecsearch.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ecsearch.Run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
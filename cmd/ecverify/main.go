@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"ectorus/internal/ecverify"
+)
+
+func main() {
+	cfg, err := ecverify.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ecverify.Run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"ectorus/internal/griddiff"
+)
+
+func main() {
+	cfg, err := griddiff.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := griddiff.Run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+)
+
+// ---------- -audit: the exclusions against a brute-force scan ----------
+
+// auditLimit bounds p for -audit: every x is tried, and without a grid
+// each point is tested against every processed slope.
+const auditLimit = 100_000
+
+// AuditOut reports a -audit: every affine point of E, found by trying each
+// x, must be found or unknown, never excluded; every found point must lie
+// on E; and with -grid, the grid's found cells must be the found points.
+type AuditOut struct {
+	Checked  int      `json:"checkedAffine"` // affine points of E tried
+	Found    int      `json:"found"`
+	Unknown  int      `json:"unknown"`
+	Pass     bool     `json:"pass"`
+	Failures int      `json:"failures"`
+	Problems []string `json:"problems,omitempty"` // first crossCheckList
+}
+
+func (a *AuditOut) fail(format string, args ...any) {
+	a.Failures++
+	if len(a.Problems) < crossCheckList {
+		a.Problems = append(a.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// audit checks the walk's exclusions against the curve itself. It reads
+// the grid or the processed lines directly rather than through Classify,
+// which answers found before it looks at them, so a line intersection
+// that excluded a found point shows up too.
+func (e *Engine) audit() (*AuditOut, error) {
+	p := e.C.P
+	if p.Cmp(big.NewInt(auditLimit)) > 0 {
+		return nil, fmt.Errorf("-audit tries every x; it needs p ≤ %d, got p=%s", auditLimit, p)
+	}
+	e.ensureMaps()
+	a := &AuditOut{}
+	for x := range p.Int64() {
+		for _, P := range e.pointsAtX(big.NewInt(x)) {
+			a.Checked++
+			_, found := e.found[e.pointKey(P)]
+			switch {
+			case e.G != nil && e.G.isExcluded(int(P.X.Int64()), int(P.Y.Int64())):
+				if found {
+					a.fail("(%s, %s) is a found point but excluded on the grid", P.X, P.Y)
+				} else {
+					a.fail("(%s, %s) is on the curve but excluded", P.X, P.Y)
+				}
+			// every processed line passes through its own curve points, so
+			// only an unfound one may not be covered
+			case e.G == nil && !found && e.implicit.covers(P.X, P.Y, e.field()):
+				a.fail("(%s, %s) is on the curve but excluded", P.X, P.Y)
+			case found:
+				a.Found++
+			default:
+				a.Unknown++
+			}
+		}
+	}
+	for _, P := range e.found {
+		if !P.Inf && !e.onCurve(P) {
+			a.fail("found point (%s, %s) is not on the curve", P.X, P.Y)
+		}
+	}
+	if e.G != nil {
+		e.auditGrid(a)
+	}
+	a.Pass = a.Failures == 0
+	return a, nil
+}
+
+// auditGrid checks that the grid marks exactly the found points as found.
+func (e *Engine) auditGrid(a *AuditOut) {
+	g := e.G
+	var found [chunkWords]uint64
+	n := 0
+	for ci := range (g.p*g.p + chunkSize - 1) / chunkSize {
+		g.found.chunk(ci, found[:])
+		for j, w := range found {
+			for ; w != 0; w &= w - 1 {
+				i := ci*chunkSize + 64*j + bits.TrailingZeros64(w)
+				x, y := big.NewInt(int64(i%g.p)), big.NewInt(int64(i/g.p))
+				n++
+				if _, ok := e.found[ptKey{x: coordOf(x), y: coordOf(y)}]; !ok {
+					a.fail("grid cell (%s, %s) is marked found but is not a found point", x, y)
+				}
+			}
+		}
+	}
+	if n < a.Found {
+		a.fail("the grid marks %d cells found, fewer than the %d found points", n, a.Found)
+	}
+}
+
+func printAudit(w io.Writer, a *AuditOut) {
+	fmt.Fprintf(w, "\nAudit: %d affine points of E, %d found, %d unknown — ", a.Checked, a.Found, a.Unknown)
+	if a.Pass {
+		fmt.Fprintln(w, "pass")
+		return
+	}
+	fmt.Fprintf(w, "FAIL (%d problems)\n", a.Failures)
+	for _, s := range a.Problems {
+		fmt.Fprintf(w, "  %s\n", s)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"ectorus/internal/ec"
+)
+
+// ---------- batched slopes ----------
+
+// lineJob is one tangent (Q == nil) or secant the walk wants processed.
+type lineJob struct {
+	P Point
+	Q *Point
+}
+
+// processLines processes jobs in order. On a Weierstrass curve the slopes
+// are computed together, with a single inversion for all denominators
+// (ec.BatchInvM), and the third points come from the slopes without any
+// further inversion; other models go line by line.
+func (e *Engine) processLines(jobs []lineJob) error {
+	lines, ok, err := e.jobLines(jobs)
+	if err != nil {
+		return err
+	}
+	for i, j := range jobs {
+		if e.coverageReached() {
+			return nil
+		}
+		if !ok[i] {
+			continue
+		}
+		if err := e.processLine(lines[i], j.P, j.Q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jobLines returns the line of every job, batched through chordLines on a
+// Weierstrass curve. When p is composite a slope's denominator can share a
+// factor with p: the batch then fails as a whole and the lines are redone
+// one at a time, and each job whose own inversion fails is recorded by
+// noteFactor and left out (ok[i] false) instead of ending the walk.
+func (e *Engine) jobLines(jobs []lineJob) (lines []Line, ok []bool, err error) {
+	ok = make([]bool, len(jobs))
+	if e.Model == nil {
+		if lines, err = chordLines(e.C, jobs); err == nil {
+			for i := range ok {
+				ok[i] = true
+			}
+			return lines, ok, nil
+		}
+		var ni *ec.NotInvertibleError
+		if !errors.As(err, &ni) {
+			return nil, nil, err
+		}
+	}
+	lines = make([]Line, len(jobs))
+	for i, j := range jobs {
+		if lines[i], err = e.line(j.P, j.Q); err == nil {
+			ok[i] = true
+		} else if err = e.noteFactor(err, j); err != nil {
+			return nil, nil, err
+		}
+	}
+	return lines, ok, nil
+}
+
+// ---------- factors from failed inversions ----------
+
+// FactorOut is a factor of p the walk stumbled on: a line whose slope
+// denominator d has 1 < gcd(d, p) < p, as in Lenstra's ECM.
+type FactorOut struct {
+	Factor   string `json:"factor"`
+	Cofactor string `json:"cofactor"`
+	Line     string `json:"line"` // where it first turned up
+	Hits     int    `json:"hits"` // lines skipped for it
+}
+
+// noteFactor records the factor behind a failed inversion for job j and
+// returns nil, or returns err unchanged when it is not such a failure.
+func (e *Engine) noteFactor(err error, j lineJob) error {
+	var ni *ec.NotInvertibleError
+	if !errors.As(err, &ni) {
+		return err
+	}
+	g := ni.Factor()
+	if g.Cmp(big.NewInt(1)) <= 0 || g.Cmp(e.C.P) >= 0 {
+		return err
+	}
+	// report the smaller half of the split
+	if h := new(big.Int).Quo(e.C.P, g); h.Cmp(g) < 0 {
+		g = h
+	}
+	for _, f := range e.factors {
+		if f.Factor == g.String() {
+			f.Hits++
+			return nil
+		}
+	}
+	where := fmt.Sprintf("tangent at (%s, %s)", j.P.X, j.P.Y)
+	if j.Q != nil {
+		where = fmt.Sprintf("secant through (%s, %s) and (%s, %s)", j.P.X, j.P.Y, j.Q.X, j.Q.Y)
+	}
+	e.factors = append(e.factors, &FactorOut{
+		Factor: g.String(), Cofactor: new(big.Int).Quo(e.C.P, g).String(), Line: where, Hits: 1,
+	})
+	fmt.Fprintf(os.Stderr, "found the factor %s of p on the %s\n", g, where)
+	return nil
+}
+
+// chordLines is lineThrough for many jobs at once.
+func chordLines(c Curve, jobs []lineJob) ([]Line, error) {
+	p := c.P
+	lines := make([]Line, len(jobs))
+	nums := make([]*big.Int, 0, len(jobs))
+	dens := make([]*big.Int, 0, len(jobs))
+	idx := make([]int, 0, len(jobs))
+	for i, j := range jobs {
+		P, Q := j.P, j.Q
+		var num, den *big.Int
+		switch {
+		case Q == nil || (P.X.Cmp(Q.X) == 0 && ec.AddM(P.Y, Q.Y, p).Sign() != 0):
+			// tangent at P
+			if P.Y.Sign() == 0 {
+				lines[i] = Line{Vertical: true, V: new(big.Int).Set(P.X)}
+				continue
+			}
+			num = ec.AddM(ec.MulM(big.NewInt(3), ec.MulM(P.X, P.X, p), p), c.A, p)
+			den = ec.MulM(big.NewInt(2), P.Y, p)
+		case P.X.Cmp(Q.X) == 0:
+			// secant through P and -P
+			lines[i] = Line{Vertical: true, V: new(big.Int).Set(P.X)}
+			continue
+		default:
+			num = ec.SubM(Q.Y, P.Y, p)
+			den = ec.SubM(Q.X, P.X, p)
+		}
+		nums, dens, idx = append(nums, num), append(dens, den), append(idx, i)
+	}
+	invs, err := ec.BatchInvM(dens, p)
+	if err != nil {
+		return nil, err
+	}
+	for k, i := range idx {
+		P := jobs[i].P
+		m := ec.MulM(nums[k], invs[k], p)
+		lines[i] = Line{M: m, C: ec.SubM(P.Y, ec.MulM(m, P.X, p), p)}
+	}
+	return lines, nil
+}
+
+// chordSum adds P and Q (doubles P for a tangent) given the line's slope:
+// R = P+Q as x_R = m² - x_P - x_Q, y_R = m(x_P - x_R) - y_P, with no
+// inversion. It also returns the affine points of E on L: P, Q and the
+// third intersection -R, which is R's reflection, not R.
+func chordSum(c Curve, L Line, P Point, Q *Point) (Point, []Point) {
+	if L.Vertical {
+		if Q == nil {
+			return Point{Inf: true}, []Point{P, c.Neg(P)}
+		}
+		return Point{Inf: true}, []Point{P, *Q}
+	}
+	p := c.P
+	xQ := P.X
+	if Q != nil {
+		xQ = Q.X
+	}
+	x := ec.SubM(ec.SubM(ec.MulM(L.M, L.M, p), P.X, p), xQ, p)
+	y := ec.SubM(ec.MulM(L.M, ec.SubM(P.X, x, p), p), P.Y, p)
+	R := Point{X: x, Y: y}
+	if Q == nil {
+		return R, []Point{P, c.Neg(R)}
+	}
+	return R, []Point{P, *Q, c.Neg(R)}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// ---------- grid storage ----------
+
+// bitset is a set of grid cell indices.
+type bitset interface {
+	set(i int) bool // reports whether i was newly added
+	get(i int) bool
+	memBytes() int
+	// chunk fills buf (chunkSize/64 words) with chunk ci, bit k of word j
+	// being cell ci*chunkSize + 64j + k; orChunk adds such words to the set.
+	chunk(ci int, buf []uint64)
+	orChunk(ci int, words []uint64)
+}
+
+func (b *Bitset) memBytes() int { return 8 * len(b.bits) }
+
+func (b *Bitset) chunk(ci int, buf []uint64) {
+	clear(buf[copy(buf, b.bits[ci*chunkWords:]):])
+}
+
+func (b *Bitset) orChunk(ci int, words []uint64) {
+	for j, w := range b.bits[ci*chunkWords : min(len(b.bits), (ci+1)*chunkWords)] {
+		b.bits[ci*chunkWords+j] = w | words[j]
+	}
+}
+
+// chunkBits is the span of one sparseBitset container (as in roaring).
+const (
+	chunkBits   = 12
+	chunkSize   = 1 << chunkBits
+	chunkWords  = chunkSize / 64
+	arrayMax    = chunkSize / 16 // beyond this an array outweighs a bitmap
+	bitmapBytes = chunkSize / 8
+)
+
+// sparseBitset is a roaring-style compressed bitset: each 2^12-bit chunk is
+// empty (nil), a sorted array of offsets while sparse, a bitmap once it
+// holds more than arrayMax cells, and a shared full marker once saturated.
+// The found set stays in arrays; the excluded set drifts through bitmaps
+// to full.
+type sparseBitset struct {
+	chunks []*container
+	bytes  int
+}
+
+type container struct {
+	arr  []uint16 // sorted; used while bm == nil and !full
+	bm   []uint64
+	n    int // cells set (bitmap)
+	full bool
+}
+
+var fullChunk = &container{full: true}
+
+func newSparseBitset(n int) *sparseBitset {
+	c := (n + chunkSize - 1) / chunkSize
+	return &sparseBitset{chunks: make([]*container, c), bytes: 8 * c}
+}
+
+func (s *sparseBitset) memBytes() int { return s.bytes }
+
+func (s *sparseBitset) get(i int) bool {
+	c := s.chunks[i>>chunkBits]
+	if c == nil {
+		return false
+	}
+	if c.full {
+		return true
+	}
+	off := uint16(i & (chunkSize - 1))
+	if c.bm != nil {
+		return c.bm[off>>6]>>(off&63)&1 == 1
+	}
+	k := search16(c.arr, off)
+	return k < len(c.arr) && c.arr[k] == off
+}
+
+func (s *sparseBitset) set(i int) bool {
+	ci := i >> chunkBits
+	c := s.chunks[ci]
+	off := uint16(i & (chunkSize - 1))
+	switch {
+	case c == nil:
+		s.chunks[ci] = &container{arr: []uint16{off}}
+		s.bytes += 2
+		return true
+	case c.full:
+		return false
+	case c.bm != nil:
+		w, b := off>>6, uint64(1)<<(off&63)
+		if c.bm[w]&b != 0 {
+			return false
+		}
+		c.bm[w] |= b
+		if c.n++; c.n == chunkSize {
+			s.chunks[ci] = fullChunk
+			s.bytes -= bitmapBytes
+		}
+		return true
+	}
+	k := search16(c.arr, off)
+	if k < len(c.arr) && c.arr[k] == off {
+		return false
+	}
+	if len(c.arr) < arrayMax {
+		c.arr = append(c.arr, 0)
+		copy(c.arr[k+1:], c.arr[k:])
+		c.arr[k] = off
+		s.bytes += 2
+		return true
+	}
+	// array → bitmap
+	c.bm = make([]uint64, chunkSize/64)
+	for _, a := range c.arr {
+		c.bm[a>>6] |= 1 << (a & 63)
+	}
+	c.bm[off>>6] |= 1 << (off & 63)
+	s.bytes += bitmapBytes - 2*len(c.arr)
+	c.n, c.arr = len(c.arr)+1, nil
+	return true
+}
+
+func (s *sparseBitset) chunk(ci int, buf []uint64) {
+	c := s.chunks[ci]
+	switch {
+	case c == nil:
+		clear(buf)
+	case c.full:
+		for j := range buf {
+			buf[j] = ^uint64(0)
+		}
+	case c.bm != nil:
+		copy(buf, c.bm)
+	default:
+		clear(buf)
+		for _, a := range c.arr {
+			buf[a>>6] |= 1 << (a & 63)
+		}
+	}
+}
+
+// orChunk merges words into chunk ci and rebuilds its container in the
+// form set would have left it: an array, a bitmap or full.
+func (s *sparseBitset) orChunk(ci int, words []uint64) {
+	var bm [chunkWords]uint64
+	s.chunk(ci, bm[:])
+	n := 0
+	for j := range bm {
+		bm[j] |= words[j]
+		n += bits.OnesCount64(bm[j])
+	}
+	switch c := s.chunks[ci]; {
+	case c == nil, c.full:
+	case c.bm != nil:
+		s.bytes -= bitmapBytes
+	default:
+		s.bytes -= 2 * len(c.arr)
+	}
+	switch {
+	case n == 0:
+		s.chunks[ci] = nil
+	case n == chunkSize:
+		s.chunks[ci] = fullChunk
+	case n <= arrayMax:
+		arr := make([]uint16, 0, n)
+		for j, w := range bm {
+			for ; w != 0; w &= w - 1 {
+				arr = append(arr, uint16(64*j+bits.TrailingZeros64(w)))
+			}
+		}
+		s.chunks[ci] = &container{arr: arr}
+		s.bytes += 2 * n
+	default:
+		s.chunks[ci] = &container{bm: append([]uint64(nil), bm[:]...), n: n}
+		s.bytes += bitmapBytes
+	}
+}
+
+// search16 is sort.Search for a sorted []uint16, without the closure.
+func search16(a []uint16, v uint16) int {
+	lo, hi := 0, len(a)
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		if a[m] < v {
+			lo = m + 1
+		} else {
+			hi = m
+		}
+	}
+	return lo
+}
+
+// popcount is used by tests to check conversions keep every bit.
+func (s *sparseBitset) popcount() int {
+	n := 0
+	for _, c := range s.chunks {
+		switch {
+		case c == nil:
+		case c.full:
+			n += chunkSize
+		case c.bm != nil:
+			for _, w := range c.bm {
+				n += bits.OnesCount64(w)
+			}
+		default:
+			n += len(c.arr)
+		}
+	}
+	return n
+}
+
+// Grid storage choices (-grid_store).
+const (
+	storeAuto   = "auto"
+	storeDense  = "dense"
+	storeSparse = "sparse"
+)
+
+// newGridStore makes a grid whose bitsets are dense when store says so, or
+// (auto) when both fit in capBytes; otherwise compressed. Over capBytes,
+// the grid reports itself full via overCap.
+func newGridStore(p int, store string, capBytes uint64) (*Grid, error) {
+	cells := p * p
+	dense := uint64(2 * ((cells + 63) / 64) * 8)
+	switch store {
+	case storeAuto:
+		if dense <= capBytes {
+			store = storeDense
+		} else {
+			store = storeSparse
+		}
+	case storeDense:
+		if dense > capBytes {
+			return nil, fmt.Errorf("dense grid needs %s; -grid_mem allows %s (try -grid_store sparse)", fmtBytes(dense), fmtBytes(capBytes))
+		}
+	case storeSparse:
+	default:
+		return nil, fmt.Errorf("unknown -grid_store %q (want auto|dense|sparse)", store)
+	}
+	g := &Grid{p: p, store: store, capBytes: capBytes}
+	if store == storeDense {
+		g.found, g.excl = newBitset(cells), newBitset(cells)
+	} else {
+		g.found, g.excl = newSparseBitset(cells), newSparseBitset(cells)
+	}
+	return g, nil
+}
+
+// fresh returns an empty grid with g's storage settings (for the twist).
+func (g *Grid) fresh() *Grid {
+	if g.store == "" {
+		return newGrid(g.p)
+	}
+	n, err := newGridStore(g.p, g.store, g.capBytes)
+	if err != nil {
+		return newGrid(g.p)
+	}
+	return n
+}
+
+func (g *Grid) memBytes() int { return g.found.memBytes() + g.excl.memBytes() }
+
+// overCap reports whether compressed storage has outgrown -grid_mem.
+func (g *Grid) overCap() error {
+	if g.capBytes == 0 || uint64(g.memBytes()) <= g.capBytes {
+		return nil
+	}
+	return fmt.Errorf("grid storage grew to %s, over -grid_mem %s", fmtBytes(uint64(g.memBytes())), fmtBytes(g.capBytes))
+}
+
+// parseBytes reads sizes like "512MB", "2GB" or a plain byte count.
+func parseBytes(s string) (uint64, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	mult := uint64(1)
+	for _, u := range []struct {
+		suf string
+		m   uint64
+	}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(t, u.suf) {
+			t, mult = strings.TrimSuffix(t, u.suf), u.m
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("bad size %q", s)
+	}
+	return uint64(v * float64(mult)), nil
+}
+
+func fmtBytes(b uint64) string {
+	switch {
+	case b >= 1<<30:
+		return fmt.Sprintf("%.2fGB", float64(b)/(1<<30))
+	case b >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(b)/(1<<20))
+	}
+	return fmt.Sprintf("%dKB", b>>10)
+}
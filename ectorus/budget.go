@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"runtime/metrics"
+	"time"
+)
+
+// ---------- -max_seconds / -max_mem ----------
+
+// budget bounds a walk by wall time and memory. The walk loops ask
+// exceeded between lines; once it reports true the walk winds down where
+// it is and the output is marked truncated, with whatever the walk found
+// and classified so far.
+type budget struct {
+	start    time.Time
+	deadline time.Time // zero: no time limit
+	maxMem   uint64    // bytes of Go memory, 0: no limit
+	calls    int
+	hit      string // what ran out, once something has
+	samples  []metrics.Sample
+}
+
+// budgetEvery is how many exceeded calls share one look at the clock and
+// the memory statistics.
+const budgetEvery = 64
+
+// newBudget returns nil when neither limit is set.
+func newBudget(maxSeconds float64, maxMem uint64) *budget {
+	if maxSeconds <= 0 && maxMem == 0 {
+		return nil
+	}
+	b := &budget{start: time.Now(), maxMem: maxMem}
+	if maxSeconds > 0 {
+		b.deadline = b.start.Add(time.Duration(maxSeconds * float64(time.Second)))
+	}
+	if maxMem > 0 {
+		b.samples = []metrics.Sample{
+			{Name: "/memory/classes/total:bytes"},
+			{Name: "/memory/classes/heap/released:bytes"},
+		}
+	}
+	return b
+}
+
+// exceeded reports whether a limit has run out. It is nil-safe, and stays
+// true once it has turned.
+func (b *budget) exceeded() bool {
+	if b == nil {
+		return false
+	}
+	if b.hit != "" {
+		return true
+	}
+	b.calls++
+	if b.calls%budgetEvery != 1 {
+		return false
+	}
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		b.hit = fmt.Sprintf("-max_seconds %g", b.deadline.Sub(b.start).Seconds())
+		return true
+	}
+	if b.maxMem > 0 {
+		metrics.Read(b.samples)
+		if used := b.samples[0].Value.Uint64() - b.samples[1].Value.Uint64(); used > b.maxMem {
+			b.hit = fmt.Sprintf("-max_mem %d bytes (at %d)", b.maxMem, used)
+			return true
+		}
+	}
+	return false
+}
+
+// truncated reports what stopped the walk, or "" if nothing did.
+func (b *budget) truncated() string {
+	if b == nil {
+		return ""
+	}
+	return b.hit
+}
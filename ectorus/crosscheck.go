@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"ectorus/internal/ecscan"
+)
+
+// ---------- -crosscheck: the walk against ecscan ----------
+
+// crossCheckList bounds how many points of each discrepancy are listed.
+const crossCheckList = 10
+
+// CrossCheckOut compares the affine points the walk found with the ones
+// ecscan's enumerator produces for the same curve, in-process. The two
+// share no point-finding code: chords and tangents on one side, a sqrt
+// table or Tonelli–Shanks per x on the other.
+type CrossCheckOut struct {
+	Walked   int  `json:"walkedAffine"`
+	Scanned  int  `json:"scannedAffine"`
+	Match    bool `json:"match"`
+	OnlyWalk []Pt `json:"onlyWalk,omitempty"` // first crossCheckList of each
+	OnlyScan []Pt `json:"onlyScan,omitempty"`
+}
+
+// crossCheck scans E with ecscan and diffs the result against e.found.
+func (e *Engine) crossCheck() (*CrossCheckOut, error) {
+	if e.Model != nil {
+		return nil, errors.New("-crosscheck needs -form weierstrass")
+	}
+	if !e.C.P.IsUint64() || e.C.P.Uint64() >= 1<<63 {
+		return nil, errors.New("-crosscheck needs p < 2^63 (ecscan's library path)")
+	}
+	walked := map[[2]uint64]bool{}
+	for _, P := range e.found {
+		if !P.Inf {
+			walked[[2]uint64{P.X.Uint64(), P.Y.Uint64()}] = true
+		}
+	}
+	c := &CrossCheckOut{Walked: len(walked)}
+	prm := ecscan.Params{P: e.C.P.Uint64(), A: e.C.A.Uint64(), B: e.C.B.Uint64()}
+	err := ecscan.Scan(context.Background(), prm, func(x, y uint64) error {
+		c.Scanned++
+		k := [2]uint64{x, y}
+		if walked[k] {
+			delete(walked, k)
+		} else if len(c.OnlyScan) < crossCheckList {
+			c.OnlyScan = append(c.OnlyScan, pt64(x, y))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for k := range walked {
+		if len(c.OnlyWalk) == crossCheckList {
+			break
+		}
+		c.OnlyWalk = append(c.OnlyWalk, pt64(k[0], k[1]))
+	}
+	c.Match = len(walked) == 0 && c.Walked == c.Scanned
+	return c, nil
+}
+
+func pt64(x, y uint64) Pt {
+	return Pt{X: strconv.FormatUint(x, 10), Y: strconv.FormatUint(y, 10)}
+}
+
+func printCrossCheck(w io.Writer, c *CrossCheckOut) {
+	fmt.Fprintf(w, "\nCross-check against ecscan: walk %d affine points, scan %d — ", c.Walked, c.Scanned)
+	if c.Match {
+		fmt.Fprintln(w, "match")
+		return
+	}
+	fmt.Fprintln(w, "MISMATCH")
+	for _, pt := range c.OnlyWalk {
+		fmt.Fprintf(w, "  only in walk: (%s, %s)\n", pt.X, pt.Y)
+	}
+	for _, pt := range c.OnlyScan {
+		fmt.Fprintf(w, "  only in scan: (%s, %s)\n", pt.X, pt.Y)
+	}
+}
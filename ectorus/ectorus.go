@@ -21,16 +21,91 @@
 //
 // Flags
 //
-//	-A, -B, -p      : curve parameters (decimal or 0x-hex), p prime > 3
-//	-grid           : enable explicit p×p bitsets for FOUND/EXCLUDED (memory ~ 2*p^2 bits)
+//	-A, -B, -p      : curve parameters (decimal, 0x-hex or an expression like 2^61-1), p prime > 3
+//	-grid           : enable explicit p×p bitsets for FOUND/EXCLUDED (p ≤ 100000)
+//	-grid_store S   : auto (default: dense 2*p^2 bits if within -grid_mem, else sparse), dense, or
+//	                  sparse (roaring-style compressed chunks; the walk stops if they outgrow -grid_mem)
+//	-grid_mem SIZE  : memory cap for the grid, e.g. 512MB (default 2GB)
+//	-grid_out FILE  : after the walk, write the found/excluded bitsets to FILE (internal/gridfile)
+//	-grid_in FILE   : start from a -grid_out file of the same curve (resume, or a baseline for griddiff)
 //	-max_lines N    : safety cap on number of lines to process (default 0 = no cap)
 //	-seed_x x       : optional x to try first when searching initial seed
-//	-json           : emit JSON instead of human text
+//	-rand_seed N    : draw seeds from math/rand seeded with N, so runs repeat exactly (0 = crypto/rand)
+//	-seed_strategy S: how each new seed is picked: random (default), sequential (scan x upward from the
+//	                  last seed), low-x (least x with an unfound point) or from-file (-seed_file, in order)
+//	-seed_file FILE : seed points for -seed_strategy from-file, one "x y" (or just "x") per line
+//	-secant_window K: draw at most K secants from each new point (default 0 = all earlier points)
+//	-lines K        : draw only tangents or only secants (tangent|secant; default both), with per-kind stats
+//	-verticals      : also process the vertical line through each point as soon as it is found
+//	-sweep_lines    : process every line of the plane (p^2 + p, or -max_lines) instead of walking
+//	-secant_policy P: which earlier points a window takes: recent (default), random or all (no window)
+//	-schedule S     : order of pending lines: fifo (default) or greedy (-grid only: most unknown cells first)
+//	-stop_at_coverage F: stop once a fraction F of the grid is classified (found or excluded; -grid only)
+//	-max_seconds S  : stop the walk S seconds into the curve's run (counting included) and report the
+//	                  partial result, marked truncated
+//	-max_mem SIZE   : likewise once the process's Go memory (heap, stacks, runtime) exceeds SIZE, e.g. 8GB
+//	-stats FILE     : write one CSV row per processed line (new points/exclusions, duplicate hits,
+//	                  coverage, lines/s); a summary block is always part of the output
+//	-lines_out FILE : write every processed line as NDJSON: slope and intercept (or vertical x), the
+//	                  curve points on it, and with -grid its new exclusions
+//	-events FILE    : stream the walk as NDJSON events (seed, point, line, reseed, completed), each with
+//	                  its time and the running line and point counts, for live dashboards (- for stdout,
+//	                  with -out elsewhere)
+//	-no_reseed      : walk from the initial seed only, and report what it reached: the cyclic
+//	                  subgroup <P>, its size and (with a count) its index in E(F_p)
+//	-crosscheck     : enumerate the same curve with ecscan in-process and report any point only one of
+//	                  them found, as asteroids crosscheck (implies -count_first; exit status 1 on a mismatch)
+//	-audit          : after the walk, try every x (p ≤ 100000) and check that no point of E is excluded,
+//	                  that every found point is on E and (-grid) that the grid's found cells are the found
+//	                  points (exit status 1 otherwise)
+//	-require_prime  : fail on a composite p (BPSW) instead of warning
+//	-prove_prime    : prove p prime (Pocklington, or Brillhart–Lehmer–Selfridge when only ∛p of p-1
+//	                  factors) and report the certificate; a composite p is an error, an unprovable one a
+//	                  warning (an error with -require_prime)
+//	-factor         : with a composite p, report its small factors (trial division, Pollard rho)
+//	-ring           : with a composite p, walk E over F_q for every prime q | p, Hensel-lift the points
+//	                  to each q^e and CRT-combine them into E(Z/pZ) (-form weierstrass only)
+//	-graph FILE     : write the discovery graph: a node per found point (seeds marked), an edge from
+//	                  each point a line was drawn through to each point it found (GraphML for *.graphml,
+//	                  DOT otherwise)
+//	-json           : emit JSON instead of human text (the same as -format json)
+//	-format F       : text (default), json, ndjson (each point as a line as soon as it is found, then a
+//	                  {"result": ...} line without the points) or csv (x,y of the found affine points)
+//	-inf_style S    : how JSON and NDJSON write O: flag (default, {"inf":true}, and "inf":false on each
+//	                  affine point), null, or string ("inf"), the last two in place of the point object
+//	-out FILE       : write the result there instead of stdout
 //	-count_first    : count #E(F_p) with Legendre scan to give a stopping target (O(p))
+//	                  (without it, a weierstrass walk reseeds until the Hasse interval, the 2-torsion and the
+//	                  found subgroup's order prove it complete, reported as hasse)
+//	-orders         : annotate every found point with its order (implies -count_first)
+//	-sec1           : annotate every found point with its compressed SEC 1 encoding in hex
+//	                  (02/03 ‖ x, 00 for O), as OpenSSL's EC_POINT_oct2point reads it
+//	-group          : after a complete walk, report E(F_p) ≅ Z/n1 × Z/n2 and generators (implies -count_first)
+//	-coords C       : affine (default) or jacobian coordinates for the scalar multiplications of -orders,
+//	                  -group and -isogenies; jacobian inverts once per multiple instead of once per step
+//	-twist          : also walk the quadratic twist E^d and check #E + #E^d = 2p+2 (implies -count_first)
+//	-query "x,y ..." : after the walk, classify each point as found, excluded or unknown (Engine.Classify)
+//	-isogenies L,.. : after a complete walk, list the rational ℓ-isogenies for each prime ℓ given: one
+//	                  per order-ℓ subgroup of the found points, codomain by Vélu's formulas (implies -count_first)
+//	-curve NAME     : take -p/-A/-B and -form from a named curve (secp256k1, p256, curve25519, ed25519,
+//	                  ...); NAME-wei walks a Montgomery or Edwards curve's short Weierstrass form instead
+//	-form F         : walk a weierstrass (default), montgomery (B y^2 = x^3 + A x^2 + x) or
+//	                  twisted edwards (A x^2 + y^2 = 1 + B x^2 y^2) curve; -A/-B are that model's coefficients
+//	-ainvs [a1,..]  : walk the long Weierstrass curve y^2 + a1 xy + a3 y = x^3 + a2 x^2 + a4 x + a6,
+//	                  e.g. a Cremona label's a-invariants reduced mod p (implies -form general)
+//	-k K            : walk the curve over F_{p^k}, k = 2 or 3 (-form weierstrass, implicit mode only);
+//	                  -A/-B are coefficient vectors a0,a1[,a2] in F_p[t]/(f), f the first irreducible
+//	                  t^k + c1 t + c0 found
+//	-manifest FILE  : run every curve listed in a JSON file, emitting one result per curve
+//	-parallel N     : with -manifest, run N curves concurrently (default 1)
+//	-cpuprofile F, -memprofile F, -trace F: write a CPU profile, an end-of-run heap profile or an
+//	                  execution trace of the run to F (go tool pprof / go tool trace)
 //
 // Notes
 //   - For large p, do NOT use -grid. The algorithm keeps an implicit list of processed
-//     lines and their true intersections and can still avoid reconsidering many points.
+//     lines (intercepts per slope, plus vertical x's) and their true intersections, so it
+//     can still avoid reconsidering many points, and Engine.Classify can tell whether any
+//     point is found, excluded or unknown.
 //   - When p is modest (<= 4096-ish), -grid provides a vivid demonstration of the
 //     exclusion idea — you can watch FOUND grow while EXCLUDED eats the plane.
 //   - Complexity: each processed line touches O(p) lattice points if -grid is set.
@@ -40,218 +115,36 @@
 package main
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"math/big"
+	mrand "math/rand"
 	"os"
 	"sort"
 	"strings"
-)
-
-func parseBig(s string) (*big.Int, error) {
-	s = strings.TrimSpace(s)
-	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
-		b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
-		if err != nil {
-			return nil, err
-		}
-		return new(big.Int).SetBytes(b), nil
-	}
-	z, ok := new(big.Int).SetString(s, 10)
-	if !ok {
-		return nil, fmt.Errorf("cannot parse integer: %q", s)
-	}
-	return z, nil
-}
-
-func mod(a, p *big.Int) *big.Int {
-	z := new(big.Int).Mod(a, p)
-	if z.Sign() < 0 {
-		z.Add(z, p)
-	}
-	return z
-}
-
-func addM(a, b, p *big.Int) *big.Int { return mod(new(big.Int).Add(a, b), p) }
-
-func subM(a, b, p *big.Int) *big.Int { return mod(new(big.Int).Sub(a, b), p) }
-
-func mulM(a, b, p *big.Int) *big.Int { return mod(new(big.Int).Mul(a, b), p) }
+	"sync"
 
-func negM(a, p *big.Int) *big.Int { return subM(new(big.Int), a, p) }
-
-func invM(a, p *big.Int) (*big.Int, error) {
-	if a.Sign() == 0 {
-		return nil, errors.New("inverse of zero")
-	}
-	inv := new(big.Int).ModInverse(a, p)
-	if inv == nil {
-		return nil, errors.New("no inverse")
-	}
-	return inv, nil
-}
-func powM(a, e, p *big.Int) *big.Int { return new(big.Int).Exp(a, e, p) }
-
-// Legendre symbol (a|p): -1,0,+1
-func legendre(a, p *big.Int) int {
-	A := mod(a, p)
-	if A.Sign() == 0 {
-		return 0
-	}
-	e := new(big.Int).Sub(p, big.NewInt(1))
-	e.Rsh(e, 1)
-	v := powM(A, e, p)
-	if v.Cmp(big.NewInt(1)) == 0 {
-		return 1
-	}
-	if v.Sign() == 0 {
-		return 0
-	}
-	return -1 // v==p-1
-}
-
-// Tonelli–Shanks sqrt mod p (p odd prime)
-func sqrtModP(a, p *big.Int) (*big.Int, error) {
-	A := mod(a, p)
-	if A.Sign() == 0 {
-		return new(big.Int), nil
-	}
-	if legendre(A, p) != 1 {
-		return nil, errors.New("non-residue")
-	}
-	// p ≡ 3 mod 4 shortcut
-	if new(big.Int).And(new(big.Int).Sub(p, big.NewInt(3)), big.NewInt(3)).Cmp(big.NewInt(0)) == 0 {
-		e := new(big.Int).Add(p, big.NewInt(1))
-		e.Rsh(e, 2)
-		return powM(A, e, p), nil
-	}
-	// factor p-1 = q*2^s
-	q := new(big.Int).Sub(p, big.NewInt(1))
-	s := 0
-	for q.Bit(0) == 0 {
-		q.Rsh(q, 1)
-		s++
-	}
-	// find z non-residue
-	z := new(big.Int).Set(big.NewInt(2))
-	for legendre(z, p) != -1 {
-		z.Add(z, big.NewInt(1))
-	}
-	c := powM(z, q, p)
-	x := powM(A, new(big.Int).Rsh(new(big.Int).Add(q, big.NewInt(1)), 1), p)
-	t := powM(A, q, p)
-	m := s
-	one := big.NewInt(1)
-	for t.Cmp(one) != 0 {
-		i := 1
-		b := new(big.Int).Exp(t, big.NewInt(2), p)
-		for i < m {
-			if b.Cmp(one) == 0 {
-				break
-			}
-			b.Exp(b, big.NewInt(2), p)
-			i++
-		}
-		if i == m {
-			return nil, errors.New("T–S failure")
-		}
-		// b = c^{2^{m-i-1}}
-		b.Set(c)
-		for j := 0; j < m-i-1; j++ {
-			b.Exp(b, big.NewInt(2), p)
-		}
-		x = mulM(x, b, p)
-		bb := mulM(b, b, p)
-		t = mulM(t, bb, p)
-		c = bb
-		m = i
-	}
-	return x, nil
-}
+	"ectorus/internal/count"
+	"ectorus/internal/ec"
+	"ectorus/internal/prime"
+	"ectorus/internal/prof"
+	"ectorus/pkg/encoding"
+)
 
 // ---------- curve & group law ----------
 
-type Curve struct{ P, A, B *big.Int }
-
-type Point struct {
-	X, Y *big.Int
-	Inf  bool
-}
-
-// isSingular reports whether Δ = -16(4A^3 + 27B^2) ≡ 0 mod p,
-// i.e., the curve is singular over F_p. We just test 4A^3 + 27B^2 ≡ 0.
-func (c Curve) isSingular() bool {
-	p := c.P
-	A2 := mulM(c.A, c.A, p)
-	A3 := mulM(A2, c.A, p)
-	term := addM(mulM(big.NewInt(4), A3, p), mulM(big.NewInt(27), mulM(c.B, c.B, p), p), p)
-	return term.Sign() == 0
-}
-
-func (c Curve) on(Pt Point) bool {
-	if Pt.Inf {
-		return true
-	}
-	x3 := mulM(Pt.X, mulM(Pt.X, Pt.X, c.P), c.P)
-	rhs := addM(addM(x3, mulM(c.A, Pt.X, c.P), c.P), c.B, c.P)
-	y2 := mulM(Pt.Y, Pt.Y, c.P)
-	return y2.Cmp(rhs) == 0
-}
-
-func (c Curve) neg(Pt Point) Point {
-	if Pt.Inf {
-		return Pt
-	}
-	return Point{X: new(big.Int).Set(Pt.X), Y: negM(Pt.Y, c.P)}
-}
-
-func (c Curve) add(P, Q Point) (Point, error) {
-	p := c.P
-	if P.Inf {
-		return Q, nil
-	}
-	if Q.Inf {
-		return P, nil
-	}
-	if P.X.Cmp(Q.X) == 0 {
-		// P==±Q
-		ysum := mod(new(big.Int).Add(P.Y, Q.Y), p)
-		if ysum.Sign() == 0 {
-			return Point{Inf: true}, nil
-		} // P == -Q -> O
-		// Doubling
-		if P.Y.Sign() == 0 {
-			return Point{Inf: true}, nil
-		} // vertical tangent
-		num := addM(mulM(big.NewInt(3), mulM(P.X, P.X, p), p), c.A, p)
-		den := mulM(big.NewInt(2), P.Y, p)
-		inv, err := invM(den, p)
-		if err != nil {
-			return Point{}, err
-		}
-		lam := mulM(num, inv, p)
-		xr := subM(subM(mulM(lam, lam, p), P.X, p), Q.X, p)
-		yr := subM(mulM(lam, subM(P.X, xr, p), p), P.Y, p)
-		return Point{X: xr, Y: yr}, nil
-	}
-	// Secant
-	num := subM(Q.Y, P.Y, p)
-	den := subM(Q.X, P.X, p)
-	inv, err := invM(den, p)
-	if err != nil {
-		return Point{}, err
-	}
-	lam := mulM(num, inv, p)
-	xr := subM(subM(mulM(lam, lam, p), P.X, p), Q.X, p)
-	yr := subM(mulM(lam, subM(P.X, xr, p), p), P.Y, p)
-	return Point{X: xr, Y: yr}, nil
-}
-
-func (c Curve) double(P Point) (Point, error) { return c.add(P, P) }
+// The field arithmetic and group law live in the shared internal/ec package.
+type (
+	Curve = ec.Curve
+	Point = ec.Point
+)
 
 // ---------- lines on the torus ----------
 
@@ -264,73 +157,46 @@ type Line struct {
 	V        *big.Int // x = V (if Vertical)
 }
 
-func (L Line) key() string {
+func (L Line) key() lineKey {
 	if L.Vertical {
-		return "v:" + L.V.String()
+		return lineKey{vertical: true, m: coordOf(L.V)}
 	}
-	return "m:" + L.M.String() + "|c:" + L.C.String()
+	return lineKey{m: coordOf(L.M), c: coordOf(L.C)}
 }
 
 // derive tangent line at P, or secant through P,Q
 func lineThrough(c Curve, P Point, Q *Point) (Line, error) {
 	p := c.P
-	if Q == nil || (Q != nil && P.X.Cmp(Q.X) == 0 && mod(new(big.Int).Add(P.Y, Q.Y), p).Sign() != 0) {
+	if Q == nil || (Q != nil && P.X.Cmp(Q.X) == 0 && ec.Mod(new(big.Int).Add(P.Y, Q.Y), p).Sign() != 0) {
 		// Tangent at P
 		if P.Y.Sign() == 0 { // vertical
 			return Line{Vertical: true, V: new(big.Int).Set(P.X)}, nil
 		}
-		num := addM(mulM(big.NewInt(3), mulM(P.X, P.X, p), p), c.A, p)
-		den := mulM(big.NewInt(2), P.Y, p)
-		inv, err := invM(den, p)
+		num := ec.AddM(ec.MulM(big.NewInt(3), ec.MulM(P.X, P.X, p), p), c.A, p)
+		den := ec.MulM(big.NewInt(2), P.Y, p)
+		inv, err := ec.InvM(den, p)
 		if err != nil {
 			return Line{}, err
 		}
-		m := mulM(num, inv, p)
-		cst := subM(P.Y, mulM(m, P.X, p), p)
+		m := ec.MulM(num, inv, p)
+		cst := ec.SubM(P.Y, ec.MulM(m, P.X, p), p)
 		return Line{M: m, C: cst}, nil
 	}
 	// Secant through distinct P,Q
-	if P.X.Cmp(Q.X) == 0 && mod(new(big.Int).Add(P.Y, Q.Y), p).Sign() == 0 { // vertical through P and -Q
+	if P.X.Cmp(Q.X) == 0 && ec.Mod(new(big.Int).Add(P.Y, Q.Y), p).Sign() == 0 { // vertical through P and -Q
 		return Line{Vertical: true, V: new(big.Int).Set(P.X)}, nil
 	}
-	num := subM(Q.Y, P.Y, p)
-	den := subM(Q.X, P.X, p)
-	inv, err := invM(den, p)
+	num := ec.SubM(Q.Y, P.Y, p)
+	den := ec.SubM(Q.X, P.X, p)
+	inv, err := ec.InvM(den, p)
 	if err != nil {
 		return Line{}, err
 	}
-	m := mulM(num, inv, p)
-	cst := subM(P.Y, mulM(m, P.X, p), p)
+	m := ec.MulM(num, inv, p)
+	cst := ec.SubM(P.Y, ec.MulM(m, P.X, p), p)
 	return Line{M: m, C: cst}, nil
 }
 
-// Compute the third intersection R for a tangent at P (Q=nil) or a secant through P,Q.
-// Returns intersections slice (distinct affine points on this line that lie on E).
-func thirdIntersection(c Curve, P Point, Q *Point) (Point, []Point, error) {
-	if Q == nil {
-		// Tangent
-		R, err := c.double(P)
-		if err != nil {
-			return Point{}, nil, err
-		}
-		if R.Inf { // vertical tangent, only P and -P on the vertical line in affine chart
-			return R, []Point{P, c.neg(P)}, nil
-		}
-		// Tangent meets at P (double) and R; both P and R are on the line; also -R by symmetry but not on same line generally.
-		return R, []Point{P, R}, nil
-	}
-	// Secant
-	if P.X.Cmp(Q.X) == 0 && mod(new(big.Int).Add(P.Y, Q.Y), c.P).Sign() == 0 {
-		// vertical secant; third point is O (at infinity). Affine intersections: P and Q only.
-		return Point{Inf: true}, []Point{P, *Q}, nil
-	}
-	R, err := c.add(P, *Q)
-	if err != nil {
-		return Point{}, nil, err
-	}
-	return R, []Point{P, *Q, R}, nil
-}
-
 // ---------- explicit p×p grid (optional) ----------
 
 type Bitset struct {
@@ -339,36 +205,67 @@ type Bitset struct {
 }
 
 func newBitset(n int) *Bitset    { return &Bitset{bits: make([]uint64, (n+63)/64), n: n} }
-func (b *Bitset) set(i int)      { b.bits[i>>6] |= 1 << (uint(i) & 63) }
 func (b *Bitset) get(i int) bool { return (b.bits[i>>6]>>(uint(i)&63))&1 == 1 }
 
+func (b *Bitset) set(i int) bool {
+	w, m := &b.bits[i>>6], uint64(1)<<(uint(i)&63)
+	fresh := *w&m == 0
+	*w |= m
+	return fresh
+}
+
 // Grid tracks FOUND and EXCLUDED points explicitly. Index = y*p + x.
 
 type Grid struct {
 	p           int
-	found, excl *Bitset
+	found, excl bitset
+	classified  int    // cells that are FOUND or EXCLUDED (or both)
+	store       string // -grid_store resolved to dense|sparse ("" = dense, no cap)
+	capBytes    uint64
 }
 
 func newGrid(p int) *Grid                { return &Grid{p: p, found: newBitset(p * p), excl: newBitset(p * p)} }
 func (g *Grid) idx(x, y int) int         { return y*g.p + x }
-func (g *Grid) markFound(x, y int)       { g.found.set(g.idx(x, y)) }
-func (g *Grid) markExcl(x, y int)        { g.excl.set(g.idx(x, y)) }
 func (g *Grid) isExcluded(x, y int) bool { return g.excl.get(g.idx(x, y)) }
 func (g *Grid) isFound(x, y int) bool    { return g.found.get(g.idx(x, y)) }
 
-// markLineExclusions excludes all points on L except those in keep map[key]=true
-func (g *Grid) markLineExclusions(L Line, keep map[string]bool) {
+func (g *Grid) markFound(x, y int) {
+	if i := g.idx(x, y); g.found.set(i) && !g.excl.get(i) {
+		g.classified++
+	}
+}
+
+// markExcl excludes (x, y), reporting whether the cell was unclassified.
+func (g *Grid) markExcl(x, y int) bool {
+	i := g.idx(x, y)
+	fresh := g.excl.set(i) && !g.found.get(i)
+	if fresh {
+		g.classified++
+	}
+	return fresh
+}
+
+// markLineExclusions excludes all points on L except those in keep. It
+// returns how many cells it newly classified and how many were already
+// classified (duplicate hits).
+func (g *Grid) markLineExclusions(L Line, keep map[cell]bool) (fresh, dup int) {
 	p := g.p
+	mark := func(x, y int) {
+		if keep[cell{x, y}] {
+			return
+		}
+		if g.markExcl(x, y) {
+			fresh++
+		} else {
+			dup++
+		}
+	}
 	if L.Vertical {
 		x := int(new(big.Int).Set(L.V).Int64()) % p
 		for y := 0; y < p; y++ {
-			k := fmt.Sprintf("%d|%d", x, y)
-			if keep[k] {
-				continue
-			}
-			g.markExcl(x, y)
+			mark(x, y)
 		}
-		return
+		return fresh, dup
 	}
 	m := int(new(big.Int).Set(L.M).Int64()) % p
 	c := int(new(big.Int).Set(L.C).Int64()) % p
@@ -377,14 +274,17 @@ func (g *Grid) markLineExclusions(L Line, keep map[string]bool) {
 		if y < 0 {
 			y += p
 		}
-		k := fmt.Sprintf("%d|%d", x, y)
-		if keep[k] {
-			continue
-		}
-		g.markExcl(x, y)
+		mark(x, y)
 	}
+	return fresh, dup
 }
 
+// maxGridP bounds -grid; beyond it even one pass over the lines is too slow.
+const maxGridP = 100_000
+
+// defaultGridMem is the -grid_mem default.
+const defaultGridMem = "2GB"
+
 // ---------- engine ----------
 
 type Engine struct {
@@ -394,44 +294,99 @@ type Engine struct {
 	MaxLines   int
 	CountFirst bool
 	KnownCount *big.Int
-
-	found       map[string]Point
-	order       []Point         // NEW: discovery order
-	indexOf     map[string]int  // NEW: for fast lookup if needed
-	deadX       map[string]bool // x where both roots (or the single y=0 root) are already known
-	linesDone   map[string]bool
-	secantDone  map[string]bool // unordered pair key "x1|y1#x2|y2"
-	tangentDone map[string]bool // by point key
+	// Model, when set, is the curve model actually walked (-form); C is then
+	// its short Weierstrass equivalent, used only for counting.
+	Model model
+	// F is the field the model is walked over; nil means F_p (-k 1).
+	F field
+	// AffineTarget overrides KnownCount-1 as the number of affine points a
+	// complete walk must find (a twisted Edwards curve can lose points to
+	// infinity).
+	AffineTarget *big.Int
+	// Rand supplies random seed x-coordinates; nil means crypto/rand.
+	Rand io.Reader
+	// Seeds picks each new seed (-seed_strategy); nil means random x.
+	Seeds seeder
+	// SecantWindow, when > 0, bounds the secants drawn from each new point
+	// to that many earlier points, chosen per SecantPolicy.
+	SecantWindow int
+	SecantPolicy string // "recent" | "random" | "all"
+	// Schedule is "fifo" (walkAndExclude) or "greedy" (walkGreedy).
+	Schedule string
+	// LineKinds limits the walk to tangents or to secants (-lines); ""
+	// draws both.
+	LineKinds string
+	// AutoVerticals processes the vertical line through each point as soon
+	// as it is found (-verticals).
+	AutoVerticals bool
+	// Jacobian does the scalar multiplications of -orders, -group and
+	// -isogenies in Jacobian coordinates (-coords jacobian).
+	Jacobian bool
+	// StopAtCoverage, when > 0, ends the walk once that fraction of the
+	// grid is classified.
+	StopAtCoverage float64
+	// NoReseed walks from the first seed only (-no_reseed).
+	NoReseed bool
+	// Budget, when set, stops the walk once -max_seconds or -max_mem runs
+	// out.
+	Budget *budget
+	// OnFound, when set, is told of each point as it is found.
+	OnFound func(Point)
+
+	greedy     *greedyState
+	milestones []Milestone
+	stats      lineStats
+	implicit   implicitLines   // processed lines, for Classify without a grid
+	linesOut   *lineWriter     // -lines_out
+	events     *eventWriter    // -events
+	graph      *discoveryGraph // -graph
+	capped     bool            // the last walk stopped at its line cap
+	verticals  []Point         // found points whose column -verticals has yet to process
+	factors    []*FactorOut    // factors of p met as non-invertible denominators
+	hasse      *HasseOut       // bounds on #E when it was not counted
+
+	found       map[ptKey]Point
+	order       []Point        // NEW: discovery order
+	indexOf     map[ptKey]int  // NEW: for fast lookup if needed
+	deadX       map[coord]bool // x where both roots (or the single y=0 root) are already known
+	linesDone   map[lineKey]bool
+	secantDone  map[pairKey]bool // unordered pair, smaller point first
+	tangentDone map[ptKey]bool
 }
 
-func (e *Engine) pointKey(P Point) string {
+func (e *Engine) pointKey(P Point) ptKey {
 	if P.Inf {
-		return "inf"
+		return ptKey{inf: true}
 	}
-	return P.X.String() + "|" + P.Y.String()
+	return ptKey{x: coordOf(P.X), y: coordOf(P.Y)}
 }
-func (e *Engine) pairKey(P, Q Point) string {
+func (e *Engine) pairKey(P, Q Point) pairKey {
 	k1 := e.pointKey(P)
 	k2 := e.pointKey(Q)
-	if k1 < k2 {
-		return k1 + "#" + k2
+	if k1.less(k2) {
+		return pairKey{k1, k2}
 	}
-	return k2 + "#" + k1
+	return pairKey{k2, k1}
 }
 
 func (e *Engine) addFound(P Point) bool {
 	e.ensureMaps()
-	if !e.C.on(P) {
-		return false
-	}
+	// the key lookup is cheap; the curve check is not, so it goes second
 	k := e.pointKey(P)
 	if _, ok := e.found[k]; ok {
 		return false
 	}
+	if !e.onCurve(P) {
+		return false
+	}
 	e.found[k] = P
+	if e.OnFound != nil {
+		e.OnFound(P)
+	}
+	e.queueVertical(P)
 	if !P.Inf {
 		if e.indexOf == nil {
-			e.indexOf = make(map[string]int)
+			e.indexOf = make(map[ptKey]int)
 		}
 		e.indexOf[k] = len(e.order)
 		e.order = append(e.order, P)
@@ -447,34 +402,51 @@ func (e *Engine) addFound(P Point) bool {
 			e.G.markFound(x, y)
 		}
 	}
+	e.events.point(e, P)
 	return true
 }
 
 // process tangent at P or secant through P,Q; exclude other points on that line
 func (e *Engine) processLineFrom(P Point, Q *Point) error {
 	e.ensureMaps()
-	L, err := lineThrough(e.C, P, Q)
+	L, err := e.line(P, Q)
 	if err != nil {
 		return err
 	}
+	return e.processLine(L, P, Q)
+}
+
+// processLine records the curve points on L, the line through P (and Q),
+// and on the explicit grid excludes the rest of L.
+func (e *Engine) processLine(L Line, P Point, Q *Point) error {
 	lk := L.key()
 	if e.linesDone[lk] {
+		e.stats.dupLines++
 		return nil
 	}
-	R, inters, err := thirdIntersection(e.C, P, Q)
+	inters, extra, err := e.intersections(L, P, Q)
 	if err != nil {
 		return err
 	}
+	return e.applyLine(lineRow{L: L, Tangent: Q == nil || Q.Equal(P)}, P, Q, inters, extra)
+}
+
+// applyLine records the points a line yields (inters on it, extra from the
+// group law) and excludes the rest of the line, then logs it.
+func (e *Engine) applyLine(row lineRow, P Point, Q *Point, inters, extra []Point) error {
+	L, lk := row.L, row.L.key()
 	// Record found intersections
-	for _, S := range inters {
-		e.addFound(S)
-	}
-	if !R.Inf {
-		e.addFound(e.C.neg(R))
-	} // For walking we’ll also eventually see -R via other lines; optional.
+	for _, S := range append(inters, extra...) {
+		if e.addFound(S) {
+			row.NewPoints++
+			if e.graph != nil {
+				e.graph.note(e, S, row, P, Q)
+			}
+		}
+	} // For walking we’ll also eventually see these via other lines; optional.
 	// Exclude rest of the line on explicit grid
 	if e.UseGrid {
-		keep := map[string]bool{}
+		keep := map[cell]bool{}
 		for _, S := range inters {
 			if S.Inf {
 				continue
@@ -487,11 +459,28 @@ func (e *Engine) processLineFrom(P Point, Q *Point) error {
 			if y < 0 {
 				y += e.G.p
 			}
-			keep[fmt.Sprintf("%d|%d", x, y)] = true
+			keep[cell{x, y}] = true
 		}
-		e.G.markLineExclusions(L, keep)
+		row.NewExcl, row.DupHits = e.G.markLineExclusions(L, keep)
 	}
 	e.linesDone[lk] = true
+	if !e.UseGrid {
+		e.implicit.add(L)
+	}
+	if e.UseGrid {
+		e.noteCoverage()
+		if err := e.G.overCap(); err != nil {
+			return err
+		}
+	}
+	if err := e.stats.record(e, row); err != nil {
+		return err
+	}
+	e.events.line(e, row)
+	if e.linesOut != nil {
+		row.Points = lineMeets(L, e.C.P, inters, extra)
+		return e.linesOut.write(e, e.stats.lines, row)
+	}
 	return nil
 }
 
@@ -502,24 +491,28 @@ func (e *Engine) walkAndExclude(maxLines int) error {
 	processed := 0
 	// start index at current length if this is a resume; else 0
 	for i := 0; i < len(e.order); i++ {
-		if maxLines > 0 && processed >= maxLines {
+		n, err := e.drainVerticals()
+		if err != nil {
+			return err
+		}
+		processed += n
+		if maxLines > 0 && processed >= maxLines || e.Budget.exceeded() {
 			break
 		}
 
 		P := e.order[i]
 		pk := e.pointKey(P)
 
-		// Tangent at P once
-		if !e.tangentDone[pk] {
-			if err := e.processLineFrom(P, nil); err != nil {
-				return err
-			}
+		// Tangent at P once, then secants with earlier points (all, or a
+		// window of them); the lines are gathered first so their slopes
+		// share one inversion.
+		var jobs []lineJob
+		if e.drawsTangents() && !e.tangentDone[pk] {
+			jobs = append(jobs, lineJob{P: P})
 			e.tangentDone[pk] = true
 			processed++
 		}
-
-		// Secants P with all earlier points
-		for j := 0; j < i; j++ {
+		for _, j := range e.secantPartners(i) {
 			Q := e.order[j]
 			if Q.Inf {
 				continue
@@ -528,111 +521,231 @@ func (e *Engine) walkAndExclude(maxLines int) error {
 			if e.secantDone[pair] {
 				continue
 			}
-			if err := e.processLineFrom(P, &Q); err != nil {
-				return err
-			}
+			jobs = append(jobs, lineJob{P: P, Q: &Q})
 			e.secantDone[pair] = true
 			processed++
 			if maxLines > 0 && processed >= maxLines {
 				break
 			}
 		}
+		if err := e.processLines(jobs); err != nil {
+			return err
+		}
+		if e.coverageReached() {
+			break
+		}
 
 		// Early stop if we know point count
-		if e.KnownCount != nil {
+		if target := e.affineTarget(); target != nil {
 			finite := len(e.order)
-			if new(big.Int).SetInt64(int64(finite)).
-				Cmp(new(big.Int).Sub(e.KnownCount, big.NewInt(1))) == 0 {
+			if new(big.Int).SetInt64(int64(finite)).Cmp(target) == 0 {
 				break
 			}
 		}
 	}
+	e.capped = maxLines > 0 && processed >= maxLines
 	return nil
 }
 
+// drawsTangents and drawsSecants report which lines -lines lets the walk
+// draw.
+func (e *Engine) drawsTangents() bool { return e.LineKinds != linesSecant }
+func (e *Engine) drawsSecants() bool  { return e.LineKinds != linesTangent }
+
+// secantPartners lists, in increasing order, the earlier points j < i that
+// point i draws secants to (none under -lines tangent).
+func (e *Engine) secantPartners(i int) []int {
+	if !e.drawsSecants() {
+		return nil
+	}
+	K := e.SecantWindow
+	if K <= 0 || K >= i || e.SecantPolicy == "all" {
+		js := make([]int, i)
+		for j := range js {
+			js[j] = j
+		}
+		return js
+	}
+	js := make([]int, 0, K)
+	if e.SecantPolicy == "random" {
+		// Floyd's algorithm: K distinct indices from [0, i)
+		picked := make(map[int]bool, K)
+		for n := i - K; n < i; n++ {
+			j := e.randIntn(n + 1)
+			if picked[j] {
+				j = n
+			}
+			picked[j] = true
+			js = append(js, j)
+		}
+		sort.Ints(js)
+		return js
+	}
+	for j := i - K; j < i; j++ {
+		js = append(js, j)
+	}
+	return js
+}
+
 // findNextSeed: pick the next lattice point that is not excluded and (if on curve) not yet found.
 // For implicit mode, we just random-search x until we get a new E point not in found.
 func (e *Engine) findNextSeed() (Point, bool) {
-	p := e.C.P
+	if e.Seeds != nil {
+		return e.Seeds.next(e)
+	}
 	tries := 0
 	for tries < 200000 {
-		x, _ := rand.Int(rand.Reader, p)
-		kx := x.String()
+		x := e.randX()
+		kx := coordOf(x)
 		if e.deadX[kx] {
 			tries++
 			continue
 		}
 
-		t := addM(addM(mulM(x, mulM(x, x, p), p), mulM(e.C.A, x, p), p), e.C.B, p)
-		lg := legendre(t, p)
-		if lg == -1 {
-			tries++
-			continue
-		}
-		if lg == 0 {
-			P := Point{X: x, Y: new(big.Int)}
+		pts := e.pointsAtX(x)
+		for _, P := range pts {
 			if _, ok := e.found[e.pointKey(P)]; !ok {
 				return P, true
 			}
-			e.deadX[kx] = true
-			tries++
-			continue
 		}
-		y, err := sqrtModP(t, p)
-		if err != nil {
-			tries++
-			continue
-		}
-		P1 := Point{X: x, Y: y}
-		P2 := Point{X: x, Y: negM(y, p)}
-		_, f1 := e.found[e.pointKey(P1)]
-		_, f2 := e.found[e.pointKey(P2)]
-		if !f1 {
-			return P1, true
-		}
-		if !f2 {
-			return P2, true
+		if len(pts) > 0 {
+			e.deadX[kx] = true
 		}
-		e.deadX[kx] = true
 		tries++
 	}
 	return Point{}, false
 }
 
-// ---------- counting ----------
+// randX draws a uniform x of the field from e.Rand.
+func (e *Engine) randX() *big.Int {
+	r := e.Rand
+	if r == nil {
+		r = rand.Reader
+	}
+	x, _ := rand.Int(r, e.field().order())
+	return x
+}
 
-func countLegendre(c Curve) *big.Int {
-	cnt := new(big.Int).Set(big.NewInt(1)) // include 0
-	for x := new(big.Int).SetInt64(0); x.Cmp(c.P) < 0; x.Add(x, big.NewInt(1)) {
-		t := addM(addM(mulM(x, mulM(x, x, c.P), c.P), mulM(c.A, x, c.P), c.P), c.B, c.P)
-		lg := legendre(t, c.P)
-		switch lg {
-		case 0:
-			cnt.Add(cnt, big.NewInt(1))
-		case 1:
-			cnt.Add(cnt, big.NewInt(2))
-		}
+// randIntn draws a uniform int in [0, n) from e.Rand.
+func (e *Engine) randIntn(n int) int {
+	r := e.Rand
+	if r == nil {
+		r = rand.Reader
 	}
-	return cnt
+	v, _ := rand.Int(r, big.NewInt(int64(n)))
+	return int(v.Int64())
 }
 
+// ---------- counting ----------
+
+func countLegendre(c Curve) *big.Int { return count.Legendre(c) }
+
 // ---------- output structs ----------
 
 type Out struct {
-	P          string   `json:"p"`
-	A          string   `json:"A"`
-	B          string   `json:"B"`
-	KnownCount string   `json:"pointCount,omitempty"`
-	Complete   bool     `json:"complete"`
-	Found      []Pt     `json:"found"`
-	Lines      int      `json:"linesProcessed"`
-	Notes      []string `json:"notes,omitempty"`
+	P            string             `json:"p"`
+	A            string             `json:"A"`
+	B            string             `json:"B"`
+	Form         string             `json:"form,omitempty"`
+	WA           string             `json:"weierstrassA,omitempty"`
+	WB           string             `json:"weierstrassB,omitempty"`
+	Ainvs        []string           `json:"ainvs,omitempty"`
+	KnownCount   string             `json:"pointCount,omitempty"`
+	Complete     bool               `json:"complete"`
+	Truncated    bool               `json:"truncated,omitempty"` // -max_seconds or -max_mem stopped the walk
+	Found        []Pt               `json:"found"`
+	Lines        int                `json:"linesProcessed"`
+	Group        *GroupOut          `json:"group,omitempty"`
+	Twist        *TwistOut          `json:"twist,omitempty"`
+	Isogenies    []IsogenyOut       `json:"isogenies,omitempty"`
+	Queries      []QueryOut         `json:"queries,omitempty"`
+	Coverage     *CoverageOut       `json:"coverage,omitempty"`
+	Stats        *StatsOut          `json:"stats,omitempty"`
+	Implicit     *ImplicitOut       `json:"implicit,omitempty"`
+	Reach        *ReachOut          `json:"reach,omitempty"`
+	PointsIn     *PointsInOut       `json:"pointsIn,omitempty"`
+	GridIn       *GridInOut         `json:"gridIn,omitempty"`
+	Sweep        *SweepOut          `json:"sweep,omitempty"`
+	Hasse        *HasseOut          `json:"hasse,omitempty"`
+	CrossCheck   *CrossCheckOut     `json:"crossCheck,omitempty"`
+	Audit        *AuditOut          `json:"audit,omitempty"`
+	PrimeProof   *prime.Certificate `json:"primeProof,omitempty"`
+	PFactors     []string           `json:"pFactors,omitempty"` // -factor on a composite p; the last may be unsplit
+	Ring         *RingOut           `json:"ring,omitempty"`
+	Extension    *ExtensionOut      `json:"extension,omitempty"` // -k > 1
+	FactorsFound []*FactorOut       `json:"factorsFound,omitempty"`
+	RandSeed     int64              `json:"randSeed,omitempty"`
+	SeedStrategy string             `json:"seedStrategy,omitempty"`
+	Notes        []string           `json:"notes,omitempty"`
+	Error        string             `json:"error,omitempty"` // -manifest: this curve failed
 }
 
 type Pt struct {
-	X   string `json:"x,omitempty"`
-	Y   string `json:"y,omitempty"`
-	Inf bool   `json:"inf"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+	Inf   bool   `json:"inf"`
+	Order string `json:"order,omitempty"`
+	SEC1  string `json:"sec1,omitempty"` // -sec1: compressed SEC 1 encoding, hex
+}
+
+// -inf_style: how the JSON outputs write O. flag is {"inf":true}, with
+// "inf":false on every affine point; null and string write O as null or
+// "inf" in place of the object, and affine points without the flag.
+const (
+	infFlag   = "flag"
+	infNull   = "null"
+	infString = "string"
+)
+
+// infStyle is set once from -inf_style, before any output.
+var infStyle = infFlag
+
+func parseInfStyle(s string) (string, error) {
+	switch s {
+	case infFlag, infNull, infString:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown -inf_style %q (want null|flag|string)", s)
+}
+
+// ptJSON is Pt without the inf flag on an affine point.
+type ptJSON struct {
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+	Inf   bool   `json:"inf,omitempty"`
+	Order string `json:"order,omitempty"`
+	SEC1  string `json:"sec1,omitempty"`
+}
+
+// MarshalJSON writes pt in infStyle. O's order and SEC 1 annotations
+// ("1", "00") go with the object in the null and string styles.
+func (pt Pt) MarshalJSON() ([]byte, error) {
+	switch {
+	case infStyle == infFlag:
+		type plain Pt
+		return json.Marshal(plain(pt))
+	case pt.Inf && infStyle == infNull:
+		return []byte("null"), nil
+	case pt.Inf:
+		return []byte(`"inf"`), nil
+	}
+	return json.Marshal(ptJSON(pt))
+}
+
+// UnmarshalJSON reads a point in any -inf_style: null and "inf" are O.
+func (pt *Pt) UnmarshalJSON(b []byte) error {
+	if isInfJSON(b) {
+		*pt = Pt{Inf: true}
+		return nil
+	}
+	type plain Pt
+	return json.Unmarshal(b, (*plain)(pt))
+}
+
+// isInfJSON recognises the null and string forms of O.
+func isInfJSON(b []byte) bool {
+	s := string(bytes.TrimSpace(b))
+	return s == "null" || s == `"inf"`
 }
 
 func toPt(P Point) Pt {
@@ -642,6 +755,14 @@ func toPt(P Point) Pt {
 	return Pt{X: P.X.String(), Y: P.Y.String()}
 }
 
+// sec1Hex is P's compressed SEC 1 encoding in hex ("00" for O).
+func sec1Hex(c Curve, P Point) string {
+	if P.Inf {
+		return hex.EncodeToString(encoding.Infinity())
+	}
+	return hex.EncodeToString(encoding.MarshalCompressed(c.P, P.X, P.Y))
+}
+
 // NewEngine constructs an Engine with all internal maps initialised.
 func NewEngine(curve Curve, useGrid bool, maxLines int, countFirst bool) *Engine {
 	e := &Engine{
@@ -649,12 +770,12 @@ func NewEngine(curve Curve, useGrid bool, maxLines int, countFirst bool) *Engine
 		UseGrid:     useGrid,
 		MaxLines:    maxLines,
 		CountFirst:  countFirst,
-		found:       make(map[string]Point),
-		linesDone:   make(map[string]bool),
-		secantDone:  make(map[string]bool),
-		tangentDone: make(map[string]bool),
-		indexOf:     make(map[string]int),
-		deadX:       make(map[string]bool),
+		found:       make(map[ptKey]Point),
+		linesDone:   make(map[lineKey]bool),
+		secantDone:  make(map[pairKey]bool),
+		tangentDone: make(map[ptKey]bool),
+		indexOf:     make(map[ptKey]int),
+		deadX:       make(map[coord]bool),
 	}
 	if useGrid {
 		pp := int(curve.P.Int64())
@@ -666,167 +787,866 @@ func NewEngine(curve Curve, useGrid bool, maxLines int, countFirst bool) *Engine
 // ensureMaps is a defensive guard (safe if called repeatedly).
 func (e *Engine) ensureMaps() {
 	if e.found == nil {
-		e.found = make(map[string]Point)
+		e.found = make(map[ptKey]Point)
 	}
 	if e.linesDone == nil {
-		e.linesDone = make(map[string]bool)
+		e.linesDone = make(map[lineKey]bool)
 	}
 	if e.secantDone == nil {
-		e.secantDone = make(map[string]bool)
+		e.secantDone = make(map[pairKey]bool)
 	}
 	if e.tangentDone == nil {
-		e.tangentDone = make(map[string]bool)
+		e.tangentDone = make(map[ptKey]bool)
 	}
 	if e.indexOf == nil {
-		e.indexOf = make(map[string]int)
+		e.indexOf = make(map[ptKey]int)
 	}
 	if e.deadX == nil {
-		e.deadX = make(map[string]bool)
+		e.deadX = make(map[coord]bool)
 	}
 }
 
 // ---------- main ----------
 
+// runOpts are the per-run switches shared by every curve of a run.
+type runOpts struct {
+	UseGrid    bool
+	MaxLines   int
+	CountFirst bool
+	Orders     bool
+	SEC1       bool // -sec1
+	Group      bool
+	Twist      bool
+	Isogenies  []int         // -isogenies: primes ℓ
+	Queries    [][2]*big.Int // -query: points to classify after the walk
+	RandSeed   int64         // 0 = crypto/rand
+	// SeedStrategy is -seed_strategy; SeedPoints the parsed -seed_file.
+	SeedStrategy string
+	SeedPoints   []seedPoint
+	SecantWindow int
+	SecantPolicy string
+	Schedule     string
+	LineKinds    string  // -lines tangent|secant|both
+	Coords       string  // -coords affine|jacobian
+	Verticals    bool    // -verticals
+	SweepLines   bool    // -sweep_lines: process every line instead of walking
+	StopAt       float64 // -stop_at_coverage
+	MaxSeconds   float64 // -max_seconds: wall-time budget per curve, 0 = none
+	MaxMem       string  // -max_mem: Go memory budget, e.g. "8GB", "" = none
+	// Stream, when set, gets each point of the curve as the walk finds it
+	// (-format ndjson).
+	Stream       func(Pt)
+	StatsPath    string // -stats
+	LinesOut     string // -lines_out
+	Events       string // -events
+	GraphPath    string // -graph
+	NoReseed     bool   // -no_reseed
+	Interactive  bool   // -interactive: a prompt on stdin drives the walk
+	PointsIn     string // -points_in: known points to start from
+	GridIn       string // -grid_in: a saved grid to start from
+	GridOut      string // -grid_out: save the grid here after the walk
+	CrossCheck   bool   // -crosscheck
+	Audit        bool   // -audit: brute-force check of the exclusions
+	RequirePrime bool   // -require_prime: composite p is an error
+	ProvePrime   bool   // -prove_prime: attach a primality certificate for p
+	FactorP      bool   // -factor: report small factors of a composite p
+	Ring         bool   // -ring: composite p walks each prime factor and CRT-combines
+	GridStore    string // -grid_store auto|dense|sparse
+	GridMem      string // -grid_mem cap, e.g. "2GB"
+}
+
+// curveSpec is one curve as given on the command line or in a manifest.
+type curveSpec struct {
+	P     string `json:"p"`
+	A     string `json:"A"`
+	B     string `json:"B"`
+	Form  string `json:"form,omitempty"`
+	Ainvs string `json:"ainvs,omitempty"`
+	SeedX string `json:"seed_x,omitempty"`
+	Curve string `json:"curve,omitempty"` // a named curve, in place of p, A, B and form
+	K     int    `json:"k,omitempty"`     // walk over F_{p^k}; A, B are then coefficient vectors
+}
+
 func main() {
-	var AStr, BStr, PStr, seedXStr string
-	var useGrid, jsonOut bool
-	var maxLines int
-	var countFirst bool
-
-	flag.StringVar(&AStr, "A", "0", "curve A (dec or 0x-hex)")
-	flag.StringVar(&BStr, "B", "0", "curve B (dec or 0x-hex)")
-	flag.StringVar(&PStr, "p", "0", "prime p>3 (dec or 0x-hex)")
-	flag.BoolVar(&useGrid, "grid", false, "use explicit p×p bitsets for found/excluded (memory ~ 2*p^2 bits)")
-	flag.IntVar(&maxLines, "max_lines", 0, "cap number of lines processed (0 = no cap)")
-	flag.BoolVar(&jsonOut, "json", false, "emit JSON")
-	flag.BoolVar(&countFirst, "count_first", false, "count #E(F_p) first (Legendre scan) to know stopping target")
-	flag.StringVar(&seedXStr, "seed_x", "", "optional x to try first when finding initial seed")
+	var spec curveSpec
+	var o runOpts
+	var jsonOut bool
+	var outPath, format, infOut string
+	var manifest string
+	var parallel int
+	var seedFile string
+	var isogenies string
+	var queries string
+	var profiles prof.Files
+
+	flag.StringVar(&spec.A, "A", "0", "curve A (dec, 0x-hex or expression like 2^61-1)")
+	flag.StringVar(&spec.B, "B", "0", "curve B (dec, 0x-hex or expression)")
+	flag.StringVar(&spec.P, "p", "0", "prime p>3 (dec, 0x-hex or expression like 2^61-1)")
+	flag.IntVar(&spec.K, "k", 1, "walk over F_{p^k} for k = 2 or 3, with -A/-B as coefficient vectors a0,a1[,a2] over F_p[t]/(f) (implicit exclusions only)")
+	flag.BoolVar(&o.UseGrid, "grid", false, "use explicit p×p bitsets for found/excluded (memory ~ 2*p^2 bits dense, less compressed)")
+	flag.StringVar(&o.GridStore, "grid_store", "auto", "grid bitsets: auto (dense if it fits -grid_mem)|dense|sparse (compressed)")
+	flag.StringVar(&o.GridMem, "grid_mem", defaultGridMem, "memory cap for the -grid bitsets")
+	flag.StringVar(&o.GridOut, "grid_out", "", "with -grid: write the found and excluded bitsets to this file after the walk (compact binary, for -grid_in and griddiff)")
+	flag.StringVar(&o.GridIn, "grid_in", "", "with -grid: start from the bitsets of a -grid_out file for the same curve, its found points as known points")
+	flag.IntVar(&o.MaxLines, "max_lines", 0, "cap number of lines processed (0 = no cap)")
+	flag.BoolVar(&jsonOut, "json", false, "emit JSON (the same as -format json)")
+	flag.StringVar(&outPath, "out", "-", "write the result to this file instead of stdout (-)")
+	flag.StringVar(&format, "format", "", "result format: text (default)|json|ndjson (each point as it is found, then the result)|csv (the found points)")
+	flag.StringVar(&infOut, "inf_style", infFlag, "how JSON and NDJSON write the point at infinity: flag ({\"inf\":true})|null|string (\"inf\")")
+	flag.BoolVar(&o.CountFirst, "count_first", false, "count #E(F_p) first (Legendre scan) to know stopping target")
+	flag.BoolVar(&o.Orders, "orders", false, "annotate each found point with its order (implies -count_first)")
+	flag.BoolVar(&o.SEC1, "sec1", false, "annotate each found point with its compressed SEC 1 encoding (hex, as OpenSSL reads it)")
+	flag.BoolVar(&o.Group, "group", false, "report group structure Z/n1 x Z/n2 and generators after a complete walk (implies -count_first)")
+	flag.StringVar(&o.Coords, "coords", "affine", "coordinates for the scalar multiplications of -orders, -group and -isogenies: affine|jacobian")
+	flag.BoolVar(&o.Twist, "twist", false, "also walk the quadratic twist E^d and cross-check #E + #E^d = 2p+2 (implies -count_first)")
+	flag.StringVar(&queries, "query", "", "after the walk, classify these points as found|excluded|unknown: \"x,y\", several separated by spaces or ;")
+	flag.StringVar(&isogenies, "isogenies", "", "after a complete walk, list the rational ℓ-isogenies for these primes ℓ, e.g. 2,3,5 (implies -count_first)")
+	flag.StringVar(&spec.SeedX, "seed_x", "", "optional x to try first when finding initial seed")
+	flag.Int64Var(&o.RandSeed, "rand_seed", 0, "seed math/rand with N for reproducible seed selection (0 = crypto/rand)")
+	flag.StringVar(&o.SeedStrategy, "seed_strategy", "random", "how new seeds are chosen: random|sequential|low-x|from-file")
+	flag.IntVar(&o.SecantWindow, "secant_window", 0, "draw at most K secants from each new point (0 = all earlier points)")
+	flag.StringVar(&o.SecantPolicy, "secant_policy", "recent", "which earlier points a -secant_window takes: recent|random|all")
+	flag.StringVar(&o.Schedule, "schedule", "fifo", "order of pending lines: fifo|greedy (greedy needs -grid)")
+	flag.StringVar(&o.LineKinds, "lines", "both", "which lines the walk draws: tangent|secant|both, to see what each kind contributes")
+	flag.BoolVar(&o.SweepLines, "sweep_lines", false, "instead of walking, process all p^2 + p lines (verticals, then y = mx + c) with their intersections, as a baseline; -max_lines bounds it")
+	flag.BoolVar(&o.Verticals, "verticals", false, "process the vertical line x = x0 through each point as soon as it is found (reported as auto-vertical lines)")
+	flag.Float64Var(&o.StopAt, "stop_at_coverage", 0, "with -grid: stop once this fraction of cells (e.g. 0.95) is found or excluded (0 = off)")
+	flag.Float64Var(&o.MaxSeconds, "max_seconds", 0, "stop the walk after this many seconds per curve and report a truncated result (0 = no limit)")
+	flag.StringVar(&o.MaxMem, "max_mem", "", "stop the walk once the process's Go memory exceeds this, e.g. 8GB, and report a truncated result")
+	flag.StringVar(&o.StatsPath, "stats", "", "write per-line exclusion statistics as CSV to this file")
+	flag.BoolVar(&o.NoReseed, "no_reseed", false, "walk from the initial seed only and report the subgroup it generates")
+	flag.BoolVar(&o.Interactive, "interactive", false, "drive the walk from a prompt on stdin (seed, step N, run, grid, stats, json), then write the result as usual")
+	flag.BoolVar(&o.CrossCheck, "crosscheck", false, "enumerate the curve with ecscan in-process and compare its points with the walk's, as asteroids crosscheck does (implies -count_first; exit 1 on a mismatch)")
+	flag.BoolVar(&o.Audit, "audit", false, "after the walk, brute-force check (p ≤ 100000) that no curve point is excluded and every found point is on the curve (exit 1 on a failure)")
+	flag.BoolVar(&o.RequirePrime, "require_prime", false, "fail when p is composite (BPSW) instead of warning")
+	flag.BoolVar(&o.ProvePrime, "prove_prime", false, "prove p prime with a Pocklington / Brillhart–Lehmer–Selfridge certificate (reported as primeProof)")
+	flag.BoolVar(&o.FactorP, "factor", false, "when p is composite, report its small factors (trial division and Pollard rho)")
+	flag.BoolVar(&o.Ring, "ring", false, "with a composite p: walk E over F_q for each prime q | p and combine the points mod p by Hensel lifting and CRT")
+	flag.StringVar(&o.GraphPath, "graph", "", "write the discovery graph (points, tangent/secant edges) to this file: GraphML if it ends in .graphml, else DOT")
+	flag.StringVar(&o.LinesOut, "lines_out", "", "write every processed line (equation, intersections, new exclusions) as NDJSON to this file")
+	flag.StringVar(&o.Events, "events", "", "stream the walk's events (seed, point, line, reseed, completed) as NDJSON to this file (- for stdout)")
+	flag.StringVar(&o.PointsIn, "points_in", "", "start from the points in this file: an ecscan dump (text, csv, ndjson or compressed) or an ectorus json/ndjson result")
+	flag.StringVar(&seedFile, "seed_file", "", "seed points for -seed_strategy from-file, one \"x y\" or \"x\" per line")
+	flag.StringVar(&spec.Curve, "curve", "", "named curve for -p/-A/-B and -form: "+strings.Join(ec.CurveNames(), "|")+" (NAME-wei: its short Weierstrass form)")
+	flag.StringVar(&spec.Form, "form", "weierstrass", "curve model: weierstrass|montgomery (B y^2 = x^3 + A x^2 + x)|edwards (A x^2 + y^2 = 1 + B x^2 y^2)|general (-ainvs)")
+	flag.StringVar(&spec.Ainvs, "ainvs", "", "a-invariants [a1,a2,a3,a4,a6] of y^2 + a1 xy + a3 y = x^3 + a2 x^2 + a4 x + a6 (implies -form general)")
+	flag.StringVar(&manifest, "manifest", "", "JSON file listing curves [{\"p\":..,\"A\":..,\"B\":..}, ...] to run in one process")
+	flag.IntVar(&parallel, "parallel", 1, "with -manifest: number of curves to run concurrently")
+	flag.StringVar(&profiles.CPU, "cpuprofile", "", "write a CPU profile of the run to this file (go tool pprof)")
+	flag.StringVar(&profiles.Mem, "memprofile", "", "write a heap profile to this file at the end of the run (go tool pprof)")
+	flag.StringVar(&profiles.Trace, "trace", "", "write an execution trace of the run to this file (go tool trace)")
 	flag.Parse()
 
-	fmt.Fprintln(os.Stderr, "Parsing input parameters...")
-	A, err := parseBig(AStr)
+	stop, err := prof.Start(profiles)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error: parsing value for A")
 		die(err)
 	}
-	B, err := parseBig(BStr)
+	stopProfiles = sync.OnceFunc(func() {
+		if err := stop(); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+	})
+	defer stopProfiles()
+
+	if spec.Curve != "" {
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "p", "A", "B", "form", "ainvs":
+				dieStr("-curve sets -p, -A, -B and -form; drop -" + f.Name)
+			}
+		})
+	}
+
+	if isogenies != "" {
+		ls, err := parseIsogenyDegrees(isogenies)
+		if err != nil {
+			die(err)
+		}
+		o.Isogenies = ls
+	}
+
+	if queries != "" {
+		qs, err := parseQueries(queries)
+		if err != nil {
+			die(err)
+		}
+		o.Queries = qs
+	}
+
+	if seedFile != "" {
+		pts, err := loadSeedFile(seedFile)
+		if err != nil {
+			die(err)
+		}
+		o.SeedPoints = pts
+	}
+
+	if infStyle, err = parseInfStyle(infOut); err != nil {
+		die(err)
+	}
+	switch {
+	case jsonOut && format != "" && format != "json":
+		dieStr("-json is -format json; drop one of them")
+	case jsonOut:
+		format = "json"
+	case format == "":
+		format = "text"
+	}
+	if o.Interactive && (manifest != "" || o.Events == "-" || format == "ndjson" && (outPath == "" || outPath == "-")) {
+		dieStr("-interactive talks on stdout and walks one curve; it does not combine with -manifest, -events - or -format ndjson to stdout")
+	}
+	if o.Events == "-" && (outPath == "" || outPath == "-") {
+		dieStr("-events - and the result would both go to stdout; give -out a file")
+	}
+	res, err := openOutput(outPath, format)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error: parsing value for B")
 		die(err)
 	}
-	P, err := parseBig(PStr)
+
+	if manifest != "" {
+		if o.StatsPath != "" || o.LinesOut != "" || o.GraphPath != "" || o.Events != "" {
+			dieStr("-stats, -lines_out, -graph and -events write one curve's walk; they do not combine with -manifest")
+		}
+		if o.PointsIn != "" {
+			dieStr("-points_in holds one curve's points; it does not combine with -manifest")
+		}
+		if o.GridIn != "" || o.GridOut != "" {
+			dieStr("-grid_in and -grid_out hold one curve's grid; they do not combine with -manifest")
+		}
+		specs, err := loadManifest(manifest, spec.Form)
+		if err != nil {
+			die(err)
+		}
+		outs := runManifest(specs, o, parallel)
+		defer exitOnMismatch(outs...)
+		if err := res.results(outs); err != nil {
+			die(err)
+		}
+		if err := res.close(); err != nil {
+			die(err)
+		}
+		return
+	}
+
+	if res.streams() {
+		o.Stream = res.point
+	}
+	out, err := runCurve(spec, o)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error: parsing value for p")
 		die(err)
 	}
+	defer exitOnMismatch(out)
+	if err := res.result(out, o.Stream != nil && out.Ring == nil); err != nil {
+		die(err)
+	}
+	if err := res.close(); err != nil {
+		die(err)
+	}
+}
+
+// factorStrings lists the factors prime.Factor finds in n, with any part it
+// could not split last, in brackets.
+func factorStrings(n *big.Int) []string {
+	fs, rest := prime.Factor(n)
+	out := make([]string, 0, len(fs)+1)
+	for _, f := range fs {
+		out = append(out, f.String())
+	}
+	if rest.Cmp(big.NewInt(1)) != 0 {
+		out = append(out, "["+rest.String()+"]")
+	}
+	return out
+}
+
+// exitOnMismatch exits with status 1, after the output is written, when a
+// -crosscheck found a discrepancy or a -audit failed.
+func exitOnMismatch(outs ...Out) {
+	for _, out := range outs {
+		if out.CrossCheck != nil && !out.CrossCheck.Match || out.Audit != nil && !out.Audit.Pass {
+			stopProfiles()
+			os.Exit(1)
+		}
+	}
+}
+
+// runCurve parses, checks, walks and (per o) analyses one curve.
+func runCurve(spec curveSpec, o runOpts) (Out, error) {
+	fmt.Fprintln(os.Stderr, "Parsing input parameters...")
+	// the budget runs from here, so it covers counting as well as walking
+	if o.MaxSeconds < 0 {
+		return Out{}, fmt.Errorf("-max_seconds %v is negative", o.MaxSeconds)
+	}
+	var maxMem uint64
+	if o.MaxMem != "" {
+		var err error
+		if maxMem, err = parseBytes(o.MaxMem); err != nil {
+			return Out{}, fmt.Errorf("-max_mem: %w", err)
+		}
+	}
+	bud := newBudget(o.MaxSeconds, maxMem)
+	if spec.Curve != "" {
+		if spec.K > 1 {
+			return Out{}, errors.New("-k needs -p, -A and -B, not -curve")
+		}
+		nc, err := ec.LookupCurve(spec.Curve)
+		if err != nil {
+			return Out{}, err
+		}
+		spec.P, spec.A, spec.B, spec.Form = nc.P, nc.A, nc.B, nc.Form
+	}
+	P, err := ec.ParseBig(spec.P)
+	if err != nil {
+		return Out{}, fmt.Errorf("parsing value for p: %w", err)
+	}
+	var A, B *big.Int
+	if spec.K <= 1 { // over F_{p^k}, extCurve reads them as vectors
+		if A, err = ec.ParseBig(spec.A); err != nil {
+			return Out{}, fmt.Errorf("parsing value for A: %w", err)
+		}
+		if B, err = ec.ParseBig(spec.B); err != nil {
+			return Out{}, fmt.Errorf("parsing value for B: %w", err)
+		}
+	}
 
 	fmt.Fprintln(os.Stderr, "Checking input parameters...")
 	if P.Cmp(big.NewInt(3)) <= 0 {
-		dieStr("p must be > 3")
+		return Out{}, errors.New("p must be > 3")
+	}
+	var ext *extField
+	if spec.K > 1 {
+		if ext, A, B, err = extCurve(spec, P, o); err != nil {
+			return Out{}, err
+		}
+	} else if spec.K < 0 {
+		return Out{}, fmt.Errorf("-k %d: the extension degree must be positive", spec.K)
+	}
+	var pFactors []string
+	var proof *prime.Certificate
+	isPrime := prime.BPSW(P)
+	if !isPrime {
+		if o.Ring {
+			if o.PointsIn != "" || o.GridIn != "" || o.GridOut != "" || len(o.Queries) > 0 || o.Audit {
+				return Out{}, errors.New("-points_in, -grid_in, -grid_out, -query and -audit do not combine with -ring")
+			}
+			return runRing(spec, P, A, B, o)
+		}
+		msg := fmt.Sprintf("p = %s is composite", P)
+		if o.FactorP {
+			pFactors = factorStrings(P)
+			msg += ": " + strings.Join(pFactors, " × ")
+		}
+		if o.RequirePrime || o.ProvePrime {
+			return Out{}, errors.New(msg)
+		}
+		fmt.Fprintln(os.Stderr, "warning:", msg+" (lines whose slope has a non-invertible denominator are skipped and the factor it reveals reported; -ring walks the prime factors instead)")
+	} else if o.ProvePrime {
+		fmt.Fprintln(os.Stderr, "Proving p prime...")
+		if proof, err = prime.Prove(P); err != nil {
+			if o.RequirePrime {
+				return Out{}, err
+			}
+			fmt.Fprintln(os.Stderr, "warning: p passes BPSW but", err)
+		}
 	}
-	if !P.ProbablyPrime(32) {
-		fmt.Fprintln(os.Stderr, "warning: p may not be prime")
+	if P.BitLen() > maxKeyBits {
+		return Out{}, fmt.Errorf("p must fit in %d bits", maxKeyBits)
 	}
 
 	fmt.Fprintln(os.Stderr, "Creating curve...")
-	curve := Curve{P: P, A: mod(A, P), B: mod(B, P)}
+	ainvs, err := parseAinvs(spec.Ainvs)
+	if err != nil {
+		return Out{}, err
+	}
+	form := spec.Form
+	if ainvs != nil {
+		form = "general"
+	}
+	var mdl model
+	var curve Curve
+	if ext != nil {
+		em := extModel{F: ext, A: A, B: B}
+		if em.singular() {
+			return Out{}, fmt.Errorf("singular curve: 4A^3+27B^2 = 0 in F_{p^%d}", ext.k)
+		}
+		mdl, curve = em, Curve{P: P, A: A, B: B} // C holds packed A, B; nothing counts with it
+	} else if mdl, curve, err = parseForm(form, P, A, B, ainvs); err != nil {
+		return Out{}, err
+	}
 	// Early safety checks
-	if curve.isSingular() {
-		dieStr("singular curve: discriminant (4A^3+27B^2) ≡ 0 mod p")
+	if ext == nil && curve.IsSingular() {
+		return Out{}, errors.New("singular curve: discriminant (4A^3+27B^2) ≡ 0 mod p")
 	}
-	if useGrid {
+	if (o.GridIn != "" || o.GridOut != "") && !o.UseGrid {
+		return Out{}, errors.New("-grid_in and -grid_out need -grid")
+	}
+	if len(o.Queries) > 0 && ext != nil {
+		return Out{}, errors.New("-query takes points over F_p; it does not combine with -k")
+	}
+	if o.Audit {
+		if ext != nil {
+			return Out{}, errors.New("-audit tries every x of F_p; it does not combine with -k")
+		}
+		if P.Cmp(big.NewInt(auditLimit)) > 0 {
+			return Out{}, fmt.Errorf("-audit tries every x; it needs p ≤ %d, got p=%s", auditLimit, P)
+		}
+	}
+	if o.SweepLines {
+		if ext != nil {
+			return Out{}, errors.New("-sweep_lines needs -k 1")
+		}
+		if err := checkSweep(o); err != nil {
+			return Out{}, err
+		}
+	}
+	var grid *Grid
+	if o.UseGrid {
 		fmt.Fprintln(os.Stderr, "Creating grid memory...")
-		limit := big.NewInt(10_000)
+		limit := big.NewInt(maxGridP)
 		if P.Cmp(limit) > 0 {
-			fmt.Fprintf(os.Stderr, "warning: -grid mode supports p ≤ %s; got p=%s. Exiting.", limit.String(), P.String())
-			os.Exit(2)
+			return Out{}, fmt.Errorf("-grid mode supports p ≤ %s; got p=%s", limit.String(), P.String())
+		}
+		mem, store := o.GridMem, o.GridStore
+		if mem == "" {
+			mem = defaultGridMem
 		}
+		if store == "" {
+			store = storeAuto
+		}
+		capBytes, err := parseBytes(mem)
+		if err != nil {
+			return Out{}, fmt.Errorf("-grid_mem: %w", err)
+		}
+		if grid, err = newGridStore(int(P.Int64()), store, capBytes); err != nil {
+			return Out{}, err
+		}
+		fmt.Fprintf(os.Stderr, "Grid storage: %s\n", grid.store)
+	}
+
+	if o.SEC1 && mdl != nil {
+		return Out{}, errors.New("-sec1 needs -form weierstrass")
+	}
+	if o.Twist || o.Group || o.Orders || len(o.Isogenies) > 0 {
+		if mdl != nil {
+			return Out{}, errors.New("-twist, -group, -orders and -isogenies need -form weierstrass")
+		}
+		o.CountFirst = true
+	}
+	if o.CrossCheck {
+		o.CountFirst = true
 	}
 
 	fmt.Fprintln(os.Stderr, "Creating engine...")
-	eng := NewEngine(curve, useGrid, maxLines, countFirst)
+	// the grid was sized above, so NewEngine must not allocate a dense one
+	eng := NewEngine(curve, false, o.MaxLines, o.CountFirst)
+	eng.UseGrid, eng.G = o.UseGrid, grid
+	eng.Model = mdl
+	if ext != nil {
+		eng.F = ext
+	}
+	eng.NoReseed = o.NoReseed
+	eng.Budget = bud
+	if o.Stream != nil {
+		eng.OnFound = func(P Point) {
+			pt := toPt(P)
+			if ext != nil && !P.Inf {
+				pt.X, pt.Y = ext.format(P.X), ext.format(P.Y)
+			}
+			o.Stream(pt)
+		}
+	}
+	if o.RandSeed != 0 {
+		eng.Rand = mrand.New(mrand.NewSource(o.RandSeed))
+	}
+	if eng.Seeds, err = newSeeder(o.SeedStrategy, o.SeedPoints); err != nil {
+		return Out{}, err
+	}
+	switch o.SecantPolicy {
+	case "", "recent", "random", "all":
+	default:
+		return Out{}, fmt.Errorf("unknown -secant_policy %q (want recent|random|all)", o.SecantPolicy)
+	}
+	eng.SecantWindow, eng.SecantPolicy = o.SecantWindow, o.SecantPolicy
+	switch o.Schedule {
+	case "", "fifo":
+	case "greedy":
+		if !o.UseGrid {
+			return Out{}, errors.New("-schedule greedy needs -grid")
+		}
+	default:
+		return Out{}, fmt.Errorf("unknown -schedule %q (want fifo|greedy)", o.Schedule)
+	}
+	eng.Schedule = o.Schedule
+	switch o.LineKinds {
+	case "", linesBoth:
+	case linesTangent, linesSecant:
+		eng.LineKinds = o.LineKinds
+		eng.stats.kinds = o.LineKinds
+	default:
+		return Out{}, fmt.Errorf("unknown -lines %q (want tangent|secant|both)", o.LineKinds)
+	}
+	switch o.Coords {
+	case "", "affine":
+	case "jacobian":
+		eng.Jacobian = true
+	default:
+		return Out{}, fmt.Errorf("unknown -coords %q (want affine|jacobian)", o.Coords)
+	}
+	eng.AutoVerticals = o.Verticals
+	if o.StopAt != 0 {
+		if !o.UseGrid {
+			return Out{}, errors.New("-stop_at_coverage needs -grid")
+		}
+		if o.StopAt < 0 || o.StopAt > 1 {
+			return Out{}, fmt.Errorf("-stop_at_coverage %v is not a fraction in (0, 1]", o.StopAt)
+		}
+		eng.StopAtCoverage = o.StopAt
+	}
 
 	// Count first if requested (O(p))
 	if eng.CountFirst {
 		fmt.Fprintln(os.Stderr, "Counting points (Legendre)...")
-		eng.KnownCount = countLegendre(curve)
+		switch {
+		case ext != nil:
+			if eng.KnownCount, err = ext.pointCount(A, B, eng); err != nil {
+				return Out{}, err
+			}
+		default:
+			eng.KnownCount = countLegendre(curve)
+			if mdl != nil {
+				eng.AffineTarget = eng.countAffine()
+			}
+		}
+	} else if isPrime && mdl == nil && ext == nil && !o.SweepLines && !o.Interactive {
+		eng.initHasse()
 	}
 
 	// seed
 	var seedX *big.Int
-	if seedXStr != "" {
+	if spec.SeedX != "" {
 		fmt.Fprintln(os.Stderr, "Parsing seed...")
-		sx, err := parseBig(seedXStr)
+		sx, err := ec.ParseBig(spec.SeedX)
 		if err != nil {
-			die(err)
+			return Out{}, err
 		}
 		seedX = sx
 	}
-	seed, ok := eng.findNextSeedFromX(seedX)
-	if !ok {
-		dieStr("failed to find a seed point on E")
+	var sw *csv.Writer
+	if o.StatsPath != "" {
+		f, err := os.Create(o.StatsPath)
+		if err != nil {
+			return Out{}, err
+		}
+		defer f.Close()
+		sw = csv.NewWriter(f)
 	}
-	fmt.Fprintln(os.Stderr, "Found seed point on E...")
-	eng.addFound(seed)
-
-	// walk + exclude
-	if err := eng.walkAndExclude(eng.MaxLines); err != nil {
-		die(err)
+	if err := eng.stats.begin(sw); err != nil {
+		return Out{}, err
 	}
-
-	// If not complete and we know count, keep sampling seeds until done
-	linesProcessed := len(eng.linesDone)
-	for eng.KnownCount != nil && !eng.isComplete() {
-		next, ok := eng.findNextSeed()
-		if !ok {
-			break
+	if o.LinesOut != "" {
+		f, err := os.Create(o.LinesOut)
+		if err != nil {
+			return Out{}, err
 		}
-		eng.addFound(next)
-		if err := eng.walkAndExclude(eng.MaxLines); err != nil {
-			die(err)
+		defer f.Close()
+		eng.linesOut = newLineWriter(f)
+	}
+	switch o.Events {
+	case "":
+	case "-":
+		eng.events = newEventWriter(os.Stdout, o.SeedStrategy)
+	default:
+		f, err := os.Create(o.Events)
+		if err != nil {
+			return Out{}, err
+		}
+		defer f.Close()
+		eng.events = newEventWriter(f, o.SeedStrategy)
+	}
+	if o.GraphPath != "" {
+		eng.graph = &discoveryGraph{}
+	}
+	var gridIn *GridInOut
+	if o.GridIn != "" {
+		fmt.Fprintln(os.Stderr, "Loading grid...")
+		if gridIn, err = eng.loadGrid(o.GridIn); err != nil {
+			return Out{}, err
+		}
+		fmt.Fprintf(os.Stderr, "Loaded %d found and %d excluded cells\n", gridIn.Found, gridIn.Excluded)
+	}
+	var pointsIn *PointsInOut
+	if o.PointsIn != "" {
+		if ext != nil {
+			return Out{}, errors.New("-points_in reads points over F_p; it does not combine with -k")
+		}
+		fmt.Fprintln(os.Stderr, "Loading known points...")
+		kps, err := loadPointsIn(o.PointsIn, P, eng.C.A, eng.C.B)
+		if err != nil {
+			return Out{}, fmt.Errorf("-points_in: %w", err)
+		}
+		if pointsIn, err = eng.preload(o.PointsIn, kps); err != nil {
+			return Out{}, err
+		}
+		fmt.Fprintf(os.Stderr, "Preloaded %d points (%d duplicates)\n", pointsIn.Points, pointsIn.Duplicates)
+	}
+	var linesProcessed int
+	var sweep *SweepOut
+	if o.SweepLines {
+		if sweep, err = eng.sweep(o.MaxLines); err == nil {
+			linesProcessed = sweep.Lines
+		}
+	} else if o.Interactive {
+		linesProcessed, err = eng.repl(os.Stdin, os.Stdout, seedX, A, B)
+	} else {
+		linesProcessed, err = eng.run(seedX)
+	}
+	if err != nil {
+		return Out{}, err
+	}
+	if eng.linesOut != nil {
+		if err := eng.linesOut.flush(); err != nil {
+			return Out{}, err
+		}
+	}
+	if eng.graph != nil {
+		if err := eng.writeGraph(o.GraphPath); err != nil {
+			return Out{}, err
+		}
+	}
+	if o.GridOut != "" {
+		if err := eng.writeGrid(o.GridOut); err != nil {
+			return Out{}, fmt.Errorf("-grid_out: %w", err)
+		}
+	}
+	if sw != nil {
+		if sw.Flush(); sw.Error() != nil {
+			return Out{}, sw.Error()
 		}
-		linesProcessed = len(eng.linesDone)
 	}
 
 	// Collate output
 	out := Out{
-		P:        P.String(),
-		A:        eng.C.A.String(),
-		B:        eng.C.B.String(),
-		Complete: eng.isComplete(),
-		Lines:    linesProcessed,
+		P:            P.String(),
+		A:            eng.C.A.String(),
+		B:            eng.C.B.String(),
+		Complete:     eng.isComplete(),
+		Lines:        linesProcessed,
+		RandSeed:     o.RandSeed,
+		PrimeProof:   proof,
+		PFactors:     pFactors,
+		FactorsFound: eng.factors,
+		Coverage:     eng.coverage(),
+		Stats:        eng.stats.summary(),
+		PointsIn:     pointsIn,
+		GridIn:       gridIn,
+		Sweep:        sweep,
+		Hasse:        eng.hasse,
+	}
+	if !o.UseGrid {
+		out.Implicit = eng.implicitSummary()
+	}
+	if o.SeedStrategy != "random" {
+		out.SeedStrategy = o.SeedStrategy
+	}
+	if o.NoReseed {
+		if out.Reach, err = eng.reachability(); err != nil {
+			return Out{}, err
+		}
+	} else if !o.Interactive && sweep == nil && eng.KnownCount != nil && !out.Complete && !eng.coverageReached() && bud.truncated() == "" && (o.MaxLines == 0 || linesProcessed < o.MaxLines) {
+		out.Notes = append(out.Notes, "ran out of seed points before the walk completed")
+	} else if eng.hasse != nil && !out.Complete && (eng.LineKinds != "" || eng.SecantWindow > 0) {
+		// the found points of a one-kind or windowed walk need not be a
+		// subgroup, so the Hasse bound cannot drive a reseed
+		out.Notes = append(out.Notes, fmt.Sprintf("incomplete: a -lines or -secant_window walk reseeds only with -count_first; it stopped after %d lines", linesProcessed))
+	}
+	if hit := bud.truncated(); hit != "" {
+		out.Truncated = true
+		out.Notes = append(out.Notes, fmt.Sprintf("truncated: the walk stopped at %s after %d lines; the points and coverage are partial", hit, linesProcessed))
+	}
+	if eng.events != nil {
+		eng.events.completed(eng, out.Complete, out.Truncated)
+		if err := eng.events.flush(); err != nil {
+			return Out{}, err
+		}
 	}
+	out.describeCurve(eng, A, B)
 	if eng.KnownCount != nil {
 		out.KnownCount = eng.KnownCount.String()
 	}
 	for _, P := range eng.sortedFound() {
-		out.Found = append(out.Found, toPt(P))
+		pt := toPt(P)
+		if ext != nil && !P.Inf {
+			pt.X, pt.Y = ext.format(P.X), ext.format(P.Y)
+		}
+		if o.SEC1 {
+			pt.SEC1 = sec1Hex(eng.C, P)
+		}
+		out.Found = append(out.Found, pt)
 	}
-	for _, P := range eng.sortedFound() {
-		out.Found = append(out.Found, toPt(P))
+	if o.Orders {
+		fmt.Fprintln(os.Stderr, "Computing point orders...")
+		if err := eng.annotateOrders(out.Found); err != nil {
+			return Out{}, err
+		}
 	}
 
-	if jsonOut {
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
-		enc.Encode(out)
-		return
+	if o.Group {
+		if out.Complete {
+			fmt.Fprintln(os.Stderr, "Determining group structure...")
+			g, err := eng.groupStructure()
+			if err != nil {
+				return Out{}, err
+			}
+			out.Group = g
+		} else {
+			out.Notes = append(out.Notes, "group structure skipped: enumeration incomplete")
+		}
+	}
+
+	if len(o.Queries) > 0 {
+		out.Queries = eng.query(o.Queries)
+	}
+
+	if len(o.Isogenies) > 0 {
+		if out.Complete {
+			if out.Isogenies, err = eng.isogenies(o.Isogenies); err != nil {
+				return Out{}, err
+			}
+			if len(out.Isogenies) == 0 {
+				out.Notes = append(out.Notes, fmt.Sprintf("no rational isogenies of degree %s: no found point has one of those orders", strings.Trim(fmt.Sprint(o.Isogenies), "[]")))
+			}
+		} else {
+			out.Notes = append(out.Notes, "isogenies skipped: enumeration incomplete")
+		}
+	}
+
+	if o.Audit {
+		fmt.Fprintln(os.Stderr, "Auditing the exclusions...")
+		if out.Audit, err = eng.audit(); err != nil {
+			return Out{}, err
+		}
+		if !out.Audit.Pass {
+			out.Notes = append(out.Notes, "audit failed: see audit.problems")
+		}
 	}
-	printHuman(out)
+
+	if o.CrossCheck {
+		fmt.Fprintln(os.Stderr, "Cross-checking against ecscan...")
+		if out.CrossCheck, err = eng.crossCheck(); err != nil {
+			return Out{}, err
+		}
+		if !out.CrossCheck.Match {
+			out.Notes = append(out.Notes, "cross-check failed: the walk and ecscan found different points")
+		}
+	}
+
+	if o.Twist {
+		t, err := runTwist(eng)
+		if err != nil {
+			return Out{}, err
+		}
+		out.Twist = t
+		if !t.SumCheck {
+			out.Notes = append(out.Notes, "twist cross-check failed: #E + #E^d != 2p+2 over the points found")
+		}
+	}
+	return out, nil
 }
 
-func (e *Engine) isComplete() bool {
-	e.ensureMaps()
-	if e.KnownCount == nil {
-		return false
+// describeCurve sets how out gives the curve when it is not a short
+// Weierstrass curve over F_p: A and B as vectors over F_{p^k}, or the
+// model's own coefficients (A and B as given, or the a-invariants) next to
+// its Weierstrass form.
+func (out *Out) describeCurve(e *Engine, A, B *big.Int) {
+	if ext, ok := e.F.(*extField); ok {
+		out.A, out.B = ext.format(A), ext.format(B)
+		out.Extension = &ExtensionOut{K: ext.k, Modulus: ext.modulus()}
+	} else if e.Model != nil {
+		out.Form = e.Model.name()
+		out.A, out.B = ec.Mod(A, e.C.P).String(), ec.Mod(B, e.C.P).String()
+		out.WA, out.WB = e.C.A.String(), e.C.B.String()
+	}
+	if g, ok := e.Model.(genModel); ok {
+		out.A, out.B = "", ""
+		for _, a := range []*big.Int{g.A1, g.A2, g.A3, g.A4, g.A6} {
+			out.Ainvs = append(out.Ainvs, a.String())
+		}
+	}
+}
+
+// run seeds the engine (trying seedX first, if given), walks, and — when a
+// target count is known — keeps reseeding until complete. Points preloaded
+// by -points_in take the place of the first seed. It returns the number of
+// distinct lines processed.
+func (e *Engine) run(seedX *big.Int) (int, error) {
+	if len(e.order) == 0 {
+		seed, ok := e.findNextSeedFromX(seedX)
+		if !ok {
+			return 0, errors.New("failed to find a seed point on E")
+		}
+		fmt.Fprintln(os.Stderr, "Found seed point on E...")
+		e.events.seed(e, seed, true)
+		e.addFound(seed)
+	}
+
+	// walk + exclude
+	if err := e.walk(e.MaxLines); err != nil {
+		return 0, err
+	}
+
+	// If not complete and we know count, keep sampling seeds until done
+	linesProcessed := len(e.linesDone)
+	for !e.NoReseed && e.KnownCount != nil && !e.isComplete() && !e.coverageReached() && !e.Budget.exceeded() {
+		next, ok := e.findNextSeed()
+		if !ok {
+			break
+		}
+		e.events.seed(e, next, false)
+		e.addFound(next)
+		if err := e.walk(e.MaxLines); err != nil {
+			return linesProcessed, err
+		}
+		linesProcessed = len(e.linesDone)
 	}
+	if e.hasse != nil {
+		if err := e.hasseReseed(); err != nil {
+			return linesProcessed, err
+		}
+		linesProcessed = len(e.linesDone)
+	}
+	return linesProcessed, nil
+}
+
+// finiteFound counts the affine points found so far (O excluded).
+func (e *Engine) finiteFound() int {
 	finite := 0
 	for _, P := range e.found {
 		if !P.Inf {
 			finite++
 		}
 	}
-	return new(big.Int).SetInt64(int64(finite)).Cmp(new(big.Int).Sub(e.KnownCount, big.NewInt(1))) == 0
+	return finite
+}
+
+func (e *Engine) isComplete() bool {
+	e.ensureMaps()
+	target := e.affineTarget()
+	if target == nil {
+		return e.hasse != nil && e.hasse.Complete
+	}
+	finite := e.finiteFound()
+	return new(big.Int).SetInt64(int64(finite)).Cmp(target) == 0
+}
+
+// affineTarget is the number of affine points a complete walk finds, or nil
+// when no count is known.
+func (e *Engine) affineTarget() *big.Int {
+	if e.AffineTarget != nil {
+		return e.AffineTarget
+	}
+	if e.KnownCount == nil {
+		return nil
+	}
+	return new(big.Int).Sub(e.KnownCount, big.NewInt(1))
 }
 
 func (e *Engine) sortedFound() []Point {
@@ -852,28 +1672,23 @@ func (e *Engine) sortedFound() []Point {
 
 func (e *Engine) findNextSeedFromX(seedX *big.Int) (Point, bool) {
 	fmt.Fprintln(os.Stderr, "Finding next seed from X...")
-	p := e.C.P
+	p := e.field().order()
 	tryX := func(x *big.Int) (Point, bool) {
-		t := addM(addM(mulM(x, mulM(x, x, p), p), mulM(e.C.A, x, p), p), e.C.B, p)
-		lg := legendre(t, p)
-		if lg == 0 {
-			return Point{X: new(big.Int).Set(x), Y: new(big.Int)}, true
-		}
-		if lg == 1 {
-			y, err := sqrtModP(t, p)
-			if err == nil {
-				return Point{X: new(big.Int).Set(x), Y: y}, true
-			}
+		if pts := e.pointsAtX(x); len(pts) > 0 {
+			return pts[0], true
 		}
 		return Point{}, false
 	}
 	if seedX != nil {
-		if P, ok := tryX(mod(seedX, p)); ok {
+		if P, ok := tryX(ec.Mod(seedX, p)); ok {
 			return P, true
 		}
 	}
+	if e.Seeds != nil {
+		return e.Seeds.next(e)
+	}
 	for tries := 0; tries < 10000; tries++ {
-		x, _ := rand.Int(rand.Reader, p)
+		x := e.randX()
 		if P, ok := tryX(x); ok {
 			return P, true
 		}
@@ -881,28 +1696,134 @@ func (e *Engine) findNextSeedFromX(seedX *big.Int) (Point, bool) {
 	return Point{}, false
 }
 
-func printHuman(o Out) {
-	fmt.Printf("Curve: y^2 = x^3 + A x + B over F_p\nA = %s\nB = %s\np = %s\n\n", o.A, o.B, o.P)
+func printHuman(w io.Writer, o Out) {
+	switch o.Form {
+	case "montgomery":
+		fmt.Fprintf(w, "Curve: B y^2 = x^3 + A x^2 + x over F_p\nA = %s\nB = %s\np = %s\n", o.A, o.B, o.P)
+	case "edwards":
+		fmt.Fprintf(w, "Curve: a x^2 + y^2 = 1 + d x^2 y^2 over F_p\na = %s\nd = %s\np = %s\n", o.A, o.B, o.P)
+	case "general":
+		fmt.Fprintf(w, "Curve: y^2 + a1 xy + a3 y = x^3 + a2 x^2 + a4 x + a6 over F_p\n[a1,a2,a3,a4,a6] = [%s]\np = %s\n", strings.Join(o.Ainvs, ","), o.P)
+	default:
+		field := "F_p"
+		if o.Ring != nil {
+			field = "Z/pZ"
+		}
+		if x := o.Extension; x != nil {
+			field = fmt.Sprintf("F_{p^%d} = F_p[t]/(%s)", x.K, x.Modulus)
+		}
+		fmt.Fprintf(w, "Curve: y^2 = x^3 + A x + B over %s\nA = %s\nB = %s\np = %s\n\n", field, o.A, o.B, o.P)
+	}
+	if o.Form != "" {
+		fmt.Fprintf(w, "Weierstrass form: y^2 = x^3 + %s x + %s\n\n", o.WA, o.WB)
+	}
+	if o.PrimeProof != nil {
+		fmt.Fprintf(w, "p proven prime (%s certificate, %d factors of p-1; -json for the full proof)\n", o.PrimeProof.Method, len(o.PrimeProof.Factors))
+	}
+	if len(o.PFactors) > 0 {
+		fmt.Fprintf(w, "p is composite: %s\n", strings.Join(o.PFactors, " × "))
+	}
+	for _, f := range o.FactorsFound {
+		fmt.Fprintf(w, "Factor of p from a failed inversion: %s × %s (first on the %s, %d lines in all)\n", f.Factor, f.Cofactor, f.Line, f.Hits)
+	}
+	if o.Ring != nil {
+		fmt.Fprintln(w, "Ring Z/pZ, by CRT over the prime-power factors:")
+		for _, f := range o.Ring.Factors {
+			fmt.Fprintf(w, "  %s^%d: #E(F_q) = %s, %s affine points mod q^e, %d lines, complete %v\n", f.Q, f.E, f.PointCount, f.Affine, f.Lines, f.Complete)
+		}
+		fmt.Fprintf(w, "Affine points mod p: %s\n", o.Ring.Affine)
+	}
 	if o.KnownCount != "" {
-		fmt.Printf("Point count (target): %s\n", o.KnownCount)
+		fmt.Fprintf(w, "Point count (target): %s\n", o.KnownCount)
+	}
+	if gi := o.GridIn; gi != nil {
+		fmt.Fprintf(w, "Grid loaded: %d found and %d excluded cells from %s (%d lines)\n", gi.Found, gi.Excluded, gi.File, gi.Lines)
+	}
+	if pi := o.PointsIn; pi != nil {
+		fmt.Fprintf(w, "Preloaded points: %d from %s (%d duplicates)\n", pi.Points, pi.File, pi.Duplicates)
+	}
+	fmt.Fprintf(w, "Lines processed: %d\n", o.Lines)
+	if o.Sweep != nil {
+		printSweep(w, o.Sweep)
 	}
-	fmt.Printf("Lines processed: %d\n", o.Lines)
-	fmt.Printf("Complete (matched target): %v\n\n", o.Complete)
-	fmt.Println("Found points (affine first, then O if present):")
+	fmt.Fprintf(w, "Complete (matched target): %v\n", o.Complete)
+	if o.Truncated {
+		fmt.Fprintln(w, "Truncated: yes (-max_seconds / -max_mem; see the notes)")
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Found points (affine first, then O if present):")
 	for _, pt := range o.Found {
+		ord := ""
+		if pt.Order != "" {
+			ord = "  order " + pt.Order
+		}
+		if pt.SEC1 != "" {
+			ord += "  " + pt.SEC1
+		}
 		if pt.Inf {
-			fmt.Println("  O")
+			fmt.Fprintf(w, "  O%s\n", ord)
 			continue
 		}
-		fmt.Printf("  (%s, %s)\n", pt.X, pt.Y)
+		fmt.Fprintf(w, "  (%s, %s)%s\n", pt.X, pt.Y, ord)
+	}
+	if o.Stats != nil {
+		printStats(w, o.Stats)
+	}
+	if o.Coverage != nil {
+		printCoverage(w, o.Coverage)
+	}
+	if o.Implicit != nil {
+		printImplicit(w, o.Implicit)
+	}
+	if o.Reach != nil {
+		printReach(w, o.Reach)
+	}
+	if o.Hasse != nil {
+		printHasse(w, o.Hasse)
+	}
+	if len(o.Queries) > 0 {
+		printQueries(w, o.Queries)
+	}
+	if o.CrossCheck != nil {
+		printCrossCheck(w, o.CrossCheck)
+	}
+	if o.Audit != nil {
+		printAudit(w, o.Audit)
+	}
+	if g := o.Group; g != nil {
+		fmt.Fprintf(w, "\nGroup structure: Z/%s x Z/%s (cyclic: %v)\n", g.N1, g.N2, g.Cyclic)
+		for i, pt := range g.Generators {
+			fmt.Fprintf(w, "  P%d = (%s, %s)\n", i+1, pt.X, pt.Y)
+		}
+	}
+	if o.Isogenies != nil {
+		fmt.Fprintf(w, "\nRational isogenies: %d\n", len(o.Isogenies))
+		for _, iso := range o.Isogenies {
+			fmt.Fprintln(w, "  "+isogenyNote(iso))
+		}
+	}
+	if t := o.Twist; t != nil {
+		fmt.Fprintf(w, "\nQuadratic twist E^d (d = %s): A = %s, B = %s\n", t.D, t.A, t.B)
+		fmt.Fprintf(w, "Point count (target): %s\n", t.KnownCount)
+		fmt.Fprintf(w, "Lines processed: %d\n", t.Lines)
+		fmt.Fprintf(w, "Complete (matched target): %v\n", t.Complete)
+		fmt.Fprintf(w, "#E + #E^d = 2p+2: %v\n", t.SumCheck)
+		fmt.Fprintln(w, "Found points on E^d:")
+		for _, pt := range t.Found {
+			fmt.Fprintf(w, "  (%s, %s)\n", pt.X, pt.Y)
+		}
 	}
 	if len(o.Notes) > 0 {
-		fmt.Println("\nNotes:")
+		fmt.Fprintln(w, "\nNotes:")
 		for _, n := range o.Notes {
-			fmt.Printf("  - %s\n", n)
+			fmt.Fprintf(w, "  - %s\n", n)
 		}
 	}
 }
 
-func die(err error)   { fmt.Fprintln(os.Stderr, "error:", err); os.Exit(2) }
-func dieStr(s string) { fmt.Fprintln(os.Stderr, "error:", s); os.Exit(2) }
+// stopProfiles finishes the -cpuprofile/-memprofile/-trace files; the
+// exits below call it, as os.Exit skips main's deferred call.
+var stopProfiles = func() {}
+
+func die(err error)   { stopProfiles(); fmt.Fprintln(os.Stderr, "error:", err); os.Exit(2) }
+func dieStr(s string) { stopProfiles(); fmt.Fprintln(os.Stderr, "error:", s); os.Exit(2) }
@@ -1,8 +1,18 @@
 package main
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
 	"math/big"
+	mrand "math/rand"
+	"os"
+	"strings"
 	"testing"
+
+	"ectorus/internal/ec"
+	"ectorus/pkg/encoding"
 )
 
 // ---------- helpers ----------
@@ -12,7 +22,7 @@ func bi(v int64) *big.Int { return big.NewInt(v) }
 func mustCurve(t *testing.T, p, A, B int64) Curve {
 	t.Helper()
 	P := bi(p)
-	return Curve{P: P, A: mod(bi(A), P), B: mod(bi(B), P)}
+	return Curve{P: P, A: ec.Mod(bi(A), P), B: ec.Mod(bi(B), P)}
 }
 
 func pt(x, y int64) Point {
@@ -33,15 +43,15 @@ func finiteCount(e *Engine) int {
 // ---------- unit tests ----------
 
 func TestParseBig(t *testing.T) {
-	z, err := parseBig("12345")
+	z, err := ec.ParseBig("12345")
 	if err != nil || z.Cmp(bi(12345)) != 0 {
 		t.Fatalf("parse dec failed: %v %v", z, err)
 	}
-	z, err = parseBig("0x2a")
+	z, err = ec.ParseBig("0x2a")
 	if err != nil || z.Cmp(bi(42)) != 0 {
 		t.Fatalf("parse hex failed: %v %v", z, err)
 	}
-	z, err = parseBig(" 12345 ")
+	z, err = ec.ParseBig(" 12345 ")
 	if err != nil || z.Cmp(bi(12345)) != 0 {
 		t.Fatalf("parse white space around dec failed: %v %v", z, err)
 	}
@@ -49,19 +59,19 @@ func TestParseBig(t *testing.T) {
 
 func TestModOps(t *testing.T) {
 	p := bi(11)
-	if mod(bi(-1), p).Cmp(bi(10)) != 0 { // -1 ≡ 10 mod 11
+	if ec.Mod(bi(-1), p).Cmp(bi(10)) != 0 { // -1 ≡ 10 mod 11
 		t.Fatal("mod neg wrong")
 	}
-	if addM(bi(8), bi(5), p).Cmp(bi(2)) != 0 { // (8 + 5) = 13 ≡ 2 mod 11
+	if ec.AddM(bi(8), bi(5), p).Cmp(bi(2)) != 0 { // (8 + 5) = 13 ≡ 2 mod 11
 		t.Fatal("addM wrong")
 	}
-	if subM(bi(3), bi(5), p).Cmp(bi(9)) != 0 { // (3 - 5) = -2 ≡ 9 mod 11
+	if ec.SubM(bi(3), bi(5), p).Cmp(bi(9)) != 0 { // (3 - 5) = -2 ≡ 9 mod 11
 		t.Fatal("subM wrong")
 	}
-	if mulM(bi(7), bi(5), p).Cmp(bi(2)) != 0 { // (7 x 5) = 35 ≡ 2 mod 11
+	if ec.MulM(bi(7), bi(5), p).Cmp(bi(2)) != 0 { // (7 x 5) = 35 ≡ 2 mod 11
 		t.Fatal("mulM wrong")
 	}
-	inv, err := invM(bi(5), p)
+	inv, err := ec.InvM(bi(5), p)
 	if err != nil || inv.Cmp(bi(9)) != 0 { // (5 * 9) = 45 ≡ 1 mod 11
 		t.Fatalf("invM wrong: %v %v", inv, err)
 	}
@@ -70,16 +80,16 @@ func TestModOps(t *testing.T) {
 // non-residue -> an element a ∈ F_p for which there is no x ∈ F_p with 𝑥^2 ≡ 𝑎 (mod 𝑝)
 func TestLegendreAndSqrt(t *testing.T) {
 	p := bi(11)
-	if legendre(bi(0), p) != 0 {
+	if ec.Legendre(bi(0), p) != 0 {
 		t.Fatal("Legendre(0) != 0") // by definition
 	}
-	if legendre(bi(2), p) != -1 { // 2 is non-residue mod 11
+	if ec.Legendre(bi(2), p) != -1 { // 2 is non-residue mod 11
 		t.Fatal("Legendre(2) != -1")
 	}
-	if legendre(bi(4), p) != 1 {
+	if ec.Legendre(bi(4), p) != 1 {
 		t.Fatal("Legendre(4) != 1") // 4 is residue mod 11, because (9 * 9) = 81 ≡ 4 mod 11
 	}
-	y, err := sqrtModP(bi(4), p)
+	y, err := ec.SqrtModP(bi(4), p)
 	if err != nil {
 		t.Fatalf("sqrtModP failed: %v", err)
 	}
@@ -87,7 +97,7 @@ func TestLegendreAndSqrt(t *testing.T) {
 		t.Fatalf("sqrtModP is wrong, should be 9, gives: %v", y) // (9 * 9) = 81 ≡ 4 mod 11
 	}
 	// y^2 == 4; the other root is p - y
-	yy := mulM(y, y, p)
+	yy := ec.MulM(y, y, p)
 	if yy.Cmp(bi(4)) != 0 {
 		t.Fatalf("sqrt square wrong: got %v", yy)
 	}
@@ -96,12 +106,12 @@ func TestLegendreAndSqrt(t *testing.T) {
 func TestSingularCheck(t *testing.T) {
 	// y^2 = x^3 (A=0,B=0) is singular over any p
 	c := mustCurve(t, 11, 0, 0)
-	if !c.isSingular() {
+	if !c.IsSingular() {
 		t.Fatal("expected singular curve")
 	}
 	// y^2 = x^3 + 1 over p=11 is nonsingular (4A^3+27B^2 = 27 ≡ 5 != 0)
 	c = mustCurve(t, 11, 0, 1)
-	if c.isSingular() {
+	if c.IsSingular() {
 		t.Fatal("expected nonsingular curve")
 	}
 }
@@ -109,19 +119,19 @@ func TestSingularCheck(t *testing.T) {
 func TestOnNegAndAddBasics(t *testing.T) {
 	c := mustCurve(t, 11, 0, 1) // y^2 = x^3 + 1
 	P := pt(0, 1)               // 1^2 = 0^3 + 1
-	if !c.on(P) {
+	if !c.On(P) {
 		t.Fatal("P not on curve")
 	}
-	mP := c.neg(P)
+	mP := c.Neg(P)
 	if mP.X.Cmp(P.X) != 0 || mP.Y.Cmp(bi(10)) != 0 { // -1 ≡ 10 mod 11
 		t.Fatal("negation wrong")
 	}
 	O := Point{Inf: true}
-	Q, _ := c.add(P, O)
-	if !c.on(Q) || Q.X.Cmp(P.X) != 0 || Q.Y.Cmp(P.Y) != 0 {
+	Q, _ := c.Add(P, O)
+	if !c.On(Q) || Q.X.Cmp(P.X) != 0 || Q.Y.Cmp(P.Y) != 0 {
 		t.Fatal("P + O != P")
 	}
-	Q, _ = c.add(P, c.neg(P))
+	Q, _ = c.Add(P, c.Neg(P))
 	if !Q.Inf {
 		t.Fatal("P + (-P) != O")
 	}
@@ -131,10 +141,10 @@ func TestDoubleVerticalAtYZero(t *testing.T) {
 	c := mustCurve(t, 11, 0, 1)
 	// y=0 ⇒ x^3 + 1 = 0 mod 11 ⇒ x^3 ≡ 10; x=10 works.
 	P := pt(10, 0)
-	if !c.on(P) {
+	if !c.On(P) {
 		t.Fatal("point (10,0) should be on curve")
 	}
-	R, _ := c.double(P)
+	R, _ := c.Double(P)
 	if !R.Inf {
 		t.Fatal("doubling y=0 should go to O (vertical tangent)")
 	}
@@ -149,34 +159,44 @@ func TestLineThroughTangentAndSecant(t *testing.T) {
 		t.Fatalf("tangent should be non-vertical: %v %v", L, err)
 	}
 	// Secant with -P should be vertical
-	mP := c.neg(P)
+	mP := c.Neg(P)
 	L2, err := lineThrough(c, P, &mP)
 	if err != nil || !L2.Vertical {
 		t.Fatalf("secant P,-P should be vertical: %v %v", L2, err)
 	}
 	// Key stability
-	if L.key() == "" || L2.key() == "" {
-		t.Fatal("empty line key")
+	if L.key() != L.key() || L.key() == L2.key() {
+		t.Fatal("line keys unstable or colliding")
 	}
 }
 
-func TestThirdIntersectionContracts(t *testing.T) {
+func TestChordSumContracts(t *testing.T) {
 	c := mustCurve(t, 11, 0, 1)
 	P := pt(0, 1)
-	// Tangent
-	R, inter, err := thirdIntersection(c, P, nil)
+	// Tangent: P twice and -2P on the line, 2P off it
+	L, err := lineThrough(c, P, nil)
 	if err != nil {
-		t.Fatalf("thirdIntersection tangent err: %v", err)
+		t.Fatal(err)
+	}
+	R, inter := chordSum(c, L, P, nil)
+	if want, _ := c.Double(P); !R.Equal(want) {
+		t.Fatalf("tangent sum %v, want %v", R, want)
 	}
-	if len(inter) < 2 {
-		t.Fatalf("tangent intersections expected ≥2, got %d", len(inter))
+	if len(inter) != 2 || !inter[1].Equal(c.Neg(R)) {
+		t.Fatalf("tangent intersections %v", inter)
+	}
+	for _, S := range inter {
+		if !onLine(L, S, c.P) {
+			t.Fatalf("%v is not on the tangent", S)
+		}
 	}
 	// Secant with -P → R = O
-	mP := c.neg(P)
-	R, inter, err = thirdIntersection(c, P, &mP)
+	mP := c.Neg(P)
+	L2, err := lineThrough(c, P, &mP)
 	if err != nil {
-		t.Fatalf("thirdIntersection secant err: %v", err)
+		t.Fatal(err)
 	}
+	R, inter = chordSum(c, L2, P, &mP)
 	if !R.Inf {
 		t.Fatal("secant through P,-P should have R=O")
 	}
@@ -190,15 +210,14 @@ func TestGridMarkLineExclusions(t *testing.T) {
 	g := newGrid(p)
 	// y = 2x + 3 mod 11
 	L := Line{Vertical: false, M: bi(2), C: bi(3)}
-	keep := map[string]bool{
-		"0|3": true, // x=0 → y=3
+	keep := map[cell]bool{
+		{0, 3}: true, // x=0 → y=3
 	}
 	g.markLineExclusions(L, keep)
 	// All points on that line should be excluded except kept ones
 	for x := 0; x < p; x++ {
 		y := (2*x + 3) % p
-		key := (fmtInt(x) + "|" + fmtInt(y))
-		if keep[key] {
+		if keep[cell{x, y}] {
 			if g.isExcluded(x, y) {
 				t.Fatalf("kept point (%d,%d) marked excluded", x, y)
 			}
@@ -208,17 +227,15 @@ func TestGridMarkLineExclusions(t *testing.T) {
 	}
 }
 
-func fmtInt(x int) string { return big.NewInt(int64(x)).String() }
-
 func TestEngineAddFoundInitializesIndexMap(t *testing.T) {
 	c := mustCurve(t, 11, 0, 1)
 	e := &Engine{
 		C:           c,
 		UseGrid:     false,
-		found:       map[string]Point{},
-		linesDone:   map[string]bool{},
-		secantDone:  map[string]bool{},
-		tangentDone: map[string]bool{},
+		found:       map[ptKey]Point{},
+		linesDone:   map[lineKey]bool{},
+		secantDone:  map[pairKey]bool{},
+		tangentDone: map[ptKey]bool{},
 		// indexOf intentionally left nil to exercise guard
 	}
 	P := pt(0, 1)
@@ -239,12 +256,12 @@ func TestEngineWalkSmallCurveCompletes(t *testing.T) {
 	e := &Engine{
 		C:           c,
 		UseGrid:     false,
-		found:       map[string]Point{},
-		linesDone:   map[string]bool{},
-		secantDone:  map[string]bool{},
-		tangentDone: map[string]bool{},
-		indexOf:     map[string]int{},
-		deadX:       map[string]bool{},
+		found:       map[ptKey]Point{},
+		linesDone:   map[lineKey]bool{},
+		secantDone:  map[pairKey]bool{},
+		tangentDone: map[ptKey]bool{},
+		indexOf:     map[ptKey]int{},
+		deadX:       map[coord]bool{},
 	}
 	// count first to set a stopping target
 	e.KnownCount = countLegendre(c) // expect 12
@@ -278,14 +295,14 @@ func TestFindNextSeedFromX(t *testing.T) {
 	e := &Engine{
 		C:           c,
 		UseGrid:     false,
-		found:       map[string]Point{},
-		linesDone:   map[string]bool{},
-		secantDone:  map[string]bool{},
-		tangentDone: map[string]bool{},
+		found:       map[ptKey]Point{},
+		linesDone:   map[lineKey]bool{},
+		secantDone:  map[pairKey]bool{},
+		tangentDone: map[ptKey]bool{},
 	}
 	// Ask for x=0 explicitly
 	Pt, ok := e.findNextSeedFromX(bi(0))
-	if !ok || !e.C.on(Pt) {
+	if !ok || !e.C.On(Pt) {
 		t.Fatalf("findNextSeedFromX failed: ok=%v P=%v", ok, Pt)
 	}
 }
@@ -295,11 +312,11 @@ func TestProcessLineFromSetsLinesDone(t *testing.T) {
 	e := &Engine{
 		C:           c,
 		UseGrid:     false,
-		found:       map[string]Point{},
-		linesDone:   map[string]bool{},
-		secantDone:  map[string]bool{},
-		tangentDone: map[string]bool{},
-		indexOf:     map[string]int{},
+		found:       map[ptKey]Point{},
+		linesDone:   map[lineKey]bool{},
+		secantDone:  map[pairKey]bool{},
+		tangentDone: map[ptKey]bool{},
+		indexOf:     map[ptKey]int{},
 	}
 	P := pt(0, 1)
 	e.addFound(P)
@@ -318,14 +335,19 @@ func TestProcessLineFromSetsLinesDone(t *testing.T) {
 
 func TestVerticalSecantThirdIsInfinity(t *testing.T) {
 	c := mustCurve(t, 11, 0, 1)
+	e := NewEngine(c, false, 0, false)
 	P := pt(0, 1)
-	mP := c.neg(P)
-	R, inters, err := thirdIntersection(c, P, &mP)
+	mP := c.Neg(P)
+	L, err := lineThrough(c, P, &mP)
 	if err != nil {
-		t.Fatalf("thirdIntersection error: %v", err)
+		t.Fatal(err)
 	}
-	if !R.Inf {
-		t.Fatalf("expected R=O for vertical secant, got %v", R)
+	inters, extra, err := e.intersections(L, P, &mP)
+	if err != nil {
+		t.Fatalf("intersections error: %v", err)
+	}
+	if len(extra) != 0 {
+		t.Fatalf("expected R=O for vertical secant, got extra %v", extra)
 	}
 	if len(inters) != 2 {
 		t.Fatalf("expected exactly P and -P intersections, got %d", len(inters))
@@ -340,10 +362,10 @@ func enumeratePoints(c Curve, max int) []Point {
 	var pts []Point
 	for x := new(big.Int).SetInt64(0); x.Cmp(c.P) < 0; x.Add(x, big.NewInt(1)) {
 		// rhs = x^3 + A x + B
-		rhs := addM(addM(mulM(x, mulM(x, x, c.P), c.P), mulM(c.A, x, c.P), c.P), c.B, c.P)
+		rhs := ec.AddM(ec.AddM(ec.MulM(x, ec.MulM(x, x, c.P), c.P), ec.MulM(c.A, x, c.P), c.P), c.B, c.P)
 		// try all y to avoid any sqrtModP dependency
 		for y := new(big.Int).SetInt64(0); y.Cmp(c.P) < 0; y.Add(y, big.NewInt(1)) {
-			if mulM(y, y, c.P).Cmp(rhs) == 0 {
+			if ec.MulM(y, y, c.P).Cmp(rhs) == 0 {
 				pts = append(pts, Point{X: new(big.Int).Set(x), Y: new(big.Int).Set(y)})
 				if len(pts) >= max {
 					return pts
@@ -365,7 +387,7 @@ func TestVerticalTangentLineThrough(t *testing.T) {
 	if !L.Vertical || L.V.Cmp(bi(10)) != 0 {
 		t.Fatalf("expected vertical tangent x=10, got %+v", L)
 	}
-	R, _ := c.double(P)
+	R, _ := c.Double(P)
 	if !R.Inf {
 		t.Fatal("doubling y=0 should return O")
 	}
@@ -376,12 +398,12 @@ func TestLineDeDupNoReprocess(t *testing.T) {
 	e := &Engine{
 		C:           c,
 		UseGrid:     false,
-		found:       map[string]Point{},
-		linesDone:   map[string]bool{},
-		secantDone:  map[string]bool{},
-		tangentDone: map[string]bool{},
-		indexOf:     map[string]int{},
-		deadX:       map[string]bool{},
+		found:       map[ptKey]Point{},
+		linesDone:   map[lineKey]bool{},
+		secantDone:  map[pairKey]bool{},
+		tangentDone: map[ptKey]bool{},
+		indexOf:     map[ptKey]int{},
+		deadX:       map[coord]bool{},
 	}
 	// Seed and walk once
 	e.addFound(pt(0, 1))
@@ -402,14 +424,13 @@ func TestGridVerticalLineExclusions(t *testing.T) {
 	p := 11
 	g := newGrid(p)
 	L := Line{Vertical: true, V: bi(3)} // x = 3
-	keep := map[string]bool{
-		"3|0": true,
-		"3|7": true,
+	keep := map[cell]bool{
+		{3, 0}: true,
+		{3, 7}: true,
 	}
 	g.markLineExclusions(L, keep)
 	for y := 0; y < p; y++ {
-		key := fmtInt(3) + "|" + fmtInt(y)
-		if keep[key] {
+		if keep[cell{3, y}] {
 			if g.isExcluded(3, y) {
 				t.Fatalf("kept (%d,%d) marked excluded", 3, y)
 			}
@@ -422,25 +443,25 @@ func TestGridVerticalLineExclusions(t *testing.T) {
 func TestSqrtModP_Peq1mod4Branch(t *testing.T) {
 	// p=13 (1 mod 4) forces Tonelli–Shanks branch; 10 is a residue mod 13.
 	p := bi(13)
-	if legendre(bi(10), p) != 1 {
+	if ec.Legendre(bi(10), p) != 1 {
 		t.Fatal("sanity: 10 should be residue mod 13")
 	}
-	r, err := sqrtModP(bi(10), p)
+	r, err := ec.SqrtModP(bi(10), p)
 	if err != nil {
 		t.Fatalf("sqrtModP err: %v", err)
 	}
-	if mulM(r, r, p).Cmp(bi(10)) != 0 {
+	if ec.MulM(r, r, p).Cmp(bi(10)) != 0 {
 		t.Fatalf("r^2 != 10 mod 13, got %v", r)
 	}
 }
 
 func TestSqrtModP_NonResidueErrors(t *testing.T) {
 	p := bi(11)
-	if _, err := sqrtModP(bi(2), p); err == nil {
+	if _, err := ec.SqrtModP(bi(2), p); err == nil {
 		t.Fatal("expected error for non-residue")
 	}
 	// a=0 case: should return 0, no error
-	r, err := sqrtModP(bi(0), p)
+	r, err := ec.SqrtModP(bi(0), p)
 	if err != nil || r.Sign() != 0 {
 		t.Fatalf("sqrt(0) failed: %v %v", r, err)
 	}
@@ -455,11 +476,11 @@ func TestGroupBasics_CommuteNegateAssoc(t *testing.T) {
 	P, Q, R := pts[0], pts[1], pts[2]
 
 	// Commutativity: P+Q == Q+P
-	a, err := c.add(P, Q)
+	a, err := c.Add(P, Q)
 	if err != nil {
 		t.Fatal(err)
 	}
-	b, err := c.add(Q, P)
+	b, err := c.Add(Q, P)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -468,24 +489,24 @@ func TestGroupBasics_CommuteNegateAssoc(t *testing.T) {
 	}
 
 	// Negation involution: -(-P) == P
-	if nn := c.neg(c.neg(P)); nn.Inf != P.Inf || (!nn.Inf && (nn.X.Cmp(P.X) != 0 || nn.Y.Cmp(P.Y) != 0)) {
+	if nn := c.Neg(c.Neg(P)); nn.Inf != P.Inf || (!nn.Inf && (nn.X.Cmp(P.X) != 0 || nn.Y.Cmp(P.Y) != 0)) {
 		t.Fatal("negation not an involution")
 	}
 
 	// Associativity: (P+Q)+R == P+(Q+R)
-	pq, err := c.add(P, Q)
+	pq, err := c.Add(P, Q)
 	if err != nil {
 		t.Fatal(err)
 	}
-	left, err := c.add(pq, R)
+	left, err := c.Add(pq, R)
 	if err != nil {
 		t.Fatal(err)
 	}
-	qr, err := c.add(Q, R)
+	qr, err := c.Add(Q, R)
 	if err != nil {
 		t.Fatal(err)
 	}
-	right, err := c.add(P, qr)
+	right, err := c.Add(P, qr)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -501,12 +522,12 @@ func TestWalkGridModeMarksSomeExclusions(t *testing.T) {
 		C:           c,
 		UseGrid:     true,
 		G:           newGrid(11),
-		found:       map[string]Point{},
-		linesDone:   map[string]bool{},
-		secantDone:  map[string]bool{},
-		tangentDone: map[string]bool{},
-		indexOf:     map[string]int{},
-		deadX:       map[string]bool{},
+		found:       map[ptKey]Point{},
+		linesDone:   map[lineKey]bool{},
+		secantDone:  map[pairKey]bool{},
+		tangentDone: map[ptKey]bool{},
+		indexOf:     map[ptKey]int{},
+		deadX:       map[coord]bool{},
 	}
 	e.addFound(pt(0, 1))
 	// Record excluded count before
@@ -534,6 +555,23 @@ func TestWalkGridModeMarksSomeExclusions(t *testing.T) {
 	}
 }
 
+func TestGridKeepsCurvePoints(t *testing.T) {
+	// A line's third intersection is -(P+Q), not P+Q; excluding it would
+	// mark curve points the full walk has found as excluded on the grid.
+	c := Curve{P: big.NewInt(101), A: big.NewInt(2), B: big.NewInt(3)}
+	e := NewEngine(c, true, 0, false)
+	e.KnownCount = countLegendre(c)
+	e.Rand = mrand.New(mrand.NewSource(3))
+	if _, err := e.run(nil); err != nil || !e.isComplete() {
+		t.Fatalf("walk: complete=%v err=%v", e.isComplete(), err)
+	}
+	for _, P := range enumeratePoints(c, 101) {
+		if e.G.isExcluded(int(P.X.Int64()), int(P.Y.Int64())) {
+			t.Fatalf("curve point %v is excluded", P)
+		}
+	}
+}
+
 func TestFindNextSeedFromX_YZero(t *testing.T) {
 	// On y^2 = x^3 + 1 over p=11, x=10 gives y=0; make sure we get it.
 	e := &Engine{C: mustCurve(t, 11, 0, 1)}
@@ -556,7 +594,7 @@ func TestLineKeyUniqueness(t *testing.T) {
 		t.Fatal(err)
 	}
 	if L1.key() != L2.key() {
-		t.Fatalf("same line produced different keys: %s vs %s", L1.key(), L2.key())
+		t.Fatalf("same line produced different keys: %v vs %v", L1.key(), L2.key())
 	}
 	// A different line should produce a different key
 	Q := pt(1, 0) // not on this curve; pick another on-curve point
@@ -571,6 +609,1356 @@ func TestLineKeyUniqueness(t *testing.T) {
 		t.Fatal(err)
 	}
 	if L1.key() == L3.key() {
-		t.Fatalf("distinct lines share key: %s", L1.key())
+		t.Fatalf("distinct lines share key: %v", L1.key())
+	}
+}
+
+func TestQuadraticTwistCountsSumTo2pPlus2(t *testing.T) {
+	for _, tc := range []struct{ p, A, B int64 }{{11, 0, 1}, {101, 2, 3}, {103, 1, 1}} {
+		c := mustCurve(t, tc.p, tc.A, tc.B)
+		tw, d := c.QuadraticTwist()
+		if ec.Legendre(d, c.P) != -1 {
+			t.Fatalf("p=%d: twist parameter d=%v is a residue", tc.p, d)
+		}
+		if tw.IsSingular() {
+			t.Fatalf("p=%d: twist is singular", tc.p)
+		}
+		sum := new(big.Int).Add(countLegendre(c), countLegendre(tw))
+		if want := bi(2*tc.p + 2); sum.Cmp(want) != 0 {
+			t.Fatalf("p=%d: #E + #E^d = %v, want %v", tc.p, sum, want)
+		}
+	}
+}
+
+func TestRunTwistWalkPassesSumCheck(t *testing.T) {
+	c := mustCurve(t, 11, 0, 1)
+	e := NewEngine(c, false, 0, true)
+	e.KnownCount = countLegendre(c)
+	if _, err := e.run(bi(0)); err != nil {
+		t.Fatal(err)
+	}
+	to, err := runTwist(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !to.Complete || !to.SumCheck {
+		t.Fatalf("twist walk incomplete or failed cross-check: %+v", to)
+	}
+}
+
+// completeEngine walks c to completion with a counted target.
+func completeEngine(t *testing.T, c Curve) *Engine {
+	t.Helper()
+	e := NewEngine(c, false, 0, true)
+	e.KnownCount = countLegendre(c)
+	if _, err := e.run(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !e.isComplete() {
+		t.Fatal("walk did not complete")
+	}
+	return e
+}
+
+func TestGroupStructure(t *testing.T) {
+	cases := []struct {
+		p, A, B int64
+		n1, n2  int64
+	}{
+		{11, 0, 1, 1, 12}, // p ≡ 2 mod 3: only one 2-torsion point, cyclic
+		{11, -1, 0, 2, 6}, // y^2 = x(x-1)(x+1): full 2-torsion
+		{1009, -1, 0, 4, 260},
+		{1009, -1, 0, 4, 260}, // again, with -coords jacobian
+	}
+	for i, tc := range cases {
+		c := mustCurve(t, tc.p, tc.A, tc.B)
+		e := completeEngine(t, c)
+		e.Jacobian = i == len(cases)-1 // -coords jacobian must not change the answer
+		g, err := e.groupStructure()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.N1 != bi(tc.n1).String() || g.N2 != bi(tc.n2).String() {
+			t.Fatalf("p=%d A=%d B=%d jacobian=%v: got Z/%s x Z/%s, want Z/%d x Z/%d", tc.p, tc.A, tc.B, e.Jacobian, g.N1, g.N2, tc.n1, tc.n2)
+		}
+		N := e.KnownCount
+		fs, err := orderFactors(N)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []int64{tc.n2, tc.n1}
+		for i, gp := range g.Generators {
+			x, _ := new(big.Int).SetString(gp.X, 10)
+			y, _ := new(big.Int).SetString(gp.Y, 10)
+			ord, err := pointOrder(c.ScalarMul, Point{X: x, Y: y}, N, fs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ord.Cmp(bi(want[i])) != 0 {
+				t.Fatalf("generator %d has order %v, want %d", i+1, ord, want[i])
+			}
+		}
+	}
+}
+
+func TestAnnotateOrders(t *testing.T) {
+	// y^2 = x^3 - x over p=11 is Z/2 x Z/6: three points of order 2,
+	// two of order 3 and six of order 6.
+	c := mustCurve(t, 11, -1, 0)
+	e := completeEngine(t, c)
+	var pts []Pt
+	for _, P := range e.sortedFound() {
+		pts = append(pts, toPt(P))
+	}
+	if err := e.annotateOrders(pts); err != nil {
+		t.Fatal(err)
+	}
+	hist := map[string]int{}
+	for _, p := range pts {
+		hist[p.Order]++
+	}
+	if hist["2"] != 3 || hist["3"] != 2 || hist["6"] != 6 || len(hist) != 3 {
+		t.Fatalf("unexpected order histogram: %v", hist)
+	}
+}
+
+func TestWalkOtherForms(t *testing.T) {
+	p := big.NewInt(101)
+	ainvs11a1, err := parseAinvs("[0,-1,1,-10,-20]") // Cremona 11a1
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, form := range []string{"montgomery", "edwards", "general"} {
+		mdl, c, err := parseForm(form, p, big.NewInt(3), big.NewInt(5), ainvs11a1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := NewEngine(c, true, 0, true)
+		e.Model = mdl
+		e.KnownCount = countLegendre(c)
+		e.AffineTarget = e.countAffine()
+
+		// brute-force the affine count straight from the model's equation
+		want := 0
+		for x := int64(0); x < 101; x++ {
+			for y := int64(0); y < 101; y++ {
+				if mdl.on(Point{X: big.NewInt(x), Y: big.NewInt(y)}) {
+					want++
+				}
+			}
+		}
+		if e.AffineTarget.Int64() != int64(want) {
+			t.Fatalf("%s: countAffine=%s, brute force=%d", form, e.AffineTarget, want)
+		}
+
+		if _, err := e.run(nil); err != nil {
+			t.Fatal(err)
+		}
+		if !e.isComplete() {
+			t.Fatalf("%s: walk found %d of %d affine points", form, e.finiteFound(), want)
+		}
+		for _, P := range e.found {
+			if !mdl.on(P) {
+				t.Fatalf("%s: found point %v not on curve", form, P)
+			}
+		}
+	}
+}
+
+func TestModelIntersectionsOnLine(t *testing.T) {
+	p := big.NewInt(101)
+	mdl, _, err := parseForm("edwards", p, big.NewInt(3), big.NewInt(5), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(Curve{P: p}, false, 0, false)
+	e.Model = mdl
+	P := e.pointsAtX(big.NewInt(2))
+	if len(P) == 0 {
+		t.Skip("no points at x=2")
+	}
+	L, err := modelLine(mdl, primeField{p}, P[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, S := range modelIntersections(mdl, primeField{p}, L, []Point{P[0], P[0]}) {
+		if !mdl.on(S) {
+			t.Fatalf("intersection %v not on curve", S)
+		}
+		onL := L.Vertical && S.X.Cmp(L.V) == 0 ||
+			!L.Vertical && ec.AddM(ec.MulM(L.M, S.X, p), L.C, p).Cmp(S.Y) == 0
+		if !onL {
+			t.Fatalf("intersection %v not on line %+v", S, L)
+		}
+	}
+}
+
+func TestManifestBatch(t *testing.T) {
+	path := t.TempDir() + "/curves.json"
+	manifest := `[
+		{"p": 101, "A": 2, "B": 3},
+		{"p": "0x65", "A": "0", "B": 7},
+		{"p": 11, "A": 0, "B": 0},
+		{"p": 13, "ainvs": [0,-1,1,-10,-20]},
+		{"p": "101", "A": "2", "B": "3", "pointCount": "96", "trace": "6"}
+	]`
+	if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	specs, err := loadManifest(path, "weierstrass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if specs[1].P != "0x65" || specs[3].Ainvs != "[0,-1,1,-10,-20]" || specs[3].A != "0" {
+		t.Fatalf("specs parsed wrong: %+v", specs)
+	}
+	outs := runManifest(specs, runOpts{CountFirst: true}, 3)
+	want := []string{"96", "102", "", "10", "96"}
+	for i, o := range outs {
+		if o.KnownCount != want[i] {
+			t.Fatalf("curve %d: pointCount %q, want %q (error %q)", i, o.KnownCount, want[i], o.Error)
+		}
+		if (o.Error != "") != (i == 2) {
+			t.Fatalf("curve %d: unexpected error state %q", i, o.Error)
+		}
+		if o.Error == "" && !o.Complete {
+			t.Fatalf("curve %d: walk incomplete", i)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"curve": "ed25519"}, {"curve": "p256", "p": 11}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadManifest(path, ""); err == nil || !strings.Contains(err.Error(), "curve 1") {
+		t.Fatalf("named curve with p: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"p": 11, "q": 3}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadManifest(path, ""); err == nil {
+		t.Fatal("unknown field accepted")
+	}
+}
+
+func TestRandSeedIsReproducible(t *testing.T) {
+	spec := curveSpec{P: "1009", A: "2", B: "3"}
+	o := runOpts{MaxLines: 3, RandSeed: 42}
+	a, err := runCurve(spec, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := runCurve(spec, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Found) == 0 || len(a.Found) != len(b.Found) || a.Lines != b.Lines {
+		t.Fatalf("runs differ: %d/%d points, %d/%d lines", len(a.Found), len(b.Found), a.Lines, b.Lines)
+	}
+	for i := range a.Found {
+		if a.Found[i] != b.Found[i] {
+			t.Fatalf("point %d: %+v vs %+v", i, a.Found[i], b.Found[i])
+		}
+	}
+}
+
+func TestSeedStrategies(t *testing.T) {
+	c := mustCurve(t, 101, 2, 3)
+	for _, s := range []string{"sequential", "low-x"} {
+		e := NewEngine(c, false, 0, true)
+		e.KnownCount = countLegendre(c)
+		if e.Seeds, _ = newSeeder(s, nil); e.Seeds == nil {
+			t.Fatalf("%s: no seeder", s)
+		}
+		first, ok := e.findNextSeedFromX(nil)
+		if !ok {
+			t.Fatalf("%s: no seed", s)
+		}
+		// x = 0 has no point (3 is a non-residue mod 101), x = 1 does
+		if first.X.Cmp(bi(1)) != 0 {
+			t.Fatalf("%s: first seed %v, want x = 1", s, first)
+		}
+		if _, err := e.run(nil); err != nil || !e.isComplete() {
+			t.Fatalf("%s: walk incomplete (%v)", s, err)
+		}
+	}
+
+	path := t.TempDir() + "/seeds.txt"
+	if err := os.WriteFile(path, []byte("# comment\n0 0\n1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pts, err := loadSeedFile(path)
+	if err != nil || len(pts) != 2 || pts[1].Y != nil {
+		t.Fatalf("loadSeedFile = %+v, %v", pts, err)
+	}
+	e := NewEngine(c, false, 0, false)
+	e.Seeds, _ = newSeeder("from-file", pts)
+	P, ok := e.findNextSeed()
+	if !ok || P.X.Cmp(bi(1)) != 0 {
+		t.Fatalf("from-file seed %v (ok=%v), want the point at x = 1", P, ok)
+	}
+	e.addFound(P)
+	e.addFound(c.Neg(P))
+	if _, ok := e.findNextSeed(); ok {
+		t.Fatal("from-file seeder should be exhausted")
+	}
+	if _, err := newSeeder("from-file", nil); err == nil {
+		t.Fatal("from-file without points accepted")
+	}
+}
+
+// BenchmarkWalk measures the map-heavy walk loop on p ≈ 10^4 (capped, so
+// one iteration is a fixed amount of work).
+func BenchmarkWalk(b *testing.B) {
+	c := Curve{P: bi(10007), A: bi(2), B: bi(3)}
+	seed := enumeratePoints(c, 1)[0]
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e := NewEngine(c, false, 0, false)
+		e.addFound(seed)
+		if err := e.walkAndExclude(100_000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestChordLinesMatchLineThrough(t *testing.T) {
+	c := mustCurve(t, 101, 2, 3)
+	pts := enumeratePoints(c, 40)
+	var jobs []lineJob
+	for i := range pts {
+		jobs = append(jobs, lineJob{P: pts[i]})
+		for j := 0; j < i; j++ {
+			jobs = append(jobs, lineJob{P: pts[i], Q: &pts[j]})
+		}
+	}
+	lines, err := chordLines(c, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, j := range jobs {
+		want, err := lineThrough(c, j.P, j.Q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines[i].key() != want.key() {
+			t.Fatalf("job %d: batched line %+v, want %+v", i, lines[i], want)
+		}
+		R, inters := chordSum(c, lines[i], j.P, j.Q)
+		Q := j.P
+		if j.Q != nil {
+			Q = *j.Q
+		}
+		wantR, err := c.Add(j.P, Q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !R.Equal(wantR) {
+			t.Fatalf("job %d: chordSum %v, want %v", i, R, wantR)
+		}
+		for _, S := range inters {
+			if !onLine(lines[i], S, c.P) || !S.Inf && !c.On(S) {
+				t.Fatalf("job %d: intersection %v is not on the line and the curve", i, S)
+			}
+		}
+	}
+}
+
+func TestSecantWindow(t *testing.T) {
+	e := &Engine{SecantWindow: 3, SecantPolicy: "recent"}
+	if got := e.secantPartners(10); len(got) != 3 || got[0] != 7 || got[2] != 9 {
+		t.Fatalf("recent partners of 10: %v", got)
+	}
+	if got := e.secantPartners(2); len(got) != 2 {
+		t.Fatalf("window larger than history: %v", got)
+	}
+	e.SecantPolicy = "random"
+	e.Rand = mrand.New(mrand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		got := e.secantPartners(20)
+		if len(got) != 3 || got[0] >= got[1] || got[1] >= got[2] || got[0] < 0 || got[2] >= 20 {
+			t.Fatalf("random partners of 20: %v", got)
+		}
+	}
+	e.SecantPolicy = "all"
+	if got := e.secantPartners(10); len(got) != 10 {
+		t.Fatalf("policy all: %v", got)
+	}
+
+	// a windowed walk still completes once reseeding fills the gaps
+	c := mustCurve(t, 211, 2, 3)
+	w := NewEngine(c, false, 0, true)
+	w.KnownCount = countLegendre(c)
+	w.SecantWindow, w.SecantPolicy = 2, "recent"
+	if _, err := w.run(nil); err != nil || !w.isComplete() {
+		t.Fatalf("windowed walk incomplete (%v)", err)
+	}
+}
+
+func TestGreedyScheduleCompletesAndTracksCoverage(t *testing.T) {
+	c := mustCurve(t, 101, 2, 3)
+	for _, sched := range []string{"fifo", "greedy"} {
+		e := NewEngine(c, true, 0, true)
+		e.KnownCount = countLegendre(c)
+		e.Schedule = sched
+		if _, err := e.run(nil); err != nil || !e.isComplete() {
+			t.Fatalf("%s: walk incomplete (%v)", sched, err)
+		}
+		cov := e.coverage()
+		if cov.Schedule != sched || cov.Cells != 101*101 || cov.Classified == 0 {
+			t.Fatalf("%s: coverage %+v", sched, cov)
+		}
+		for i := 1; i < len(cov.Milestones); i++ {
+			if cov.Milestones[i].Lines < cov.Milestones[i-1].Lines {
+				t.Fatalf("%s: milestones out of order %+v", sched, cov.Milestones)
+			}
+		}
+	}
+	if _, err := runCurve(curveSpec{P: "101", A: "2", B: "3"}, runOpts{Schedule: "greedy"}); err == nil {
+		t.Fatal("-schedule greedy without -grid accepted")
+	}
+}
+
+func TestGridUnknownOn(t *testing.T) {
+	g := newGrid(11)
+	L := Line{M: bi(2), C: bi(3)}
+	if n := g.unknownOn(L); n != 11 {
+		t.Fatalf("fresh line has %d unknown cells, want 11", n)
+	}
+	g.markExcl(0, 3)
+	g.markFound(1, 5)
+	g.markFound(1, 5)
+	if n := g.unknownOn(L); n != 9 || g.classified != 2 {
+		t.Fatalf("unknown %d (want 9), classified %d (want 2)", n, g.classified)
+	}
+}
+
+func TestStopAtCoverage(t *testing.T) {
+	out, err := runCurve(curveSpec{P: "211", A: "2", B: "3"}, runOpts{UseGrid: true, CountFirst: true, StopAt: 0.5, RandSeed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cov := out.Coverage
+	if cov == nil || cov.StoppedAt != 0.5 || cov.Fraction < 0.5 || cov.Fraction > 0.6 || out.Complete {
+		t.Fatalf("coverage %+v, complete %v", cov, out.Complete)
+	}
+	if cov.Remaining != cov.Cells-cov.Classified {
+		t.Fatalf("remaining %d != %d - %d", cov.Remaining, cov.Cells, cov.Classified)
+	}
+	if _, err := runCurve(curveSpec{P: "211", A: "2", B: "3"}, runOpts{StopAt: 0.5}); err == nil {
+		t.Fatal("-stop_at_coverage without -grid accepted")
+	}
+}
+
+func TestBudgetTruncates(t *testing.T) {
+	spec := curveSpec{P: "211", A: "2", B: "3"}
+	// any Go process is past 1KB, so the walk stops at its first check
+	out, err := runCurve(spec, runOpts{UseGrid: true, CountFirst: true, MaxMem: "1KB", RandSeed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Truncated || out.Complete || out.Coverage == nil || len(out.Notes) == 0 {
+		t.Fatalf("truncated %v, complete %v, coverage %+v, notes %q", out.Truncated, out.Complete, out.Coverage, out.Notes)
+	}
+	out, err = runCurve(spec, runOpts{UseGrid: true, CountFirst: true, MaxSeconds: 60, MaxMem: "1GB", RandSeed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Truncated || !out.Complete {
+		t.Fatalf("a generous budget: truncated %v, complete %v", out.Truncated, out.Complete)
+	}
+	if _, err := runCurve(spec, runOpts{MaxSeconds: -1}); err == nil {
+		t.Fatal("negative -max_seconds accepted")
+	}
+}
+
+func TestStatsCSVAndSummary(t *testing.T) {
+	path := t.TempDir() + "/stats.csv"
+	out, err := runCurve(curveSpec{P: "101", A: "2", B: "3"}, runOpts{UseGrid: true, CountFirst: true, StatsPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := out.Stats
+	if st == nil || st.Lines != out.Lines || st.NewExclusions == 0 || st.DuplicateHitRate <= 0 || st.DuplicateHitRate >= 1 {
+		t.Fatalf("stats summary %+v (lines %d)", st, out.Lines)
+	}
+	// a line records its points before excluding the rest, so no found
+	// cell was excluded first
+	if out.Coverage.Classified != st.NewExclusions+len(out.Found) {
+		t.Fatalf("classified %d != exclusions %d + found %d", out.Coverage.Classified, st.NewExclusions, len(out.Found))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(rows) != st.Lines+1 || !strings.HasPrefix(rows[0], "line,kind,") {
+		t.Fatalf("stats CSV has %d rows for %d lines; header %q", len(rows), st.Lines, rows[0])
+	}
+}
+
+func TestSparseBitsetMatchesDense(t *testing.T) {
+	const n = 5 * chunkSize
+	s, d := newSparseBitset(n), newBitset(n)
+	r := mrand.New(mrand.NewSource(9))
+	// chunk 0 stays an array, chunk 1 becomes a bitmap, chunk 2 fills up
+	for i := 0; i < 100; i++ {
+		j := r.Intn(chunkSize)
+		s.set(j)
+		d.set(j)
+	}
+	for i := 0; i < 3*arrayMax; i++ {
+		j := chunkSize + r.Intn(chunkSize)
+		s.set(j)
+		d.set(j)
+	}
+	for j := 2 * chunkSize; j < 3*chunkSize; j++ {
+		s.set(j)
+		d.set(j)
+	}
+	want := 0
+	for i := 0; i < n; i++ {
+		if s.get(i) != d.get(i) {
+			t.Fatalf("bit %d: sparse %v, dense %v", i, s.get(i), d.get(i))
+		}
+		if d.get(i) {
+			want++
+		}
+	}
+	if got := s.popcount(); got != want {
+		t.Fatalf("popcount %d, want %d", got, want)
+	}
+	if s.chunks[0].bm != nil || s.chunks[1].bm == nil || s.chunks[2] != fullChunk {
+		t.Fatal("containers did not take the expected shapes")
+	}
+	if s.memBytes() >= d.memBytes() {
+		t.Fatalf("sparse %d bytes, dense %d", s.memBytes(), d.memBytes())
+	}
+}
+
+func TestSparseGridWalkMatchesDense(t *testing.T) {
+	spec := curveSpec{P: "211", A: "2", B: "3"}
+	var covs []*CoverageOut
+	for _, store := range []string{"dense", "sparse"} {
+		out, err := runCurve(spec, runOpts{UseGrid: true, CountFirst: true, RandSeed: 3, GridStore: store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		covs = append(covs, out.Coverage)
+	}
+	if covs[0].Classified != covs[1].Classified || len(covs[0].Milestones) != len(covs[1].Milestones) {
+		t.Fatalf("dense %+v vs sparse %+v", covs[0], covs[1])
+	}
+	if _, err := runCurve(spec, runOpts{UseGrid: true, GridStore: "sparse", GridMem: "1KB"}); err == nil {
+		t.Fatal("grid outgrew -grid_mem without an error")
+	}
+}
+
+func TestClassifyImplicitMatchesGrid(t *testing.T) {
+	out, err := runCurve(curveSpec{P: "101", A: "2", B: "3"}, runOpts{MaxLines: 40, RandSeed: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Implicit == nil || out.Implicit.Slopes == 0 || out.Implicit.CandidateX <= 0 {
+		t.Fatalf("implicit block %+v", out.Implicit)
+	}
+
+	// the same partial walk with and without a grid must classify alike
+	c := Curve{P: big.NewInt(101), A: big.NewInt(2), B: big.NewInt(3)}
+	walk := func(useGrid bool) *Engine {
+		e := NewEngine(c, useGrid, 40, false)
+		e.Rand = mrand.New(mrand.NewSource(5))
+		if _, err := e.run(nil); err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	ie, ge := walk(false), walk(true)
+	counts := map[Class]int{}
+	for x := int64(0); x < 101; x++ {
+		for y := int64(0); y < 101; y++ {
+			X, Y := big.NewInt(x), big.NewInt(y)
+			k := ie.Classify(X, Y)
+			if g := ge.Classify(X, Y); k != g {
+				t.Fatalf("(%d,%d): implicit %v, grid %v", x, y, k, g)
+			}
+			counts[k]++
+		}
+	}
+	if counts[ClassFound] == 0 || counts[ClassExcluded] == 0 || counts[ClassUnknown] == 0 {
+		t.Fatalf("class counts %v", counts)
+	}
+}
+
+func TestLinesOut(t *testing.T) {
+	path := t.TempDir() + "/lines.ndjson"
+	out, err := runCurve(curveSpec{P: "101", A: "2", B: "3"}, runOpts{UseGrid: true, CountFirst: true, LinesOut: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p := big.NewInt(101)
+	c := Curve{P: p, A: big.NewInt(2), B: big.NewInt(3)}
+	dec := json.NewDecoder(f)
+	n, excl := 0, 0
+	for dec.More() {
+		var r LineRecord
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if r.Line != n || r.NewExcl == nil || len(r.Intersect) == 0 || len(r.Intersect) > 3 {
+			t.Fatalf("record %d: %+v", n, r)
+		}
+		excl += *r.NewExcl
+		for _, s := range r.Intersect {
+			if s.Inf {
+				if r.Kind != "vertical" {
+					t.Fatalf("line %d (%s) meets O", n, r.Kind)
+				}
+				continue
+			}
+			x, _ := new(big.Int).SetString(s.X, 10)
+			y, _ := new(big.Int).SetString(s.Y, 10)
+			if !c.On(Point{X: x, Y: y}) {
+				t.Fatalf("line %d: (%s, %s) not on E", n, s.X, s.Y)
+			}
+			if r.Kind == "vertical" {
+				if s.X != r.X {
+					t.Fatalf("line %d: (%s, %s) not on x = %s", n, s.X, s.Y, r.X)
+				}
+				continue
+			}
+			m, _ := new(big.Int).SetString(r.M, 10)
+			b, _ := new(big.Int).SetString(r.C, 10)
+			if ec.Mod(m.Mul(m, x).Add(m, b), p).Cmp(y) != 0 {
+				t.Fatalf("line %d: (%s, %s) not on y = %s x + %s", n, s.X, s.Y, r.M, r.C)
+			}
+		}
+	}
+	if n != out.Stats.Lines || excl != out.Stats.NewExclusions {
+		t.Fatalf("%d records / %d exclusions, stats say %d / %d", n, excl, out.Stats.Lines, out.Stats.NewExclusions)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	path := t.TempDir() + "/events.ndjson"
+	out, err := runCurve(curveSpec{P: "101", A: "2", B: "3"}, runOpts{UseGrid: true, CountFirst: true, Events: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	counts := map[string]int{}
+	var last Event
+	for dec.More() {
+		var ev Event
+		if err := dec.Decode(&ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.T < last.T || ev.Lines < last.Lines || ev.Found < last.Found {
+			t.Fatalf("%+v after %+v", ev, last)
+		}
+		if counts["completed"] > 0 || (len(counts) == 0) != (ev.Event == "seed") {
+			t.Fatalf("%s event out of place (after %v)", ev.Event, counts)
+		}
+		if ev.Event == "line" && (ev.Kind == "vertical") != (ev.X != "" && ev.M == "") {
+			t.Fatalf("line event %+v: equation does not match its kind", ev)
+		}
+		counts[ev.Event]++
+		last = ev
+	}
+	affine := 0
+	for _, pt := range out.Found {
+		if !pt.Inf {
+			affine++
+		}
+	}
+	if counts["line"] != out.Stats.Lines || counts["point"] != len(out.Found) || last.Found != affine {
+		t.Fatalf("events %v, last %+v; stats %+v, %d found", counts, last, out.Stats, len(out.Found))
+	}
+	if last.Event != "completed" || last.Complete == nil || *last.Complete != out.Complete {
+		t.Fatalf("last event %+v, complete %v", last, out.Complete)
+	}
+}
+
+func TestDiscoveryGraph(t *testing.T) {
+	dir := t.TempDir()
+	spec := curveSpec{P: "101", A: "2", B: "3"}
+	out, err := runCurve(spec, runOpts{CountFirst: true, RandSeed: 4, GraphPath: dir + "/g.graphml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dir + "/g.graphml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g graphML
+	if err := xml.Unmarshal(data, &g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Graph.Nodes) != len(out.Found) {
+		t.Fatalf("%d nodes for %d points", len(g.Graph.Nodes), len(out.Found))
+	}
+	// every non-seed is reached by an edge from an earlier node
+	pos := map[string]int{}
+	for i, n := range g.Graph.Nodes {
+		pos[n.ID] = i
+	}
+	reached := map[string]bool{}
+	for _, ed := range g.Graph.Edges {
+		if pos[ed.Source] >= pos[ed.Target] {
+			t.Fatalf("edge %s -> %s goes backwards", ed.Source, ed.Target)
+		}
+		reached[ed.Target] = true
+	}
+	seeds := 0
+	for _, n := range g.Graph.Nodes {
+		seed := n.Data[len(n.Data)-1].Value == "true"
+		if seed {
+			seeds++
+		}
+		if seed == reached[n.ID] {
+			t.Fatalf("node %s: seed %v, reached %v", n.ID, seed, reached[n.ID])
+		}
+	}
+	if seeds == 0 {
+		t.Fatal("no seed nodes")
+	}
+
+	if _, err := runCurve(spec, runOpts{RandSeed: 4, MaxLines: 20, GraphPath: dir + "/g.dot"}); err != nil {
+		t.Fatal(err)
+	}
+	dot, err := os.ReadFile(dir + "/g.dot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(dot); !strings.HasPrefix(s, "digraph discovery {") || !strings.Contains(s, "->") || !strings.HasSuffix(s, "}\n") {
+		t.Fatalf("DOT output:\n%s", s)
+	}
+}
+
+func TestNoReseedReachesSeedSubgroup(t *testing.T) {
+	for seed := int64(1); seed <= 6; seed++ {
+		out, err := runCurve(curveSpec{P: "101", A: "2", B: "3"}, runOpts{CountFirst: true, NoReseed: true, RandSeed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := out.Reach
+		if r == nil || !r.Closed || r.Size == "" || r.Size != r.Order {
+			t.Fatalf("seed %d: reach %+v", seed, r)
+		}
+		size, _ := new(big.Int).SetString(r.Size, 10)
+		idx, _ := new(big.Int).SetString(r.Index, 10)
+		if size.Mul(size, idx).String() != out.KnownCount || len(out.Found) != r.Reached {
+			t.Fatalf("seed %d: |<P>| %s · index %s != #E %s", seed, r.Size, r.Index, out.KnownCount)
+		}
+	}
+	out, err := runCurve(curveSpec{P: "101", A: "2", B: "3"}, runOpts{NoReseed: true, RandSeed: 1, MaxLines: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := out.Reach; r == nil || r.Closed || r.Size != "" {
+		t.Fatalf("capped walk: reach %+v", r)
+	}
+}
+
+func TestCrossCheckAgainstEcscan(t *testing.T) {
+	out, err := runCurve(curveSpec{P: "1009", A: "2", B: "3"}, runOpts{CrossCheck: true, RandSeed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := out.CrossCheck; c == nil || !c.Match || c.Walked != c.Scanned || c.Scanned != 1067 {
+		t.Fatalf("complete walk: cross-check %+v", c)
+	}
+
+	// A walk that found only two points disagrees, and says where.
+	e := NewEngine(mustCurve(t, 101, 2, 3), false, 0, false)
+	e.addFound(pt(3, 6))
+	bogus := pt(200, 1) // not even reduced: the scan never produces it
+	e.found[e.pointKey(bogus)] = bogus
+	c, err := e.crossCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Match || len(c.OnlyWalk) != 1 || c.OnlyWalk[0].X != "200" || len(c.OnlyScan) != crossCheckList {
+		t.Fatalf("partial walk: cross-check %+v", c)
+	}
+
+	if _, err := runCurve(curveSpec{P: "101", A: "2", B: "3", Form: "montgomery"}, runOpts{CrossCheck: true}); err == nil {
+		t.Fatal("-crosscheck accepted a montgomery curve")
+	}
+}
+
+func TestSEC1Annotation(t *testing.T) {
+	out, err := runCurve(curveSpec{P: "101", A: "2", B: "3"}, runOpts{CountFirst: true, SEC1: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	P, A, B := bi(101), bi(2), bi(3)
+	for _, pt := range out.Found {
+		raw, err := hex.DecodeString(pt.SEC1)
+		if err != nil {
+			t.Fatalf("%+v: %v", pt, err)
+		}
+		x, y, err := encoding.Unmarshal(P, A, B, raw)
+		switch {
+		case err != nil:
+			t.Fatalf("%+v: %v", pt, err)
+		case pt.Inf != (x == nil):
+			t.Fatalf("%+v decodes to (%v, %v)", pt, x, y)
+		case x != nil && (x.String() != pt.X || y.String() != pt.Y):
+			t.Fatalf("%+v decodes to (%v, %v)", pt, x, y)
+		}
+	}
+	if _, err := runCurve(curveSpec{P: "101", A: "3", B: "1", Form: "montgomery"}, runOpts{SEC1: true}); err == nil {
+		t.Fatal("-sec1 accepted for a montgomery curve")
+	}
+}
+
+func TestNamedCurve(t *testing.T) {
+	out, err := runCurve(curveSpec{Curve: "curve25519"}, runOpts{MaxLines: 2, RandSeed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Form != "montgomery" || out.A != "486662" || len(out.Found) == 0 {
+		t.Fatalf("curve25519: form %q, A %s, %d points", out.Form, out.A, len(out.Found))
+	}
+	out, err = runCurve(curveSpec{Curve: "curve25519-wei"}, runOpts{MaxLines: 2, RandSeed: 1})
+	if err != nil || out.Form != "" || out.WA != "" {
+		t.Fatalf("curve25519-wei: form %q, %v", out.Form, err)
+	}
+	if _, err := runCurve(curveSpec{Curve: "p384"}, runOpts{MaxLines: 1}); err == nil {
+		t.Fatal("p384 walked past the 256-bit limit")
+	}
+}
+
+func TestPrimeChecks(t *testing.T) {
+	spec := curveSpec{P: "1001", A: "1", B: "1"}
+	out, err := runCurve(spec, runOpts{MaxLines: 1, FactorP: true})
+	if err != nil || strings.Join(out.PFactors, " ") != "7 11 13" {
+		t.Fatalf("factors %v, %v", out.PFactors, err)
+	}
+	if _, err := runCurve(spec, runOpts{MaxLines: 1, RequirePrime: true}); err == nil || !strings.Contains(err.Error(), "composite") {
+		t.Fatalf("-require_prime on 1001: %v", err)
+	}
+	out, err = runCurve(curveSpec{P: "2^89-1", A: "2", B: "3"}, runOpts{MaxLines: 1, ProvePrime: true, RequirePrime: true})
+	if err != nil || out.PrimeProof == nil || out.PrimeProof.Verify() != nil {
+		t.Fatalf("-prove_prime on 2^89-1: %+v, %v", out.PrimeProof, err)
+	}
+}
+
+func TestRingMatchesBruteForce(t *testing.T) {
+	// 245 = 5 · 7^2: one plain factor, one lifted by Hensel's lemma
+	const n = 245
+	out, err := runCurve(curveSpec{P: "245", A: "1", B: "1"}, runOpts{Ring: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			if (y*y-(x*x*x+x+1))%n == 0 {
+				want = append(want, fmt.Sprint(x, y))
+			}
+		}
+	}
+	var got []string
+	for _, pt := range out.Found {
+		if !pt.Inf {
+			got = append(got, pt.X+" "+pt.Y)
+		}
+	}
+	if !out.Complete || !out.Ring.Listed || out.Ring.Affine != fmt.Sprint(len(want)) || strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("ring: %d points (affine %s), brute force %d", len(got), out.Ring.Affine, len(want))
+	}
+	// #E(Z/245Z) = #E(F_5) · 7 · #E(F_7)
+	if out.KnownCount != "315" {
+		t.Fatalf("pointCount %s, want 315", out.KnownCount)
+	}
+
+	if _, err := runCurve(curveSpec{P: "77", A: "2", B: "3"}, runOpts{Ring: true}); err == nil || !strings.Contains(err.Error(), "mod 11") {
+		t.Fatalf("singular mod 11: %v", err)
+	}
+	if _, err := runCurve(curveSpec{P: "3 * 7", A: "1", B: "1"}, runOpts{Ring: true}); err == nil {
+		t.Fatal("factor 3 accepted")
+	}
+}
+
+func TestInversionFailuresYieldFactors(t *testing.T) {
+	for _, schedule := range []string{"fifo", "greedy"} {
+		out, err := runCurve(curveSpec{P: "1001", A: "1", B: "1"}, runOpts{MaxLines: 200, RandSeed: 3, Schedule: schedule, UseGrid: schedule == "greedy"})
+		if err != nil {
+			t.Fatalf("%s: %v", schedule, err)
+		}
+		if len(out.FactorsFound) == 0 {
+			t.Fatalf("%s: no factor of 1001 found in %d lines", schedule, out.Lines)
+		}
+		for _, f := range out.FactorsFound {
+			g, _ := new(big.Int).SetString(f.Factor, 10)
+			h, _ := new(big.Int).SetString(f.Cofactor, 10)
+			if g.Cmp(bi(1)) <= 0 || new(big.Int).Mul(g, h).Cmp(bi(1001)) != 0 || f.Hits < 1 {
+				t.Fatalf("%s: bad factor %+v", schedule, f)
+			}
+		}
+	}
+}
+
+func TestIsogenies(t *testing.T) {
+	// E(F_101) ⊇ E[5] for y^2 = x^3 + 2x + 26: six 5-isogenies, one 2-isogeny
+	out, err := runCurve(curveSpec{P: "101", A: "2", B: "26"}, runOpts{Isogenies: []int{2, 3, 5}, RandSeed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byL := make(map[int]int)
+	for _, iso := range out.Isogenies {
+		byL[iso.L]++
+		A, _ := new(big.Int).SetString(iso.A, 10)
+		B, _ := new(big.Int).SetString(iso.B, 10)
+		// isogenous curves over F_p have the same number of points
+		if n := countLegendre(Curve{P: bi(101), A: A, B: B}); n.Cmp(bi(100)) != 0 {
+			t.Fatalf("%d-isogenous curve y^2 = x^3 + %s x + %s has %s points, want 100", iso.L, iso.A, iso.B, n)
+		}
+	}
+	if byL[2] != 1 || byL[3] != 0 || byL[5] != 6 {
+		t.Fatalf("isogenies by degree %v, want 2:1 5:6", byL)
+	}
+	if _, err := parseIsogenyDegrees("2,4"); err == nil {
+		t.Fatal("-isogenies 4 accepted")
+	}
+}
+
+func TestExtFieldArithmetic(t *testing.T) {
+	for _, tc := range []struct{ p, k int64 }{{11, 2}, {7, 3}} {
+		F, err := newExtField(bi(tc.p), int(tc.k))
+		if err != nil {
+			t.Fatal(err)
+		}
+		squares := 0
+		for a := int64(1); a < F.q.Int64(); a++ {
+			x := bi(a)
+			inv, err := F.inv(x)
+			if err != nil || F.mul(x, inv).Cmp(bi(1)) != 0 {
+				t.Fatalf("F_%d^%d: %s · %v ≠ 1 (%v)", tc.p, tc.k, F.format(x), inv, err)
+			}
+			rs := F.sqrts(x)
+			for _, r := range rs {
+				if F.mul(r, r).Cmp(x) != 0 {
+					t.Fatalf("F_%d^%d: %s^2 ≠ %s", tc.p, tc.k, F.format(r), F.format(x))
+				}
+			}
+			if len(rs) > 0 {
+				squares++
+			}
+		}
+		if want := int((F.q.Int64() - 1) / 2); squares != want {
+			t.Fatalf("F_%d^%d: %d nonzero squares, want %d", tc.p, tc.k, squares, want)
+		}
+		if v, err := F.parse("(3,-1)"); err != nil || F.format(v) != "(3,"+fmt.Sprint(tc.p-1)+strings.Repeat(",0", int(tc.k)-2)+")" {
+			t.Fatalf("parse (3,-1): %v %v", v, err)
+		}
+	}
+}
+
+func TestExtensionWalk(t *testing.T) {
+	for _, tc := range []struct {
+		p    int64
+		A, B string
+		k    int
+		N    int64
+	}{
+		{11, "1", "1", 2, 140}, // #E(F_11) = 14, so #E(F_121) = 122 - (t^2 - 22) with t = -2
+		{7, "1,1", "3", 3, 0},  // A outside F_7: counted point by point
+	} {
+		out, err := runCurve(curveSpec{P: fmt.Sprint(tc.p), A: tc.A, B: tc.B, K: tc.k}, runOpts{CountFirst: true, RandSeed: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Extension == nil || out.Extension.K != tc.k || !out.Complete {
+			t.Fatalf("F_%d^%d: extension %+v, complete %v", tc.p, tc.k, out.Extension, out.Complete)
+		}
+		if tc.N != 0 && out.KnownCount != fmt.Sprint(tc.N) {
+			t.Fatalf("F_%d^%d: #E = %s, want %d", tc.p, tc.k, out.KnownCount, tc.N)
+		}
+		F, _ := newExtField(bi(tc.p), tc.k)
+		A, _ := F.parse(tc.A)
+		B, _ := F.parse(tc.B)
+		m := extModel{F: F, A: A, B: B}
+		affine := 0
+		for _, pt := range out.Found {
+			if pt.Inf {
+				continue
+			}
+			x, _ := F.parse(pt.X)
+			y, _ := F.parse(pt.Y)
+			if !m.on(Point{X: x, Y: y}) {
+				t.Fatalf("F_%d^%d: %s, %s is not on the curve", tc.p, tc.k, pt.X, pt.Y)
+			}
+			affine++
+		}
+		if fmt.Sprint(affine+1) != out.KnownCount {
+			t.Fatalf("F_%d^%d: %d affine points, #E = %s", tc.p, tc.k, affine, out.KnownCount)
+		}
+	}
+	if _, err := runCurve(curveSpec{P: "11", A: "1", B: "1", K: 2}, runOpts{UseGrid: true}); err == nil {
+		t.Fatal("-grid accepted with -k 2")
+	}
+	if _, err := runCurve(curveSpec{P: "11", A: "1", B: "1", K: 4}, runOpts{}); err == nil {
+		t.Fatal("-k 4 accepted")
+	}
+}
+
+func TestREPL(t *testing.T) {
+	c := mustCurve(t, 11, 0, 1)
+	e := NewEngine(c, true, 0, true)
+	e.KnownCount = countLegendre(c)
+	in := strings.NewReader("seed 0 1\nstep 2\ngrid\nbogus\nseed 5 5\nrun\nstats\njson\nquit\nstep\n")
+	var out strings.Builder
+	lines, err := e.repl(in, &out, nil, c.A, c.B)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"seed (0, 1); 1 points",
+		"1 new points; ", // step 2: the tangent at (0, 1) finds (0, 10)
+		"10 |",           // the grid's top row, y = 10
+		"x = 0..10",
+		`unknown command "bogus"`,
+		"(5, 5) is not on the curve",
+		"(complete)",
+		"points found: 11 affine of 11 (complete: true)",
+		`"complete": true`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+	if !e.isComplete() || lines != len(e.linesDone) || strings.Count(got, "> ") != 9 {
+		t.Fatalf("complete %v, %d lines of %d, output\n%s", e.isComplete(), lines, len(e.linesDone), got)
+	}
+	// the seed's row: (0, 1) found; the rest of the row is on its tangent y = 1
+	if !strings.Contains(got, " 1 |#") {
+		t.Errorf("row y = 1 does not start with the seed:\n%s", got)
+	}
+}
+
+func TestPointsIn(t *testing.T) {
+	dir := t.TempDir()
+	spec := curveSpec{P: "101", A: "2", B: "3"}
+	full, err := runCurve(spec, runOpts{CountFirst: true, RandSeed: 1})
+	if err != nil || !full.Complete {
+		t.Fatalf("%v, complete %v", err, full.Complete)
+	}
+	// half of the points in each form ectorus and ecscan write
+	var text, ndjson strings.Builder
+	text.WriteString("# ecscan v=2\n# p=101\n# A=2\n# B=3\n")
+	for i, pt := range full.Found[:len(full.Found)/2] {
+		if i%2 == 0 {
+			fmt.Fprintf(&text, "%s %s\n", pt.X, pt.Y)
+		}
+		fmt.Fprintf(&ndjson, "{\"x\":%s,\"y\":%s}\n", pt.X, pt.Y)
+	}
+	half := full
+	half.Found = full.Found[:len(full.Found)/2]
+	res, _ := json.MarshalIndent(half, "", "  ")
+	files := map[string]string{
+		"dump.txt":      text.String(),
+		"dump.ndjson":   `{"type":"meta","version":2,"p":"101","A":"2","B":"3"}` + "\n" + ndjson.String(),
+		"result.json":   string(res),
+		"result.ndjson": `{"x":"` + full.Found[0].X + `","y":"` + full.Found[0].Y + `","inf":false}` + "\n" + `{"result":{"p":"101","found":null}}`,
+	}
+	for name, body := range files {
+		path := dir + "/" + name
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		out, err := runCurve(spec, runOpts{CountFirst: true, RandSeed: 2, PointsIn: path})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !out.Complete || len(out.Found) != len(full.Found) || out.PointsIn == nil || out.PointsIn.Points == 0 {
+			t.Fatalf("%s: complete %v, %d points of %d, %+v", name, out.Complete, len(out.Found), len(full.Found), out.PointsIn)
+		}
+	}
+
+	bad := dir + "/bad.txt"
+	os.WriteFile(bad, []byte("1 1\n"), 0o644)
+	if _, err := runCurve(spec, runOpts{PointsIn: bad}); err == nil || !strings.Contains(err.Error(), "not on the curve") {
+		t.Fatalf("off-curve point: %v", err)
+	}
+	other := dir + "/other.txt"
+	os.WriteFile(other, []byte("# p=103\n1 1\n"), 0o644)
+	if _, err := runCurve(spec, runOpts{PointsIn: other}); err == nil {
+		t.Fatal("a dump for another p was accepted")
+	}
+}
+
+func TestInfStyle(t *testing.T) {
+	defer func() { infStyle = infFlag }()
+	dir := t.TempDir()
+	spec := curveSpec{P: "101", A: "2", B: "3"}
+	full, err := runCurve(spec, runOpts{CountFirst: true, RandSeed: 1})
+	if err != nil || !full.Complete {
+		t.Fatalf("%v, complete %v", err, full.Complete)
+	}
+	if !full.Found[len(full.Found)-1].Inf {
+		full.Found = append(full.Found, Pt{Inf: true})
+	}
+	for style, inf := range map[string]string{infFlag: `{"inf":true}`, infNull: "null", infString: `"inf"`} {
+		infStyle = style
+		b, err := json.Marshal(full)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), ","+inf+"]") || strings.Contains(string(b), `"inf":false`) != (style == infFlag) {
+			t.Fatalf("%s: O is not %s in %s", style, inf, b)
+		}
+		// the result and a stream of its points read back with O counted
+		var back Out
+		if err := json.Unmarshal(b, &back); err != nil || len(back.Found) != len(full.Found) || !back.Found[len(back.Found)-1].Inf {
+			t.Fatalf("%s: read back %d points, %v", style, len(back.Found), err)
+		}
+		var stream strings.Builder
+		for i := len(full.Found) - 1; i >= 0; i-- { // O first
+			pb, _ := json.Marshal(full.Found[i])
+			stream.Write(append(pb, '\n'))
+		}
+		path := dir + "/" + style + ".ndjson"
+		if err := os.WriteFile(path, []byte(stream.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		out, err := runCurve(spec, runOpts{CountFirst: true, RandSeed: 2, PointsIn: path})
+		if err != nil || out.PointsIn.Points != len(full.Found) {
+			t.Fatalf("%s: %v, pointsIn %+v, want %d points", style, err, out.PointsIn, len(full.Found))
+		}
+	}
+}
+
+func TestGridOutIn(t *testing.T) {
+	dir := t.TempDir()
+	spec := curveSpec{P: "211", A: "2", B: "3"}
+	for _, store := range []string{"dense", "sparse"} {
+		part, full := dir+"/part-"+store+".grid", dir+"/full-"+store+".grid"
+		a, err := runCurve(spec, runOpts{UseGrid: true, GridStore: store, CountFirst: true, NoReseed: true, MaxLines: 30, RandSeed: 3, GridOut: part})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// resume: the saved grid's points and exclusions carry over
+		b, err := runCurve(spec, runOpts{UseGrid: true, GridStore: store, CountFirst: true, RandSeed: 4, GridIn: part, GridOut: full})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !b.Complete || b.GridIn == nil || b.GridIn.Found != len(a.Found) || b.GridIn.Lines != a.Lines {
+			t.Fatalf("%s: complete %v, gridIn %+v, first walk %d points %d lines", store, b.Complete, b.GridIn, len(a.Found), a.Lines)
+		}
+		if b.Coverage.Classified < a.Coverage.Classified {
+			t.Fatalf("%s: resumed at %d cells, saved %d", store, b.Coverage.Classified, a.Coverage.Classified)
+		}
+		// reloading the finished grid reproduces its coverage exactly
+		e := NewEngine(mustCurve(t, 211, 2, 3), false, 0, false)
+		e.UseGrid = true
+		if e.G, err = newGridStore(211, store, 1<<30); err != nil {
+			t.Fatal(err)
+		}
+		gi, err := e.loadGrid(full)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.G.classified != b.Coverage.Classified || gi.Found != len(b.Found) {
+			t.Fatalf("%s: reloaded %d cells, %d points; wrote %d, %d", store, e.G.classified, gi.Found, b.Coverage.Classified, len(b.Found))
+		}
+	}
+	if _, err := runCurve(curveSpec{P: "211", A: "2", B: "5"}, runOpts{UseGrid: true, GridIn: dir + "/full-dense.grid"}); err == nil {
+		t.Fatal("a grid of another curve was accepted")
+	}
+	if _, err := runCurve(spec, runOpts{GridOut: dir + "/x.grid"}); err == nil {
+		t.Fatal("-grid_out without -grid accepted")
+	}
+}
+
+func TestLineKinds(t *testing.T) {
+	spec := curveSpec{P: "211", A: "2", B: "3"}
+	for _, kinds := range []string{"tangent", "secant"} {
+		out, err := runCurve(spec, runOpts{UseGrid: true, CountFirst: true, RandSeed: 3, LineKinds: kinds})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := out.Stats
+		if !out.Complete || s.LineKinds != kinds || s.ByKind[kinds] == nil {
+			t.Fatalf("-lines %s: complete %v, stats %+v", kinds, out.Complete, s)
+		}
+		other := map[string]string{"tangent": "secant", "secant": "tangent"}[kinds]
+		if s.ByKind[other] != nil {
+			t.Fatalf("-lines %s drew %d %s lines", kinds, s.ByKind[other].Lines, other)
+		}
+		n := 0
+		for _, k := range s.ByKind {
+			n += k.Lines
+		}
+		if n != s.Lines {
+			t.Fatalf("-lines %s: %d lines by kind, %d in all", kinds, n, s.Lines)
+		}
+	}
+	if _, err := runCurve(spec, runOpts{LineKinds: "chord"}); err == nil {
+		t.Fatal("-lines chord accepted")
+	}
+}
+
+func TestAutoVerticals(t *testing.T) {
+	c := mustCurve(t, 211, 2, 3)
+	e := NewEngine(c, true, 0, false)
+	e.AutoVerticals = true
+	e.Rand = mrand.New(mrand.NewSource(5))
+	if _, err := e.run(nil); err != nil {
+		t.Fatal(err)
+	}
+	// every found point's column is classified from top to bottom
+	for _, P := range e.order {
+		x := int(P.X.Int64())
+		for y := range 211 {
+			if !e.G.isFound(x, y) && !e.G.isExcluded(x, y) {
+				t.Fatalf("(%d, %d) unknown in the column of (%s, %s)", x, y, P.X, P.Y)
+			}
+		}
+	}
+	k := e.stats.summary().ByKind["auto-vertical"]
+	if k == nil || k.Lines == 0 || k.NewExclusions == 0 {
+		t.Fatalf("auto-vertical stats %+v", k)
+	}
+}
+
+func TestSweepLines(t *testing.T) {
+	spec := curveSpec{P: "101", A: "2", B: "3"}
+	out, err := runCurve(spec, runOpts{UseGrid: true, CountFirst: true, SweepLines: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := out.Sweep
+	if s == nil || !s.Complete || s.Lines != 101*101+101 || !out.Complete || out.Coverage.Remaining != 0 {
+		t.Fatalf("sweep %+v, complete %v, coverage %+v", s, out.Complete, out.Coverage)
+	}
+	// each affine point lies on p + 1 lines
+	n, inc := 0, 0
+	for k, lines := range s.Meets {
+		n += lines
+		inc += k * lines
+	}
+	if n != s.Lines || inc != len(out.Found)*102 {
+		t.Fatalf("meets %v: %d lines, %d incidences for %d points", s.Meets, n, inc, len(out.Found))
+	}
+
+	out, err = runCurve(spec, runOpts{SweepLines: true, MaxLines: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Sweep.Complete || out.Sweep.Lines != 150 || out.Lines != 150 {
+		t.Fatalf("-max_lines 150: %+v, %d lines", out.Sweep, out.Lines)
+	}
+	if _, err := runCurve(spec, runOpts{SweepLines: true, Verticals: true}); err == nil {
+		t.Fatal("-sweep_lines -verticals accepted")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	qs, err := parseQueries("1,39; 0,0 -1,2^3")
+	if err != nil || len(qs) != 3 || qs[2][0].Int64() != -1 || qs[2][1].Int64() != 8 {
+		t.Fatalf("%v %v", qs, err)
+	}
+	if _, err := parseQueries("1 2"); err == nil {
+		t.Fatal("a point without a comma accepted")
+	}
+	for _, grid := range []bool{false, true} {
+		out, err := runCurve(curveSpec{P: "101", A: "2", B: "3"}, runOpts{UseGrid: grid, CountFirst: true, RandSeed: 1, Queries: qs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []QueryOut{{"1", "39", ClassFound, true}, {"0", "0", ClassExcluded, false}, {"100", "8", ClassExcluded, false}}
+		for i, q := range out.Queries {
+			if q != want[i] {
+				t.Fatalf("grid %v: query %d = %+v, want %+v", grid, i, q, want[i])
+			}
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	for _, spec := range []curveSpec{{P: "101", A: "2", B: "3"}, {P: "101", A: "3", B: "1", Form: "montgomery"}} {
+		for _, grid := range []bool{false, true} {
+			out, err := runCurve(spec, runOpts{UseGrid: grid, CountFirst: true, RandSeed: 1, Audit: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a := out.Audit; a == nil || !a.Pass || a.Checked != a.Found || a.Unknown != 0 {
+				t.Fatalf("%s grid %v: audit %+v", spec.Form, grid, a)
+			}
+		}
+	}
+	out, err := runCurve(curveSpec{P: "101", A: "2", B: "3"}, runOpts{UseGrid: true, MaxLines: 3, RandSeed: 1, Audit: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := out.Audit; !a.Pass || a.Unknown == 0 || a.Found+a.Unknown != a.Checked {
+		t.Fatalf("partial walk: audit %+v", a)
+	}
+
+	// A curve point wrongly excluded, a found point excluded too, a found
+	// point off the curve and a stray found cell each fail it.
+	e := NewEngine(mustCurve(t, 101, 2, 3), false, 0, false)
+	e.G = newGrid(101)
+	e.addFound(pt(3, 6))
+	e.G.markExcl(1, 39)
+	e.addFound(pt(3, 95))
+	e.G.markExcl(3, 95)
+	bogus := pt(5, 5)
+	e.found[e.pointKey(bogus)] = bogus
+	e.G.markFound(7, 7)
+	a, err := e.audit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Pass || a.Failures != 4 {
+		t.Fatalf("corrupted walk: audit %+v", a)
+	}
+
+	if _, err := runCurve(curveSpec{P: "100003", A: "2", B: "3"}, runOpts{Audit: true, MaxLines: 1}); err == nil {
+		t.Fatal("-audit accepted p > auditLimit")
+	}
+}
+
+func TestHasseStop(t *testing.T) {
+	// Without a count, the walk reseeds until the Hasse interval, the
+	// 2-torsion and the found subgroup leave a single #E, and has it all.
+	for _, ab := range [][2]int64{{2, 3}, {-1, 0}, {1, 1}, {0, 5}, {7, 0}} {
+		spec := curveSpec{P: "103", A: fmt.Sprint(ab[0]), B: fmt.Sprint(ab[1])}
+		out, err := runCurve(spec, runOpts{RandSeed: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := out.Hasse
+		want := countLegendre(mustCurve(t, 103, ab[0], ab[1]))
+		if h == nil || !h.Complete || !h.Resolved || h.CountMax != want.String() || !out.Complete || len(out.Found)+1 != int(want.Int64()) {
+			t.Fatalf("A=%d B=%d: complete %v, %d points, hasse %+v, want #E = %s", ab[0], ab[1], out.Complete, len(out.Found), h, want)
+		}
+	}
+
+	out, err := runCurve(curveSpec{P: "1009", A: "2", B: "3"}, runOpts{RandSeed: 3, MaxLines: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := out.Hasse; h == nil || h.Complete || h.Subgroup != "" || h.Lo != "947" || h.Hi != "1073" || out.Complete {
+		t.Fatalf("capped walk: hasse %+v", h)
+	}
+	out, err = runCurve(curveSpec{P: "1009", A: "2", B: "3"}, runOpts{RandSeed: 3, CountFirst: true})
+	if err != nil || out.Hasse != nil {
+		t.Fatalf("with -count_first: hasse %+v, %v", out.Hasse, err)
 	}
 }
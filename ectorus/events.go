@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// ---------- -events: the walk as a stream of typed records ----------
+
+// Event is one NDJSON record of -events. Every record has the event type,
+// the seconds since the walk started, and the lines processed and affine
+// points found at that moment; the rest depends on the type:
+//
+//	seed      the first seed point, and the seed strategy
+//	point     a newly found point (seeds included)
+//	line      a processed line: its kind and equation, new points and (-grid)
+//	          new exclusions
+//	reseed    a further seed, taken when the walk ran dry
+//	completed the end of the walk: complete and truncated
+type Event struct {
+	Event     string  `json:"event"` // seed|point|line|reseed|completed
+	T         float64 `json:"t"`
+	Lines     int     `json:"lines"`
+	Found     int     `json:"found"`
+	Point     *Pt     `json:"point,omitempty"`
+	Strategy  string  `json:"strategy,omitempty"`
+	Kind      string  `json:"kind,omitempty"` // tangent|secant|vertical|auto-vertical (-verticals)|sweep (-sweep_lines)
+	M         string  `json:"m,omitempty"`    // y = m x + c
+	C         string  `json:"c,omitempty"`    //
+	X         string  `json:"x,omitempty"`    // x = X, for a vertical line
+	NewPoints *int    `json:"new_points,omitempty"`
+	NewExcl   *int    `json:"new_exclusions,omitempty"`
+	Complete  *bool   `json:"complete,omitempty"`
+	Truncated *bool   `json:"truncated,omitempty"`
+}
+
+// eventFlush is how long records may sit in the buffer, so a dashboard
+// tailing the file lags the walk by at most this much.
+const eventFlush = 100 * time.Millisecond
+
+// eventWriter streams the Events of one walk.
+type eventWriter struct {
+	bw       *bufio.Writer
+	enc      *json.Encoder
+	start    time.Time
+	flushed  time.Time
+	strategy string
+	err      error
+}
+
+func newEventWriter(w io.Writer, strategy string) *eventWriter {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	now := time.Now()
+	if strategy == "" {
+		strategy = "random"
+	}
+	return &eventWriter{bw: bw, enc: enc, start: now, flushed: now, strategy: strategy}
+}
+
+// emit fills in the common fields and writes ev. The first error sticks
+// and is returned by flush.
+func (ew *eventWriter) emit(e *Engine, ev Event) {
+	if ew == nil || ew.err != nil {
+		return
+	}
+	now := time.Now()
+	ev.T = now.Sub(ew.start).Seconds()
+	ev.Lines, ev.Found = e.stats.lines, len(e.order)
+	if ew.err = ew.enc.Encode(ev); ew.err == nil && now.Sub(ew.flushed) >= eventFlush {
+		ew.err = ew.bw.Flush()
+		ew.flushed = now
+	}
+}
+
+func (ew *eventWriter) seed(e *Engine, P Point, first bool) {
+	if ew == nil {
+		return
+	}
+	pt := toPt(P)
+	ev := Event{Event: "reseed", Point: &pt, Strategy: ew.strategy}
+	if first {
+		ev.Event = "seed"
+	}
+	ew.emit(e, ev)
+}
+
+func (ew *eventWriter) point(e *Engine, P Point) {
+	if ew == nil {
+		return
+	}
+	pt := toPt(P)
+	ew.emit(e, Event{Event: "point", Point: &pt})
+}
+
+func (ew *eventWriter) line(e *Engine, r lineRow) {
+	if ew == nil {
+		return
+	}
+	ev := Event{Event: "line", Kind: r.kind(), NewPoints: &r.NewPoints}
+	if r.L.Vertical {
+		ev.X = r.L.V.String()
+	} else {
+		ev.M, ev.C = r.L.M.String(), r.L.C.String()
+	}
+	if e.UseGrid {
+		ev.NewExcl = &r.NewExcl
+	}
+	ew.emit(e, ev)
+}
+
+func (ew *eventWriter) completed(e *Engine, complete, truncated bool) {
+	ew.emit(e, Event{Event: "completed", Complete: &complete, Truncated: &truncated})
+}
+
+func (ew *eventWriter) flush() error {
+	if ew.err != nil {
+		return ew.err
+	}
+	return ew.bw.Flush()
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"ectorus/internal/ec"
+	"ectorus/internal/prime"
+)
+
+// ---------- curves over F_{p^k} ----------
+
+// extField is F_q, q = p^k (k = 2 or 3), as F_p[t]/(f) for the first monic
+// irreducible f = t^k + c1 t + c0 in a fixed search order. An element is a
+// coefficient vector (a_0, ..., a_{k-1}) of a_0 + a_1 t + ...; in the engine
+// it travels packed as the integer a_0 + a_1 p + ... + a_{k-1} p^(k-1).
+// Addition is coordinate-wise; products are reduced with t^k = -(c1 t + c0).
+type extField struct {
+	p, q *big.Int
+	k    int
+	mod  []*big.Int // c_0 .. c_{k-1} of f
+	z    *big.Int   // a non-square, for sqrts; found on first use
+}
+
+// newExtField picks f by trying t^k + c1 t + c0 for c1 = 0, 1, ... and
+// c0 = 1, 2, ...: of degree ≤ 3, it is irreducible exactly when it has no
+// root in F_p.
+func newExtField(p *big.Int, k int) (*extField, error) {
+	if k != 2 && k != 3 {
+		return nil, fmt.Errorf("-k %d: only F_{p^2} and F_{p^3} are supported", k)
+	}
+	q := new(big.Int).Exp(p, big.NewInt(int64(k)), nil)
+	for c1 := int64(0); c1 < 4; c1++ {
+		for c0 := int64(1); c0 < 1000 && big.NewInt(c0).Cmp(p) < 0; c0++ {
+			cs := []*big.Int{big.NewInt(c0), big.NewInt(c1)}
+			for len(cs) < k {
+				cs = append(cs, new(big.Int))
+			}
+			f := ec.NewPoly(p, append(append([]*big.Int(nil), cs...), big.NewInt(1))...)
+			if len(f.Roots(p)) == 0 {
+				return &extField{p: p, q: q, k: k, mod: cs[:k]}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no irreducible t^%d + c1 t + c0 over F_%s with small coefficients", k, p)
+}
+
+// unpack returns the k coefficients of a packed element.
+func (f *extField) unpack(a *big.Int) []*big.Int {
+	v := make([]*big.Int, f.k)
+	r := new(big.Int).Set(a)
+	for i := range v {
+		v[i] = new(big.Int)
+		r.QuoRem(r, f.p, v[i])
+	}
+	return v
+}
+
+// pack is unpack's inverse; v is reduced mod p.
+func (f *extField) pack(v []*big.Int) *big.Int {
+	a := new(big.Int)
+	for i := len(v) - 1; i >= 0; i-- {
+		a.Mul(a, f.p).Add(a, ec.Mod(v[i], f.p))
+	}
+	return a
+}
+
+func (f *extField) order() *big.Int { return f.q }
+
+func (f *extField) add(a, b *big.Int) *big.Int {
+	u, v := f.unpack(a), f.unpack(b)
+	for i := range u {
+		u[i].Add(u[i], v[i])
+	}
+	return f.pack(u)
+}
+
+func (f *extField) sub(a, b *big.Int) *big.Int {
+	u, v := f.unpack(a), f.unpack(b)
+	for i := range u {
+		u[i].Sub(u[i], v[i])
+	}
+	return f.pack(u)
+}
+
+func (f *extField) neg(a *big.Int) *big.Int { return f.sub(new(big.Int), a) }
+
+func (f *extField) mul(a, b *big.Int) *big.Int {
+	u, v := f.unpack(a), f.unpack(b)
+	r := make([]*big.Int, 2*f.k-1)
+	for i := range r {
+		r[i] = new(big.Int)
+	}
+	t := new(big.Int)
+	for i := range u {
+		for j := range v {
+			r[i+j].Add(r[i+j], t.Mul(u[i], v[j]))
+		}
+	}
+	for i := len(r) - 1; i >= f.k; i-- {
+		c := r[i].Mod(r[i], f.p)
+		for j, m := range f.mod {
+			r[i-f.k+j].Sub(r[i-f.k+j], t.Mul(c, m))
+		}
+	}
+	return f.pack(r[:f.k])
+}
+
+func (f *extField) pow(a, e *big.Int) *big.Int {
+	out := big.NewInt(1)
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		out = f.mul(out, out)
+		if e.Bit(i) == 1 {
+			out = f.mul(out, a)
+		}
+	}
+	return out
+}
+
+// inv solves a·x = 1 as a k×k linear system over F_p: column j of the
+// matrix is a·t^j.
+func (f *extField) inv(a *big.Int) (*big.Int, error) {
+	if a.Sign() == 0 {
+		return nil, errors.New("inverse of zero")
+	}
+	p, k := f.p, f.k
+	// rows of the augmented matrix [M | e_0]
+	m := make([][]*big.Int, k)
+	for i := range m {
+		m[i] = make([]*big.Int, k+1)
+		m[i][k] = new(big.Int)
+	}
+	m[0][k].SetInt64(1)
+	col := a
+	for j := 0; j < k; j++ {
+		for i, c := range f.unpack(col) {
+			m[i][j] = c
+		}
+		col = f.mul(col, f.p) // t packs to p
+	}
+	for c := 0; c < k; c++ {
+		piv := c
+		for piv < k && m[piv][c].Sign() == 0 {
+			piv++
+		}
+		if piv == k {
+			return nil, errors.New("extension field: singular multiplication matrix")
+		}
+		m[c], m[piv] = m[piv], m[c]
+		inv, err := ec.InvM(m[c][c], p)
+		if err != nil {
+			return nil, err
+		}
+		for j := c; j <= k; j++ {
+			m[c][j] = ec.MulM(m[c][j], inv, p)
+		}
+		for i := 0; i < k; i++ {
+			if i == c || m[i][c].Sign() == 0 {
+				continue
+			}
+			g := m[i][c]
+			for j := c; j <= k; j++ {
+				m[i][j] = ec.SubM(m[i][j], ec.MulM(g, m[c][j], p), p)
+			}
+		}
+	}
+	x := make([]*big.Int, k)
+	for i := range x {
+		x[i] = m[i][k]
+	}
+	return f.pack(x), nil
+}
+
+func (f *extField) int(v int64) *big.Int { return ec.Mod(big.NewInt(v), f.p) }
+
+// sqrts is Tonelli–Shanks in F_q: a is a square when a^((q-1)/2) = 1.
+func (f *extField) sqrts(a *big.Int) []*big.Int {
+	if a.Sign() == 0 {
+		return []*big.Int{new(big.Int)}
+	}
+	one := big.NewInt(1)
+	qm1 := new(big.Int).Sub(f.q, one)
+	half := new(big.Int).Rsh(qm1, 1)
+	if f.pow(a, half).Cmp(one) != 0 {
+		return nil
+	}
+	if f.z == nil {
+		for z := big.NewInt(2); ; z.Add(z, one) {
+			if f.pow(z, half).Cmp(one) != 0 {
+				f.z = new(big.Int).Set(z)
+				break
+			}
+		}
+	}
+	s := int(qm1.TrailingZeroBits())
+	Q := new(big.Int).Rsh(qm1, uint(s))
+	c := f.pow(f.z, Q)
+	t := f.pow(a, Q)
+	R := f.pow(a, new(big.Int).Rsh(new(big.Int).Add(Q, one), 1))
+	for M := s; t.Cmp(one) != 0; {
+		i, t2 := 0, t
+		for t2.Cmp(one) != 0 {
+			t2 = f.mul(t2, t2)
+			i++
+		}
+		b := c
+		for j := 0; j < M-i-1; j++ {
+			b = f.mul(b, b)
+		}
+		M, c = i, f.mul(b, b)
+		t, R = f.mul(t, c), f.mul(R, b)
+	}
+	return []*big.Int{R, f.neg(R)}
+}
+
+// parse reads an element as its coefficient vector "a0,a1[,a2]" (brackets
+// or parentheses optional, each entry decimal, 0x-hex or an expression,
+// negatives allowed); a single value is an element of F_p.
+func (f *extField) parse(s string) (*big.Int, error) {
+	s = strings.Trim(strings.TrimSpace(s), "[]()")
+	parts := strings.Split(s, ",")
+	if len(parts) > f.k {
+		return nil, fmt.Errorf("%q has %d coefficients; F_{p^%d} takes at most %d", s, len(parts), f.k, f.k)
+	}
+	v := make([]*big.Int, f.k)
+	for i := range v {
+		v[i] = new(big.Int)
+	}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		neg := strings.HasPrefix(part, "-")
+		c, err := ec.ParseBig(strings.TrimPrefix(part, "-"))
+		if err != nil {
+			return nil, err
+		}
+		if neg {
+			c.Neg(c)
+		}
+		v[i] = c
+	}
+	return f.pack(v), nil
+}
+
+// format writes a packed element as its coefficient vector "(a0,a1,...)".
+func (f *extField) format(a *big.Int) string {
+	v := f.unpack(a)
+	s := make([]string, len(v))
+	for i, c := range v {
+		s[i] = c.String()
+	}
+	return "(" + strings.Join(s, ",") + ")"
+}
+
+// modulus writes f, e.g. "t^2 + 2".
+func (f *extField) modulus() string {
+	s := fmt.Sprintf("t^%d", f.k)
+	for i := f.k - 1; i >= 0; i-- {
+		c := f.mod[i]
+		switch {
+		case c.Sign() == 0:
+		case i == 0:
+			s += " + " + c.String()
+		case i == 1:
+			s += " + " + c.String() + " t"
+		default:
+			s += fmt.Sprintf(" + %s t^%d", c, i)
+		}
+	}
+	return s
+}
+
+// inBase reports whether a lies in F_p.
+func (f *extField) inBase(a *big.Int) bool { return a.Cmp(f.p) < 0 }
+
+// pointCount is #E(F_q). For A, B in F_p it counts E over F_p and lifts:
+// with t = p + 1 - #E(F_p), s_0 = 2, s_1 = t, s_n = t s_{n-1} - p s_{n-2},
+// #E(F_{p^k}) = p^k + 1 - s_k. Otherwise it counts the affine points of e
+// one x at a time (O(q)).
+func (f *extField) pointCount(A, B *big.Int, e *Engine) (*big.Int, error) {
+	if f.inBase(A) && f.inBase(B) {
+		t := new(big.Int).Sub(new(big.Int).Add(f.p, big.NewInt(1)), countLegendre(Curve{P: f.p, A: A, B: B}))
+		s0, s1 := big.NewInt(2), new(big.Int).Set(t)
+		for n := 2; n <= f.k; n++ {
+			s2 := new(big.Int).Sub(new(big.Int).Mul(t, s1), new(big.Int).Mul(f.p, s0))
+			s0, s1 = s1, s2
+		}
+		N := new(big.Int).Add(f.q, big.NewInt(1))
+		return N.Sub(N, s1), nil
+	}
+	if f.q.Cmp(big.NewInt(candidateLimit)) > 0 {
+		return nil, fmt.Errorf("-count_first over F_{p^%d} with A or B outside F_p scans all p^%d x; that needs p^%d ≤ %d", f.k, f.k, f.k, candidateLimit)
+	}
+	return new(big.Int).Add(e.countAffine(), big.NewInt(1)), nil
+}
+
+// extModel is y^2 = x^3 + A x + B over an extField, walked as a model: the
+// field arithmetic comes from F, and F_p's chord batching is not used.
+type extModel struct {
+	F    *extField
+	A, B *big.Int
+}
+
+func (m extModel) name() string { return "weierstrass" }
+
+func (m extModel) rhs(x *big.Int) *big.Int {
+	F := m.F
+	return F.add(F.mul(F.add(F.mul(x, x), m.A), x), m.B)
+}
+
+// singular: 4A^3 + 27B^2 = 0.
+func (m extModel) singular() bool {
+	F := m.F
+	d := F.add(F.mul(F.int(4), F.mul(m.A, F.mul(m.A, m.A))), F.mul(F.int(27), F.mul(m.B, m.B)))
+	return d.Sign() == 0
+}
+
+func (m extModel) on(P Point) bool {
+	return P.Inf || m.F.mul(P.Y, P.Y).Cmp(m.rhs(P.X)) == 0
+}
+
+func (m extModel) neg(P Point) Point {
+	if P.Inf {
+		return P
+	}
+	return Point{X: new(big.Int).Set(P.X), Y: m.F.neg(P.Y)}
+}
+
+func (m extModel) add(P, Q Point) (Point, error) {
+	F := m.F
+	switch {
+	case P.Inf:
+		return Q, nil
+	case Q.Inf:
+		return P, nil
+	case P.X.Cmp(Q.X) == 0 && F.add(P.Y, Q.Y).Sign() == 0:
+		return Point{Inf: true}, nil
+	}
+	var lam *big.Int
+	if P.X.Cmp(Q.X) == 0 {
+		inv, err := F.inv(F.mul(F.int(2), P.Y))
+		if err != nil {
+			return Point{}, err
+		}
+		lam = F.mul(F.add(F.mul(F.int(3), F.mul(P.X, P.X)), m.A), inv)
+	} else {
+		inv, err := F.inv(F.sub(Q.X, P.X))
+		if err != nil {
+			return Point{}, err
+		}
+		lam = F.mul(F.sub(Q.Y, P.Y), inv)
+	}
+	x := F.sub(F.sub(F.mul(lam, lam), P.X), Q.X)
+	return Point{X: x, Y: F.sub(F.mul(lam, F.sub(P.X, x)), P.Y)}, nil
+}
+
+func (m extModel) ysAt(x *big.Int) []*big.Int { return m.F.sqrts(m.rhs(x)) }
+
+// F = y^2 - x^3 - A x - B
+func (m extModel) grad(P Point) (*big.Int, *big.Int) {
+	F := m.F
+	return F.neg(F.add(F.mul(F.int(3), F.mul(P.X, P.X)), m.A)), F.mul(F.int(2), P.Y)
+}
+
+func (m extModel) restrict(L Line) poly {
+	F := m.F
+	if L.Vertical {
+		return poly{F.neg(m.rhs(L.V)), new(big.Int), F.int(1)}
+	}
+	// (Mx + C)^2 - x^3 - A x - B
+	M, C := L.M, L.C
+	return poly{
+		F.sub(F.mul(C, C), m.B),
+		F.sub(F.mul(F.int(2), F.mul(M, C)), m.A),
+		F.mul(M, M),
+		F.neg(F.int(1)),
+	}
+}
+
+// ExtensionOut describes the field of a -k run.
+type ExtensionOut struct {
+	K       int    `json:"k"`
+	Modulus string `json:"modulus"` // F_{p^k} = F_p[t]/(modulus)
+}
+
+// extCurve checks that o suits a walk over F_{p^k} and returns the field
+// and the parsed A and B. The explicit grid would be p^k × p^k, and the
+// analyses after the walk work over F_p, so they are refused.
+func extCurve(spec curveSpec, p *big.Int, o runOpts) (*extField, *big.Int, *big.Int, error) {
+	switch {
+	case spec.Ainvs != "" || (spec.Form != "" && spec.Form != "weierstrass" && spec.Form != "w"):
+		return nil, nil, nil, errors.New("-k needs -form weierstrass")
+	case o.UseGrid:
+		return nil, nil, nil, errors.New("-grid needs -k 1: over F_{p^k} the walk keeps implicit exclusions only")
+	case o.Ring:
+		return nil, nil, nil, errors.New("-ring needs -k 1")
+	case o.Orders || o.Group || o.Twist || len(o.Isogenies) > 0 || o.CrossCheck || o.SEC1:
+		return nil, nil, nil, errors.New("-orders, -group, -twist, -isogenies, -crosscheck and -sec1 work over F_p; they need -k 1")
+	case o.LinesOut != "" || o.GraphPath != "" || o.Events != "":
+		return nil, nil, nil, errors.New("-lines_out, -graph and -events write F_p coordinates; they need -k 1")
+	case o.SeedStrategy == "from-file":
+		return nil, nil, nil, errors.New("-seed_strategy from-file needs -k 1")
+	case !prime.BPSW(p):
+		return nil, nil, nil, fmt.Errorf("-k needs a prime p; p = %s is composite", p)
+	}
+	F, err := newExtField(p, spec.K)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if F.q.BitLen() > maxKeyBits {
+		return nil, nil, nil, fmt.Errorf("p^%d must fit in %d bits", spec.K, maxKeyBits)
+	}
+	A, err := F.parse(spec.A)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("parsing value for A: %w", err)
+	}
+	B, err := F.parse(spec.B)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("parsing value for B: %w", err)
+	}
+	return F, A, B, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math/big"
+
+	"ectorus/internal/ec"
+)
+
+// ---------- the field the walk runs over ----------
+
+// field is the arithmetic the model-based walk (lines, intersections,
+// implicit exclusions) needs. Elements are *big.Int in [0, order()): plain
+// residues for F_p, packed coefficient vectors for F_{p^k} (extField), so
+// points, map keys and lines keep their F_p types.
+type field interface {
+	order() *big.Int
+	add(a, b *big.Int) *big.Int
+	sub(a, b *big.Int) *big.Int
+	mul(a, b *big.Int) *big.Int
+	neg(a *big.Int) *big.Int
+	inv(a *big.Int) (*big.Int, error)
+	// sqrts returns the distinct square roots of a (0, 1 or 2 of them).
+	sqrts(a *big.Int) []*big.Int
+	// int returns the image of the integer v.
+	int(v int64) *big.Int
+}
+
+// primeField is F_p through internal/ec.
+type primeField struct{ p *big.Int }
+
+func (f primeField) order() *big.Int                  { return f.p }
+func (f primeField) add(a, b *big.Int) *big.Int       { return ec.AddM(a, b, f.p) }
+func (f primeField) sub(a, b *big.Int) *big.Int       { return ec.SubM(a, b, f.p) }
+func (f primeField) mul(a, b *big.Int) *big.Int       { return ec.MulM(a, b, f.p) }
+func (f primeField) neg(a *big.Int) *big.Int          { return ec.NegM(a, f.p) }
+func (f primeField) inv(a *big.Int) (*big.Int, error) { return ec.InvM(a, f.p) }
+func (f primeField) sqrts(a *big.Int) []*big.Int      { return sqrtsOf(a, f.p) }
+func (f primeField) int(v int64) *big.Int             { return ec.Mod(big.NewInt(v), f.p) }
+
+// field is the engine's field: F when set, else F_p for the curve C.
+func (e *Engine) field() field {
+	if e.F != nil {
+		return e.F
+	}
+	return primeField{e.C.P}
+}
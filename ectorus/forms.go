@@ -0,0 +1,411 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"ectorus/internal/ec"
+)
+
+// ---------- other curve models ----------
+
+// model is a curve model other than short Weierstrass that the walk can run
+// on. Lines are still lines of the (x,y) torus, but how they meet the curve
+// depends on the model: a Montgomery cubic is met in three points like
+// Weierstrass, a twisted Edwards quartic in up to four, and the Edwards
+// group law is not chord-and-tangent at all.
+type model interface {
+	name() string
+	on(P Point) bool
+	add(P, Q Point) (Point, error)
+	neg(P Point) Point
+	// ysAt returns every y with (x, y) on the curve.
+	ysAt(x *big.Int) []*big.Int
+	// grad returns (∂F/∂x, ∂F/∂y) at P for the defining equation F = 0.
+	grad(P Point) (fx, fy *big.Int)
+	// restrict returns F on L: a polynomial in x for y = Mx + C, in y for x = V.
+	restrict(L Line) poly
+}
+
+// parseForm returns the model for -form (nil for weierstrass), reading -A/-B
+// as that model's two coefficients (or ainvs for the long form), and the
+// isomorphic short Weierstrass curve used for counting.
+func parseForm(form string, p, A, B *big.Int, ainvs []*big.Int) (model, Curve, error) {
+	switch strings.ToLower(strings.TrimSpace(form)) {
+	case "", "weierstrass", "w":
+		return nil, Curve{P: p, A: ec.Mod(A, p), B: ec.Mod(B, p)}, nil
+	case "montgomery", "m":
+		m := ec.Montgomery{P: p, A: ec.Mod(A, p), B: ec.Mod(B, p)}
+		if m.IsSingular() {
+			return nil, Curve{}, errors.New("singular Montgomery curve: B(A^2-4) ≡ 0 mod p")
+		}
+		w, err := m.ToWeierstrass()
+		return montModel{m}, w, err
+	case "edwards", "e":
+		ed := ec.Edwards{P: p, A: ec.Mod(A, p), D: ec.Mod(B, p)}
+		if ed.IsSingular() {
+			return nil, Curve{}, errors.New("singular Edwards curve: a·d·(a-d) ≡ 0 mod p")
+		}
+		w, err := ed.ToWeierstrass()
+		return edwModel{ed}, w, err
+	case "general", "long", "g":
+		if len(ainvs) != 5 {
+			return nil, Curve{}, errors.New("-form general needs -ainvs a1,a2,a3,a4,a6")
+		}
+		g := ec.General{P: p, A1: ec.Mod(ainvs[0], p), A2: ec.Mod(ainvs[1], p), A3: ec.Mod(ainvs[2], p), A4: ec.Mod(ainvs[3], p), A6: ec.Mod(ainvs[4], p)}
+		if g.IsSingular() {
+			return nil, Curve{}, errors.New("singular curve: discriminant Δ ≡ 0 mod p")
+		}
+		w, err := g.ToWeierstrass()
+		return genModel{g}, w, err
+	default:
+		return nil, Curve{}, fmt.Errorf("unknown -form %q (want weierstrass|montgomery|edwards|general)", form)
+	}
+}
+
+// parseAinvs reads Cremona-style a-invariants "[a1,a2,a3,a4,a6]" (brackets
+// optional, decimal or 0x-hex, negatives allowed).
+func parseAinvs(s string) ([]*big.Int, error) {
+	s = strings.Trim(strings.TrimSpace(s), "[]")
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("-ainvs wants 5 values a1,a2,a3,a4,a6; got %d", len(parts))
+	}
+	out := make([]*big.Int, len(parts))
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		neg := strings.HasPrefix(part, "-")
+		v, err := ec.ParseBig(strings.TrimPrefix(part, "-"))
+		if err != nil {
+			return nil, fmt.Errorf("-ainvs[%d]: %w", i, err)
+		}
+		if neg {
+			v.Neg(v)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// --- Montgomery: B y^2 = x^3 + A x^2 + x ---
+
+type montModel struct{ ec.Montgomery }
+
+func (m montModel) name() string                  { return "montgomery" }
+func (m montModel) on(P Point) bool               { return m.On(P) }
+func (m montModel) add(P, Q Point) (Point, error) { return m.Add(P, Q) }
+func (m montModel) neg(P Point) Point             { return m.Neg(P) }
+
+func (m montModel) ysAt(x *big.Int) []*big.Int {
+	rhs, err := m.RHS(x)
+	if err != nil {
+		return nil
+	}
+	return sqrtsOf(rhs, m.P)
+}
+
+// F = B y^2 - x^3 - A x^2 - x
+func (m montModel) grad(P Point) (*big.Int, *big.Int) {
+	p := m.P
+	fx := ec.NegM(ec.AddM(ec.AddM(ec.MulM(big.NewInt(3), ec.MulM(P.X, P.X, p), p), ec.MulM(big.NewInt(2), ec.MulM(m.A, P.X, p), p), p), big.NewInt(1), p), p)
+	fy := ec.MulM(big.NewInt(2), ec.MulM(m.B, P.Y, p), p)
+	return fx, fy
+}
+
+func (m montModel) restrict(L Line) poly {
+	p := m.P
+	if L.Vertical {
+		v := L.V
+		rhs := ec.AddM(ec.AddM(ec.MulM(v, ec.MulM(v, v, p), p), ec.MulM(m.A, ec.MulM(v, v, p), p), p), v, p)
+		return poly{ec.NegM(rhs, p), big.NewInt(0), ec.Mod(m.B, p)}
+	}
+	// B(Mx + C)^2 - x^3 - A x^2 - x
+	M, C := L.M, L.C
+	return poly{
+		ec.MulM(m.B, ec.MulM(C, C, p), p),
+		ec.SubM(ec.MulM(big.NewInt(2), ec.MulM(m.B, ec.MulM(M, C, p), p), p), big.NewInt(1), p),
+		ec.SubM(ec.MulM(m.B, ec.MulM(M, M, p), p), m.A, p),
+		ec.NegM(big.NewInt(1), p),
+	}
+}
+
+// --- twisted Edwards: a x^2 + y^2 = 1 + d x^2 y^2 ---
+
+type edwModel struct{ ec.Edwards }
+
+func (e edwModel) name() string                  { return "edwards" }
+func (e edwModel) on(P Point) bool               { return e.On(P) }
+func (e edwModel) add(P, Q Point) (Point, error) { return e.Add(P, Q) }
+func (e edwModel) neg(P Point) Point             { return e.Neg(P) }
+
+// y^2 = (1 - a x^2) / (1 - d x^2)
+func (e edwModel) ysAt(x *big.Int) []*big.Int {
+	p := e.P
+	x2 := ec.MulM(x, x, p)
+	den, err := ec.InvM(ec.SubM(big.NewInt(1), ec.MulM(e.D, x2, p), p), p)
+	if err != nil {
+		return nil
+	}
+	return sqrtsOf(ec.MulM(ec.SubM(big.NewInt(1), ec.MulM(e.A, x2, p), p), den, p), p)
+}
+
+// F = a x^2 + y^2 - 1 - d x^2 y^2
+func (e edwModel) grad(P Point) (*big.Int, *big.Int) {
+	p := e.P
+	x2, y2 := ec.MulM(P.X, P.X, p), ec.MulM(P.Y, P.Y, p)
+	fx := ec.MulM(ec.MulM(big.NewInt(2), P.X, p), ec.SubM(e.A, ec.MulM(e.D, y2, p), p), p)
+	fy := ec.MulM(ec.MulM(big.NewInt(2), P.Y, p), ec.SubM(big.NewInt(1), ec.MulM(e.D, x2, p), p), p)
+	return fx, fy
+}
+
+func (e edwModel) restrict(L Line) poly {
+	p := e.P
+	if L.Vertical {
+		v2 := ec.MulM(L.V, L.V, p)
+		return poly{ec.SubM(ec.MulM(e.A, v2, p), big.NewInt(1), p), big.NewInt(0), ec.SubM(big.NewInt(1), ec.MulM(e.D, v2, p), p)}
+	}
+	// a x^2 + (Mx+C)^2 - 1 - d x^2 (Mx+C)^2
+	M, C := L.M, L.C
+	M2, C2, MC := ec.MulM(M, M, p), ec.MulM(C, C, p), ec.MulM(M, C, p)
+	return poly{
+		ec.SubM(C2, big.NewInt(1), p),
+		ec.MulM(big.NewInt(2), MC, p),
+		ec.SubM(ec.AddM(e.A, M2, p), ec.MulM(e.D, C2, p), p),
+		ec.NegM(ec.MulM(big.NewInt(2), ec.MulM(e.D, MC, p), p), p),
+		ec.NegM(ec.MulM(e.D, M2, p), p),
+	}
+}
+
+// --- long Weierstrass: y^2 + a1 x y + a3 y = x^3 + a2 x^2 + a4 x + a6 ---
+
+type genModel struct{ ec.General }
+
+func (g genModel) name() string                  { return "general" }
+func (g genModel) on(P Point) bool               { return g.On(P) }
+func (g genModel) add(P, Q Point) (Point, error) { return g.Add(P, Q) }
+func (g genModel) neg(P Point) Point             { return g.Neg(P) }
+
+func (g genModel) ysAt(x *big.Int) []*big.Int {
+	return g.restrict(Line{Vertical: true, V: x}).roots(primeField{g.P})
+}
+
+// F = y^2 + a1 x y + a3 y - x^3 - a2 x^2 - a4 x - a6
+func (g genModel) grad(P Point) (*big.Int, *big.Int) {
+	p := g.P
+	fx := ec.SubM(ec.MulM(g.A1, P.Y, p), ec.AddM(ec.AddM(ec.MulM(big.NewInt(3), ec.MulM(P.X, P.X, p), p), ec.MulM(ec.MulM(big.NewInt(2), g.A2, p), P.X, p), p), g.A4, p), p)
+	fy := ec.AddM(ec.AddM(ec.MulM(big.NewInt(2), P.Y, p), ec.MulM(g.A1, P.X, p), p), g.A3, p)
+	return fx, fy
+}
+
+func (g genModel) restrict(L Line) poly {
+	p := g.P
+	if L.Vertical {
+		v := L.V
+		rhs := ec.AddM(ec.AddM(ec.AddM(ec.MulM(v, ec.MulM(v, v, p), p), ec.MulM(g.A2, ec.MulM(v, v, p), p), p), ec.MulM(g.A4, v, p), p), g.A6, p)
+		return poly{ec.NegM(rhs, p), ec.AddM(ec.MulM(g.A1, v, p), g.A3, p), big.NewInt(1)}
+	}
+	// (Mx+C)^2 + a1 x (Mx+C) + a3 (Mx+C) - x^3 - a2 x^2 - a4 x - a6
+	M, C := L.M, L.C
+	c1 := ec.AddM(ec.AddM(ec.MulM(big.NewInt(2), ec.MulM(M, C, p), p), ec.MulM(g.A1, C, p), p), ec.MulM(g.A3, M, p), p)
+	return poly{
+		ec.SubM(ec.AddM(ec.MulM(C, C, p), ec.MulM(g.A3, C, p), p), g.A6, p),
+		ec.SubM(c1, g.A4, p),
+		ec.SubM(ec.AddM(ec.MulM(M, M, p), ec.MulM(g.A1, M, p), p), g.A2, p),
+		ec.NegM(big.NewInt(1), p),
+	}
+}
+
+// ---------- lines and intersections on a model ----------
+
+// modelLine is lineThrough for a general model: the tangent at P comes from
+// the gradient of F (vertical when ∂F/∂y = 0), a secant from the two points.
+func modelLine(m model, f field, P Point, Q *Point) (Line, error) {
+	var slope *big.Int
+	switch {
+	case Q == nil || Q.Equal(P):
+		fx, fy := m.grad(P)
+		if fy.Sign() == 0 {
+			return Line{Vertical: true, V: new(big.Int).Set(P.X)}, nil
+		}
+		inv, err := f.inv(fy)
+		if err != nil {
+			return Line{}, err
+		}
+		slope = f.neg(f.mul(fx, inv))
+	case P.X.Cmp(Q.X) == 0:
+		return Line{Vertical: true, V: new(big.Int).Set(P.X)}, nil
+	default:
+		inv, err := f.inv(f.sub(Q.X, P.X))
+		if err != nil {
+			return Line{}, err
+		}
+		slope = f.mul(f.sub(Q.Y, P.Y), inv)
+	}
+	return Line{M: slope, C: f.sub(P.Y, f.mul(slope, P.X))}, nil
+}
+
+// modelIntersections returns the affine points of m on L: the known points
+// (P twice for a tangent) are divided out of F|L and whatever is left, at
+// most a quadratic, is solved directly.
+func modelIntersections(m model, F field, L Line, known []Point) []Point {
+	f := m.restrict(L)
+	for _, K := range known {
+		r := K.X
+		if L.Vertical {
+			r = K.Y
+		}
+		if q, ok := f.divLinear(r, F); ok {
+			f = q
+		}
+	}
+	pts := append([]Point(nil), known...)
+	for _, r := range f.roots(F) {
+		if L.Vertical {
+			pts = append(pts, Point{X: new(big.Int).Set(L.V), Y: r})
+		} else {
+			pts = append(pts, Point{X: r, Y: F.add(F.mul(L.M, r), L.C)})
+		}
+	}
+	return pts
+}
+
+// ---------- small polynomials over the walk's field ----------
+
+// poly holds field elements, constant term first.
+type poly []*big.Int
+
+// trim drops zero leading coefficients.
+func (f poly) trim() poly {
+	for len(f) > 0 && f[len(f)-1].Sign() == 0 {
+		f = f[:len(f)-1]
+	}
+	return f
+}
+
+// divLinear divides f by (t - r), reporting ok only for an exact division.
+func (f poly) divLinear(r *big.Int, F field) (poly, bool) {
+	f = f.trim()
+	if len(f) < 2 {
+		return f, false
+	}
+	q := make(poly, len(f)-1)
+	acc := big.NewInt(0)
+	for i := len(f) - 1; i >= 1; i-- {
+		acc = F.add(f[i], F.mul(acc, r))
+		q[i-1] = acc
+	}
+	rem := F.add(f[0], F.mul(acc, r))
+	return q, rem.Sign() == 0
+}
+
+// roots returns the distinct roots of f when deg f ≤ 2 (nil otherwise).
+func (f poly) roots(F field) []*big.Int {
+	f = f.trim()
+	switch len(f) {
+	case 2: // f1 t + f0
+		inv, err := F.inv(f[1])
+		if err != nil {
+			return nil
+		}
+		return []*big.Int{F.neg(F.mul(f[0], inv))}
+	case 3: // (-f1 ± sqrt(f1^2 - 4 f2 f0)) / 2 f2
+		disc := F.sub(F.mul(f[1], f[1]), F.mul(F.int(4), F.mul(f[2], f[0])))
+		inv, err := F.inv(F.mul(F.int(2), f[2]))
+		if err != nil {
+			return nil
+		}
+		var rs []*big.Int
+		for _, s := range F.sqrts(disc) {
+			rs = append(rs, F.mul(F.sub(s, f[1]), inv))
+		}
+		return rs
+	}
+	return nil
+}
+
+// sqrtsOf returns the distinct square roots of a mod p (0, 1 or 2 of them).
+func sqrtsOf(a, p *big.Int) []*big.Int {
+	switch ec.Legendre(a, p) {
+	case 0:
+		return []*big.Int{big.NewInt(0)}
+	case 1:
+		y, err := ec.SqrtModP(a, p)
+		if err != nil {
+			return nil
+		}
+		return []*big.Int{y, ec.NegM(y, p)}
+	}
+	return nil
+}
+
+// ---------- engine dispatch ----------
+
+func (e *Engine) onCurve(P Point) bool {
+	if e.Model != nil {
+		return e.Model.on(P)
+	}
+	return e.C.On(P)
+}
+
+// pointsAtX returns the affine points with abscissa x on the walked curve.
+func (e *Engine) pointsAtX(x *big.Int) []Point {
+	var ys []*big.Int
+	if e.Model != nil {
+		ys = e.Model.ysAt(x)
+	} else {
+		p := e.C.P
+		t := ec.AddM(ec.AddM(ec.MulM(x, ec.MulM(x, x, p), p), ec.MulM(e.C.A, x, p), p), e.C.B, p)
+		ys = sqrtsOf(t, p)
+	}
+	pts := make([]Point, 0, len(ys))
+	for _, y := range ys {
+		pts = append(pts, Point{X: new(big.Int).Set(x), Y: y})
+	}
+	return pts
+}
+
+func (e *Engine) line(P Point, Q *Point) (Line, error) {
+	if e.Model != nil {
+		return modelLine(e.Model, e.field(), P, Q)
+	}
+	return lineThrough(e.C, P, Q)
+}
+
+// intersections returns the points of the curve on L through P (and Q), plus
+// the points the group law yields from them that need not lie on L: R = P+Q
+// for Weierstrass (L meets E in -R), P+Q and -(P+Q) for the other models.
+func (e *Engine) intersections(L Line, P Point, Q *Point) (inters, extra []Point, err error) {
+	if e.Model == nil {
+		R, inters := chordSum(e.C, L, P, Q)
+		if !R.Inf {
+			extra = append(extra, R)
+		}
+		return inters, extra, nil
+	}
+	Q2 := P
+	if Q != nil {
+		Q2 = *Q
+	}
+	inters = modelIntersections(e.Model, e.field(), L, []Point{P, Q2})
+	// Exceptional sums (Edwards with d a square) just contribute nothing.
+	if S, err := e.Model.add(P, Q2); err == nil {
+		extra = append(extra, S, e.Model.neg(S))
+	}
+	return inters, extra, nil
+}
+
+// countAffine counts the affine points of the walked curve directly (O(q)
+// for the field order q).
+func (e *Engine) countAffine() *big.Int {
+	cnt := new(big.Int)
+	q := e.field().order()
+	for x := new(big.Int); x.Cmp(q) < 0; x.Add(x, big.NewInt(1)) {
+		cnt.Add(cnt, big.NewInt(int64(len(e.pointsAtX(x)))))
+	}
+	return cnt
+}
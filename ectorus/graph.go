@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ---------- -graph: the discovery graph ----------
+
+// origin is the line that first produced a point: its kind, its number in
+// processing order, and the point(s) it was drawn through.
+type origin struct {
+	kind    string
+	line    int
+	parents []ptKey
+}
+
+// discoveryGraph records an origin for every point a line found; points
+// without one are seeds.
+type discoveryGraph struct {
+	origins map[ptKey]origin
+}
+
+func (g *discoveryGraph) note(e *Engine, S Point, r lineRow, P Point, Q *Point) {
+	if g.origins == nil {
+		g.origins = make(map[ptKey]origin)
+	}
+	o := origin{kind: r.kind(), line: e.stats.lines + 1, parents: []ptKey{e.pointKey(P)}}
+	if Q != nil && !Q.Equal(P) {
+		o.parents = append(o.parents, e.pointKey(*Q))
+	}
+	g.origins[e.pointKey(S)] = o
+}
+
+// graphNode is a found point in discovery order.
+type graphNode struct {
+	id   string
+	P    Point
+	seed bool
+}
+
+func (e *Engine) graphNodes() ([]graphNode, map[ptKey]string) {
+	ids := map[ptKey]string{}
+	var nodes []graphNode
+	add := func(P Point) {
+		k := e.pointKey(P)
+		id := fmt.Sprintf("n%d", len(nodes))
+		if P.Inf {
+			id = "O"
+		}
+		ids[k] = id
+		_, derived := e.graph.origins[k]
+		nodes = append(nodes, graphNode{id: id, P: P, seed: !derived})
+	}
+	for _, P := range e.order {
+		add(P)
+	}
+	if O, ok := e.found[ptKey{inf: true}]; ok {
+		add(O)
+	}
+	return nodes, ids
+}
+
+func pointLabel(P Point) string {
+	if P.Inf {
+		return "O"
+	}
+	return fmt.Sprintf("(%s, %s)", P.X, P.Y)
+}
+
+// writeGraph writes the discovery graph to path: GraphML for a .graphml
+// file, DOT otherwise.
+func (e *Engine) writeGraph(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	if strings.HasSuffix(strings.ToLower(path), ".graphml") {
+		err = e.writeGraphML(bw)
+	} else {
+		err = e.writeDOT(bw)
+	}
+	if err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeDOT emits one node per found point (seeds boxed) and one edge from
+// each parent of a point to it, labelled with the line's kind and number.
+func (e *Engine) writeDOT(w io.Writer) error {
+	nodes, ids := e.graphNodes()
+	ew := &errWriter{w: w}
+	ew.printf("digraph discovery {\n")
+	ew.printf("  label=%q;\n", fmt.Sprintf("y^2 = x^3 + %s x + %s over F_%s", e.C.A, e.C.B, e.C.P))
+	for _, n := range nodes {
+		shape := "ellipse"
+		if n.seed {
+			shape = "box"
+		}
+		ew.printf("  %s [label=%q, shape=%s];\n", n.id, pointLabel(n.P), shape)
+	}
+	for _, n := range nodes {
+		o, ok := e.graph.origins[e.pointKey(n.P)]
+		if !ok {
+			continue
+		}
+		for _, pk := range o.parents {
+			style := "solid"
+			if o.kind == "tangent" {
+				style = "dashed"
+			}
+			ew.printf("  %s -> %s [label=\"%s %d\", style=%s];\n", ids[pk], n.id, o.kind, o.line, style)
+		}
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+type gmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type gmlNode struct {
+	ID   string    `xml:"id,attr"`
+	Data []gmlData `xml:"data"`
+}
+
+type gmlEdge struct {
+	Source string    `xml:"source,attr"`
+	Target string    `xml:"target,attr"`
+	Data   []gmlData `xml:"data"`
+}
+
+type gmlKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphML struct {
+	XMLName xml.Name `xml:"graphml"`
+	NS      string   `xml:"xmlns,attr"`
+	Keys    []gmlKey `xml:"key"`
+	Graph   struct {
+		ID          string    `xml:"id,attr"`
+		EdgeDefault string    `xml:"edgedefault,attr"`
+		Nodes       []gmlNode `xml:"node"`
+		Edges       []gmlEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+// writeGraphML emits the same graph as writeDOT, with x, y, inf and seed
+// node attributes and kind and line edge attributes.
+func (e *Engine) writeGraphML(w io.Writer) error {
+	nodes, ids := e.graphNodes()
+	g := graphML{NS: "http://graphml.graphdrawing.org/xmlns"}
+	g.Keys = []gmlKey{
+		{"x", "node", "x", "string"}, {"y", "node", "y", "string"},
+		{"inf", "node", "inf", "boolean"}, {"seed", "node", "seed", "boolean"},
+		{"kind", "edge", "kind", "string"}, {"line", "edge", "line", "int"},
+	}
+	g.Graph.ID, g.Graph.EdgeDefault = "discovery", "directed"
+	for _, n := range nodes {
+		gn := gmlNode{ID: n.id}
+		if n.P.Inf {
+			gn.Data = append(gn.Data, gmlData{"inf", "true"})
+		} else {
+			gn.Data = append(gn.Data, gmlData{"x", n.P.X.String()}, gmlData{"y", n.P.Y.String()})
+		}
+		gn.Data = append(gn.Data, gmlData{"seed", fmt.Sprint(n.seed)})
+		g.Graph.Nodes = append(g.Graph.Nodes, gn)
+	}
+	for _, n := range nodes {
+		o, ok := e.graph.origins[e.pointKey(n.P)]
+		if !ok {
+			continue
+		}
+		for _, pk := range o.parents {
+			g.Graph.Edges = append(g.Graph.Edges, gmlEdge{Source: ids[pk], Target: n.id,
+				Data: []gmlData{{"kind", o.kind}, {"line", fmt.Sprint(o.line)}}})
+		}
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(g); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// errWriter keeps the first write error so the DOT writer can stay linear.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = fmt.Fprintf(e.w, format, args...)
+}
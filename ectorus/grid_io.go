@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+	"os"
+
+	"ectorus/internal/gridfile"
+)
+
+// ---------- -grid_out / -grid_in: the grid on disk ----------
+
+// GridInOut reports the grid a -grid_in file supplied.
+type GridInOut struct {
+	File     string `json:"file"`
+	Found    int    `json:"found"`
+	Excluded int    `json:"excluded"`
+	Lines    int    `json:"linesProcessed"` // by the walk that wrote it
+}
+
+// gridHeader identifies the walk's grid: p, the short Weierstrass A and B
+// (the same for every -form) and the model whose coordinates the cells are.
+func (e *Engine) gridHeader() gridfile.Header {
+	form := "weierstrass"
+	if e.Model != nil {
+		form = e.Model.name()
+	}
+	return gridfile.Header{P: e.G.p, A: e.C.A.String(), B: e.C.B.String(), Form: form, Lines: len(e.linesDone)}
+}
+
+// writeGrid writes the grid's FOUND and EXCLUDED bitsets to path.
+func (e *Engine) writeGrid(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := gridfile.NewWriter(f, e.gridHeader())
+	if err != nil {
+		return err
+	}
+	var found, excl [chunkWords]uint64
+	for ci := range gridfile.Chunks(e.G.p) {
+		e.G.found.chunk(ci, found[:])
+		e.G.excl.chunk(ci, excl[:])
+		if err := w.Chunk(found[:], excl[:]); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// loadGrid reads a -grid_in file written for the same curve: its excluded
+// cells go straight into the grid and its found cells are added as points,
+// so the walk's lines start from them. A found cell off the curve is an
+// error.
+func (e *Engine) loadGrid(path string) (*GridInOut, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := gridfile.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	h, want := r.Header(), e.gridHeader()
+	if h.P != want.P || h.A != want.A || h.B != want.B || h.Form != want.Form {
+		return nil, fmt.Errorf("%s is the grid of p = %d, A = %s, B = %s (%s), not p = %d, A = %s, B = %s (%s)",
+			path, h.P, h.A, h.B, h.Form, want.P, want.A, want.B, want.Form)
+	}
+	var found, excl [chunkWords]uint64
+	var cells []int
+	for ci := 0; ; ci++ {
+		err := r.Next(found[:], excl[:])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		e.G.excl.orChunk(ci, excl[:])
+		for j, w := range found {
+			for ; w != 0; w &= w - 1 {
+				cells = append(cells, ci*chunkSize+64*j+bits.TrailingZeros64(w))
+			}
+		}
+	}
+	e.G.recount()
+	res := &GridInOut{File: path, Found: len(cells), Excluded: r.Counts().Excluded, Lines: h.Lines}
+	p := e.G.p
+	for _, i := range cells {
+		P := Point{X: big.NewInt(int64(i % p)), Y: big.NewInt(int64(i / p))}
+		if !e.onCurve(P) {
+			return nil, fmt.Errorf("-grid_in %s: found cell (%s, %s) is not on the curve", path, P.X, P.Y)
+		}
+		e.addFound(P)
+	}
+	return res, nil
+}
+
+// recount sets classified from the bitsets, after orChunk has bypassed
+// markFound and markExcl.
+func (g *Grid) recount() {
+	var found, excl [chunkWords]uint64
+	g.classified = 0
+	for ci := range (g.p*g.p + chunkSize - 1) / chunkSize {
+		g.found.chunk(ci, found[:])
+		g.excl.chunk(ci, excl[:])
+		for j := range found {
+			g.classified += bits.OnesCount64(found[j] | excl[j])
+		}
+	}
+}
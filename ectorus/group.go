@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"ectorus/internal/factor"
+)
+
+// ---------- group structure ----------
+
+// orderFactors factors a group order N for pointOrder. An incomplete
+// split is refused: the unsplit part would be stripped as if it were
+// prime and could overstate an order.
+func orderFactors(N *big.Int) ([]factor.PrimePower, error) {
+	f := factor.Of(N)
+	if !f.Complete() {
+		return nil, fmt.Errorf("could not fully factor the group order %s = %s", N, f)
+	}
+	return f.Powers, nil
+}
+
+// valuation returns the exponent of prime q in n.
+func valuation(n, q *big.Int) int {
+	v := 0
+	m := new(big.Int).Set(n)
+	r := new(big.Int)
+	for m.Sign() != 0 {
+		quo, rem := new(big.Int).QuoRem(m, q, r)
+		if rem.Sign() != 0 {
+			break
+		}
+		m = quo
+		v++
+	}
+	return v
+}
+
+// scalarMul returns kP in the coordinates -coords picked.
+func (e *Engine) scalarMul(k *big.Int, P Point) (Point, error) {
+	if e.Jacobian {
+		return e.C.ScalarMulJacobian(k, P)
+	}
+	return e.C.ScalarMul(k, P)
+}
+
+// pointOrder returns the order of P given a multiple N of it and the
+// factorisation of N: strip each prime while the cofactor still kills P.
+// mul is Curve.ScalarMul or Engine.scalarMul.
+func pointOrder(mul func(*big.Int, Point) (Point, error), P Point, N *big.Int, fs []factor.PrimePower) (*big.Int, error) {
+	ord := new(big.Int).Set(N)
+	for _, f := range fs {
+		for i := 0; i < f.E; i++ {
+			cand := new(big.Int).Quo(ord, f.Q)
+			R, err := mul(cand, P)
+			if err != nil {
+				return nil, err
+			}
+			if !R.Inf {
+				break
+			}
+			ord = cand
+		}
+	}
+	return ord, nil
+}
+
+// annotateOrders fills in Order on each output point, using the known
+// group order N (any multiple of every point order would do) and its
+// factorisation, so each order costs O(Σ e_i) scalar multiplications.
+func (e *Engine) annotateOrders(pts []Pt) error {
+	if e.KnownCount == nil {
+		return errors.New("point orders need a known group order (-count_first)")
+	}
+	N := e.KnownCount
+	fs, err := orderFactors(N)
+	if err != nil {
+		return err
+	}
+	for i := range pts {
+		if pts[i].Inf {
+			pts[i].Order = "1"
+			continue
+		}
+		x, _ := new(big.Int).SetString(pts[i].X, 10)
+		y, _ := new(big.Int).SetString(pts[i].Y, 10)
+		ord, err := pointOrder(e.scalarMul, Point{X: x, Y: y}, N, fs)
+		if err != nil {
+			return err
+		}
+		pts[i].Order = ord.String()
+	}
+	return nil
+}
+
+// GroupOut describes E(F_p) ≅ Z/n1 × Z/n2 with n1 | n2.
+type GroupOut struct {
+	N1         string `json:"n1"`
+	N2         string `json:"n2"`
+	Cyclic     bool   `json:"cyclic"`
+	Generators []Pt   `json:"generators"` // P1 of order n2, then P2 of order n1 (if n1 > 1)
+}
+
+// groupStructure determines E(F_p) ≅ Z/n1 × Z/n2 from the complete set of
+// found points. It first builds an element P1 of maximal order n2 (the group
+// exponent) by merging points of coprime order parts, then searches for P2
+// of order n1 = N/n2 with <P2> ∩ <P1> = {O}, so that E = <P1> ⊕ <P2>.
+func (e *Engine) groupStructure() (*GroupOut, error) {
+	if !e.isComplete() {
+		return nil, errors.New("group structure needs a complete enumeration")
+	}
+	c := e.C
+	N := e.KnownCount
+	fs, err := orderFactors(N)
+	if err != nil {
+		return nil, err
+	}
+
+	// P1 of maximal order
+	P1 := Point{Inf: true}
+	L := big.NewInt(1)
+	for _, Q := range e.order {
+		if L.Cmp(N) == 0 {
+			break
+		}
+		m, err := pointOrder(e.scalarMul, Q, N, fs)
+		if err != nil {
+			return nil, err
+		}
+		if new(big.Int).Rem(L, m).Sign() == 0 {
+			continue
+		}
+		// R = d1·P1 + d2·Q has order lcm(L, m): per prime, keep the side
+		// with the larger power and kill it on the other side.
+		d1, d2 := big.NewInt(1), big.NewInt(1)
+		for _, f := range fs {
+			e1, e2 := valuation(L, f.Q), valuation(m, f.Q)
+			if e1 >= e2 {
+				d2.Mul(d2, new(big.Int).Exp(f.Q, big.NewInt(int64(e2)), nil))
+			} else {
+				d1.Mul(d1, new(big.Int).Exp(f.Q, big.NewInt(int64(e1)), nil))
+			}
+		}
+		A, err := e.scalarMul(d1, P1)
+		if err != nil {
+			return nil, err
+		}
+		B, err := e.scalarMul(d2, Q)
+		if err != nil {
+			return nil, err
+		}
+		if P1, err = c.Add(A, B); err != nil {
+			return nil, err
+		}
+		L.Mul(new(big.Int).Quo(L, d1), new(big.Int).Quo(m, d2))
+	}
+	n2 := L
+	n1 := new(big.Int).Quo(N, n2)
+	g := &GroupOut{N1: n1.String(), N2: n2.String(), Cyclic: n1.Cmp(big.NewInt(1)) == 0}
+	g.Generators = append(g.Generators, toPt(P1))
+	if g.Cyclic {
+		return g, nil
+	}
+
+	// P2: order exactly n1 and independent of P1. A nontrivial intersection
+	// of <P2> with <P1> would contain the order-q element (n1/q)·P2 for some
+	// prime q | n1, and the only order-q subgroup of <P1> is <(n2/q)·P1>.
+	n1fs, err := orderFactors(n1)
+	if err != nil {
+		return nil, err
+	}
+	for _, Q := range e.order {
+		m, err := pointOrder(e.scalarMul, Q, N, fs)
+		if err != nil {
+			return nil, err
+		}
+		if m.Cmp(n1) != 0 {
+			continue
+		}
+		indep := true
+		for _, f := range n1fs {
+			T, err := e.scalarMul(new(big.Int).Quo(n1, f.Q), Q)
+			if err != nil {
+				return nil, err
+			}
+			S, err := e.scalarMul(new(big.Int).Quo(n2, f.Q), P1)
+			if err != nil {
+				return nil, err
+			}
+			if inCyclic(c, T, S, f.Q) {
+				indep = false
+				break
+			}
+		}
+		if indep {
+			g.Generators = append(g.Generators, toPt(Q))
+			return g, nil
+		}
+	}
+	return nil, fmt.Errorf("no second generator of order %s found", n1)
+}
+
+// inCyclic reports whether T is a multiple j·S, 1 ≤ j < q, of the order-q point S.
+func inCyclic(c Curve, T, S Point, q *big.Int) bool {
+	R := S
+	for j := int64(1); j < q.Int64(); j++ {
+		if R.Equal(T) {
+			return true
+		}
+		next, err := c.Add(R, S)
+		if err != nil {
+			return false
+		}
+		R = next
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"ectorus/internal/ec"
+)
+
+// ---------- stopping on the Hasse bound, without -count_first ----------
+
+// HasseOut bounds #E(F_p) when it was not counted. Hasse puts it in
+// [p+1-2√p, p+1+2√p]. The affine points pair up as ±y except the roots of
+// x^3+Ax+B, so with r roots #E ≡ 1+r (mod 2), and 4 | #E when r = 3. A walk
+// that closed (see ReachOut) has found a subgroup, whose order divides #E.
+// The walk reseeds until only one count is left and it has every point.
+type HasseOut struct {
+	Lo         string `json:"lo"` // the Hasse interval for #E
+	Hi         string `json:"hi"`
+	TwoTorsion int    `json:"twoTorsion"`         // affine points with y = 0
+	Subgroup   string `json:"subgroup,omitempty"` // found + O, when the walk closed
+	CountMin   string `json:"countMin"`           // least #E the above allow
+	CountMax   string `json:"countMax"`
+	// RemainingMax is CountMax less the points found: 0 proves the walk
+	// complete.
+	RemainingMax string `json:"remainingMax"`
+	Resolved     bool   `json:"resolved"` // CountMin = CountMax
+	Complete     bool   `json:"complete"`
+
+	lo, hi *big.Int
+}
+
+// initHasse fixes the Hasse interval and the 2-torsion of E.
+func (e *Engine) initHasse() {
+	p := e.C.P
+	w := new(big.Int).Sqrt(new(big.Int).Lsh(p, 2)) // ⌊2√p⌋
+	n := new(big.Int).Add(p, big.NewInt(1))
+	f := ec.NewPoly(p, e.C.B, e.C.A, big.NewInt(0), big.NewInt(1))
+	h := &HasseOut{lo: new(big.Int).Sub(n, w), hi: new(big.Int).Add(n, w), TwoTorsion: len(f.Roots(p))}
+	h.Lo, h.Hi = h.lo.String(), h.hi.String()
+	e.hasse = h
+}
+
+// closedSubgroup reports whether the found points and O are a subgroup: the
+// walk drew every tangent and secant and ran out of lines.
+func (e *Engine) closedSubgroup() bool {
+	return !e.capped && e.LineKinds == "" && e.SecantWindow == 0 && !e.coverageReached() && e.Budget.truncated() == ""
+}
+
+// updateHasse recomputes the bounds from the points found so far.
+func (e *Engine) updateHasse() error {
+	h := e.hasse
+	have := big.NewInt(int64(e.finiteFound() + 1))
+	lo := h.lo
+	if have.Cmp(lo) > 0 {
+		lo = have
+	}
+	step := big.NewInt(1)
+	switch h.TwoTorsion {
+	case 1:
+		step.SetInt64(2)
+	case 3:
+		step.SetInt64(4)
+	}
+	h.Subgroup = ""
+	if e.closedSubgroup() {
+		h.Subgroup = have.String()
+		g := new(big.Int).GCD(nil, nil, step, have)
+		step.Mul(step, have).Quo(step, g)
+	}
+	odd := h.TwoTorsion == 0
+	// the least and the greatest multiple of step in [lo, hi], odd when
+	// #E must be
+	nMin := new(big.Int).Add(lo, new(big.Int).Sub(step, big.NewInt(1)))
+	nMin.Quo(nMin, step).Mul(nMin, step)
+	nMax := new(big.Int).Quo(h.hi, step)
+	nMax.Mul(nMax, step)
+	if odd && nMin.Bit(0) == 0 {
+		nMin.Add(nMin, step)
+	}
+	if odd && nMax.Bit(0) == 0 {
+		nMax.Sub(nMax, step)
+	}
+	if nMin.Cmp(nMax) > 0 {
+		return fmt.Errorf("no #E in [%s, %s] is a multiple of %s: the found points are not a subgroup", lo, h.hi, step)
+	}
+	h.CountMin, h.CountMax = nMin.String(), nMax.String()
+	h.RemainingMax = new(big.Int).Sub(nMax, have).String()
+	h.Resolved = nMin.Cmp(nMax) == 0
+	h.Complete = nMax.Cmp(have) == 0
+	return nil
+}
+
+// hasseReseed reseeds a walk without a count while the bounds leave room
+// for more points. Each closed walk from a new seed at least doubles the
+// subgroup, so few are needed; a walk that did not close ends it.
+func (e *Engine) hasseReseed() error {
+	if err := e.updateHasse(); err != nil {
+		return err
+	}
+	for !e.NoReseed && !e.hasse.Complete && e.closedSubgroup() {
+		next, ok := e.findNextSeed()
+		if !ok {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "Hasse bound: up to %s points left, reseeding...\n", e.hasse.RemainingMax)
+		e.events.seed(e, next, false)
+		e.addFound(next)
+		if err := e.walk(e.MaxLines); err != nil {
+			return err
+		}
+		if err := e.updateHasse(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printHasse(w io.Writer, h *HasseOut) {
+	fmt.Fprintf(w, "\nHasse bound: #E in [%s, %s], affine 2-torsion %d", h.Lo, h.Hi, h.TwoTorsion)
+	if h.Subgroup != "" {
+		fmt.Fprintf(w, ", found a subgroup of order %s", h.Subgroup)
+	}
+	fmt.Fprintln(w)
+	switch {
+	case h.Complete:
+		fmt.Fprintf(w, "  #E = %s: the walk is complete\n", h.CountMax)
+	case h.Resolved:
+		fmt.Fprintf(w, "  #E = %s: %s points left\n", h.CountMax, h.RemainingMax)
+	default:
+		fmt.Fprintf(w, "  #E in [%s, %s]: up to %s points left\n", h.CountMin, h.CountMax, h.RemainingMax)
+	}
+}
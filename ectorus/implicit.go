@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+
+	"ectorus/internal/ec"
+)
+
+// ---------- implicit exclusions ----------
+
+// Class is what the walk knows about a lattice point.
+type Class int
+
+const (
+	ClassUnknown  Class = iota
+	ClassFound          // a curve point the walk has found
+	ClassExcluded       // on a processed line but not one of its curve points
+)
+
+func (c Class) String() string {
+	switch c {
+	case ClassFound:
+		return "found"
+	case ClassExcluded:
+		return "excluded"
+	}
+	return "unknown"
+}
+
+// implicitLines indexes the processed lines for point queries without a
+// grid: the intercepts processed for each slope, and the vertical x's.
+// A processed line's curve points are all recorded as found, so any other
+// point on it is excluded.
+type implicitLines struct {
+	slopes    map[coord]map[coord]struct{}
+	verticals map[coord]struct{}
+}
+
+func (im *implicitLines) add(L Line) {
+	if im.slopes == nil {
+		im.slopes = make(map[coord]map[coord]struct{})
+		im.verticals = make(map[coord]struct{})
+	}
+	if L.Vertical {
+		im.verticals[coordOf(L.V)] = struct{}{}
+		return
+	}
+	m := coordOf(L.M)
+	cs := im.slopes[m]
+	if cs == nil {
+		cs = make(map[coord]struct{})
+		im.slopes[m] = cs
+	}
+	cs[coordOf(L.C)] = struct{}{}
+}
+
+// covers reports whether a processed line passes through (x, y): O(number
+// of distinct slopes).
+func (im *implicitLines) covers(x, y *big.Int, f field) bool {
+	if _, ok := im.verticals[coordOf(x)]; ok {
+		return true
+	}
+	for m, cs := range im.slopes {
+		c := f.sub(y, f.mul(m.big(), x))
+		if _, ok := cs[coordOf(c)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// big converts a coord back to its value.
+func (k coord) big() *big.Int {
+	z, w := new(big.Int), new(big.Int)
+	for i := len(k) - 1; i >= 0; i-- {
+		z.Lsh(z, 64)
+		z.Or(z, w.SetUint64(k[i]))
+	}
+	return z
+}
+
+// Classify answers found, excluded or unknown for any lattice point (x, y)
+// of the torus, from the grid when there is one and from the processed
+// lines otherwise.
+func (e *Engine) Classify(x, y *big.Int) Class {
+	e.ensureMaps()
+	q := e.field().order()
+	x, y = ec.Mod(x, q), ec.Mod(y, q)
+	if _, ok := e.found[ptKey{x: coordOf(x), y: coordOf(y)}]; ok {
+		return ClassFound
+	}
+	if e.G != nil {
+		if e.G.isExcluded(int(x.Int64()), int(y.Int64())) {
+			return ClassExcluded
+		}
+		return ClassUnknown
+	}
+	if e.implicit.covers(x, y, e.field()) {
+		return ClassExcluded
+	}
+	return ClassUnknown
+}
+
+// candidateLimit bounds p for the O(p) candidate-x count.
+const candidateLimit = 10_000_000
+
+// ImplicitOut summarises the implicit exclusion structure.
+type ImplicitOut struct {
+	Slopes     int `json:"slopes"`
+	Verticals  int `json:"verticalX"`
+	CandidateX int `json:"candidateX"` // x that may still hold an unfound curve point (-1: not counted)
+}
+
+// implicitSummary counts slopes, vertical lines and the x's that could
+// still yield a new seed: not on a processed vertical, and carrying a curve
+// point not yet found.
+func (e *Engine) implicitSummary() *ImplicitOut {
+	o := &ImplicitOut{Slopes: len(e.implicit.slopes), Verticals: len(e.implicit.verticals), CandidateX: -1}
+	q := e.field().order()
+	if q.Cmp(big.NewInt(candidateLimit)) > 0 {
+		return o
+	}
+	o.CandidateX = 0
+	for x := new(big.Int); x.Cmp(q) < 0; x.Add(x, big.NewInt(1)) {
+		if _, ok := e.implicit.verticals[coordOf(x)]; ok {
+			continue
+		}
+		for _, P := range e.pointsAtX(x) {
+			if _, ok := e.found[e.pointKey(P)]; !ok {
+				o.CandidateX++
+				break
+			}
+		}
+	}
+	return o
+}
+
+func printImplicit(w io.Writer, o *ImplicitOut) {
+	fmt.Fprintf(w, "\nImplicit exclusions: %d slopes, %d vertical lines", o.Slopes, o.Verticals)
+	if o.CandidateX >= 0 {
+		fmt.Fprintf(w, ", %d candidate x left", o.CandidateX)
+	}
+	fmt.Fprintln(w)
+}
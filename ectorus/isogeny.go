@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ---------- rational ℓ-isogenies ----------
+
+// IsogenyOut is one F_p-rational ℓ-isogeny out of the walked curve: its
+// kernel is the order-ℓ subgroup generated by Kernel, and Vélu's formulas
+// give the codomain. Running ectorus on the codomain takes one step along
+// the ℓ-isogeny graph.
+type IsogenyOut struct {
+	L      int    `json:"l"`
+	Kernel Pt     `json:"kernel"`
+	A      string `json:"A"`
+	B      string `json:"B"`
+	J      string `json:"j"`
+}
+
+// parseIsogenyDegrees reads -isogenies: comma-separated primes ℓ.
+func parseIsogenyDegrees(s string) ([]int, error) {
+	var ls []int
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		l, err := strconv.Atoi(f)
+		if err != nil || l < 2 || !big.NewInt(int64(l)).ProbablyPrime(0) {
+			return nil, fmt.Errorf("-isogenies: %q is not a prime", f)
+		}
+		ls = append(ls, l)
+	}
+	if len(ls) == 0 {
+		return nil, errors.New("-isogenies: no degrees given")
+	}
+	return ls, nil
+}
+
+// isogenies lists the rational ℓ-isogenies for each ℓ in ls, reading the
+// kernels off the points the walk found (ec.IsogenyFromKernel does the
+// Vélu step): each order-ℓ subgroup among them is one isogeny.
+func (e *Engine) isogenies(ls []int) ([]IsogenyOut, error) {
+	var out []IsogenyOut
+	for _, l := range ls {
+		bl := big.NewInt(int64(l))
+		seen := make(map[string]bool)
+		for _, P := range e.sortedFound() {
+			if P.Inf || seen[P.X.String()+","+P.Y.String()] {
+				continue
+			}
+			if lP, err := e.scalarMul(bl, P); err != nil || !lP.Inf {
+				continue
+			}
+			phi, err := e.C.IsogenyFromKernel([]Point{P})
+			if err != nil {
+				return nil, err
+			}
+			for _, Q := range phi.Kernel {
+				seen[Q.X.String()+","+Q.Y.String()] = true
+			}
+			iso := IsogenyOut{L: l, Kernel: toPt(P), A: phi.Codomain.A.String(), B: phi.Codomain.B.String()}
+			if j, err := phi.Codomain.JInvariant(); err == nil {
+				iso.J = j.String()
+			}
+			out = append(out, iso)
+		}
+	}
+	return out, nil
+}
+
+// isogenyNote is the printHuman line for one isogeny.
+func isogenyNote(iso IsogenyOut) string {
+	return fmt.Sprintf("%d-isogeny with kernel <(%s, %s)> → y^2 = x^3 + %s x + %s (j = %s)",
+		iso.L, iso.Kernel.X, iso.Kernel.Y, iso.A, iso.B, iso.J)
+}
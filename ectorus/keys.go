@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// ---------- map keys ----------
+
+// maxKeyBits bounds p: coordinates must fit a coord.
+const maxKeyBits = 256
+
+// coord is a reduced field element as a comparable map key: its 64-bit
+// words, least significant first. Building one allocates nothing and hashing
+// it is a plain memory hash, unlike the formatted strings the engine maps
+// used to be keyed by.
+type coord [maxKeyBits / 64]uint64
+
+func coordOf(x *big.Int) coord {
+	var k coord
+	if x.BitLen() > maxKeyBits {
+		panic("ectorus: coordinate wider than maxKeyBits")
+	}
+	for i, w := range x.Bits() {
+		if bits.UintSize == 64 {
+			k[i] = uint64(w)
+		} else {
+			k[i/2] |= uint64(w) << (32 * uint(i%2))
+		}
+	}
+	return k
+}
+
+// less orders coords by value (for canonical pair keys).
+func (a coord) less(b coord) bool {
+	for i := len(a) - 1; i >= 0; i-- {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// ptKey identifies a point; O has inf set and zero coordinates.
+type ptKey struct {
+	x, y coord
+	inf  bool
+}
+
+func (a ptKey) less(b ptKey) bool {
+	if a.inf != b.inf {
+		return b.inf
+	}
+	if a.x != b.x {
+		return a.x.less(b.x)
+	}
+	return a.y.less(b.y)
+}
+
+// pairKey is an unordered pair of points, stored smaller first.
+type pairKey struct{ a, b ptKey }
+
+// lineKey identifies y = m x + c, or x = m when vertical.
+type lineKey struct {
+	vertical bool
+	m, c     coord
+}
+
+// cell is a grid position.
+type cell struct{ x, y int }
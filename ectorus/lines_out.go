@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math/big"
+
+	"ectorus/internal/ec"
+)
+
+// ---------- -lines_out: processed lines as NDJSON ----------
+
+// LineRecord is one processed line of the walk.
+type LineRecord struct {
+	Line      int    `json:"line"`
+	Kind      string `json:"kind"`                     // tangent|secant|vertical|auto-vertical (-verticals)|sweep (-sweep_lines)
+	M         string `json:"m,omitempty"`              // y = m x + c
+	C         string `json:"c,omitempty"`              //
+	X         string `json:"x,omitempty"`              // x = X, for a vertical line
+	Intersect []Pt   `json:"intersections"`            // the curve points on the line (O for a vertical)
+	NewPoints int    `json:"new_points"`               // found for the first time
+	NewExcl   *int   `json:"new_exclusions,omitempty"` // cells newly excluded (-grid only)
+}
+
+// lineWriter streams a LineRecord per processed line.
+type lineWriter struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+func newLineWriter(w io.Writer) *lineWriter {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	return &lineWriter{bw: bw, enc: enc}
+}
+
+func (lw *lineWriter) write(e *Engine, n int, r lineRow) error {
+	rec := LineRecord{Line: n, Kind: r.kind(), NewPoints: r.NewPoints, Intersect: []Pt{}}
+	if r.L.Vertical {
+		rec.X = r.L.V.String()
+	} else {
+		rec.M, rec.C = r.L.M.String(), r.L.C.String()
+	}
+	for _, S := range r.Points {
+		rec.Intersect = append(rec.Intersect, toPt(S))
+	}
+	if e.UseGrid {
+		n := r.NewExcl
+		rec.NewExcl = &n
+	}
+	return lw.enc.Encode(rec)
+}
+
+func (lw *lineWriter) flush() error { return lw.bw.Flush() }
+
+// onLine reports whether S lies on L; O lies on every vertical line.
+func onLine(L Line, S Point, p *big.Int) bool {
+	if S.Inf {
+		return L.Vertical
+	}
+	if L.Vertical {
+		return S.X.Cmp(L.V) == 0
+	}
+	y := new(big.Int).Mul(L.M, S.X)
+	y.Add(y, L.C)
+	return ec.Mod(y, p).Cmp(S.Y) == 0
+}
+
+// lineMeets returns the distinct candidates that lie on L, plus O when L
+// is vertical.
+func lineMeets(L Line, p *big.Int, candidates ...[]Point) []Point {
+	var out []Point
+	seen := map[ptKey]bool{}
+	for _, pts := range candidates {
+		for _, S := range pts {
+			k := ptKey{inf: true}
+			if !S.Inf {
+				k = ptKey{x: coordOf(S.X), y: coordOf(S.Y)}
+			}
+			if seen[k] || !onLine(L, S, p) {
+				continue
+			}
+			seen[k] = true
+			out = append(out, S)
+		}
+	}
+	if L.Vertical && !seen[ptKey{inf: true}] {
+		out = append(out, Point{Inf: true})
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ---------- batch manifests ----------
+
+// ecgenFields are the annotations cmd/ecgen writes next to p, A and B; a
+// manifest may carry them, but they do not change the run.
+var ecgenFields = map[string]bool{"pointCount": true, "trace": true, "cofactor": true, "order": true}
+
+// UnmarshalJSON lets manifest values be JSON numbers or strings (hex and
+// numbers beyond float64 need strings), and ainvs a plain JSON array; k is
+// a JSON number.
+func (c *curveSpec) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for key, dst := range map[string]*string{
+		"p": &c.P, "A": &c.A, "B": &c.B, "form": &c.Form, "ainvs": &c.Ainvs, "seed_x": &c.SeedX, "curve": &c.Curve,
+	} {
+		v, ok := raw[key]
+		if !ok {
+			continue
+		}
+		delete(raw, key)
+		if bytes.HasPrefix(v, []byte(`"`)) {
+			if err := json.Unmarshal(v, dst); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			continue
+		}
+		*dst = string(v)
+	}
+	if v, ok := raw["k"]; ok {
+		delete(raw, "k")
+		if err := json.Unmarshal(v, &c.K); err != nil {
+			return fmt.Errorf("k: %w", err)
+		}
+	}
+	for key := range raw {
+		if ecgenFields[key] {
+			continue
+		}
+		return fmt.Errorf("unknown manifest field %q", key)
+	}
+	return nil
+}
+
+// loadManifest reads a JSON array of curves; entries without a form get
+// defaultForm (the -form flag).
+func loadManifest(path, defaultForm string) ([]curveSpec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var specs []curveSpec
+	if err := json.Unmarshal(b, &specs); err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", path, err)
+	}
+	for i := range specs {
+		if specs[i].Curve != "" {
+			if specs[i].P != "" || specs[i].A != "" || specs[i].B != "" || specs[i].Form != "" || specs[i].Ainvs != "" {
+				return nil, fmt.Errorf("manifest %s: curve %d names a curve and also gives p, A, B, form or ainvs", path, i)
+			}
+			continue
+		}
+		if strings.TrimSpace(specs[i].P) == "" {
+			return nil, fmt.Errorf("manifest %s: curve %d has no p or curve", path, i)
+		}
+		if specs[i].A == "" {
+			specs[i].A = "0"
+		}
+		if specs[i].B == "" {
+			specs[i].B = "0"
+		}
+		if specs[i].Form == "" {
+			specs[i].Form = defaultForm
+		}
+	}
+	return specs, nil
+}
+
+// runManifest runs every spec with up to parallel at a time, returning the
+// results in manifest order; a failing curve yields an Out with Error set
+// rather than stopping the batch.
+func runManifest(specs []curveSpec, o runOpts, parallel int) []Out {
+	if parallel < 1 {
+		parallel = 1
+	}
+	outs := make([]Out, len(specs))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fmt.Fprintf(os.Stderr, "Manifest curve %d/%d (p=%s)...\n", i+1, len(specs), spec.P)
+			out, err := runCurve(spec, o)
+			if err != nil {
+				out = Out{P: spec.P, A: spec.A, B: spec.B, Error: err.Error()}
+			}
+			outs[i] = out
+		}()
+	}
+	wg.Wait()
+	return outs
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// ---------- -out / -format ----------
+
+// output is where the results go (-out, "-" for stdout) and in which
+// -format: text (printHuman), json (the Out document), csv (the found
+// points) or ndjson (one found point per line as the walk finds it, then
+// the result without its points).
+type output struct {
+	format string
+	f      *os.File // nil for stdout
+	bw     *bufio.Writer
+	err    error // first failed point write
+}
+
+func openOutput(path, format string) (*output, error) {
+	switch format {
+	case "text", "json", "csv", "ndjson":
+	default:
+		return nil, fmt.Errorf("unknown -format %q (want text|json|ndjson|csv)", format)
+	}
+	o := &output{format: format}
+	w := io.Writer(os.Stdout)
+	if path != "" && path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		o.f, w = f, f
+	}
+	o.bw = bufio.NewWriter(w)
+	return o, nil
+}
+
+// streams reports whether points go out as the walk finds them.
+func (o *output) streams() bool { return o.format == "ndjson" }
+
+// point writes one found point as an NDJSON line and flushes it, so a
+// reader following the file sees it at once.
+func (o *output) point(pt Pt) {
+	if o.err != nil {
+		return
+	}
+	b, err := json.Marshal(pt)
+	if err == nil {
+		o.bw.Write(append(b, '\n'))
+		err = o.bw.Flush()
+	}
+	o.err = err
+}
+
+// ndjsonResult is the last NDJSON record of a curve.
+type ndjsonResult struct {
+	Result Out `json:"result"`
+}
+
+// result writes one curve's result. streamed says its points have
+// already gone out through point.
+func (o *output) result(out Out, streamed bool) error {
+	switch o.format {
+	case "json":
+		enc := json.NewEncoder(o.bw)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	case "ndjson":
+		if !streamed {
+			for _, pt := range out.Found {
+				o.point(pt)
+			}
+		}
+		out.Found = nil
+		return json.NewEncoder(o.bw).Encode(ndjsonResult{out})
+	case "csv":
+		return o.csv([]Out{out}, false)
+	}
+	printHuman(o.bw, out)
+	return nil
+}
+
+// results writes a -manifest's results, in manifest order.
+func (o *output) results(outs []Out) error {
+	switch o.format {
+	case "json":
+		enc := json.NewEncoder(o.bw)
+		enc.SetIndent("", "  ")
+		return enc.Encode(outs)
+	case "ndjson":
+		enc := json.NewEncoder(o.bw)
+		for _, out := range outs {
+			if err := enc.Encode(ndjsonResult{out}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		return o.csv(outs, true)
+	}
+	for i, out := range outs {
+		if i > 0 {
+			fmt.Fprintln(o.bw, "\n----------------------------------------")
+		}
+		if out.Error != "" {
+			fmt.Fprintf(o.bw, "Curve %d (p = %s): error: %s\n", i, out.P, out.Error)
+			continue
+		}
+		printHuman(o.bw, out)
+	}
+	return nil
+}
+
+// csv writes the affine points found, one row each; order and sec1
+// columns are added when -orders or -sec1 filled them in, and with a
+// manifest each row leads with its curve's index and p.
+func (o *output) csv(outs []Out, manifest bool) error {
+	var orders, sec1 bool
+	for _, out := range outs {
+		for _, pt := range out.Found {
+			orders = orders || pt.Order != ""
+			sec1 = sec1 || pt.SEC1 != ""
+		}
+	}
+	w := csv.NewWriter(o.bw)
+	row := func(lead []string, x, y, ord, enc string) {
+		r := append(lead, x, y)
+		if orders {
+			r = append(r, ord)
+		}
+		if sec1 {
+			r = append(r, enc)
+		}
+		w.Write(r)
+	}
+	var lead []string
+	if manifest {
+		lead = []string{"curve", "p"}
+	}
+	row(lead, "x", "y", "order", "sec1")
+	for i, out := range outs {
+		if manifest {
+			lead = []string{strconv.Itoa(i), out.P}
+		}
+		for _, pt := range out.Found {
+			if !pt.Inf {
+				row(lead, pt.X, pt.Y, pt.Order, pt.SEC1)
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (o *output) close() error {
+	err := o.err
+	if ferr := o.bw.Flush(); err == nil {
+		err = ferr
+	}
+	if o.f != nil {
+		if cerr := o.f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+
+	"ectorus/internal/ec"
+	"ectorus/internal/ptfile"
+)
+
+// ---------- -points_in: known points as the starting set ----------
+
+// knownPoint is one point of a -points_in file; at says where it was, for
+// errors.
+type knownPoint struct {
+	X, Y *big.Int
+	Inf  bool
+	at   string
+}
+
+// PointsInOut reports the points a -points_in file supplied.
+type PointsInOut struct {
+	File       string `json:"file"`
+	Points     int    `json:"points"`     // on the curve, O included
+	Duplicates int    `json:"duplicates"` // given more than once
+}
+
+// loadPointsIn reads the points of path. A file starting with '{' is JSON:
+// an ectorus -format json result, ectorus -format ndjson points and result,
+// or ecscan --format=ndjson records. Anything else is an ecscan dump as
+// ptfile reads it (text, csv or compressed, possibly gzip or zstd), the
+// compressed form decoded on y^2 = x^3 + Ax + B mod p.
+func loadPointsIn(path string, p, A, B *big.Int) ([]knownPoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	for {
+		c, err := br.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if c[0] != ' ' && c[0] != '\t' && c[0] != '\r' && c[0] != '\n' {
+			break
+		}
+		br.ReadByte()
+	}
+	if c, _ := br.Peek(1); c[0] == '{' || c[0] == 'n' || c[0] == '"' { // O as null or "inf" may come first
+		pts, err := jsonPoints(br)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return pts, nil
+	}
+	rd, err := ptfile.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer rd.Close()
+	if m := rd.Meta(); m != nil && m.P != nil && m.P.Cmp(p) != 0 {
+		return nil, fmt.Errorf("%s: the dump is for p = %s, not %s", path, m.P, p)
+	}
+	if rd.Meta() == nil || rd.Meta().P == nil {
+		rd.SetCurve(p, A, B)
+	}
+	var pts []knownPoint
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			return pts, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		at := fmt.Sprintf("%s:%d", path, rec.Line)
+		if len(rec.Coords) == 4 {
+			return nil, fmt.Errorf("%s: a point over F_{p^2}; -points_in reads points over F_p", at)
+		}
+		kp := knownPoint{Inf: rec.Inf, at: at}
+		if !rec.Inf {
+			kp.X, kp.Y = rec.Coords[0], rec.Coords[1]
+		}
+		pts = append(pts, kp)
+	}
+}
+
+// jsonPoints reads a stream of JSON values: result documents (their found
+// points), {"result": ...} records and single points, skipping ecscan's
+// meta and end records.
+func jsonPoints(r io.Reader) ([]knownPoint, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var pts []knownPoint
+	for n := 1; ; n++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return pts, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("record %d: %w", n, err)
+		}
+		at := fmt.Sprintf("record %d", n)
+		if isInfJSON(raw) { // -inf_style null or string
+			pts = append(pts, knownPoint{Inf: true, at: at})
+			continue
+		}
+		var v struct {
+			X, Y   json.RawMessage
+			Inf    bool   `json:"inf"`
+			Type   string `json:"type"`
+			Found  []Pt   `json:"found"`
+			Result *struct {
+				Found []Pt `json:"found"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("%s: %w", at, err)
+		}
+		found := v.Found
+		if v.Result != nil {
+			found = v.Result.Found
+		}
+		switch {
+		case v.Type != "": // ecscan's meta and end records
+		case found != nil:
+			for i, pt := range found {
+				kp := knownPoint{Inf: pt.Inf, at: fmt.Sprintf("%s, point %d", at, i+1)}
+				if !pt.Inf {
+					var err error
+					if kp.X, err = ec.ParseBig(pt.X); err == nil {
+						kp.Y, err = ec.ParseBig(pt.Y)
+					}
+					if err != nil {
+						return nil, fmt.Errorf("%s: %w", kp.at, err)
+					}
+				}
+				pts = append(pts, kp)
+			}
+		case v.Inf:
+			pts = append(pts, knownPoint{Inf: true, at: at})
+		case v.X != nil && v.Y != nil:
+			kp := knownPoint{at: at}
+			var err error
+			if kp.X, err = jsonCoord(v.X); err == nil {
+				kp.Y, err = jsonCoord(v.Y)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", at, err)
+			}
+			pts = append(pts, kp)
+		case v.Result == nil:
+			return nil, fmt.Errorf("%s: want a point, a result or a {\"result\": ...} record", at)
+		}
+	}
+}
+
+// jsonCoord reads a coordinate written as a number (ecscan) or a string
+// (ectorus).
+func jsonCoord(raw json.RawMessage) (*big.Int, error) {
+	s := string(raw)
+	if u, err := strconv.Unquote(s); err == nil {
+		s = u
+	}
+	if len(s) > 0 && s[0] == '[' {
+		return nil, errors.New("a coordinate over F_{p^2}; -points_in reads points over F_p")
+	}
+	return ec.ParseBig(s)
+}
+
+// preload adds the known points to the found set before the walk, so its
+// lines start from them. Every point must lie on the curve.
+func (e *Engine) preload(path string, pts []knownPoint) (*PointsInOut, error) {
+	res := &PointsInOut{File: path}
+	p := e.field().order()
+	for _, kp := range pts {
+		P := Point{Inf: true}
+		if !kp.Inf {
+			P = Point{X: ec.Mod(kp.X, p), Y: ec.Mod(kp.Y, p)}
+			if !e.onCurve(P) {
+				return nil, fmt.Errorf("-points_in %s: (%s, %s) is not on the curve", kp.at, kp.X, kp.Y)
+			}
+		}
+		if e.addFound(P) {
+			res.Points++
+		} else {
+			res.Duplicates++
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"ectorus/internal/ec"
+)
+
+// ---------- -query: ask the engine about lattice points ----------
+
+// QueryOut answers one -query point after the walk.
+type QueryOut struct {
+	X       string `json:"x"`
+	Y       string `json:"y"`
+	Class   Class  `json:"class"`
+	OnCurve bool   `json:"onCurve"` // an unknown point on the curve is one the walk has yet to find
+}
+
+func (c Class) MarshalText() ([]byte, error) { return []byte(c.String()), nil }
+
+// parseQueries reads -query: points "x,y" separated by spaces or
+// semicolons, each coordinate as ec.ParseBig reads it.
+func parseQueries(s string) ([][2]*big.Int, error) {
+	var qs [][2]*big.Int
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ';' }) {
+		xs, ys, ok := strings.Cut(f, ",")
+		if !ok {
+			return nil, fmt.Errorf("-query: %q is not x,y", f)
+		}
+		x, err := ec.ParseBig(xs)
+		if err != nil {
+			return nil, fmt.Errorf("-query %q: %w", f, err)
+		}
+		y, err := ec.ParseBig(ys)
+		if err != nil {
+			return nil, fmt.Errorf("-query %q: %w", f, err)
+		}
+		qs = append(qs, [2]*big.Int{x, y})
+	}
+	if len(qs) == 0 {
+		return nil, errors.New("-query: no points given")
+	}
+	return qs, nil
+}
+
+// query classifies each point with Classify, reduced mod p as Classify
+// reduces it.
+func (e *Engine) query(qs [][2]*big.Int) []QueryOut {
+	p := e.field().order()
+	var out []QueryOut
+	for _, q := range qs {
+		x, y := ec.Mod(q[0], p), ec.Mod(q[1], p)
+		out = append(out, QueryOut{X: x.String(), Y: y.String(), Class: e.Classify(x, y), OnCurve: e.onCurve(Point{X: x, Y: y})})
+	}
+	return out
+}
+
+func printQueries(w io.Writer, qs []QueryOut) {
+	fmt.Fprintln(w, "\nQueries:")
+	for _, q := range qs {
+		on := "off the curve"
+		if q.OnCurve {
+			on = "on the curve"
+		}
+		fmt.Fprintf(w, "  (%s, %s): %s, %s\n", q.X, q.Y, q.Class, on)
+	}
+}
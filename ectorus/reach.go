@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// ---------- -no_reseed: what one seed reaches ----------
+
+// ReachOut describes the set a walk from a single seed P reached. Chords
+// and tangents through multiples of P only ever yield multiples of P, and
+// tangents give -2P and 2P, so once the walk closes the set is the cyclic
+// subgroup <P> (a subgroup, not a coset: it holds O and every inverse).
+type ReachOut struct {
+	Seed    Pt     `json:"seed"`
+	Reached int    `json:"reachedAffine"`          // affine points found
+	Closed  bool   `json:"closed"`                 // the walk ran out of lines, not into -max_lines, -stop_at_coverage or a budget
+	Size    string `json:"subgroupSize,omitempty"` // reached + O (weierstrass)
+	Order   string `json:"seedOrder,omitempty"`    // ord(P), from the known count
+	Index   string `json:"index,omitempty"`        // #E / |<P>|
+}
+
+// reachability summarises a walk made without reseeding.
+func (e *Engine) reachability() (*ReachOut, error) {
+	if len(e.order) == 0 {
+		return nil, nil
+	}
+	r := &ReachOut{
+		Seed:    toPt(e.order[0]),
+		Reached: e.finiteFound(),
+		Closed:  !e.capped && !e.coverageReached() && e.Budget.truncated() == "",
+	}
+	// a walk of one line kind closes before it has the whole subgroup
+	if e.Model != nil || !r.Closed || e.LineKinds != "" {
+		return r, nil
+	}
+	size := big.NewInt(int64(r.Reached + 1))
+	r.Size = size.String()
+	if e.KnownCount == nil {
+		return r, nil
+	}
+	// with #E only partly factored, report the subgroup without ord P
+	fs, err := orderFactors(e.KnownCount)
+	if err != nil {
+		return r, nil
+	}
+	ord, err := pointOrder(e.scalarMul, e.order[0], e.KnownCount, fs)
+	if err != nil {
+		return nil, err
+	}
+	r.Order = ord.String()
+	idx, rem := new(big.Int).QuoRem(e.KnownCount, size, new(big.Int))
+	if rem.Sign() == 0 {
+		r.Index = idx.String()
+	}
+	return r, nil
+}
+
+func printReach(w io.Writer, r *ReachOut) {
+	fmt.Fprintf(w, "\nReachability from seed (%s, %s) without reseeding: %d affine points", r.Seed.X, r.Seed.Y, r.Reached)
+	if !r.Closed {
+		fmt.Fprintln(w, " (stopped early)")
+		return
+	}
+	fmt.Fprintln(w)
+	if r.Size != "" {
+		fmt.Fprintf(w, "  subgroup <P> of size %s", r.Size)
+		if r.Order != "" {
+			fmt.Fprintf(w, " (ord P = %s), index %s in E(F_p)", r.Order, r.Index)
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"ectorus/internal/ec"
+)
+
+// ---------- -interactive: a prompt over one engine ----------
+
+const replHelp = `commands:
+  seed [X [Y]]        add a seed: the next one by -seed_strategy (-seed_x first), the point at X, or (X, Y)
+  step [N]            process N more lines from the found points (default 1)
+  run                 walk and reseed until complete (needs a known count) or out of seeds
+  grid [X Y [W [H]]]  show the torus from (X, Y), W×H cells (default 0 0 and up to 48×24):
+                      # found, . excluded, blank unknown
+  stats               lines, points and coverage so far
+  json                the current state as the -json result
+  help                this list
+  quit                leave, and write the result as usual (so does end of input)`
+
+// replGridW and replGridH are the default grid window.
+const (
+	replGridW = 48
+	replGridH = 24
+)
+
+// repl reads commands from r and answers on w until quit or end of input,
+// so a walk can be seeded, stepped and inspected a piece at a time. A and
+// B are the curve's coefficients as given, for json. It returns the
+// distinct lines processed, as run does.
+func (e *Engine) repl(r io.Reader, w io.Writer, seedX, A, B *big.Int) (int, error) {
+	e.ensureMaps()
+	fmt.Fprintf(w, "ectorus -interactive: p = %s", e.C.P)
+	if t := e.affineTarget(); t != nil {
+		fmt.Fprintf(w, ", %s affine points to find", t)
+	}
+	fmt.Fprintln(w, "; \"help\" lists the commands")
+	seeded := false
+	sc := bufio.NewScanner(r)
+	for {
+		fmt.Fprint(w, "> ")
+		if !sc.Scan() {
+			fmt.Fprintln(w)
+			break
+		}
+		args := strings.Fields(sc.Text())
+		if len(args) == 0 {
+			continue
+		}
+		var err error
+		switch cmd, args := args[0], args[1:]; cmd {
+		case "seed":
+			err = e.replSeed(w, args, seedX, !seeded)
+			seeded = seeded || err == nil
+		case "step":
+			err = e.replStep(w, args)
+		case "run":
+			err = e.replRun(w, args)
+		case "grid":
+			err = e.replGrid(w, args)
+		case "stats":
+			e.replStats(w)
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(e.snapshot(A, B))
+		case "help", "?":
+			fmt.Fprintln(w, replHelp)
+		case "quit", "exit", "q":
+			return len(e.linesDone), sc.Err()
+		default:
+			err = fmt.Errorf("unknown command %q (help lists them)", cmd)
+		}
+		if err != nil {
+			fmt.Fprintln(w, "error:", err)
+		}
+	}
+	return len(e.linesDone), sc.Err()
+}
+
+// replSeed adds a seed point: the one named by args, else the next one the
+// engine would pick.
+func (e *Engine) replSeed(w io.Writer, args []string, seedX *big.Int, fromX bool) error {
+	var P Point
+	switch len(args) {
+	case 0:
+		ok := false
+		if fromX {
+			P, ok = e.findNextSeedFromX(seedX)
+		} else {
+			P, ok = e.findNextSeed()
+		}
+		if !ok {
+			return errors.New("no seed found")
+		}
+	case 1, 2:
+		vs := make([]*big.Int, len(args))
+		for i, a := range args {
+			v, err := ec.ParseBig(a)
+			if err != nil {
+				return err
+			}
+			vs[i] = ec.Mod(v, e.field().order())
+		}
+		pts := e.pointsAtX(vs[0])
+		if len(vs) == 2 {
+			P = Point{X: vs[0], Y: vs[1]}
+			if !e.onCurve(P) {
+				return fmt.Errorf("(%s, %s) is not on the curve", P.X, P.Y)
+			}
+		} else if len(pts) == 0 {
+			return fmt.Errorf("no curve point has x = %s", vs[0])
+		} else {
+			P = pts[0]
+		}
+	default:
+		return errors.New("usage: seed [X [Y]]")
+	}
+	first := len(e.order) == 0
+	if !e.addFound(P) {
+		fmt.Fprintf(w, "(%s, %s) is already found\n", P.X, P.Y)
+		return nil
+	}
+	e.events.seed(e, P, first)
+	fmt.Fprintf(w, "seed (%s, %s); %d points\n", P.X, P.Y, len(e.order))
+	return nil
+}
+
+// replStep processes N more lines, as -max_lines counts them.
+func (e *Engine) replStep(w io.Writer, args []string) error {
+	n := 1
+	if len(args) > 1 {
+		return errors.New("usage: step [N]")
+	}
+	if len(args) == 1 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+			return fmt.Errorf("bad line count %q", args[0])
+		}
+	}
+	lines, points := len(e.linesDone), len(e.order)
+	if err := e.walk(n); err != nil {
+		return err
+	}
+	e.replProgress(w, lines, points)
+	return nil
+}
+
+// replRun walks to the end, reseeding as run does.
+func (e *Engine) replRun(w io.Writer, args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: run")
+	}
+	if len(e.order) == 0 {
+		return errors.New("no points yet; add a seed first")
+	}
+	lines, points := len(e.linesDone), len(e.order)
+	if err := e.walk(e.MaxLines); err != nil {
+		return err
+	}
+	for !e.NoReseed && e.KnownCount != nil && !e.isComplete() && !e.coverageReached() && !e.Budget.exceeded() {
+		next, ok := e.findNextSeed()
+		if !ok {
+			break
+		}
+		e.events.seed(e, next, false)
+		e.addFound(next)
+		if err := e.walk(e.MaxLines); err != nil {
+			return err
+		}
+	}
+	e.replProgress(w, lines, points)
+	return nil
+}
+
+// replProgress reports what a step or run added.
+func (e *Engine) replProgress(w io.Writer, lines, points int) {
+	dl, dp := len(e.linesDone)-lines, len(e.order)-points
+	fmt.Fprintf(w, "%d lines, %d new points; %d lines and %d points in all", dl, dp, len(e.linesDone), len(e.order))
+	switch {
+	case e.isComplete():
+		fmt.Fprint(w, " (complete)")
+	case dl == 0 && dp == 0:
+		fmt.Fprint(w, " (no lines left from the found points; add a seed)")
+	}
+	fmt.Fprintln(w)
+}
+
+// replGrid draws a window of the torus, highest y at the top.
+func (e *Engine) replGrid(w io.Writer, args []string) error {
+	if e.F != nil {
+		return errors.New("grid shows F_p only, not -k > 1")
+	}
+	if len(args) == 1 || len(args) > 4 {
+		return errors.New("usage: grid [X Y [W [H]]]")
+	}
+	p := e.C.P
+	if !p.IsInt64() {
+		return errors.New("grid needs p < 2^63")
+	}
+	pn := p.Int64()
+	v := []int64{0, 0, min(pn, replGridW), min(pn, replGridH)}
+	for i, a := range args {
+		n, err := strconv.ParseInt(a, 10, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("bad grid argument %q", a)
+		}
+		v[i] = n
+	}
+	x0, y0, gw, gh := v[0], v[1], min(v[2], pn), min(v[3], pn)
+	if x0 >= pn || y0 >= pn || gw < 1 || gh < 1 {
+		return fmt.Errorf("the window must start in [0, %d) and be at least 1×1", pn)
+	}
+	if gw*gh > 1<<16 {
+		return errors.New("window too large (at most 65536 cells)")
+	}
+	label := len(strconv.FormatInt(pn-1, 10))
+	X, Y := new(big.Int), new(big.Int)
+	row := make([]byte, gw)
+	for j := gh - 1; j >= 0; j-- {
+		y := (y0 + j) % pn
+		Y.SetInt64(y)
+		for i := range gw {
+			X.SetInt64((x0 + i) % pn)
+			switch e.Classify(X, Y) {
+			case ClassFound:
+				row[i] = '#'
+			case ClassExcluded:
+				row[i] = '.'
+			default:
+				row[i] = ' '
+			}
+		}
+		fmt.Fprintf(w, "%*d |%s|\n", label, y, row)
+	}
+	fmt.Fprintf(w, "%*s  x = %d..%d\n", label, "", x0, (x0+gw-1)%pn)
+	return nil
+}
+
+// replStats prints the walk's progress so far.
+func (e *Engine) replStats(w io.Writer) {
+	fmt.Fprintf(w, "lines processed: %d\n", len(e.linesDone))
+	fmt.Fprintf(w, "points found: %d affine", len(e.order))
+	if t := e.affineTarget(); t != nil {
+		fmt.Fprintf(w, " of %s", t)
+	}
+	fmt.Fprintf(w, " (complete: %v)\n", e.isComplete())
+	if c := e.coverage(); c != nil {
+		fmt.Fprintf(w, "grid: %d / %d cells classified (%.2f%%)\n", c.Classified, c.Cells, 100*c.Fraction)
+	} else {
+		fmt.Fprintf(w, "implicit exclusions: %d slopes, %d vertical lines\n", len(e.implicit.slopes), len(e.implicit.verticals))
+	}
+	s := e.stats.summary()
+	fmt.Fprintf(w, "walk: %d lines met again, %d new points from lines, %.3fs\n", s.DuplicateLines, s.NewPoints, s.Seconds)
+}
+
+// snapshot is the engine's state as a result: the curve (A and B as
+// given), the count when known, the points found so far and the walk's
+// statistics.
+func (e *Engine) snapshot(A, B *big.Int) Out {
+	out := Out{
+		P:        e.C.P.String(),
+		A:        e.C.A.String(),
+		B:        e.C.B.String(),
+		Complete: e.isComplete(),
+		Lines:    len(e.linesDone),
+		Coverage: e.coverage(),
+		Stats:    e.stats.summary(),
+		Found:    []Pt{},
+	}
+	out.describeCurve(e, A, B)
+	if e.KnownCount != nil {
+		out.KnownCount = e.KnownCount.String()
+	}
+	ext, _ := e.F.(*extField)
+	for _, P := range e.sortedFound() {
+		pt := toPt(P)
+		if ext != nil && !P.Inf {
+			pt.X, pt.Y = ext.format(P.X), ext.format(P.Y)
+		}
+		out.Found = append(out.Found, pt)
+	}
+	return out
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"ectorus/internal/ec"
+	"ectorus/internal/prime"
+)
+
+// ---------- composite p: E(Z/nZ) by CRT ----------
+
+// maxRingPoints caps the affine points -ring lists; past it only the counts
+// are reported.
+const maxRingPoints = 1 << 20
+
+// RingOut describes a -ring run over Z/nZ, n = Π q^e.
+type RingOut struct {
+	Factors []RingFactor `json:"factors"`
+	Affine  string       `json:"affinePoints"` // affine points mod n: Π over the factors
+	Listed  bool         `json:"listed"`       // found holds all of them
+}
+
+// RingFactor is the walk over F_q behind one prime-power factor q^e of n.
+type RingFactor struct {
+	Q          string `json:"q"`
+	E          int    `json:"e"`
+	PointCount string `json:"pointCount"`   // #E(F_q)
+	Affine     string `json:"affinePoints"` // affine points mod q^e: q^(e-1)·(#E(F_q) - 1)
+	Complete   bool   `json:"complete"`
+	Lines      int    `json:"linesProcessed"`
+}
+
+// runRing enumerates E over Z/nZ for composite n. The chord-and-tangent law
+// needs inverses that Z/nZ lacks, so the walk runs over F_q for each prime
+// q | n instead. Hensel's lemma lifts each affine point mod q to q^(e-1)
+// points mod q^e (in x, or in y where y ≡ 0), and the CRT glues one point
+// per factor into each affine point mod n. #E(Z/nZ) = Π q^(e-1)·#E(F_q),
+// counting the points that are at infinity mod some q but not all.
+func runRing(spec curveSpec, n, A, B *big.Int, o runOpts) (Out, error) {
+	switch {
+	case spec.Ainvs != "" || (spec.Form != "" && spec.Form != "weierstrass" && spec.Form != "w"):
+		return Out{}, errors.New("-ring needs -form weierstrass")
+	case o.RequirePrime || o.ProvePrime:
+		return Out{}, errors.New("-ring walks a composite p; drop -require_prime and -prove_prime")
+	case o.Orders || o.Group || o.Twist || o.CrossCheck || o.SEC1 || o.NoReseed:
+		return Out{}, errors.New("-ring does not combine with -orders, -group, -twist, -crosscheck, -sec1 or -no_reseed")
+	case o.StatsPath != "" || o.LinesOut != "" || o.GraphPath != "" || o.Events != "":
+		return Out{}, errors.New("-stats, -lines_out, -graph and -events write one walk; -ring runs one per prime factor")
+	}
+	fs, rest := prime.Factor(n)
+	if rest.Cmp(big.NewInt(1)) != 0 {
+		return Out{}, fmt.Errorf("-ring: cannot factor p = %s (left with %s)", n, rest)
+	}
+	A, B = ec.Mod(A, n), ec.Mod(B, n)
+	out := Out{P: n.String(), A: A.String(), B: B.String(), Complete: true, Ring: &RingOut{}}
+	total, affine := big.NewInt(1), big.NewInt(1)
+	var mods []*big.Int
+	var lifted [][]Point
+	sub := o
+	sub.Ring, sub.FactorP, sub.CountFirst = false, false, true
+	sub.Stream = nil // the points mod q are not the result's
+	for i := 0; i < len(fs); {
+		q := fs[i]
+		e := 0
+		for ; i < len(fs) && fs[i].Cmp(q) == 0; i++ {
+			e++
+		}
+		if q.Cmp(big.NewInt(3)) <= 0 {
+			return Out{}, fmt.Errorf("-ring: p = %s has the factor %s; y^2 = x^3 + Ax + B is no model in characteristic 2 or 3", n, q)
+		}
+		fmt.Fprintf(os.Stderr, "Walking the factor %s^%d...\n", q, e)
+		qo, err := runCurve(curveSpec{P: q.String(), A: ec.Mod(A, q).String(), B: ec.Mod(B, q).String(), SeedX: spec.SeedX}, sub)
+		if err != nil {
+			return Out{}, fmt.Errorf("-ring: mod %s: %w", q, err)
+		}
+		qe := new(big.Int).Exp(q, big.NewInt(int64(e)), nil)
+		lift := new(big.Int).Quo(qe, q) // q^(e-1) lifts per point
+		N, _ := new(big.Int).SetString(qo.KnownCount, 10)
+		fAff := new(big.Int).Mul(lift, new(big.Int).Sub(N, big.NewInt(1)))
+		out.Ring.Factors = append(out.Ring.Factors, RingFactor{
+			Q: q.String(), E: e, PointCount: qo.KnownCount, Affine: fAff.String(),
+			Complete: qo.Complete, Lines: qo.Lines,
+		})
+		out.Complete = out.Complete && qo.Complete
+		out.Truncated = out.Truncated || qo.Truncated
+		out.Lines += qo.Lines
+		out.Notes = append(out.Notes, qo.Notes...)
+		total.Mul(total, new(big.Int).Mul(lift, N))
+		affine.Mul(affine, fAff)
+		mods = append(mods, qe)
+		lifted = append(lifted, henselLift(Curve{P: q, A: ec.Mod(A, q), B: ec.Mod(B, q)}, qo.Found, e))
+	}
+	out.KnownCount = total.String()
+	out.Ring.Affine = affine.String()
+
+	found := big.NewInt(1)
+	for _, pts := range lifted {
+		found.Mul(found, big.NewInt(int64(len(pts))))
+	}
+	if found.Cmp(big.NewInt(maxRingPoints)) > 0 {
+		out.Notes = append(out.Notes, fmt.Sprintf("%s affine points over Z/%sZ: too many to list (at most %d)", found, n, maxRingPoints))
+		return out, nil
+	}
+	out.Ring.Listed = out.Complete
+	for _, P := range crtCombine(n, mods, lifted) {
+		out.Found = append(out.Found, toPt(P))
+	}
+	out.Found = append(out.Found, Pt{Inf: true})
+	return out, nil
+}
+
+// henselLift lifts the affine points among found (on c over F_q) to every
+// point mod q^e above them: y^2 = f(x) is smooth, so for each of the q^(e-1)
+// choices of x (or of y, where y ≡ 0 and ∂/∂y vanishes) Newton's method
+// fixes the other coordinate uniquely.
+func henselLift(c Curve, found []Pt, e int) []Point {
+	q := c.P
+	m := new(big.Int).Exp(q, big.NewInt(int64(e)), nil)
+	steps := new(big.Int).Quo(m, q)
+	f := func(x *big.Int) *big.Int {
+		t := new(big.Int).Mul(x, x)
+		t.Add(t, c.A).Mul(t, x).Add(t, c.B)
+		return t.Mod(t, m)
+	}
+	var out []Point
+	for _, pt := range found {
+		if pt.Inf {
+			continue
+		}
+		x0, _ := new(big.Int).SetString(pt.X, 10)
+		y0, _ := new(big.Int).SetString(pt.Y, 10)
+		for t := new(big.Int); t.Cmp(steps) < 0; t.Add(t, big.NewInt(1)) {
+			shift := new(big.Int).Mul(t, q)
+			x, y := new(big.Int).Set(x0), new(big.Int).Set(y0)
+			for k := 0; k < e; k++ { // precision doubles each step
+				if y0.Sign() != 0 {
+					x.Add(x0, shift)
+					// y -= (y^2 - f(x)) / 2y
+					d := new(big.Int).Sub(new(big.Int).Mul(y, y), f(x))
+					inv := new(big.Int).ModInverse(new(big.Int).Lsh(y, 1), m)
+					y.Sub(y, d.Mul(d, inv)).Mod(y, m)
+				} else {
+					y.Set(shift)
+					// x -= (f(x) - y^2) / (3x^2 + A)
+					d := new(big.Int).Sub(f(x), new(big.Int).Mul(y, y))
+					df := new(big.Int).Mul(x, x)
+					df.Mul(df, big.NewInt(3)).Add(df, c.A)
+					inv := new(big.Int).ModInverse(df.Mod(df, m), m)
+					x.Sub(x, d.Mul(d, inv)).Mod(x, m)
+				}
+			}
+			out = append(out, Point{X: x, Y: y})
+		}
+	}
+	return out
+}
+
+// crtCombine returns every affine point mod n whose reduction mod mods[i]
+// is in pts[i], sorted by x then y.
+func crtCombine(n *big.Int, mods []*big.Int, pts [][]Point) []Point {
+	coef := make([]*big.Int, len(mods)) // ≡ 1 mod mods[i], 0 mod the rest
+	for i, m := range mods {
+		Ni := new(big.Int).Quo(n, m)
+		coef[i] = new(big.Int).Mul(Ni, new(big.Int).ModInverse(new(big.Int).Mod(Ni, m), m))
+	}
+	out := []Point{{X: new(big.Int), Y: new(big.Int)}}
+	for i, set := range pts {
+		var next []Point
+		for _, acc := range out {
+			for _, P := range set {
+				next = append(next, Point{
+					X: new(big.Int).Add(acc.X, new(big.Int).Mul(P.X, coef[i])),
+					Y: new(big.Int).Add(acc.Y, new(big.Int).Mul(P.Y, coef[i])),
+				})
+			}
+		}
+		out = next
+	}
+	for _, P := range out {
+		P.X.Mod(P.X, n)
+		P.Y.Mod(P.Y, n)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if c := out[i].X.Cmp(out[j].X); c != 0 {
+			return c < 0
+		}
+		return out[i].Y.Cmp(out[j].Y) < 0
+	})
+	return out
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+)
+
+// ---------- line scheduling and coverage ----------
+
+// coverageMarks are the coverage fractions at which the line count is
+// recorded, so FIFO and greedy runs can be compared.
+var coverageMarks = []float64{0.25, 0.5, 0.75, 0.9, 0.95, 0.99}
+
+// Milestone: coverage first reached At after Lines distinct lines.
+type Milestone struct {
+	At    float64 `json:"at"`
+	Lines int     `json:"lines"`
+}
+
+// CoverageOut reports how much of the p×p grid the walk classified.
+type CoverageOut struct {
+	Schedule   string      `json:"schedule"`
+	Cells      int         `json:"cells"`
+	Classified int         `json:"classified"` // found or excluded
+	Fraction   float64     `json:"fraction"`
+	Remaining  int         `json:"remaining"` // candidate cells still unclassified
+	StoppedAt  float64     `json:"stoppedAtCoverage,omitempty"`
+	Milestones []Milestone `json:"milestones,omitempty"`
+}
+
+// noteCoverage records any coverage marks crossed by the line just done.
+func (e *Engine) noteCoverage() {
+	cells := float64(e.G.p * e.G.p)
+	for len(e.milestones) < len(coverageMarks) {
+		at := coverageMarks[len(e.milestones)]
+		if float64(e.G.classified) < at*cells {
+			return
+		}
+		e.milestones = append(e.milestones, Milestone{At: at, Lines: len(e.linesDone)})
+	}
+}
+
+// coverage summarises the grid (nil without one).
+func (e *Engine) coverage() *CoverageOut {
+	if e.G == nil {
+		return nil
+	}
+	cells := e.G.p * e.G.p
+	sched := e.Schedule
+	if sched == "" {
+		sched = "fifo"
+	}
+	c := &CoverageOut{
+		Schedule:   sched,
+		Cells:      cells,
+		Classified: e.G.classified,
+		Fraction:   float64(e.G.classified) / float64(cells),
+		Remaining:  cells - e.G.classified,
+		Milestones: e.milestones,
+	}
+	if e.coverageReached() {
+		c.StoppedAt = e.StopAtCoverage
+	}
+	return c
+}
+
+// coverageReached reports whether -stop_at_coverage has been met.
+func (e *Engine) coverageReached() bool {
+	return e.StopAtCoverage > 0 && e.G != nil &&
+		float64(e.G.classified) >= e.StopAtCoverage*float64(e.G.p*e.G.p)
+}
+
+// unknownOn counts the cells of L that are neither FOUND nor EXCLUDED: how
+// many cells processing L could newly classify.
+func (g *Grid) unknownOn(L Line) int {
+	p, n := g.p, 0
+	if L.Vertical {
+		x := int(L.V.Int64()) % p
+		for y := 0; y < p; y++ {
+			if i := g.idx(x, y); !g.found.get(i) && !g.excl.get(i) {
+				n++
+			}
+		}
+		return n
+	}
+	m, c := int(L.M.Int64())%p, int(L.C.Int64())%p
+	for x := 0; x < p; x++ {
+		if i := g.idx(x, (m*x+c)%p); !g.found.get(i) && !g.excl.get(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// candidate is a line waiting in the greedy queue. score is the unknown
+// cell count when the grid had classified == ver cells; as cells only ever
+// become classified, a stale score is an upper bound.
+type candidate struct {
+	L     Line
+	P     Point
+	Q     *Point
+	score int
+	ver   int
+}
+
+type lineHeap []*candidate
+
+func (h lineHeap) Len() int           { return len(h) }
+func (h lineHeap) Less(i, j int) bool { return h[i].score > h[j].score }
+func (h lineHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *lineHeap) Push(x any)        { *h = append(*h, x.(*candidate)) }
+func (h *lineHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// greedyState persists the queue across reseeds.
+type greedyState struct {
+	queue    lineHeap
+	expanded int // e.order[:expanded] have had their lines queued
+}
+
+// walkGreedy is walkAndExclude with the pending lines in a max-heap keyed
+// by unknown cells (lazy greedy: the top is rescored until its score is
+// current, then processed). Grid mode only.
+func (e *Engine) walkGreedy(maxLines int) error {
+	e.ensureMaps()
+	if e.greedy == nil {
+		e.greedy = &greedyState{}
+	}
+	gs := e.greedy
+	processed := 0
+	for {
+		n, err := e.drainVerticals()
+		if err != nil {
+			return err
+		}
+		processed += n
+		if err := e.queueNewLines(); err != nil {
+			return err
+		}
+		e.capped = gs.queue.Len() > 0 && maxLines > 0 && processed >= maxLines
+		if gs.queue.Len() == 0 || (maxLines > 0 && processed >= maxLines) || e.coverageReached() || e.Budget.exceeded() {
+			return nil
+		}
+		if t := e.affineTarget(); t != nil && t.IsInt64() && int64(len(e.order)) == t.Int64() {
+			return nil
+		}
+		c := heap.Pop(&gs.queue).(*candidate)
+		if e.linesDone[c.L.key()] {
+			continue
+		}
+		if c.ver != e.G.classified {
+			c.score, c.ver = e.G.unknownOn(c.L), e.G.classified
+			heap.Push(&gs.queue, c)
+			continue
+		}
+		if err := e.processLine(c.L, c.P, c.Q); err != nil {
+			return err
+		}
+		processed++
+	}
+}
+
+// queueNewLines queues the tangent and secants of every point found since
+// the last call, unscored (score = p, the most a line can have).
+func (e *Engine) queueNewLines() error {
+	gs := e.greedy
+	for ; gs.expanded < len(e.order); gs.expanded++ {
+		i := gs.expanded
+		P := e.order[i]
+		var jobs []lineJob
+		if pk := e.pointKey(P); e.drawsTangents() && !e.tangentDone[pk] {
+			jobs = append(jobs, lineJob{P: P})
+			e.tangentDone[pk] = true
+		}
+		for _, j := range e.secantPartners(i) {
+			Q := e.order[j]
+			if pair := e.pairKey(P, Q); !Q.Inf && !e.secantDone[pair] {
+				jobs = append(jobs, lineJob{P: P, Q: &Q})
+				e.secantDone[pair] = true
+			}
+		}
+		lines, ok, err := e.jobLines(jobs)
+		if err != nil {
+			return err
+		}
+		for k, j := range jobs {
+			if !ok[k] {
+				continue
+			}
+			heap.Push(&gs.queue, &candidate{L: lines[k], P: j.P, Q: j.Q, score: e.G.p, ver: -1})
+		}
+	}
+	return nil
+}
+
+// walk runs one walk pass with the configured schedule.
+func (e *Engine) walk(maxLines int) error {
+	if e.Schedule == "greedy" {
+		return e.walkGreedy(maxLines)
+	}
+	return e.walkAndExclude(maxLines)
+}
+
+func printCoverage(w io.Writer, c *CoverageOut) {
+	fmt.Fprintf(w, "\nGrid coverage (%s): %d / %d cells classified (%.2f%%), %d candidate cells left\n", c.Schedule, c.Classified, c.Cells, 100*c.Fraction, c.Remaining)
+	if c.StoppedAt > 0 {
+		fmt.Fprintf(w, "  stopped at -stop_at_coverage %g\n", c.StoppedAt)
+	}
+	for _, m := range c.Milestones {
+		fmt.Fprintf(w, "  %3.0f%% after %d lines\n", 100*m.At, m.Lines)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"ectorus/internal/ec"
+)
+
+// ---------- seed strategies ----------
+
+// seeder picks the point a walk (re)starts from. next returns false when
+// it has nothing left to offer.
+type seeder interface {
+	next(e *Engine) (Point, bool)
+}
+
+// seqSeeder scans x upward for an x carrying a point not yet found: from 0
+// every time (low-x), or from where the previous scan stopped, wrapping
+// around p (sequential).
+type seqSeeder struct {
+	fromZero bool
+	cursor   *big.Int
+}
+
+// fileSeeder hands out the points of a -seed_file in order, skipping any
+// already found or not on the curve.
+type fileSeeder struct {
+	pts []seedPoint
+	i   int
+}
+
+// seedPoint is one line of a seed file: "x y", or just "x" for the first
+// point above x.
+type seedPoint struct {
+	X, Y *big.Int
+}
+
+// seqScanLimit bounds how many x one sequential/low-x request examines.
+const seqScanLimit = 200000
+
+func newSeeder(strategy string, file []seedPoint) (seeder, error) {
+	switch strategy {
+	case "", "random":
+		return nil, nil
+	case "sequential":
+		return &seqSeeder{}, nil
+	case "low-x":
+		return &seqSeeder{fromZero: true}, nil
+	case "from-file":
+		if len(file) == 0 {
+			return nil, fmt.Errorf("-seed_strategy from-file needs -seed_file with at least one point")
+		}
+		return &fileSeeder{pts: file}, nil
+	default:
+		return nil, fmt.Errorf("unknown -seed_strategy %q (want random|sequential|low-x|from-file)", strategy)
+	}
+}
+
+func (s *seqSeeder) next(e *Engine) (Point, bool) {
+	p := e.field().order()
+	x := new(big.Int)
+	if !s.fromZero && s.cursor != nil {
+		x.Set(s.cursor)
+	}
+	limit := seqScanLimit
+	if p.IsInt64() && p.Int64() < int64(limit) {
+		limit = int(p.Int64())
+	}
+	one := big.NewInt(1)
+	for tries := 0; tries < limit; tries++ {
+		if kx := coordOf(x); !e.deadX[kx] {
+			pts := e.pointsAtX(x)
+			for _, P := range pts {
+				if _, ok := e.found[e.pointKey(P)]; !ok {
+					s.cursor = ec.Mod(new(big.Int).Add(x, one), p)
+					return P, true
+				}
+			}
+			if len(pts) > 0 {
+				e.deadX[kx] = true
+			}
+		}
+		x = ec.Mod(x.Add(x, one), p)
+	}
+	return Point{}, false
+}
+
+func (s *fileSeeder) next(e *Engine) (Point, bool) {
+	for ; s.i < len(s.pts); s.i++ {
+		sp := s.pts[s.i]
+		x := ec.Mod(sp.X, e.C.P)
+		for _, P := range e.pointsAtX(x) {
+			if sp.Y != nil && P.Y.Cmp(ec.Mod(sp.Y, e.C.P)) != 0 {
+				continue
+			}
+			if _, ok := e.found[e.pointKey(P)]; !ok {
+				s.i++
+				return P, true
+			}
+		}
+		if sp.Y != nil && !e.onCurve(Point{X: x, Y: ec.Mod(sp.Y, e.C.P)}) {
+			fmt.Fprintf(os.Stderr, "warning: seed (%s, %s) is not on the curve; skipped\n", sp.X, sp.Y)
+		}
+	}
+	return Point{}, false
+}
+
+// loadSeedFile reads one seed per line, "x y" or "x,y" or just "x"
+// (decimal or 0x-hex); blank lines and #-comments are ignored.
+func loadSeedFile(path string) ([]seedPoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var pts []seedPoint
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("%s:%d: want \"x y\" or \"x\"", path, n)
+		}
+		var sp seedPoint
+		if sp.X, err = ec.ParseBig(fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if len(fields) == 2 {
+			if sp.Y, err = ec.ParseBig(fields[1]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+		}
+		pts = append(pts, sp)
+	}
+	return pts, sc.Err()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ---------- exclusion statistics ----------
+
+// lineRow is what processing one line achieved.
+type lineRow struct {
+	L         Line
+	Tangent   bool
+	Auto      bool // a -verticals column, not a line the walk drew
+	Sweep     bool // a -sweep_lines line, through no particular point
+	NewPoints int
+	NewExcl   int     // grid cells newly excluded
+	DupHits   int     // grid cells on the line that were already classified
+	Points    []Point // the curve points on L, for -lines_out
+}
+
+func (r lineRow) kind() string {
+	switch {
+	case r.Auto:
+		return "auto-vertical"
+	case r.L.Vertical:
+		return "vertical"
+	case r.Sweep:
+		return "sweep"
+	case r.Tangent:
+		return "tangent"
+	}
+	return "secant"
+}
+
+// Line kinds for -lines.
+const (
+	linesBoth    = "both"
+	linesTangent = "tangent"
+	linesSecant  = "secant"
+)
+
+// lineStats accumulates lineRows and, with -stats, writes each as CSV.
+type lineStats struct {
+	start     time.Time
+	lines     int
+	dupLines  int
+	newPoints int
+	newExcl   int
+	dupHits   int
+	hits      int // cells touched by exclusion passes
+	byKind    map[string]*KindStatsOut
+	kinds     string // -lines, when not both
+	w         *csv.Writer
+}
+
+// StatsOut is the summary block of the walk's statistics.
+type StatsOut struct {
+	Lines             int     `json:"lines"`
+	DuplicateLines    int     `json:"duplicateLines"` // met again after processing, skipped
+	NewPoints         int     `json:"newPoints"`
+	NewExclusions     int     `json:"newExclusions,omitempty"`
+	MeanNewExclusions float64 `json:"meanNewExclusionsPerLine,omitempty"`
+	DuplicateHitRate  float64 `json:"duplicateHitRate,omitempty"` // of the cells lines touched, share already classified
+	Seconds           float64 `json:"seconds"`
+	LinesPerSecond    float64 `json:"linesPerSecond"`
+	// LineKinds is -lines when the walk drew one kind only; ByKind splits
+	// the totals by tangent, secant and vertical lines.
+	LineKinds string                   `json:"lineKinds,omitempty"`
+	ByKind    map[string]*KindStatsOut `json:"byKind,omitempty"`
+}
+
+// KindStatsOut is what the lines of one kind achieved.
+type KindStatsOut struct {
+	Lines         int `json:"lines"`
+	NewPoints     int `json:"newPoints"`
+	NewExclusions int `json:"newExclusions,omitempty"`
+	DuplicateHits int `json:"duplicateHits,omitempty"`
+}
+
+// kindOrder is the order kinds are printed in.
+var kindOrder = []string{"tangent", "secant", "vertical", "auto-vertical", "sweep"}
+
+var statsHeader = []string{"line", "kind", "new_points", "new_exclusions", "dup_hits", "dup_hit_rate", "classified", "coverage", "elapsed_s", "lines_per_s"}
+
+func (s *lineStats) begin(w *csv.Writer) error {
+	s.start = time.Now()
+	if w == nil {
+		return nil
+	}
+	s.w = w
+	return w.Write(statsHeader)
+}
+
+func (s *lineStats) record(e *Engine, r lineRow) error {
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+	s.lines++
+	s.newPoints += r.NewPoints
+	s.newExcl += r.NewExcl
+	s.dupHits += r.DupHits
+	s.hits += r.NewExcl + r.DupHits
+	kind := r.kind()
+	if s.byKind == nil {
+		s.byKind = map[string]*KindStatsOut{}
+	}
+	k := s.byKind[kind]
+	if k == nil {
+		k = &KindStatsOut{}
+		s.byKind[kind] = k
+	}
+	k.Lines++
+	k.NewPoints += r.NewPoints
+	k.NewExclusions += r.NewExcl
+	k.DuplicateHits += r.DupHits
+	if s.w == nil {
+		return nil
+	}
+	classified, coverage := "", ""
+	if e.G != nil {
+		classified = strconv.Itoa(e.G.classified)
+		coverage = strconv.FormatFloat(float64(e.G.classified)/float64(e.G.p*e.G.p), 'f', 6, 64)
+	}
+	el := time.Since(s.start).Seconds()
+	return s.w.Write([]string{
+		strconv.Itoa(s.lines), kind, strconv.Itoa(r.NewPoints),
+		strconv.Itoa(r.NewExcl), strconv.Itoa(r.DupHits), ratio(s.dupHits, s.hits),
+		classified, coverage,
+		strconv.FormatFloat(el, 'f', 6, 64), strconv.FormatFloat(float64(s.lines)/el, 'f', 1, 64),
+	})
+}
+
+func ratio(a, b int) string {
+	if b == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(a)/float64(b), 'f', 6, 64)
+}
+
+func (s *lineStats) summary() *StatsOut {
+	o := &StatsOut{Lines: s.lines, DuplicateLines: s.dupLines, NewPoints: s.newPoints, NewExclusions: s.newExcl, LineKinds: s.kinds}
+	if len(s.byKind) > 0 {
+		o.ByKind = map[string]*KindStatsOut{}
+		for kind, k := range s.byKind {
+			c := *k
+			o.ByKind[kind] = &c
+		}
+	}
+	if !s.start.IsZero() {
+		o.Seconds = time.Since(s.start).Seconds()
+		if o.Seconds > 0 {
+			o.LinesPerSecond = float64(s.lines) / o.Seconds
+		}
+	}
+	if s.lines > 0 {
+		o.MeanNewExclusions = float64(s.newExcl) / float64(s.lines)
+	}
+	if s.hits > 0 {
+		o.DuplicateHitRate = float64(s.dupHits) / float64(s.hits)
+	}
+	return o
+}
+
+func printStats(w io.Writer, s *StatsOut) {
+	fmt.Fprintf(w, "\nWalk stats: %d lines (%d met again), %d new points, %.3fs (%.0f lines/s)\n",
+		s.Lines, s.DuplicateLines, s.NewPoints, s.Seconds, s.LinesPerSecond)
+	if s.NewExclusions > 0 {
+		fmt.Fprintf(w, "  %d exclusions, %.1f per line, duplicate-hit rate %.3f\n", s.NewExclusions, s.MeanNewExclusions, s.DuplicateHitRate)
+	}
+	if s.LineKinds != "" {
+		fmt.Fprintf(w, "  only %s lines (-lines %s)\n", s.LineKinds, s.LineKinds)
+	}
+	for _, kind := range kindOrder {
+		k := s.ByKind[kind]
+		if k == nil {
+			continue
+		}
+		fmt.Fprintf(w, "  %-14s %d lines, %d new points", kind+":", k.Lines, k.NewPoints)
+		if s.NewExclusions > 0 {
+			fmt.Fprintf(w, ", %d exclusions (%.1f%%), %.1f per line", k.NewExclusions, 100*float64(k.NewExclusions)/float64(s.NewExclusions), float64(k.NewExclusions)/float64(k.Lines))
+		}
+		fmt.Fprintln(w)
+	}
+}
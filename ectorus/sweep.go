@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+)
+
+// ---------- -sweep_lines: every line of the plane ----------
+
+// maxSweepP bounds -sweep_lines: c = y - m*x is computed in int64.
+const maxSweepP = 1 << 31
+
+// SweepOut summarises a -sweep_lines run.
+type SweepOut struct {
+	Lines    int    `json:"lines"` // processed, verticals first, then y = mx + c by m and c
+	Total    string `json:"total"` // p^2 + p
+	Complete bool   `json:"complete"`
+	// Meets[k] counts the lines through exactly k affine points of the
+	// curve (up to 3 on a cubic, 4 on an Edwards quartic).
+	Meets []int `json:"meets"`
+}
+
+// sweep processes the p verticals and the p^2 lines y = mx + c in turn,
+// each with the curve points that lie on it, instead of only the lines
+// through found points. The points are enumerated once by x; for each
+// slope they are bucketed by intercept, so finding the intersections costs
+// O(p^2) in all (the -grid exclusions O(p^3)), with O(p) extra memory
+// beyond the processed-line set. It stops after maxLines lines (0 = all) or at
+// -stop_at_coverage or a budget. The line at infinity, which meets E at O
+// alone, is left out.
+func (e *Engine) sweep(maxLines int) (*SweepOut, error) {
+	e.ensureMaps()
+	P := e.C.P
+	if !P.IsInt64() || P.Int64() >= maxSweepP {
+		return nil, fmt.Errorf("-sweep_lines supports p < 2^31; got p=%s", P)
+	}
+	p := P.Int64()
+	res := &SweepOut{Total: fmt.Sprint(p*p + p), Meets: make([]int, 4)}
+
+	fmt.Fprintln(os.Stderr, "Enumerating points for the sweep...")
+	var pts []Point
+	var xs, ys []int64
+	columns := make([][]Point, p)
+	for x := range p {
+		columns[x] = e.pointsAtX(big.NewInt(x))
+		for _, Q := range columns[x] {
+			pts = append(pts, Q)
+			xs, ys = append(xs, x), append(ys, Q.Y.Int64())
+		}
+	}
+
+	done := func() bool {
+		return maxLines > 0 && res.Lines >= maxLines || e.coverageReached() || e.Budget.exceeded()
+	}
+	line := func(L Line, on []Point) error {
+		for len(res.Meets) <= len(on) {
+			res.Meets = append(res.Meets, 0)
+		}
+		res.Meets[len(on)]++
+		res.Lines++
+		return e.applyLine(lineRow{L: L, Sweep: true}, Point{Inf: true}, nil, on, nil)
+	}
+	for x := int64(0); x < p && !done(); x++ {
+		if err := line(Line{Vertical: true, V: big.NewInt(x)}, columns[x]); err != nil {
+			return nil, err
+		}
+	}
+	columns = nil
+	bucket := make([][]int, p)
+	var on []Point
+	for m := int64(0); m < p && !done(); m++ {
+		for c := range bucket {
+			bucket[c] = bucket[c][:0]
+		}
+		for i := range pts {
+			c := (ys[i] - m*xs[i]%p + p) % p
+			bucket[c] = append(bucket[c], i)
+		}
+		M := big.NewInt(m)
+		for c := int64(0); c < p && !done(); c++ {
+			on = on[:0]
+			for _, i := range bucket[c] {
+				on = append(on, pts[i])
+			}
+			if err := line(Line{M: M, C: big.NewInt(c)}, on); err != nil {
+				return nil, err
+			}
+		}
+	}
+	res.Complete = int64(res.Lines) == p*p+p
+	return res, nil
+}
+
+// checkSweep rejects the options that shape a walk, which a sweep does not
+// make.
+func checkSweep(o runOpts) error {
+	switch {
+	case o.Interactive, o.NoReseed, o.PointsIn != "", o.GridIn != "":
+		return errors.New("-sweep_lines processes every line itself; it does not combine with -interactive, -no_reseed, -points_in or -grid_in")
+	case o.LineKinds != "" && o.LineKinds != linesBoth, o.Verticals, o.Schedule == "greedy", o.SecantWindow != 0:
+		return errors.New("-sweep_lines draws every line; -lines, -verticals, -schedule greedy and -secant_window do not apply")
+	case o.GraphPath != "":
+		return errors.New("-sweep_lines finds points without parent lines; it does not combine with -graph")
+	}
+	return nil
+}
+
+func printSweep(w io.Writer, s *SweepOut) {
+	fmt.Fprintf(w, "Line sweep: %d of %s lines", s.Lines, s.Total)
+	if !s.Complete {
+		fmt.Fprint(w, " (stopped early)")
+	}
+	fmt.Fprint(w, "; through 0, 1, 2, ... affine points:")
+	for _, n := range s.Meets {
+		fmt.Fprintf(w, " %d", n)
+	}
+	fmt.Fprintln(w)
+}
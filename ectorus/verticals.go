@@ -0,0 +1,35 @@
+package main
+
+import "math/big"
+
+// ---------- -verticals: each found point's column at once ----------
+
+// queueVertical notes P's column for the next drainVerticals; addFound
+// calls it for every new affine point.
+func (e *Engine) queueVertical(P Point) {
+	if e.AutoVerticals && !P.Inf {
+		e.verticals = append(e.verticals, P)
+	}
+}
+
+// drainVerticals processes the vertical line x = x0 through each point
+// queued since the last call, unless the walk has already drawn it. The
+// curve points on it are the one or two with that x, so it costs a square
+// root and, with -grid, a column of exclusions; the other root joins the
+// found points. It returns how many lines it processed.
+func (e *Engine) drainVerticals() (int, error) {
+	n := 0
+	for len(e.verticals) > 0 {
+		P := e.verticals[0]
+		e.verticals = e.verticals[1:]
+		L := Line{Vertical: true, V: new(big.Int).Set(P.X)}
+		if e.linesDone[L.key()] {
+			continue
+		}
+		if err := e.applyLine(lineRow{L: L, Auto: true}, P, nil, e.pointsAtX(P.X), nil); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,156 @@
+package count
+
+import (
+	"errors"
+	"io"
+	"math/big"
+
+	"ectorus/internal/ec"
+)
+
+// ErrAmbiguous is returned by BSGS when every sampled point has an order
+// with more than one multiple in the Hasse interval (the group exponent is
+// below 4√p), so the points alone cannot pin N down.
+var ErrAmbiguous = errors.New("count: group exponent too small to pin down N")
+
+// bsgsPoints bounds how many random points BSGS tries.
+const bsgsPoints = 20
+
+// LegendreBelow is where Auto switches from the O(p) Legendre scan to
+// O(p^{1/4}) Shanks–Mestre.
+const LegendreBelow = 1 << 15
+
+// Auto counts c with method "legendre", "bsgs", "mestre" or "auto"
+// (Legendre below LegendreBelow, Shanks–Mestre above), and reports the
+// method it used.
+func Auto(c ec.Curve, method string, rnd io.Reader) (*big.Int, string, error) {
+	switch {
+	case method == "legendre" || (method == "auto" && c.P.Cmp(big.NewInt(LegendreBelow)) < 0):
+		return Legendre(c), "legendre", nil
+	case method == "bsgs":
+		N, err := BSGS(c, rnd)
+		return N, "bsgs", err
+	}
+	N, err := ShanksMestre(c, rnd)
+	return N, "mestre", err
+}
+
+// HasseInterval returns [p + 1 - 2√p, p + 1 + 2√p] (rounded outward).
+func HasseInterval(p *big.Int) (lo, hi *big.Int) {
+	s := new(big.Int).Sqrt(new(big.Int).Lsh(p, 2)) // ⌊2√p⌋
+	s.Add(s, big.NewInt(1))
+	lo = new(big.Int).Add(p, big.NewInt(1))
+	hi = new(big.Int).Add(lo, s)
+	lo.Sub(lo, s)
+	return lo, hi
+}
+
+// BSGS counts #E(F_p) in O(p^{1/4}) group operations per point: for random
+// P it finds every k in the Hasse interval with kP = O by baby-step
+// giant-step, and intersects the candidates over points (through the lcm of
+// their orders) until one N is left.
+func BSGS(c ec.Curve, rnd io.Reader) (*big.Int, error) {
+	lo, hi := HasseInterval(c.P)
+	M := big.NewInt(1) // lcm of the point orders seen so far
+	for i := 0; i < bsgsPoints; i++ {
+		P, err := c.RandomPoint(rnd)
+		if err != nil {
+			return nil, err
+		}
+		ord, ks, err := killersInRange(c, P, lo, hi)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ord != nil:
+		case len(ks) == 0:
+			return nil, errors.New("count: no multiple of the point order in the Hasse interval (is p prime?)")
+		case len(ks) == 1:
+			// N is a multiple of ord(P) inside the interval
+			return ks[0], nil
+		default:
+			// consecutive solutions are exactly ord(P) apart
+			ord = new(big.Int).Sub(ks[1], ks[0])
+		}
+		M = lcm(M, ord)
+		if N, ok := uniqueMultiple(M, lo, hi); ok {
+			return N, nil
+		}
+	}
+	return nil, ErrAmbiguous
+}
+
+// killersInRange returns, in increasing order, every k ∈ [lo, hi] with
+// kP = O. Baby steps jP (0 ≤ j < m) are stored; giant steps
+// (lo + i·m)P for 0 ≤ i ≤ m + 1 are looked up, giving k = lo + i·m - j.
+// If a baby step already reaches O, ord(P) < m is returned instead.
+func killersInRange(c ec.Curve, P ec.Point, lo, hi *big.Int) (ord *big.Int, ks []*big.Int, err error) {
+	width := new(big.Int).Sub(hi, lo)
+	m := new(big.Int).Sqrt(width)
+	m.Add(m, big.NewInt(1))
+	if !m.IsInt64() || m.Int64() > 1<<26 {
+		return nil, nil, errors.New("count: BSGS table too large for this p")
+	}
+	mm := int(m.Int64())
+
+	key := func(Q ec.Point) string {
+		if Q.Inf {
+			return "inf"
+		}
+		return Q.X.String() + "|" + Q.Y.String()
+	}
+	baby := make(map[string]int, mm)
+	R := ec.Point{Inf: true}
+	for j := 0; j < mm; j++ {
+		if j > 0 && R.Inf {
+			return big.NewInt(int64(j)), nil, nil
+		}
+		baby[key(R)] = j
+		if R, err = c.Add(R, P); err != nil {
+			return nil, nil, err
+		}
+	}
+	step, err := c.ScalarMul(m, P)
+	if err != nil {
+		return nil, nil, err
+	}
+	G, err := c.ScalarMul(lo, P)
+	if err != nil {
+		return nil, nil, err
+	}
+	// ord(P) ≥ m, so the jP are distinct and each k has one (i, j).
+	for i := 0; i <= mm+1; i++ {
+		if j, ok := baby[key(G)]; ok {
+			// G = (lo + i m) P = j P  ⇒  (lo + i m - j) P = O
+			k := new(big.Int).Mul(big.NewInt(int64(i)), m)
+			k.Add(k, lo).Sub(k, big.NewInt(int64(j)))
+			if k.Cmp(lo) >= 0 && k.Cmp(hi) <= 0 {
+				ks = append(ks, k)
+			}
+		}
+		if G, err = c.Add(G, step); err != nil {
+			return nil, nil, err
+		}
+	}
+	return nil, ks, nil
+}
+
+// uniqueMultiple reports the single multiple of M in [lo, hi], if there is
+// exactly one.
+func uniqueMultiple(M, lo, hi *big.Int) (*big.Int, bool) {
+	first := new(big.Int).Add(lo, new(big.Int).Sub(M, big.NewInt(1)))
+	first.Div(first, M).Mul(first, M) // ⌈lo / M⌉·M
+	if first.Cmp(hi) > 0 {
+		return nil, false
+	}
+	if new(big.Int).Add(first, M).Cmp(hi) <= 0 {
+		return nil, false
+	}
+	return first, true
+}
+
+func lcm(a, b *big.Int) *big.Int {
+	g := new(big.Int).GCD(nil, nil, a, b)
+	l := new(big.Int).Div(a, g)
+	return l.Mul(l, b)
+}
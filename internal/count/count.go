@@ -0,0 +1,132 @@
+// Package count computes #E(F_p) for short Weierstrass curves. The Legendre
+// scan here is the O(p) baseline every other counting method is checked
+// against.
+package count
+
+import (
+	"math/big"
+	"math/bits"
+
+	"ectorus/internal/ec"
+)
+
+// Legendre returns #E(F_p) = p + 1 + Σ_x χ(x^3 + Ax + B) by a direct scan,
+// switching to machine words when p < 2^63.
+func Legendre(c ec.Curve) *big.Int {
+	if c.P.IsUint64() && c.P.Uint64() < 1<<63 {
+		p := c.P.Uint64()
+		return new(big.Int).SetUint64(LegendreU64(p, ec.Mod(c.A, c.P).Uint64(), ec.Mod(c.B, c.P).Uint64()))
+	}
+	cnt := big.NewInt(1) // O
+	for x := new(big.Int); x.Cmp(c.P) < 0; x.Add(x, big.NewInt(1)) {
+		t := ec.AddM(ec.AddM(ec.MulM(x, ec.MulM(x, x, c.P), c.P), ec.MulM(c.A, x, c.P), c.P), c.B, c.P)
+		cnt.Add(cnt, big.NewInt(int64(1+ec.Legendre(t, c.P))))
+	}
+	return cnt
+}
+
+// LegendreU64 is Legendre for p < 2^63 with A, B already reduced. f(x) is
+// stepped by finite differences (Δf = 3x^2 + 3x + 1 + A, Δ²f = 6x + 6,
+// Δ³f = 6), so each x costs a few additions and one character lookup: a
+// table of squares for p ≤ 2^30, a Jacobi symbol beyond.
+func LegendreU64(p, A, B uint64) uint64 {
+	return LegendreRangeU64(p, A, B, 0, p) + 1
+}
+
+// residueTableMax bounds the primes for which LegendreRangeU64 builds a
+// p-bit table of squares instead of taking a Jacobi symbol per x.
+const residueTableMax = 1 << 30
+
+// LegendreRangeU64 counts the affine points with x in [lo, hi).
+func LegendreRangeU64(p, A, B, lo, hi uint64) uint64 {
+	if lo >= hi {
+		return 0
+	}
+	add := func(a, b uint64) uint64 {
+		s := a + b
+		if s >= p {
+			s -= p
+		}
+		return s
+	}
+	mul := func(a, b uint64) uint64 {
+		h, l := bits.Mul64(a, b)
+		_, r := bits.Div64(h, l, p)
+		return r
+	}
+	chi := func(f uint64) int { return Jacobi(f, p) }
+	if p <= residueTableMax && hi-lo >= p/4 {
+		sq := squaresTable(p)
+		chi = func(f uint64) int {
+			switch {
+			case f == 0:
+				return 0
+			case sq[f>>6]>>(f&63)&1 == 1:
+				return 1
+			}
+			return -1
+		}
+	}
+
+	x := lo % p
+	x2 := mul(x, x)
+	f := add(add(mul(x2, x), mul(A, x)), B)
+	d1 := add(add(add(mul(3, x2), mul(3, x)), 1), A)
+	d2 := add(mul(6, x), 6)
+	d3 := 6 % p
+
+	var n uint64
+	for i := lo; i < hi; i++ {
+		n += uint64(1 + chi(f))
+		f = add(f, d1)
+		d1 = add(d1, d2)
+		d2 = add(d2, d3)
+	}
+	return n
+}
+
+// squaresTable marks every nonzero square mod p, stepping y^2 by 2y + 1.
+func squaresTable(p uint64) []uint64 {
+	t := make([]uint64, (p+63)/64)
+	var sq, step uint64 = 1, 3 // 1^2, then (y+1)^2 - y^2 = 2y + 1
+	for y := uint64(1); y <= p/2; y++ {
+		t[sq>>6] |= 1 << (sq & 63)
+		sq += step
+		if sq >= p {
+			sq -= p
+		}
+		step += 2
+		if step >= p {
+			step -= p
+		}
+	}
+	return t
+}
+
+// Jacobi returns the Jacobi symbol (a|n) for odd n (the Legendre symbol when
+// n is prime), by the binary reciprocity algorithm.
+func Jacobi(a, n uint64) int {
+	a %= n
+	s := 1
+	for a != 0 {
+		tz := bits.TrailingZeros64(a)
+		a >>= uint(tz)
+		if tz&1 == 1 && (n&7 == 3 || n&7 == 5) {
+			s = -s
+		}
+		if a&3 == 3 && n&3 == 3 {
+			s = -s
+		}
+		a, n = n%a, a
+	}
+	if n == 1 {
+		return s
+	}
+	return 0
+}
+
+// Trace returns the trace of Frobenius a_p = p + 1 - N.
+func Trace(p, N *big.Int) *big.Int {
+	t := new(big.Int).Add(p, big.NewInt(1))
+	return t.Sub(t, N)
+}
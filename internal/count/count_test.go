@@ -0,0 +1,131 @@
+package count
+
+import (
+	"math/big"
+	mrand "math/rand"
+	"testing"
+
+	"ectorus/internal/ec"
+)
+
+func bi(v int64) *big.Int { return big.NewInt(v) }
+
+// brute counts #E(F_p) by testing every (x, y).
+func brute(p, A, B int64) int64 {
+	n := int64(1)
+	for x := int64(0); x < p; x++ {
+		for y := int64(0); y < p; y++ {
+			if (y*y-x*x*x-A*x-B)%p == 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestJacobiMatchesLegendre(t *testing.T) {
+	for _, p := range []uint64{3, 5, 11, 101, 1009} {
+		for a := uint64(0); a < p; a++ {
+			if got, want := Jacobi(a, p), ec.Legendre(new(big.Int).SetUint64(a), new(big.Int).SetUint64(p)); got != want {
+				t.Fatalf("Jacobi(%d|%d) = %d, want %d", a, p, got, want)
+			}
+		}
+	}
+}
+
+func TestLegendreMatchesBruteForce(t *testing.T) {
+	for _, tc := range [][3]int64{{11, 0, 1}, {101, 2, 3}, {101, 0, 7}, {97, 96, 0}} {
+		p, A, B := tc[0], tc[1], tc[2]
+		want := brute(p, A, B)
+		if got := LegendreU64(uint64(p), uint64(A), uint64(B)); got != uint64(want) {
+			t.Fatalf("LegendreU64(%v) = %d, want %d", tc, got, want)
+		}
+		if got := Legendre(ec.Curve{P: bi(p), A: bi(A), B: bi(B)}); got.Int64() != want {
+			t.Fatalf("Legendre(%v) = %s, want %d", tc, got, want)
+		}
+	}
+}
+
+func TestLegendreRangeSplits(t *testing.T) {
+	const p, A, B = 1009, 5, 7
+	whole := LegendreRangeU64(p, A, B, 0, p)
+	parts := LegendreRangeU64(p, A, B, 0, 300) + LegendreRangeU64(p, A, B, 300, 777) + LegendreRangeU64(p, A, B, 777, p)
+	if whole != parts {
+		t.Fatalf("split count %d != whole %d", parts, whole)
+	}
+}
+
+func TestBSGSMatchesLegendre(t *testing.T) {
+	r := mrand.New(mrand.NewSource(3))
+	for _, p := range []uint64{10007, 65537, 1000003} {
+		for i := 0; i < 20; i++ {
+			A, B := r.Uint64()%p, r.Uint64()%p
+			c := ec.Curve{P: new(big.Int).SetUint64(p), A: new(big.Int).SetUint64(A), B: new(big.Int).SetUint64(B)}
+			if c.IsSingular() {
+				continue
+			}
+			got, err := BSGS(c, r)
+			if err == ErrAmbiguous {
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := LegendreU64(p, A, B); got.Uint64() != want {
+				t.Fatalf("BSGS(p=%d A=%d B=%d) = %s, want %d", p, A, B, got, want)
+			}
+		}
+	}
+}
+
+func TestShanksMestreMatchesLegendre(t *testing.T) {
+	r := mrand.New(mrand.NewSource(5))
+	for _, p := range []uint64{233, 1009, 10007, 65537, 1000003} {
+		for i := 0; i < 40; i++ {
+			A, B := r.Uint64()%p, r.Uint64()%p
+			if i < 4 {
+				// j = 0 and 1728, whose small exponents defeat BSGS
+				A, B = [4]uint64{0, 0, 1, p - 1}[i], [4]uint64{1, 7, 0, 0}[i]
+			}
+			c := ec.Curve{P: new(big.Int).SetUint64(p), A: new(big.Int).SetUint64(A), B: new(big.Int).SetUint64(B)}
+			if c.IsSingular() {
+				continue
+			}
+			got, err := ShanksMestre(c, r)
+			if err != nil {
+				t.Fatalf("ShanksMestre(p=%d A=%d B=%d): %v", p, A, B, err)
+			}
+			if want := LegendreU64(p, A, B); got.Uint64() != want {
+				t.Fatalf("ShanksMestre(p=%d A=%d B=%d) = %s, want %d", p, A, B, got, want)
+			}
+		}
+	}
+}
+
+func TestShanksMestreLargeP(t *testing.T) {
+	// p ≈ 10^14, beyond the Legendre scan: N must kill random points of E
+	// and 2p + 2 - N those of the twist.
+	r := mrand.New(mrand.NewSource(6))
+	p, _ := new(big.Int).SetString("100000000000031", 10)
+	c := ec.Curve{P: p, A: bi(-3), B: bi(5)}
+	N, err := ShanksMestre(c, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw, _ := c.QuadraticTwist()
+	Nt := new(big.Int).Sub(new(big.Int).Lsh(new(big.Int).Add(p, bi(1)), 1), N)
+	for i := 0; i < 5; i++ {
+		for _, k := range []struct {
+			c ec.Curve
+			n *big.Int
+		}{{c, N}, {tw, Nt}} {
+			P, err := k.c.RandomPoint(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if Q, err := k.c.ScalarMul(k.n, P); err != nil || !Q.Inf {
+				t.Fatalf("%s·P ≠ O on %+v (%v)", k.n, k.c, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package crosscheck backs `asteroids crosscheck`: it walks a curve with
+// the ectorus engine and enumerates the same curve with ecscan, and
+// compares the two point sets. They share no point-finding code (chords and
+// tangents on one side, a sqrt table or Tonelli–Shanks per x on the
+// other), so agreement is an end-to-end check of both.
+package crosscheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"ectorus/internal/ecscan"
+)
+
+// listMax bounds how many points of each discrepancy are listed.
+const listMax = 10
+
+type Config struct {
+	P, A, B    uint64  // --p, --A, --B (A and B reduced mod p)
+	Ectorus    string  // --ectorus: the binary that walks the curve
+	MaxSeconds float64 // --max-seconds: budget for the walk (0 = none)
+	Workers    int     // --workers: ecscan's (0 = GOMAXPROCS*4)
+	JSON       bool    // --json
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("asteroids crosscheck", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: asteroids crosscheck -p P -A A -B B [flags]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		p          = fs.Uint64("p", 0, "prime modulus, 3 < p < 2^63 (required)")
+		A          = fs.Uint64("A", 0, "curve parameter A")
+		B          = fs.Uint64("B", 0, "curve parameter B")
+		ectorus    = fs.String("ectorus", "./bin/ectorus", "path to the ectorus binary that walks the curve")
+		maxSeconds = fs.Float64("max-seconds", 0, "stop the walk after this many seconds; a truncated walk is an error (0 = no limit)")
+		workers    = fs.Int("workers", 0, "ecscan workers (0 = GOMAXPROCS*4)")
+		jsonOut    = fs.Bool("json", false, "emit JSON instead of text")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 0 {
+		return nil, fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	if *p <= 3 || *p >= 1<<63 || !new(big.Int).SetUint64(*p).ProbablyPrime(32) {
+		return nil, errors.New("--p must be a prime in (3, 2^63)")
+	}
+	if *maxSeconds < 0 {
+		return nil, fmt.Errorf("bad --max-seconds %v", *maxSeconds)
+	}
+	return &Config{P: *p, A: *A % *p, B: *B % *p, Ectorus: *ectorus, MaxSeconds: *maxSeconds, Workers: *workers, JSON: *jsonOut}, nil
+}
+
+// Point is an affine point in decimal.
+type Point struct {
+	X string `json:"x"`
+	Y string `json:"y"`
+}
+
+// Result is what a cross-check reports.
+type Result struct {
+	P        string  `json:"p"`
+	A        string  `json:"A"`
+	B        string  `json:"B"`
+	Walked   int     `json:"walkedAffine"`
+	Scanned  int     `json:"scannedAffine"`
+	Match    bool    `json:"match"`
+	OnlyWalk []Point `json:"onlyWalk,omitempty"` // the first listMax of each
+	OnlyScan []Point `json:"onlyScan,omitempty"`
+}
+
+// Run walks and scans cfg's curve and writes the comparison to w. A
+// mismatch is an error, after the report is written.
+func Run(cfg *Config, w io.Writer) error {
+	walked, err := walk(cfg)
+	if err != nil {
+		return err
+	}
+	res, err := compare(context.Background(), ecscan.Params{P: cfg.P, A: cfg.A, B: cfg.B, Workers: cfg.Workers}, walked)
+	if err != nil {
+		return err
+	}
+	if cfg.JSON {
+		b, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	} else {
+		res.print(w)
+	}
+	if !res.Match {
+		return errors.New("crosscheck: the walk and the scan found different points")
+	}
+	return nil
+}
+
+// walk runs ectorus with -count_first, so the walk reseeds until it has
+// every point, and returns its affine points.
+func walk(cfg *Config) ([][2]uint64, error) {
+	dir, err := os.MkdirTemp("", "asteroids-crosscheck")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	resPath := filepath.Join(dir, "result.json")
+	args := []string{
+		"-p", strconv.FormatUint(cfg.P, 10), "-A", strconv.FormatUint(cfg.A, 10), "-B", strconv.FormatUint(cfg.B, 10),
+		"-count_first", "-format", "json", "-out", resPath,
+	}
+	if cfg.MaxSeconds > 0 {
+		args = append(args, "-max_seconds", strconv.FormatFloat(cfg.MaxSeconds, 'g', -1, 64))
+	}
+	cmd := exec.Command(cfg.Ectorus, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg[strings.LastIndexByte(msg, '\n')+1:])
+		}
+		return nil, fmt.Errorf("ectorus: %w", err)
+	}
+	b, err := os.ReadFile(resPath)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Complete bool `json:"complete"`
+		Found    []struct {
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			Inf bool   `json:"inf"`
+		} `json:"found"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("ectorus result: %w", err)
+	}
+	if !out.Complete {
+		return nil, errors.New("ectorus stopped before the walk was complete (raise --max-seconds)")
+	}
+	var pts [][2]uint64
+	for _, pt := range out.Found {
+		if pt.Inf {
+			continue
+		}
+		x, errX := strconv.ParseUint(pt.X, 10, 64)
+		y, errY := strconv.ParseUint(pt.Y, 10, 64)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("ectorus result: bad point (%s, %s)", pt.X, pt.Y)
+		}
+		pts = append(pts, [2]uint64{x, y})
+	}
+	return pts, nil
+}
+
+// compare scans prm's curve with ecscan and diffs the result against the
+// walked points.
+func compare(ctx context.Context, prm ecscan.Params, walked [][2]uint64) (*Result, error) {
+	left := make(map[[2]uint64]bool, len(walked))
+	for _, k := range walked {
+		left[k] = true
+	}
+	res := &Result{
+		P: strconv.FormatUint(prm.P, 10), A: strconv.FormatUint(prm.A, 10), B: strconv.FormatUint(prm.B, 10),
+		Walked: len(left),
+	}
+	err := ecscan.Scan(ctx, prm, func(x, y uint64) error {
+		res.Scanned++
+		k := [2]uint64{x, y}
+		if left[k] {
+			delete(left, k)
+		} else if len(res.OnlyScan) < listMax {
+			res.OnlyScan = append(res.OnlyScan, point(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.Match = len(left) == 0 && res.Walked == res.Scanned
+	for _, k := range walked {
+		if len(res.OnlyWalk) == listMax {
+			break
+		}
+		if left[k] {
+			res.OnlyWalk = append(res.OnlyWalk, point(k))
+			delete(left, k) // listed once, however often it was walked
+		}
+	}
+	return res, nil
+}
+
+func point(k [2]uint64) Point {
+	return Point{X: strconv.FormatUint(k[0], 10), Y: strconv.FormatUint(k[1], 10)}
+}
+
+func (r *Result) print(w io.Writer) {
+	fmt.Fprintf(w, "E: y^2 = x^3 + %sx + %s over F_%s\n", r.A, r.B, r.P)
+	fmt.Fprintf(w, "walk %d affine points, scan %d — ", r.Walked, r.Scanned)
+	if r.Match {
+		fmt.Fprintln(w, "match")
+		return
+	}
+	fmt.Fprintln(w, "MISMATCH")
+	for _, pt := range r.OnlyWalk {
+		fmt.Fprintf(w, "  only in walk: (%s, %s)\n", pt.X, pt.Y)
+	}
+	for _, pt := range r.OnlyScan {
+		fmt.Fprintf(w, "  only in scan: (%s, %s)\n", pt.X, pt.Y)
+	}
+}
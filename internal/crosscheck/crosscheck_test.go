@@ -0,0 +1,41 @@
+package crosscheck
+
+import (
+	"context"
+	"testing"
+
+	"ectorus/internal/ecscan"
+)
+
+func TestCompare(t *testing.T) {
+	prm := ecscan.Params{P: 1009, A: 2, B: 3}
+	var all [][2]uint64
+	if err := ecscan.Scan(context.Background(), prm, func(x, y uint64) error {
+		all = append(all, [2]uint64{x, y})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := compare(context.Background(), prm, all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Match || res.Walked != len(all) || res.Scanned != len(all) {
+		t.Fatalf("the scan's own points: %+v", res)
+	}
+
+	// one point missed by the walk, one it made up (not on the curve)
+	walked := append([][2]uint64{{0, 0}}, all[1:]...)
+	if res, err = compare(context.Background(), prm, walked); err != nil {
+		t.Fatal(err)
+	}
+	if res.Match {
+		t.Fatal("a wrong walk matched")
+	}
+	if len(res.OnlyWalk) != 1 || res.OnlyWalk[0] != (Point{"0", "0"}) {
+		t.Fatalf("only in walk: %v, want (0, 0)", res.OnlyWalk)
+	}
+	if len(res.OnlyScan) != 1 || res.OnlyScan[0] != point(all[0]) {
+		t.Fatalf("only in scan: %v, want %v", res.OnlyScan, point(all[0]))
+	}
+}
@@ -0,0 +1,159 @@
+package ec
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// ---------- curve & group law ----------
+
+// Curve is y^2 = x^3 + A x + B over F_P, with A and B reduced mod P.
+type Curve struct{ P, A, B *big.Int }
+
+// Point is an affine point, or the point at infinity O when Inf is set.
+type Point struct {
+	X, Y *big.Int
+	Inf  bool
+}
+
+// IsSingular reports whether Δ = -16(4A^3 + 27B^2) ≡ 0 mod p,
+// i.e., the curve is singular over F_p. We just test 4A^3 + 27B^2 ≡ 0.
+func (c Curve) IsSingular() bool {
+	p := c.P
+	A2 := MulM(c.A, c.A, p)
+	A3 := MulM(A2, c.A, p)
+	term := AddM(MulM(big.NewInt(4), A3, p), MulM(big.NewInt(27), MulM(c.B, c.B, p), p), p)
+	return term.Sign() == 0
+}
+
+// Discriminant returns Δ = -16(4A^3 + 27B^2) mod p.
+func (c Curve) Discriminant() *big.Int {
+	p := c.P
+	term := AddM(MulM(big.NewInt(4), MulM(c.A, MulM(c.A, c.A, p), p), p), MulM(big.NewInt(27), MulM(c.B, c.B, p), p), p)
+	return MulM(big.NewInt(-16), term, p)
+}
+
+// JInvariant returns j = 1728 · 4A^3 / (4A^3 + 27B^2), which classifies c up
+// to isomorphism over the algebraic closure.
+func (c Curve) JInvariant() (*big.Int, error) {
+	p := c.P
+	a3 := MulM(big.NewInt(4), MulM(c.A, MulM(c.A, c.A, p), p), p)
+	inv, err := InvM(AddM(a3, MulM(big.NewInt(27), MulM(c.B, c.B, p), p), p), p)
+	if err != nil {
+		return nil, errors.New("j-invariant of a singular curve")
+	}
+	return MulM(MulM(big.NewInt(1728), a3, p), inv, p), nil
+}
+
+// RandomPoint returns an affine point of c with x drawn from rnd, retrying
+// while x^3 + Ax + B is a non-residue (a few hundred tries at most).
+func (c Curve) RandomPoint(rnd io.Reader) (Point, error) {
+	p := c.P
+	for tries := 0; tries < 512; tries++ {
+		x, err := rand.Int(rnd, p)
+		if err != nil {
+			return Point{}, err
+		}
+		rhs := AddM(AddM(MulM(x, MulM(x, x, p), p), MulM(c.A, x, p), p), c.B, p)
+		y, err := SqrtModP(rhs, p)
+		if err != nil {
+			continue
+		}
+		if tries&1 == 1 {
+			y = NegM(y, p)
+		}
+		return Point{X: x, Y: y}, nil
+	}
+	return Point{}, errors.New("no point found on curve")
+}
+
+// On reports whether Pt lies on c (O always does).
+func (c Curve) On(Pt Point) bool {
+	if Pt.Inf {
+		return true
+	}
+	x3 := MulM(Pt.X, MulM(Pt.X, Pt.X, c.P), c.P)
+	rhs := AddM(AddM(x3, MulM(c.A, Pt.X, c.P), c.P), c.B, c.P)
+	y2 := MulM(Pt.Y, Pt.Y, c.P)
+	return y2.Cmp(rhs) == 0
+}
+
+// Neg returns -Pt.
+func (c Curve) Neg(Pt Point) Point {
+	if Pt.Inf {
+		return Pt
+	}
+	return Point{X: new(big.Int).Set(Pt.X), Y: NegM(Pt.Y, c.P)}
+}
+
+// Add returns P + Q by the chord-and-tangent law. The only error is a failed
+// inversion, which cannot happen for prime p.
+func (c Curve) Add(P, Q Point) (Point, error) {
+	p := c.P
+	if P.Inf {
+		return Q, nil
+	}
+	if Q.Inf {
+		return P, nil
+	}
+	if P.X.Cmp(Q.X) == 0 {
+		// P==±Q
+		ysum := Mod(new(big.Int).Add(P.Y, Q.Y), p)
+		if ysum.Sign() == 0 {
+			return Point{Inf: true}, nil
+		} // P == -Q -> O
+		// Doubling
+		if P.Y.Sign() == 0 {
+			return Point{Inf: true}, nil
+		} // vertical tangent
+		num := AddM(MulM(big.NewInt(3), MulM(P.X, P.X, p), p), c.A, p)
+		den := MulM(big.NewInt(2), P.Y, p)
+		inv, err := InvM(den, p)
+		if err != nil {
+			return Point{}, err
+		}
+		lam := MulM(num, inv, p)
+		xr := SubM(SubM(MulM(lam, lam, p), P.X, p), Q.X, p)
+		yr := SubM(MulM(lam, SubM(P.X, xr, p), p), P.Y, p)
+		return Point{X: xr, Y: yr}, nil
+	}
+	// Secant
+	num := SubM(Q.Y, P.Y, p)
+	den := SubM(Q.X, P.X, p)
+	inv, err := InvM(den, p)
+	if err != nil {
+		return Point{}, err
+	}
+	lam := MulM(num, inv, p)
+	xr := SubM(SubM(MulM(lam, lam, p), P.X, p), Q.X, p)
+	yr := SubM(MulM(lam, SubM(P.X, xr, p), p), P.Y, p)
+	return Point{X: xr, Y: yr}, nil
+}
+
+// Double returns 2P.
+func (c Curve) Double(P Point) (Point, error) { return c.Add(P, P) }
+
+// Equal reports whether P and Q are the same point.
+func (P Point) Equal(Q Point) bool {
+	if P.Inf || Q.Inf {
+		return P.Inf == Q.Inf
+	}
+	return P.X.Cmp(Q.X) == 0 && P.Y.Cmp(Q.Y) == 0
+}
+
+// QuadraticTwist returns E^d: y^2 = x^3 + A d^2 x + B d^3 for the least
+// quadratic non-residue d mod p, together with d. Every x with f(x) a
+// non-residue on E shows up (as d·x) on E^d, which is why
+// #E + #E^d = 2p + 2.
+func (c Curve) QuadraticTwist() (Curve, *big.Int) {
+	p := c.P
+	d := big.NewInt(2)
+	for Legendre(d, p) != -1 {
+		d.Add(d, big.NewInt(1))
+	}
+	d2 := MulM(d, d, p)
+	d3 := MulM(d2, d, p)
+	return Curve{P: p, A: MulM(c.A, d2, p), B: MulM(c.B, d3, p)}, d
+}
@@ -0,0 +1,774 @@
+package ec
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func bi(v int64) *big.Int { return big.NewInt(v) }
+
+// firstPoint returns the affine point with the least x on c (tiny p only).
+func firstPoint(t *testing.T, c Curve) Point {
+	t.Helper()
+	for x := int64(0); x < c.P.Int64(); x++ {
+		rhs := AddM(AddM(MulM(bi(x), MulM(bi(x), bi(x), c.P), c.P), MulM(c.A, bi(x), c.P), c.P), c.B, c.P)
+		if y, err := SqrtModP(rhs, c.P); err == nil {
+			return Point{X: bi(x), Y: y}
+		}
+	}
+	t.Fatal("no affine point")
+	return Point{}
+}
+
+func TestWNAFDigits(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		k := new(big.Int).Rand(r, new(big.Int).Lsh(bi(1), 80))
+		d := wNAF(k, wnafWidth)
+		sum := new(big.Int)
+		for j := len(d) - 1; j >= 0; j-- {
+			sum.Lsh(sum, 1)
+			sum.Add(sum, bi(int64(d[j])))
+			if d[j] != 0 {
+				if d[j]%2 == 0 || d[j] >= 1<<(wnafWidth-1) || d[j] <= -(1<<(wnafWidth-1)) {
+					t.Fatalf("bad digit %d", d[j])
+				}
+				for n := 1; n < wnafWidth && j+n < len(d); n++ {
+					if d[j+n] != 0 {
+						t.Fatalf("digits %d and %d both nonzero", j, j+n)
+					}
+				}
+			}
+		}
+		if sum.Cmp(k) != 0 {
+			t.Fatalf("wNAF(%v) reconstructs to %v", k, sum)
+		}
+	}
+}
+
+func TestScalarMulMatchesBinary(t *testing.T) {
+	c := Curve{P: bi(10007), A: bi(2), B: bi(3)}
+	P := firstPoint(t, c)
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		k := new(big.Int).Rand(r, bi(1<<40))
+		got, err := c.ScalarMul(k, P)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := c.ScalarMulBinary(k, P)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("k=%v: wNAF %+v != binary %+v", k, got, want)
+		}
+		neg, err := c.ScalarMul(new(big.Int).Neg(k), P)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !neg.Equal(c.Neg(want)) {
+			t.Fatalf("k=%v: (-k)P != -(kP)", k)
+		}
+	}
+}
+
+func TestScalarMulGroupOrder(t *testing.T) {
+	// y^2 = x^3 + 1 over p=11 is supersingular: #E = 12.
+	c := Curve{P: bi(11), A: bi(0), B: bi(1)}
+	P := firstPoint(t, c)
+	R, err := c.ScalarMul(bi(12), P)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !R.Inf {
+		t.Fatalf("12·P = %+v, want O", R)
+	}
+}
+
+func TestScalarMulJacobian(t *testing.T) {
+	// every multiple of every point of a small curve, through O and the
+	// 2-torsion: #E(F_103) = 104 for y^2 = x^3 + x
+	c := Curve{P: bi(103), A: bi(1), B: bi(0)}
+	for x := int64(0); x < 103; x++ {
+		for _, P := range []Point{{X: bi(x), Y: bi(0)}} {
+			rhs := AddM(MulM(bi(x), MulM(bi(x), bi(x), c.P), c.P), bi(x), c.P)
+			y, err := SqrtModP(rhs, c.P)
+			if err != nil {
+				continue
+			}
+			P.Y = y
+			for k := int64(-3); k <= 106; k++ {
+				got, err := c.ScalarMulJacobian(bi(k), P)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, _ := c.ScalarMul(bi(k), P)
+				if !got.Equal(want) {
+					t.Fatalf("%d·(%d, %s): Jacobian %+v, affine %+v", k, x, y, got, want)
+				}
+			}
+		}
+	}
+	// and random scalars on P-256
+	params := elliptic.P256().Params()
+	c = Curve{P: params.P, A: new(big.Int).Sub(params.P, bi(3)), B: params.B}
+	G := Point{X: params.Gx, Y: params.Gy}
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 20; i++ {
+		k := new(big.Int).Rand(r, params.N)
+		got, err := c.ScalarMulJacobian(k, G)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, y := elliptic.P256().ScalarBaseMult(k.Bytes())
+		if got.X.Cmp(x) != 0 || got.Y.Cmp(y) != 0 {
+			t.Fatalf("k=%v: (%v, %v), want (%v, %v)", k, got.X, got.Y, x, y)
+		}
+	}
+}
+
+func BenchmarkScalarMul(b *testing.B) {
+	params := elliptic.P256().Params()
+	c := Curve{P: params.P, A: new(big.Int).Sub(params.P, bi(3)), B: params.B}
+	G := Point{X: params.Gx, Y: params.Gy}
+	k := new(big.Int).Sub(params.N, bi(12345))
+	for _, bc := range []struct {
+		name string
+		mul  func(*big.Int, Point) (Point, error)
+	}{{"affine", c.ScalarMul}, {"jacobian", c.ScalarMulJacobian}} {
+		b.Run(bc.name, func(b *testing.B) {
+			for range b.N {
+				if _, err := bc.mul(k, G); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchInvM(t *testing.T) {
+	p := bi(10007)
+	r := rand.New(rand.NewSource(4))
+	as := make([]*big.Int, 50)
+	for i := range as {
+		as[i] = bi(1 + r.Int63n(10006))
+	}
+	invs, err := BatchInvM(as, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, a := range as {
+		if MulM(a, invs[i], p).Cmp(bi(1)) != 0 {
+			t.Fatalf("as[%d] = %v: batch inverse %v is wrong", i, a, invs[i])
+		}
+	}
+	if _, err := BatchInvM([]*big.Int{bi(3), bi(0)}, p); err == nil {
+		t.Fatal("batch with a zero succeeded")
+	}
+}
+
+func TestQuadraticTwistIsNonResidue(t *testing.T) {
+	c := Curve{P: bi(101), A: bi(2), B: bi(3)}
+	tw, d := c.QuadraticTwist()
+	if Legendre(d, c.P) != -1 {
+		t.Fatalf("d=%v is a residue", d)
+	}
+	if tw.IsSingular() {
+		t.Fatal("twist is singular")
+	}
+}
+
+// montgomeryPoints lists the affine points of m (tiny p only).
+func montgomeryPoints(m Montgomery) []Point {
+	var pts []Point
+	for x := int64(0); x < m.P.Int64(); x++ {
+		rhs, _ := m.RHS(bi(x))
+		if y, err := SqrtModP(rhs, m.P); err == nil {
+			pts = append(pts, Point{X: bi(x), Y: y})
+			if y.Sign() != 0 {
+				pts = append(pts, Point{X: bi(x), Y: NegM(y, m.P)})
+			}
+		}
+	}
+	return pts
+}
+
+func TestMontgomeryWeierstrassIsHomomorphism(t *testing.T) {
+	m := Montgomery{P: bi(101), A: bi(6), B: bi(1)}
+	w, err := m.ToWeierstrass()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pts := montgomeryPoints(m)
+	for i := 0; i+1 < len(pts); i += 7 {
+		P, Q := pts[i], pts[i+1]
+		S, err := m.Add(P, Q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wP, _ := m.PointToWeierstrass(P)
+		wQ, _ := m.PointToWeierstrass(Q)
+		wS, _ := m.PointToWeierstrass(S)
+		if !w.On(wP) || !w.On(wS) {
+			t.Fatal("mapped point not on Weierstrass curve")
+		}
+		sum, err := w.Add(wP, wQ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sum.Equal(wS) {
+			t.Fatalf("φ(P+Q) != φ(P)+φ(Q) for P=%v Q=%v", P, Q)
+		}
+		back, _ := m.PointFromWeierstrass(wP)
+		if !back.Equal(P) {
+			t.Fatal("Weierstrass round trip failed")
+		}
+	}
+	m2, err := MontgomeryFromWeierstrass(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2, _ := m2.ToWeierstrass()
+	if w2.A.Cmp(w.A) != 0 || w2.B.Cmp(w.B) != 0 {
+		t.Fatalf("MontgomeryFromWeierstrass does not round trip: %v vs %v", w2, w)
+	}
+}
+
+func TestEdwardsMontgomeryIsHomomorphism(t *testing.T) {
+	// d = 2 is a non-residue mod 101, so the Edwards law is complete.
+	e := Edwards{P: bi(101), A: bi(1), D: bi(2)}
+	m, err := e.ToMontgomery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pts []Point
+	for _, P := range montgomeryPoints(m) {
+		E, err := e.PointFromMontgomery(P)
+		if err != nil {
+			continue
+		}
+		if !e.On(E) {
+			t.Fatalf("%v maps off the Edwards curve", P)
+		}
+		pts = append(pts, E)
+	}
+	for i := 0; i+1 < len(pts); i += 5 {
+		P, Q := pts[i], pts[i+1]
+		S, err := e.Add(P, Q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mP, _ := e.PointToMontgomery(P)
+		mQ, _ := e.PointToMontgomery(Q)
+		mS, _ := e.PointToMontgomery(S)
+		sum, err := m.Add(mP, mQ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sum.Equal(mS) {
+			t.Fatalf("ψ(P+Q) != ψ(P)+ψ(Q) for P=%v Q=%v", P, Q)
+		}
+	}
+	id, _ := e.PointToMontgomery(e.Identity())
+	if !id.Inf {
+		t.Fatal("Edwards identity should map to O")
+	}
+}
+
+// generalPoints lists every affine point of g (tiny p only).
+func generalPoints(g General) []Point {
+	var pts []Point
+	for x := int64(0); x < g.P.Int64(); x++ {
+		for y := int64(0); y < g.P.Int64(); y++ {
+			if P := (Point{X: bi(x), Y: bi(y)}); g.On(P) {
+				pts = append(pts, P)
+			}
+		}
+	}
+	return pts
+}
+
+func TestGeneralWeierstrass(t *testing.T) {
+	// Cremona 11a1 = [0,-1,1,-10,-20] has a_13 = 4, so #E(F_13) = 10.
+	p := bi(13)
+	g := General{P: p, A1: bi(0), A2: Mod(bi(-1), p), A3: bi(1), A4: Mod(bi(-10), p), A6: Mod(bi(-20), p)}
+	if g.IsSingular() {
+		t.Fatal("11a1 has good reduction at 13")
+	}
+	pts := generalPoints(g)
+	if len(pts)+1 != 10 {
+		t.Fatalf("#E(F_13) = %d, want 10", len(pts)+1)
+	}
+	w, err := g.ToWeierstrass()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, P := range pts {
+		if S, _ := g.Add(P, g.Neg(P)); !S.Inf {
+			t.Fatalf("P + (-P) != O for %v", P)
+		}
+		for _, Q := range pts {
+			S, err := g.Add(P, Q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !g.On(S) {
+				t.Fatalf("%v + %v = %v not on curve", P, Q, S)
+			}
+			wP, _ := g.PointToWeierstrass(P)
+			wQ, _ := g.PointToWeierstrass(Q)
+			wS, _ := g.PointToWeierstrass(S)
+			sum, err := w.Add(wP, wQ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sum.Equal(wS) {
+				t.Fatalf("φ(P+Q) != φ(P)+φ(Q) for P=%v Q=%v", P, Q)
+			}
+		}
+	}
+
+	// a short curve viewed as a long one adds the same way
+	c := Curve{P: bi(101), A: bi(2), B: bi(3)}
+	P := firstPoint(t, c)
+	Q, _ := c.Double(P)
+	want, _ := c.Add(P, Q)
+	got, _ := c.General().Add(P, Q)
+	if !got.Equal(want) {
+		t.Fatalf("General().Add = %v, want %v", got, want)
+	}
+	if c.General().Discriminant().Sign() == 0 {
+		t.Fatal("nonsingular short curve has Δ = 0")
+	}
+}
+
+func TestParseBig(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"12345", "12345"},
+		{" 0x2a ", "42"},
+		{"0XfF", "255"},
+		{"2^61-1", "2305843009213693951"},
+		{"2 ** 255 - 19", new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19)).String()},
+		{"-3", "-3"},
+		{"2^2^3", "256"}, // right-associative
+		{"-2^2", "-4"},
+		{"2*3+4*5", "26"},
+		{"(1+2)*(3-10)", "-21"},
+		{"0x10 * 2^4 + 1", "257"},
+	} {
+		z, err := ParseBig(tc.in)
+		if err != nil || z.String() != tc.want {
+			t.Errorf("ParseBig(%q) = %v, %v; want %s", tc.in, z, err, tc.want)
+		}
+	}
+	for _, bad := range []string{"", "12a", "0x", "2^", "(1+2", "1 2", "2^-1", "2^2^40", "3**"} {
+		if z, err := ParseBig(bad); err == nil {
+			t.Errorf("ParseBig(%q) = %v, want an error", bad, z)
+		}
+	}
+}
+
+func TestNamedCurves(t *testing.T) {
+	for _, name := range CurveNames() {
+		nc, err := LookupCurve(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := nc.Weierstrass()
+		if err != nil || !c.P.ProbablyPrime(20) || c.IsSingular() {
+			t.Fatalf("%s: %+v, %v", name, c, err)
+		}
+	}
+
+	// the NIST curves against crypto/elliptic
+	for name, params := range map[string]*elliptic.CurveParams{
+		"P-224": elliptic.P224().Params(), "secp256r1": elliptic.P256().Params(),
+		"p384": elliptic.P384().Params(), "nist-p521": elliptic.P521().Params(),
+	} {
+		nc, err := LookupCurve(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, _ := nc.Weierstrass()
+		if c.P.Cmp(params.P) != 0 || c.B.Cmp(params.B) != 0 || c.A.Cmp(new(big.Int).Sub(params.P, bi(3))) != 0 {
+			t.Fatalf("%s does not match %s", name, params.Name)
+		}
+	}
+
+	// published generators lie on their curves
+	hex := func(s string) *big.Int { z, _ := new(big.Int).SetString(s, 16); return z }
+	for name, G := range map[string]Point{
+		"secp256k1": {X: hex("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"), Y: hex("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8")},
+		"bn254":     {X: bi(1), Y: bi(2)},
+		"bls12-381": {X: hex("17f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb"), Y: hex("08b3f481e3aaa0f1a09e30ed741d8ae4fcf5e095d5d00af600db18cb2c04b3edd03cc744a2888ae40caa232946c5e7e1")},
+	} {
+		nc, _ := LookupCurve(name)
+		c, _ := nc.Weierstrass()
+		if !c.On(G) {
+			t.Fatalf("%s: generator not on the curve", name)
+		}
+	}
+
+	// -wei converts; curve25519 and ed25519 are the same curve up to isomorphism
+	mont, err := LookupCurve("Curve25519-wei")
+	if err != nil || mont.Form != "weierstrass" || mont.A != hex("2aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa984914a144").String() {
+		t.Fatalf("curve25519-wei = %+v, %v", mont, err)
+	}
+	edw, _ := LookupCurve("ed25519-wei")
+	cm, _ := mont.Weierstrass()
+	ce, _ := edw.Weierstrass()
+	jm, _ := cm.JInvariant()
+	je, _ := ce.JInvariant()
+	if jm.Cmp(je) != 0 {
+		t.Fatalf("j(curve25519) = %s, j(ed25519) = %s", jm, je)
+	}
+	if _, err := LookupCurve("p257"); err == nil {
+		t.Fatal("unknown curve accepted")
+	}
+}
+
+func TestInvMFactor(t *testing.T) {
+	_, err := InvM(bi(21), bi(77))
+	var ni *NotInvertibleError
+	if !errors.As(err, &ni) || ni.Factor().Int64() != 7 {
+		t.Fatalf("InvM(21, 77): %v", err)
+	}
+	if _, err := InvM(bi(0), bi(77)); !errors.As(err, &ni) || ni.Factor().Int64() != 77 || err.Error() != "inverse of zero" {
+		t.Fatalf("InvM(0, 77): %v", err)
+	}
+}
+
+// torsion returns E(F_p)[m] by brute force.
+func torsion(c Curve, m int64) []Point {
+	var out []Point
+	for x := int64(0); x < c.P.Int64(); x++ {
+		for y := int64(0); y < c.P.Int64(); y++ {
+			P := Point{X: bi(x), Y: bi(y)}
+			if !c.On(P) {
+				continue
+			}
+			if mP, _ := c.ScalarMul(bi(m), P); mP.Inf {
+				out = append(out, P)
+			}
+		}
+	}
+	return out
+}
+
+func TestWeilPairing(t *testing.T) {
+	// E(F_p) ⊇ Z/m × Z/m: the Weil pairing is non-degenerate on it
+	for _, tc := range []struct{ p, A, B, m int64 }{{43, 0, 1, 3}, {101, 2, 26, 5}} {
+		c := Curve{P: bi(tc.p), A: bi(tc.A), B: bi(tc.B)}
+		E := torsion(c, tc.m)
+		if int64(len(E)) != tc.m*tc.m-1 {
+			t.Fatalf("p=%d A=%d B=%d: %d affine points of order | %d, want full torsion", tc.p, tc.A, tc.B, len(E), tc.m)
+		}
+		m := bi(tc.m)
+		nontrivial := false
+		for _, P := range E[:4] {
+			for _, Q := range E {
+				e, err := c.WeilPairing(P, Q, m)
+				if err != nil {
+					t.Fatalf("e(%v, %v): %v", P, Q, err)
+				}
+				if PowM(e, m, c.P).Cmp(bi(1)) != 0 {
+					t.Fatalf("e(P, Q) = %s is no %d-th root of unity", e, tc.m)
+				}
+				nontrivial = nontrivial || e.Cmp(bi(1)) != 0
+				back, _ := c.WeilPairing(Q, P, m)
+				if MulM(e, back, c.P).Cmp(bi(1)) != 0 {
+					t.Fatalf("e(P, Q)·e(Q, P) ≠ 1")
+				}
+				// e(2P, Q) = e(P, Q)^2 and e(P, P + Q) = e(P, Q)
+				P2, _ := c.Double(P)
+				e2, _ := c.WeilPairing(P2, Q, m)
+				PQ, _ := c.Add(P, Q)
+				ePQ, _ := c.WeilPairing(P, PQ, m)
+				if e2.Cmp(MulM(e, e, c.P)) != 0 || ePQ.Cmp(e) != 0 {
+					t.Fatalf("p=%d: pairing not bilinear at P=%v Q=%v", tc.p, P, Q)
+				}
+			}
+		}
+		if !nontrivial {
+			t.Fatalf("p=%d: Weil pairing trivial on full %d-torsion", tc.p, tc.m)
+		}
+	}
+}
+
+func TestTatePairing(t *testing.T) {
+	// #E(F_101) = 100 for A=1, B=2, and 5 | p-1: embedding degree 1
+	c := Curve{P: bi(101), A: bi(1), B: bi(2)}
+	m := bi(5)
+	P := torsion(c, 5)[0]
+	nontrivial := false
+	for x := int64(0); x < 101; x++ {
+		rhs := AddM(AddM(MulM(bi(x), MulM(bi(x), bi(x), c.P), c.P), bi(x), c.P), bi(2), c.P)
+		y, err := SqrtModP(rhs, c.P)
+		if err != nil {
+			continue
+		}
+		Q := Point{X: bi(x), Y: y}
+		v, err := c.TatePairing(P, Q, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nontrivial = nontrivial || v.Cmp(bi(1)) != 0
+		P3, _ := c.ScalarMul(bi(3), P)
+		v3, _ := c.TatePairing(P3, Q, m)
+		Q2, _ := c.Double(Q)
+		w2, _ := c.TatePairing(P, Q2, m)
+		if v3.Cmp(PowM(v, bi(3), c.P)) != 0 || w2.Cmp(MulM(v, v, c.P)) != 0 {
+			t.Fatalf("Tate pairing not bilinear at Q=%v", Q)
+		}
+	}
+	if !nontrivial {
+		t.Fatal("Tate pairing trivial")
+	}
+	if _, err := (Curve{P: bi(103), A: bi(1), B: bi(8)}).TatePairing(P, P, bi(5)); err == nil {
+		t.Fatal("m ∤ p-1 accepted")
+	}
+}
+
+func TestIsogenyFromKernel(t *testing.T) {
+	// #E(F_101) = 100 and E(F_101) ⊇ E[5]
+	c := Curve{P: bi(101), A: bi(2), B: bi(26)}
+	all := torsion(c, 100)
+	E5 := torsion(c, 5)
+	// a second generator of E[5], outside <E5[0]>
+	inFirst := make(map[string]bool)
+	for k := int64(1); k < 5; k++ {
+		R, _ := c.ScalarMul(bi(k), E5[0])
+		inFirst[R.X.String()+","+R.Y.String()] = true
+	}
+	var T Point
+	for _, P := range E5 {
+		if !inFirst[P.X.String()+","+P.Y.String()] {
+			T = P
+			break
+		}
+	}
+	var E2 []Point
+	for _, P := range all {
+		if P.Y.Sign() == 0 {
+			E2 = append(E2, P)
+		}
+	}
+	for _, tc := range []struct {
+		name   string
+		kernel []Point
+		degree int
+	}{
+		{"5-isogeny", E5[:1], 5},
+		{"2-isogeny", E2[:1], 2},
+		{"kernel E[5]", []Point{E5[0], T}, 25},
+	} {
+		phi, err := c.IsogenyFromKernel(tc.kernel)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if phi.Degree() != tc.degree {
+			t.Fatalf("%s: degree %d, want %d", tc.name, phi.Degree(), tc.degree)
+		}
+		E2c := phi.Codomain
+		if E2c.IsSingular() {
+			t.Fatalf("%s: singular codomain", tc.name)
+		}
+		images := 0
+		for i, P := range all {
+			fP, err := phi.Map(P)
+			if err != nil || !E2c.On(fP) {
+				t.Fatalf("%s: φ(%v) = %v not on the codomain (%v)", tc.name, P, fP, err)
+			}
+			if fP.Inf {
+				images++
+			}
+			Q := all[(7*i+3)%len(all)]
+			PQ, _ := c.Add(P, Q)
+			fQ, _ := phi.Map(Q)
+			lhs, _ := phi.Map(PQ)
+			rhs, _ := E2c.Add(fP, fQ)
+			if !lhs.Equal(rhs) {
+				t.Fatalf("%s: φ(P+Q) ≠ φ(P)+φ(Q)", tc.name)
+			}
+		}
+		if images+1 != phi.Degree() {
+			t.Fatalf("%s: %d points map to O, kernel has %d", tc.name, images+1, phi.Degree())
+		}
+		if tc.degree == 25 {
+			// E → E/E[5] is [5] up to isomorphism
+			j, _ := c.JInvariant()
+			j2, _ := E2c.JInvariant()
+			if j.Cmp(j2) != 0 {
+				t.Fatalf("j(E/E[5]) = %s, j(E) = %s", j2, j)
+			}
+		}
+	}
+}
+
+func TestPolyRoots(t *testing.T) {
+	p := bi(103) // x^2 + 1 is irreducible mod 103
+	lin := func(r int64) Poly { return NewPoly(p, bi(-r), bi(1)) }
+	f := PolyMul(PolyMul(lin(3), lin(5), p), PolyMul(lin(5), NewPoly(p, bi(1), bi(0), bi(1)), p), p)
+	f = PolyMul(f, lin(77), p)
+	roots := f.Roots(p)
+	if len(roots) != 3 || roots[0].Int64() != 3 || roots[1].Int64() != 5 || roots[2].Int64() != 77 {
+		t.Fatalf("roots %v, want [3 5 77]", roots)
+	}
+	q, r := PolyDivMod(f, lin(77), p)
+	if len(r) != 0 || q.Degree() != f.Degree()-1 {
+		t.Fatalf("f / (x - 77): q = %v, r = %v", q, r)
+	}
+	if roots := NewPoly(p, bi(1), bi(0), bi(1)).Roots(p); len(roots) != 0 {
+		t.Fatalf("x^2 + 1 has roots %v mod 103", roots)
+	}
+}
+
+func TestDivisionPolynomial(t *testing.T) {
+	c := Curve{P: bi(101), A: bi(2), B: bi(26)}
+	for _, l := range []int64{3, 5, 7} {
+		psi := c.DivisionPolynomial(int(l))
+		if psi.Degree() != int(l*l-1)/2 || psi[len(psi)-1].Int64() != l {
+			t.Fatalf("ψ_%d: degree %d, leading %s", l, psi.Degree(), psi[len(psi)-1])
+		}
+		want := make(map[int64]bool)
+		for _, P := range torsion(c, l) {
+			want[P.X.Int64()] = true
+		}
+		got := make(map[int64]bool)
+		for _, x := range psi.Roots(c.P) {
+			rhs := AddM(AddM(MulM(x, MulM(x, x, c.P), c.P), MulM(c.A, x, c.P), c.P), c.B, c.P)
+			if Legendre(rhs, c.P) >= 0 {
+				got[x.Int64()] = true
+			}
+		}
+		if len(got) != len(want) {
+			t.Fatalf("ψ_%d: rational x %v, torsion x %v", l, got, want)
+		}
+		for x := range want {
+			if !got[x] {
+				t.Fatalf("ψ_%d: torsion x = %d is not a root", l, x)
+			}
+		}
+	}
+}
+
+func TestRationalTorsion(t *testing.T) {
+	for _, tc := range []struct{ p, A, B int64 }{{101, 2, 26}, {43, 0, 1}, {103, 1, 0}, {97, 5, 7}} {
+		c := Curve{P: bi(tc.p), A: bi(tc.A), B: bi(tc.B)}
+		for m := 2; m <= 9; m++ {
+			pts, err := c.RationalTorsion(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := torsion(c, int64(m))
+			if len(pts) != len(want) {
+				t.Fatalf("p=%d A=%d B=%d: %d affine points in E[%d], want %d", tc.p, tc.A, tc.B, len(pts), m, len(want))
+			}
+			for _, P := range pts {
+				if mP, _ := c.ScalarMul(bi(int64(m)), P); !c.On(P) || !mP.Inf {
+					t.Fatalf("(%s, %s) is not in E[%d]", P.X, P.Y, m)
+				}
+			}
+			// the exponent of E(F_p)[m] is the largest order in it
+			d1, d2, err := c.TorsionStructure(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := int64(1)
+			for _, P := range want {
+				for k := int64(1); k <= int64(m); k++ {
+					if kP, _ := c.ScalarMul(bi(k), P); kP.Inf {
+						exp = max(exp, k)
+						break
+					}
+				}
+			}
+			if d1*d2 != len(want)+1 || int64(d1) != exp || d1%d2 != 0 {
+				t.Fatalf("p=%d A=%d B=%d: E(F_p)[%d] = Z/%d × Z/%d, but %d points of exponent %d", tc.p, tc.A, tc.B, m, d1, d2, len(want)+1, exp)
+			}
+			k, err := c.FullTorsionDegree(m, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (k == 1) != (d2 == m) {
+				t.Fatalf("p=%d A=%d B=%d: E[%d] rational over F_p: %v, but E(F_p)[%d] = Z/%d × Z/%d", tc.p, tc.A, tc.B, m, k == 1, m, d1, d2)
+			}
+		}
+	}
+}
+
+func TestFullTorsionDegree(t *testing.T) {
+	// y^2 = x^3 + x over F_103 is supersingular with π^2 = -103 on every
+	// E[m], so E(F_{103^2}) ≅ Z/104 × Z/104 and otherwise k is the order
+	// of π, from the order of -103 mod m
+	c := Curve{P: bi(103), A: bi(1), B: bi(0)}
+	for _, tc := range []struct{ m, k int }{{2, 2}, {3, 4}, {4, 2}, {5, 8}, {7, 6}, {8, 2}, {13, 2}} {
+		k, err := c.FullTorsionDegree(tc.m, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k != tc.k {
+			t.Fatalf("E[%d] is rational over F_{p^%d}, want k = %d", tc.m, k, tc.k)
+		}
+	}
+	if _, err := c.FullTorsionDegree(103, 1); err == nil {
+		t.Fatal("m = p was accepted")
+	}
+}
+
+func TestModularRoots(t *testing.T) {
+	// every rational 2- and 3-kernel's codomain is a root of Φ_ℓ(j, Y)
+	p := bi(101)
+	checked := 0
+	for a := int64(0); a < 10; a++ {
+		for b := int64(1); b < 10; b++ {
+			c := Curve{P: p, A: bi(a), B: bi(b)}
+			if c.IsSingular() {
+				continue
+			}
+			j, _ := c.JInvariant()
+			for _, l := range []int{2, 3} {
+				roots, err := ModularRoots(l, j, p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				psi := c.DivisionPolynomial(l)
+				if l == 2 {
+					psi = NewPoly(p, c.B, c.A, bi(0), bi(1))
+				}
+				for _, x := range psi.Roots(p) {
+					rhs := AddM(AddM(MulM(x, MulM(x, x, p), p), MulM(c.A, x, p), p), c.B, p)
+					y, err := SqrtModP(rhs, p)
+					if err != nil {
+						continue
+					}
+					phi, err := c.IsogenyFromKernel([]Point{{X: x, Y: y}})
+					if err != nil {
+						t.Fatal(err)
+					}
+					j2, _ := phi.Codomain.JInvariant()
+					found := false
+					for _, r := range roots {
+						found = found || r.Cmp(j2) == 0
+					}
+					if !found {
+						t.Fatalf("A=%d B=%d: %d-isogenous j = %s not among Φ roots %v", a, b, l, j2, roots)
+					}
+					checked++
+				}
+			}
+		}
+	}
+	if checked < 20 {
+		t.Fatalf("only %d isogenies checked", checked)
+	}
+	if _, err := ModularRoots(5, bi(1), p); err == nil {
+		t.Fatal("Φ_5 should be unavailable")
+	}
+}
@@ -0,0 +1,165 @@
+// Package ec implements prime-field arithmetic and the affine group law for
+// short Weierstrass curves y^2 = x^3 + A x + B over F_p, on math/big. It is
+// shared by the ectorus walker and the analysis tools.
+package ec
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Mod returns a mod p in [0, p).
+func Mod(a, p *big.Int) *big.Int {
+	z := new(big.Int).Mod(a, p)
+	if z.Sign() < 0 {
+		z.Add(z, p)
+	}
+	return z
+}
+
+// AddM, SubM, MulM and NegM return freshly allocated results reduced mod p.
+func AddM(a, b, p *big.Int) *big.Int { return Mod(new(big.Int).Add(a, b), p) }
+
+func SubM(a, b, p *big.Int) *big.Int { return Mod(new(big.Int).Sub(a, b), p) }
+
+func MulM(a, b, p *big.Int) *big.Int { return Mod(new(big.Int).Mul(a, b), p) }
+
+func NegM(a, p *big.Int) *big.Int { return SubM(new(big.Int), a, p) }
+
+// InvM returns a^-1 mod p, or a *NotInvertibleError if a is not invertible.
+func InvM(a, p *big.Int) (*big.Int, error) {
+	if a.Sign() == 0 {
+		return nil, &NotInvertibleError{A: new(big.Int), P: p}
+	}
+	inv := new(big.Int).ModInverse(a, p)
+	if inv == nil {
+		return nil, &NotInvertibleError{A: new(big.Int).Set(a), P: p}
+	}
+	return inv, nil
+}
+
+// NotInvertibleError reports a with gcd(a, p) ≠ 1. For prime p that means
+// a ≡ 0; for composite p the gcd may be a proper factor, which is how
+// Lenstra's ECM finds factors.
+type NotInvertibleError struct{ A, P *big.Int }
+
+func (e *NotInvertibleError) Error() string {
+	if g := e.Factor(); g.Cmp(e.P) != 0 {
+		return fmt.Sprintf("no inverse of %s mod %s: gcd %s", e.A, e.P, g)
+	}
+	return "inverse of zero"
+}
+
+// Factor returns gcd(A, P): a proper factor of P unless A ≡ 0.
+func (e *NotInvertibleError) Factor() *big.Int {
+	return new(big.Int).GCD(nil, nil, Mod(e.A, e.P), e.P)
+}
+
+// BatchInvM returns the inverses of as mod p using one ModInverse and
+// 3(n-1) multiplications (Montgomery's trick). It fails, as a whole, if any
+// element is not invertible.
+func BatchInvM(as []*big.Int, p *big.Int) ([]*big.Int, error) {
+	if len(as) == 0 {
+		return nil, nil
+	}
+	// prefix[i] = as[0]·…·as[i]
+	prefix := make([]*big.Int, len(as))
+	acc := big.NewInt(1)
+	for i, a := range as {
+		acc = MulM(acc, a, p)
+		prefix[i] = acc
+	}
+	inv, err := InvM(acc, p)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*big.Int, len(as))
+	for i := len(as) - 1; i > 0; i-- {
+		// inv = (as[0]·…·as[i])^-1
+		out[i] = MulM(inv, prefix[i-1], p)
+		inv = MulM(inv, as[i], p)
+	}
+	out[0] = inv
+	return out, nil
+}
+
+// PowM returns a^e mod p.
+func PowM(a, e, p *big.Int) *big.Int { return new(big.Int).Exp(a, e, p) }
+
+// Legendre symbol (a|p): -1,0,+1
+func Legendre(a, p *big.Int) int {
+	A := Mod(a, p)
+	if A.Sign() == 0 {
+		return 0
+	}
+	e := new(big.Int).Sub(p, big.NewInt(1))
+	e.Rsh(e, 1)
+	v := PowM(A, e, p)
+	if v.Cmp(big.NewInt(1)) == 0 {
+		return 1
+	}
+	if v.Sign() == 0 {
+		return 0
+	}
+	return -1 // v==p-1
+}
+
+// Tonelli–Shanks sqrt mod p (p odd prime)
+func SqrtModP(a, p *big.Int) (*big.Int, error) {
+	A := Mod(a, p)
+	if A.Sign() == 0 {
+		return new(big.Int), nil
+	}
+	if Legendre(A, p) != 1 {
+		return nil, errors.New("non-residue")
+	}
+	// p ≡ 3 mod 4 shortcut
+	if new(big.Int).And(new(big.Int).Sub(p, big.NewInt(3)), big.NewInt(3)).Cmp(big.NewInt(0)) == 0 {
+		e := new(big.Int).Add(p, big.NewInt(1))
+		e.Rsh(e, 2)
+		return PowM(A, e, p), nil
+	}
+	// factor p-1 = q*2^s
+	q := new(big.Int).Sub(p, big.NewInt(1))
+	s := 0
+	for q.Bit(0) == 0 {
+		q.Rsh(q, 1)
+		s++
+	}
+	// find z non-residue
+	z := new(big.Int).Set(big.NewInt(2))
+	for Legendre(z, p) != -1 {
+		z.Add(z, big.NewInt(1))
+	}
+	c := PowM(z, q, p)
+	x := PowM(A, new(big.Int).Rsh(new(big.Int).Add(q, big.NewInt(1)), 1), p)
+	t := PowM(A, q, p)
+	m := s
+	one := big.NewInt(1)
+	for t.Cmp(one) != 0 {
+		i := 1
+		b := new(big.Int).Exp(t, big.NewInt(2), p)
+		for i < m {
+			if b.Cmp(one) == 0 {
+				break
+			}
+			b.Exp(b, big.NewInt(2), p)
+			i++
+		}
+		if i == m {
+			return nil, errors.New("T–S failure")
+		}
+		// b = c^{2^{m-i-1}}
+		b.Set(c)
+		for j := 0; j < m-i-1; j++ {
+			b.Exp(b, big.NewInt(2), p)
+		}
+		x = MulM(x, b, p)
+		bb := MulM(b, b, p)
+		t = MulM(t, bb, p)
+		c = bb
+		m = i
+	}
+	return x, nil
+}
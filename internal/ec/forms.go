@@ -0,0 +1,301 @@
+package ec
+
+import (
+	"errors"
+	"math/big"
+)
+
+// ---------- Montgomery form ----------
+
+// Montgomery is B y^2 = x^3 + A x^2 + x over F_P, nonsingular when
+// B(A^2 - 4) ≠ 0. Like short Weierstrass it has a single point O at
+// infinity, and lines meet it in three points.
+type Montgomery struct{ P, A, B *big.Int }
+
+// IsSingular reports whether B(A^2 - 4) ≡ 0 mod p.
+func (m Montgomery) IsSingular() bool {
+	p := m.P
+	return MulM(m.B, SubM(MulM(m.A, m.A, p), big.NewInt(4), p), p).Sign() == 0
+}
+
+// RHS returns (x^3 + A x^2 + x) / B, the value y^2 must take.
+func (m Montgomery) RHS(x *big.Int) (*big.Int, error) {
+	p := m.P
+	num := AddM(AddM(MulM(x, MulM(x, x, p), p), MulM(m.A, MulM(x, x, p), p), p), x, p)
+	inv, err := InvM(Mod(m.B, p), p)
+	if err != nil {
+		return nil, err
+	}
+	return MulM(num, inv, p), nil
+}
+
+// On reports whether Pt lies on m (O always does).
+func (m Montgomery) On(Pt Point) bool {
+	if Pt.Inf {
+		return true
+	}
+	rhs, err := m.RHS(Pt.X)
+	return err == nil && MulM(Pt.Y, Pt.Y, m.P).Cmp(rhs) == 0
+}
+
+// Neg returns -Pt.
+func (m Montgomery) Neg(Pt Point) Point {
+	if Pt.Inf {
+		return Pt
+	}
+	return Point{X: new(big.Int).Set(Pt.X), Y: NegM(Pt.Y, m.P)}
+}
+
+// Add returns P + Q by the chord-and-tangent law:
+//
+//	λ = (y2-y1)/(x2-x1)  or  (3x1^2 + 2A x1 + 1)/(2B y1)
+//	x3 = Bλ^2 - A - x1 - x2,  y3 = λ(x1 - x3) - y1
+func (m Montgomery) Add(P, Q Point) (Point, error) {
+	p := m.P
+	if P.Inf {
+		return Q, nil
+	}
+	if Q.Inf {
+		return P, nil
+	}
+	var lam *big.Int
+	if P.X.Cmp(Q.X) == 0 {
+		if AddM(P.Y, Q.Y, p).Sign() == 0 {
+			return Point{Inf: true}, nil
+		}
+		num := AddM(AddM(MulM(big.NewInt(3), MulM(P.X, P.X, p), p), MulM(big.NewInt(2), MulM(m.A, P.X, p), p), p), big.NewInt(1), p)
+		inv, err := InvM(MulM(big.NewInt(2), MulM(m.B, P.Y, p), p), p)
+		if err != nil {
+			return Point{}, err
+		}
+		lam = MulM(num, inv, p)
+	} else {
+		inv, err := InvM(SubM(Q.X, P.X, p), p)
+		if err != nil {
+			return Point{}, err
+		}
+		lam = MulM(SubM(Q.Y, P.Y, p), inv, p)
+	}
+	x3 := SubM(SubM(SubM(MulM(m.B, MulM(lam, lam, p), p), m.A, p), P.X, p), Q.X, p)
+	y3 := SubM(MulM(lam, SubM(P.X, x3, p), p), P.Y, p)
+	return Point{X: x3, Y: y3}, nil
+}
+
+// ToWeierstrass returns the short Weierstrass curve v^2 = u^3 + a u + b
+// isomorphic to m via u = x/B + A/(3B), v = y/B, with
+// a = (3 - A^2)/(3B^2) and b = (2A^3 - 9A)/(27B^3).
+func (m Montgomery) ToWeierstrass() (Curve, error) {
+	p := m.P
+	inv3B, err := InvM(MulM(big.NewInt(3), m.B, p), p)
+	if err != nil {
+		return Curve{}, err
+	}
+	inv3B2 := MulM(inv3B, inv3B, p)
+	inv3B3 := MulM(inv3B2, inv3B, p)
+	A2 := MulM(m.A, m.A, p)
+	// (3 - A^2)/(3B^2) = 3(3 - A^2)/(3B)^2
+	a := MulM(MulM(big.NewInt(3), SubM(big.NewInt(3), A2, p), p), inv3B2, p)
+	// (2A^3 - 9A)/(27B^3) = (2A^3 - 9A)/(3B)^3
+	b := MulM(SubM(MulM(big.NewInt(2), MulM(A2, m.A, p), p), MulM(big.NewInt(9), m.A, p), p), inv3B3, p)
+	return Curve{P: p, A: a, B: b}, nil
+}
+
+// PointToWeierstrass maps a point of m onto m.ToWeierstrass().
+func (m Montgomery) PointToWeierstrass(Pt Point) (Point, error) {
+	if Pt.Inf {
+		return Pt, nil
+	}
+	p := m.P
+	invB, err := InvM(m.B, p)
+	if err != nil {
+		return Point{}, err
+	}
+	inv3B, err := InvM(MulM(big.NewInt(3), m.B, p), p)
+	if err != nil {
+		return Point{}, err
+	}
+	u := AddM(MulM(Pt.X, invB, p), MulM(m.A, inv3B, p), p)
+	return Point{X: u, Y: MulM(Pt.Y, invB, p)}, nil
+}
+
+// PointFromWeierstrass is the inverse of PointToWeierstrass.
+func (m Montgomery) PointFromWeierstrass(Pt Point) (Point, error) {
+	if Pt.Inf {
+		return Pt, nil
+	}
+	p := m.P
+	inv3, err := InvM(big.NewInt(3), p)
+	if err != nil {
+		return Point{}, err
+	}
+	// x = B u - A/3, y = B v
+	x := SubM(MulM(m.B, Pt.X, p), MulM(m.A, inv3, p), p)
+	return Point{X: x, Y: MulM(m.B, Pt.Y, p)}, nil
+}
+
+// MontgomeryFromWeierstrass finds a Montgomery model of c. One exists iff c
+// has a 2-torsion point (α, 0) with 3α^2 + a a square s^2; then
+// B = 1/s and A = 3α/s, with x = (u - α)/s.
+func MontgomeryFromWeierstrass(c Curve) (Montgomery, error) {
+	p := c.P
+	for alpha := big.NewInt(0); alpha.Cmp(p) < 0; alpha.Add(alpha, big.NewInt(1)) {
+		f := AddM(AddM(MulM(alpha, MulM(alpha, alpha, p), p), MulM(c.A, alpha, p), p), c.B, p)
+		if f.Sign() != 0 {
+			continue
+		}
+		t := AddM(MulM(big.NewInt(3), MulM(alpha, alpha, p), p), c.A, p)
+		if Legendre(t, p) != 1 {
+			continue
+		}
+		s, err := SqrtModP(t, p)
+		if err != nil {
+			return Montgomery{}, err
+		}
+		sInv, err := InvM(s, p)
+		if err != nil {
+			return Montgomery{}, err
+		}
+		return Montgomery{P: p, A: MulM(MulM(big.NewInt(3), alpha, p), sInv, p), B: sInv}, nil
+	}
+	return Montgomery{}, errors.New("curve has no Montgomery model (no suitable 2-torsion point)")
+}
+
+// ---------- twisted Edwards form ----------
+
+// Edwards is the twisted Edwards curve A x^2 + y^2 = 1 + D x^2 y^2 over F_P,
+// nonsingular when A·D·(A-D) ≠ 0. The identity is the affine point (0, 1)
+// and the curve has degree four, so a line can meet it in four points.
+type Edwards struct{ P, A, D *big.Int }
+
+// IsSingular reports whether A·D·(A-D) ≡ 0 mod p.
+func (e Edwards) IsSingular() bool {
+	p := e.P
+	return MulM(MulM(e.A, e.D, p), SubM(e.A, e.D, p), p).Sign() == 0
+}
+
+// Identity returns (0, 1).
+func (e Edwards) Identity() Point { return Point{X: big.NewInt(0), Y: big.NewInt(1)} }
+
+// On reports whether Pt lies on e.
+func (e Edwards) On(Pt Point) bool {
+	if Pt.Inf {
+		return false
+	}
+	p := e.P
+	x2, y2 := MulM(Pt.X, Pt.X, p), MulM(Pt.Y, Pt.Y, p)
+	lhs := AddM(MulM(e.A, x2, p), y2, p)
+	rhs := AddM(big.NewInt(1), MulM(e.D, MulM(x2, y2, p), p), p)
+	return lhs.Cmp(rhs) == 0
+}
+
+// Neg returns -(x, y) = (-x, y).
+func (e Edwards) Neg(Pt Point) Point {
+	return Point{X: NegM(Pt.X, e.P), Y: new(big.Int).Set(Pt.Y)}
+}
+
+// Add is the unified twisted Edwards law
+//
+//	x3 = (x1 y2 + y1 x2) / (1 + D x1 x2 y1 y2)
+//	y3 = (y1 y2 - A x1 x2) / (1 - D x1 x2 y1 y2)
+//
+// which is complete (never divides by zero) when A is a square and D is not.
+func (e Edwards) Add(P, Q Point) (Point, error) {
+	p := e.P
+	x1x2 := MulM(P.X, Q.X, p)
+	y1y2 := MulM(P.Y, Q.Y, p)
+	t := MulM(e.D, MulM(x1x2, y1y2, p), p)
+	invX, err := InvM(AddM(big.NewInt(1), t, p), p)
+	if err != nil {
+		return Point{}, errors.New("edwards: exceptional addition (denominator 1 + d·x1x2y1y2 = 0)")
+	}
+	invY, err := InvM(SubM(big.NewInt(1), t, p), p)
+	if err != nil {
+		return Point{}, errors.New("edwards: exceptional addition (denominator 1 - d·x1x2y1y2 = 0)")
+	}
+	x3 := MulM(AddM(MulM(P.X, Q.Y, p), MulM(P.Y, Q.X, p), p), invX, p)
+	y3 := MulM(SubM(y1y2, MulM(e.A, x1x2, p), p), invY, p)
+	return Point{X: x3, Y: y3}, nil
+}
+
+// ToMontgomery returns the birationally equivalent Montgomery curve with
+// A_M = 2(A+D)/(A-D) and B_M = 4/(A-D).
+func (e Edwards) ToMontgomery() (Montgomery, error) {
+	p := e.P
+	inv, err := InvM(SubM(e.A, e.D, p), p)
+	if err != nil {
+		return Montgomery{}, err
+	}
+	return Montgomery{
+		P: p,
+		A: MulM(MulM(big.NewInt(2), AddM(e.A, e.D, p), p), inv, p),
+		B: MulM(big.NewInt(4), inv, p),
+	}, nil
+}
+
+// ToWeierstrass composes ToMontgomery with Montgomery.ToWeierstrass.
+func (e Edwards) ToWeierstrass() (Curve, error) {
+	m, err := e.ToMontgomery()
+	if err != nil {
+		return Curve{}, err
+	}
+	return m.ToWeierstrass()
+}
+
+// PointToMontgomery maps (x, y) to (u, v) = ((1+y)/(1-y), u/x). The
+// identity goes to O and (0, -1) to the 2-torsion point (0, 0); points with
+// y = 1, x ≠ 0 only exist when D is a square and have no affine image.
+func (e Edwards) PointToMontgomery(Pt Point) (Point, error) {
+	p := e.P
+	if Pt.X.Sign() == 0 {
+		if Pt.Y.Cmp(big.NewInt(1)) == 0 {
+			return Point{Inf: true}, nil
+		}
+		return Point{X: big.NewInt(0), Y: big.NewInt(0)}, nil
+	}
+	inv, err := InvM(SubM(big.NewInt(1), Pt.Y, p), p)
+	if err != nil {
+		return Point{}, errors.New("edwards: point maps to infinity on the Montgomery model")
+	}
+	u := MulM(AddM(big.NewInt(1), Pt.Y, p), inv, p)
+	xInv, err := InvM(Pt.X, p)
+	if err != nil {
+		return Point{}, err
+	}
+	return Point{X: u, Y: MulM(u, xInv, p)}, nil
+}
+
+// EdwardsFromMontgomery returns the twisted Edwards model of m with
+// A = (A_M + 2)/B_M and D = (A_M - 2)/B_M.
+func EdwardsFromMontgomery(m Montgomery) (Edwards, error) {
+	p := m.P
+	inv, err := InvM(m.B, p)
+	if err != nil {
+		return Edwards{}, err
+	}
+	return Edwards{
+		P: p,
+		A: MulM(AddM(m.A, big.NewInt(2), p), inv, p),
+		D: MulM(SubM(m.A, big.NewInt(2), p), inv, p),
+	}, nil
+}
+
+// PointFromMontgomery maps (u, v) to (x, y) = (u/v, (u-1)/(u+1)); O goes to
+// the identity and (0, 0) to (0, -1).
+func (e Edwards) PointFromMontgomery(Pt Point) (Point, error) {
+	p := e.P
+	if Pt.Inf {
+		return e.Identity(), nil
+	}
+	if Pt.X.Sign() == 0 {
+		return Point{X: big.NewInt(0), Y: NegM(big.NewInt(1), p)}, nil
+	}
+	vInv, err := InvM(Pt.Y, p)
+	if err != nil {
+		return Point{}, errors.New("edwards: 2-torsion point has no affine Edwards image")
+	}
+	uInv, err := InvM(AddM(Pt.X, big.NewInt(1), p), p)
+	if err != nil {
+		return Point{}, errors.New("edwards: point u = -1 has no affine Edwards image")
+	}
+	return Point{X: MulM(Pt.X, vInv, p), Y: MulM(SubM(Pt.X, big.NewInt(1), p), uInv, p)}, nil
+}
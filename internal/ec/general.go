@@ -0,0 +1,140 @@
+package ec
+
+import (
+	"errors"
+	"math/big"
+)
+
+// ---------- long Weierstrass form ----------
+
+// General is the long Weierstrass curve
+//
+//	y^2 + a1 x y + a3 y = x^3 + a2 x^2 + a4 x + a6
+//
+// over F_P, the form curves reduced from ℚ (Cremona/LMFDB a-invariants
+// [a1,a2,a3,a4,a6]) come in. The group law below holds in every
+// characteristic; only the map to short form needs p > 3.
+type General struct{ P, A1, A2, A3, A4, A6 *big.Int }
+
+// General returns c as a long Weierstrass curve (a1 = a2 = a3 = 0).
+func (c Curve) General() General {
+	z := big.NewInt(0)
+	return General{P: c.P, A1: z, A2: z, A3: z, A4: Mod(c.A, c.P), A6: Mod(c.B, c.P)}
+}
+
+// bInvariants returns b2, b4, b6, b8.
+func (g General) bInvariants() (b2, b4, b6, b8 *big.Int) {
+	p := g.P
+	a1a1 := MulM(g.A1, g.A1, p)
+	b2 = AddM(a1a1, MulM(big.NewInt(4), g.A2, p), p)
+	b4 = AddM(MulM(big.NewInt(2), g.A4, p), MulM(g.A1, g.A3, p), p)
+	b6 = AddM(MulM(g.A3, g.A3, p), MulM(big.NewInt(4), g.A6, p), p)
+	// b8 = a1^2 a6 + 4 a2 a6 - a1 a3 a4 + a2 a3^2 - a4^2
+	b8 = AddM(MulM(a1a1, g.A6, p), MulM(big.NewInt(4), MulM(g.A2, g.A6, p), p), p)
+	b8 = SubM(b8, MulM(g.A1, MulM(g.A3, g.A4, p), p), p)
+	b8 = AddM(b8, MulM(g.A2, MulM(g.A3, g.A3, p), p), p)
+	b8 = SubM(b8, MulM(g.A4, g.A4, p), p)
+	return
+}
+
+// Discriminant returns Δ = -b2^2 b8 - 8 b4^3 - 27 b6^2 + 9 b2 b4 b6 mod p.
+func (g General) Discriminant() *big.Int {
+	p := g.P
+	b2, b4, b6, b8 := g.bInvariants()
+	d := NegM(MulM(MulM(b2, b2, p), b8, p), p)
+	d = SubM(d, MulM(big.NewInt(8), MulM(b4, MulM(b4, b4, p), p), p), p)
+	d = SubM(d, MulM(big.NewInt(27), MulM(b6, b6, p), p), p)
+	return AddM(d, MulM(big.NewInt(9), MulM(b2, MulM(b4, b6, p), p), p), p)
+}
+
+// IsSingular reports whether Δ ≡ 0 mod p.
+func (g General) IsSingular() bool { return g.Discriminant().Sign() == 0 }
+
+// On reports whether Pt lies on g (O always does).
+func (g General) On(Pt Point) bool {
+	if Pt.Inf {
+		return true
+	}
+	p, x, y := g.P, Pt.X, Pt.Y
+	lhs := AddM(MulM(y, y, p), MulM(y, AddM(MulM(g.A1, x, p), g.A3, p), p), p)
+	rhs := AddM(AddM(AddM(MulM(x, MulM(x, x, p), p), MulM(g.A2, MulM(x, x, p), p), p), MulM(g.A4, x, p), p), g.A6, p)
+	return lhs.Cmp(rhs) == 0
+}
+
+// Neg returns -Pt = (x, -y - a1 x - a3).
+func (g General) Neg(Pt Point) Point {
+	if Pt.Inf {
+		return Pt
+	}
+	p := g.P
+	return Point{X: new(big.Int).Set(Pt.X), Y: NegM(AddM(AddM(Pt.Y, MulM(g.A1, Pt.X, p), p), g.A3, p), p)}
+}
+
+// Add returns P + Q:
+//
+//	λ = (y2-y1)/(x2-x1)  or  (3x1^2 + 2a2 x1 + a4 - a1 y1)/(2y1 + a1 x1 + a3)
+//	ν = y1 - λ x1
+//	x3 = λ^2 + a1 λ - a2 - x1 - x2,  y3 = -(λ + a1) x3 - ν - a3
+func (g General) Add(P, Q Point) (Point, error) {
+	p := g.P
+	if P.Inf {
+		return Q, nil
+	}
+	if Q.Inf {
+		return P, nil
+	}
+	var lam *big.Int
+	if P.X.Cmp(Q.X) == 0 {
+		// Q = P or Q = -P; the denominator 2y1 + a1 x1 + a3 vanishes exactly
+		// when P = -P, and y1 + y2 + a1 x1 + a3 vanishes when Q = -P.
+		if AddM(AddM(AddM(P.Y, Q.Y, p), MulM(g.A1, P.X, p), p), g.A3, p).Sign() == 0 {
+			return Point{Inf: true}, nil
+		}
+		den := AddM(AddM(MulM(big.NewInt(2), P.Y, p), MulM(g.A1, P.X, p), p), g.A3, p)
+		inv, err := InvM(den, p)
+		if err != nil {
+			return Point{}, err
+		}
+		num := AddM(MulM(big.NewInt(3), MulM(P.X, P.X, p), p), MulM(MulM(big.NewInt(2), g.A2, p), P.X, p), p)
+		num = SubM(AddM(num, g.A4, p), MulM(g.A1, P.Y, p), p)
+		lam = MulM(num, inv, p)
+	} else {
+		inv, err := InvM(SubM(Q.X, P.X, p), p)
+		if err != nil {
+			return Point{}, err
+		}
+		lam = MulM(SubM(Q.Y, P.Y, p), inv, p)
+	}
+	nu := SubM(P.Y, MulM(lam, P.X, p), p)
+	x3 := SubM(SubM(SubM(AddM(MulM(lam, lam, p), MulM(g.A1, lam, p), p), g.A2, p), P.X, p), Q.X, p)
+	y3 := SubM(SubM(NegM(MulM(AddM(lam, g.A1, p), x3, p), p), nu, p), g.A3, p)
+	return Point{X: x3, Y: y3}, nil
+}
+
+// ToWeierstrass returns the short curve y^2 = x^3 - 27 c4 x - 54 c6
+// isomorphic to g (p > 3), where c4 = b2^2 - 24 b4 and
+// c6 = -b2^3 + 36 b2 b4 - 216 b6.
+func (g General) ToWeierstrass() (Curve, error) {
+	p := g.P
+	if p.Cmp(big.NewInt(3)) <= 0 {
+		return Curve{}, errors.New("short Weierstrass form needs p > 3")
+	}
+	b2, b4, b6, _ := g.bInvariants()
+	c4 := SubM(MulM(b2, b2, p), MulM(big.NewInt(24), b4, p), p)
+	c6 := AddM(NegM(MulM(b2, MulM(b2, b2, p), p), p), MulM(big.NewInt(36), MulM(b2, b4, p), p), p)
+	c6 = SubM(c6, MulM(big.NewInt(216), b6, p), p)
+	return Curve{P: p, A: NegM(MulM(big.NewInt(27), c4, p), p), B: NegM(MulM(big.NewInt(54), c6, p), p)}, nil
+}
+
+// PointToWeierstrass maps a point of g onto g.ToWeierstrass() by
+// (x, y) ↦ (36x + 3b2, 108(2y + a1 x + a3)).
+func (g General) PointToWeierstrass(Pt Point) (Point, error) {
+	if Pt.Inf {
+		return Pt, nil
+	}
+	p := g.P
+	b2, _, _, _ := g.bInvariants()
+	u := AddM(MulM(big.NewInt(36), Pt.X, p), MulM(big.NewInt(3), b2, p), p)
+	v := MulM(big.NewInt(108), AddM(AddM(MulM(big.NewInt(2), Pt.Y, p), MulM(g.A1, Pt.X, p), p), g.A3, p), p)
+	return Point{X: u, Y: v}, nil
+}
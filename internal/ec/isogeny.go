@@ -0,0 +1,150 @@
+package ec
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ---------- isogenies (Vélu) ----------
+
+// maxKernel bounds the subgroup IsogenyFromKernel will close up and sum
+// over; Vélu's formulas cost O(#G) per point mapped.
+const maxKernel = 1 << 16
+
+// Isogeny is the separable isogeny φ: Domain → Codomain with the given
+// finite kernel, normalized as Vélu's formulas give it (φ*(dx/y) = dx/y).
+type Isogeny struct {
+	Domain, Codomain Curve
+	Kernel           []Point // the affine points of the kernel, O excluded
+	terms            []veluTerm
+}
+
+// veluTerm is one representative Q of the kernel mod ±, with
+// g^x = 3x_Q^2 + a, g^y = -2y_Q, v_Q = g^x (2g^x unless Q has order 2) and
+// u_Q = (g^y)^2.
+type veluTerm struct {
+	x, y, gx, gy, v, u *big.Int
+}
+
+// Degree is #kernel.
+func (phi *Isogeny) Degree() int { return len(phi.Kernel) + 1 }
+
+// IsogenyFromKernel returns the isogeny whose kernel is the subgroup that
+// points generate (O is implied; the points must lie on c). Vélu's
+// formulas give the codomain
+//
+//	y^2 = x^3 + (a - 5v)x + (b - 7w),  v = Σ v_Q,  w = Σ (u_Q + x_Q v_Q),
+//
+// summing over the points of order 2 and one of each pair ±Q of the rest.
+func (c Curve) IsogenyFromKernel(points []Point) (*Isogeny, error) {
+	G, err := c.closure(points)
+	if err != nil {
+		return nil, err
+	}
+	p := c.P
+	phi := &Isogeny{Domain: c, Kernel: G}
+	v, w := new(big.Int), new(big.Int)
+	seen := make(map[string]bool)
+	for _, Q := range G {
+		if seen[Q.X.String()] {
+			continue // -Q was summed already
+		}
+		seen[Q.X.String()] = true
+		t := veluTerm{x: Q.X, y: Q.Y}
+		t.gx = AddM(MulM(big.NewInt(3), MulM(Q.X, Q.X, p), p), c.A, p)
+		t.gy = MulM(big.NewInt(-2), Q.Y, p)
+		t.v = t.gx
+		if Q.Y.Sign() != 0 {
+			t.v = MulM(big.NewInt(2), t.gx, p)
+		}
+		t.u = MulM(t.gy, t.gy, p)
+		v = AddM(v, t.v, p)
+		w = AddM(w, AddM(t.u, MulM(Q.X, t.v, p), p), p)
+		phi.terms = append(phi.terms, t)
+	}
+	phi.Codomain = Curve{
+		P: p,
+		A: SubM(c.A, MulM(big.NewInt(5), v, p), p),
+		B: SubM(c.B, MulM(big.NewInt(7), w, p), p),
+	}
+	return phi, nil
+}
+
+// closure returns the affine points of the subgroup gens generate.
+func (c Curve) closure(gens []Point) ([]Point, error) {
+	key := func(P Point) string { return P.X.String() + "," + P.Y.String() }
+	in := make(map[string]bool)
+	var G []Point
+	add := func(P Point) error {
+		if P.Inf || in[key(P)] {
+			return nil
+		}
+		if len(G) >= maxKernel {
+			return fmt.Errorf("isogeny: kernel larger than %d points", maxKernel)
+		}
+		in[key(P)] = true
+		G = append(G, P)
+		return nil
+	}
+	for _, P := range gens {
+		if !c.On(P) {
+			return nil, errors.New("isogeny: kernel point not on the curve")
+		}
+		P = Point{X: Mod(P.X, c.P), Y: Mod(P.Y, c.P), Inf: P.Inf}
+		// add every multiple of P to every point so far, and to O
+		prev := append([]Point{{Inf: true}}, G...)
+		for R := P; !R.Inf; {
+			for _, S := range prev {
+				T, err := c.Add(S, R)
+				if err != nil {
+					return nil, err
+				}
+				if err := add(T); err != nil {
+					return nil, err
+				}
+			}
+			var err error
+			if R, err = c.Add(R, P); err != nil {
+				return nil, err
+			}
+			if len(G) >= maxKernel {
+				return nil, fmt.Errorf("isogeny: kernel larger than %d points", maxKernel)
+			}
+		}
+	}
+	return G, nil
+}
+
+// Map returns φ(P):
+//
+//	X = x + Σ [ v_Q/(x - x_Q) + u_Q/(x - x_Q)^2 ]
+//	Y = y - Σ [ u_Q·2y/(x - x_Q)^3 + v_Q(y - y_Q)/(x - x_Q)^2 - g^x_Q g^y_Q/(x - x_Q)^2 ]
+//
+// Kernel points (and O) go to O.
+func (phi *Isogeny) Map(P Point) (Point, error) {
+	if P.Inf {
+		return P, nil
+	}
+	p := phi.Domain.P
+	x, y := Mod(P.X, p), Mod(P.Y, p)
+	X, Y := new(big.Int).Set(x), new(big.Int).Set(y)
+	for _, t := range phi.terms {
+		d := SubM(x, t.x, p)
+		if d.Sign() == 0 {
+			return Point{Inf: true}, nil // P = ±Q is in the kernel
+		}
+		inv, err := InvM(d, p)
+		if err != nil {
+			return Point{}, err
+		}
+		inv2 := MulM(inv, inv, p)
+		inv3 := MulM(inv2, inv, p)
+		X = AddM(X, AddM(MulM(t.v, inv, p), MulM(t.u, inv2, p), p), p)
+		s := MulM(MulM(t.u, MulM(big.NewInt(2), y, p), p), inv3, p)
+		s = AddM(s, MulM(MulM(t.v, SubM(y, t.y, p), p), inv2, p), p)
+		s = SubM(s, MulM(MulM(t.gx, t.gy, p), inv2, p), p)
+		Y = SubM(Y, s, p)
+	}
+	return Point{X: X, Y: Y}, nil
+}
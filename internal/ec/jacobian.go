@@ -0,0 +1,238 @@
+package ec
+
+import "math/big"
+
+// ---------- Jacobian coordinates ----------
+
+// Jacobian is the point (X/Z^2, Y/Z^3) of a curve, or O when Z = 0. The
+// group law needs no inversion in these coordinates, only the one that
+// takes a result back to affine.
+type Jacobian struct{ X, Y, Z *big.Int }
+
+// ToJacobian returns P with Z = 1 (Z = 0 for O).
+func (c Curve) ToJacobian(P Point) Jacobian {
+	if P.Inf {
+		return Jacobian{X: big.NewInt(1), Y: big.NewInt(1), Z: new(big.Int)}
+	}
+	return Jacobian{X: new(big.Int).Set(P.X), Y: new(big.Int).Set(P.Y), Z: big.NewInt(1)}
+}
+
+// Affine returns J as an affine point, with one inversion of Z.
+func (c Curve) Affine(J Jacobian) (Point, error) {
+	if J.Z.Sign() == 0 {
+		return Point{Inf: true}, nil
+	}
+	p := c.P
+	zi, err := InvM(J.Z, p)
+	if err != nil {
+		return Point{}, err
+	}
+	zi2 := MulM(zi, zi, p)
+	return Point{X: MulM(J.X, zi2, p), Y: MulM(J.Y, MulM(zi2, zi, p), p)}, nil
+}
+
+// JacobianDouble returns 2J.
+func (c Curve) JacobianDouble(J Jacobian) Jacobian {
+	R := Jacobian{X: new(big.Int).Set(J.X), Y: new(big.Int).Set(J.Y), Z: new(big.Int).Set(J.Z)}
+	newJacobianArith(c).double(R)
+	return R
+}
+
+// JacobianAddAffine returns J + Q for an affine Q: the mixed addition that
+// scalar multiplication uses against its affine table.
+func (c Curve) JacobianAddAffine(J Jacobian, Q Point) Jacobian {
+	R := Jacobian{X: new(big.Int).Set(J.X), Y: new(big.Int).Set(J.Y), Z: new(big.Int).Set(J.Z)}
+	newJacobianArith(c).addAffine(R, Q)
+	return R
+}
+
+// ScalarMulJacobian is ScalarMul with a Jacobian accumulator: the same
+// width-4 NAF and affine table, but the doublings and additions need no
+// inversion, so kP costs the table's four and one at the end instead of one
+// per operation. Over a composite modulus a failed inversion is reported
+// the same way, as a *NotInvertibleError.
+func (c Curve) ScalarMulJacobian(k *big.Int, P Point) (Point, error) {
+	if k.Sign() == 0 || P.Inf {
+		return Point{Inf: true}, nil
+	}
+	if k.Sign() < 0 {
+		P = c.Neg(P)
+	}
+	digits := wNAF(new(big.Int).Abs(k), wnafWidth)
+
+	// tbl[i] = (2i+1)·P, and its negation
+	tbl := make([]Point, 1<<(wnafWidth-2))
+	neg := make([]Point, len(tbl))
+	tbl[0] = P
+	P2, err := c.Double(P)
+	if err != nil {
+		return Point{}, err
+	}
+	for i := 1; i < len(tbl); i++ {
+		if tbl[i], err = c.Add(tbl[i-1], P2); err != nil {
+			return Point{}, err
+		}
+	}
+	for i, T := range tbl {
+		neg[i] = c.Neg(T)
+	}
+
+	ja := newJacobianArith(c)
+	R := c.ToJacobian(Point{Inf: true})
+	for i := len(digits) - 1; i >= 0; i-- {
+		ja.double(R)
+		switch d := digits[i]; {
+		case d > 0:
+			ja.addAffine(R, tbl[d/2])
+		case d < 0:
+			ja.addAffine(R, neg[-d/2])
+		}
+	}
+	return c.Affine(R)
+}
+
+// jacobianArith does the Jacobian group law in place, on reused scratch
+// integers: each reduction is one QuoRem, and sums and differences are
+// brought back into [0, p) by one subtraction or addition. That keeps a
+// multiplication mod p well under half an inversion, which is what makes
+// Jacobian coordinates pay off on math/big.
+type jacobianArith struct {
+	p, a    *big.Int
+	aMinus3 bool // A = -3: M = 3(X - Z^2)(X + Z^2) saves a multiplication
+	q       *big.Int
+	t       [8]*big.Int
+}
+
+func newJacobianArith(c Curve) *jacobianArith {
+	ja := &jacobianArith{p: c.P, a: c.A, q: new(big.Int)}
+	ja.aMinus3 = new(big.Int).Add(c.A, big.NewInt(3)).Cmp(c.P) == 0
+	for i := range ja.t {
+		ja.t[i] = new(big.Int)
+	}
+	return ja
+}
+
+func (ja *jacobianArith) mul(z, x, y *big.Int) {
+	z.Mul(x, y)
+	ja.q.QuoRem(z, ja.p, z)
+}
+
+func (ja *jacobianArith) add(z, x, y *big.Int) {
+	z.Add(x, y)
+	if z.Cmp(ja.p) >= 0 {
+		z.Sub(z, ja.p)
+	}
+}
+
+func (ja *jacobianArith) sub(z, x, y *big.Int) {
+	z.Sub(x, y)
+	if z.Sign() < 0 {
+		z.Add(z, ja.p)
+	}
+}
+
+// double sets J = 2J (dbl-2007-bl).
+func (ja *jacobianArith) double(J Jacobian) {
+	if J.Z.Sign() == 0 {
+		return
+	}
+	if J.Y.Sign() == 0 {
+		J.Z.SetInt64(0)
+		return
+	}
+	XX, YY, YYYY, ZZ, S, M, t := ja.t[0], ja.t[1], ja.t[2], ja.t[3], ja.t[4], ja.t[5], ja.t[6]
+	ja.mul(XX, J.X, J.X)
+	ja.mul(YY, J.Y, J.Y)
+	ja.mul(YYYY, YY, YY)
+	ja.mul(ZZ, J.Z, J.Z)
+	// S = 2((X + YY)^2 - XX - YYYY)
+	ja.add(t, J.X, YY)
+	ja.mul(S, t, t)
+	ja.sub(S, S, XX)
+	ja.sub(S, S, YYYY)
+	ja.add(S, S, S)
+	if ja.aMinus3 {
+		// M = 3(X - ZZ)(X + ZZ)
+		ja.sub(t, J.X, ZZ)
+		ja.add(M, J.X, ZZ)
+		ja.mul(M, M, t)
+		ja.add(t, M, M)
+		ja.add(M, M, t)
+	} else {
+		// M = 3XX + A·ZZ^2
+		ja.add(M, XX, XX)
+		ja.add(M, M, XX)
+		if ja.a.Sign() != 0 {
+			ja.mul(t, ZZ, ZZ)
+			ja.mul(t, t, ja.a)
+			ja.add(M, M, t)
+		}
+	}
+	// Z3 = (Y + Z)^2 - YY - ZZ, before Y changes
+	ja.add(t, J.Y, J.Z)
+	ja.mul(J.Z, t, t)
+	ja.sub(J.Z, J.Z, YY)
+	ja.sub(J.Z, J.Z, ZZ)
+	// X3 = M^2 - 2S
+	ja.mul(J.X, M, M)
+	ja.sub(J.X, J.X, S)
+	ja.sub(J.X, J.X, S)
+	// Y3 = M(S - X3) - 8YYYY
+	ja.sub(t, S, J.X)
+	ja.mul(J.Y, M, t)
+	ja.add(YYYY, YYYY, YYYY)
+	ja.add(YYYY, YYYY, YYYY)
+	ja.add(YYYY, YYYY, YYYY)
+	ja.sub(J.Y, J.Y, YYYY)
+}
+
+// addAffine sets J = J + Q for affine Q (madd-2007-bl).
+func (ja *jacobianArith) addAffine(J Jacobian, Q Point) {
+	switch {
+	case Q.Inf:
+		return
+	case J.Z.Sign() == 0:
+		J.X.Set(Q.X)
+		J.Y.Set(Q.Y)
+		J.Z.SetInt64(1)
+		return
+	}
+	Z1Z1, U2, S2, H, HH, r, V, t := ja.t[0], ja.t[1], ja.t[2], ja.t[3], ja.t[4], ja.t[5], ja.t[6], ja.t[7]
+	ja.mul(Z1Z1, J.Z, J.Z)
+	ja.mul(U2, Q.X, Z1Z1)
+	ja.mul(S2, J.Z, Z1Z1)
+	ja.mul(S2, S2, Q.Y)
+	ja.sub(H, U2, J.X)
+	ja.sub(r, S2, J.Y)
+	ja.add(r, r, r)
+	if H.Sign() == 0 {
+		if r.Sign() == 0 {
+			ja.double(J) // J = Q
+		} else {
+			J.Z.SetInt64(0) // J = -Q
+		}
+		return
+	}
+	ja.mul(HH, H, H)
+	// I = 4HH in U2, J = H·I in S2, V = X1·I
+	ja.add(U2, HH, HH)
+	ja.add(U2, U2, U2)
+	ja.mul(S2, H, U2)
+	ja.mul(V, J.X, U2)
+	// Z3 = (Z1 + H)^2 - Z1Z1 - HH
+	ja.add(t, J.Z, H)
+	ja.mul(J.Z, t, t)
+	ja.sub(J.Z, J.Z, Z1Z1)
+	ja.sub(J.Z, J.Z, HH)
+	// X3 = r^2 - J - 2V
+	ja.mul(J.X, r, r)
+	ja.sub(J.X, J.X, S2)
+	ja.sub(J.X, J.X, V)
+	ja.sub(J.X, J.X, V)
+	// Y3 = r(V - X3) - 2Y1·J
+	ja.mul(t, J.Y, S2)
+	ja.add(t, t, t)
+	ja.sub(V, V, J.X)
+	ja.mul(J.Y, r, V)
+	ja.sub(J.Y, J.Y, t)
+}
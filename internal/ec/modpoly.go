@@ -0,0 +1,73 @@
+package ec
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// ---------- classical modular polynomials ----------
+
+// modularTerm is c·X^i·Y^k in Φ_ℓ(X, Y); the polynomials are symmetric, so
+// only i ≥ k is listed.
+type modularTerm struct {
+	i, k int
+	c    string
+}
+
+// modular holds the classical modular polynomials small enough to carry
+// as constants. Φ_ℓ(j(E), j(E')) = 0 exactly when some cyclic ℓ-isogeny
+// links E and E' over the algebraic closure.
+var modular = map[int][]modularTerm{
+	2: {
+		{3, 0, "1"},
+		{2, 2, "-1"},
+		{2, 1, "1488"},
+		{2, 0, "-162000"},
+		{1, 1, "40773375"},
+		{1, 0, "8748000000"},
+		{0, 0, "-157464000000000"},
+	},
+	3: {
+		{4, 0, "1"},
+		{3, 3, "-1"},
+		{3, 2, "2232"},
+		{3, 1, "-1069956"},
+		{3, 0, "36864000"},
+		{2, 2, "2587918086"},
+		{2, 1, "8900222976000"},
+		{2, 0, "452984832000000"},
+		{1, 1, "-770845966336000000"},
+		{1, 0, "1855425871872000000000"},
+	},
+}
+
+// HasModularPolynomial reports whether ModularRoots knows Φ_ℓ.
+func HasModularPolynomial(l int) bool {
+	_, ok := modular[l]
+	return ok
+}
+
+// ModularRoots returns the distinct j' in F_p with Φ_ℓ(j, j') = 0: the
+// j-invariants ℓ-isogenous to j over F_p (including j itself for a loop).
+func ModularRoots(l int, j, p *big.Int) ([]*big.Int, error) {
+	terms, ok := modular[l]
+	if !ok {
+		return nil, fmt.Errorf("modular polynomial: Φ_%d not available", l)
+	}
+	f := make(Poly, l+2)
+	for i := range f {
+		f[i] = new(big.Int)
+	}
+	add := func(i, k int, c *big.Int) { // c·j^i·Y^k
+		t := MulM(c, PowM(j, big.NewInt(int64(i)), p), p)
+		f[k] = AddM(f[k], t, p)
+	}
+	for _, t := range terms {
+		c, _ := new(big.Int).SetString(t.c, 10)
+		add(t.i, t.k, c)
+		if t.i != t.k {
+			add(t.k, t.i, c)
+		}
+	}
+	return f.trim().Roots(p), nil
+}
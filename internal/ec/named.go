@@ -0,0 +1,114 @@
+package ec
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ---------- named curves ----------
+
+// NamedCurve is a standard curve in the model it is published in. P, A and
+// B are ParseBig strings; for Form "montgomery" they are the A and B of
+// B y^2 = x^3 + A x^2 + x, for "edwards" the a and d of
+// a x^2 + y^2 = 1 + d x^2 y^2.
+type NamedCurve struct {
+	Name    string
+	Aliases []string
+	Form    string // weierstrass, montgomery or edwards
+	P, A, B string
+}
+
+// weiSuffix asks LookupCurve for the short Weierstrass model of a curve
+// published in another form ("curve25519-wei").
+const weiSuffix = "-wei"
+
+var namedCurves = []NamedCurve{
+	{Name: "secp256k1", Aliases: []string{"k256"}, Form: "weierstrass",
+		P: "2^256 - 2^32 - 977", A: "0", B: "7"},
+	{Name: "p224", Aliases: []string{"secp224r1", "nist-p224", "p-224"}, Form: "weierstrass",
+		P: "2^224 - 2^96 + 1", A: "-3",
+		B: "0xb4050a850c04b3abf54132565044b0b7d7bfd8ba270b39432355ffb4"},
+	{Name: "p256", Aliases: []string{"secp256r1", "prime256v1", "nist-p256", "p-256"}, Form: "weierstrass",
+		P: "2^256 - 2^224 + 2^192 + 2^96 - 1", A: "-3",
+		B: "0x5ac635d8aa3a93e7b3ebbd55769886bc651d06b0cc53b0f63bce3c3e27d2604b"},
+	{Name: "p384", Aliases: []string{"secp384r1", "nist-p384", "p-384"}, Form: "weierstrass",
+		P: "2^384 - 2^128 - 2^96 + 2^32 - 1", A: "-3",
+		B: "0xb3312fa7e23ee7e4988e056be3f82d19181d9c6efe8141120314088f5013875ac656398d8a2ed19d2a85c8edd3ec2aef"},
+	{Name: "p521", Aliases: []string{"secp521r1", "nist-p521", "p-521"}, Form: "weierstrass",
+		P: "2^521 - 1", A: "-3",
+		B: "0x51953eb9618e1c9a1f929a21a0b68540eea2da725b99b315f3b8b489918ef109e156193951ec7e937b1652c0bd3bb1bf073573df883d2c34f1ef451fd46b503f00"},
+	{Name: "bn254", Aliases: []string{"alt_bn128", "bn128"}, Form: "weierstrass",
+		P: "0x30644e72e131a029b85045b68181585d97816a916871ca8d3c208c16d87cfd47", A: "0", B: "3"},
+	{Name: "bls12-381", Aliases: []string{"bls12_381"}, Form: "weierstrass",
+		P: "0x1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaaab", A: "0", B: "4"},
+	{Name: "curve25519", Aliases: []string{"x25519"}, Form: "montgomery",
+		P: "2^255 - 19", A: "486662", B: "1"},
+	// d = -121665/121666
+	{Name: "ed25519", Aliases: []string{"edwards25519"}, Form: "edwards",
+		P: "2^255 - 19", A: "-1",
+		B: "37095705934669439343138083508754565189542113879843219016388785533085940283555"},
+	{Name: "curve448", Aliases: []string{"x448"}, Form: "montgomery",
+		P: "2^448 - 2^224 - 1", A: "156326", B: "1"},
+	{Name: "ed448", Aliases: []string{"edwards448"}, Form: "edwards",
+		P: "2^448 - 2^224 - 1", A: "1", B: "-39081"},
+}
+
+// CurveNames lists the registry's primary names, sorted.
+func CurveNames() []string {
+	names := make([]string, len(namedCurves))
+	for i, nc := range namedCurves {
+		names[i] = nc.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupCurve finds a curve by name or alias, ignoring case. A "-wei"
+// suffix ("curve25519-wei") returns the isomorphic short Weierstrass curve
+// in place of a Montgomery or Edwards one, with P, A and B in decimal.
+func LookupCurve(name string) (NamedCurve, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	base, wei := strings.CutSuffix(key, weiSuffix)
+	for _, nc := range namedCurves {
+		if nc.Name != base && !slices.Contains(nc.Aliases, base) {
+			continue
+		}
+		if !wei || nc.Form == "weierstrass" {
+			return nc, nil
+		}
+		c, err := nc.Weierstrass()
+		if err != nil {
+			return NamedCurve{}, err
+		}
+		return NamedCurve{Name: nc.Name + weiSuffix, Form: "weierstrass", P: c.P.String(), A: c.A.String(), B: c.B.String()}, nil
+	}
+	return NamedCurve{}, fmt.Errorf("unknown curve %q (known: %s; add %s for the Weierstrass form of a Montgomery or Edwards curve)",
+		name, strings.Join(CurveNames(), ", "), weiSuffix)
+}
+
+// Weierstrass returns nc as a short Weierstrass Curve, converting from its
+// published model where needed.
+func (nc NamedCurve) Weierstrass() (Curve, error) {
+	p, err := ParseBig(nc.P)
+	if err != nil {
+		return Curve{}, err
+	}
+	a, err := ParseBig(nc.A)
+	if err != nil {
+		return Curve{}, err
+	}
+	b, err := ParseBig(nc.B)
+	if err != nil {
+		return Curve{}, err
+	}
+	a, b = Mod(a, p), Mod(b, p)
+	switch nc.Form {
+	case "montgomery":
+		return Montgomery{P: p, A: a, B: b}.ToWeierstrass()
+	case "edwards":
+		return Edwards{P: p, A: a, D: b}.ToWeierstrass()
+	}
+	return Curve{P: p, A: a, B: b}, nil
+}
@@ -0,0 +1,226 @@
+package ec
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ---------- pairings ----------
+
+// Both pairings here take their values in F_p, so they see only torsion
+// that is rational over F_p: the Weil pairing needs P and Q in E(F_p)[m],
+// which forces m | p-1, and the Tate pairing needs m | p-1 (embedding
+// degree 1). Larger embedding degrees need E over F_{p^k}.
+
+// millerTries bounds the auxiliary points S tried before giving up.
+const millerTries = 64
+
+// errMillerDegenerate: the Miller function hit a zero or pole at an
+// evaluation point; another auxiliary point avoids it.
+var errMillerDegenerate = errors.New("miller: evaluation point in the divisor's support")
+
+// miller evaluates at X and Y the function f with div(f) = m[P] - m[O]
+// (P of order dividing m), returning f(X)/f(Y). Lines are evaluated in
+// projective pairs (numerator, denominator) so the loop inverts only once.
+func (c Curve) miller(P Point, m *big.Int, X, Y Point) (*big.Int, error) {
+	p := c.P
+	nx, dx := big.NewInt(1), big.NewInt(1) // f(X) = nx/dx
+	ny, dy := big.NewInt(1), big.NewInt(1)
+	// step multiplies f by l_{T,U}/v_{T+U} and returns T+U
+	step := func(T, U Point) (Point, error) {
+		var lam *big.Int
+		vertical := false
+		switch {
+		case T.X.Cmp(U.X) == 0 && AddM(T.Y, U.Y, p).Sign() == 0:
+			vertical = true
+		case T.X.Cmp(U.X) == 0:
+			inv, err := InvM(MulM(big.NewInt(2), T.Y, p), p)
+			if err != nil {
+				return Point{}, err
+			}
+			lam = MulM(AddM(MulM(big.NewInt(3), MulM(T.X, T.X, p), p), c.A, p), inv, p)
+		default:
+			inv, err := InvM(SubM(U.X, T.X, p), p)
+			if err != nil {
+				return Point{}, err
+			}
+			lam = MulM(SubM(U.Y, T.Y, p), inv, p)
+		}
+		R, err := c.Add(T, U)
+		if err != nil {
+			return Point{}, err
+		}
+		for _, e := range []struct {
+			Z    Point
+			n, d *big.Int
+		}{{X, nx, dx}, {Y, ny, dy}} {
+			// l(Z) = y - y_T - λ(x - x_T), or x - x_T for a vertical line; v(Z) = x - x_R
+			var l *big.Int
+			if vertical {
+				l = SubM(e.Z.X, T.X, p)
+			} else {
+				l = SubM(SubM(e.Z.Y, T.Y, p), MulM(lam, SubM(e.Z.X, T.X, p), p), p)
+			}
+			e.n.Set(MulM(e.n, l, p))
+			if !R.Inf {
+				e.d.Set(MulM(e.d, SubM(e.Z.X, R.X, p), p))
+			}
+		}
+		return R, nil
+	}
+	T := P
+	for i := m.BitLen() - 2; i >= 0; i-- {
+		for _, f := range []*big.Int{nx, dx, ny, dy} {
+			f.Set(MulM(f, f, p))
+		}
+		var err error
+		if T, err = step(T, T); err != nil {
+			return nil, err
+		}
+		if m.Bit(i) == 1 {
+			if T, err = step(T, P); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !T.Inf {
+		return nil, fmt.Errorf("miller: %s·P ≠ O", m)
+	}
+	num, den := MulM(nx, dy, p), MulM(dx, ny, p)
+	if num.Sign() == 0 || den.Sign() == 0 {
+		return nil, errMillerDegenerate
+	}
+	inv, err := InvM(den, p)
+	if err != nil {
+		return nil, err
+	}
+	return MulM(num, inv, p), nil
+}
+
+// auxPoints returns candidate auxiliary points S: small x first, so the
+// pairings are deterministic.
+func (c Curve) auxPoints(yield func(S Point) bool) {
+	p := c.P
+	tries := 0
+	for x := big.NewInt(0); x.Cmp(p) < 0 && tries < millerTries; x.Add(x, big.NewInt(1)) {
+		rhs := AddM(AddM(MulM(x, MulM(x, x, p), p), MulM(c.A, x, p), p), c.B, p)
+		y, err := SqrtModP(rhs, p)
+		if err != nil {
+			continue
+		}
+		tries++
+		if !yield(Point{X: new(big.Int).Set(x), Y: y}) {
+			return
+		}
+	}
+}
+
+// WeilPairing returns e_m(P, Q) for P, Q in E(F_p)[m], an m-th root of
+// unity in F_p. With f_P, f_Q the Miller functions of divisor m[P] - m[O]
+// and m[Q] - m[O] and S an auxiliary point,
+//
+//	e_m(P, Q) = f_P(Q+S)/f_P(S) · f_Q(-S)/f_Q(P-S).
+//
+// It is bilinear, alternating (e_m(P, P) = 1) and non-degenerate on E[m].
+func (c Curve) WeilPairing(P, Q Point, m *big.Int) (*big.Int, error) {
+	if m.Sign() <= 0 {
+		return nil, errors.New("weil pairing: m must be positive")
+	}
+	for _, R := range []Point{P, Q} {
+		if !c.On(R) {
+			return nil, errors.New("weil pairing: point not on the curve")
+		}
+		if mR, err := c.ScalarMul(m, R); err != nil || !mR.Inf {
+			return nil, fmt.Errorf("weil pairing: point not killed by m = %s", m)
+		}
+	}
+	if P.Inf || Q.Inf || P.Equal(Q) {
+		return big.NewInt(1), nil
+	}
+	var out *big.Int
+	var err error
+	c.auxPoints(func(S Point) bool {
+		var QS, PS Point
+		if QS, err = c.Add(Q, S); err != nil {
+			return false
+		}
+		if PS, err = c.Add(P, c.Neg(S)); err != nil {
+			return false
+		}
+		if QS.Inf || PS.Inf || S.Equal(P) || S.Equal(c.Neg(Q)) {
+			return true
+		}
+		var a, b *big.Int
+		if a, err = c.miller(P, m, QS, S); err == errMillerDegenerate {
+			return true
+		} else if err != nil {
+			return false
+		}
+		if b, err = c.miller(Q, m, c.Neg(S), PS); err == errMillerDegenerate {
+			return true
+		} else if err != nil {
+			return false
+		}
+		out = MulM(a, b, c.P)
+		return false
+	})
+	if err != nil && err != errMillerDegenerate {
+		return nil, err
+	}
+	if out == nil {
+		return nil, fmt.Errorf("weil pairing: no usable auxiliary point in %d tries", millerTries)
+	}
+	return out, nil
+}
+
+// TatePairing returns the reduced Tate pairing
+//
+//	t_m(P, Q) = (f_P(Q+S)/f_P(S))^((p-1)/m)
+//
+// for P in E(F_p)[m] and any Q in E(F_p), where m | p-1. The final power
+// makes it independent of S and of Q's class mod mE(F_p), and leaves an
+// m-th root of unity; t_m(P, ·) ≠ 1 for some Q whenever P ≠ O has prime
+// order m, which is what carries the discrete log of <P> into F_p^*.
+func (c Curve) TatePairing(P, Q Point, m *big.Int) (*big.Int, error) {
+	pm1 := new(big.Int).Sub(c.P, big.NewInt(1))
+	e, rem := new(big.Int).QuoRem(pm1, m, new(big.Int))
+	if m.Sign() <= 0 || rem.Sign() != 0 {
+		return nil, fmt.Errorf("tate pairing: m = %s does not divide p-1 (embedding degree > 1)", m)
+	}
+	if !c.On(P) || !c.On(Q) {
+		return nil, errors.New("tate pairing: point not on the curve")
+	}
+	if mP, err := c.ScalarMul(m, P); err != nil || !mP.Inf {
+		return nil, fmt.Errorf("tate pairing: m = %s does not kill P", m)
+	}
+	if P.Inf || Q.Inf {
+		return big.NewInt(1), nil
+	}
+	var out *big.Int
+	var err error
+	c.auxPoints(func(S Point) bool {
+		var QS Point
+		if QS, err = c.Add(Q, S); err != nil {
+			return false
+		}
+		if QS.Inf {
+			return true
+		}
+		var f *big.Int
+		if f, err = c.miller(P, m, QS, S); err == errMillerDegenerate {
+			return true
+		} else if err != nil {
+			return false
+		}
+		out = PowM(f, e, c.P)
+		return false
+	})
+	if err != nil && err != errMillerDegenerate {
+		return nil, err
+	}
+	if out == nil {
+		return nil, fmt.Errorf("tate pairing: no usable auxiliary point in %d tries", millerTries)
+	}
+	return out, nil
+}
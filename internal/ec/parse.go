@@ -0,0 +1,173 @@
+package ec
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// maxParsedBits caps the size of a parsed integer, so a typo like 2^2^40
+// fails fast instead of exhausting memory.
+const maxParsedBits = 1 << 16
+
+// ParseBig reads an integer the way curve parameters are usually written:
+// decimal or 0x-hex literals, combined with + - * ^ (or **) and
+// parentheses, with the usual precedence and ^ binding right — so
+// "2^61-1", "2^255 - 19", "-3" and "0xFFFFFFFF00000001 * 2^192 + 1" all
+// parse. Negative results are returned as is; callers reduce mod p.
+func ParseBig(s string) (*big.Int, error) {
+	ps := &exprParser{s: s}
+	z, err := ps.expr()
+	if err == nil {
+		if ps.skip(); ps.i < len(ps.s) {
+			err = fmt.Errorf("unexpected %q", ps.s[ps.i:])
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse integer %q: %w", s, err)
+	}
+	return z, nil
+}
+
+// exprParser is a recursive-descent parser over s:
+//
+//	expr  = term { ("+" | "-") term }
+//	term  = unary { "*" unary }
+//	unary = ("-" | "+") unary | power
+//	power = atom [ ("^" | "**") unary ]
+//	atom  = number | "(" expr ")"
+type exprParser struct {
+	s string
+	i int
+}
+
+func (ps *exprParser) skip() {
+	for ps.i < len(ps.s) && (ps.s[ps.i] == ' ' || ps.s[ps.i] == '\t') {
+		ps.i++
+	}
+}
+
+// eat consumes tok if it comes next.
+func (ps *exprParser) eat(tok string) bool {
+	ps.skip()
+	if strings.HasPrefix(ps.s[ps.i:], tok) {
+		ps.i += len(tok)
+		return true
+	}
+	return false
+}
+
+func (ps *exprParser) expr() (*big.Int, error) {
+	z, err := ps.term()
+	for err == nil {
+		neg := ps.eat("-")
+		if !neg && !ps.eat("+") {
+			break
+		}
+		var t *big.Int
+		if t, err = ps.term(); err == nil {
+			if neg {
+				t.Neg(t)
+			}
+			z.Add(z, t)
+			err = checkSize(z)
+		}
+	}
+	return z, err
+}
+
+func (ps *exprParser) term() (*big.Int, error) {
+	z, err := ps.unary()
+	for err == nil {
+		if ps.skip(); strings.HasPrefix(ps.s[ps.i:], "**") || !ps.eat("*") {
+			break
+		}
+		var t *big.Int
+		if t, err = ps.unary(); err == nil {
+			z.Mul(z, t)
+			err = checkSize(z)
+		}
+	}
+	return z, err
+}
+
+func (ps *exprParser) unary() (*big.Int, error) {
+	switch {
+	case ps.eat("-"):
+		z, err := ps.unary()
+		if err != nil {
+			return nil, err
+		}
+		return z.Neg(z), nil
+	case ps.eat("+"):
+		return ps.unary()
+	}
+	return ps.power()
+}
+
+func (ps *exprParser) power() (*big.Int, error) {
+	z, err := ps.atom()
+	if err != nil || !ps.eat("^") && !ps.eat("**") {
+		return z, err
+	}
+	e, err := ps.unary()
+	if err != nil {
+		return nil, err
+	}
+	if e.Sign() < 0 {
+		return nil, errors.New("negative exponent")
+	}
+	// |z|^e has about e·log2|z| bits
+	if z.CmpAbs(big.NewInt(1)) > 0 && (!e.IsInt64() || e.Int64()*int64(z.BitLen()-1) > maxParsedBits) {
+		return nil, fmt.Errorf("%s^%s is too large", z, e)
+	}
+	return z.Exp(z, e, nil), nil
+}
+
+func (ps *exprParser) atom() (*big.Int, error) {
+	if ps.eat("(") {
+		z, err := ps.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !ps.eat(")") {
+			return nil, errors.New("missing )")
+		}
+		return z, nil
+	}
+	ps.skip()
+	start, base := ps.i, 10
+	if strings.HasPrefix(ps.s[ps.i:], "0x") || strings.HasPrefix(ps.s[ps.i:], "0X") {
+		ps.i += 2
+		start, base = ps.i, 16
+	}
+	for ps.i < len(ps.s) && isDigit(ps.s[ps.i], base) {
+		ps.i++
+	}
+	if ps.i == start {
+		if ps.i == len(ps.s) {
+			return nil, errors.New("unexpected end")
+		}
+		return nil, fmt.Errorf("unexpected %q", ps.s[ps.i:])
+	}
+	z, _ := new(big.Int).SetString(ps.s[start:ps.i], base)
+	return z, checkSize(z)
+}
+
+func isDigit(c byte, base int) bool {
+	switch {
+	case '0' <= c && c <= '9':
+		return true
+	case base == 16:
+		return 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+	}
+	return false
+}
+
+func checkSize(z *big.Int) error {
+	if z.BitLen() > maxParsedBits {
+		return fmt.Errorf("more than %d bits", maxParsedBits)
+	}
+	return nil
+}
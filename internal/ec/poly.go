@@ -0,0 +1,238 @@
+package ec
+
+import (
+	"math/big"
+	"sort"
+)
+
+// ---------- polynomials over F_p ----------
+
+// Poly is a polynomial over F_p, coefficients from the constant term up,
+// reduced mod p and trimmed so the last one is nonzero (nil is 0).
+type Poly []*big.Int
+
+// NewPoly reduces cs mod p and trims it.
+func NewPoly(p *big.Int, cs ...*big.Int) Poly {
+	f := make(Poly, len(cs))
+	for i, c := range cs {
+		f[i] = Mod(c, p)
+	}
+	return f.trim()
+}
+
+func (f Poly) trim() Poly {
+	for len(f) > 0 && f[len(f)-1].Sign() == 0 {
+		f = f[:len(f)-1]
+	}
+	return f
+}
+
+// Degree is -1 for the zero polynomial.
+func (f Poly) Degree() int { return len(f) - 1 }
+
+// Eval returns f(x) by Horner's rule.
+func (f Poly) Eval(x, p *big.Int) *big.Int {
+	r := new(big.Int)
+	for i := len(f) - 1; i >= 0; i-- {
+		r = AddM(MulM(r, x, p), f[i], p)
+	}
+	return r
+}
+
+func PolyAdd(f, g Poly, p *big.Int) Poly {
+	if len(f) < len(g) {
+		f, g = g, f
+	}
+	out := make(Poly, len(f))
+	for i := range f {
+		out[i] = new(big.Int).Set(f[i])
+		if i < len(g) {
+			out[i] = AddM(out[i], g[i], p)
+		}
+	}
+	return out.trim()
+}
+
+func PolySub(f, g Poly, p *big.Int) Poly {
+	neg := make(Poly, len(g))
+	for i, c := range g {
+		neg[i] = NegM(c, p)
+	}
+	return PolyAdd(f, neg, p)
+}
+
+func PolyMul(f, g Poly, p *big.Int) Poly {
+	if len(f) == 0 || len(g) == 0 {
+		return nil
+	}
+	out := make(Poly, len(f)+len(g)-1)
+	for i := range out {
+		out[i] = new(big.Int)
+	}
+	t := new(big.Int)
+	for i, a := range f {
+		for j, b := range g {
+			out[i+j].Add(out[i+j], t.Mul(a, b))
+		}
+	}
+	for i := range out {
+		out[i].Mod(out[i], p)
+	}
+	return out.trim()
+}
+
+// PolyDivMod returns q, r with f = q·g + r, deg r < deg g; g ≠ 0.
+func PolyDivMod(f, g Poly, p *big.Int) (q, r Poly) {
+	r = append(Poly(nil), f...)
+	for i := range r {
+		r[i] = new(big.Int).Set(r[i])
+	}
+	if len(r) < len(g) {
+		return nil, r
+	}
+	inv := new(big.Int).ModInverse(g[len(g)-1], p)
+	q = make(Poly, len(r)-len(g)+1)
+	for i := len(q) - 1; i >= 0; i-- {
+		c := MulM(r[i+len(g)-1], inv, p)
+		q[i] = c
+		if c.Sign() == 0 {
+			continue
+		}
+		for j, b := range g {
+			r[i+j] = SubM(r[i+j], MulM(c, b, p), p)
+		}
+	}
+	return q.trim(), r.trim()
+}
+
+// PolyGCD returns the monic gcd of f and g.
+func PolyGCD(f, g Poly, p *big.Int) Poly {
+	for len(g) > 0 {
+		_, r := PolyDivMod(f, g, p)
+		f, g = g, r
+	}
+	if len(f) == 0 {
+		return nil
+	}
+	inv := new(big.Int).ModInverse(f[len(f)-1], p)
+	out := make(Poly, len(f))
+	for i, c := range f {
+		out[i] = MulM(c, inv, p)
+	}
+	return out
+}
+
+// PolyPowMod returns f^e mod m.
+func PolyPowMod(f Poly, e *big.Int, m Poly, p *big.Int) Poly {
+	_, base := PolyDivMod(f, m, p)
+	out := Poly{big.NewInt(1)}
+	_, out = PolyDivMod(out, m, p)
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		_, out = PolyDivMod(PolyMul(out, out, p), m, p)
+		if e.Bit(i) == 1 {
+			_, out = PolyDivMod(PolyMul(out, base, p), m, p)
+		}
+	}
+	return out
+}
+
+// Roots returns the distinct roots of f ≠ 0 in F_p, ascending: the linear
+// factors split off by gcd(f, x^p - x), then separated by
+// Cantor–Zassenhaus with the shifts x + δ, δ = 0, 1, 2, ... in turn.
+func (f Poly) Roots(p *big.Int) []*big.Int {
+	if len(f) <= 1 {
+		return nil
+	}
+	x := Poly{new(big.Int), big.NewInt(1)}
+	xp := PolyPowMod(x, p, f, p)
+	g := PolyGCD(f, PolySub(xp, x, p), p)
+	var roots []*big.Int
+	var split func(g Poly, delta int64)
+	split = func(g Poly, delta int64) {
+		switch g.Degree() {
+		case 0:
+			return
+		case 1:
+			roots = append(roots, NegM(g[0], p)) // g is monic
+			return
+		}
+		if p.Cmp(big.NewInt(3)) <= 0 {
+			// tiny fields: test every element
+			for v := int64(0); v < p.Int64(); v++ {
+				if g.Eval(big.NewInt(v), p).Sign() == 0 {
+					roots = append(roots, big.NewInt(v))
+				}
+			}
+			return
+		}
+		half := new(big.Int).Rsh(new(big.Int).Sub(p, big.NewInt(1)), 1)
+		for d := delta; ; d++ {
+			h := PolyPowMod(Poly{big.NewInt(d), big.NewInt(1)}, half, g, p)
+			h = PolyGCD(g, PolySub(h, Poly{big.NewInt(1)}, p), p)
+			if h.Degree() > 0 && h.Degree() < g.Degree() {
+				q, _ := PolyDivMod(g, h, p)
+				split(h, d+1)
+				split(q, d+1)
+				return
+			}
+		}
+	}
+	split(g, 0)
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Cmp(roots[j]) < 0 })
+	return roots
+}
+
+// DivisionPolynomial returns the x-only division polynomial of c for odd
+// n ≥ 1: ψ_n, whose roots are the x-coordinates of the points of order
+// dividing n other than O (over the algebraic closure; Roots keeps those in
+// F_p). Even n give ψ_n / 2y; the recursion needs both.
+func (c Curve) DivisionPolynomial(n int) Poly {
+	p, A, B := c.P, c.A, c.B
+	memo := map[int]Poly{0: nil, 1: {big.NewInt(1)}, 2: {big.NewInt(1)}}
+	memo[3] = NewPoly(p,
+		new(big.Int).Neg(new(big.Int).Mul(A, A)),
+		new(big.Int).Mul(big.NewInt(12), B),
+		new(big.Int).Mul(big.NewInt(6), A),
+		new(big.Int),
+		big.NewInt(3))
+	// ψ_4 / 2y = 2(x^6 + 5Ax^4 + 20Bx^3 - 5A^2x^2 - 4ABx - 8B^2 - A^3)
+	A2, AB, B2, A3 := new(big.Int).Mul(A, A), new(big.Int).Mul(A, B), new(big.Int).Mul(B, B), new(big.Int).Mul(A, new(big.Int).Mul(A, A))
+	memo[4] = NewPoly(p,
+		new(big.Int).Mul(big.NewInt(-2), new(big.Int).Add(new(big.Int).Mul(big.NewInt(8), B2), A3)),
+		new(big.Int).Mul(big.NewInt(-8), AB),
+		new(big.Int).Mul(big.NewInt(-10), A2),
+		new(big.Int).Mul(big.NewInt(40), B),
+		new(big.Int).Mul(big.NewInt(10), A),
+		new(big.Int),
+		big.NewInt(2))
+	// F = (2y)^2 = 4(x^3 + Ax + B), squared
+	F := NewPoly(p, new(big.Int).Mul(big.NewInt(4), B), new(big.Int).Mul(big.NewInt(4), A), new(big.Int), big.NewInt(4))
+	F2 := PolyMul(F, F, p)
+	var psi func(n int) Poly
+	psi = func(n int) Poly {
+		if f, ok := memo[n]; ok {
+			return f
+		}
+		m := n / 2
+		var f Poly
+		if n%2 == 1 {
+			// ψ_{2m+1} = ψ_{m+2}ψ_m^3 - ψ_{m-1}ψ_{m+1}^3, the even-index pair carrying (2y)^4
+			a := PolyMul(psi(m+2), PolyMul(psi(m), PolyMul(psi(m), psi(m), p), p), p)
+			b := PolyMul(psi(m-1), PolyMul(psi(m+1), PolyMul(psi(m+1), psi(m+1), p), p), p)
+			if m%2 == 0 {
+				a = PolyMul(a, F2, p)
+			} else {
+				b = PolyMul(b, F2, p)
+			}
+			f = PolySub(a, b, p)
+		} else {
+			// ψ_{2m}/2y = ψ_m(ψ_{m+2}ψ_{m-1}^2 - ψ_{m-2}ψ_{m+1}^2), in the reduced forms
+			a := PolyMul(psi(m+2), PolyMul(psi(m-1), psi(m-1), p), p)
+			b := PolyMul(psi(m-2), PolyMul(psi(m+1), psi(m+1), p), p)
+			f = PolyMul(psi(m), PolySub(a, b, p), p)
+		}
+		memo[n] = f
+		return f
+	}
+	return psi(n)
+}
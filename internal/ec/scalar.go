@@ -0,0 +1,96 @@
+package ec
+
+import "math/big"
+
+// ---------- scalar multiplication ----------
+
+// wnafWidth is the window width used by ScalarMul. Width w precomputes the
+// 2^(w-2) odd multiples P, 3P, ..., (2^(w-1)-1)P and leaves on average one
+// addition per w+1 doublings.
+const wnafWidth = 4
+
+// ScalarMul returns kP for any integer k (negative k gives -|k|P), using a
+// width-4 NAF: a fixed four-point table of odd multiples, then one doubling
+// per bit and an addition only at the nonzero digits.
+func (c Curve) ScalarMul(k *big.Int, P Point) (Point, error) {
+	if k.Sign() == 0 || P.Inf {
+		return Point{Inf: true}, nil
+	}
+	if k.Sign() < 0 {
+		P = c.Neg(P)
+	}
+	digits := wNAF(new(big.Int).Abs(k), wnafWidth)
+
+	// tbl[i] = (2i+1)·P
+	tbl := make([]Point, 1<<(wnafWidth-2))
+	tbl[0] = P
+	P2, err := c.Double(P)
+	if err != nil {
+		return Point{}, err
+	}
+	for i := 1; i < len(tbl); i++ {
+		if tbl[i], err = c.Add(tbl[i-1], P2); err != nil {
+			return Point{}, err
+		}
+	}
+
+	R := Point{Inf: true}
+	for i := len(digits) - 1; i >= 0; i-- {
+		if R, err = c.Double(R); err != nil {
+			return Point{}, err
+		}
+		switch d := digits[i]; {
+		case d > 0:
+			R, err = c.Add(R, tbl[d/2])
+		case d < 0:
+			R, err = c.Add(R, c.Neg(tbl[-d/2]))
+		}
+		if err != nil {
+			return Point{}, err
+		}
+	}
+	return R, nil
+}
+
+// ScalarMulBinary returns kP (k ≥ 0) by plain left-to-right double-and-add.
+// It is the reference the windowed path is tested against and is handy
+// when reading the code for the first time.
+func (c Curve) ScalarMulBinary(k *big.Int, P Point) (Point, error) {
+	R := Point{Inf: true}
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		var err error
+		if R, err = c.Double(R); err != nil {
+			return Point{}, err
+		}
+		if k.Bit(i) == 1 {
+			if R, err = c.Add(R, P); err != nil {
+				return Point{}, err
+			}
+		}
+	}
+	return R, nil
+}
+
+// wNAF returns the width-w non-adjacent form of k ≥ 0, least significant
+// digit first. Every nonzero digit is odd with |d| < 2^(w-1), and any w
+// consecutive digits contain at most one nonzero.
+func wNAF(k *big.Int, w uint) []int {
+	var digits []int
+	n := new(big.Int).Set(k)
+	mod := int64(1) << w
+	for n.Sign() > 0 {
+		d := 0
+		if n.Bit(0) == 1 {
+			// d = n mods 2^w, in (-2^(w-1), 2^(w-1)]
+			r := new(big.Int).And(n, big.NewInt(mod-1)).Int64()
+			if r >= mod/2 {
+				r -= mod
+			}
+			d = int(r)
+			n.Sub(n, big.NewInt(r))
+		}
+		digits = append(digits, d)
+		n.Rsh(n, 1)
+	}
+	return digits
+}
@@ -0,0 +1,171 @@
+package ec
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// ---------- rational m-torsion ----------
+
+// torsionPoly returns the squarefree polynomial whose roots are the
+// x-coordinates of E[m] \ {O} over the algebraic closure: ψ_m for odd m,
+// and for even m the reduced ψ_m / 2y, whose roots are E[m] \ E[2], times
+// x^3 + Ax + B for E[2] itself. It needs p ∤ m.
+func (c Curve) torsionPoly(m int) Poly {
+	g := c.DivisionPolynomial(m)
+	if m%2 == 0 {
+		g = PolyMul(g, NewPoly(c.P, c.B, c.A, new(big.Int), big.NewInt(1)), c.P)
+	}
+	return g
+}
+
+func (c Curve) checkTorsionIndex(m int) error {
+	if m < 1 || new(big.Int).Mod(big.NewInt(int64(m)), c.P).Sign() == 0 {
+		return fmt.Errorf("m-torsion needs m ≥ 1 prime to p, got m=%d, p=%s", m, c.P)
+	}
+	return nil
+}
+
+// RationalTorsion returns the affine points of E(F_p)[m], p ∤ m: the roots
+// in F_p of the division polynomial whose x^3 + Ax + B is a square.
+func (c Curve) RationalTorsion(m int) ([]Point, error) {
+	if err := c.checkTorsionIndex(m); err != nil {
+		return nil, err
+	}
+	if m == 1 {
+		return nil, nil
+	}
+	p := c.P
+	var out []Point
+	for _, x := range c.torsionPoly(m).Roots(p) {
+		rhs := AddM(AddM(MulM(x, MulM(x, x, p), p), MulM(c.A, x, p), p), c.B, p)
+		if rhs.Sign() == 0 {
+			out = append(out, Point{X: x, Y: new(big.Int)})
+			continue
+		}
+		y, err := SqrtModP(rhs, p)
+		if err != nil {
+			continue // (x, y) lies over F_{p^2}
+		}
+		out = append(out, Point{X: x, Y: y}, Point{X: x, Y: NegM(y, p)})
+	}
+	return out, nil
+}
+
+// TorsionStructure returns d1, d2 with E(F_p)[m] ≅ Z/d1 × Z/d2, d2 | d1 | m.
+// For each prime power ℓ^k ∥ m the ℓ-part is Z/ℓ^a × Z/ℓ^b, and going from
+// E(F_p)[ℓ^(j-1)] to E(F_p)[ℓ^j] multiplies its size by ℓ^([a≥j]+[b≥j]).
+func (c Curve) TorsionStructure(m int) (d1, d2 int, err error) {
+	if err := c.checkTorsionIndex(m); err != nil {
+		return 0, 0, err
+	}
+	d1, d2 = 1, 1
+	rest := m
+	for l := 2; rest > 1; l++ {
+		if rest%l != 0 {
+			continue
+		}
+		prev, lj := 1, 1
+		for ; rest%l == 0; rest /= l {
+			lj *= l
+			pts, err := c.RationalTorsion(lj)
+			if err != nil {
+				return 0, 0, err
+			}
+			n := len(pts) + 1
+			switch n / prev {
+			case l * l:
+				d1, d2 = d1*l, d2*l
+			case l:
+				d1 *= l
+			}
+			prev = n
+		}
+	}
+	return d1, d2, nil
+}
+
+// FullTorsionDegree returns the least k ≤ maxK with E[m] ⊆ E(F_{p^k}), or
+// 0 if there is none, for p ∤ m. E[m] is the sum of its prime-power parts,
+// so k is the lcm of theirs. E[ℓ^e] is rational over F_q exactly when
+// every root of the division polynomial g lies in F_q, x^q ≡ x mod g, and
+// y^q = y·f^((q-1)/2) is y at each root off E[2]. Both are carried from
+// k to k+1 through the Frobenius x^p mod g, by composition. The Weil
+// pairing needs ℓ^e | q - 1, so only those k are tested.
+func (c Curve) FullTorsionDegree(m, maxK int) (int, error) {
+	if err := c.checkTorsionIndex(m); err != nil {
+		return 0, err
+	}
+	k, rest := 1, m
+	for l := 2; rest > 1; l++ {
+		le := 1
+		for ; rest%l == 0; rest /= l {
+			le *= l
+		}
+		if le == 1 {
+			continue
+		}
+		kl, err := c.primePowerTorsionDegree(le, maxK)
+		if err != nil || kl == 0 {
+			return 0, err
+		}
+		a, b := k, kl
+		for b != 0 {
+			a, b = b, a%b
+		}
+		if k = k / a * kl; k > maxK { // lcm(k, kl)
+			return 0, nil
+		}
+	}
+	return k, nil
+}
+
+// primePowerTorsionDegree is FullTorsionDegree for m = ℓ^e > 1.
+func (c Curve) primePowerTorsionDegree(m, maxK int) (int, error) {
+	p := c.P
+	f := NewPoly(p, c.B, c.A, new(big.Int), big.NewInt(1))
+	g := c.torsionPoly(m)
+	g1 := g // the roots off E[2]: f(x)^((q-1)/2) must be 1 there
+	if m%2 == 0 {
+		g1 = c.DivisionPolynomial(m)
+	}
+	x := Poly{new(big.Int), big.NewInt(1)}
+	h := PolyPowMod(x, p, g, p) // x^p
+	Y1 := PolyPowMod(f, new(big.Int).Rsh(new(big.Int).Sub(p, big.NewInt(1)), 1), g, p)
+	X, Y := h, Y1 // x^(p^k) and f^((p^k-1)/2), mod g
+	mm := big.NewInt(int64(m))
+	pk := new(big.Int).Mod(p, mm)
+	one := Poly{big.NewInt(1)}
+	for k := 1; k <= maxK; k++ {
+		if k > 1 {
+			// a(x)^p = a(x^p) over F_p
+			_, Y = PolyDivMod(PolyMul(polyCompose(Y, h, g, p), Y1, p), g, p)
+			X = polyCompose(X, h, g, p)
+			pk.Mul(pk, p).Mod(pk, mm)
+		}
+		if pk.Cmp(big.NewInt(1)) != 0 {
+			continue
+		}
+		_, r := PolyDivMod(PolySub(X, x, p), g, p)
+		if len(r) != 0 {
+			continue
+		}
+		if len(g1) > 1 {
+			_, r = PolyDivMod(PolySub(Y, one, p), g1, p)
+			if len(r) != 0 {
+				continue
+			}
+		}
+		return k, nil
+	}
+	return 0, nil
+}
+
+// polyCompose returns a(h) mod g by Horner's rule.
+func polyCompose(a, h, g Poly, p *big.Int) Poly {
+	var r Poly
+	for i := len(a) - 1; i >= 0; i-- {
+		_, r = PolyDivMod(PolyAdd(PolyMul(r, h, p), Poly{a[i]}, p), g, p)
+	}
+	return r
+}
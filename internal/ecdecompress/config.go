@@ -0,0 +1,59 @@
+package ecdecompress
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"ectorus/internal/ec"
+)
+
+type Config struct {
+	Path    string   // compressed dump ("-" = stdin)
+	P, A, B *big.Int // --p/--A/--B; nil = take the curve from the header
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("ecdecompress", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ecdecompress [flags] FILE")
+		fs.PrintDefaults()
+	}
+
+	var (
+		pStr = fs.String("p", "", "prime modulus p (decimal, 0x-hex or e.g. 2^61-1; default: from the dump's --header)")
+		AStr = fs.String("A", "", "curve parameter A (default: from the header)")
+		BStr = fs.String("B", "", "curve parameter B (default: from the header)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 1 {
+		return nil, errors.New("want exactly one point file")
+	}
+	cfg := &Config{Path: fs.Arg(0)}
+	set := 0
+	for _, f := range []struct {
+		s    string
+		name string
+		dst  **big.Int
+	}{{*pStr, "p", &cfg.P}, {*AStr, "A", &cfg.A}, {*BStr, "B", &cfg.B}} {
+		if strings.TrimSpace(f.s) == "" {
+			continue
+		}
+		set++
+		z, err := ec.ParseBig(f.s)
+		if err != nil {
+			return nil, fmt.Errorf("--%s: %w", f.name, err)
+		}
+		*f.dst = z
+	}
+	if set != 0 && set != 3 {
+		return nil, errors.New("pass all of --p, --A and --B, or none")
+	}
+	return cfg, nil
+}
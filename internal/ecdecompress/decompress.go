@@ -0,0 +1,107 @@
+// Package ecdecompress expands a dump written with ecscan --format
+// compressed back into ecscan's text format, behind cmd/ecdecompress: each
+// y is recovered from x and its parity by a Tonelli–Shanks square root.
+package ecdecompress
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+
+	"ectorus/internal/ec"
+	"ectorus/internal/ptfile"
+)
+
+// Run writes the points of cfg.Path to w as "x y" lines, with the header,
+// point at infinity marker and checksum footer where the input had them.
+// The input's own footer is checked as it goes.
+func Run(cfg *Config, w io.Writer) (err error) {
+	rd, err := ptfile.Open(cfg.Path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := rd.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if rd.Format() != ptfile.FormatCompressed {
+		return fmt.Errorf("%s: a %s dump, not a compressed one", cfg.Path, rd.Format())
+	}
+	m := rd.Meta()
+	p, A, B := cfg.P, cfg.A, cfg.B
+	if p == nil {
+		if m == nil || m.P == nil || m.A == nil || m.B == nil {
+			return fmt.Errorf("%s: no curve: pass --p/--A/--B or write the dump with --header", cfg.Path)
+		}
+		p, A, B = m.P, m.A, m.B
+	}
+	if p.Cmp(big.NewInt(3)) <= 0 || !p.ProbablyPrime(32) {
+		return fmt.Errorf("p = %s is not a prime > 3", p)
+	}
+	A, B = ec.Mod(A, p), ec.Mod(B, p)
+	rd.SetCurve(p, A, B)
+
+	// The footer's SHA-256 covers the bytes under the buffer, as in ecscan.
+	sum := sha256.New()
+	bw := bufio.NewWriterSize(io.MultiWriter(sum, w), 1<<20)
+	if m != nil {
+		fmt.Fprintf(bw, "# ecscan v=%d p=%s A=%s B=%s", m.Version, p, A, B)
+		if m.Mode != "" {
+			fmt.Fprintf(bw, " mode=%s", m.Mode)
+		}
+		if m.Shard != "" {
+			fmt.Fprintf(bw, " shard=%s", m.Shard)
+		}
+		bw.WriteString("\n")
+	}
+	// O as ecscan's text format writes it in the header's version: a
+	// sentinel point for this p before v=2
+	inf := "inf\n"
+	switch {
+	case m == nil || m.Version >= 2:
+	case p.BitLen() > 63:
+		inf = "-1 -1\n"
+	default:
+		inf = "18446744073709551615 18446744073709551615\n"
+	}
+	var n uint64
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", cfg.Path, err)
+		}
+		if rec.Inf {
+			bw.WriteString(inf)
+			continue
+		}
+		n++
+		if _, err := fmt.Fprintf(bw, "%s %s\n", rec.Coords[0], rec.Coords[1]); err != nil {
+			return err
+		}
+	}
+	if m != nil && m.Version >= 1 {
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, "# end points=%d sha256=%s\n", n, hex.EncodeToString(sum.Sum(nil)))
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if f := rd.Footer(); f == nil && m != nil && m.Version >= 1 {
+		return fmt.Errorf("%s: header has v=%d but there is no footer (truncated file?)", cfg.Path, m.Version)
+	} else if f != nil && (f.Points != n || f.SHA256 != rd.Sum()) {
+		return fmt.Errorf("%s: the footer does not match the points read (truncated or altered?)", cfg.Path)
+	}
+	if k := rd.DataAfterFooter(); k > 0 {
+		return fmt.Errorf("%s: %d records after the footer were skipped", cfg.Path, k)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package ecdecompress
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ectorus/internal/ecscan"
+)
+
+// compressed writes the points of y^2 = x^3 + 2x + 3 over F_1009 in SEC1
+// form with a version v header and footer, and returns the path and the
+// "x y" lines.
+func compressed(t *testing.T, v int) (string, []string) {
+	t.Helper()
+	var body strings.Builder
+	fmt.Fprintf(&body, "# ecscan v=%d format=compressed p=1009 A=2 B=3 mode=table timestamp=x\n", v)
+	var want []string
+	err := ecscan.Scan(context.Background(), ecscan.Params{P: 1009, A: 2, B: 3}, func(x, y uint64) error {
+		fmt.Fprintf(&body, "%02x%04x\n", 2+y&1, x)
+		want = append(want, fmt.Sprintf("%d %d", x, y))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.WriteString("00\n")
+	fmt.Fprintf(&body, "# end points=%d sha256=%x\n", len(want), sha256.Sum256([]byte(body.String())))
+	path := filepath.Join(t.TempDir(), "c.txt")
+	if err := os.WriteFile(path, []byte(body.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path, want
+}
+
+func TestRoundTrip(t *testing.T) {
+	// O in the text layout of the header's version
+	for v, inf := range map[int]string{1: "18446744073709551615 18446744073709551615", 2: "inf"} {
+		path, want := compressed(t, v)
+		var out bytes.Buffer
+		if err := Run(&Config{Path: path}, &out); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(lines) != len(want)+3 || !strings.HasPrefix(lines[0], fmt.Sprintf("# ecscan v=%d p=1009 A=2 B=3", v)) || lines[len(lines)-2] != inf {
+			t.Fatalf("v=%d layout: %q … %q", v, lines[0], lines[len(lines)-2:])
+		}
+		for i, w := range want {
+			if lines[i+1] != w {
+				t.Fatalf("line %d: %q, want %q", i+2, lines[i+1], w)
+			}
+		}
+		body := strings.Join(lines[:len(lines)-1], "\n") + "\n"
+		if foot := fmt.Sprintf("# end points=%d sha256=%x", len(want), sha256.Sum256([]byte(body))); lines[len(lines)-1] != foot {
+			t.Fatalf("footer %q, want %q", lines[len(lines)-1], foot)
+		}
+	}
+}
+
+func TestDamagedInput(t *testing.T) {
+	path, _ := compressed(t, 2)
+	data, _ := os.ReadFile(path)
+	i := bytes.IndexByte(data, '\n') + 1
+	os.WriteFile(path, append(data[:i:i], data[bytes.IndexByte(data[i:], '\n')+i+1:]...), 0o644) // first point gone
+	if err := Run(&Config{Path: path}, new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "footer") {
+		t.Fatalf("want a footer mismatch, got %v", err)
+	}
+}
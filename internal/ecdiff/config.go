@@ -0,0 +1,43 @@
+package ecdiff
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+type Config struct {
+	PathA, PathB string // PathA is read twice, so it cannot be stdin
+	MaxReport    int    // --max: list at most this many points per side (0 = all)
+	CountOnly    bool   // --count-only: totals, no point lists
+	JSON         bool   // --json
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("ecdiff", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ecdiff [flags] A B")
+		fs.PrintDefaults()
+	}
+
+	var (
+		maxReport = fs.Int("max", 0, "list at most this many differing points per side (0 = all)")
+		countOnly = fs.Bool("count-only", false, "print only the totals")
+		jsonOut   = fs.Bool("json", false, "emit JSON instead of text")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 2 {
+		return nil, errors.New("want exactly two point files")
+	}
+	if fs.Arg(0) == "-" {
+		return nil, errors.New("the first file is read twice and cannot be stdin; swap the arguments")
+	}
+	if *maxReport < 0 {
+		return nil, fmt.Errorf("bad --max %d", *maxReport)
+	}
+	return &Config{PathA: fs.Arg(0), PathB: fs.Arg(1), MaxReport: *maxReport, CountOnly: *countOnly, JSON: *jsonOut}, nil
+}
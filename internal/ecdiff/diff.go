@@ -0,0 +1,221 @@
+// Package ecdiff compares two point dumps as sets, behind cmd/ecdiff. The
+// files may differ in format, compression and order; only the points they
+// hold matter.
+package ecdiff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strings"
+
+	"ectorus/internal/ptfile"
+)
+
+type Result struct {
+	A      File     `json:"a"`
+	B      File     `json:"b"`
+	Common uint64   `json:"common"`
+	OnlyA  uint64   `json:"onlyA"`
+	OnlyB  uint64   `json:"onlyB"`
+	ListA  []string `json:"listA,omitempty"` // points only in A, in A's order
+	ListB  []string `json:"listB,omitempty"` // points only in B, in B's order
+	Notes  []string `json:"notes,omitempty"`
+}
+
+type File struct {
+	Path       string `json:"path"`
+	Format     string `json:"format"`
+	Records    uint64 `json:"records"`
+	Duplicates uint64 `json:"duplicates,omitempty"` // repeats, counted once in the comparison
+}
+
+// Same reports whether both files hold the same set of points.
+func (r *Result) Same() bool { return r.OnlyA == 0 && r.OnlyB == 0 }
+
+func Run(cfg *Config, w io.Writer) error {
+	r, err := Diff(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(r)
+	} else {
+		err = r.Print(w)
+	}
+	if err != nil {
+		return err
+	}
+	if !r.Same() {
+		return errors.New("point sets differ")
+	}
+	return nil
+}
+
+// Diff loads A as a set of 16-byte point hashes (with the line each came
+// from), streams B against it, and then re-reads A for the lines of the
+// points B never matched. Memory is about 24 bytes per point of A.
+func Diff(cfg *Config) (*Result, error) {
+	r := &Result{A: File{Path: cfg.PathA}, B: File{Path: cfg.PathB}}
+	list := func(n uint64) bool { return !cfg.CountOnly && (cfg.MaxReport == 0 || n <= uint64(cfg.MaxReport)) }
+
+	type entry struct {
+		line    int
+		matched bool
+	}
+	setA := map[[16]byte]*entry{}
+	metaA, err := each(cfg.PathA, &r.A, func(rec ptfile.Record) {
+		k := rec.Key()
+		if _, dup := setA[k]; dup {
+			r.A.Duplicates++
+			return
+		}
+		setA[k] = &entry{line: rec.Line}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seenB := map[[16]byte]struct{}{}
+	metaB, err := each(cfg.PathB, &r.B, func(rec ptfile.Record) {
+		k := rec.Key()
+		if _, dup := seenB[k]; dup {
+			r.B.Duplicates++
+			return
+		}
+		seenB[k] = struct{}{}
+		if e, ok := setA[k]; ok {
+			e.matched = true
+			r.Common++
+			return
+		}
+		r.OnlyB++
+		if list(r.OnlyB) {
+			r.ListB = append(r.ListB, pointString(rec))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if note := curveNote(metaA, metaB); note != "" {
+		r.Notes = append(r.Notes, note)
+	}
+
+	var lines []int
+	for _, e := range setA {
+		if !e.matched {
+			r.OnlyA++
+			lines = append(lines, e.line)
+		}
+	}
+	if len(lines) == 0 || cfg.CountOnly {
+		return r, nil
+	}
+	slices.Sort(lines)
+	if cfg.MaxReport > 0 && len(lines) > cfg.MaxReport {
+		lines = lines[:cfg.MaxReport]
+	}
+	var again File
+	_, err = each(cfg.PathA, &again, func(rec ptfile.Record) {
+		if len(lines) > 0 && rec.Line == lines[0] {
+			r.ListA = append(r.ListA, pointString(rec))
+			lines = lines[1:]
+		}
+	})
+	return r, err
+}
+
+// each calls fn for every record of path and fills in f.
+func each(path string, f *File, fn func(ptfile.Record)) (meta *ptfile.Meta, err error) {
+	rd, err := ptfile.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := rd.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	f.Format = string(rd.Format())
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			return rd.Meta(), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		f.Records++
+		fn(rec)
+	}
+}
+
+// curveNote flags headers that name different curves: the diff is still
+// computed, but is unlikely to mean much.
+func curveNote(a, b *ptfile.Meta) string {
+	if a == nil || b == nil || a.P == nil || b.P == nil {
+		return ""
+	}
+	same := func(x, y *big.Int) bool { return x == nil || y == nil || x.Cmp(y) == 0 }
+	if same(a.P, b.P) && same(a.A, b.A) && same(a.B, b.B) && a.Ext == b.Ext {
+		return ""
+	}
+	return fmt.Sprintf("the headers name different curves (p=%s A=%s B=%s vs p=%s A=%s B=%s)", a.P, a.A, a.B, b.P, b.A, b.B)
+}
+
+func pointString(rec ptfile.Record) string {
+	if rec.Inf {
+		return "O"
+	}
+	s := make([]string, len(rec.Coords))
+	for i, z := range rec.Coords {
+		s[i] = z.String()
+	}
+	return strings.Join(s, " ")
+}
+
+// Print writes the points only in A as "< x y" and those only in B as
+// "> x y", diff-style, followed by the totals.
+func (r *Result) Print(w io.Writer) error {
+	ew := &errWriter{w: w}
+	for _, s := range r.ListA {
+		ew.printf("< %s\n", s)
+	}
+	for _, s := range r.ListB {
+		ew.printf("> %s\n", s)
+	}
+	if shown := uint64(len(r.ListA) + len(r.ListB)); shown > 0 && shown < r.OnlyA+r.OnlyB {
+		ew.printf("(%d of %d differing points listed)\n", shown, r.OnlyA+r.OnlyB)
+	}
+	for _, f := range []File{r.A, r.B} {
+		ew.printf("# %s: %s, %d records", f.Path, f.Format, f.Records)
+		if f.Duplicates > 0 {
+			ew.printf(", %d duplicates", f.Duplicates)
+		}
+		ew.printf("\n")
+	}
+	ew.printf("# common %d, only in A %d, only in B %d\n", r.Common, r.OnlyA, r.OnlyB)
+	for _, n := range r.Notes {
+		ew.printf("# note: %s\n", n)
+	}
+	return ew.err
+}
+
+// errWriter keeps the first write error so Print can stay linear.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = fmt.Fprintf(e.w, format, args...)
+}
@@ -0,0 +1,52 @@
+package ecdiff
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func write(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDiffAcrossFormats(t *testing.T) {
+	a := write(t, "a.txt", "# ecscan p=101 A=2 B=3 mode=table timestamp=x\n1 41\n1 60\n3 6\n18446744073709551615 18446744073709551615\n")
+	b := write(t, "b.ndjson", `{"x":3,"y":6}`+"\n"+`{"x":1,"y":41}`+"\n"+`{"x":1,"y":60}`+"\n"+`{"inf":true}`+"\n")
+	r, err := Diff(&Config{PathA: a, PathB: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Same() || r.Common != 4 {
+		t.Fatalf("reordered ndjson copy differs: %+v", r)
+	}
+}
+
+func TestDiffLists(t *testing.T) {
+	a := write(t, "a.csv", "x,y\n1,41\n2,2\n1,60\n5,5\n1,60\n")
+	b := write(t, "b.txt", "1 60\n9 9\n1 41\n")
+	r, err := Diff(&Config{PathA: a, PathB: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Common != 2 || r.OnlyA != 2 || r.OnlyB != 1 || r.A.Duplicates != 1 {
+		t.Fatalf("counts %+v", r)
+	}
+	if !slices.Equal(r.ListA, []string{"2 2", "5 5"}) || !slices.Equal(r.ListB, []string{"9 9"}) {
+		t.Fatalf("lists %q / %q", r.ListA, r.ListB)
+	}
+
+	r, err = Diff(&Config{PathA: a, PathB: b, MaxReport: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.OnlyA != 2 || !slices.Equal(r.ListA, []string{"2 2"}) {
+		t.Fatalf("--max 1: %+v", r)
+	}
+}
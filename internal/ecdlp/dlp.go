@@ -0,0 +1,343 @@
+// Package ecdlp backs cmd/ecdlp: solve Q = kP on a curve small enough to
+// count. The order of P is factored and Pohlig–Hellman reduces the problem
+// to its prime-order subgroups, where Pollard rho with distinguished points
+// runs several walkers in parallel.
+package ecdlp
+
+import (
+	crand "crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	mrand "math/rand"
+	"os"
+	"runtime"
+	"strings"
+
+	"ectorus/internal/count"
+	"ectorus/internal/ec"
+	"ectorus/internal/prime"
+)
+
+type Config struct {
+	P, A, B  *big.Int
+	Base     *ec.Point // --P; nil = a random point
+	Target   *ec.Point // --Q; nil = kP for a random k
+	N        *big.Int  // --N: known #E(F_p), skips counting
+	Count    string    // --count: auto|legendre|bsgs|mestre
+	Walkers  int       // --walkers
+	DistBits int       // --dist-bits; -1 = about a quarter of the bits of q
+	MaxSteps uint64    // --max-steps per prime subgroup; 0 = 20√q + 16 per walker per distinguished point
+	Seed     int64     // --seed: 0 => crypto/rand
+	JSON     bool      // --json
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("ecdlp", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	var (
+		pStr      = fs.String("p", "", "prime modulus p > 3 (decimal, 0x-hex or e.g. 2^61-1; required)")
+		AStr      = fs.String("A", "0", "curve parameter A")
+		BStr      = fs.String("B", "0", "curve parameter B")
+		baseStr   = fs.String("P", "", "base point x,y (default: a random point)")
+		targetStr = fs.String("Q", "", "target point x,y (default: kP for a random k, to check the answer against)")
+		NStr      = fs.String("N", "", "known point count #E(F_p) (skips counting)")
+		countWith = fs.String("count", "auto", "point counting: auto|legendre|bsgs|mestre")
+		walkers   = fs.Int("walkers", runtime.NumCPU(), "parallel rho walkers")
+		distBits  = fs.Int("dist-bits", -1, "a point is distinguished when this many hash bits are zero (-1 = a quarter of the bits of each prime order)")
+		maxSteps  = fs.Uint64("max-steps", 0, "give up on a prime subgroup after this many steps in all (0 = 20√q plus slack for the distinguished points)")
+		seed      = fs.Int64("seed", 0, "seed for the points, k and the walks (0 = crypto/rand)")
+		jsonOut   = fs.Bool("json", false, "emit JSON instead of text")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(*pStr) == "" {
+		return nil, errors.New("missing required --p")
+	}
+	cfg := &Config{
+		Count: strings.ToLower(strings.TrimSpace(*countWith)), Walkers: *walkers, DistBits: *distBits,
+		MaxSteps: *maxSteps, Seed: *seed, JSON: *jsonOut,
+	}
+	var err error
+	if cfg.P, err = parseInt(*pStr, "p"); err != nil {
+		return nil, err
+	}
+	if cfg.P.Cmp(big.NewInt(3)) <= 0 || !cfg.P.ProbablyPrime(32) {
+		return nil, errors.New("--p must be a prime > 3")
+	}
+	if cfg.A, err = parseInt(*AStr, "A"); err != nil {
+		return nil, err
+	}
+	if cfg.B, err = parseInt(*BStr, "B"); err != nil {
+		return nil, err
+	}
+	if *NStr != "" {
+		if cfg.N, err = parseInt(*NStr, "N"); err != nil {
+			return nil, err
+		}
+	}
+	if *baseStr != "" {
+		if cfg.Base, err = parsePoint(*baseStr, "P"); err != nil {
+			return nil, err
+		}
+	}
+	if *targetStr != "" {
+		if cfg.Target, err = parsePoint(*targetStr, "Q"); err != nil {
+			return nil, err
+		}
+	}
+	switch cfg.Count {
+	case "auto", "legendre", "bsgs", "mestre":
+	default:
+		return nil, fmt.Errorf("bad --count %q (want auto|legendre|bsgs|mestre)", *countWith)
+	}
+	if cfg.Walkers <= 0 {
+		return nil, errors.New("--walkers must be positive")
+	}
+	if cfg.DistBits < -1 || cfg.DistBits > 32 {
+		return nil, errors.New("--dist-bits must be in -1..32")
+	}
+	return cfg, nil
+}
+
+// Result is a solved Q = kP. K is the least such k ≥ 0, unique mod Order.
+type Result struct {
+	P         string     `json:"p"`
+	A         string     `json:"A"`
+	B         string     `json:"B"`
+	N         string     `json:"pointCount"`
+	Base      [2]string  `json:"P"`
+	Target    [2]string  `json:"Q"`
+	Order     string     `json:"order"` // of P
+	K         string     `json:"k"`
+	Chosen    string     `json:"chosenK,omitempty"` // the k that made Q when --Q was not given
+	Subgroups []Subgroup `json:"subgroups"`
+	Steps     uint64     `json:"steps"`
+	Walkers   int        `json:"walkers"`
+}
+
+// Subgroup is the Pohlig–Hellman piece for one prime power q^e of the order.
+type Subgroup struct {
+	Q             string `json:"q"`
+	E             int    `json:"e"`
+	K             string `json:"k"`      // k mod q^e
+	Method        string `json:"method"` // "scan" for small q, else "rho"
+	Steps         uint64 `json:"steps"`
+	Distinguished int    `json:"distinguished"`
+}
+
+func Run(cfg *Config, w io.Writer) error {
+	r, err := Solve(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(r)
+	}
+	ew := &errWriter{w: w}
+	ew.printf("curve       y^2 = x^3 + %s x + %s over F_%s, #E = %s\n", r.A, r.B, r.P, r.N)
+	ew.printf("P           (%s, %s) of order %s\n", r.Base[0], r.Base[1], r.Order)
+	ew.printf("Q           (%s, %s)\n", r.Target[0], r.Target[1])
+	for _, s := range r.Subgroups {
+		ew.printf("  mod %s^%d  k ≡ %s by %s, %d steps", s.Q, s.E, s.K, s.Method, s.Steps)
+		if s.Method == "rho" {
+			ew.printf(", %d distinguished points", s.Distinguished)
+		}
+		ew.printf("\n")
+	}
+	ew.printf("k           %s (%d steps, %d walkers)\n", r.K, r.Steps, r.Walkers)
+	if r.Chosen != "" {
+		ew.printf("chosen k    %s\n", r.Chosen)
+	}
+	return ew.err
+}
+
+// Solve finds k with Q = kP, or fails when Q is not a multiple of P.
+func Solve(cfg *Config) (*Result, error) {
+	p := cfg.P
+	c := ec.Curve{P: p, A: ec.Mod(cfg.A, p), B: ec.Mod(cfg.B, p)}
+	if c.IsSingular() {
+		return nil, errors.New("curve is singular (4A^3 + 27B^2 ≡ 0 mod p)")
+	}
+	var rnd io.Reader = crand.Reader
+	if cfg.Seed != 0 {
+		rnd = mrand.New(mrand.NewSource(cfg.Seed))
+	}
+	N := cfg.N
+	if N == nil {
+		var err error
+		if N, _, err = count.Auto(c, cfg.Count, rnd); err != nil {
+			return nil, fmt.Errorf("count: %w", err)
+		}
+	}
+	P, err := pickPoint(c, cfg.Base, rnd, "P")
+	if err != nil {
+		return nil, err
+	}
+	n, fs, err := pointOrder(c, P, N)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{P: p.String(), A: c.A.String(), B: c.B.String(), N: N.String(), Base: pair(P), Order: n.String(), Walkers: cfg.Walkers}
+	Q := ec.Point{}
+	if cfg.Target != nil {
+		if Q, err = pickPoint(c, cfg.Target, rnd, "Q"); err != nil {
+			return nil, err
+		}
+	} else {
+		k, err := crand.Int(rnd, n)
+		if err != nil {
+			return nil, err
+		}
+		if Q, err = c.ScalarMul(k, P); err != nil {
+			return nil, err
+		}
+		res.Chosen = k.String()
+	}
+	res.Target = pair(Q)
+
+	// Pohlig–Hellman: k mod q^e one base-q digit at a time, then the CRT
+	k, mod := new(big.Int), big.NewInt(1)
+	for i := 0; i < len(fs); {
+		q := fs[i]
+		e := 0
+		for ; i < len(fs) && fs[i].Cmp(q) == 0; i++ {
+			e++
+		}
+		qe := new(big.Int).Exp(q, big.NewInt(int64(e)), nil)
+		P0, err := c.ScalarMul(new(big.Int).Quo(n, q), P)
+		if err != nil {
+			return nil, err
+		}
+		sub := Subgroup{Q: q.String(), E: e}
+		x, qi := new(big.Int), big.NewInt(1)
+		for j := 0; j < e; j++ {
+			// Q_j = (n / q^(j+1))·(Q - xP) lies in <P0>
+			R, err := c.ScalarMul(x, P)
+			if err != nil {
+				return nil, err
+			}
+			if R, err = c.Add(Q, c.Neg(R)); err != nil {
+				return nil, err
+			}
+			cof := new(big.Int).Quo(n, new(big.Int).Mul(qi, q))
+			if R, err = c.ScalarMul(cof, R); err != nil {
+				return nil, err
+			}
+			d, st, err := dlogPrime(c, P0, R, q, cfg, rnd)
+			if err != nil {
+				return nil, fmt.Errorf("subgroup of order %s: %w", q, err)
+			}
+			sub.Method, sub.Steps, sub.Distinguished = st.method, sub.Steps+st.steps, sub.Distinguished+st.distinguished
+			x.Add(x, new(big.Int).Mul(d, qi))
+			qi.Mul(qi, q)
+		}
+		sub.K = x.String()
+		res.Subgroups = append(res.Subgroups, sub)
+		res.Steps += sub.Steps
+		// k ≡ x mod q^e
+		t := new(big.Int).Sub(x, k)
+		t.Mul(t, new(big.Int).ModInverse(mod, qe)).Mod(t, qe)
+		k.Add(k, t.Mul(t, mod))
+		mod.Mul(mod, qe)
+	}
+	kP, err := c.ScalarMul(k, P)
+	if err != nil {
+		return nil, err
+	}
+	if !kP.Equal(Q) {
+		return nil, errors.New("Q is not a multiple of P")
+	}
+	res.K = k.String()
+	return res, nil
+}
+
+// pointOrder returns the order of P, given the group order N, with its
+// prime factors (with multiplicity, ascending).
+func pointOrder(c ec.Curve, P ec.Point, N *big.Int) (*big.Int, []*big.Int, error) {
+	fs, rest := prime.Factor(N)
+	if rest.Cmp(big.NewInt(1)) != 0 {
+		return nil, nil, fmt.Errorf("cannot factor #E = %s (left with %s)", N, rest)
+	}
+	if R, err := c.ScalarMul(N, P); err != nil || !R.Inf {
+		return nil, nil, fmt.Errorf("N·P ≠ O: %s is not the order of the group", N)
+	}
+	n := new(big.Int).Set(N)
+	var keep []*big.Int
+	for _, q := range fs {
+		m := new(big.Int).Quo(n, q)
+		R, err := c.ScalarMul(m, P)
+		if err != nil {
+			return nil, nil, err
+		}
+		if R.Inf {
+			n = m
+		} else {
+			keep = append(keep, q)
+		}
+	}
+	return n, keep, nil
+}
+
+func pickPoint(c ec.Curve, pt *ec.Point, rnd io.Reader, name string) (ec.Point, error) {
+	if pt == nil {
+		return c.RandomPoint(rnd)
+	}
+	P := ec.Point{X: ec.Mod(pt.X, c.P), Y: ec.Mod(pt.Y, c.P)}
+	if !c.On(P) {
+		return ec.Point{}, fmt.Errorf("%s = (%s, %s) is not on the curve", name, pt.X, pt.Y)
+	}
+	return P, nil
+}
+
+func pair(P ec.Point) [2]string {
+	if P.Inf {
+		return [2]string{"inf", "inf"}
+	}
+	return [2]string{P.X.String(), P.Y.String()}
+}
+
+// parsePoint reads "x,y", each coordinate as ec.ParseBig does.
+func parsePoint(s, name string) (*ec.Point, error) {
+	xs, ys, ok := strings.Cut(s, ",")
+	if !ok {
+		return nil, fmt.Errorf("bad --%s %q (want x,y)", name, s)
+	}
+	x, err := parseInt(xs, name)
+	if err != nil {
+		return nil, err
+	}
+	y, err := parseInt(ys, name)
+	if err != nil {
+		return nil, err
+	}
+	return &ec.Point{X: x, Y: y}, nil
+}
+
+// parseInt reads decimal, 0x-hex or an expression like 2^61-1 (ec.ParseBig).
+func parseInt(s, name string) (*big.Int, error) {
+	z, err := ec.ParseBig(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %w", name, err)
+	}
+	return z, nil
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
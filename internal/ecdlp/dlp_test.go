@@ -0,0 +1,60 @@
+package ecdlp
+
+import (
+	"math/big"
+	mrand "math/rand"
+	"testing"
+
+	"ectorus/internal/ec"
+)
+
+func TestSolveRecoversK(t *testing.T) {
+	p := big.NewInt(1000003)
+	for _, tc := range []struct {
+		name string
+		A, B int64
+		base *ec.Point
+		rho  bool // the largest subgroup is beyond the point-by-point scan
+	}{
+		{"prime order", 391254, 57818, &ec.Point{X: big.NewInt(666644), Y: big.NewInt(334094)}, true},
+		{"random point", 129616, 148299, nil, true},
+		{"smooth order", 2, 3, nil, false},
+	} {
+		for seed := int64(1); seed <= 3; seed++ {
+			cfg := &Config{P: p, A: big.NewInt(tc.A), B: big.NewInt(tc.B), Base: tc.base, Count: "auto",
+				Walkers: 3, DistBits: -1, Seed: seed}
+			r, err := Solve(cfg)
+			if err != nil {
+				t.Fatalf("%s, seed %d: %v", tc.name, seed, err)
+			}
+			if r.K != r.Chosen {
+				t.Fatalf("%s, seed %d: k = %s, Q was made with %s", tc.name, seed, r.K, r.Chosen)
+			}
+			last := r.Subgroups[len(r.Subgroups)-1]
+			if (last.Method == "rho") != tc.rho {
+				t.Fatalf("%s: subgroup %s solved by %s", tc.name, last.Q, last.Method)
+			}
+		}
+	}
+}
+
+func TestSolveRejectsNonMultiple(t *testing.T) {
+	// #E = 4 × 250433 and P has order 250433: most points are not multiples of P
+	c := ec.Curve{P: big.NewInt(1000003), A: big.NewInt(129616), B: big.NewInt(148299)}
+	rnd := mrand.New(mrand.NewSource(1))
+	var Q ec.Point
+	for {
+		var err error
+		if Q, err = c.RandomPoint(rnd); err != nil {
+			t.Fatal(err)
+		}
+		if R, _ := c.ScalarMul(big.NewInt(250433), Q); !R.Inf {
+			break
+		}
+	}
+	cfg := &Config{P: c.P, A: c.A, B: c.B, Base: &ec.Point{X: big.NewInt(780074), Y: big.NewInt(454891)},
+		Target: &Q, N: big.NewInt(1001732), Walkers: 2, DistBits: -1, Seed: 1}
+	if r, err := Solve(cfg); err == nil {
+		t.Fatalf("solved Q = %sP for a Q outside <P>", r.K)
+	}
+}
@@ -0,0 +1,238 @@
+package ecdlp
+
+import (
+	crand "crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+	mrand "math/rand"
+	"sync"
+	"sync/atomic"
+
+	"ectorus/internal/ec"
+)
+
+// scanBelow: prime subgroups smaller than this are searched point by point.
+const scanBelow = 1 << 12
+
+// partitions is the number of r-adding walk multipliers.
+const partitions = 32
+
+type dlogStats struct {
+	method        string
+	steps         uint64
+	distinguished int
+}
+
+// track is where a walk stood when it hit a distinguished point: R = aP + bQ.
+type track struct{ a, b, y *big.Int }
+
+// dlogPrime returns d in [0, q) with Q = dP, P of prime order q.
+//
+// Above scanBelow it runs Teske's r-adding walk: R ← R + M_j with
+// M_j = c_j P + d_j Q and j a hash of x(R). Each walker starts at a random
+// aP + bQ and reports only its distinguished points (hash bits all zero)
+// to a shared table, so walkers that merge are noticed one trail later.
+// Two tracks a1 P + b1 Q = ±(a2 P + b2 Q) give k = ∓(a1 ∓ a2)/(b1 ∓ b2).
+func dlogPrime(c ec.Curve, P, Q ec.Point, q *big.Int, cfg *Config, rnd io.Reader) (*big.Int, dlogStats, error) {
+	if Q.Inf {
+		return new(big.Int), dlogStats{method: "scan"}, nil
+	}
+	if q.Cmp(big.NewInt(scanBelow)) < 0 {
+		R := P
+		for d := int64(1); d < q.Int64(); d++ {
+			if R.Equal(Q) {
+				return big.NewInt(d), dlogStats{method: "scan", steps: uint64(d)}, nil
+			}
+			var err error
+			if R, err = c.Add(R, P); err != nil {
+				return nil, dlogStats{}, err
+			}
+		}
+		return nil, dlogStats{}, errors.New("Q is not a multiple of P")
+	}
+
+	bits := cfg.DistBits
+	if bits < 0 {
+		bits = q.BitLen() / 4
+	}
+	mask := uint64(1)<<bits - 1
+	limit := cfg.MaxSteps
+	if limit == 0 {
+		s := new(big.Int).Sqrt(q)
+		limit = 20*s.Uint64() + uint64(cfg.Walkers)<<(bits+4)
+	}
+
+	w := &walker{c: c, P: P, Q: Q, q: q, mask: mask, limit: limit}
+	for j := range w.m {
+		var err error
+		if w.mc[j], err = crand.Int(rnd, q); err != nil {
+			return nil, dlogStats{}, err
+		}
+		if w.md[j], err = crand.Int(rnd, q); err != nil {
+			return nil, dlogStats{}, err
+		}
+		if w.m[j], err = combine(c, P, Q, w.mc[j], w.md[j]); err != nil {
+			return nil, dlogStats{}, err
+		}
+	}
+	seeds := make([]int64, cfg.Walkers)
+	for i := range seeds {
+		s, err := crand.Int(rnd, big.NewInt(1<<62))
+		if err != nil {
+			return nil, dlogStats{}, err
+		}
+		seeds[i] = s.Int64()
+	}
+
+	var (
+		mu     sync.Mutex
+		seen   = make(map[string]track)
+		answer *big.Int
+		failed error
+		wg     sync.WaitGroup
+	)
+	// collide checks a distinguished point against the table; true once k is known
+	w.collide = func(R ec.Point, a, b *big.Int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		key := R.X.String()
+		t, ok := seen[key]
+		if !ok {
+			seen[key] = track{a, b, R.Y}
+			return false
+		}
+		num, den := new(big.Int), new(big.Int)
+		if t.y.Cmp(R.Y) == 0 {
+			// aP + bQ = a'P + b'Q: k = (a - a')/(b' - b)
+			num.Sub(a, t.a)
+			den.Sub(t.b, b)
+		} else {
+			// aP + bQ = -(a'P + b'Q): k = -(a + a')/(b + b')
+			num.Neg(num.Add(a, t.a))
+			den.Add(b, t.b)
+		}
+		inv := new(big.Int).ModInverse(den.Mod(den, q), q)
+		if inv == nil {
+			return false // the walks met without learning anything; the walker starts over
+		}
+		k := num.Mul(num, inv).Mod(num, q)
+		if kP, err := c.ScalarMul(k, P); err != nil || !kP.Equal(Q) {
+			return false
+		}
+		answer = k
+		return true
+	}
+	walk := func(seed int64) {
+		defer wg.Done()
+		wr := mrand.New(mrand.NewSource(seed))
+		for !w.done.Load() {
+			a, err := crand.Int(wr, q)
+			if err == nil {
+				var b *big.Int
+				if b, err = crand.Int(wr, q); err == nil {
+					err = w.trail(a, b)
+				}
+			}
+			if err != nil {
+				mu.Lock()
+				if failed == nil {
+					failed = err
+				}
+				mu.Unlock()
+				w.done.Store(true)
+			}
+		}
+	}
+	wg.Add(cfg.Walkers)
+	for _, s := range seeds {
+		go walk(s)
+	}
+	wg.Wait()
+	st := dlogStats{method: "rho", steps: w.steps.Load(), distinguished: len(seen)}
+	switch {
+	case answer != nil:
+		return answer, st, nil
+	case failed != nil:
+		return nil, st, failed
+	}
+	return nil, st, errors.New("rho found no collision within --max-steps (is Q a multiple of P?)")
+}
+
+// walker is the state the rho walkers share: the r-adding multipliers
+// M_j = c_j P + d_j Q, the step budget and the distinguished-point table
+// behind collide.
+type walker struct {
+	c           ec.Curve
+	P, Q        ec.Point
+	q           *big.Int
+	m           [partitions]ec.Point
+	mc, md      [partitions]*big.Int
+	mask, limit uint64
+	collide     func(R ec.Point, a, b *big.Int) bool // true once k is known
+	steps       atomic.Uint64
+	done        atomic.Bool
+}
+
+// trail walks from aP + bQ until a distinguished point, which it hands to
+// collide, or until the walk as a whole is over. It returns when the
+// walker should pick a fresh start.
+func (w *walker) trail(a, b *big.Int) error {
+	R, err := combine(w.c, w.P, w.Q, a, b)
+	if err != nil {
+		return err
+	}
+	// a trail 32× the expected length is caught in a cycle
+	for n := uint64(0); n <= 32*(w.mask+1) && !w.done.Load(); n++ {
+		if R.Inf {
+			return nil
+		}
+		x := mix(R.X)
+		if x>>32&w.mask == 0 {
+			if w.collide(R, a, b) {
+				w.done.Store(true)
+			}
+			return nil
+		}
+		j := x % partitions
+		if R, err = w.c.Add(R, w.m[j]); err != nil {
+			return err
+		}
+		a = new(big.Int).Add(a, w.mc[j])
+		a.Mod(a, w.q)
+		b = new(big.Int).Add(b, w.md[j])
+		b.Mod(b, w.q)
+		if w.steps.Add(1) >= w.limit {
+			w.done.Store(true)
+		}
+	}
+	return nil
+}
+
+// mix hashes x for the walk: its 64-bit words folded through the
+// SplitMix64 finalizer, so the high bits depend on every bit of x.
+func mix(x *big.Int) uint64 {
+	var h uint64
+	for _, w := range x.Bits() {
+		h ^= uint64(w)
+		h ^= h >> 30
+		h *= 0xbf58476d1ce4e5b9
+		h ^= h >> 27
+		h *= 0x94d049bb133111eb
+		h ^= h >> 31
+	}
+	return h
+}
+
+// combine returns aP + bQ.
+func combine(c ec.Curve, P, Q ec.Point, a, b *big.Int) (ec.Point, error) {
+	aP, err := c.ScalarMul(a, P)
+	if err != nil {
+		return ec.Point{}, err
+	}
+	bQ, err := c.ScalarMul(b, Q)
+	if err != nil {
+		return ec.Point{}, err
+	}
+	return c.Add(aP, bQ)
+}
@@ -0,0 +1,374 @@
+// Package ecfactor backs cmd/ecfactor: Lenstra's elliptic curve method.
+// A random curve y^2 = x^3 + Ax + B over Z/nZ runs the ordinary group law
+// of internal/ec as if n were prime; when some prime q | n has #E(F_q)
+// smooth enough, a chord's denominator vanishes mod q but not mod n and the
+// failed inversion (ec.NotInvertibleError) hands over the factor.
+package ecfactor
+
+import (
+	crand "crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	mrand "math/rand"
+	"os"
+	"sort"
+	"strings"
+
+	"ectorus/internal/ec"
+	"ectorus/internal/prime"
+)
+
+// trialBound: primes below it are divided out before any curve is tried.
+const trialBound = 1000
+
+// giantStep is D in stage 2: primes q = mD ± j with gcd(j, D) = 1.
+const giantStep = 2310
+
+type Config struct {
+	N      *big.Int
+	B1     uint64 // --B1: stage 1 multiplies by every prime power ≤ B1
+	B2     uint64 // --B2: stage 2 covers one more prime in (B1, B2]; ≤ B1 skips it
+	Curves int    // --curves: curves per composite before giving up on it
+	Seed   int64  // --seed: 0 => crypto/rand
+	Format string // --format: text|json
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("ecfactor", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	var (
+		nStr   = fs.String("n", "", "number to factor (decimal, 0x-hex or e.g. 2^128+1; required)")
+		b1     = fs.Uint64("B1", 11_000, "stage 1 bound: multiply by every prime power up to B1")
+		b2     = fs.Uint64("B2", 0, "stage 2 bound: also catch one prime in (B1, B2] (0 = 100×B1; ≤ B1 turns stage 2 off)")
+		curves = fs.Int("curves", 100, "random curves to try per composite before giving up")
+		seed   = fs.Int64("seed", 0, "seed for the curves (0 = crypto/rand)")
+		format = fs.String("format", "text", "output: text|json")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(*nStr) == "" {
+		return nil, errors.New("missing required --n")
+	}
+	n, err := ec.ParseBig(*nStr)
+	if err != nil {
+		return nil, fmt.Errorf("--n: %w", err)
+	}
+	if n.Cmp(big.NewInt(1)) <= 0 {
+		return nil, errors.New("--n must be > 1")
+	}
+	cfg := &Config{N: n, B1: *b1, B2: *b2, Curves: *curves, Seed: *seed, Format: strings.ToLower(strings.TrimSpace(*format))}
+	if *b2 == 0 {
+		cfg.B2 = 100 * cfg.B1
+	}
+	switch {
+	case cfg.B1 < 2:
+		return nil, errors.New("--B1 must be at least 2")
+	case cfg.Curves <= 0:
+		return nil, errors.New("--curves must be positive")
+	}
+	switch cfg.Format {
+	case "text", "json":
+	default:
+		return nil, fmt.Errorf("bad --format %q (want text|json)", *format)
+	}
+	return cfg, nil
+}
+
+// Result is the factorization found: Factors are primes (BPSW above 2^64),
+// ascending with multiplicity; Unsplit are composites no curve split.
+type Result struct {
+	N       string   `json:"n"`
+	Factors []string `json:"factors"`
+	Unsplit []string `json:"unsplit,omitempty"`
+	Splits  []Split  `json:"splits,omitempty"`
+}
+
+// Split records one factor found by a curve.
+type Split struct {
+	N      string `json:"n"`
+	Factor string `json:"factor"`
+	Curve  int    `json:"curve"` // 1-based, counted per composite
+	Stage  int    `json:"stage"` // 0: the discriminant already shared a factor with n
+}
+
+func Run(cfg *Config, w io.Writer) error {
+	res, err := Factor(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Format == "json" {
+		b, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+	for _, s := range res.Splits {
+		n, _ := new(big.Int).SetString(s.N, 10)
+		d, _ := new(big.Int).SetString(s.Factor, 10)
+		if _, err := fmt.Fprintf(w, "curve %d, stage %d: %s = %s × %s\n", s.Curve, s.Stage, n, d, new(big.Int).Quo(n, d)); err != nil {
+			return err
+		}
+	}
+	parts := append([]string(nil), res.Factors...)
+	for _, u := range res.Unsplit {
+		parts = append(parts, "["+u+"]")
+	}
+	_, err = fmt.Fprintf(w, "%s = %s\n", res.N, strings.Join(parts, " × "))
+	return err
+}
+
+// Factor splits cfg.N completely, or as far as cfg.Curves curves per
+// composite allow: trial division below 1000 first, then ECM on whatever
+// BPSW does not call prime.
+func Factor(cfg *Config) (*Result, error) {
+	var rnd io.Reader = crand.Reader
+	if cfg.Seed != 0 {
+		rnd = mrand.New(mrand.NewSource(cfg.Seed))
+	}
+	res := &Result{N: cfg.N.String()}
+	var fs, unsplit []*big.Int
+	m := new(big.Int).Set(cfg.N)
+	q, r := new(big.Int), new(big.Int)
+	for _, d := range primesTo(trialBound) {
+		bd := new(big.Int).SetUint64(d)
+		for q.QuoRem(m, bd, r); r.Sign() == 0; q.QuoRem(m, bd, r) {
+			fs = append(fs, bd)
+			m.Set(q)
+		}
+	}
+	k := stage1Multiplier(cfg.B1)
+	pending := []*big.Int{m}
+	for len(pending) > 0 {
+		x := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		switch {
+		case x.Cmp(big.NewInt(1)) == 0:
+			continue
+		case prime.BPSW(x):
+			fs = append(fs, x)
+			continue
+		}
+		d, split, err := ecm(x, k, cfg, rnd)
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			unsplit = append(unsplit, x)
+			continue
+		}
+		split.N = x.String()
+		res.Splits = append(res.Splits, split)
+		pending = append(pending, d, new(big.Int).Quo(x, d))
+	}
+	sort.Slice(fs, func(i, j int) bool { return fs[i].Cmp(fs[j]) < 0 })
+	for _, f := range fs {
+		res.Factors = append(res.Factors, f.String())
+	}
+	for _, u := range unsplit {
+		res.Unsplit = append(res.Unsplit, u.String())
+	}
+	return res, nil
+}
+
+// ecm tries up to cfg.Curves random curves on the odd composite n, returning
+// the first proper factor found (nil if none) and where it came from.
+func ecm(n, k *big.Int, cfg *Config, rnd io.Reader) (*big.Int, Split, error) {
+	for i := 1; i <= cfg.Curves; i++ {
+		// pick the point first and solve for B, so no square root mod n is needed
+		x, err := crand.Int(rnd, n)
+		if err != nil {
+			return nil, Split{}, err
+		}
+		y, err := crand.Int(rnd, n)
+		if err != nil {
+			return nil, Split{}, err
+		}
+		A, err := crand.Int(rnd, n)
+		if err != nil {
+			return nil, Split{}, err
+		}
+		B := ec.SubM(ec.SubM(ec.MulM(y, y, n), ec.MulM(x, ec.MulM(x, x, n), n), n), ec.MulM(A, x, n), n)
+		c := ec.Curve{P: n, A: A, B: B}
+		// a discriminant sharing a factor with n is a factor already
+		if g := new(big.Int).GCD(nil, nil, c.Discriminant(), n); g.Cmp(big.NewInt(1)) != 0 {
+			if g.Cmp(n) != 0 {
+				return g, Split{Factor: g.String(), Curve: i}, nil
+			}
+			continue
+		}
+		Q, err := c.ScalarMul(k, ec.Point{X: x, Y: y})
+		if d := properFactor(err, n); d != nil {
+			return d, Split{Factor: d.String(), Curve: i, Stage: 1}, nil
+		}
+		if err != nil || Q.Inf || cfg.B2 <= cfg.B1 {
+			continue // Q is O mod every prime of n at once: this curve is spent
+		}
+		if d := properFactor(stage2(c, Q, cfg.B1, cfg.B2), n); d != nil {
+			return d, Split{Factor: d.String(), Curve: i, Stage: 2}, nil
+		}
+	}
+	return nil, Split{}, nil
+}
+
+// properFactor returns the proper factor of n a failed inversion revealed,
+// or nil.
+func properFactor(err error, n *big.Int) *big.Int {
+	var ni *ec.NotInvertibleError
+	if !errors.As(err, &ni) {
+		return nil
+	}
+	if g := ni.Factor(); g.Cmp(big.NewInt(1)) > 0 && g.Cmp(n) < 0 {
+		return g
+	}
+	return nil
+}
+
+// stage1Multiplier returns Π q^⌊log_q B1⌋ over the primes q ≤ B1: Q = kP
+// is O mod every prime of n whose #E is B1-powersmooth.
+func stage1Multiplier(b1 uint64) *big.Int {
+	k := big.NewInt(1)
+	for _, q := range primesTo(b1) {
+		qe := q
+		for qe <= b1/q {
+			qe *= q
+		}
+		k.Mul(k, new(big.Int).SetUint64(qe))
+	}
+	return k
+}
+
+// stage2 is the standard continuation: it looks for one prime q in
+// (b1, b2] with qQ = O mod some p | n. Writing q = mD ± j, that happens
+// exactly when mD·Q = ∓jQ mod p, i.e. when x(mD·Q) - x(jQ) ≡ 0; the
+// differences are multiplied up and one gcd per block of giant steps
+// tests them all. A nonzero gcd comes back as the NotInvertibleError that
+// inverting the product would have raised, like a failed step of its own.
+func stage2(c ec.Curve, Q ec.Point, b1, b2 uint64) error {
+	n := c.P
+	// baby steps: jQ for odd j < D/2, kept where gcd(j, D) = 1
+	var js []uint64
+	var xs []*big.Int
+	Q2, err := c.Double(Q)
+	if err != nil {
+		return err
+	}
+	jQ := Q
+	for j := uint64(1); j < giantStep/2; j += 2 {
+		if j > 1 {
+			if jQ, err = c.Add(jQ, Q2); err != nil {
+				return err
+			}
+		}
+		if jQ.Inf {
+			return nil
+		}
+		if gcdU(j, giantStep) == 1 {
+			js, xs = append(js, j), append(xs, jQ.X)
+		}
+	}
+	G, err := c.ScalarMul(new(big.Int).SetUint64(giantStep), Q)
+	if err != nil || G.Inf {
+		return err
+	}
+	m := (b1 + giantStep/2) / giantStep
+	R, err := c.ScalarMul(new(big.Int).SetUint64(m), G)
+	if err != nil {
+		return err
+	}
+	base := primesTo(isqrt(b2 + giantStep))
+	acc := big.NewInt(1)
+	for ; m*giantStep <= b2+giantStep/2; m++ {
+		lo := m*giantStep - giantStep/2
+		if m*giantStep < giantStep/2 {
+			lo = 0
+		}
+		isPrime := sieveWindow(lo, m*giantStep+giantStep/2, base)
+		if !R.Inf {
+			for i, j := range js {
+				lo2, hi2 := m*giantStep-j, m*giantStep+j
+				if m*giantStep >= j && lo2 > b1 && lo2 <= b2 && isPrime[lo2-lo] ||
+					hi2 > b1 && hi2 <= b2 && isPrime[hi2-lo] {
+					acc = ec.MulM(acc, ec.SubM(R.X, xs[i], n), n)
+				}
+			}
+		}
+		if m%64 == 0 {
+			if err := gcdCheck(acc, n); err != nil {
+				return err
+			}
+		}
+		if R, err = c.Add(R, G); err != nil {
+			return err
+		}
+	}
+	return gcdCheck(acc, n)
+}
+
+// gcdCheck reports gcd(acc, n) ≠ 1 as the NotInvertibleError inverting acc
+// would have raised.
+func gcdCheck(acc, n *big.Int) error {
+	if new(big.Int).GCD(nil, nil, acc, n).Cmp(big.NewInt(1)) != 0 {
+		return &ec.NotInvertibleError{A: new(big.Int).Set(acc), P: n}
+	}
+	return nil
+}
+
+// primesTo returns the primes ≤ n by the sieve of Eratosthenes.
+func primesTo(n uint64) []uint64 {
+	if n < 2 {
+		return nil
+	}
+	composite := make([]bool, n+1)
+	var ps []uint64
+	for i := uint64(2); i <= n; i++ {
+		if composite[i] {
+			continue
+		}
+		ps = append(ps, i)
+		for j := i * i; j <= n; j += i {
+			composite[j] = true
+		}
+	}
+	return ps
+}
+
+// sieveWindow marks the primes in [lo, hi]: isPrime[i] for lo+i. base must
+// hold every prime up to √hi.
+func sieveWindow(lo, hi uint64, base []uint64) []bool {
+	isPrime := make([]bool, hi-lo+1)
+	for i := range isPrime {
+		isPrime[i] = lo+uint64(i) >= 2
+	}
+	for _, q := range base {
+		if q*q > hi {
+			break
+		}
+		start := (lo + q - 1) / q * q
+		if start < q*q {
+			start = q * q
+		}
+		for v := start; v <= hi; v += q {
+			isPrime[v-lo] = false
+		}
+	}
+	return isPrime
+}
+
+func isqrt(n uint64) uint64 {
+	return new(big.Int).Sqrt(new(big.Int).SetUint64(n)).Uint64()
+}
+
+func gcdU(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,74 @@
+package ecfactor
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestFactorSplitsCompletely(t *testing.T) {
+	for _, tc := range []struct {
+		n    string
+		want string
+		b2   uint64
+	}{
+		{"1000000016000000063", "1000000007 1000000009", 0},
+		{"18446744073709551617", "274177 67280421310721", 0},   // 2^64 + 1
+		{"147573952589676412927", "193707721 761838257287", 0}, // 2^67 - 1
+		{"8589934591", "7 23 89 599479", 1},                    // 2^33 - 1, stage 1 only
+		{"1000003", "1000003", 0},
+	} {
+		n, _ := new(big.Int).SetString(tc.n, 10)
+		cfg := &Config{N: n, B1: 2000, B2: tc.b2, Curves: 200, Seed: 5}
+		if cfg.B2 == 0 {
+			cfg.B2 = 100 * cfg.B1
+		}
+		res, err := Factor(cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.n, err)
+		}
+		if got := strings.Join(res.Factors, " "); got != tc.want || len(res.Unsplit) != 0 {
+			t.Fatalf("%s: factors %q, unsplit %v, want %q", tc.n, got, res.Unsplit, tc.want)
+		}
+		for _, s := range res.Splits {
+			if s.Stage == 2 && tc.b2 == 1 {
+				t.Fatalf("%s: stage 2 ran with B2 < B1", tc.n)
+			}
+		}
+	}
+}
+
+func TestFactorGivesUp(t *testing.T) {
+	// two 40-bit primes are beyond a single small curve
+	n, _ := new(big.Int).SetString("1208925819660808663073173", 10)
+	res, err := Factor(&Config{N: n, B1: 10, B2: 1, Curves: 1, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Unsplit) != 1 || res.Unsplit[0] != n.String() {
+		t.Fatalf("got %+v, want n left unsplit", res)
+	}
+	var buf bytes.Buffer
+	if err := Run(&Config{N: n, B1: 10, B2: 1, Curves: 1, Seed: 1, Format: "text"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := n.String() + " = [" + n.String() + "]\n"; buf.String() != want {
+		t.Fatalf("text output %q, want %q", buf.String(), want)
+	}
+}
+
+func TestSieveWindow(t *testing.T) {
+	ps := primesTo(3000)
+	isPrime := make(map[uint64]bool)
+	for _, p := range ps {
+		isPrime[p] = true
+	}
+	lo, hi := uint64(1000), uint64(3000)
+	w := sieveWindow(lo, hi, primesTo(isqrt(hi)))
+	for v := lo; v <= hi; v++ {
+		if w[v-lo] != isPrime[v] {
+			t.Fatalf("sieveWindow says %d prime = %v", v, w[v-lo])
+		}
+	}
+}
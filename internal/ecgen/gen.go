@@ -0,0 +1,224 @@
+// Package ecgen backs cmd/ecgen: draw random nonsingular curves over a fixed
+// p, keep those meeting the order and trace constraints, and emit them as
+// JSON that ectorus -manifest reads directly (or as ecscan flag lines).
+package ecgen
+
+import (
+	crand "crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	mrand "math/rand"
+	"os"
+	"strings"
+
+	"ectorus/internal/count"
+	"ectorus/internal/ec"
+)
+
+type Config struct {
+	P        *big.Int
+	N        int      // --n: curves to emit
+	Cofactor *big.Int // --cofactor, --prime-order: #E = cofactor·r, r prime; nil = any order
+	TraceLo  *big.Int // --trace lo:hi, inclusive; nil = any trace
+	TraceHi  *big.Int
+	Count    string // --count: auto|legendre|bsgs|mestre
+	MaxTries int    // --max-tries, over all curves
+	Seed     int64  // --seed: 0 => crypto/rand
+	Format   string // --format: json|ndjson|args
+
+	AllowAnomalous bool // --allow-anomalous: accept #E = p under --cofactor
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("ecgen", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	var (
+		pStr       = fs.String("p", "", "prime modulus p > 3 (decimal, 0x-hex or e.g. 2^61-1; required)")
+		n          = fs.Int("n", 1, "number of curves to emit")
+		primeOrder = fs.Bool("prime-order", false, "only curves with #E prime (same as --cofactor 1)")
+		hStr       = fs.String("cofactor", "", "only curves with #E = cofactor × prime")
+		traceStr   = fs.String("trace", "", "only curves with trace of Frobenius t = p+1-#E in lo:hi (inclusive, may be negative)")
+		countWith  = fs.String("count", "auto", "point counting: auto|legendre|bsgs|mestre")
+		maxTries   = fs.Int("max-tries", 1_000_000, "give up after drawing this many curves in all")
+		anomalous  = fs.Bool("allow-anomalous", false, "with --prime-order/--cofactor: accept curves with order r = p")
+		seed       = fs.Int64("seed", 0, "seed for A, B and the counting points (0 = crypto/rand)")
+		format     = fs.String("format", "json", "output: json (an ectorus -manifest array)|ndjson|args (one line of ecscan flags per curve)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(*pStr) == "" {
+		return nil, errors.New("missing required --p")
+	}
+	cfg := &Config{
+		N: *n, Count: strings.ToLower(strings.TrimSpace(*countWith)), MaxTries: *maxTries, Seed: *seed,
+		Format: strings.ToLower(strings.TrimSpace(*format)), AllowAnomalous: *anomalous,
+	}
+	var err error
+	if cfg.P, err = parseInt(*pStr, "p"); err != nil {
+		return nil, err
+	}
+	if cfg.P.Cmp(big.NewInt(3)) <= 0 || !cfg.P.ProbablyPrime(32) {
+		return nil, errors.New("--p must be a prime > 3")
+	}
+	switch {
+	case *primeOrder && *hStr != "":
+		return nil, errors.New("--prime-order and --cofactor are exclusive")
+	case *primeOrder:
+		cfg.Cofactor = big.NewInt(1)
+	case *hStr != "":
+		if cfg.Cofactor, err = parseInt(*hStr, "cofactor"); err != nil {
+			return nil, err
+		}
+		if cfg.Cofactor.Sign() <= 0 {
+			return nil, errors.New("--cofactor must be positive")
+		}
+	}
+	if *traceStr != "" {
+		lo, hi, ok := strings.Cut(*traceStr, ":")
+		if !ok {
+			return nil, fmt.Errorf("bad --trace %q (want lo:hi)", *traceStr)
+		}
+		if cfg.TraceLo, err = parseInt(lo, "trace"); err != nil {
+			return nil, err
+		}
+		if cfg.TraceHi, err = parseInt(hi, "trace"); err != nil {
+			return nil, err
+		}
+		if cfg.TraceLo.Cmp(cfg.TraceHi) > 0 {
+			return nil, fmt.Errorf("bad --trace %q (lo > hi)", *traceStr)
+		}
+	}
+	switch cfg.Count {
+	case "auto", "legendre", "bsgs", "mestre":
+	default:
+		return nil, fmt.Errorf("bad --count %q (want auto|legendre|bsgs|mestre)", *countWith)
+	}
+	switch cfg.Format {
+	case "json", "ndjson", "args":
+	default:
+		return nil, fmt.Errorf("bad --format %q (want json|ndjson|args)", *format)
+	}
+	if cfg.N <= 0 || cfg.MaxTries <= 0 {
+		return nil, errors.New("--n and --max-tries must be positive")
+	}
+	return cfg, nil
+}
+
+// Curve is one generated curve. p, A and B are the ectorus manifest fields;
+// the rest annotate the choice and are ignored by -manifest.
+type Curve struct {
+	P        string `json:"p"`
+	A        string `json:"A"`
+	B        string `json:"B"`
+	N        string `json:"pointCount"`
+	Trace    string `json:"trace"`
+	Cofactor string `json:"cofactor,omitempty"`
+	R        string `json:"order,omitempty"` // the prime #E / cofactor
+}
+
+func Run(cfg *Config, w io.Writer) error {
+	curves, err := Generate(cfg)
+	if err != nil {
+		return err
+	}
+	for i, c := range curves {
+		var err error
+		switch cfg.Format {
+		case "args":
+			_, err = fmt.Fprintf(w, "--p=%s --A=%s --B=%s\n", c.P, c.A, c.B)
+		case "ndjson":
+			err = writeJSON(w, "", c, "\n")
+		default:
+			open, end := "  ", ",\n"
+			if i == 0 {
+				open = "[\n  "
+			}
+			if i == len(curves)-1 {
+				end = "\n]\n"
+			}
+			err = writeJSON(w, open, c, end)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, before string, c Curve, after string) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s%s", before, b, after)
+	return err
+}
+
+// Generate draws (A, B) uniformly mod p until cfg.N distinct nonsingular
+// curves meet the constraints; under a cofactor constraint, anomalous curves
+// (r = p) are skipped unless allowed. Curves --count bsgs cannot count (group
+// exponent below 4√p) are skipped; with the other methods none are.
+func Generate(cfg *Config) ([]Curve, error) {
+	p := cfg.P
+	var rnd io.Reader = crand.Reader
+	if cfg.Seed != 0 {
+		rnd = mrand.New(mrand.NewSource(cfg.Seed))
+	}
+	seen := make(map[[2]string]bool)
+	var out []Curve
+	for try := 0; try < cfg.MaxTries && len(out) < cfg.N; try++ {
+		A, err := crand.Int(rnd, p)
+		if err != nil {
+			return nil, err
+		}
+		B, err := crand.Int(rnd, p)
+		if err != nil {
+			return nil, err
+		}
+		c := ec.Curve{P: p, A: A, B: B}
+		key := [2]string{A.String(), B.String()}
+		if c.IsSingular() || seen[key] {
+			continue
+		}
+		seen[key] = true
+		N, _, err := count.Auto(c, cfg.Count, rnd)
+		if errors.Is(err, count.ErrAmbiguous) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("count A=%s B=%s: %w", A, B, err)
+		}
+		t := count.Trace(p, N)
+		if cfg.TraceLo != nil && (t.Cmp(cfg.TraceLo) < 0 || t.Cmp(cfg.TraceHi) > 0) {
+			continue
+		}
+		cur := Curve{P: p.String(), A: key[0], B: key[1], N: N.String(), Trace: t.String()}
+		if cfg.Cofactor != nil {
+			r, rem := new(big.Int).QuoRem(N, cfg.Cofactor, new(big.Int))
+			if rem.Sign() != 0 || !r.ProbablyPrime(32) || r.Cmp(p) == 0 && !cfg.AllowAnomalous {
+				continue
+			}
+			cur.Cofactor, cur.R = cfg.Cofactor.String(), r.String()
+		}
+		out = append(out, cur)
+	}
+	if len(out) < cfg.N {
+		return nil, fmt.Errorf("found %d of %d curves in %d tries", len(out), cfg.N, cfg.MaxTries)
+	}
+	return out, nil
+}
+
+// parseInt reads decimal, 0x-hex or an expression like 2^61-1 (ec.ParseBig).
+func parseInt(s, name string) (*big.Int, error) {
+	z, err := ec.ParseBig(s)
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %w", name, err)
+	}
+	return z, nil
+}
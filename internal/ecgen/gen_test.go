@@ -0,0 +1,83 @@
+package ecgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"ectorus/internal/count"
+	"ectorus/internal/ec"
+)
+
+func TestGenerateMeetsConstraints(t *testing.T) {
+	p := big.NewInt(10007)
+	for _, tc := range []struct {
+		name     string
+		cofactor int64 // 0: none
+		lo, hi   int64
+		count    string
+	}{
+		{"prime order", 1, 0, 0, "legendre"},
+		{"cofactor 4", 4, 0, 0, "bsgs"},
+		{"trace range", 0, -20, 20, "auto"},
+	} {
+		cfg := &Config{P: p, N: 4, Count: tc.count, MaxTries: 100_000, Seed: 11}
+		if tc.cofactor != 0 {
+			cfg.Cofactor = big.NewInt(tc.cofactor)
+		}
+		if tc.lo != tc.hi {
+			cfg.TraceLo, cfg.TraceHi = big.NewInt(tc.lo), big.NewInt(tc.hi)
+		}
+		curves, err := Generate(cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		seen := make(map[string]bool)
+		for _, c := range curves {
+			A, _ := new(big.Int).SetString(c.A, 10)
+			B, _ := new(big.Int).SetString(c.B, 10)
+			E := ec.Curve{P: p, A: A, B: B}
+			N := count.Legendre(E)
+			tr := count.Trace(p, N)
+			switch {
+			case E.IsSingular() || seen[c.A+","+c.B]:
+				t.Fatalf("%s: singular or repeated curve %+v", tc.name, c)
+			case N.String() != c.N || tr.String() != c.Trace:
+				t.Fatalf("%s: %+v, Legendre says #E = %s", tc.name, c, N)
+			case tc.cofactor != 0 && (new(big.Int).Mod(N, cfg.Cofactor).Sign() != 0 ||
+				!new(big.Int).Div(N, cfg.Cofactor).ProbablyPrime(20) || N.Cmp(p) == 0):
+				t.Fatalf("%s: #E = %s is not %d × prime (or is anomalous)", tc.name, N, tc.cofactor)
+			case tc.lo != tc.hi && (tr.Int64() < tc.lo || tr.Int64() > tc.hi):
+				t.Fatalf("%s: trace %s out of range", tc.name, tr)
+			}
+			seen[c.A+","+c.B] = true
+		}
+	}
+}
+
+func TestRunFormats(t *testing.T) {
+	cfg := &Config{P: big.NewInt(101), N: 3, Count: "auto", MaxTries: 1000, Seed: 1, Format: "json"}
+	var buf bytes.Buffer
+	if err := Run(cfg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	var curves []Curve
+	if err := json.Unmarshal(buf.Bytes(), &curves); err != nil || len(curves) != 3 || curves[0].P != "101" {
+		t.Fatalf("json output %q: %v", buf.String(), err)
+	}
+
+	cfg.Format = "args"
+	buf.Reset()
+	if err := Run(cfg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "--p=101 --A=" + curves[0].A + " --B=" + curves[0].B + "\n"; !bytes.HasPrefix(buf.Bytes(), []byte(want)) {
+		t.Fatalf("args output %q, want it to start %q", buf.String(), want)
+	}
+
+	cfg.N, cfg.Cofactor, cfg.MaxTries = 1, big.NewInt(1000), 50
+	if _, err := Generate(cfg); err == nil {
+		t.Fatal("impossible cofactor satisfied")
+	}
+}
@@ -0,0 +1,81 @@
+package ecinfo
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"ectorus/internal/ec"
+)
+
+type Config struct {
+	P, A, B    *big.Int
+	N          *big.Int // --N: known #E(F_p), skips counting
+	CountLimit uint64   // --count-limit: count only when p ≤ this
+	MaxK       int      // --max-k: embedding-degree search bound
+	JSON       bool     // --json
+	MOV        bool     // --mov: pairing-transfer report per prime r | N
+	Torsion    int      // --torsion: E(F_p)[m] for 2 ≤ m ≤ this; 0 = off
+}
+
+// torsionMax bounds --torsion: ψ_m has degree about m^2/2, and the
+// F_{p^k} search composes polynomials of that degree up to --max-k times.
+const torsionMax = 16
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("ecinfo", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	var (
+		pStr       = fs.String("p", "", "prime modulus p > 3 (decimal, 0x-hex or e.g. 2^61-1; required)")
+		AStr       = fs.String("A", "0", "curve parameter A")
+		BStr       = fs.String("B", "0", "curve parameter B")
+		NStr       = fs.String("N", "", "known point count #E(F_p) (skips counting)")
+		countLimit = fs.Uint64("count-limit", 100_000_000, "count points (O(p) Legendre scan) only when p ≤ this")
+		maxK       = fs.Int("max-k", 100, "search embedding degrees k ≤ max-k")
+		jsonOut    = fs.Bool("json", false, "emit JSON instead of text")
+		mov        = fs.Bool("mov", false, "report MOV/Frey–Rück susceptibility per prime r | N, checking the Tate pairing when k = 1")
+		torsion    = fs.Int("torsion", 0, "report E(F_p)[m] and the least k with E[m] ⊆ E(F_{p^k}) for 2 ≤ m ≤ this, by division polynomials (0 = off)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(*pStr) == "" {
+		return nil, errors.New("missing required --p")
+	}
+	cfg := &Config{CountLimit: *countLimit, MaxK: *maxK, JSON: *jsonOut, MOV: *mov, Torsion: *torsion}
+	if cfg.Torsion < 0 || cfg.Torsion > torsionMax {
+		return nil, fmt.Errorf("--torsion must be in 0..%d", torsionMax)
+	}
+	var err error
+	if cfg.P, err = parseInt(*pStr, "p"); err != nil {
+		return nil, err
+	}
+	if cfg.P.Cmp(big.NewInt(3)) <= 0 {
+		return nil, errors.New("--p must be > 3")
+	}
+	if cfg.A, err = parseInt(*AStr, "A"); err != nil {
+		return nil, err
+	}
+	if cfg.B, err = parseInt(*BStr, "B"); err != nil {
+		return nil, err
+	}
+	if *NStr != "" {
+		if cfg.N, err = parseInt(*NStr, "N"); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// parseInt reads decimal, 0x-hex or an expression like 2^61-1 (ec.ParseBig).
+func parseInt(s, name string) (*big.Int, error) {
+	z, err := ec.ParseBig(s)
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %w", name, err)
+	}
+	return z, nil
+}
@@ -0,0 +1,424 @@
+// Package ecinfo builds the one-stop sanity report behind cmd/ecinfo:
+// invariants, point count and trace, and the classical weak-curve checks.
+package ecinfo
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"ectorus/internal/count"
+	"ectorus/internal/ec"
+	"ectorus/internal/factor"
+)
+
+// movMaxK is the largest embedding degree --mov calls susceptible: up to
+// it, index calculus in F_{p^k}^* beats generic attacks on a subgroup the
+// same size as F_p itself (Menezes–Okamoto–Vanstone).
+const movMaxK = 6
+
+// movTries bounds the random points --mov draws looking for a point of order
+// r and a Q that pairs nontrivially with it.
+const movTries = 20
+
+// supersingularSamples is how many random points must all be killed by p+1
+// before a curve with no count is called probably supersingular.
+const supersingularSamples = 20
+
+type Report struct {
+	P            string `json:"p"`
+	A            string `json:"A"`
+	B            string `json:"B"`
+	Discriminant string `json:"discriminant"`
+	Singular     bool   `json:"singular"`
+	J            string `json:"j,omitempty"`
+
+	N          string `json:"pointCount,omitempty"`
+	CountedBy  string `json:"countedBy,omitempty"` // "legendre" | "given"
+	Trace      string `json:"trace,omitempty"`
+	HasseOK    *bool  `json:"hasseOK,omitempty"`
+	Anomalous  *bool  `json:"anomalous,omitempty"`
+	PrimeOrder *bool  `json:"primeOrder,omitempty"`
+
+	// Supersingular is "yes"/"no" when decided, "probable" when only the
+	// random-point test was available.
+	Supersingular       string `json:"supersingular"`
+	SupersingularReason string `json:"supersingularReason"`
+
+	Factors    []Factor `json:"factors,omitempty"`
+	Unfactored string   `json:"unfactoredCofactor,omitempty"` // composite part rho could not split
+	// LargestPrime is the largest prime r | N, the order of the biggest
+	// prime subgroup, and Cofactor is h = N / r (both only when N is fully
+	// factored).
+	LargestPrime string    `json:"largestPrime,omitempty"`
+	Cofactor     string    `json:"cofactor,omitempty"`
+	Notes        []string  `json:"notes,omitempty"`
+	MaxK         int       `json:"maxK,omitempty"`
+	Embedding    []Degree  `json:"embeddingDegrees,omitempty"`
+	MOV          []MOV     `json:"mov,omitempty"`
+	Torsion      []Torsion `json:"torsion,omitempty"`
+}
+
+type Factor struct {
+	Q string `json:"q"`
+	E int    `json:"e"`
+}
+
+// Degree is the embedding degree k of the order-r subgroup: the least k with
+// r | p^k - 1 (0 when k > MaxK).
+type Degree struct {
+	R string `json:"r"`
+	K int    `json:"k"`
+}
+
+// MOV is the pairing transfer of the order-r subgroup into F_{p^k}^*.
+type MOV struct {
+	R           string `json:"r"`
+	K           int    `json:"k"`
+	FieldBits   int    `json:"fieldBits"` // bits of p^k
+	Susceptible bool   `json:"susceptible"`
+	// with k = 1: a point P of order r, a Q, and t_r(P, Q) ≠ 1
+	P       [2]string `json:"P,omitempty"`
+	Q       [2]string `json:"Q,omitempty"`
+	Pairing string    `json:"tatePairing,omitempty"`
+	Note    string    `json:"note,omitempty"`
+}
+
+// Torsion is E(F_p)[m] ≅ Z/D1 × Z/D2 (D2 | D1 | m), from the roots of the
+// division polynomials. E[m] ≅ (Z/m)^2 lies in E(F_p) when D2 = m, and in
+// E(F_{p^k}) from FullDegree on (0 when that is beyond MaxK).
+type Torsion struct {
+	M          int  `json:"m"`
+	D1         int  `json:"d1"`
+	D2         int  `json:"d2"`
+	Points     int  `json:"points"` // #E(F_p)[m], with O
+	Full       bool `json:"full"`
+	FullDegree int  `json:"fullDegree"`
+}
+
+func Run(cfg *Config, w io.Writer) error {
+	r, err := Analyze(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(r)
+	}
+	return r.Print(w)
+}
+
+// Analyze computes the report for cfg's curve.
+func Analyze(cfg *Config) (*Report, error) {
+	p := cfg.P
+	c := ec.Curve{P: p, A: ec.Mod(cfg.A, p), B: ec.Mod(cfg.B, p)}
+	r := &Report{
+		P: p.String(), A: c.A.String(), B: c.B.String(),
+		Discriminant: c.Discriminant().String(),
+		Singular:     c.IsSingular(),
+	}
+	if !p.ProbablyPrime(32) {
+		return nil, fmt.Errorf("p = %s is not prime", p)
+	}
+	if r.Singular {
+		r.Supersingular = "n/a"
+		r.SupersingularReason = "singular curve"
+		return r, nil
+	}
+	j, err := c.JInvariant()
+	if err != nil {
+		return nil, err
+	}
+	r.J = j.String()
+	if r.Torsion, err = torsion(c, cfg); err != nil {
+		return nil, err
+	}
+
+	N := cfg.N
+	switch {
+	case N != nil:
+		r.CountedBy = "given"
+	case p.IsUint64() && p.Uint64() <= cfg.CountLimit:
+		N = count.Legendre(c)
+		r.CountedBy = "legendre"
+	default:
+		r.Notes = append(r.Notes, fmt.Sprintf("p > --count-limit %d: no point count, so no trace, anomaly or embedding checks", cfg.CountLimit))
+	}
+
+	if N == nil {
+		r.Supersingular, r.SupersingularReason, err = supersingularNoCount(c, j)
+		return r, err
+	}
+
+	ap := count.Trace(p, N)
+	r.N, r.Trace = N.String(), ap.String()
+	// Hasse: a_p^2 ≤ 4p
+	hasse := new(big.Int).Mul(ap, ap).Cmp(new(big.Int).Lsh(p, 2)) <= 0
+	anomalous := N.Cmp(p) == 0
+	prime := N.ProbablyPrime(32)
+	r.HasseOK, r.Anomalous, r.PrimeOrder = &hasse, &anomalous, &prime
+	if !hasse {
+		r.Notes = append(r.Notes, "point count violates the Hasse bound: wrong N?")
+	}
+	if new(big.Int).Mod(ap, p).Sign() == 0 {
+		r.Supersingular, r.SupersingularReason = "yes", "a_p ≡ 0 mod p"
+	} else {
+		r.Supersingular, r.SupersingularReason = "no", "a_p ≢ 0 mod p"
+	}
+
+	for _, t := range r.Torsion {
+		if new(big.Int).Mod(N, big.NewInt(int64(t.Points))).Sign() != 0 {
+			r.Notes = append(r.Notes, fmt.Sprintf("#E(F_p)[%d] = %d does not divide N: wrong N?", t.M, t.Points))
+		}
+	}
+
+	r.MaxK = cfg.MaxK
+	fN := factor.Of(N)
+	if !fN.Complete() {
+		r.Unfactored = fN.Rest.String()
+	}
+	if q := fN.Largest(); q != nil {
+		r.LargestPrime, r.Cofactor = q.String(), fN.Cofactor().String()
+	}
+	for _, f := range fN.Powers {
+		r.Factors = append(r.Factors, Factor{Q: f.Q.String(), E: f.E})
+		if f.Q.Cmp(p) == 0 {
+			continue // the anomalous p-part has no embedding degree
+		}
+		k := embeddingDegree(p, f.Q, cfg.MaxK)
+		r.Embedding = append(r.Embedding, Degree{R: f.Q.String(), K: k})
+		if cfg.MOV && k > 0 {
+			m, err := movCheck(c, N, f.Q, f.E, k)
+			if err != nil {
+				return nil, err
+			}
+			r.MOV = append(r.MOV, m)
+		}
+	}
+	return r, nil
+}
+
+// movCheck reports the pairing transfer of the order-r subgroup. With
+// k = 1 the Tate pairing lives in F_p and is computed: a value ≠ 1 shows
+// t_r(·, Q) embeds <P> in F_p^*, turning its discrete logs into ones in F_p.
+func movCheck(c ec.Curve, N, r *big.Int, e, k int) (MOV, error) {
+	m := MOV{
+		R: r.String(), K: k, Susceptible: k <= movMaxK,
+		FieldBits: new(big.Int).Exp(c.P, big.NewInt(int64(k)), nil).BitLen(),
+	}
+	if k > 1 {
+		m.Note = fmt.Sprintf("pairing not computed: it takes values in F_{p^%d}", k)
+		return m, nil
+	}
+	// (N / r^e)·R lies in the r-part, which need not be cyclic
+	h := new(big.Int).Quo(N, new(big.Int).Exp(r, big.NewInt(int64(e)), nil))
+	for i := 0; i < movTries && m.Pairing == ""; i++ {
+		R, err := c.RandomPoint(rand.Reader)
+		if err != nil {
+			return m, err
+		}
+		P, err := c.ScalarMul(h, R)
+		if err != nil {
+			return m, err
+		}
+		if P.Inf {
+			continue
+		}
+		// P has order r^j; step down to order r
+		for {
+			rP, err := c.ScalarMul(r, P)
+			if err != nil {
+				return m, err
+			}
+			if rP.Inf {
+				break
+			}
+			P = rP
+		}
+		for j := 0; j < movTries; j++ {
+			Q, err := c.RandomPoint(rand.Reader)
+			if err != nil {
+				return m, err
+			}
+			t, err := c.TatePairing(P, Q, r)
+			if err != nil {
+				return m, err
+			}
+			if t.Cmp(big.NewInt(1)) != 0 {
+				m.P, m.Q, m.Pairing = [2]string{P.X.String(), P.Y.String()}, [2]string{Q.X.String(), Q.Y.String()}, t.String()
+				break
+			}
+		}
+	}
+	if m.Pairing == "" {
+		m.Note = fmt.Sprintf("no pair with t_r(P, Q) ≠ 1 in %d tries", movTries*movTries)
+	}
+	return m, nil
+}
+
+// torsion reports E(F_p)[m] for 2 ≤ m ≤ cfg.Torsion, skipping the
+// multiples of p, whose division polynomials are not separable.
+func torsion(c ec.Curve, cfg *Config) ([]Torsion, error) {
+	var out []Torsion
+	for m := 2; m <= cfg.Torsion; m++ {
+		if new(big.Int).Mod(big.NewInt(int64(m)), c.P).Sign() == 0 {
+			continue
+		}
+		d1, d2, err := c.TorsionStructure(m)
+		if err != nil {
+			return nil, err
+		}
+		k, err := c.FullTorsionDegree(m, cfg.MaxK)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Torsion{M: m, D1: d1, D2: d2, Points: d1 * d2, Full: d2 == m, FullDegree: k})
+	}
+	return out, nil
+}
+
+// supersingularNoCount decides supersingularity without N: j = 0 with
+// p ≡ 2 mod 3 and j = 1728 with p ≡ 3 mod 4 are always supersingular, and
+// otherwise a supersingular curve has N = p+1, so every point is killed by
+// p+1 — one point that is not proves the curve ordinary.
+func supersingularNoCount(c ec.Curve, j *big.Int) (string, string, error) {
+	p := c.P
+	if j.Sign() == 0 && new(big.Int).Mod(p, big.NewInt(3)).Int64() == 2 {
+		return "yes", "j = 0 and p ≡ 2 mod 3", nil
+	}
+	if j.Cmp(ec.Mod(big.NewInt(1728), p)) == 0 && new(big.Int).Mod(p, big.NewInt(4)).Int64() == 3 {
+		return "yes", "j = 1728 and p ≡ 3 mod 4", nil
+	}
+	k := new(big.Int).Add(p, big.NewInt(1))
+	for i := 0; i < supersingularSamples; i++ {
+		P, err := c.RandomPoint(rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		Q, err := c.ScalarMul(k, P)
+		if err != nil {
+			return "", "", err
+		}
+		if !Q.Inf {
+			return "no", "(p+1)·P ≠ O for a random P", nil
+		}
+	}
+	return "probable", fmt.Sprintf("(p+1)·P = O for %d random points", supersingularSamples), nil
+}
+
+// embeddingDegree returns the least k ≤ maxK with p^k ≡ 1 mod r, or 0.
+func embeddingDegree(p, r *big.Int, maxK int) int {
+	pr := new(big.Int).Mod(p, r)
+	acc := new(big.Int).Set(pr)
+	for k := 1; k <= maxK; k++ {
+		if acc.Cmp(big.NewInt(1)) == 0 {
+			return k
+		}
+		acc.Mul(acc, pr).Mod(acc, r)
+	}
+	return 0
+}
+
+// Print writes r as a human-readable report.
+func (r *Report) Print(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("Curve: y^2 = x^3 + %s x + %s over F_%s\n", r.A, r.B, r.P)
+	ew.printf("Discriminant: %s\n", r.Discriminant)
+	if r.Singular {
+		ew.printf("Singular: yes (not an elliptic curve)\n")
+		return ew.err
+	}
+	ew.printf("j-invariant: %s\n", r.J)
+	if r.N != "" {
+		ew.printf("\nPoint count N: %s (%s)\n", r.N, r.CountedBy)
+		ew.printf("Trace a_p = p + 1 - N: %s\n", r.Trace)
+		ew.printf("Hasse bound |a_p| ≤ 2√p: %v\n", *r.HasseOK)
+		ew.printf("Anomalous (N = p): %v\n", *r.Anomalous)
+		ew.printf("Prime order: %v\n", *r.PrimeOrder)
+	}
+	ew.printf("Supersingular: %s (%s)\n", r.Supersingular, r.SupersingularReason)
+	if len(r.Factors) > 0 || r.Unfactored != "" {
+		ew.printf("\nFactorization of N:")
+		for _, f := range r.Factors {
+			if f.E > 1 {
+				ew.printf(" %s^%d", f.Q, f.E)
+			} else {
+				ew.printf(" %s", f.Q)
+			}
+		}
+		if r.Unfactored != "" {
+			ew.printf(" · %s (composite, unfactored)", r.Unfactored)
+		}
+		ew.printf("\n")
+	}
+	if r.LargestPrime != "" {
+		ew.printf("Largest prime subgroup: r = %s, cofactor h = N / r = %s\n", r.LargestPrime, r.Cofactor)
+	}
+	if len(r.Embedding) > 0 {
+		ew.printf("Embedding degrees (least k with r | p^k - 1):\n")
+		for _, d := range r.Embedding {
+			if d.K == 0 {
+				ew.printf("  r = %s: k > %d\n", d.R, r.MaxK)
+			} else {
+				ew.printf("  r = %s: k = %d\n", d.R, d.K)
+			}
+		}
+	}
+	if len(r.MOV) > 0 {
+		ew.printf("MOV/Frey–Rück transfer (k ≤ %d counts as susceptible):\n", movMaxK)
+		for _, m := range r.MOV {
+			ew.printf("  r = %s: into F_{p^%d}^*, a %d-bit field, susceptible: %v\n", m.R, m.K, m.FieldBits, m.Susceptible)
+			if m.Pairing != "" {
+				ew.printf("    t_r((%s, %s), (%s, %s)) = %s ≠ 1\n", m.P[0], m.P[1], m.Q[0], m.Q[1], m.Pairing)
+			}
+			if m.Note != "" {
+				ew.printf("    %s\n", m.Note)
+			}
+		}
+	}
+	if len(r.Torsion) > 0 {
+		ew.printf("\nTorsion E(F_p)[m] (division polynomials):\n")
+		for _, t := range r.Torsion {
+			switch {
+			case t.D1 == 1:
+				ew.printf("  m = %d: trivial", t.M)
+			case t.D2 == 1:
+				ew.printf("  m = %d: Z/%d", t.M, t.D1)
+			default:
+				ew.printf("  m = %d: Z/%d × Z/%d", t.M, t.D1, t.D2)
+			}
+			switch {
+			case t.Full:
+				ew.printf("; E[%d] ⊆ E(F_p)\n", t.M)
+			case t.FullDegree > 0:
+				ew.printf("; E[%d] ⊆ E(F_{p^%d})\n", t.M, t.FullDegree)
+			default:
+				ew.printf("; E[%d] ⊄ E(F_{p^k}) for k ≤ %d\n", t.M, r.MaxK)
+			}
+		}
+	}
+	if len(r.Notes) > 0 {
+		ew.printf("\nNotes:\n")
+		for _, n := range r.Notes {
+			ew.printf("  - %s\n", n)
+		}
+	}
+	return ew.err
+}
+
+// errWriter keeps the first write error so Print can stay linear.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = fmt.Fprintf(e.w, format, args...)
+}
@@ -0,0 +1,137 @@
+package ecinfo
+
+import (
+	"math/big"
+	"testing"
+)
+
+func cfg(p, A, B int64) *Config {
+	return &Config{P: big.NewInt(p), A: big.NewInt(A), B: big.NewInt(B), CountLimit: 1 << 20, MaxK: 100}
+}
+
+func TestAnalyzeCounted(t *testing.T) {
+	r, err := Analyze(cfg(101, 2, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.N != "96" || r.Trace != "6" || !*r.HasseOK || *r.Anomalous || *r.PrimeOrder {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+	if r.Supersingular != "no" {
+		t.Fatalf("supersingular = %q", r.Supersingular)
+	}
+	if r.LargestPrime != "3" || r.Cofactor != "32" || r.Unfactored != "" {
+		t.Fatalf("N = 96: largest prime %q, cofactor %q, unfactored %q", r.LargestPrime, r.Cofactor, r.Unfactored)
+	}
+	want := []Degree{{"2", 1}, {"3", 2}}
+	if len(r.Embedding) != len(want) {
+		t.Fatalf("embedding degrees %v, want %v", r.Embedding, want)
+	}
+	for i := range want {
+		if r.Embedding[i] != want[i] {
+			t.Fatalf("embedding degrees %v, want %v", r.Embedding, want)
+		}
+	}
+}
+
+func TestAnalyzeSupersingular(t *testing.T) {
+	// y^2 = x^3 + x over p ≡ 3 mod 4 has N = p + 1.
+	r, err := Analyze(cfg(103, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Trace != "0" || r.Supersingular != "yes" {
+		t.Fatalf("want supersingular with a_p = 0, got %+v", r)
+	}
+
+	// Without a count the j = 0, p ≡ 2 mod 3 rule still decides it.
+	c := cfg(107, 0, 5)
+	c.CountLimit = 0
+	if r, err = Analyze(c); err != nil {
+		t.Fatal(err)
+	}
+	if r.N != "" || r.Supersingular != "yes" {
+		t.Fatalf("want supersingular without count, got %+v", r)
+	}
+
+	// ... and the random-point test rejects an ordinary curve.
+	c = cfg(101, 2, 3)
+	c.CountLimit = 0
+	if r, err = Analyze(c); err != nil {
+		t.Fatal(err)
+	}
+	if r.Supersingular != "no" {
+		t.Fatalf("want ordinary, got %+v", r)
+	}
+}
+
+func TestAnalyzeSingular(t *testing.T) {
+	r, err := Analyze(cfg(11, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Singular || r.J != "" {
+		t.Fatalf("want singular report, got %+v", r)
+	}
+}
+
+func TestAnalyzeMOV(t *testing.T) {
+	// E(F_101) ⊇ Z/5 × Z/5 and 5 | p-1: the order-5 subgroup pairs into F_101
+	c := cfg(101, 2, 26)
+	c.MOV = true
+	r, err := Analyze(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var five *MOV
+	for i := range r.MOV {
+		if r.MOV[i].R == "5" {
+			five = &r.MOV[i]
+		}
+	}
+	if five == nil || five.K != 1 || !five.Susceptible || five.Pairing == "" {
+		t.Fatalf("mov report %+v", r.MOV)
+	}
+	v, _ := new(big.Int).SetString(five.Pairing, 10)
+	if v.Cmp(big.NewInt(1)) == 0 || new(big.Int).Exp(v, big.NewInt(5), big.NewInt(101)).Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("t_5 = %s is not a nontrivial 5th root of unity", v)
+	}
+
+	// r = 3 has k = 2 over F_101 for y^2 = x^3 + 2x + 3: reported, not computed
+	c = cfg(101, 2, 3)
+	c.MOV = true
+	if r, err = Analyze(c); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range r.MOV {
+		if m.R == "3" && (m.K != 2 || m.Pairing != "" || m.Note == "") {
+			t.Fatalf("k = 2 entry %+v", m)
+		}
+	}
+}
+
+func TestAnalyzeTorsion(t *testing.T) {
+	// E(F_101) ⊇ Z/5 × Z/5, so all of E[5] is rational
+	c := cfg(101, 2, 26)
+	c.Torsion = 5
+	r, err := Analyze(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Torsion) != 4 {
+		t.Fatalf("torsion for m = 2..5, got %+v", r.Torsion)
+	}
+	if got := r.Torsion[3]; got != (Torsion{M: 5, D1: 5, D2: 5, Points: 25, Full: true, FullDegree: 1}) {
+		t.Fatalf("m = 5: %+v", got)
+	}
+
+	// y^2 = x^3 + x over F_103: π^2 = -103 acts on E[3] with order 2
+	c = cfg(103, 1, 0)
+	c.Torsion = 3
+	if r, err = Analyze(c); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Torsion[1]; got != (Torsion{M: 3, D1: 1, D2: 1, Points: 1, FullDegree: 4}) {
+		t.Fatalf("m = 3: %+v", got)
+	}
+}
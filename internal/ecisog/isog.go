@@ -0,0 +1,418 @@
+// Package ecisog backs cmd/ecisog: walk the F_p-rational ℓ-isogeny graph
+// from a curve and print its component. Nodes are j-invariants (isogenous
+// curves share #E, so j pins the curve down up to isomorphism); edges come
+// from the roots of the modular polynomial Φ_ℓ(j, Y) for ℓ = 2, 3, or from
+// Vélu's formulas on the rational ℓ-torsion for any small ℓ.
+package ecisog
+
+import (
+	crand "crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	mrand "math/rand"
+	"os"
+	"strings"
+
+	"ectorus/internal/count"
+	"ectorus/internal/ec"
+)
+
+// maxVeluL bounds ℓ for --method velu: ψ_ℓ has degree (ℓ²-1)/2 and its
+// roots cost about deg² log p field operations.
+const maxVeluL = 31
+
+// twistChecks is how many random points must be killed by #E before a
+// curve built from j' is taken as the right twist (above count.LegendreBelow).
+const twistChecks = 16
+
+type Config struct {
+	P, A, B  *big.Int
+	L        int    // --l: the prime degree ℓ
+	Method   string // --method: auto|modular|velu
+	MaxNodes int    // --max-nodes
+	Count    string // --count: auto|legendre|bsgs|mestre
+	Seed     int64  // --seed: 0 => crypto/rand
+	Format   string // --format: dot|json
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("ecisog", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	var (
+		pStr      = fs.String("p", "", "prime modulus p > 3 (decimal, 0x-hex or e.g. 2^61-1; required)")
+		AStr      = fs.String("A", "0", "curve parameter A")
+		BStr      = fs.String("B", "0", "curve parameter B")
+		l         = fs.Int("l", 2, "isogeny degree ℓ (a prime)")
+		method    = fs.String("method", "auto", "edges from: modular (Φ_ℓ, ℓ = 2 or 3) | velu (rational ℓ-torsion) | auto")
+		maxNodes  = fs.Int("max-nodes", 1000, "stop the walk after this many curves")
+		countWith = fs.String("count", "auto", "point counting: auto|legendre|bsgs|mestre")
+		seed      = fs.Int64("seed", 0, "seed for counting and twist checks (0 = crypto/rand)")
+		format    = fs.String("format", "dot", "output format: dot|json")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(*pStr) == "" {
+		return nil, errors.New("missing required --p")
+	}
+	cfg := &Config{
+		L: *l, Method: strings.ToLower(strings.TrimSpace(*method)), MaxNodes: *maxNodes,
+		Count: strings.ToLower(strings.TrimSpace(*countWith)), Seed: *seed,
+		Format: strings.ToLower(strings.TrimSpace(*format)),
+	}
+	var err error
+	if cfg.P, err = parseInt(*pStr, "p"); err != nil {
+		return nil, err
+	}
+	if cfg.P.Cmp(big.NewInt(3)) <= 0 || !cfg.P.ProbablyPrime(32) {
+		return nil, errors.New("--p must be a prime > 3")
+	}
+	if cfg.A, err = parseInt(*AStr, "A"); err != nil {
+		return nil, err
+	}
+	if cfg.B, err = parseInt(*BStr, "B"); err != nil {
+		return nil, err
+	}
+	if cfg.L < 2 || !big.NewInt(int64(cfg.L)).ProbablyPrime(0) {
+		return nil, fmt.Errorf("--l %d is not a prime", cfg.L)
+	}
+	if big.NewInt(int64(cfg.L)).Cmp(cfg.P) == 0 {
+		return nil, errors.New("--l must differ from p")
+	}
+	switch cfg.Method {
+	case "auto":
+		cfg.Method = "velu"
+		if ec.HasModularPolynomial(cfg.L) {
+			cfg.Method = "modular"
+		}
+	case "modular":
+		if !ec.HasModularPolynomial(cfg.L) {
+			return nil, fmt.Errorf("--method modular: Φ_%d not available (ℓ = 2 or 3; use --method velu)", cfg.L)
+		}
+	case "velu":
+		if cfg.L > maxVeluL {
+			return nil, fmt.Errorf("--method velu: ℓ = %d is above %d", cfg.L, maxVeluL)
+		}
+	default:
+		return nil, fmt.Errorf("bad --method %q (want auto|modular|velu)", *method)
+	}
+	if cfg.MaxNodes <= 0 {
+		return nil, errors.New("--max-nodes must be positive")
+	}
+	switch cfg.Count {
+	case "auto", "legendre", "bsgs", "mestre":
+	default:
+		return nil, fmt.Errorf("bad --count %q (want auto|legendre|bsgs|mestre)", *countWith)
+	}
+	switch cfg.Format {
+	case "dot", "json":
+	default:
+		return nil, fmt.Errorf("bad --format %q (want dot|json)", *format)
+	}
+	return cfg, nil
+}
+
+// Graph is the component of the ℓ-isogeny graph over F_p that holds the
+// starting curve. Every curve in it has the same #E, so the whole
+// component is ordinary or supersingular together.
+type Graph struct {
+	P             string `json:"p"`
+	L             int    `json:"l"`
+	Method        string `json:"method"`
+	N             string `json:"pointCount"`
+	Trace         string `json:"trace"`
+	Supersingular bool   `json:"supersingular"`
+	Nodes         []Node `json:"nodes"`
+	Edges         []Edge `json:"edges"`
+	Complete      bool   `json:"complete"` // false when --max-nodes cut the walk short
+}
+
+// Node is one j-invariant with a curve y^2 = x^3 + Ax + B realizing it.
+// Depth is its distance from the start in the walk.
+type Node struct {
+	J     string `json:"j"`
+	A     string `json:"A"`
+	B     string `json:"B"`
+	Depth int    `json:"depth"`
+}
+
+// Edge links two nodes (or one node to itself). Count is the number of
+// distinct ℓ-isogenies seen from the endpoint walked first: kernels under
+// velu, distinct roots of Φ_ℓ under modular (so always 1 there).
+type Edge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+func Run(cfg *Config, w io.Writer) error {
+	g, err := Walk(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(g)
+	}
+	return writeDOT(g, w)
+}
+
+// Walk explores the component breadth-first from the configured curve.
+func Walk(cfg *Config) (*Graph, error) {
+	p := cfg.P
+	c := ec.Curve{P: p, A: ec.Mod(cfg.A, p), B: ec.Mod(cfg.B, p)}
+	if c.IsSingular() {
+		return nil, errors.New("curve is singular (4A^3 + 27B^2 ≡ 0 mod p)")
+	}
+	var rnd io.Reader = crand.Reader
+	if cfg.Seed != 0 {
+		rnd = mrand.New(mrand.NewSource(cfg.Seed))
+	}
+	N, _, err := count.Auto(c, cfg.Count, rnd)
+	if err != nil {
+		return nil, fmt.Errorf("count: %w", err)
+	}
+	t := count.Trace(p, N)
+	g := &Graph{
+		P: p.String(), L: cfg.L, Method: cfg.Method, N: N.String(), Trace: t.String(),
+		Supersingular: new(big.Int).Mod(t, p).Sign() == 0, Edges: []Edge{}, Complete: true,
+	}
+	j0, _ := c.JInvariant()
+	type entry struct {
+		c     ec.Curve
+		depth int
+	}
+	index := map[string]int{j0.String(): 0} // j -> position in g.Nodes
+	g.Nodes = append(g.Nodes, Node{J: j0.String(), A: c.A.String(), B: c.B.String()})
+	queue := []entry{{c, 0}}
+	done := make(map[string]bool)
+	for len(queue) > 0 {
+		e := queue[0]
+		queue = queue[1:]
+		j, _ := e.c.JInvariant()
+		u := j.String()
+		var nbrs []neighbor
+		if cfg.Method == "modular" {
+			nbrs, err = modularNeighbors(e.c, j, cfg.L, N, rnd)
+		} else {
+			nbrs, err = veluNeighbors(e.c, cfg.L)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("j = %s: %w", u, err)
+		}
+		counts := make(map[string]int)
+		var order []string
+		for _, nb := range nbrs {
+			v := nb.j.String()
+			if done[v] && v != u {
+				continue // recorded when v was walked
+			}
+			if _, ok := index[v]; !ok {
+				if len(g.Nodes) >= cfg.MaxNodes {
+					g.Complete = false
+					continue
+				}
+				index[v] = len(g.Nodes)
+				g.Nodes = append(g.Nodes, Node{J: v, A: nb.c.A.String(), B: nb.c.B.String(), Depth: e.depth + 1})
+				queue = append(queue, entry{nb.c, e.depth + 1})
+			}
+			if counts[v] == 0 {
+				order = append(order, v)
+			}
+			counts[v]++
+		}
+		for _, v := range order {
+			g.Edges = append(g.Edges, Edge{From: u, To: v, Count: counts[v]})
+		}
+		done[u] = true
+	}
+	return g, nil
+}
+
+// neighbor is the codomain of one ℓ-isogeny out of a node.
+type neighbor struct {
+	c ec.Curve
+	j *big.Int
+}
+
+// modularNeighbors takes the roots of Φ_ℓ(j, Y) and builds, for each, a
+// curve with that j-invariant and N points (the right twist).
+func modularNeighbors(c ec.Curve, j *big.Int, l int, N *big.Int, rnd io.Reader) ([]neighbor, error) {
+	roots, err := ec.ModularRoots(l, j, c.P)
+	if err != nil {
+		return nil, err
+	}
+	var out []neighbor
+	for _, j2 := range roots {
+		c2, err := curveWithJ(c.P, j2, N, rnd)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, neighbor{c2, j2})
+	}
+	return out, nil
+}
+
+// veluNeighbors lists one codomain per order-ℓ subgroup of E(F_p): its
+// x-coordinates are roots of ψ_ℓ (of x^3 + Ax + B for ℓ = 2) with a
+// rational y. Rational ℓ-isogenies whose kernel points lie only over an
+// extension of F_p are not seen.
+func veluNeighbors(c ec.Curve, l int) ([]neighbor, error) {
+	p := c.P
+	psi := ec.NewPoly(p, c.B, c.A, new(big.Int), big.NewInt(1))
+	if l > 2 {
+		psi = c.DivisionPolynomial(l)
+	}
+	used := make(map[string]bool)
+	var out []neighbor
+	for _, x := range psi.Roots(p) {
+		if used[x.String()] {
+			continue
+		}
+		rhs := ec.AddM(ec.AddM(ec.MulM(x, ec.MulM(x, x, p), p), ec.MulM(c.A, x, p), p), c.B, p)
+		y, err := ec.SqrtModP(rhs, p)
+		if err != nil {
+			continue // the kernel point lies over F_{p^2}
+		}
+		phi, err := c.IsogenyFromKernel([]ec.Point{{X: x, Y: y}})
+		if err != nil {
+			return nil, err
+		}
+		for _, Q := range phi.Kernel {
+			used[Q.X.String()] = true
+		}
+		j2, err := phi.Codomain.JInvariant()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, neighbor{phi.Codomain, j2})
+	}
+	return out, nil
+}
+
+// curveWithJ returns a curve with j-invariant j and N points. For j ≠ 0,
+// 1728 that is y^2 = x^3 + 3kx + 2k, k = j/(1728 - j), or its quadratic
+// twist; j = 0 and j = 1728 have more twists, so B (resp. A) is searched.
+func curveWithJ(p, j, N *big.Int, rnd io.Reader) (ec.Curve, error) {
+	j1728 := ec.Mod(big.NewInt(1728), p)
+	var candidates func(i int64) ec.Curve
+	switch {
+	case j.Sign() == 0:
+		candidates = func(i int64) ec.Curve { return ec.Curve{P: p, A: new(big.Int), B: big.NewInt(i)} }
+	case j.Cmp(j1728) == 0:
+		candidates = func(i int64) ec.Curve { return ec.Curve{P: p, A: big.NewInt(i), B: new(big.Int)} }
+	default:
+		inv, err := ec.InvM(ec.SubM(j1728, j, p), p)
+		if err != nil {
+			return ec.Curve{}, err
+		}
+		k := ec.MulM(j, inv, p)
+		c := ec.Curve{P: p, A: ec.MulM(big.NewInt(3), k, p), B: ec.MulM(big.NewInt(2), k, p)}
+		tw, _ := c.QuadraticTwist()
+		candidates = func(i int64) ec.Curve {
+			if i == 1 {
+				return c
+			}
+			return tw
+		}
+	}
+	for i := int64(1); i < 1000 && big.NewInt(i).Cmp(p) < 0; i++ {
+		c := candidates(i)
+		ok, err := hasCount(c, N, rnd)
+		if err != nil {
+			return ec.Curve{}, err
+		}
+		if ok {
+			return c, nil
+		}
+	}
+	return ec.Curve{}, fmt.Errorf("no twist with j = %s has %s points", j, N)
+}
+
+// hasCount reports whether #c = N: exactly by Legendre below
+// count.LegendreBelow, else by N killing twistChecks random points.
+func hasCount(c ec.Curve, N *big.Int, rnd io.Reader) (bool, error) {
+	if c.P.Cmp(big.NewInt(count.LegendreBelow)) < 0 {
+		return count.Legendre(c).Cmp(N) == 0, nil
+	}
+	for i := 0; i < twistChecks; i++ {
+		P, err := c.RandomPoint(rnd)
+		if err != nil {
+			return false, err
+		}
+		NP, err := c.ScalarMul(N, P)
+		if err != nil {
+			return false, err
+		}
+		if !NP.Inf {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// writeDOT prints g for Graphviz: an undirected multigraph with one node
+// per j-invariant. Supersingular components are filled; j = 0 and 1728
+// (extra automorphisms) are boxes.
+func writeDOT(g *Graph, w io.Writer) error {
+	ew := &errWriter{w: w}
+	kind := "ordinary"
+	if g.Supersingular {
+		kind = "supersingular"
+	}
+	ew.printf("// %d-isogeny graph over F_%s (%s), #E = %s, trace %s\n", g.L, g.P, g.Method, g.N, g.Trace)
+	ew.printf("// %d curves, %d edges, %s", len(g.Nodes), len(g.Edges), kind)
+	if !g.Complete {
+		ew.printf("; stopped at --max-nodes, the component is larger")
+	}
+	ew.printf("\n")
+	ew.printf("graph isogenies {\n")
+	ew.printf("\tlabel=\"%d-isogenies over F_%s, #E = %s (%s)\";\n", g.L, g.P, g.N, kind)
+	if g.Supersingular {
+		ew.printf("\tnode [style=filled, fillcolor=lightpink];\n")
+	}
+	p, _ := new(big.Int).SetString(g.P, 10)
+	j1728 := ec.Mod(big.NewInt(1728), p).String()
+	for _, n := range g.Nodes { // breadth-first, so by depth
+		shape := ""
+		if n.J == "0" || n.J == j1728 {
+			shape = ", shape=box"
+		}
+		ew.printf("\t%q [label=\"j = %s\\ny^2 = x^3 + %s x + %s\"%s];\n", n.J, n.J, n.A, n.B, shape)
+	}
+	for _, e := range g.Edges {
+		ew.printf("\t%q -- %q", e.From, e.To)
+		if e.Count > 1 {
+			ew.printf(" [label=\"%d\"]", e.Count)
+		}
+		ew.printf(";\n")
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+func parseInt(s, name string) (*big.Int, error) {
+	z, err := ec.ParseBig(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %w", name, err)
+	}
+	return z, nil
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
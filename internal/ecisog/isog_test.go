@@ -0,0 +1,113 @@
+package ecisog
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"ectorus/internal/count"
+	"ectorus/internal/ec"
+)
+
+func walk(t *testing.T, p, A, B int64, l int, method string) *Graph {
+	t.Helper()
+	g, err := Walk(&Config{P: big.NewInt(p), A: big.NewInt(A), B: big.NewInt(B), L: l, Method: method,
+		MaxNodes: 1000, Count: "auto", Seed: 1})
+	if err != nil {
+		t.Fatalf("p=%d A=%d B=%d ℓ=%d %s: %v", p, A, B, l, method, err)
+	}
+	return g
+}
+
+// edgeSet is the undirected edges of g as "j1-j2" with j1 ≤ j2.
+func edgeSet(g *Graph) map[string]bool {
+	out := make(map[string]bool)
+	for _, e := range g.Edges {
+		a, b := e.From, e.To
+		if a > b {
+			a, b = b, a
+		}
+		out[a+"-"+b] = true
+	}
+	return out
+}
+
+func TestModularMatchesVelu(t *testing.T) {
+	// every rational 2-isogeny has a rational kernel point, so both
+	// methods see the same 2-isogeny graph
+	for _, c := range [][3]int64{{1009, 2, 3}, {1009, 5, 0}, {1009, 11, 17}, {1019, 3, 8}} {
+		m := walk(t, c[0], c[1], c[2], 2, "modular")
+		v := walk(t, c[0], c[1], c[2], 2, "velu")
+		if len(m.Nodes) != len(v.Nodes) {
+			t.Fatalf("%v: %d nodes by Φ_2, %d by Vélu", c, len(m.Nodes), len(v.Nodes))
+		}
+		me, ve := edgeSet(m), edgeSet(v)
+		if len(me) != len(ve) {
+			t.Fatalf("%v: edges %v by Φ_2, %v by Vélu", c, me, ve)
+		}
+		for e := range me {
+			if !ve[e] {
+				t.Fatalf("%v: edge %s missing under Vélu", c, e)
+			}
+		}
+	}
+}
+
+func TestNodesRealizeJAndCount(t *testing.T) {
+	for _, tc := range []struct {
+		p, A, B       int64
+		l             int
+		method        string
+		supersingular bool
+	}{
+		{101, 2, 26, 5, "velu", false},
+		{1009, 11, 17, 3, "modular", false},
+		{101, 0, 1, 2, "modular", true},
+		{1019, 0, 1, 3, "modular", true},
+	} {
+		g := walk(t, tc.p, tc.A, tc.B, tc.l, tc.method)
+		if g.Supersingular != tc.supersingular {
+			t.Fatalf("p=%d A=%d B=%d: supersingular = %v", tc.p, tc.A, tc.B, g.Supersingular)
+		}
+		if !g.Complete || len(g.Nodes) < 2 {
+			t.Fatalf("p=%d A=%d B=%d ℓ=%d: %d nodes, complete %v", tc.p, tc.A, tc.B, tc.l, len(g.Nodes), g.Complete)
+		}
+		for _, n := range g.Nodes {
+			A, _ := new(big.Int).SetString(n.A, 10)
+			B, _ := new(big.Int).SetString(n.B, 10)
+			c := ec.Curve{P: big.NewInt(tc.p), A: A, B: B}
+			j, err := c.JInvariant()
+			if err != nil || j.String() != n.J {
+				t.Fatalf("node %s: curve has j = %v (%v)", n.J, j, err)
+			}
+			if N := count.Legendre(c); N.String() != g.N {
+				t.Fatalf("node %s: #E = %s, want %s", n.J, N, g.N)
+			}
+		}
+	}
+}
+
+func TestMaxNodesAndDOT(t *testing.T) {
+	g, err := Walk(&Config{P: big.NewInt(1019), A: big.NewInt(0), B: big.NewInt(1), L: 3, Method: "modular",
+		MaxNodes: 3, Count: "auto", Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Complete || len(g.Nodes) != 3 {
+		t.Fatalf("%d nodes, complete %v; want 3 and a cut walk", len(g.Nodes), g.Complete)
+	}
+	var buf bytes.Buffer
+	if err := writeDOT(g, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"graph isogenies {", "supersingular", "fillcolor", `"0" [label="j = 0`, "stopped at --max-nodes"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("DOT output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, " -- ") != len(g.Edges) {
+		t.Fatalf("DOT has %d edges, graph %d", strings.Count(out, " -- "), len(g.Edges))
+	}
+}
@@ -0,0 +1,89 @@
+package ecscan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"runtime"
+)
+
+// ------------------- library API -------------------
+
+// Params is a curve y^2 = x^3 + Ax + B over F_P for Scan and Points.
+type Params struct {
+	P, A, B uint64 // P prime, 3 < P < 2^63; A and B are reduced mod P
+	Mode    Mode   // auto (default), table, hybrid or onthefly, as --mode
+	MaxMem  uint64 // sqrt-table (or bitmap) cap in bytes for auto/table/hybrid, as --max-mem (0 = 48GB)
+	Workers int    // 0 = GOMAXPROCS*4
+	Ordered bool   // deliver points sorted by x, then y (as --ordered)
+}
+
+// defaultMaxMem matches the --max-mem default.
+const defaultMaxMem = 48 << 30
+
+func (prm Params) resolve() (Params, error) {
+	if prm.P <= 3 || prm.P >= 1<<63 || !new(big.Int).SetUint64(prm.P).ProbablyPrime(32) {
+		return prm, fmt.Errorf("ecscan: p = %d is not a prime in (3, 2^63)", prm.P)
+	}
+	prm.A, prm.B = prm.A%prm.P, prm.B%prm.P
+	if prm.Mode == "" {
+		prm.Mode = ModeAuto
+	}
+	if prm.MaxMem == 0 {
+		prm.MaxMem = defaultMaxMem
+	}
+	if prm.Workers <= 0 {
+		prm.Workers = runtime.GOMAXPROCS(0) * 4
+	}
+	var err error
+	prm.Mode, err = resolveModeU64(prm.P, prm.Mode, prm.MaxMem)
+	return prm, err
+}
+
+// Scan calls fn for every affine point of the curve, with the same worker
+// pool as the CLI. Points come in no particular order unless prm.Ordered
+// is set, but fn is only ever called from one goroutine at a time. O is
+// not passed. Scan returns fn's first error, or ctx.Err() if ctx is done
+// before the scan completes.
+func Scan(ctx context.Context, prm Params, fn func(x, y uint64) error) error {
+	prm, err := prm.resolve()
+	if err != nil {
+		return err
+	}
+	return scanU64(ctx, prm.P, prm.A, prm.B, prm.Mode, nil, prm.Workers, prm.Ordered, pipeSpec{}, []func(PointU64) error{func(pt PointU64) error {
+		return fn(pt.X, pt.Y)
+	}})
+}
+
+// errStopped ends a Scan behind Points whose consumer went away.
+var errStopped = errors.New("ecscan: points consumer stopped")
+
+// Points streams the affine points of the curve on a channel, which is
+// closed when the scan ends. The returned func waits for that and reports
+// the scan's error (nil after a complete scan). A consumer that stops
+// reading early must cancel ctx so the scan can wind down.
+func Points(ctx context.Context, prm Params) (<-chan PointU64, func() error) {
+	ch := make(chan PointU64, 1<<12)
+	done := make(chan struct{})
+	var err error
+	go func() {
+		defer close(done)
+		defer close(ch)
+		err = Scan(ctx, prm, func(x, y uint64) error {
+			select {
+			case ch <- PointU64{X: x, Y: y}:
+				return nil
+			case <-ctx.Done():
+				return errStopped
+			}
+		})
+		if errors.Is(err, errStopped) {
+			err = ctx.Err()
+		}
+	}()
+	return ch, func() error {
+		<-done
+		return err
+	}
+}
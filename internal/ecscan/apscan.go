@@ -0,0 +1,223 @@
+package ecscan
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ectorus/internal/count"
+)
+
+// ------------------- apscan: a_p over many primes -------------------
+
+// APConfig drives cmd/apscan: one curve y^2 = x^3 + Ax + B over ℚ, reduced
+// at every good prime in [From, To].
+type APConfig struct {
+	A, B     *big.Rat
+	From, To uint64
+	OutPath  string      // "-" for stdout
+	Compress Compression // --compress (none|gzip|zstd)
+	Header   bool        // --header: "#" metadata lines before the CSV
+	Workers  int
+}
+
+func ParseAPFlags(args []string) (*APConfig, error) {
+	fs := flag.NewFlagSet("apscan", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	var (
+		AStr     = fs.String("A", "0", "curve parameter A over ℚ (integer or fraction like -3/4)")
+		BStr     = fs.String("B", "0", "curve parameter B over ℚ")
+		from     = fs.Uint64("from", 5, "smallest prime to consider (primes < 5 are always skipped)")
+		to       = fs.Uint64("to", 0, "largest prime to consider (required)")
+		outPath  = fs.String("out", "-", "CSV output path, - for stdout")
+		compress = fs.String("compress", "none", "stream-compress output: none|gzip|zstd")
+		header   = fs.Bool("header", false, "write # metadata lines (A, B, range, timestamp) before the CSV")
+		workers  = fs.Int("workers", 0, "number of workers (default GOMAXPROCS)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	A, ok := new(big.Rat).SetString(strings.TrimSpace(*AStr))
+	if !ok {
+		return nil, fmt.Errorf("invalid rational for --A: %q", *AStr)
+	}
+	B, ok := new(big.Rat).SetString(strings.TrimSpace(*BStr))
+	if !ok {
+		return nil, fmt.Errorf("invalid rational for --B: %q", *BStr)
+	}
+	if *to == 0 {
+		return nil, errors.New("missing required --to")
+	}
+	if *to >= 1<<63 {
+		return nil, errors.New("--to must be < 2^63")
+	}
+	if *from > *to {
+		return nil, fmt.Errorf("empty range: --from %d > --to %d", *from, *to)
+	}
+	comp, err := parseCompression(*compress)
+	if err != nil {
+		return nil, err
+	}
+	w := *workers
+	if w <= 0 {
+		w = runtime.GOMAXPROCS(0)
+	}
+	return &APConfig{A: A, B: B, From: *from, To: *to, OutPath: *outPath, Compress: comp, Header: *header, Workers: w}, nil
+}
+
+// apRow is one CSV record: x = a_p / 2√p lies in [-1, 1] by Hasse, and
+// θ = arccos x is the angle whose distribution Sato–Tate predicts.
+type apRow struct {
+	p      uint64
+	ap     int64
+	x, phi float64
+}
+
+// reduceRat returns r mod p, or ok=false when p divides the denominator.
+func reduceRat(r *big.Rat, p uint64) (uint64, bool) {
+	P := new(big.Int).SetUint64(p)
+	den := new(big.Int).Mod(r.Denom(), P)
+	if den.Sign() == 0 {
+		return 0, false
+	}
+	num := new(big.Int).Mod(r.Num(), P)
+	return num.Mul(num, den.ModInverse(den, P)).Mod(num, P).Uint64(), true
+}
+
+// apAt returns a_p for E mod p, or ok=false when p is a bad prime.
+func apAt(A, B *big.Rat, p uint64) (int64, bool) {
+	a, okA := reduceRat(A, p)
+	b, okB := reduceRat(B, p)
+	if !okA || !okB {
+		return 0, false
+	}
+	// 4A^3 + 27B^2 ≢ 0 mod p
+	P := new(big.Int).SetUint64(p)
+	d := new(big.Int).SetUint64(a)
+	d.Exp(d, big.NewInt(3), P).Mul(d, big.NewInt(4))
+	bb := new(big.Int).SetUint64(b)
+	d.Add(d, bb.Mul(bb, bb).Mul(bb, big.NewInt(27))).Mod(d, P)
+	if d.Sign() == 0 {
+		return 0, false
+	}
+	return int64(p) + 1 - int64(count.LegendreU64(p, a, b)), true
+}
+
+// RunAP counts E mod p for every good prime in range on cfg.Workers
+// goroutines and writes the rows in increasing p through the usual single
+// writer goroutine.
+func RunAP(cfg *APConfig) (err error) {
+	w, closeFn, err := openOutput(cfg.OutPath, cfg.Compress, 0, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := closeFn(); err == nil {
+			err = cerr
+		}
+	}()
+
+	start := time.Now()
+	if cfg.Header {
+		fmt.Fprintf(w, "# A=%s\n# B=%s\n# primes=[%d,%d]\n# timestamp=%s\n",
+			cfg.A.RatString(), cfg.B.RatString(), cfg.From, cfg.To, start.UTC().Format(time.RFC3339))
+	}
+	if _, err := w.WriteString("p,a_p,x,theta\n"); err != nil {
+		return err
+	}
+
+	type job struct {
+		i int
+		p uint64
+	}
+	type result struct {
+		i    int
+		row  apRow
+		good bool
+	}
+	jobs := make(chan job, cfg.Workers*2)
+	results := make(chan result, cfg.Workers*2)
+
+	var wg sync.WaitGroup
+	for k := 0; k < cfg.Workers; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for jb := range jobs {
+				ap, ok := apAt(cfg.A, cfg.B, jb.p)
+				r := result{i: jb.i, good: ok}
+				if ok {
+					x := float64(ap) / (2 * math.Sqrt(float64(jb.p)))
+					r.row = apRow{p: jb.p, ap: ap, x: x, phi: math.Acos(math.Max(-1, math.Min(1, x)))}
+				}
+				results <- r
+			}
+		}()
+	}
+
+	// writer: results arrive out of order, so hold them until their turn
+	var good, bad int
+	var werr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pending := map[int]result{}
+		next := 0
+		for r := range results {
+			pending[r.i] = r
+			for {
+				r, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				if !r.good {
+					bad++
+					continue
+				}
+				good++
+				if werr == nil {
+					_, werr = w.WriteString(formatAPRow(r.row))
+				}
+			}
+		}
+	}()
+
+	i := 0
+	lo := cfg.From
+	if lo < 5 {
+		lo = 5
+	}
+	for n := lo; n <= cfg.To; n++ {
+		if !new(big.Int).SetUint64(n).ProbablyPrime(0) {
+			continue
+		}
+		jobs <- job{i: i, p: n}
+		i++
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+	<-done
+	if werr != nil {
+		return werr
+	}
+	log.Printf("apscan: %d good primes, %d bad, in %v", good, bad, time.Since(start))
+	return nil
+}
+
+func formatAPRow(r apRow) string {
+	return strconv.FormatUint(r.p, 10) + "," + strconv.FormatInt(r.ap, 10) + "," +
+		strconv.FormatFloat(r.x, 'f', 6, 64) + "," + strconv.FormatFloat(r.phi, 'f', 6, 64) + "\n"
+}
@@ -0,0 +1,124 @@
+package ecscan
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ectorus/internal/count"
+)
+
+// ------------------- families: --B-range and --count-only -------------------
+
+// runBRange scans y^2 = x^3 + Ax + b for every b in [bFrom, bTo], writing a
+// summary line per curve. The sqrt table (or hybrid mode's residue bitmap)
+// depends only on p, so it is built once (unless passed in as table) and
+// shared by every curve. With countOnly no points are written; otherwise
+// out.Path has "{B}" replaced by b for each curve.
+func runBRange(p, A, bFrom, bTo uint64, mode Mode, maxMem uint64, table any, out outputSpec, countOnly bool, workers int, sumW io.Writer) error {
+	if table == nil {
+		var err error
+		if table, err = tableFor(p, mode, workers); err != nil {
+			return err
+		}
+	}
+	P, bigA := new(big.Int).SetUint64(p), new(big.Int).SetUint64(A)
+	for b := bFrom; ; b++ {
+		start := time.Now()
+		bb := b % p
+		bigB := new(big.Int).SetUint64(bb)
+		if singularU64(p, A, bb) {
+			log.Printf("B=%d: singular curve, skipped", b)
+		} else {
+			var n uint64
+			var err error
+			if countOnly {
+				n = countU64(p, A, bb, mode, table, workers)
+			} else {
+				o := out
+				o.Path = strings.ReplaceAll(out.Path, "{B}", strconv.FormatUint(b, 10))
+				n, err = enumerateU64(p, A, bb, mode, maxMem, o, workers, nil, nil, table)
+			}
+			if err != nil {
+				return err
+			}
+			if out.Verify {
+				if err := verifyCount(p, A, bb, 1, n, out.VerifyMax); err != nil {
+					return fmt.Errorf("B=%d: %w", b, err)
+				}
+			}
+			if err := newSummary(P, bigA, bigB, 1, mode, n, time.Since(start)).report(sumW); err != nil {
+				return err
+			}
+		}
+		if b == bTo {
+			return nil
+		}
+	}
+}
+
+// singularU64 reports whether 4A^3 + 27B^2 ≡ 0 mod p.
+func singularU64(p, A, B uint64) bool {
+	m := mod64{p}
+	return m.add(m.mul(4, m.mul(A, m.mul(A, A))), m.mul(27, m.mul(B, B))) == 0
+}
+
+// countU64 returns the number of affine points without emitting them:
+// table or bitmap lookups in table and hybrid mode, the count package's
+// Legendre scan otherwise.
+func countU64(p, A, B uint64, mode Mode, table any, workers int) uint64 {
+	mt := newMont64(p)
+	AM, BM := mt.to(A%p), mt.to(B%p)
+	oneA := mt.add(mt.one, AM)
+	var total atomic.Uint64
+	var wg sync.WaitGroup
+	chunk := (p + uint64(workers) - 1) / uint64(workers)
+	for lo := uint64(0); lo < p; lo += chunk {
+		hi := min(lo+chunk, p)
+		wg.Add(1)
+		go func(lo, hi uint64) {
+			defer wg.Done()
+			if mode != ModeTable && mode != ModeHybrid {
+				total.Add(count.LegendreRangeU64(p, A, B, lo, hi))
+				return
+			}
+			var n uint64
+			x := mt.to(lo)
+			x2 := mt.mul(x, x)
+			f := mt.add(mt.add(mt.mul(x2, x), mt.mul(AM, x)), BM)
+			for range hi - lo {
+				var y uint64
+				var ok bool
+				r := mt.from(f)
+				switch T := table.(type) {
+				case []uint32:
+					y, ok = uint64(T[r]), T[r] != ^uint32(0)
+				case []uint64:
+					y, ok = T[r], T[r] != ^uint64(0)
+				case residueBitmap:
+					y, ok = r, r == 0 || T.has(r) // only y ≠ 0 matters
+				}
+				if ok {
+					n++
+					if y != 0 {
+						n++
+					}
+				}
+				// delta = 3(x^2 + x) + 1 + A, in Montgomery form as in scanU64
+				t := mt.add(x2, x)
+				f = mt.add(f, mt.add(mt.add(t, mt.add(t, t)), oneA))
+				x2 = mt.add(x2, mt.add(mt.add(x, x), mt.one))
+				x = mt.add(x, mt.one)
+			}
+			total.Add(n)
+		}(lo, hi)
+	}
+	wg.Wait()
+	return total.Load()
+}
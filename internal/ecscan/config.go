@@ -4,11 +4,14 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"math/big"
 	"os"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
+
+	"ectorus/internal/ec"
+	"ectorus/internal/prof"
 )
 
 type Mode string
@@ -17,19 +20,70 @@ const (
 	ModeAuto     Mode = "auto"
 	ModeTable    Mode = "table"
 	ModeOnTheFly Mode = "onthefly"
+	// ModeHybrid keeps a p-bit residue bitmap instead of the sqrt table and
+	// runs Tonelli–Shanks only on the residues.
+	ModeHybrid Mode = "hybrid"
+)
+
+// Field is the coordinate field: F_p (and F_{p^2} with --ext 2) or GF(2^m).
+type Field string
+
+const (
+	FieldFp   Field = "fp"
+	FieldGF2m Field = "gf2m"
 )
 
 type Config struct {
-	P       string // decimal strings for generality
-	A       string
-	B       string
-	Mode    Mode
-	MaxMem  string // e.g. "48GB"
-	OutPath string // "-" for stdout
-	Workers int    // 0 => default
-	Vis     bool   // --vis
-	VisMax  int    // --vis-max
-	VisMode string // --vis-mode (auto|fail)
+	P         string // decimal strings for generality (Run also takes 0x-hex and expressions)
+	A         string
+	B         string
+	Mode      Mode
+	MaxMem    string      // e.g. "48GB"
+	OutPath   string      // "-" for stdout
+	Format    Format      // --format (text|csv|ndjson)
+	Coords    Coords      // --coords (affine|projective|jacobian)
+	Header    bool        // --header: metadata record before points
+	Compress  Compression // --compress (none|gzip|zstd)
+	Ext       int         // --ext: 1 = F_p, 2 = F_{p^2}
+	Field     Field       // --field: fp, or gf2m (P = "2", A and B bit masks)
+	Poly      uint64      // --poly: GF(2^m) reduction polynomial, bit i = z^i
+	Summary   string      // --summary: JSON summary path, "-" for stderr
+	BRange    bool        // --B-range given: scan B in [BFrom, BTo]
+	BFrom     uint64
+	BTo       uint64
+	CountOnly bool // --count-only: summaries, no points
+	// CountMethod is --count-method: "" counts by the scan's lookups,
+	// CountLegendreSum by a parallel character sum (implies CountOnly).
+	CountMethod string
+	// Checkpoint, CheckpointEvery: --checkpoint and --checkpoint-every, the
+	// legendre-sum partial-sum file and how often it is saved
+	Checkpoint      string
+	CheckpointEvery time.Duration
+	Workers         int // 0 => default
+	// WorkersAuto is --workers auto: Run calibrates the worker count on the
+	// p < 2^63 scan, with Workers as the fallback.
+	WorkersAuto bool
+	Vis         bool   // --vis
+	VisMax      int    // --vis-max
+	VisMode     string // --vis-mode (auto|fail)
+	VisPNG      string // --vis-png: density heatmap path
+	VisPNGW     int    // --vis-png-size
+	VisPNGH     int
+	Metrics     string // --metrics-addr: serve Prometheus metrics here
+	Ordered     bool   // --ordered: points sorted by x, then y
+	Verify      bool   // --verify: on-curve check per point, count cross-check
+	VerifyMax   uint64 // --verify-count-max
+	SplitSize   uint64 // --split-size: bytes per output part, 0 = one file
+	SplitEvery  uint64 // --split-every: x values per output part, 0 = off
+	// --writer-buffer, --worker-batch, --point-chan-size: the output buffer
+	// in bytes, points per worker batch, batches in flight (0 = 2 per worker)
+	WriterBuffer  int
+	WorkerBatch   int
+	PointChanSize int
+	Writers       int  // --writers: writer goroutines, each with its own part file
+	MergeWriters  bool // --merge-writers: merge those parts into --out
+	// --cpuprofile, --memprofile, --trace: Go profiles of the run
+	Profile prof.Files
 }
 
 func ParseFlags(args []string) (*Config, error) {
@@ -37,44 +91,242 @@ func ParseFlags(args []string) (*Config, error) {
 	fs.SetOutput(os.Stderr)
 
 	var (
-		pStr      = fs.String("p", "", "prime modulus p (decimal string, required)")
-		AStr      = fs.String("A", "0", "curve parameter A (decimal)")
-		BStr      = fs.String("B", "0", "curve parameter B (decimal)")
-		modeStr   = fs.String("mode", "auto", "mode: auto|table|onthefly")
-		maxMemStr = fs.String("max-mem", "48GB", "memory cap for auto/table (e.g. 48GB, 500MB)")
-		outPath   = fs.String("out", "-", "output file path, or - for stdout")
-		workers   = fs.Int("workers", 0, "number of workers (default GOMAXPROCS*4)")
+		pStr      = fs.String("p", "", "prime modulus p: decimal, 0x-hex or an expression like 2^61-1 (required unless --curve)")
+		AStr      = fs.String("A", "0", "curve parameter A, as for --p; negative values are taken mod p")
+		BStr      = fs.String("B", "0", "curve parameter B, as for --p; negative values are taken mod p")
+		curveName = fs.String("curve", "", "named curve for p, A and B: "+strings.Join(ec.CurveNames(), "|")+" (Montgomery and Edwards curves are converted to short Weierstrass form)")
+		modeStr   = fs.String("mode", "auto", "mode: auto|table|hybrid|onthefly (hybrid: p/8-byte residue bitmap, sqrt on demand)")
+		maxMemStr = fs.String("max-mem", "48GB", "memory cap for auto/table/hybrid (e.g. 48GB, 500MB)")
+		outPath   = fs.String("out", "-", "output file path, - for stdout, or sqlite:FILE.db")
+		formatStr = fs.String("format", "text", "output format: text|csv|ndjson|compressed (SEC1: x and the parity of y, in hex)|sage|gp (a script that checks the points)")
+		coordsStr = fs.String("coords", "affine", "point coordinates for text|csv|ndjson: affine (x y), or projective|jacobian (x y 1, O as 0 1 0 or 1 1 0)")
+		compress  = fs.String("compress", "none", "stream-compress output: none|gzip|zstd")
+		header    = fs.Bool("header", false, "write a metadata record (p, A, B, mode, timestamp) before the points")
+		ext       = fs.Int("ext", 1, "coordinate field degree: 1 = F_p, 2 = F_{p^2} (on-the-fly, p < 2^32)")
+		fieldStr  = fs.String("field", "fp", "coordinate field: fp, or gf2m for y^2 + xy = x^3 + Ax^2 + B over GF(2)[z]/(--poly)")
+		polyStr   = fs.String("poly", "", "with --field gf2m: irreducible reduction polynomial of degree m ≤ 32, e.g. z^4+z+1 or 0x13; --A/--B are elements in the same notation")
+		summary   = fs.String("summary", "", "write a JSON summary (count, trace, anomalous/supersingular/prime-order flags) to this path, - for stderr")
+		bRange    = fs.String("B-range", "", "scan every curve with B in start:end (inclusive), sharing one sqrt table; --out may contain {B}")
+		countOnly = fs.Bool("count-only", false, "only count points (summary per curve), write no points")
+		countWith = fs.String("count-method", "scan", "how --count-only counts: scan (the mode's lookups) or legendre-sum (N = p+1+Σχ(x^3+Ax+B) over all workers, no table; implies --count-only)")
+		ckPath    = fs.String("checkpoint", "", "with --count-method legendre-sum: save the partial sum to this file, and resume from it if it exists")
+		ckEvery   = fs.Duration("checkpoint-every", time.Minute, "how often --checkpoint is saved")
+		workersS  = fs.String("workers", "", "number of workers (default GOMAXPROCS*4), or auto to time a few counts on the start of the scan and take the fastest")
 		vis       = fs.Bool("vis", false, "render ASCII visualization to stdout after run")
 		visMax    = fs.Int("vis-max", 120, "max grid width/height for -vis")
 		visMode   = fs.String("vis-mode", "auto", "auto|fail: downsample to fit, or fail if exact grid > vis-max")
+		visPNG    = fs.String("vis-png", "", "write a PNG heatmap of point density to this path")
+		metrics   = fs.String("metrics-addr", "", "serve Prometheus metrics at http://ADDR/metrics during the scan, e.g. :9090")
+		visPNGSz  = fs.String("vis-png-size", "1024x1024", "WxH of the --vis-png image (smaller for p below it)")
+		ordered   = fs.Bool("ordered", false, "emit points sorted by x, then y (chunks are merged in x order)")
+		verify    = fs.Bool("verify", false, "check y^2 = x^3+Ax+B for every point before writing it, and cross-check the final count; exit nonzero on a mismatch")
+		verifyMax = fs.Uint64("verify-count-max", 1<<30, "largest p whose count --verify cross-checks against a Legendre sum (0: never)")
+		splitSize = fs.String("split-size", "", "split the output into numbered parts of about this size before compression (e.g. 1GB); --out may contain {part}")
+		splitX    = fs.Uint64("split-every", 0, "split the output into numbered parts of N consecutive x values each (implies --ordered)")
+		writerBuf = fs.String("writer-buffer", "4MB", "bytes buffered in front of the output (and compressor) between writes, 4KB to 1GB")
+		batch     = fs.Int("worker-batch", pointBatch, "points each worker gathers locally before handing them to the writer as one batch")
+		chanSize  = fs.Int("point-chan-size", 0, "batches the channel from the workers to the writer holds (0: 2 per worker)")
+		writers   = fs.Int("writers", 1, "output writer goroutines; writer i owns every N-th chunk and writes its own part of --out (numbered as with --split-size)")
+		mergeW    = fs.Bool("merge-writers", false, "with --writers: write the parts to temporary files and merge them into --out at the end")
+		cpuProf   = fs.String("cpuprofile", "", "write a CPU profile of the run to this file (go tool pprof)")
+		memProf   = fs.String("memprofile", "", "write a heap profile to this file at the end of the run (go tool pprof)")
+		traceOut  = fs.String("trace", "", "write an execution trace of the run to this file (go tool trace)")
 	)
 
 	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
+	profile := prof.Files{CPU: *cpuProf, Mem: *memProf, Trace: *traceOut}
+	workers, workersAuto := 0, false
+	switch s := strings.ToLower(strings.TrimSpace(*workersS)); s {
+	case "", "0":
+	case "auto":
+		workersAuto = true
+	default:
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("bad --workers %q (want a count or auto)", *workersS)
+		}
+		workers = n
+	}
+	wbuf, err := parseBytes(*writerBuf)
+	if err != nil || wbuf < 4<<10 || wbuf > 1<<30 {
+		return nil, fmt.Errorf("bad --writer-buffer %q (want 4KB to 1GB)", *writerBuf)
+	}
+	if *batch < 1 {
+		return nil, fmt.Errorf("bad --worker-batch %d (want at least 1)", *batch)
+	}
+	if *chanSize < 0 {
+		return nil, fmt.Errorf("bad --point-chan-size %d (want 0 for the default, or more)", *chanSize)
+	}
+	switch Field(strings.ToLower(strings.TrimSpace(*fieldStr))) {
+	case FieldFp:
+		if *polyStr != "" {
+			return nil, errors.New("--poly needs --field gf2m")
+		}
+	case FieldGF2m:
+		cfg, err := parseGF2mFlags(fs, *polyStr, *AStr, *BStr, *outPath, *formatStr, *compress, *summary, *metrics, *header, *verify, workers)
+		if err != nil {
+			return nil, err
+		}
+		cfg.WriterBuffer, cfg.WorkerBatch, cfg.PointChanSize = int(wbuf), *batch, *chanSize
+		cfg.WorkersAuto = workersAuto
+		cfg.Profile = profile
+		return cfg, nil
+	default:
+		return nil, fmt.Errorf("bad --field %q (want fp|gf2m)", *fieldStr)
+	}
+	if *curveName != "" {
+		var given []string
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "p" || f.Name == "A" || f.Name == "B" {
+				given = append(given, "--"+f.Name)
+			}
+		})
+		if len(given) > 0 {
+			return nil, fmt.Errorf("--curve sets p, A and B; drop %s", strings.Join(given, " "))
+		}
+		nc, err := ec.LookupCurve(*curveName)
+		if err != nil {
+			return nil, fmt.Errorf("--curve: %w", err)
+		}
+		c, err := nc.Weierstrass()
+		if err != nil {
+			return nil, fmt.Errorf("--curve %s: %w", nc.Name, err)
+		}
+		*pStr, *AStr, *BStr = c.P.String(), c.A.String(), c.B.String()
+	}
 	if strings.TrimSpace(*pStr) == "" {
-		return nil, errors.New("missing required --p")
+		return nil, errors.New("missing required --p (or --curve)")
 	}
 
 	mode, err := parseMode(*modeStr)
 	if err != nil {
 		return nil, err
 	}
-	// Validate parseability early (friendlier errors)
-	if _, ok := new(big.Int).SetString(*pStr, 10); !ok {
-		return nil, fmt.Errorf("invalid integer for --p: %q", *pStr)
+	// Validate parseability early (friendlier errors), and keep decimal
+	var pab [3]string
+	for i, f := range []struct{ s, name string }{{*pStr, "p"}, {*AStr, "A"}, {*BStr, "B"}} {
+		z, err := parseBigArg(f.s, f.name)
+		if err != nil {
+			return nil, err
+		}
+		pab[i] = z.String()
+	}
+	format, err := parseFormat(*formatStr)
+	if err != nil {
+		return nil, err
+	}
+	comp, err := parseCompression(*compress)
+	if err != nil {
+		return nil, err
+	}
+	if isSQLitePath(*outPath) && (format != FormatText || comp != CompressNone) {
+		return nil, errors.New("--format and --compress do not apply to a sqlite: output")
+	}
+	if *ext != 1 && *ext != 2 {
+		return nil, fmt.Errorf("bad --ext %d (want 1 or 2)", *ext)
+	}
+	coords, err := parseCoords(*coordsStr)
+	if err != nil {
+		return nil, err
+	}
+	if coords.triple() && (*ext != 1 || isSQLitePath(*outPath) || format != FormatText && format != FormatCSV && format != FormatNDJSON) {
+		return nil, fmt.Errorf("--coords %s applies to --format text|csv|ndjson over F_p, not --ext 2 or a sqlite: output", coords)
+	}
+	if *ext == 2 && (*vis || isSQLitePath(*outPath) || format == FormatCompressed || format == FormatSage || format == FormatGP) {
+		return nil, errors.New("--ext 2 does not support --vis, a sqlite: output or --format compressed|sage|gp")
+	}
+	var bFrom, bTo uint64
+	if *bRange != "" {
+		lo, hi, ok := strings.Cut(*bRange, ":")
+		var err1, err2 error
+		bFrom, err1 = strconv.ParseUint(strings.TrimSpace(lo), 10, 64)
+		bTo, err2 = strconv.ParseUint(strings.TrimSpace(hi), 10, 64)
+		if !ok || err1 != nil || err2 != nil || bFrom > bTo {
+			return nil, fmt.Errorf("bad --B-range %q (want start:end with start ≤ end)", *bRange)
+		}
+		if *ext != 1 || *vis {
+			return nil, errors.New("--B-range does not support --ext 2 or --vis")
+		}
+		if !*countOnly && bFrom != bTo && !isSQLitePath(*outPath) && !strings.Contains(*outPath, "{B}") {
+			return nil, errors.New("--B-range writes one output per curve: put {B} in --out, use a sqlite: output, or pass --count-only")
+		}
+	}
+	var splitBytes uint64
+	if *splitSize != "" {
+		if splitBytes, err = parseBytes(*splitSize); err != nil || splitBytes == 0 {
+			return nil, fmt.Errorf("bad --split-size %q", *splitSize)
+		}
 	}
-	if _, ok := new(big.Int).SetString(*AStr, 10); !ok {
-		return nil, fmt.Errorf("invalid integer for --A: %q", *AStr)
+	if splitBytes > 0 || *splitX > 0 {
+		switch {
+		case splitBytes > 0 && *splitX > 0:
+			return nil, errors.New("--split-size and --split-every are exclusive")
+		case *outPath == "-" || isSQLitePath(*outPath):
+			return nil, errors.New("--split-size/--split-every need --out to name a file")
+		case *countOnly:
+			return nil, errors.New("--count-only writes no points to split")
+		}
+		// x ranges are cut in x order
+		*ordered = *ordered || *splitX > 0
 	}
-	if _, ok := new(big.Int).SetString(*BStr, 10); !ok {
-		return nil, fmt.Errorf("invalid integer for --B: %q", *BStr)
+	if *ordered && *ext != 1 {
+		return nil, errors.New("--ordered and --split-every do not support --ext 2")
+	}
+	countMethod := strings.ToLower(strings.TrimSpace(*countWith))
+	switch countMethod {
+	case "scan":
+		countMethod = ""
+		if *ckPath != "" {
+			return nil, errors.New("--checkpoint needs --count-method legendre-sum")
+		}
+	case CountLegendreSum:
+		if *bRange != "" || *ordered || splitBytes > 0 || *writers > 1 || isSQLitePath(*outPath) {
+			return nil, errors.New("--count-method legendre-sum counts one curve and writes no points: not with --B-range, --ordered, --split-*, --writers or a sqlite: output")
+		}
+		if *ckEvery <= 0 {
+			return nil, fmt.Errorf("bad --checkpoint-every %v (want a positive duration)", *ckEvery)
+		}
+		*countOnly = true
+	default:
+		return nil, fmt.Errorf("bad --count-method %q (want scan|legendre-sum)", *countWith)
+	}
+	if *countOnly && (*ext != 1 || *vis) {
+		return nil, errors.New("--count-only does not support --ext 2 or --vis")
+	}
+	if *writers < 1 {
+		return nil, fmt.Errorf("bad --writers %d (want at least 1)", *writers)
+	}
+	if *mergeW && *writers == 1 {
+		return nil, errors.New("--merge-writers needs --writers > 1")
+	}
+	if *writers > 1 {
+		switch {
+		case *ordered || splitBytes > 0:
+			return nil, errors.New("--writers does not support --ordered or --split-size/--split-every")
+		case *ext != 1 || *vis || *visPNG != "" || *countOnly:
+			return nil, errors.New("--writers does not support --ext 2, --vis, --vis-png or --count-only")
+		case isSQLitePath(*outPath):
+			return nil, errors.New("--writers does not support a sqlite: output")
+		case !*mergeW && *outPath == "-":
+			return nil, errors.New("--writers writes one part per writer: set --out to a file, or pass --merge-writers")
+		case *mergeW && (format == FormatSage || format == FormatGP):
+			return nil, errors.New("--merge-writers cannot merge --format sage|gp scripts")
+		}
+	}
+	pngW, pngH, err := parseSize(*visPNGSz)
+	if err != nil {
+		return nil, fmt.Errorf("--vis-png-size: %v", err)
+	}
+	if *visPNG != "" && (*ext != 1 || *bRange != "" || *countOnly) {
+		return nil, errors.New("--vis-png does not support --ext 2, --B-range or --count-only")
 	}
 	if _, err := parseBytes(*maxMemStr); err != nil {
 		return nil, fmt.Errorf("bad --max-mem: %v", err)
 	}
 
-	w := *workers
+	w := workers
 	if w <= 0 {
 		w = runtime.GOMAXPROCS(0) * 4
 	}
@@ -85,9 +337,73 @@ func ParseFlags(args []string) (*Config, error) {
 	}
 
 	return &Config{
-		P: *pStr, A: *AStr, B: *BStr,
-		Mode: mode, MaxMem: *maxMemStr, OutPath: *outPath, Workers: w,
+		P: pab[0], A: pab[1], B: pab[2],
+		Mode: mode, MaxMem: *maxMemStr, OutPath: *outPath, Workers: w, WorkersAuto: workersAuto,
+		Format: format, Coords: coords, Header: *header, Compress: comp, Ext: *ext, Field: FieldFp, Summary: *summary,
+		BRange: *bRange != "", BFrom: bFrom, BTo: bTo, CountOnly: *countOnly,
+		CountMethod: countMethod, Checkpoint: *ckPath, CheckpointEvery: *ckEvery,
 		Vis: *vis, VisMax: *visMax, VisMode: vm,
+		VisPNG: *visPNG, VisPNGW: pngW, VisPNGH: pngH,
+		Metrics: *metrics, Ordered: *ordered,
+		Verify: *verify, VerifyMax: *verifyMax,
+		SplitSize: splitBytes, SplitEvery: *splitX,
+		WriterBuffer: int(wbuf), WorkerBatch: *batch, PointChanSize: *chanSize,
+		Writers: *writers, MergeWriters: *mergeW,
+		Profile: profile,
+	}, nil
+}
+
+// parseGF2mFlags is the rest of ParseFlags for --field gf2m: the curve is
+// given by --poly, --A and --B, and only the plain point outputs apply.
+func parseGF2mFlags(fs *flag.FlagSet, polyStr, AStr, BStr, outPath, formatStr, compress, summary, metrics string, header, verify bool, workers int) (*Config, error) {
+	var given []string
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "p", "curve", "ext", "mode", "max-mem", "B-range", "count-only", "count-method", "checkpoint", "checkpoint-every", "vis", "vis-max", "vis-mode", "vis-png", "vis-png-size",
+			"ordered", "split-size", "split-every", "verify-count-max", "writers", "merge-writers", "coords":
+			given = append(given, "--"+f.Name)
+		}
+	})
+	if len(given) > 0 {
+		return nil, fmt.Errorf("--field gf2m does not support %s", strings.Join(given, " "))
+	}
+	if strings.TrimSpace(polyStr) == "" {
+		return nil, errors.New("--field gf2m needs --poly")
+	}
+	poly, err := parseGF2Poly(polyStr, "poly")
+	if err != nil {
+		return nil, err
+	}
+	var ab [2]string
+	for i, f := range []struct{ s, name string }{{AStr, "A"}, {BStr, "B"}} {
+		v, err := parseGF2Poly(f.s, f.name)
+		if err != nil {
+			return nil, err
+		}
+		ab[i] = strconv.FormatUint(v, 10)
+	}
+	format, err := parseFormat(formatStr)
+	if err != nil {
+		return nil, err
+	}
+	if format != FormatText && format != FormatCSV && format != FormatNDJSON {
+		return nil, fmt.Errorf("--field gf2m writes text, csv or ndjson, not --format %s", format)
+	}
+	comp, err := parseCompression(compress)
+	if err != nil {
+		return nil, err
+	}
+	if isSQLitePath(outPath) {
+		return nil, errors.New("--field gf2m does not support a sqlite: output")
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0) * 4
+	}
+	return &Config{
+		P: "2", A: ab[0], B: ab[1], Field: FieldGF2m, Poly: poly,
+		Mode: ModeOnTheFly, OutPath: outPath, Workers: workers,
+		Format: format, Header: header, Compress: comp, Ext: 1, Summary: summary,
+		Metrics: metrics, Verify: verify,
 	}, nil
 }
 
@@ -99,6 +415,8 @@ func parseMode(s string) (Mode, error) {
 		return ModeAuto, nil
 	case "table":
 		return ModeTable, nil
+	case "hybrid", "bitmap":
+		return ModeHybrid, nil
 	case "onthefly", "on-the-fly", "fly":
 		return ModeOnTheFly, nil
 	default:
@@ -0,0 +1,207 @@
+package ecscan
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ------------------- F_{p^2} arithmetic (p < 2^32) -------------------
+
+// fp2 is F_{p^2} = F_p[i]/(i^2 - n) for the least quadratic non-residue n,
+// so i^2 - n is irreducible over F_p.
+type fp2 struct {
+	m    mod64
+	n    uint64
+	ninv uint64 // n^-1 mod p
+}
+
+// elt2 is a + b·i.
+type elt2 struct{ a, b uint64 }
+
+// PointExt2 is an affine point of E(F_{p^2}); X = X0 + X1·i, Y = Y0 + Y1·i.
+type PointExt2 struct{ X0, X1, Y0, Y1 uint64 }
+
+func newFp2(p uint64) fp2 {
+	n := uint64(2)
+	for legendre64(n, p) != -1 {
+		n++
+	}
+	m := mod64{p}
+	return fp2{m: m, n: n, ninv: m.pow(n, p-2)}
+}
+
+func (f fp2) add(x, y elt2) elt2 { return elt2{f.m.add(x.a, y.a), f.m.add(x.b, y.b)} }
+
+// mul is Karatsuba: three base-field multiplications instead of four.
+//
+//	(a+bi)(c+di) = (ac + n·bd) + ((a+b)(c+d) - ac - bd)·i
+func (f fp2) mul(x, y elt2) elt2 {
+	m := f.m
+	ac := m.mul(x.a, y.a)
+	bd := m.mul(x.b, y.b)
+	mid := m.mul(m.add(x.a, x.b), m.add(y.a, y.b))
+	return elt2{m.add(ac, m.mul(f.n, bd)), m.sub(m.sub(mid, ac), bd)}
+}
+
+// scale multiplies by a base-field scalar.
+func (f fp2) scale(k uint64, x elt2) elt2 { return elt2{f.m.mul(k, x.a), f.m.mul(k, x.b)} }
+
+// norm is N(a+bi) = a^2 - n·b^2; a nonzero element is a square in F_{p^2}
+// iff its norm is a square in F_p.
+func (f fp2) norm(x elt2) uint64 {
+	return f.m.sub(f.m.mul(x.a, x.a), f.m.mul(f.n, f.m.mul(x.b, x.b)))
+}
+
+// sqrt returns some y with y^2 = x, or ok=false if x is a non-square.
+func (f fp2) sqrt(x elt2) (elt2, bool) {
+	m, p := f.m, f.m.p
+	if x.b == 0 {
+		// Every element of F_p is a square in F_{p^2}.
+		if x.a == 0 || legendre64(x.a, p) == 1 {
+			return elt2{tonelli64(x.a, p), 0}, true
+		}
+		// (c·i)^2 = n·c^2 = a  =>  c = sqrt(a/n)
+		return elt2{0, tonelli64(m.mul(x.a, f.ninv), p)}, true
+	}
+	N := f.norm(x)
+	if legendre64(N, p) != 1 {
+		return elt2{}, false
+	}
+	// (x0 + x1·i)^2 = a + b·i  <=>  x0^2 = (a ± sqrt(N))/2, x1 = b/(2·x0)
+	half := (p + 1) / 2
+	d := tonelli64(N, p)
+	t := m.mul(m.add(x.a, d), half)
+	if legendre64(t, p) != 1 {
+		t = m.mul(m.sub(x.a, d), half)
+	}
+	x0 := tonelli64(t, p)
+	x1 := m.mul(x.b, m.pow(m.add(x0, x0), p-2))
+	return elt2{x0, x1}, true
+}
+
+// ------------------- enumeration: E(F_{p^2}) -------------------
+
+// enumerateExt2 lists the affine points of E(F_{p^2}) for a curve with
+// coefficients in F_p, reusing the job/batch/writer pipeline of
+// enumerateU64. Square roots are always computed on the fly. It returns
+// the number of affine points written.
+func enumerateExt2(p, A, B uint64, out outputSpec, workers int) (n uint64, err error) {
+	if p >= 1<<32 {
+		return 0, fmt.Errorf("--ext 2 needs p < 2^32 (p^2 field elements are scanned)")
+	}
+	meta := runMeta{
+		P: strconv.FormatUint(p, 10), A: strconv.FormatUint(A, 10), B: strconv.FormatUint(B, 10),
+		Mode: ModeOnTheFly, Ext: 2, Timestamp: time.Now().UTC(),
+	}
+	w, closeFn, err := newPointWriter(out, meta)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := closeFn(); err == nil {
+			err = cerr
+		}
+	}()
+
+	f := newFp2(p)
+	log.Printf("EXT2 p=%d A=%d B=%d i^2=%d workers=%d", p, A, B, f.n, workers)
+
+	type job struct{ x0, x1 uint64 } // range of the real part of x
+	jobs := make(chan job, workers*2)
+	batches := make(chan []PointExt2, out.Pipe.chanSize(workers))
+	g, gctx := errgroup.WithContext(context.Background())
+
+	// writer; n counts the affine points written
+	g.Go(func() error {
+		for pts := range batches {
+			for _, pt := range pts {
+				if out.Verify {
+					if err := verifyPointExt2(f, A, B, pt); err != nil {
+						return err
+					}
+				}
+				if err := w.WriteExt2(pt); err != nil {
+					return fmt.Errorf("write error: %w", err)
+				}
+				n++
+			}
+			metrics.emitted(len(pts))
+		}
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	bElt := elt2{B % p, 0}
+	worker := func() error {
+		defer wg.Done()
+		bt := newBatcher(batches, gctx.Done(), out.Pipe.batch())
+		send := bt.add
+		for jb := range jobs {
+			t0 := time.Now()
+			for a := jb.x0; a < jb.x1; a++ {
+				for b := uint64(0); b < p; b++ {
+					x := elt2{a, b}
+					// f = x^3 + A*x + B
+					rhs := f.add(f.add(f.mul(f.mul(x, x), x), f.scale(A, x)), bElt)
+					if rhs == (elt2{}) {
+						if !send(PointExt2{X0: a, X1: b}) {
+							return nil
+						}
+						continue
+					}
+					y, ok := f.sqrt(rhs)
+					if !ok {
+						continue
+					}
+					if !send(PointExt2{X0: a, X1: b, Y0: y.a, Y1: y.b}) ||
+						!send(PointExt2{X0: a, X1: b, Y0: (p - y.a) % p, Y1: (p - y.b) % p}) {
+						return nil
+					}
+				}
+			}
+			if !bt.flush() {
+				return nil
+			}
+			metrics.chunkDone(time.Since(t0))
+		}
+		return nil
+	}
+	metrics.scanStarted(strconv.FormatUint(p, 10), strconv.FormatUint(A, 10), strconv.FormatUint(B, 10), workers,
+		func() int { return len(batches) }, func() int { return cap(batches) })
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		g.Go(worker)
+	}
+	g.Go(func() error {
+		wg.Wait()
+		close(batches)
+		return nil
+	})
+
+	g.Go(func() error {
+		defer close(jobs)
+		chunk := (p + 1023) / 1024
+		for s := uint64(0); s < p; s += chunk {
+			select {
+			case jobs <- job{x0: s, x1: min(s+chunk, p)}:
+			case <-gctx.Done():
+				return nil
+			}
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return n, err
+	}
+
+	if err := w.WriteInf(); err != nil {
+		return n, err
+	}
+	return n, w.WriteFooter(n)
+}
@@ -0,0 +1,406 @@
+package ecscan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ------------------- GF(2^m) arithmetic (m ≤ 32) -------------------
+
+// maxGF2m bounds the degree: elements are uint64 bit masks and a
+// carry-less product of two of them must fit in 64 bits.
+const maxGF2m = 32
+
+// gf2m is GF(2^m) = GF(2)[z]/(f) in polynomial basis: an element is a bit
+// mask, bit i the coefficient of z^i.
+type gf2m struct {
+	m      int
+	f      uint64          // reduction polynomial, bit m set
+	trMask uint64          // bit i set iff Tr(z^i) = 1, so Tr is a parity
+	solve  [maxGF2m]uint64 // w_i with w^2 + w = c solved by XOR of w_i over the bits of c
+}
+
+// clmul is the carry-less (GF(2)[z]) product of a and b.
+func clmul(a, b uint64) uint64 {
+	var r uint64
+	for ; b != 0; b &= b - 1 {
+		r ^= a << bits.TrailingZeros64(b)
+	}
+	return r
+}
+
+// polyMod2 is a mod b in GF(2)[z], b ≠ 0.
+func polyMod2(a, b uint64) uint64 {
+	db := bits.Len64(b)
+	for da := bits.Len64(a); da >= db; da = bits.Len64(a) {
+		a ^= b << (da - db)
+	}
+	return a
+}
+
+func polyGCD2(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, polyMod2(a, b)
+	}
+	return a
+}
+
+func (g gf2m) mul(a, b uint64) uint64 { return polyMod2(clmul(a, b), g.f) }
+func (g gf2m) sqr(a uint64) uint64    { return g.mul(a, a) }
+
+func (g gf2m) pow(a, e uint64) uint64 {
+	r := uint64(1)
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			r = g.mul(r, a)
+		}
+		a = g.sqr(a)
+	}
+	return r
+}
+
+// inv is a^(2^m - 2), i.e. 1/a for a ≠ 0.
+func (g gf2m) inv(a uint64) uint64 { return g.pow(a, 1<<g.m-2) }
+
+// sqrt is a^(2^(m-1)): squaring is a bijection in characteristic 2.
+func (g gf2m) sqrt(a uint64) uint64 {
+	for i := 1; i < g.m; i++ {
+		a = g.sqr(a)
+	}
+	return a
+}
+
+// trace is Tr(a) = a + a^2 + a^4 + ... + a^(2^(m-1)) ∈ GF(2).
+func (g gf2m) trace(a uint64) uint64 {
+	return uint64(bits.OnesCount64(a&g.trMask) & 1)
+}
+
+// quadSolve returns a w with w^2 + w = c, for c of trace 0; the other
+// root is w + 1.
+func (g gf2m) quadSolve(c uint64) uint64 {
+	var w uint64
+	for ; c != 0; c &= c - 1 {
+		w ^= g.solve[bits.TrailingZeros64(c)]
+	}
+	return w
+}
+
+// newGF2m checks that f is irreducible of degree 1..maxGF2m (Rabin's
+// test) and precomputes the trace mask and the solver for w^2 + w = c.
+func newGF2m(f uint64) (gf2m, error) {
+	m := bits.Len64(f) - 1
+	if m < 1 || m > maxGF2m {
+		return gf2m{}, fmt.Errorf("--poly: degree %d outside 1..%d", m, maxGF2m)
+	}
+	g := gf2m{m: m, f: f}
+	// z^(2^k) mod f for k = 0..m
+	frob := make([]uint64, m+1)
+	frob[0] = polyMod2(2, f)
+	for k := 1; k <= m; k++ {
+		frob[k] = g.sqr(frob[k-1])
+	}
+	irreducible := frob[m] == frob[0]
+	for q := 2; irreducible && q <= m; q++ {
+		if m%q == 0 && big.NewInt(int64(q)).ProbablyPrime(0) && polyGCD2(f, frob[m/q]^frob[0]) != 1 {
+			irreducible = false
+		}
+	}
+	if !irreducible {
+		return gf2m{}, fmt.Errorf("--poly %s is not irreducible over GF(2)", formatGF2Poly(f))
+	}
+
+	full := func(a uint64) uint64 { // Tr(a) by its definition
+		t := a
+		for i := 1; i < m; i++ {
+			a = g.sqr(a)
+			t ^= a
+		}
+		return t
+	}
+	for i := 0; i < m; i++ {
+		if full(1<<i) == 1 {
+			g.trMask |= 1 << i
+		}
+	}
+	// w = Σ_{k=0}^{m-2} c^(2^k)·T_k with T_k = Σ_{j=k+1}^{m-1} τ^(2^j) and
+	// Tr(τ) = 1 solves w^2 + w = c whenever Tr(c) = 0 (IEEE 1363, A.4.7).
+	// It is GF(2)-linear in c, so a row per basis element z^i suffices.
+	tau := uint64(1) << bits.TrailingZeros64(g.trMask)
+	T := make([]uint64, m)
+	t := tau
+	for j := 1; j < m; j++ {
+		t = g.sqr(t) // τ^(2^j)
+		for k := 0; k < j; k++ {
+			T[k] ^= t
+		}
+	}
+	for i := 0; i < m; i++ {
+		c, w := uint64(1)<<i, uint64(0)
+		for k := 0; k+1 < m; k++ {
+			w ^= g.mul(c, T[k])
+			c = g.sqr(c)
+		}
+		g.solve[i] = w
+	}
+	return g, nil
+}
+
+// parseGF2Poly reads an element of GF(2)[z]: a sum of powers of one
+// variable ("z^4+z+1", x or t work too) or a bit mask as ec.ParseBig reads
+// it ("0x13", "19"). Repeated terms are an error rather than cancelling.
+func parseGF2Poly(s, name string) (uint64, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	if n, err := parseBigArg(s, name); err == nil || !strings.ContainsAny(strings.TrimPrefix(s, "0x"), "xzt") {
+		if err != nil {
+			return 0, err
+		}
+		v, ok := fitsUint64(n)
+		if !ok {
+			return 0, fmt.Errorf("--%s: %s is not a bit mask of at most 64 bits", name, s)
+		}
+		return v, nil
+	}
+	var f uint64
+	for _, term := range strings.Split(s, "+") {
+		k := 0
+		switch {
+		case term == "1":
+		case len(term) == 1 && strings.ContainsAny(term, "xzt"):
+			k = 1
+		case len(term) > 2 && strings.ContainsAny(term[:1], "xzt") && term[1] == '^':
+			v, err := strconv.Atoi(term[2:])
+			if err != nil || v < 0 || v > 63 {
+				return 0, fmt.Errorf("--%s: bad term %q in %q", name, term, s)
+			}
+			k = v
+		default:
+			return 0, fmt.Errorf("--%s: bad term %q in %q (want e.g. z^4+z+1)", name, term, s)
+		}
+		if f&(1<<k) != 0 {
+			return 0, fmt.Errorf("--%s: term %q repeated in %q", name, term, s)
+		}
+		f |= 1 << k
+	}
+	return f, nil
+}
+
+// formatGF2Poly renders f as a bit mask in hex, the usual notation for
+// binary-field reduction polynomials (0x13 is z^4 + z + 1).
+func formatGF2Poly(f uint64) string { return "0x" + strconv.FormatUint(f, 16) }
+
+// ------------------- enumeration: E(GF(2^m)) -------------------
+
+// verifyPointGF2m checks y^2 + xy = x^3 + ax^2 + b for a point about to be
+// written.
+func verifyPointGF2m(g gf2m, a, b uint64, pt PointU64) error {
+	x, y := pt.X, pt.Y
+	x2 := g.sqr(x)
+	if x>>g.m != 0 || y>>g.m != 0 || g.sqr(y)^g.mul(x, y) != g.mul(x2, x)^g.mul(a, x2)^b {
+		return fmt.Errorf("verify: (%#x, %#x) is not on y^2 + xy = x^3 + %#x x^2 + %#x over GF(2)[z]/(%s)",
+			x, y, a, b, formatGF2Poly(g.f))
+	}
+	return nil
+}
+
+// koblitzCount is #E(GF(2^m)) for y^2 + xy = x^3 + ax^2 + 1 with a ∈ {0, 1}:
+// 2^m + 1 - V_m, V_0 = 2, V_1 = t, V_{k+1} = t·V_k - 2·V_{k-1}, where
+// t = 3 - #E(GF(2)) is (-1)^(1-a).
+func koblitzCount(m int, a uint64) *big.Int {
+	t := big.NewInt(1)
+	if a == 0 {
+		t.SetInt64(-1)
+	}
+	v0, v1 := big.NewInt(2), new(big.Int).Set(t)
+	for k := 1; k < m; k++ {
+		v2 := new(big.Int).Mul(t, v1)
+		v2.Sub(v2, new(big.Int).Lsh(v0, 1))
+		v0, v1 = v1, v2
+	}
+	n := new(big.Int).Lsh(big.NewInt(1), uint(m))
+	n.Add(n, big.NewInt(1))
+	return n.Sub(n, v1)
+}
+
+// enumerateGF2m lists the affine points of y^2 + xy = x^3 + ax^2 + b over
+// GF(2^m), the non-supersingular char-2 Weierstrass form (Koblitz curves
+// have a ∈ {0, 1}, b = 1). x = 0 gives the single point (0, √b). For
+// x ≠ 0, y = xw turns the curve into w^2 + w = x + a + b/x^2, which has
+// two roots w, w+1 when the right side has trace 0 and none otherwise.
+// Points go through the usual writer as bit masks; it returns the number
+// of affine points written.
+func enumerateGF2m(g gf2m, a, b uint64, out outputSpec, workers int) (n uint64, err error) {
+	A, B := strconv.FormatUint(a, 10), strconv.FormatUint(b, 10)
+	meta := runMeta{
+		P: "2", A: A, B: B, Mode: ModeOnTheFly, Ext: g.m, Poly: formatGF2Poly(g.f), Timestamp: time.Now().UTC(),
+	}
+	w, closeFn, err := newPointWriter(out, meta)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := closeFn(); err == nil {
+			err = cerr
+		}
+	}()
+	log.Printf("GF2M m=%d poly=%s a=%#x b=%#x workers=%d", g.m, formatGF2Poly(g.f), a, b, workers)
+
+	type job struct{ x0, x1 uint64 }
+	jobs := make(chan job, workers*2)
+	batches := make(chan []PointU64, out.Pipe.chanSize(workers))
+	grp, gctx := errgroup.WithContext(context.Background())
+
+	grp.Go(func() error {
+		for pts := range batches {
+			for _, pt := range pts {
+				if out.Verify {
+					if err := verifyPointGF2m(g, a, b, pt); err != nil {
+						return err
+					}
+				}
+				if err := w.WriteU64(pt); err != nil {
+					return fmt.Errorf("write error: %w", err)
+				}
+				n++
+			}
+			metrics.emitted(len(pts))
+		}
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	worker := func() error {
+		defer wg.Done()
+		bt := newBatcher(batches, gctx.Done(), out.Pipe.batch())
+		send := bt.add
+		for jb := range jobs {
+			t0 := time.Now()
+			for x := jb.x0; x < jb.x1; x++ {
+				if x == 0 {
+					if !send(PointU64{X: 0, Y: g.sqrt(b)}) {
+						return nil
+					}
+					continue
+				}
+				c := x ^ a ^ g.mul(b, g.sqr(g.inv(x)))
+				if g.trace(c) != 0 {
+					continue
+				}
+				y := g.mul(x, g.quadSolve(c))
+				if !send(PointU64{X: x, Y: y}) || !send(PointU64{X: x, Y: y ^ x}) {
+					return nil
+				}
+			}
+			if !bt.flush() {
+				return nil
+			}
+			metrics.chunkDone(time.Since(t0))
+		}
+		return nil
+	}
+	size := uint64(1) << g.m
+	metrics.scanStarted("2^"+strconv.Itoa(g.m), A, B, workers,
+		func() int { return len(batches) }, func() int { return cap(batches) })
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		grp.Go(worker)
+	}
+	grp.Go(func() error {
+		wg.Wait()
+		close(batches)
+		return nil
+	})
+	grp.Go(func() error {
+		defer close(jobs)
+		chunk := (size + 1023) / 1024
+		for s := uint64(0); s < size; s += chunk {
+			select {
+			case jobs <- job{x0: s, x1: min(s+chunk, size)}:
+			case <-gctx.Done():
+				return nil
+			}
+		}
+		return nil
+	})
+	if err := grp.Wait(); err != nil {
+		return n, err
+	}
+
+	if err := w.WriteInf(); err != nil {
+		return n, err
+	}
+	return n, w.WriteFooter(n)
+}
+
+// runGF2m is Run for --field gf2m.
+func runGF2m(cfg *Config) (err error) {
+	g, err := newGF2m(cfg.Poly)
+	if err != nil {
+		return err
+	}
+	a, err := parseGF2Poly(cfg.A, "A")
+	if err != nil {
+		return err
+	}
+	b, err := parseGF2Poly(cfg.B, "B")
+	if err != nil {
+		return err
+	}
+	if a>>g.m != 0 || b>>g.m != 0 {
+		return fmt.Errorf("--A and --B must have degree below m = %d", g.m)
+	}
+	if b == 0 {
+		return errors.New("--B must be nonzero: y^2 + xy = x^3 + Ax^2 is singular")
+	}
+	if cfg.Metrics != "" {
+		stop, err := serveMetrics(cfg.Metrics)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+	sumW, closeSum, err := openSummary(cfg.Summary)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := closeSum(); err == nil {
+			err = cerr
+		}
+	}()
+
+	if cfg.WorkersAuto {
+		log.Printf("workers auto: --field gf2m is not calibrated, using %d workers", cfg.Workers)
+	}
+	out := outputSpec{Path: cfg.OutPath, Format: cfg.Format, Header: cfg.Header, Compress: cfg.Compress, Verify: cfg.Verify,
+		WriterBuffer: cfg.WriterBuffer, Pipe: pipeSpec{Batch: cfg.WorkerBatch, ChanSize: cfg.PointChanSize}}
+	start := time.Now()
+	n, err := enumerateGF2m(g, a, b, out, cfg.Workers)
+	if err != nil {
+		return err
+	}
+	if cfg.Verify {
+		if a <= 1 && b == 1 {
+			want := koblitzCount(g.m, a)
+			got := new(big.Int).SetUint64(n + 1)
+			if got.Cmp(want) != 0 {
+				return fmt.Errorf("verify: scan found N = %s points but the Koblitz recurrence gives %s", got, want)
+			}
+			log.Printf("verify: N = %s matches the Koblitz recurrence", got)
+		} else {
+			log.Printf("verify: A or B outside GF(2), point count not cross-checked")
+		}
+	}
+	s := newSummary(big.NewInt(2), new(big.Int).SetUint64(a), new(big.Int).SetUint64(b), g.m, ModeOnTheFly, n, time.Since(start))
+	s.Poly = formatGF2Poly(g.f)
+	return s.report(sumW)
+}
@@ -0,0 +1,139 @@
+package ecscan
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// aesPoly is the AES field polynomial z^8 + z^4 + z^3 + z + 1.
+const aesPoly = 0x11b
+
+func TestGF2mField(t *testing.T) {
+	g, err := newGF2m(aesPoly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// FIPS-197 §4.2 and the inverse of its S-box example, {53}^-1 = {ca}
+	for _, tc := range []struct{ a, b, want uint64 }{
+		{0x57, 0x83, 0xc1},
+		{0x57, 0x13, 0xfe},
+		{0x57, 0x02, 0xae},
+		{0x53, 0xca, 0x01},
+	} {
+		if got := g.mul(tc.a, tc.b); got != tc.want {
+			t.Errorf("{%02x}·{%02x} = {%02x}, want {%02x}", tc.a, tc.b, got, tc.want)
+		}
+	}
+	if got := g.inv(0x53); got != 0xca {
+		t.Errorf("{53}^-1 = {%02x}, want {ca}", got)
+	}
+	traceZero := 0
+	for a := uint64(1); a < 1<<8; a++ {
+		if g.mul(a, g.inv(a)) != 1 {
+			t.Fatalf("{%02x}·{%02x}^-1 ≠ 1", a, a)
+		}
+		if g.sqr(g.sqrt(a)) != a {
+			t.Fatalf("sqrt({%02x})^2 ≠ {%02x}", a, a)
+		}
+		if g.trace(a) == 0 {
+			traceZero++
+			if w := g.quadSolve(a); g.sqr(w)^w != a {
+				t.Fatalf("w = {%02x} does not solve w^2 + w = {%02x}", w, a)
+			}
+		}
+	}
+	// Tr is a nonzero linear form: half of GF(2^8), 0 included, has trace 0
+	if traceZero+1 != 1<<7 {
+		t.Errorf("%d elements of trace 0, want %d", traceZero+1, 1<<7)
+	}
+
+	for _, f := range []uint64{
+		0x11,  // z^4 + 1 = (z + 1)^4
+		0x1b,  // z^4 + z^3 + z + 1 = (z + 1)^2 (z^2 + z + 1)
+		0x103, // z^8 + z + 1 = (z^2 + z + 1)(z^6 + z^5 + z^3 + z^2 + 1), no root in GF(2)
+	} {
+		if _, err := newGF2m(f); err == nil {
+			t.Errorf("reducible %#x accepted", f)
+		}
+	}
+}
+
+// TestGF2mCount enumerates small curves over GF(2^5) and checks the points
+// against a brute force over every (x, y), and the Koblitz counts against
+// koblitzCount.
+func TestGF2mCount(t *testing.T) {
+	g, err := newGF2m(0x25) // z^5 + z^2 + 1
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ a, b uint64 }{{0, 1}, {1, 1}, {0x3, 0x11}, {0x1e, 0x7}} {
+		want := map[PointU64]bool{}
+		for x := uint64(0); x < 1<<g.m; x++ {
+			for y := uint64(0); y < 1<<g.m; y++ {
+				x2 := g.sqr(x)
+				if g.sqr(y)^g.mul(x, y) == g.mul(x2, x)^g.mul(c.a, x2)^c.b {
+					want[PointU64{X: x, Y: y}] = true
+				}
+			}
+		}
+		path := filepath.Join(t.TempDir(), "pts.txt")
+		n, err := enumerateGF2m(g, c.a, c.b, outputSpec{Path: path, Format: FormatText, Verify: true}, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := readTextU64(t, path)
+		if n != uint64(len(want)) || len(got) != len(want) {
+			t.Fatalf("a=%#x b=%#x: %d points written (%d read back), brute force %d", c.a, c.b, n, len(got), len(want))
+		}
+		for pt := range got {
+			if !want[pt] {
+				t.Fatalf("a=%#x b=%#x: (%#x, %#x) is not on the curve", c.a, c.b, pt.X, pt.Y)
+			}
+		}
+		if c.b == 1 && c.a <= 1 {
+			if k := koblitzCount(g.m, c.a); k.Uint64() != n+1 {
+				t.Errorf("a=%d: koblitzCount %s, enumerated %d + O", c.a, k, n)
+			}
+		}
+	}
+}
+
+// readTextU64 reads a text dump of uint64 points, which must end in the
+// inf record.
+func readTextU64(t *testing.T, path string) map[PointU64]bool {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	pts, inf := map[PointU64]bool{}, false
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if inf {
+			t.Fatalf("%s: %q after the inf record", path, sc.Text())
+		}
+		if sc.Text() == "inf" {
+			inf = true
+			continue
+		}
+		xs, ys, ok := strings.Cut(sc.Text(), " ")
+		x, errX := strconv.ParseUint(xs, 10, 64)
+		y, errY := strconv.ParseUint(ys, 10, 64)
+		if !ok || errX != nil || errY != nil {
+			t.Fatalf("%s: bad line %q", path, sc.Text())
+		}
+		pts[PointU64{X: x, Y: y}] = true
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !inf {
+		t.Fatalf("%s: no inf record", path)
+	}
+	return pts
+}
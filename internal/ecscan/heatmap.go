@@ -0,0 +1,137 @@
+package ecscan
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/big"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ----------- visualisation (PNG density heatmap) ------------
+
+// heatmap bins points of the p×p torus into a W×H grid of counts. The
+// writer goroutine is its only caller, so it needs no locking.
+type heatmap struct {
+	W, H   int
+	p      uint64   // p < 2^63, or 0 on the big path
+	pBig   *big.Int // big path only
+	counts []uint32
+	t0, t1 big.Int // scratch for the big path
+}
+
+// newHeatmap sizes the image to W×H, or p×p when p is smaller (one pixel
+// per point).
+func newHeatmap(p *big.Int, W, H int) *heatmap {
+	if p.IsInt64() && p.Int64() < int64(W) {
+		W = int(p.Int64())
+	}
+	if p.IsInt64() && p.Int64() < int64(H) {
+		H = int(p.Int64())
+	}
+	h := &heatmap{W: W, H: H, pBig: new(big.Int).Set(p), counts: make([]uint32, W*H)}
+	if pu, ok := fitsUint64(p); ok && pu < 1<<63 {
+		h.p = pu
+	}
+	return h
+}
+
+// scale maps v in [0, p) to floor(v*n/p) without overflow.
+func scaleU64(v uint64, n int, p uint64) int {
+	hi, lo := bits.Mul64(v, uint64(n))
+	q, _ := bits.Div64(hi, lo, p)
+	return int(q)
+}
+
+func (h *heatmap) bump(ix, iy int) {
+	// image row 0 is the top, field y grows up
+	i := (h.H-1-iy)*h.W + ix
+	if h.counts[i] < math.MaxUint32 {
+		h.counts[i]++
+	}
+}
+
+func (h *heatmap) AddU64(x, y uint64) {
+	h.bump(scaleU64(x, h.W, h.p), scaleU64(y, h.H, h.p))
+}
+
+func (h *heatmap) AddBig(x, y *big.Int) {
+	h.bump(h.scaleBig(x, h.W), h.scaleBig(y, h.H))
+}
+
+func (h *heatmap) scaleBig(v *big.Int, n int) int {
+	h.t0.Mul(v, big.NewInt(int64(n)))
+	h.t1.Quo(&h.t0, h.pBig)
+	return int(h.t1.Int64())
+}
+
+// Image colours each pixel by log(1+count) relative to the densest pixel,
+// along a black → purple → orange → yellow ramp; empty pixels stay black.
+func (h *heatmap) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, h.W, h.H))
+	var max uint32
+	for _, c := range h.counts {
+		if c > max {
+			max = c
+		}
+	}
+	den := math.Log1p(float64(max))
+	for i, c := range h.counts {
+		t := 0.0
+		if c > 0 && den > 0 {
+			t = 0.15 + 0.85*math.Log1p(float64(c))/den
+		}
+		img.SetRGBA(i%h.W, i/h.W, ramp(t))
+	}
+	return img
+}
+
+var rampStops = []color.RGBA{
+	{0, 0, 0, 255}, {87, 16, 110, 255}, {188, 55, 84, 255}, {249, 142, 9, 255}, {252, 255, 164, 255},
+}
+
+func ramp(t float64) color.RGBA {
+	if t <= 0 {
+		return rampStops[0]
+	}
+	if t >= 1 {
+		return rampStops[len(rampStops)-1]
+	}
+	f := t * float64(len(rampStops)-1)
+	i := int(f)
+	f -= float64(i)
+	a, b := rampStops[i], rampStops[i+1]
+	mix := func(u, v uint8) uint8 { return uint8(float64(u) + f*(float64(v)-float64(u)) + 0.5) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+func (h *heatmap) WritePNG(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, h.Image()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// parseSize parses "WxH" (or a single N for N×N).
+func parseSize(s string) (int, int, error) {
+	ws, hs, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	if !ok {
+		hs = ws
+	}
+	W, err1 := strconv.Atoi(ws)
+	H, err2 := strconv.Atoi(hs)
+	if err1 != nil || err2 != nil || W < 1 || H < 1 || W*H > 1<<26 {
+		return 0, 0, fmt.Errorf("bad size %q (want WxH, e.g. 1024x1024)", s)
+	}
+	return W, H, nil
+}
@@ -0,0 +1,124 @@
+package ecscan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ------------------- --metrics-addr: Prometheus exposition -------------------
+
+// scanMetrics are the live counters of a scan, served in the Prometheus
+// text format. The enumerators update the package-level instance, which is
+// nil (and every method a no-op) unless --metrics-addr is set.
+type scanMetrics struct {
+	start      time.Time
+	points     atomic.Uint64 // handed to the writer
+	chunks     atomic.Uint64 // x-ranges finished by a worker
+	busyNanos  atomic.Int64  // summed over workers
+	workers    atomic.Int64
+	tableNanos atomic.Int64
+
+	mu    sync.Mutex
+	curve string     // label set of ecscan_scan_info
+	queue func() int // current writer backlog
+	qcap  func() int
+}
+
+var metrics *scanMetrics
+
+func (m *scanMetrics) scanStarted(p, A, B string, workers int, queue, qcap func() int) {
+	if m == nil {
+		return
+	}
+	m.workers.Store(int64(workers))
+	m.mu.Lock()
+	m.curve = fmt.Sprintf(`p=%q,A=%q,B=%q`, p, A, B)
+	m.queue, m.qcap = queue, qcap
+	m.mu.Unlock()
+}
+
+func (m *scanMetrics) chunkDone(busy time.Duration) {
+	if m == nil {
+		return
+	}
+	m.chunks.Add(1)
+	m.busyNanos.Add(int64(busy))
+}
+
+func (m *scanMetrics) emitted(n int) {
+	if m != nil {
+		m.points.Add(uint64(n))
+	}
+}
+
+func (m *scanMetrics) tableBuilt(d time.Duration) {
+	if m != nil {
+		m.tableNanos.Store(int64(d))
+	}
+}
+
+func (m *scanMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.mu.Lock()
+	curve, queue, qcap := m.curve, m.queue, m.qcap
+	m.mu.Unlock()
+	up := time.Since(m.start).Seconds()
+	busy := time.Duration(m.busyNanos.Load()).Seconds()
+	workers := m.workers.Load()
+	util := 0.0
+	if workers > 0 && up > 0 {
+		util = math.Min(1, busy/(float64(workers)*up))
+	}
+	depth, capacity := 0, 0
+	if queue != nil {
+		depth, capacity = queue(), qcap()
+	}
+	metric := func(name, typ, help string, v any) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, typ, name, v)
+	}
+	if curve != "" {
+		fmt.Fprintf(w, "# HELP ecscan_scan_info Curve of the current scan.\n# TYPE ecscan_scan_info gauge\necscan_scan_info{%s} 1\n", curve)
+	}
+	metric("ecscan_points_emitted_total", "counter", "Affine points handed to the writer.", m.points.Load())
+	metric("ecscan_chunks_completed_total", "counter", "x-ranges finished by the workers.", m.chunks.Load())
+	metric("ecscan_workers", "gauge", "Workers in the current scan.", workers)
+	metric("ecscan_worker_busy_seconds_total", "counter", "Time workers spent on x-ranges (including waits on a full writer queue), summed over workers.", busy)
+	metric("ecscan_worker_utilization", "gauge", "Busy share of the workers since metrics were enabled (0..1).", util)
+	metric("ecscan_writer_queue_depth", "gauge", "Point batches waiting for the writers.", depth)
+	metric("ecscan_writer_queue_capacity", "gauge", "Capacity of the writer queue.", capacity)
+	metric("ecscan_table_build_seconds", "gauge", "Time spent building the last sqrt table (0 if none).", time.Duration(m.tableNanos.Load()).Seconds())
+	metric("ecscan_uptime_seconds", "gauge", "Seconds since metrics were enabled.", up)
+}
+
+// serveMetrics enables the package metrics and serves them on addr at
+// /metrics. The returned func shuts the server down.
+func serveMetrics(addr string) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("--metrics-addr: %w", err)
+	}
+	metrics = &scanMetrics{start: time.Now()}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics server: %v", err)
+		}
+	}()
+	log.Printf("serving metrics on http://%s/metrics", ln.Addr())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+		metrics = nil
+	}, nil
+}
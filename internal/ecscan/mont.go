@@ -0,0 +1,130 @@
+package ecscan
+
+import "math/bits"
+
+// ------------------- Montgomery arithmetic (odd p < 2^63) -------------------
+
+// mont64 is arithmetic mod p on Montgomery residues aR mod p, R = 2^64:
+// a product is reduced by REDC (two 64×64 multiplications and an add)
+// instead of the 128/64 division mod64.mul pays. Sums need no conversion,
+// so the finite-difference scan loop runs entirely in this form and only
+// leaves it for a table lookup or an output y.
+type mont64 struct {
+	p    uint64
+	pinv uint64 // -p^-1 mod 2^64
+	r2   uint64 // R^2 mod p, for to
+	one  uint64 // R mod p, i.e. 1
+	// Tonelli–Shanks constants: p - 1 = q·2^s, c = z^q for a non-residue z
+	q uint64
+	s int
+	c uint64
+}
+
+func newMont64(p uint64) mont64 {
+	inv := p // Newton: each step doubles the correct low bits of p^-1
+	for i := 0; i < 5; i++ {
+		inv *= 2 - p*inv
+	}
+	one := -p % p
+	_, r2 := bits.Div64(one, 0, p)
+	mt := mont64{p: p, pinv: -inv, r2: r2, one: one}
+	mt.q, mt.s = p-1, 0
+	for mt.q&1 == 0 {
+		mt.q >>= 1
+		mt.s++
+	}
+	z := mt.add(mt.one, mt.one)
+	for mt.legendre(z) != -1 {
+		z = mt.add(z, mt.one)
+	}
+	mt.c = mt.pow(z, mt.q)
+	return mt
+}
+
+// mul is REDC(a·b) = a·b·R^-1 mod p; with a, b < p < 2^63 the
+// intermediate (ab + mp)/R stays below 2p.
+func (mt mont64) mul(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	mh, ml := bits.Mul64(lo*mt.pinv, mt.p)
+	_, carry := bits.Add64(lo, ml, 0)
+	r, _ := bits.Add64(hi, mh, carry)
+	if r >= mt.p {
+		r -= mt.p
+	}
+	return r
+}
+
+func (mt mont64) add(a, b uint64) uint64 {
+	c := a + b
+	if c >= mt.p {
+		c -= mt.p
+	}
+	return c
+}
+
+func (mt mont64) sub(a, b uint64) uint64 {
+	if a >= b {
+		return a - b
+	}
+	return a + mt.p - b
+}
+
+// to takes a < p into Montgomery form, from brings it back.
+func (mt mont64) to(a uint64) uint64   { return mt.mul(a, mt.r2) }
+func (mt mont64) from(a uint64) uint64 { return mt.mul(a, 1) }
+
+func (mt mont64) pow(a, e uint64) uint64 {
+	r := mt.one
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			r = mt.mul(r, a)
+		}
+		a = mt.mul(a, a)
+	}
+	return r
+}
+
+// legendre is legendre64 on a Montgomery residue: Euler's criterion, with
+// 1 and -1 compared in Montgomery form.
+func (mt mont64) legendre(a uint64) int {
+	if a == 0 {
+		return 0
+	}
+	switch mt.pow(a, (mt.p-1)/2) {
+	case mt.one:
+		return 1
+	case mt.p - mt.one:
+		return -1
+	}
+	return 0
+}
+
+// sqrt is tonelli64 in Montgomery form, for a nonzero residue a, with the
+// non-residue search done once in newMont64.
+func (mt mont64) sqrt(a uint64) uint64 {
+	c := mt.c
+	x := mt.pow(a, (mt.q+1)/2)
+	t := mt.pow(a, mt.q)
+	si := mt.s
+	for t != mt.one {
+		// find i s.t. t^(2^i) = 1
+		i := 1
+		t2i := mt.mul(t, t)
+		for t2i != mt.one {
+			t2i = mt.mul(t2i, t2i)
+			i++
+			if i == si {
+				panic("mont64.sqrt: non-residue")
+			}
+		}
+		b := c
+		for j := 0; j < si-i-1; j++ {
+			b = mt.mul(b, b)
+		}
+		x = mt.mul(x, b)
+		c = mt.mul(b, b)
+		t = mt.mul(t, c)
+		si = i
+	}
+	return x
+}
@@ -0,0 +1,127 @@
+package ecscan
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// prevPrime is the largest prime ≤ n.
+func prevPrime(n *big.Int) *big.Int {
+	p := new(big.Int).Set(n)
+	for !p.ProbablyPrime(32) {
+		p.Sub(p, big.NewInt(1))
+	}
+	return p
+}
+
+// operands are the values each modulus is tested on: the ends of [0, p),
+// the word and half-word boundaries below p, and random ones.
+func operands(P *big.Int, rng *rand.Rand) []*big.Int {
+	var vs []*big.Int
+	for _, d := range []int64{0, 1, 2, 3} {
+		vs = append(vs, big.NewInt(d), new(big.Int).Sub(P, big.NewInt(d+1)))
+	}
+	half := new(big.Int).Rsh(P, 1)
+	vs = append(vs, half, new(big.Int).Add(half, big.NewInt(1)))
+	for _, k := range []uint{31, 32, 63, 64, 65, 96, 126} {
+		w := new(big.Int).Lsh(big.NewInt(1), k)
+		if w.Cmp(P) < 0 {
+			vs = append(vs, w, new(big.Int).Sub(w, big.NewInt(1)))
+		}
+	}
+	for range 40 {
+		vs = append(vs, new(big.Int).Rand(rng, P))
+	}
+	return vs
+}
+
+// TestMont64 checks mont64 against math/big and against mod64, the
+// division-based arithmetic it replaced, up to the largest p below 2^63,
+// where a REDC intermediate (ab + mp)/R comes closest to 2p.
+func TestMont64(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	R := new(big.Int).Lsh(big.NewInt(1), 64)
+	for _, P := range []*big.Int{
+		big.NewInt(10007),
+		prevPrime(big.NewInt(1 << 32)),
+		big.NewInt(1<<32 + 15),
+		big.NewInt(1<<61 - 1),
+		prevPrime(big.NewInt(1 << 62)),
+		prevPrime(big.NewInt(1<<63 - 1)),
+	} {
+		p := P.Uint64()
+		mt, m := newMont64(p), mod64{p}
+		vs := operands(P, rng)
+		for _, a := range vs {
+			au := a.Uint64()
+			if want := new(big.Int).Mod(new(big.Int).Mul(a, R), P).Uint64(); mt.to(au) != want {
+				t.Fatalf("p=%d: to(%d) = %d, want %d", p, au, mt.to(au), want)
+			}
+			if mt.from(mt.to(au)) != au {
+				t.Fatalf("p=%d: from(to(%d)) = %d", p, au, mt.from(mt.to(au)))
+			}
+			for _, b := range vs {
+				bu := b.Uint64()
+				want := new(big.Int).Mod(new(big.Int).Mul(a, b), P).Uint64()
+				if got := mt.from(mt.mul(mt.to(au), mt.to(bu))); got != want || m.mul(au, bu) != want {
+					t.Fatalf("p=%d: %d·%d = %d (mont64), %d (mod64), want %d", p, au, bu, got, m.mul(au, bu), want)
+				}
+				// REDC of plain operands is a·b·R^-1
+				redc := new(big.Int).Mul(new(big.Int).Mul(a, b), new(big.Int).ModInverse(R, P))
+				if got, want := mt.mul(au, bu), redc.Mod(redc, P).Uint64(); got != want {
+					t.Fatalf("p=%d: REDC(%d·%d) = %d, want %d", p, au, bu, got, want)
+				}
+				if got, want := mt.from(mt.add(mt.to(au), mt.to(bu))), m.add(au, bu); got != want {
+					t.Fatalf("p=%d: %d + %d = %d, want %d", p, au, bu, got, want)
+				}
+				if got, want := mt.from(mt.sub(mt.to(au), mt.to(bu))), m.sub(au, bu); got != want {
+					t.Fatalf("p=%d: %d - %d = %d, want %d", p, au, bu, got, want)
+				}
+			}
+			if au == 0 {
+				continue
+			}
+			if got, want := mt.legendre(mt.to(au)), big.Jacobi(a, P); got != want {
+				t.Fatalf("p=%d: legendre(%d) = %d, want %d", p, au, got, want)
+			}
+			if mt.legendre(mt.to(au)) == 1 {
+				y := mt.from(mt.sqrt(mt.to(au)))
+				if m.mul(y, y) != au {
+					t.Fatalf("p=%d: sqrt(%d) = %d does not square to it", p, au, y)
+				}
+			}
+		}
+	}
+}
+
+// TestScanModes checks the three uint64 modes, all on mont64 residues,
+// against a brute-force point set.
+func TestScanModes(t *testing.T) {
+	const p, A, B = 10007, 2, 3
+	roots := make([][]uint64, p) // every y with y^2 = r, ascending
+	for y := uint64(0); y < p; y++ {
+		roots[y*y%p] = append(roots[y*y%p], y)
+	}
+	var want []PointU64
+	for x := uint64(0); x < p; x++ {
+		for _, y := range roots[(x*x%p*x+A*x+B)%p] {
+			want = append(want, PointU64{X: x, Y: y})
+		}
+	}
+	for _, mode := range []Mode{ModeTable, ModeHybrid, ModeOnTheFly} {
+		var got []PointU64
+		err := Scan(context.Background(), Params{P: p, A: A, B: B, Mode: mode, Ordered: true}, func(x, y uint64) error {
+			got = append(got, PointU64{X: x, Y: y})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("%s: %d points, brute force %d (or they differ)", mode, len(got), len(want))
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package ecscan
+
+import "context"
+
+// ------------------- --ordered: chunks back in x order -------------------
+
+// orderedChunkX caps the x-range of a chunk in ordered mode, so a chunk
+// held back behind a slower one stays small.
+const orderedChunkX = 1 << 16
+
+// chunkBatch is a run of points from one chunk of x; last marks the
+// chunk's final batch (which may be empty).
+type chunkBatch[T any] struct {
+	chunk int
+	last  bool
+	pts   []T
+}
+
+// chunkWindow bounds how far the feeder may run ahead of the writer: a
+// chunk takes a slot when it is queued and frees it once written.
+type chunkWindow chan struct{}
+
+func (w chunkWindow) acquire(ctx context.Context) bool {
+	if w == nil {
+		return true
+	}
+	select {
+	case w <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (w chunkWindow) release() {
+	if w != nil {
+		<-w
+	}
+}
+
+// inOrder is a streaming merge: batches of the chunk being written pass
+// straight through, later chunks are held until their turn.
+type inOrder[T any] struct {
+	next    int
+	pending map[int]*heldChunk[T]
+	window  chunkWindow
+}
+
+type heldChunk[T any] struct {
+	pts  []T
+	done bool
+}
+
+func newInOrder[T any](window chunkWindow) *inOrder[T] {
+	return &inOrder[T]{pending: map[int]*heldChunk[T]{}, window: window}
+}
+
+func (o *inOrder[T]) add(b chunkBatch[T], emit func([]T) error) error {
+	if b.chunk != o.next {
+		h := o.pending[b.chunk]
+		if h == nil {
+			h = &heldChunk[T]{}
+			o.pending[b.chunk] = h
+		}
+		h.pts = append(h.pts, b.pts...)
+		h.done = b.last
+		return nil
+	}
+	if err := emit(b.pts); err != nil {
+		return err
+	}
+	if !b.last {
+		return nil
+	}
+	// the current chunk is complete: flush whatever the next ones hold
+	for {
+		o.next++
+		o.window.release()
+		h := o.pending[o.next]
+		if h == nil {
+			return nil
+		}
+		if err := emit(h.pts); err != nil {
+			return err
+		}
+		h.pts = nil
+		if !h.done {
+			return nil
+		}
+		delete(o.pending, o.next)
+	}
+}
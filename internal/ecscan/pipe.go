@@ -0,0 +1,95 @@
+package ecscan
+
+// ------------------- worker → writer hand-off -------------------
+
+// pipeSpec sizes the hand-off from the workers to the single writer
+// goroutine. Zero fields take the defaults.
+type pipeSpec struct {
+	Batch    int // --worker-batch: points a worker gathers before sending them on
+	ChanSize int // --point-chan-size: batches the channel into the writer holds
+}
+
+// pointBatch is how many points a worker gathers before handing them over
+// unless --worker-batch says otherwise.
+const pointBatch = 1024
+
+func (s pipeSpec) batch() int {
+	if s.Batch > 0 {
+		return s.Batch
+	}
+	return pointBatch
+}
+
+// chanSize defaults to two batches per worker, enough that a worker
+// finishing a batch seldom waits for the writer.
+func (s pipeSpec) chanSize(workers int) int {
+	if s.ChanSize > 0 {
+		return s.ChanSize
+	}
+	return workers * 2
+}
+
+// batcher is a worker's local buffer in front of the writer's channel:
+// add gathers points and sends them size at a time, so workers take the
+// channel once per batch rather than once per point.
+type batcher[T any] struct {
+	ch   chan<- []T
+	done <-chan struct{}
+	size int
+	buf  []T
+}
+
+func newBatcher[T any](ch chan<- []T, done <-chan struct{}, size int) *batcher[T] {
+	return &batcher[T]{ch: ch, done: done, size: size, buf: make([]T, 0, size)}
+}
+
+// add reports false once done is closed.
+func (b *batcher[T]) add(pt T) bool {
+	b.buf = append(b.buf, pt)
+	return len(b.buf) < b.size || b.flush()
+}
+
+// flush sends what has been gathered; workers call it at the end of a job.
+func (b *batcher[T]) flush() bool {
+	if len(b.buf) == 0 {
+		return true
+	}
+	select {
+	case b.ch <- b.buf:
+		b.buf = make([]T, 0, b.size)
+		return true
+	case <-b.done:
+		return false
+	}
+}
+
+// fanout is the channels from the workers to the writer goroutines, one
+// per writer: with --writers N, writer i takes the chunks ≡ i mod N.
+type fanout[T any] []chan chunkBatch[T]
+
+func newFanout[T any](writers, size int) fanout[T] {
+	f := make(fanout[T], writers)
+	for i := range f {
+		f[i] = make(chan chunkBatch[T], size)
+	}
+	return f
+}
+
+func (f fanout[T]) to(chunk int) chan<- chunkBatch[T] { return f[chunk%len(f)] }
+
+func (f fanout[T]) close() {
+	for _, ch := range f {
+		close(ch)
+	}
+}
+
+// queued and capacity sum over the channels, for the metrics.
+func (f fanout[T]) queued() int {
+	n := 0
+	for _, ch := range f {
+		n += len(ch)
+	}
+	return n
+}
+
+func (f fanout[T]) capacity() int { return len(f) * cap(f[0]) }
@@ -0,0 +1,136 @@
+package ecscan
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ectorus/internal/ptfile"
+)
+
+// TestWriteReadBack writes y^2 = x^3 + 2x + 3 over F_1009 in each format
+// (and --coords) through Run and reads it back with ptfile: the affine
+// points must be the curve's, and O must come back as one typed record at
+// the end, not as a point.
+func TestWriteReadBack(t *testing.T) {
+	const p, A, B = 1009, 2, 3
+	want := map[[2]uint64]bool{}
+	if err := Scan(context.Background(), Params{P: p, A: A, B: B}, func(x, y uint64) error {
+		want[[2]uint64{x, y}] = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		inf  string // the raw O record, "" when the file is compressed
+	}{
+		{"text", []string{"--format=text"}, "inf"},
+		{"text header", []string{"--format=text", "--header"}, "inf"},
+		{"csv", []string{"--format=csv"}, "inf,inf"},
+		{"csv header", []string{"--format=csv", "--header"}, "inf,inf"},
+		{"ndjson", []string{"--format=ndjson"}, `{"inf":true}`},
+		{"ndjson header", []string{"--format=ndjson", "--header"}, `{"inf":true}`},
+		{"compressed", []string{"--format=compressed"}, "00"},
+		{"compressed header", []string{"--format=compressed", "--header"}, "00"},
+		{"text gzip", []string{"--format=text", "--header", "--compress=gzip"}, ""},
+		{"ordered", []string{"--format=text", "--header", "--ordered"}, "inf"},
+		// --coords: Z = 1 triples, and O as the Z = 0 one
+		{"text projective", []string{"--format=text", "--coords=projective"}, "0 1 0"},
+		{"text jacobian header", []string{"--format=text", "--coords=jacobian", "--header"}, "1 1 0"},
+		{"csv projective header", []string{"--format=csv", "--coords=projective", "--header"}, "0,1,0"},
+		{"csv jacobian", []string{"--format=csv", "--coords=jacobian"}, "1,1,0"},
+		{"ndjson projective", []string{"--format=ndjson", "--coords=projective"}, `{"x":0,"y":1,"z":0}`},
+		{"ndjson jacobian header", []string{"--format=ndjson", "--coords=jacobian", "--header"}, `{"x":1,"y":1,"z":0}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pts")
+			args := append([]string{"--p=1009", "--A=2", "--B=3", "--workers=4", "--out=" + path}, tc.args...)
+			cfg, err := ParseFlags(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Run(cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.inf != "" {
+				b, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+				last := lines[len(lines)-1]
+				if strings.Contains(last, "end") { // the footer
+					last = lines[len(lines)-2]
+				}
+				if last != tc.inf {
+					t.Fatalf("O written as %q, want %q", last, tc.inf)
+				}
+			}
+
+			r, err := ptfile.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			r.SetCurve(big.NewInt(p), big.NewInt(A), big.NewInt(B))
+			got, inf := map[[2]uint64]bool{}, 0
+			for {
+				rec, err := r.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if rec.Inf {
+					inf++
+					continue
+				}
+				if inf > 0 {
+					t.Fatalf("line %d: a point after the inf record", rec.Line)
+				}
+				if len(rec.Coords) != 2 {
+					t.Fatalf("line %d: %d coordinates", rec.Line, len(rec.Coords))
+				}
+				k := [2]uint64{rec.Coords[0].Uint64(), rec.Coords[1].Uint64()}
+				if !want[k] || got[k] {
+					t.Fatalf("line %d: (%d, %d) is not a point of the curve, or repeated", rec.Line, k[0], k[1])
+				}
+				got[k] = true
+			}
+			if inf != 1 {
+				t.Fatalf("%d inf records, want 1", inf)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("%d affine points read back, want %d", len(got), len(want))
+			}
+			if m := r.Meta(); m != nil {
+				if m.Version != outputVersion {
+					t.Fatalf("header version %d, want %d", m.Version, outputVersion)
+				}
+				_, coords, _ := strings.Cut(strings.Join(tc.args, " "), "--coords=")
+				if coords, _, _ = strings.Cut(coords, " "); m.Coords != coords {
+					t.Fatalf("header coords %q, want %q", m.Coords, coords)
+				}
+			}
+			if strings.Contains(strings.Join(tc.args, " "), "--header") {
+				f := r.Footer()
+				if f == nil {
+					t.Fatal("no footer")
+				}
+				if f.Points != uint64(len(want)) || f.SHA256 != r.Sum() {
+					t.Fatalf("footer points=%d sha256=%s, want %d and %s", f.Points, f.SHA256, len(want), r.Sum())
+				}
+			}
+		})
+	}
+}
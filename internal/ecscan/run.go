@@ -6,22 +6,72 @@ import (
 	"log"
 	"math/big"
 	"os"
-)
+	"time"
 
-// safety factor for table-mode RAM check (use up to 80% of cap)
-const safety80 = 8.0 / 10.0
+	"ectorus/internal/ec"
+	"ectorus/internal/prof"
+)
 
-func Run(cfg *Config) error {
+func Run(cfg *Config) (err error) {
+	stopProf, err := prof.Start(cfg.Profile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if perr := stopProf(); err == nil {
+			err = perr
+		}
+	}()
+	if cfg.Field == FieldGF2m {
+		return runGF2m(cfg)
+	}
 	// Parse numbers as big.Int first (keeps one codepath for validation)
-	p := mustParseBig(cfg.P, "p")
-	A := mustParseBig(cfg.A, "A")
-	B := mustParseBig(cfg.B, "B")
+	p, err := parseBigArg(cfg.P, "p")
+	if err != nil {
+		return err
+	}
+	A, err := parseBigArg(cfg.A, "A")
+	if err != nil {
+		return err
+	}
+	B, err := parseBigArg(cfg.B, "B")
+	if err != nil {
+		return err
+	}
+	if p.Cmp(big.NewInt(3)) <= 0 {
+		return fmt.Errorf("--p must be a prime > 3, got %s", p)
+	}
+	// negative or oversized A, B (e.g. A = -3) are taken mod p
+	A, B = new(big.Int).Mod(A, p), new(big.Int).Mod(B, p)
 
 	maxMemBytes, err := parseBytes(cfg.MaxMem)
 	if err != nil {
 		return fmt.Errorf("bad --max-mem: %v", err)
 	}
 
+	if cfg.Metrics != "" {
+		stop, err := serveMetrics(cfg.Metrics)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	out := outputSpec{Path: cfg.OutPath, Format: cfg.Format, Coords: cfg.Coords, Header: cfg.Header, Compress: cfg.Compress,
+		Ordered: cfg.Ordered, Verify: cfg.Verify, VerifyMax: cfg.VerifyMax, SplitSize: cfg.SplitSize, SplitEvery: cfg.SplitEvery,
+		WriterBuffer: cfg.WriterBuffer, Pipe: pipeSpec{Batch: cfg.WorkerBatch, ChanSize: cfg.PointChanSize},
+		Writers: cfg.Writers, Merge: cfg.MergeWriters}
+	start := time.Now()
+	sumW, closeSum, err := openSummary(cfg.Summary)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := closeSum(); err == nil {
+			err = cerr
+		}
+	}()
+
 	// Work out vis-mode enum
 	vm := visAuto
 	if cfg.VisMode == "fail" {
@@ -30,6 +80,33 @@ func Run(cfg *Config) error {
 	if cfg.Vis && cfg.OutPath == "-" {
 		return fmt.Errorf("vis: please set --out to a file (not '-') so the ASCII plot can print to stdout")
 	}
+	var hm *heatmap
+	if cfg.VisPNG != "" {
+		hm = newHeatmap(p, cfg.VisPNGW, cfg.VisPNGH)
+	}
+
+	// Points over the quadratic extension F_{p^2}
+	if cfg.Ext == 2 {
+		if cfg.WorkersAuto {
+			log.Printf("workers auto: --ext 2 is not calibrated, using %d workers", cfg.Workers)
+		}
+		pu64, ok := fitsUint64(p)
+		Au64, okA := fitsUint64(A)
+		Bu64, okB := fitsUint64(B)
+		if !ok || !okA || !okB {
+			return fmt.Errorf("--ext 2 needs p, A, B to fit in uint64")
+		}
+		n, err := enumerateExt2(pu64, Au64%pu64, Bu64%pu64, out, cfg.Workers)
+		if err != nil {
+			return err
+		}
+		if cfg.Verify {
+			if err := verifyCount(pu64, Au64, Bu64, 2, n, cfg.VerifyMax); err != nil {
+				return err
+			}
+		}
+		return newSummary(p, new(big.Int).SetUint64(Au64%pu64), new(big.Int).SetUint64(Bu64%pu64), 2, ModeOnTheFly, n, time.Since(start)).report(sumW)
+	}
 
 	// Fast path if p fits in uint64 and p < 2^63
 	if pu64, ok := fitsUint64(p); ok && pu64 < (1<<63) {
@@ -38,28 +115,47 @@ func Run(cfg *Config) error {
 		if !okA || !okB {
 			return fmt.Errorf("'A' or 'B' does not fit into uint64 while p does")
 		}
+		if cfg.CountMethod == CountLegendreSum {
+			n, err := legendreSum(pu64, Au64%pu64, Bu64%pu64, cfg.Workers, cfg.Checkpoint, cfg.CheckpointEvery)
+			if err != nil {
+				return err
+			}
+			return newSummary(p, new(big.Int).SetUint64(Au64%pu64), new(big.Int).SetUint64(Bu64%pu64), 1, CountLegendreSum, n, time.Since(start)).report(sumW)
+		}
 
-		// Estimate table memory: 4B entry if p < 2^32, else 8B (store y)
+		// Estimate table memory: 4B entry if p < 2^32, else 8B (store y);
+		// the hybrid residue bitmap is p/8 bytes
 		entryBytes := uint64(4)
 		if pu64 >= (1 << 32) {
 			entryBytes = 8
 		}
 		tableBytes := entryBytes * pu64
 
-		mode := cfg.Mode
-		if mode == ModeAuto {
-			if float64(tableBytes) <= float64(maxMemBytes)*safety80 {
-				mode = ModeTable
-			} else {
-				mode = ModeOnTheFly
+		mode, err := resolveModeU64(pu64, cfg.Mode, maxMemBytes)
+		if err != nil {
+			return err
+		}
+		if cfg.Mode == ModeAuto {
+			log.Printf("auto mode => %s (table≈%.2fGB, bitmap≈%.2fGB, cap=%.2fGB)",
+				mode, float64(tableBytes)/(1<<30), float64(pu64/8)/(1<<30), float64(maxMemBytes)/(1<<30))
+		}
+
+		bFrom, bTo := Bu64, Bu64
+		if cfg.BRange {
+			bFrom, bTo = cfg.BFrom, cfg.BTo
+		}
+		// --workers auto: the table is built once, for the trials and the scan
+		workers := cfg.Workers
+		var table any
+		if cfg.WorkersAuto {
+			if table, err = tableFor(pu64, mode, workers); err != nil {
+				return err
 			}
-			log.Printf("auto mode => %s (table≈%.2fGB, cap=%.2fGB)",
-				mode, float64(tableBytes)/(1<<30), float64(maxMemBytes)/(1<<30))
+			workers = calibrateWorkers(pu64, Au64%pu64, bFrom%pu64, mode, table, out, cfg.CountOnly, workers)
 		}
 
-		if mode == ModeTable && float64(tableBytes) > float64(maxMemBytes)*safety80 {
-			return fmt.Errorf("mode=table needs ~%.2f GB; allowed ~%.2f GB (cap*safety)",
-				float64(tableBytes)/(1<<30), float64(maxMemBytes)*safety80/(1<<30))
+		if cfg.BRange || cfg.CountOnly {
+			return runBRange(pu64, Au64%pu64, bFrom, bTo, mode, maxMemBytes, table, out, cfg.CountOnly, workers, sumW)
 		}
 
 		// Optional vis grid
@@ -72,21 +168,39 @@ func Run(cfg *Config) error {
 			vg = g
 		}
 
-		if err := enumerateU64(pu64, Au64, Bu64, mode, maxMemBytes, cfg.OutPath, cfg.Workers, vg); err != nil {
+		n, err := enumerateU64(pu64, Au64, Bu64, mode, maxMemBytes, out, workers, vg, hm, table)
+		if err != nil {
 			return err
 		}
+		if cfg.Verify {
+			if err := verifyCount(pu64, Au64, Bu64, 1, n, cfg.VerifyMax); err != nil {
+				return err
+			}
+		}
+		if hm != nil {
+			if err := hm.WritePNG(cfg.VisPNG); err != nil {
+				return err
+			}
+		}
 		if cfg.Vis && vg != nil {
 			bw := bufio.NewWriter(os.Stdout)
 			if err := vg.RenderTo(bw); err != nil {
 				return err
 			}
 		}
-		return nil
+		return newSummary(p, new(big.Int).SetUint64(Au64%pu64), new(big.Int).SetUint64(Bu64%pu64), 1, mode, n, time.Since(start)).report(sumW)
+	}
+
+	if cfg.WorkersAuto {
+		log.Printf("workers auto: only scans with p < 2^63 are calibrated, using %d workers", cfg.Workers)
 	}
 
 	// Big path (onthefly only)
-	if cfg.Mode == ModeTable {
-		return fmt.Errorf("mode=table is not supported when p does not fit in uint64")
+	if cfg.BRange || cfg.CountOnly {
+		return fmt.Errorf("--B-range, --count-only and --count-method legendre-sum need p < 2^63")
+	}
+	if cfg.Mode == ModeTable || cfg.Mode == ModeHybrid {
+		return fmt.Errorf("mode=%s is not supported when p does not fit in uint64", cfg.Mode)
 	}
 
 	mode := cfg.Mode
@@ -104,24 +218,42 @@ func Run(cfg *Config) error {
 		vgBig = g
 	}
 
-	if err := enumerateBig(p, A, B, mode, cfg.OutPath, cfg.Workers, vgBig); err != nil {
+	n, err := enumerateBig(p, A, B, mode, out, cfg.Workers, vgBig, hm)
+	if err != nil {
 		return err
 	}
+	if cfg.Verify {
+		log.Printf("verify: p ≥ 2^63, point count not cross-checked")
+	}
+	if hm != nil {
+		if err := hm.WritePNG(cfg.VisPNG); err != nil {
+			return err
+		}
+	}
 	if cfg.Vis && vgBig != nil {
 		bw := bufio.NewWriter(os.Stdout)
 		if err := vgBig.RenderTo(bw); err != nil {
 			return err
 		}
 	}
-	return nil
+	return newSummary(p, new(big.Int).Mod(A, p), new(big.Int).Mod(B, p), 1, mode, n, time.Since(start)).report(sumW)
 }
 
 // --- local helpers (mirror the ones used in the rest of the package) ---
 
+// parseBigArg reads decimal, 0x-hex or an expression like 2^61-1.
+func parseBigArg(s, name string) (*big.Int, error) {
+	n, err := ec.ParseBig(s)
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %w", name, err)
+	}
+	return n, nil
+}
+
 func mustParseBig(s, name string) *big.Int {
-	n, ok := new(big.Int).SetString(s, 10)
-	if !ok {
-		log.Fatalf("invalid integer for %s: %q", name, s)
+	n, err := ec.ParseBig(s)
+	if err != nil {
+		log.Fatalf("%s: %v", name, err)
 	}
 	return n
 }
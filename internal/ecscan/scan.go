@@ -2,19 +2,22 @@ package ecscan
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
-	"math"
 	"math/big"
 	"math/bits"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
 type visMode int
@@ -251,50 +254,191 @@ func tonelliBig(n, p *big.Int) *big.Int {
 	return x
 }
 
-// ------------------- writer -------------------
+// ------------------- big.Int scan loop (p ≥ 2^127) -------------------
+
+// bigField is the per-prime setup of the big.Int scan: the Tonelli–Shanks
+// constants, so no x repeats the non-residue search tonelliBig does.
+type bigField struct {
+	p, A, B *big.Int
+	oneA    *big.Int // 1 + A mod p
+	pm1     *big.Int // p - 1 = q·2^s
+	qh      *big.Int // (q-1)/2
+	s       int
+	c       *big.Int // z^q for a non-residue z
+}
 
-type pointWriter interface {
-	WriteU64(p PointU64) error
-	WriteBig(p PointBig) error
-	Close() error
+// newBigField takes A, B already reduced mod the odd prime p.
+func newBigField(p, A, B *big.Int) *bigField {
+	fd := &bigField{p: p, A: A, B: B, pm1: new(big.Int).Sub(p, b1)}
+	fd.oneA = new(big.Int).Add(A, b1)
+	fd.oneA.Mod(fd.oneA, p)
+	q := new(big.Int).Set(fd.pm1)
+	for q.Bit(0) == 0 {
+		q.Rsh(q, 1)
+		fd.s++
+	}
+	fd.qh = new(big.Int).Rsh(q, 1)
+	z := big.NewInt(2)
+	for legendreBig(z, p) != -1 {
+		z.Add(z, b1)
+	}
+	fd.c = new(big.Int).Exp(z, q, p)
+	return fd
 }
 
-type textWriter struct {
-	bw *bufio.Writer
+// bigScan is one worker's big.Int working set. Every step writes into a
+// field of it and reduces by QuoRem into a kept quotient, so the loop
+// itself allocates nothing once the words have grown to size; what is
+// left are math/big's own temporaries in Exp and QuoRem.
+type bigScan struct {
+	*bigField
+	x, x2, f, t, d big.Int
+	py             big.Int
+	sx, st, sc, sb big.Int // Tonelli–Shanks
+	prod, quo      big.Int
 }
 
-func newTextWriter(path string) (*textWriter, func(), error) {
-	var f *os.File
-	var err error
-	if path == "-" {
-		f = os.Stdout
-	} else {
-		f, err = os.Create(path)
-		if err != nil {
-			return nil, nil, err
-		}
+func (s *bigScan) mulMod(z, a, b *big.Int) {
+	s.prod.Mul(a, b)
+	s.quo.QuoRem(&s.prod, s.p, z)
+}
+
+// addMod needs a, b in [0, p).
+func (s *bigScan) addMod(z, a, b *big.Int) {
+	z.Add(a, b)
+	if z.Cmp(s.p) >= 0 {
+		z.Sub(z, s.p)
 	}
-	w := bufio.NewWriterSize(f, 4<<20) // 4 MB buffer
-	closeFn := func() {
-		w.Flush()
-		if f != os.Stdout {
-			f.Close()
+}
+
+// sqrt is legendreBig and tonelliBig on one exponentiation: w = a^((q-1)/2)
+// gives x = aw = a^((q+1)/2) and t = xw = a^q, and Euler's a^((p-1)/2) is
+// t squared s-1 times. For a residue it also returns the root, which lives
+// in s.sx until the next call.
+func (s *bigScan) sqrt(a *big.Int) (int, *big.Int) {
+	if a.Sign() == 0 {
+		return 0, nil
+	}
+	x, t, c, b := &s.sx, &s.st, &s.sc, &s.sb
+	b.Exp(a, s.qh, s.p)
+	s.mulMod(x, a, b)
+	s.mulMod(t, x, b)
+	c.Set(t)
+	for i := 1; i < s.s; i++ {
+		s.mulMod(c, c, c)
+	}
+	switch {
+	case c.Cmp(s.pm1) == 0:
+		return -1, nil
+	case c.Cmp(b1) != 0:
+		return 0, nil // p is not prime
+	}
+	c.Set(s.c)
+	si := s.s
+	for t.Cmp(b1) != 0 {
+		// find i s.t. t^(2^i) = 1, squaring into s.d
+		i := 1
+		s.mulMod(&s.d, t, t)
+		for s.d.Cmp(b1) != 0 {
+			s.mulMod(&s.d, &s.d, &s.d)
+			i++
+			if i == si {
+				panic("bigScan.sqrt: non-residue")
+			}
 		}
+		b.Set(c)
+		for j := 0; j < si-i-1; j++ {
+			s.mulMod(b, b, b)
+		}
+		s.mulMod(x, x, b)
+		s.mulMod(c, b, b)
+		s.mulMod(t, t, c)
+		si = i
 	}
-	return &textWriter{bw: w}, closeFn, nil
+	return 1, x
 }
-func (w *textWriter) WriteU64(p PointU64) error {
-	_, err := w.bw.WriteString(fmt.Sprintf("%d %d\n", p.X, p.Y))
-	return err
+
+// scan is enumerateBig's inner loop over x in [x0, x1): scanU64's finite
+// differences on big.Ints. Points go to *buf through nextPointBig; flush
+// hands it over once it holds batch points and reports false once the scan
+// is cancelled.
+func (s *bigScan) scan(x0, x1 *big.Int, ordered bool, batch int, buf *[]PointBig, flush func() bool) bool {
+	x, x2, f, t, d := &s.x, &s.x2, &s.f, &s.t, &s.d
+	x.Set(x0)
+	s.mulMod(x2, x, x)
+	s.mulMod(f, x2, x)
+	s.mulMod(t, s.A, x)
+	s.addMod(f, f, t)
+	s.addMod(f, f, s.B)
+	var pt *PointBig
+	for ; x.Cmp(x1) < 0; x.Add(x, b1) {
+		switch leg, y := s.sqrt(f); leg {
+		case 1:
+			py := s.py.Sub(s.p, y)
+			if ordered && py.Cmp(y) < 0 {
+				y, py = py, y
+			}
+			*buf, pt = nextPointBig(*buf)
+			pt.X.Set(x)
+			pt.Y.Set(y)
+			*buf, pt = nextPointBig(*buf)
+			pt.X.Set(x)
+			pt.Y.Set(py)
+		case 0:
+			*buf, pt = nextPointBig(*buf)
+			pt.X.Set(x)
+			pt.Y.SetInt64(0)
+		}
+		if len(*buf) >= batch && !flush() {
+			return false
+		}
+		// f += 3(x^2 + x) + 1 + A, x2 += 2x + 1
+		s.addMod(t, x2, x)
+		s.addMod(d, t, t)
+		s.addMod(d, d, t)
+		s.addMod(d, d, s.oneA)
+		s.addMod(f, f, d)
+		s.addMod(t, x, x)
+		s.addMod(t, t, b1)
+		s.addMod(x2, x2, t)
+	}
+	return true
 }
-func (w *textWriter) WriteBig(p PointBig) error {
-	_, err := w.bw.WriteString(fmt.Sprintf("%s %s\n", p.X.String(), p.Y.String()))
-	return err
+
+// pointBigBatches recycles the big path's point batches: the writer
+// returns a batch once it is written (no pointWriter keeps the big.Ints
+// past WriteBig), and nextPointBig refills the entries in place.
+var pointBigBatches = sync.Pool{New: func() any { return new([]PointBig) }}
+
+func getPointBigBatch() []PointBig { return (*pointBigBatches.Get().(*[]PointBig))[:0] }
+
+func putPointBigBatch(pts []PointBig) {
+	pts = pts[:0]
+	pointBigBatches.Put(&pts)
+}
+
+// nextPointBig extends buf by one point and returns it for filling,
+// reusing the big.Ints a recycled batch holds past its length.
+func nextPointBig(buf []PointBig) ([]PointBig, *PointBig) {
+	if len(buf) < cap(buf) {
+		buf = buf[:len(buf)+1]
+	} else {
+		buf = append(buf, PointBig{})
+	}
+	pt := &buf[len(buf)-1]
+	if pt.X == nil {
+		pt.X, pt.Y = new(big.Int), new(big.Int)
+	}
+	return buf, pt
 }
-func (w *textWriter) Close() error { return w.bw.Flush() }
 
 // ------------------- sqrt table (uint64 fast path) -------------------
 
+// buildSqrtTableU64 maps every residue r to its root y ≤ (p-1)/2 (0 for
+// r = 0) and every non-residue to the all-ones sentinel. The roots in
+// [0, (p-1)/2] square to distinct residues, so each worker fills its own
+// y-range with plain stores: no CAS, nothing written twice, and the same
+// bytes whatever the worker count or scheduling.
 func buildSqrtTableU64(p uint64, workers int, store64 bool) (any, error) {
 	// store64=false => []uint32 (p must fit in int and y<p<2^32)
 	// store64=true  => []uint64
@@ -303,86 +447,95 @@ func buildSqrtTableU64(p uint64, workers int, store64 bool) (any, error) {
 		return nil, fmt.Errorf("p too large for slice length on this platform")
 	}
 
-	const u32sent = ^uint32(0)
-	const u64sent = ^uint64(0)
-
 	start := time.Now()
 	log.Printf("building sqrt table with %d workers ...", workers)
-
+	var T any
 	if !store64 {
-		T := make([]uint32, plen)
-		for i := range T {
-			T[i] = u32sent
-		}
+		T32 := make([]uint32, plen)
+		fillSqrtTable(T32, p, workers)
+		T = T32
+	} else {
+		T64 := make([]uint64, plen)
+		fillSqrtTable(T64, p, workers)
+		T = T64
+	}
+	log.Printf("sqrt table ready in %v", time.Since(start))
+	metrics.tableBuilt(time.Since(start))
+	return T, nil
+}
+
+// fillSqrtTable is buildSqrtTableU64 for one entry width: a parallel pass
+// setting the sentinel, then one writing T[y^2] = y for y in [0, (p-1)/2].
+// Within a range the squares step by (y+1)^2 = y^2 + 2y + 1, one add and
+// one conditional subtract per entry.
+func fillSqrtTable[E uint32 | uint64](T []E, p uint64, workers int) {
+	parallel := func(n uint64, fn func(a, b uint64)) {
 		var wg sync.WaitGroup
-		chunk := (p + uint64(workers) - 1) / uint64(workers)
-		for w := 0; w < workers; w++ {
-			s := uint64(w) * chunk
-			e := s + chunk
-			if e > p {
-				e = p
-			}
-			if s >= e {
-				continue
-			}
+		chunk := (n + uint64(workers) - 1) / uint64(workers)
+		for s := uint64(0); s < n; s += chunk {
 			wg.Add(1)
 			go func(a, b uint64) {
 				defer wg.Done()
-				for y := a; y < b; y++ {
-					r := (y * y) % p
-					// CAS first-wins
-					for {
-						old := atomic.LoadUint32(&T[r])
-						if old != u32sent {
-							break
-						}
-						if atomic.CompareAndSwapUint32(&T[r], u32sent, uint32(y)) {
-							break
-						}
-					}
-				}
-			}(s, e)
+				fn(a, b)
+			}(s, min(s+chunk, n))
 		}
 		wg.Wait()
-		log.Printf("sqrt table ready in %v", time.Since(start))
-		return T, nil
 	}
+	parallel(p, func(a, b uint64) {
+		for i := a; i < b; i++ {
+			T[i] = ^E(0)
+		}
+	})
+	m := mod64{p}
+	parallel((p-1)/2+1, func(a, b uint64) {
+		r := m.mul(a, a)
+		for y := a; y < b; y++ {
+			T[r] = E(y)
+			r += 2*y + 1 // < 2p, as 2y+1 ≤ p
+			if r >= p {
+				r -= p
+			}
+		}
+	})
+}
+
+// ------------------- residue bitmap (hybrid mode) -------------------
+
+// residueBitmap has bit r set iff r is a nonzero square mod p: p/8 bytes
+// where the sqrt table takes 4p or 8p, enough to skip the Euler check per x
+// and run Tonelli–Shanks only on the residues.
+type residueBitmap []uint64
+
+func (R residueBitmap) has(r uint64) bool { return R[r>>6]&(1<<(r&63)) != 0 }
 
-	T := make([]uint64, plen)
-	for i := range T {
-		T[i] = u64sent
+// buildResidueBitmap marks y^2 mod p for y in [1, (p-1)/2], which covers
+// every nonzero residue once.
+func buildResidueBitmap(p uint64, workers int) (residueBitmap, error) {
+	words := p/64 + 1
+	if int64(int(words)) < 0 || uint64(int(words)) != words {
+		return nil, fmt.Errorf("p too large for slice length on this platform")
 	}
+	start := time.Now()
+	log.Printf("building residue bitmap with %d workers ...", workers)
+	R := make(residueBitmap, words)
+	m := mod64{p}
+	half := (p - 1) / 2
 	var wg sync.WaitGroup
-	chunk := (p + uint64(workers) - 1) / uint64(workers)
-	for w := 0; w < workers; w++ {
-		s := uint64(w) * chunk
-		e := s + chunk
-		if e > p {
-			e = p
-		}
-		if s >= e {
-			continue
-		}
+	chunk := (half + uint64(workers) - 1) / uint64(workers)
+	for s := uint64(1); s <= half; s += chunk {
 		wg.Add(1)
 		go func(a, b uint64) {
 			defer wg.Done()
 			for y := a; y < b; y++ {
-				r := (y * y) % p
-				for {
-					old := atomic.LoadUint64(&T[r])
-					if old != u64sent {
-						break
-					}
-					if atomic.CompareAndSwapUint64(&T[r], u64sent, y) {
-						break
-					}
-				}
+				r := m.mul(y, y)
+				atomic.OrUint64(&R[r>>6], 1<<(r&63))
 			}
-		}(s, e)
+		}(s, min(s+chunk, half+1))
 	}
 	wg.Wait()
-	log.Printf("sqrt table ready in %v", time.Since(start))
-	return T, nil
+	log.Printf("residue bitmap ready in %v", time.Since(start))
+	metrics.tableBuilt(time.Since(start))
+	return R, nil
 }
 
 // ----------- visualisation (ASCII) ------------
@@ -481,9 +634,6 @@ func (g *visGridBig) bucket(x, y *big.Int) (int, int) {
 }
 
 func (g *visGridBig) Add(x, y *big.Int) {
-	if x.Sign() < 0 || y.Sign() < 0 {
-		return
-	}
 	ix, iy := g.bucket(x, y)
 	gy := (g.H - 1) - iy
 	g.cells[gy][ix] = true
@@ -533,10 +683,6 @@ func (g *visGridU64) bucket(x, y uint64) (int, int) {
 }
 
 func (g *visGridU64) Add(x, y uint64) {
-	// ignore infinity sentinel if it ever sneaks through
-	if x == math.MaxUint64 && y == math.MaxUint64 {
-		return
-	}
 	ix, iy := g.bucket(x, y)
 	// text row 0 at top => y grows down; field y grows up; flip vertically
 	gy := (g.H - 1) - iy
@@ -575,261 +721,479 @@ func (g *visGridU64) RenderTo(w *bufio.Writer) error {
 
 // ------------------- enumeration: uint64 fast path -------------------
 
-func enumerateU64(p, A, B uint64, mode Mode, maxMem uint64, outPath string, workers int, vg *visGridU64) error {
-	// Decide table layout
-	store64 := p >= (1 << 32) // need 8B entries if y >= 2^32
+// resolveModeU64 turns auto into table when a sqrt table for p fits in 80%
+// of maxMem, into hybrid when only the p-bit residue bitmap does, and into
+// onthefly otherwise; it rejects a table or hybrid mode that does not fit.
+func resolveModeU64(p uint64, mode Mode, maxMem uint64) (Mode, error) {
 	entryBytes := uint64(4)
-	if store64 {
+	if p >= 1<<32 { // need 8B entries if y >= 2^32
 		entryBytes = 8
 	}
 	tableBytes := entryBytes * p
-	autoMode := mode == ModeAuto
-
-	if autoMode {
-		if tableBytes <= maxMem*8/10 {
+	bitmapBytes := p/8 + 8
+	if mode == ModeAuto {
+		switch {
+		case tableBytes <= maxMem*8/10:
 			mode = ModeTable
-		} else {
+		case bitmapBytes <= maxMem*8/10:
+			mode = ModeHybrid
+		default:
 			mode = ModeOnTheFly
 		}
 	}
-
 	if mode == ModeTable && tableBytes > maxMem*8/10 {
-		return fmt.Errorf("requested table mode needs ~%0.2f GB but max-mem allows ~%0.2f GB",
+		return mode, fmt.Errorf("requested table mode needs ~%0.2f GB but max-mem allows ~%0.2f GB",
 			float64(tableBytes)/(1<<30), float64(maxMem*8/10)/(1<<30))
 	}
+	if mode == ModeHybrid && bitmapBytes > maxMem*8/10 {
+		return mode, fmt.Errorf("requested hybrid mode needs ~%0.2f GB but max-mem allows ~%0.2f GB",
+			float64(bitmapBytes)/(1<<30), float64(maxMem*8/10)/(1<<30))
+	}
+	return mode, nil
+}
 
-	w, closeFn, err := newTextWriter(outPath)
+// enumerateU64 writes every affine point to out and returns how many it wrote.
+// table, if non-nil, is a sqrt table for p from buildSqrtTableU64 (or in
+// hybrid mode a residueBitmap) to use instead of building one (it depends
+// only on p, so --B-range shares it).
+func enumerateU64(p, A, B uint64, mode Mode, maxMem uint64, out outputSpec, workers int, vg *visGridU64, hm *heatmap, table any) (n uint64, err error) {
+	mode, err = resolveModeU64(p, mode, maxMem)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer closeFn()
 
-	log.Printf("p=%d A=%d B=%d mode=%v workers=%d", p, A, B, mode, workers)
-
-	var Tany any
-	if mode == ModeTable {
-		Tany, err = buildSqrtTableU64(p, workers, store64)
-		if err != nil {
-			return err
-		}
+	meta := runMeta{
+		P: strconv.FormatUint(p, 10), A: strconv.FormatUint(A, 10), B: strconv.FormatUint(B, 10),
+		Mode: mode, Timestamp: time.Now().UTC(),
 	}
+	ws, err := openWriters(out, meta)
+	if err != nil {
+		return 0, err
+	}
+	defer ws.abort()
 
-	// work channel
-	type job struct{ x0, x1 uint64 }
-	jobs := make(chan job, workers*2)
-	points := make(chan PointU64, 1<<16)
-
-	// writer goroutine
-	var wgW sync.WaitGroup
-	wgW.Add(1)
-	go func() {
-		defer wgW.Done()
-		for pt := range points {
+	log.Printf("p=%d A=%d B=%d mode=%v workers=%d", p, A, B, mode, workers)
+
+	// writer i counts the affine points it writes in ws.counts[i]
+	emits := make([]func(PointU64) error, len(ws.ws))
+	for i, w := range ws.ws {
+		emits[i] = func(pt PointU64) error {
+			if out.Verify {
+				if err := verifyPointU64(p, A%p, B%p, pt); err != nil {
+					return err
+				}
+			}
 			if err := w.WriteU64(pt); err != nil {
-				log.Fatalf("write error: %v", err)
+				return fmt.Errorf("write error: %w", err)
 			}
+			ws.counts[i]++
 			if vg != nil {
 				vg.Add(pt.X, pt.Y)
 			}
+			if hm != nil {
+				hm.AddU64(pt.X, pt.Y)
+			}
+			return nil
 		}
-	}()
+	}
+	if err := scanU64(context.Background(), p, A, B, mode, table, workers, out.Ordered, out.Pipe, emits); err != nil {
+		return ws.total(), err
+	}
+
+	return ws.finish()
+}
+
+// tableFor builds what mode looks residues up in: the sqrt table, the
+// hybrid residue bitmap, or nothing on the fly.
+func tableFor(p uint64, mode Mode, workers int) (any, error) {
+	switch mode {
+	case ModeTable:
+		return buildSqrtTableU64(p, workers, p >= 1<<32)
+	case ModeHybrid:
+		return buildResidueBitmap(p, workers)
+	}
+	return nil, nil
+}
+
+// scanU64 runs the worker pool over x in [0, p) and passes every affine
+// point to one of emits: emits[i] gets the chunks ≡ i mod len(emits), each
+// from a goroutine of its own. Points come in no particular order unless
+// ordered is set (with a single emit), in which case they are sorted by x
+// and then y. mode must be resolved (table, hybrid or onthefly); a nil
+// table (or residue bitmap) is built here.
+// pipe sizes the worker batches and the channels to the emits.
+// It stops at the first error from an emit, or when ctx is done, and
+// returns that error.
+func scanU64(ctx context.Context, p, A, B uint64, mode Mode, table any, workers int, ordered bool, pipe pipeSpec, emits []func(PointU64) error) error {
+	store64 := p >= (1 << 32)
+	Tany := table
+	if Tany == nil {
+		var err error
+		if Tany, err = tableFor(p, mode, workers); err != nil {
+			return err
+		}
+	}
+	// The feeder, the workers and the consumer share one errgroup: the first
+	// error cancels gctx, and everything blocked on a channel gives up.
+	g, gctx := errgroup.WithContext(ctx)
+
+	// work channel
+	type job struct {
+		chunk  int
+		x0, x1 uint64
+	}
+	jobs := make(chan job, workers*2)
+	batches := newFanout[PointU64](len(emits), pipe.chanSize(workers))
+	batch := pipe.batch()
+	var window chunkWindow
+	if ordered {
+		window = make(chunkWindow, workers*4)
+	}
 
-	// workers
 	var wg sync.WaitGroup
-	m := mod64{p}
+	// the scan runs in Montgomery form (see mont64): x, x^2 and f advance by
+	// additions only, and products are REDCs
+	mt := newMont64(p)
+	AM, BM := mt.to(A%p), mt.to(B%p)
+	oneA := mt.add(mt.one, AM)
 	// unpack table
 	var T32 []uint32
 	var T64 []uint64
+	var R residueBitmap
 	const u32sent = ^uint32(0)
 	const u64sent = ^uint64(0)
-	if mode == ModeTable {
+	switch mode {
+	case ModeTable:
 		if !store64 {
 			T32 = Tany.([]uint32)
 		} else {
 			T64 = Tany.([]uint64)
 		}
+	case ModeHybrid:
+		R = Tany.(residueBitmap)
 	}
 
-	worker := func() {
+	worker := func() error {
 		defer wg.Done()
+		var cur job
+		buf := make([]PointU64, 0, batch)
+		flush := func(last bool) bool {
+			if len(buf) == 0 && !last {
+				return true
+			}
+			select {
+			case batches.to(cur.chunk) <- chunkBatch[PointU64]{chunk: cur.chunk, last: last, pts: buf}:
+				buf = make([]PointU64, 0, batch)
+				return true
+			case <-gctx.Done():
+				return false
+			}
+		}
+		emitPt := func(pt PointU64) { buf = append(buf, pt) }
+		// emitY emits (x, y) and, for y ≠ 0, (x, p-y); ordered output
+		// puts the smaller root first
+		emitY := func(x, y uint64) {
+			if y == 0 {
+				emitPt(PointU64{X: x})
+				return
+			}
+			y2 := (p - y) % p
+			if ordered && y2 < y {
+				y, y2 = y2, y
+			}
+			emitPt(PointU64{X: x, Y: y})
+			emitPt(PointU64{X: x, Y: y2})
+		}
 		for jb := range jobs {
-			x := jb.x0 % p
-			x2 := m.mul(x, x)
+			t0 := time.Now()
+			cur = jb
+			x := mt.to(jb.x0)
+			x2 := mt.mul(x, x)
 			// f = x^3 + A*x + B
-			f := m.add(m.add(m.mul(m.mul(x2, x), 1), m.mul(A, x)), B)
+			f := mt.add(mt.add(mt.mul(x2, x), mt.mul(AM, x)), BM)
 
 			for xx := jb.x0; xx < jb.x1; xx++ {
-				if mode == ModeTable {
+				switch mode {
+				case ModeTable:
 					if !store64 {
-						y := T32[f]
-						if y != u32sent {
-							yy := uint64(y)
-							points <- PointU64{X: x, Y: yy}
-							if yy != 0 {
-								points <- PointU64{X: x, Y: (p - yy) % p}
-							}
+						if y := T32[mt.from(f)]; y != u32sent {
+							emitY(xx, uint64(y))
 						}
 					} else {
-						y := T64[f]
-						if y != u64sent {
-							points <- PointU64{X: x, Y: y}
-							if y != 0 {
-								points <- PointU64{X: x, Y: (p - y) % p}
-							}
+						if y := T64[mt.from(f)]; y != u64sent {
+							emitY(xx, y)
 						}
 					}
-				} else { // on-the-fly
-					leg := legendre64(f, p)
+				case ModeHybrid:
+					if f == 0 {
+						emitPt(PointU64{X: xx, Y: 0})
+					} else if R.has(mt.from(f)) {
+						emitY(xx, mt.from(mt.sqrt(f)))
+					}
+				default: // on-the-fly
+					leg := mt.legendre(f)
 					if leg == 1 {
-						y := tonelli64(f, p)
-						points <- PointU64{X: x, Y: y}
-						if y != 0 {
-							points <- PointU64{X: x, Y: (p - y) % p}
-						}
+						emitY(xx, mt.from(mt.sqrt(f)))
 					} else if leg == 0 { // f==0
-						points <- PointU64{X: x, Y: 0}
+						emitPt(PointU64{X: xx, Y: 0})
 					}
 				}
-				// increment x, x2, f using finite-difference formula
-				// delta = (3x^2 + 3x + 1 + A) mod p
-				delta := m.add(m.add(m.add(m.mul(3, x2), m.mul(3, x)), 1), A)
-				f = m.add(f, delta)
-				x2 = m.add(x2, m.add(m.mul(2, x), 1))
-				x = m.add(x, 1)
+				// increment x, x2, f using finite-difference formula:
+				// delta = 3(x^2 + x) + 1 + A, and x^2 grows by 2x + 1
+				t := mt.add(x2, x)
+				f = mt.add(f, mt.add(mt.add(t, mt.add(t, t)), oneA))
+				x2 = mt.add(x2, mt.add(mt.add(x, x), mt.one))
+				x = mt.add(x, mt.one)
+				if len(buf) > batch-2 && !flush(false) {
+					return nil
+				}
 			}
+			if !flush(true) {
+				return nil
+			}
+			metrics.chunkDone(time.Since(t0))
 		}
+		return nil
 	}
 
+	metrics.scanStarted(strconv.FormatUint(p, 10), strconv.FormatUint(A, 10), strconv.FormatUint(B, 10), workers,
+		batches.queued, batches.capacity)
 	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go worker()
+		g.Go(worker)
 	}
+	g.Go(func() error {
+		wg.Wait()
+		batches.close()
+		return nil
+	})
 
 	// feed jobs
-	const chunks = 1024
-	chunk := (p + chunks - 1) / chunks
-	for s := uint64(0); s < p; s += chunk {
-		e := s + chunk
-		if e > p {
-			e = p
+	g.Go(func() error {
+		defer close(jobs)
+		const chunks = 1024
+		chunk := (p + chunks - 1) / chunks
+		if ordered {
+			chunk = min(chunk, orderedChunkX)
+		}
+		i := 0
+		for s := uint64(0); s < p; s += chunk {
+			if !window.acquire(gctx) {
+				return nil
+			}
+			select {
+			case jobs <- job{chunk: i, x0: s, x1: min(s+chunk, p)}:
+			case <-gctx.Done():
+				return nil
+			}
+			i++
 		}
-		jobs <- job{x0: s, x1: e}
+		return nil
+	})
+
+	// consume
+	for i, emit := range emits {
+		g.Go(func() error {
+			write := func(pts []PointU64) error {
+				for _, pt := range pts {
+					if err := emit(pt); err != nil {
+						return err
+					}
+				}
+				metrics.emitted(len(pts))
+				return nil
+			}
+			merge := newInOrder[PointU64](window)
+			for b := range batches[i] {
+				var err error
+				if ordered {
+					err = merge.add(b, write)
+				} else {
+					err = write(b.pts)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
 	}
-	close(jobs)
-	wg.Wait()
-	close(points)
-	wgW.Wait()
-
-	// point at infinity marker:
-	_ = w.WriteU64(PointU64{X: math.MaxUint64, Y: math.MaxUint64}) // prints -1 -1 if cast to signed; leave as big marker
-	return nil
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
 }
 
 // ------------------- enumeration: big.Int fallback -------------------
 
-func enumerateBig(p, A, B *big.Int, mode Mode, outPath string, workers int, vgBig *visGridBig) error {
+// enumerateBig is enumerateU64 on math/big; it returns the affine count.
+// Primes below 2^127 are scanned in u128 arithmetic (scanU128) instead.
+func enumerateBig(p, A, B *big.Int, mode Mode, out outputSpec, workers int, vgBig *visGridBig, hm *heatmap) (n uint64, err error) {
 	// Only on-the-fly is viable (table would be absurd).
 	if mode == ModeTable {
-		return errors.New("table mode is not supported for big.Int p")
+		return 0, errors.New("table mode is not supported for big.Int p")
 	}
 	if mode == ModeAuto {
 		mode = ModeOnTheFly
 	}
 
-	w, closeFn, err := newTextWriter(outPath)
+	meta := runMeta{P: p.String(), A: A.String(), B: B.String(), Mode: mode, Timestamp: time.Now().UTC()}
+	ws, err := openWriters(out, meta)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer closeFn()
+	defer ws.abort()
 
-	log.Printf("BIG mode p=%s A=%s B=%s workers=%d", p.String(), A.String(), B.String(), workers)
+	log.Printf("BIG mode p=%s A=%s B=%s workers=%d u128=%v", p.String(), A.String(), B.String(), workers, p.BitLen() <= 127)
 
 	type job struct {
+		chunk  int
 		x0, x1 *big.Int // half-open
 	}
 	jobs := make(chan job, workers*2)
-	points := make(chan PointBig, 1<<12)
-
-	// writer
-	var wgW sync.WaitGroup
-	wgW.Add(1)
-	go func() {
-		defer wgW.Done()
-		for pt := range points {
-			if err := w.WriteBig(pt); err != nil {
-				log.Fatalf("write error: %v", err)
+	batches := newFanout[PointBig](len(ws.ws), out.Pipe.chanSize(workers))
+	batch := out.Pipe.batch()
+	var window chunkWindow
+	if out.Ordered {
+		window = make(chunkWindow, workers*4)
+	}
+	g, gctx := errgroup.WithContext(context.Background())
+
+	// writers; writer i counts the affine points it writes in ws.counts[i]
+	for i, w := range ws.ws {
+		g.Go(func() error {
+			write := func(pts []PointBig) error {
+				for _, pt := range pts {
+					if out.Verify {
+						if err := verifyPointBig(p, A, B, pt); err != nil {
+							return err
+						}
+					}
+					if err := w.WriteBig(pt); err != nil {
+						return fmt.Errorf("write error: %w", err)
+					}
+					ws.counts[i]++
+					if vgBig != nil {
+						vgBig.Add(pt.X, pt.Y)
+					}
+					if hm != nil {
+						hm.AddBig(pt.X, pt.Y)
+					}
+				}
+				metrics.emitted(len(pts))
+				putPointBigBatch(pts)
+				return nil
 			}
-			if vgBig != nil {
-				vgBig.Add(pt.X, pt.Y)
+			merge := newInOrder[PointBig](window)
+			for b := range batches[i] {
+				var err error
+				if out.Ordered {
+					err = merge.add(b, write)
+				} else {
+					err = write(b.pts)
+				}
+				if err != nil {
+					return err
+				}
 			}
-		}
-	}()
+			return nil
+		})
+	}
 
 	// worker
 	var wg sync.WaitGroup
-	mod := modBig{p: p}
-	one := big.NewInt(1)
-	two := big.NewInt(2)
-	three := big.NewInt(3)
+	// below 2^127 the workers use two-limb Montgomery arithmetic, above it
+	// a bigScan each; both fill pooled batches the writer hands back
+	var mt *mont128
+	if p.BitLen() <= 127 {
+		m := newMont128(p)
+		mt = &m
+	}
+	var fd *bigField
+	if mt == nil {
+		fd = newBigField(p, A, B)
+	}
 
-	worker := func() {
+	worker := func() error {
 		defer wg.Done()
+		buf := getPointBigBatch()
+		flush := func(chunk int, last bool) bool {
+			if len(buf) == 0 && !last {
+				return true
+			}
+			select {
+			case batches.to(chunk) <- chunkBatch[PointBig]{chunk: chunk, last: last, pts: buf}:
+				buf = getPointBigBatch()
+				return true
+			case <-gctx.Done():
+				return false
+			}
+		}
+		var sc *bigScan
+		if fd != nil {
+			sc = &bigScan{bigField: fd}
+		}
 		for jb := range jobs {
-			// x := x0
-			x := new(big.Int).Set(jb.x0)
-			// x2 := x*x mod p
-			x2 := mod.mul(x, x)
-			// f := x^3 + A*x + B
-			f := mod.add(mod.add(mod.mul(mod.mul(x2, x), one), mod.mul(A, x)), B)
-			for cmp := new(big.Int).Set(jb.x0); cmp.Cmp(jb.x1) < 0; cmp.Add(cmp, one) {
-				leg := legendreBig(f, p)
-				if leg == 1 {
-					y := tonelliBig(f, p)
-					points <- PointBig{X: new(big.Int).Set(x), Y: y}
-					if y.Sign() != 0 {
-						py := new(big.Int).Sub(p, y)
-						points <- PointBig{X: new(big.Int).Set(x), Y: py}
-					}
-				} else if leg == 0 {
-					points <- PointBig{X: new(big.Int).Set(x), Y: new(big.Int)}
-				}
-				// delta = (3x^2 + 3x + 1 + A) mod p
-				d1 := mod.mul(three, x2)
-				d2 := mod.mul(three, x)
-				delta := mod.add(mod.add(mod.add(d1, d2), one), A)
-				f = mod.add(f, delta)
-				// x2 = x2 + (2x+1)
-				t := mod.add(mod.mul(two, x), one)
-				x2 = mod.add(x2, t)
-				x = mod.add(x, one)
+			t0 := time.Now()
+			more := func() bool { return flush(jb.chunk, false) }
+			var ok bool
+			if mt != nil {
+				ok = scanU128(*mt, u128From(jb.x0), u128From(jb.x1), out.Ordered, A, B, batch, &buf, more)
+			} else {
+				ok = sc.scan(jb.x0, jb.x1, out.Ordered, batch, &buf, more)
+			}
+			if !ok || !flush(jb.chunk, true) {
+				return nil
 			}
+			metrics.chunkDone(time.Since(t0))
 		}
+		return nil
 	}
 
+	metrics.scanStarted(p.String(), A.String(), B.String(), workers,
+		batches.queued, batches.capacity)
 	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go worker()
+		g.Go(worker)
 	}
-
-	// Partition [0, p) into ~1024 chunks
-	total := new(big.Int).Set(p)
-	chunks := big.NewInt(1024)
-	chunk := new(big.Int).Add(new(big.Int).Quo(total, chunks), big.NewInt(1))
-	for s := new(big.Int); s.Cmp(p) < 0; s.Add(s, chunk) {
-		e := new(big.Int).Add(s, chunk)
-		if e.Cmp(p) > 0 {
-			e.Set(p)
+	g.Go(func() error {
+		wg.Wait()
+		batches.close()
+		return nil
+	})
+
+	// Partition [0, p) into ~1024 chunks (of at most orderedChunkX x's when ordered)
+	g.Go(func() error {
+		defer close(jobs)
+		total := new(big.Int).Set(p)
+		chunks := big.NewInt(1024)
+		chunk := new(big.Int).Add(new(big.Int).Quo(total, chunks), big.NewInt(1))
+		if out.Ordered && chunk.Cmp(big.NewInt(orderedChunkX)) > 0 {
+			chunk.SetInt64(orderedChunkX)
+		}
+		i := 0
+		for s := new(big.Int); s.Cmp(p) < 0; s.Add(s, chunk) {
+			e := new(big.Int).Add(s, chunk)
+			if e.Cmp(p) > 0 {
+				e.Set(p)
+			}
+			if !window.acquire(gctx) {
+				return nil
+			}
+			select {
+			case jobs <- job{chunk: i, x0: new(big.Int).Set(s), x1: new(big.Int).Set(e)}:
+			case <-gctx.Done():
+				return nil
+			}
+			i++
 		}
-		jobs <- job{x0: new(big.Int).Set(s), x1: new(big.Int).Set(e)}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return ws.total(), err
 	}
-	close(jobs)
-	wg.Wait()
-	close(points)
-	wgW.Wait()
 
-	// point at infinity marker:
-	_ = w.WriteBig(PointBig{X: big.NewInt(-1), Y: big.NewInt(-1)})
-	return nil
+	return ws.finish()
 }
 
 // ------------------- main -------------------
@@ -923,7 +1287,7 @@ func main() {
 			log.Printf("auto-selecting mode (table bytes ≈ %.2f GB, cap=%.2f GB)",
 				float64(tableBytes)/(1<<30), float64(maxMemBytes)/(1<<30))
 		}
-		if err := enumerateU64(pu64, Au64, Bu64, mode, maxMemBytes, *outPath, workers, vgU64); err != nil {
+		if _, err := enumerateU64(pu64, Au64, Bu64, mode, maxMemBytes, outputSpec{Path: *outPath, Format: FormatText}, workers, vgU64, nil, nil); err != nil {
 			log.Fatal(err)
 		}
 		// render after the run, if requested
@@ -940,7 +1304,7 @@ func main() {
 	if mode == ModeTable {
 		log.Fatal("mode=table is not supported when p does not fit in uint64")
 	}
-	if err := enumerateBig(p, A, B, mode, *outPath, workers, vgBig); err != nil {
+	if _, err := enumerateBig(p, A, B, mode, outputSpec{Path: *outPath, Format: FormatText}, workers, vgBig, nil); err != nil {
 		log.Fatal(err)
 	}
 	if *visFlag && vgBig != nil {
@@ -0,0 +1,57 @@
+package ecscan
+
+import (
+	"math/big"
+	"testing"
+)
+
+// secp256k1's field prime: p ≡ 3 mod 4, the common big-path case.
+var benchP, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+
+// BenchmarkBigScan runs the big path's worker loop over 256 x's of
+// y^2 = x^3 + 7 mod benchP, recycling batches the way the writer does.
+func BenchmarkBigScan(b *testing.B) {
+	sc := &bigScan{bigField: newBigField(benchP, big.NewInt(0), big.NewInt(7))}
+	x0, x1 := big.NewInt(1000), big.NewInt(1256)
+	buf := getPointBigBatch()
+	flush := func() bool {
+		putPointBigBatch(buf)
+		buf = getPointBigBatch()
+		return true
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sc.scan(x0, x1, false, pointBatch, &buf, flush)
+		flush()
+	}
+}
+
+// BenchmarkBigScanModBig is the same range through modBig, legendreBig and
+// tonelliBig, as the loop was before bigScan: the baseline to compare
+// BenchmarkBigScan against.
+func BenchmarkBigScanModBig(b *testing.B) {
+	p, A, B := benchP, big.NewInt(0), big.NewInt(7)
+	m := modBig{p: p}
+	three := big.NewInt(3)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var buf []PointBig
+		x := big.NewInt(1000)
+		x2 := m.mul(x, x)
+		f := m.add(m.add(m.mul(x2, x), m.mul(A, x)), B)
+		for x.Cmp(big.NewInt(1256)) < 0 {
+			switch legendreBig(f, p) {
+			case 1:
+				y := tonelliBig(f, p)
+				buf = append(buf, PointBig{X: new(big.Int).Set(x), Y: y},
+					PointBig{X: new(big.Int).Set(x), Y: new(big.Int).Sub(p, y)})
+			case 0:
+				buf = append(buf, PointBig{X: new(big.Int).Set(x), Y: new(big.Int)})
+			}
+			delta := m.add(m.add(m.add(m.mul(three, x2), m.mul(three, x)), b1), A)
+			f = m.add(f, delta)
+			x2 = m.add(x2, m.add(m.add(x, x), b1))
+			x = m.add(x, b1)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package ecscan
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// --- sage / gp: a script that defines the curve and the points ---
+//
+// `sage points.sage` or `gp -q points.gp` rebuilds E, loads the points and
+// checks them independently: each on the curve, no repeats, and (for a
+// complete output, not one --split part) #P + 1 = #E(F_p) by the CAS's own
+// point counting. The point at infinity closes the list, so a truncated
+// script fails to parse rather than passing on fewer points.
+
+// scriptDialect is what differs between the two languages.
+type scriptDialect struct {
+	format  Format
+	comment string // line comment marker
+	curve   string // defines p and E and opens the list P; args p, A, B
+	point   string // one point; args x, y
+	close   string // closes P
+	checks  string // on-curve and duplicate checks
+	count   string // the point count check
+	done    string // reports success
+}
+
+var sageDialect = scriptDialect{
+	format:  FormatSage,
+	comment: "#",
+	curve:   "p = %s\nE = EllipticCurve(GF(p), [%s, %s])\nP = [",
+	point:   "(%s,%s)",
+	close:   "\n]\n",
+	checks: `assert all(E.is_on_curve(x, y) for x, y in P), "ecscan: a point is not on the curve"
+assert len(set(P)) == len(P), "ecscan: duplicate points"
+`,
+	count: `assert len(P) + 1 == E.cardinality(), "ecscan: #E = %d, the file has %d points" % (E.cardinality(), len(P) + 1)
+`,
+	done: `print("ecscan: %d affine points on %s check out" % (len(P), E))
+`,
+}
+
+// GP reads one line per command, so the list goes inside braces.
+var gpDialect = scriptDialect{
+	format:  FormatGP,
+	comment: `\\`,
+	curve:   "p = %s;\nE = ellinit([%s, %s], p);\n{\nP = [",
+	point:   "[%s,%s]",
+	close:   "\n];\n}\n",
+	checks: `for(i = 1, #P, if(!ellisoncurve(E, Mod(P[i], p)), error("ecscan: ", P[i], " is not on the curve")));
+if(#Set(P) != #P, error("ecscan: duplicate points"));
+`,
+	count: `if(#P + 1 != ellcard(E), error("ecscan: #E = ", ellcard(E), ", the file has ", #P + 1, " points"));
+`,
+	done: `print("ecscan: ", #P, " affine points over F_", p, " check out");
+`,
+}
+
+type scriptWriter struct {
+	footer
+	d     scriptDialect
+	whole bool // the output holds every point, so the count can be checked
+	n     uint64
+}
+
+func newScriptWriter(ft footer, meta runMeta, d scriptDialect) (*scriptWriter, error) {
+	if ft.sum != nil {
+		if _, err := fmt.Fprintf(ft.bw, "%s ecscan v=%d format=%s p=%s A=%s B=%s mode=%s%s timestamp=%s\n",
+			d.comment, outputVersion, d.format, meta.P, meta.A, meta.B, meta.Mode, meta.shardTag(" "), meta.Timestamp.Format(time.RFC3339)); err != nil {
+			return nil, err
+		}
+	}
+	if meta.Shard != "" {
+		if _, err := fmt.Fprintf(ft.bw, "%s part %s of the points: the count is checked over all parts only\n", d.comment, meta.Shard); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := fmt.Fprintf(ft.bw, d.curve, meta.P, meta.A, meta.B); err != nil {
+		return nil, err
+	}
+	return &scriptWriter{footer: ft, d: d, whole: meta.Shard == ""}, nil
+}
+
+func (w *scriptWriter) write(x, y string) error {
+	sep := ",\n"
+	if w.n == 0 {
+		sep = "\n"
+	}
+	w.n++
+	_, err := fmt.Fprintf(w.bw, "%s"+w.d.point, sep, x, y)
+	return err
+}
+
+// WriteInf closes the list and appends the checks: the point at infinity is
+// always written last.
+func (w *scriptWriter) WriteInf() error {
+	tail := w.d.close + w.d.checks
+	if w.whole {
+		tail += w.d.count
+	}
+	_, err := w.bw.WriteString(tail + w.d.done)
+	return err
+}
+
+func (w *scriptWriter) WriteU64(p PointU64) error {
+	return w.write(strconv.FormatUint(p.X, 10), strconv.FormatUint(p.Y, 10))
+}
+func (w *scriptWriter) WriteBig(p PointBig) error {
+	return w.write(p.X.String(), p.Y.String())
+}
+func (w *scriptWriter) WriteExt2(p PointExt2) error {
+	return errors.New("--format sage/gp does not support --ext 2")
+}
+func (w *scriptWriter) WriteFooter(points uint64) error { return w.writeComment(w.d.comment, points) }
+func (w *scriptWriter) Close() error                    { return w.bw.Flush() }
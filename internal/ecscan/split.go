@@ -0,0 +1,186 @@
+package ecscan
+
+import (
+	"bufio"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxSplitParts bounds the part count --split-every may ask for: every part
+// is created, so a tiny N over a large p would otherwise mean millions of
+// files.
+const maxSplitParts = 100_000
+
+// splitWriter spreads the points over numbered output files, each a complete
+// output of its own: the same header (plus shard=i/n), its points, O and, with --header, a footer counting that part alone. A part is
+// closed once it holds out.SplitSize bytes before compression, or, with
+// out.SplitEvery, once x leaves its range [(i-1)N, iN); the x ranges need
+// the points in x order, so --split-every implies --ordered.
+type splitWriter struct {
+	out   outputSpec
+	meta  runMeta
+	every *big.Int // out.SplitEvery as a big.Int for the big path
+	parts uint64   // part count with --split-every, 0 with --split-size
+
+	i      uint64 // current part, from 1
+	cur    pointWriter
+	bw     *bufio.Writer
+	count  *countWriter
+	close  func() error
+	points uint64 // affine points in the current part
+}
+
+// countWriter counts the bytes that pass through it.
+type countWriter struct{ n uint64 }
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	c.n += uint64(len(b))
+	return len(b), nil
+}
+
+func newSplitWriter(out outputSpec, meta runMeta) (pointWriter, func() error, error) {
+	w := &splitWriter{out: out, meta: meta}
+	if out.SplitEvery > 0 {
+		p, ok := new(big.Int).SetString(meta.P, 10)
+		if !ok {
+			return nil, nil, fmt.Errorf("split: bad p %q", meta.P)
+		}
+		w.every = new(big.Int).SetUint64(out.SplitEvery)
+		n := new(big.Int).Add(p, new(big.Int).Sub(w.every, big.NewInt(1)))
+		n.Div(n, w.every)
+		if !n.IsUint64() || n.Uint64() > maxSplitParts {
+			return nil, nil, fmt.Errorf("--split-every %d makes %s parts (at most %d)", out.SplitEvery, n, maxSplitParts)
+		}
+		w.parts = n.Uint64()
+	}
+	if err := w.open(1); err != nil {
+		return nil, nil, err
+	}
+	return w, func() error { return w.close() }, nil
+}
+
+// partPath numbers part i of path: "{part}" is replaced if present, else the
+// number goes before the extension (pts.txt.gz → pts.0001.txt.gz).
+func partPath(path string, i uint64) string {
+	num := fmt.Sprintf("%04d", i)
+	if strings.Contains(path, "{part}") {
+		return strings.ReplaceAll(path, "{part}", num)
+	}
+	dir, base := filepath.Split(path)
+	if j := strings.IndexByte(base, '.'); j > 0 {
+		return dir + base[:j] + "." + num + base[j:]
+	}
+	return path + "." + num
+}
+
+func (w *splitWriter) open(i uint64) error {
+	out, meta := w.out, w.meta
+	out.Path = partPath(w.out.Path, i)
+	meta.Shard = strconv.FormatUint(i, 10)
+	if w.parts > 0 {
+		meta.Shard += "/" + strconv.FormatUint(w.parts, 10)
+	}
+	w.count = &countWriter{}
+	cur, bw, closeFn, err := openStream(out, meta, w.count)
+	if err != nil {
+		return err
+	}
+	w.i, w.cur, w.bw, w.close, w.points = i, cur, bw, closeFn, 0
+	return nil
+}
+
+// end writes O and the footer that close the current part.
+func (w *splitWriter) end() error {
+	if err := w.cur.WriteInf(); err != nil {
+		return err
+	}
+	return w.cur.WriteFooter(w.points)
+}
+
+// next closes the current part and opens the one after it.
+func (w *splitWriter) next() error {
+	err := w.close()
+	w.close = func() error { return nil }
+	if err != nil {
+		return err
+	}
+	return w.open(w.i + 1)
+}
+
+// advance moves on to part i, writing any empty parts in between.
+func (w *splitWriter) advance(i uint64) error {
+	for w.i < i {
+		if err := w.end(); err != nil {
+			return err
+		}
+		if err := w.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partOf returns the part a point with x-coordinate x (xBig on the big
+// path) belongs in.
+func (w *splitWriter) partOf(x uint64, xBig *big.Int) uint64 {
+	if w.out.SplitEvery > 0 {
+		if xBig != nil {
+			return new(big.Int).Div(xBig, w.every).Uint64() + 1
+		}
+		return x/w.out.SplitEvery + 1
+	}
+	if w.points > 0 && w.count.n+uint64(w.bw.Buffered()) >= w.out.SplitSize {
+		return w.i + 1
+	}
+	return w.i
+}
+
+func (w *splitWriter) WriteU64(p PointU64) error {
+	if err := w.advance(w.partOf(p.X, nil)); err != nil {
+		return err
+	}
+	w.points++
+	return w.cur.WriteU64(p)
+}
+
+func (w *splitWriter) WriteBig(p PointBig) error {
+	if err := w.advance(w.partOf(0, p.X)); err != nil {
+		return err
+	}
+	w.points++
+	return w.cur.WriteBig(p)
+}
+
+func (w *splitWriter) WriteExt2(p PointExt2) error {
+	if err := w.advance(w.partOf(p.X0, nil)); err != nil {
+		return err
+	}
+	w.points++
+	return w.cur.WriteExt2(p)
+}
+
+// WriteInf closes the points of the last part written to.
+func (w *splitWriter) WriteInf() error { return w.cur.WriteInf() }
+
+// WriteFooter ends the last part written to (the enumerator has already
+// sent O), then, with --split-every, writes the empty parts past
+// the largest x so that all n exist.
+func (w *splitWriter) WriteFooter(points uint64) error {
+	if err := w.cur.WriteFooter(w.points); err != nil {
+		return err
+	}
+	for w.i < w.parts {
+		if err := w.next(); err != nil {
+			return err
+		}
+		if err := w.end(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *splitWriter) Close() error { return w.cur.Close() }
@@ -0,0 +1,172 @@
+package ecscan
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go driver, registers "sqlite"
+)
+
+// sqlitePrefix marks an --out value as an SQLite sink, e.g. "sqlite:points.db".
+const sqlitePrefix = "sqlite:"
+
+func isSQLitePath(path string) bool { return strings.HasPrefix(path, sqlitePrefix) }
+
+// Rows are committed in batches so a crash loses at most one batch and the
+// journal stays small.
+const sqliteBatch = 100_000
+
+// Schema: one row per curve in `curves` (the metadata record) and one row per
+// affine point in `points`, indexed by (curve_id, x). Coordinates have no
+// declared type so u64 values are stored as INTEGER and big values as TEXT,
+// both without lossy affinity conversion.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS curves (
+	id       INTEGER PRIMARY KEY,
+	p        TEXT NOT NULL,
+	A        TEXT NOT NULL,
+	B        TEXT NOT NULL,
+	mode     TEXT NOT NULL,
+	created  TEXT NOT NULL,
+	points   INTEGER,          -- affine point count, set when the scan completes
+	has_inf  INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS points (
+	curve_id INTEGER NOT NULL REFERENCES curves(id),
+	x        NOT NULL,
+	y        NOT NULL
+);
+CREATE INDEX IF NOT EXISTS points_curve_x ON points(curve_id, x);
+`
+
+type sqliteWriter struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	ins     *sql.Stmt
+	curveID int64
+	pending int
+	total   int64
+	inf     bool
+}
+
+func newSQLiteWriter(path string, meta runMeta) (*sqliteWriter, func() error, error) {
+	file := strings.TrimPrefix(path, sqlitePrefix)
+	if file == "" {
+		return nil, nil, fmt.Errorf("sqlite: empty database path in %q", path)
+	}
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sqlite: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("sqlite schema: %w", err)
+	}
+	res, err := db.Exec(`INSERT INTO curves (p, A, B, mode, created) VALUES (?, ?, ?, ?, ?)`,
+		meta.P, meta.A, meta.B, string(meta.Mode), meta.Timestamp.Format(time.RFC3339))
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("sqlite curve row: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("sqlite curve id: %w", err)
+	}
+	w := &sqliteWriter{db: db, curveID: id}
+	if err := w.begin(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	closeFn := func() error {
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("sqlite close: %w", err)
+		}
+		return nil
+	}
+	return w, closeFn, nil
+}
+
+func (w *sqliteWriter) begin() error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("sqlite begin: %w", err)
+	}
+	ins, err := tx.Prepare(`INSERT INTO points (curve_id, x, y) VALUES (?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("sqlite prepare: %w", err)
+	}
+	w.tx, w.ins, w.pending = tx, ins, 0
+	return nil
+}
+
+func (w *sqliteWriter) commit() error {
+	if w.tx == nil {
+		return nil
+	}
+	w.ins.Close()
+	err := w.tx.Commit()
+	w.tx, w.ins = nil, nil
+	if err != nil {
+		return fmt.Errorf("sqlite commit: %w", err)
+	}
+	return nil
+}
+
+func (w *sqliteWriter) insert(x, y any) error {
+	if _, err := w.ins.Exec(w.curveID, x, y); err != nil {
+		return fmt.Errorf("sqlite insert: %w", err)
+	}
+	w.total++
+	w.pending++
+	if w.pending >= sqliteBatch {
+		if err := w.commit(); err != nil {
+			return err
+		}
+		return w.begin()
+	}
+	return nil
+}
+
+func (w *sqliteWriter) WriteU64(p PointU64) error {
+	// p < 2^63 on this path, so coordinates fit SQLite's signed INTEGER.
+	return w.insert(int64(p.X), int64(p.Y))
+}
+
+func (w *sqliteWriter) WriteBig(p PointBig) error {
+	return w.insert(p.X.String(), p.Y.String())
+}
+
+func (w *sqliteWriter) WriteExt2(p PointExt2) error {
+	return fmt.Errorf("sqlite sink does not support --ext 2 points")
+}
+
+// WriteInf sets has_inf on the curve row.
+func (w *sqliteWriter) WriteInf() error {
+	w.inf = true
+	return nil
+}
+
+// WriteFooter is a no-op: Close records the count on the curve row.
+func (w *sqliteWriter) WriteFooter(points uint64) error { return nil }
+
+// Close commits the last batch, records the point count on the curve row and
+// closes the database. Safe to call more than once.
+func (w *sqliteWriter) Close() error {
+	if w.db == nil {
+		return nil
+	}
+	err := w.commit()
+	if err == nil {
+		_, err = w.db.Exec(`UPDATE curves SET points = ?, has_inf = ? WHERE id = ?`, w.total, w.inf, w.curveID)
+	}
+	if cerr := w.db.Close(); err == nil {
+		err = cerr
+	}
+	w.db = nil
+	return err
+}
@@ -0,0 +1,102 @@
+package ecscan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"time"
+
+	"ectorus/internal/factor"
+)
+
+// Summary is the machine-readable record of a finished enumeration. A full
+// scan is also a point count, so it carries the trace of Frobenius and the
+// flags researchers filter curve families by.
+type Summary struct {
+	Type    string  `json:"type"` // always "summary"
+	P       string  `json:"p"`
+	A       string  `json:"A"`
+	B       string  `json:"B"`
+	Ext     int     `json:"ext,omitempty"`
+	Poly    string  `json:"poly,omitempty"` // GF(2^m) reduction polynomial (p = 2, ext = m)
+	Mode    Mode    `json:"mode"`
+	Affine  uint64  `json:"affinePoints"`
+	N       string  `json:"pointCount"` // #E(F_q), q = p^ext, including O
+	Trace   string  `json:"trace"`      // q + 1 - N
+	Seconds float64 `json:"seconds"`
+
+	Anomalous     bool `json:"anomalous"`     // N == q
+	Supersingular bool `json:"supersingular"` // trace ≡ 0 mod p
+	PrimeOrder    bool `json:"primeOrder"`    // N prime
+
+	// Factorization is N as "2^2 · 3 · 89" (an unsplit part in brackets);
+	// LargestPrime is the order r of the largest prime subgroup and
+	// Cofactor is N / r, both omitted when N is not fully factored.
+	Factorization string `json:"factorization"`
+	LargestPrime  string `json:"largestPrime,omitempty"`
+	Cofactor      string `json:"cofactor,omitempty"`
+}
+
+// newSummary derives the count flags from the number of affine points seen.
+func newSummary(p, A, B *big.Int, ext int, mode Mode, affine uint64, elapsed time.Duration) Summary {
+	q := new(big.Int).Exp(p, big.NewInt(int64(ext)), nil)
+	N := new(big.Int).SetUint64(affine)
+	N.Add(N, big.NewInt(1))
+	t := new(big.Int).Add(q, big.NewInt(1))
+	t.Sub(t, N)
+	s := Summary{
+		Type: "summary",
+		P:    p.String(), A: A.String(), B: B.String(),
+		Mode: mode, Affine: affine,
+		N: N.String(), Trace: t.String(),
+		Seconds:       elapsed.Seconds(),
+		Anomalous:     N.Cmp(q) == 0,
+		Supersingular: new(big.Int).Mod(t, p).Sign() == 0,
+		PrimeOrder:    N.ProbablyPrime(32),
+	}
+	f := factor.Of(N)
+	s.Factorization = f.String()
+	if r := f.Largest(); r != nil {
+		s.LargestPrime, s.Cofactor = r.String(), f.Cofactor().String()
+	}
+	if ext != 1 {
+		s.Ext = ext
+	}
+	return s
+}
+
+// report logs s and, when w is set, writes it there as one JSON line.
+func (s Summary) report(w io.Writer) error {
+	log.Printf("N=%s = %s trace=%s anomalous=%v supersingular=%v primeOrder=%v (%.2fs)",
+		s.N, s.Factorization, s.Trace, s.Anomalous, s.Supersingular, s.PrimeOrder, s.Seconds)
+	if w == nil {
+		return nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// openSummary opens the --summary destination: nil for "", stderr for "-"
+// (stdout may be carrying the points), else a file that every curve of the
+// run appends a line to.
+func openSummary(path string) (io.Writer, func() error, error) {
+	nop := func() error { return nil }
+	switch path {
+	case "":
+		return nil, nop, nil
+	case "-":
+		return os.Stderr, nop, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("summary: %w", err)
+	}
+	return f, f.Close, nil
+}
@@ -0,0 +1,52 @@
+package ecscan
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestSqrtTableDeterministic builds the sqrt table with different worker
+// counts, which must give the same bytes (the table is meant to be cached
+// as a file), holding the smaller root of every residue.
+func TestSqrtTableDeterministic(t *testing.T) {
+	for _, p := range []uint64{10007, 1000003} {
+		var first32 []uint32
+		var first64 []uint64
+		for _, workers := range []int{1, 3, 8, 64} {
+			T32, err := buildSqrtTableU64(p, workers, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			T64, err := buildSqrtTableU64(p, workers, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first32 == nil {
+				first32, first64 = T32.([]uint32), T64.([]uint64)
+				continue
+			}
+			if !slices.Equal(T32.([]uint32), first32) || !slices.Equal(T64.([]uint64), first64) {
+				t.Fatalf("p=%d: the table built with %d workers differs from the one built with 1", p, workers)
+			}
+		}
+
+		half := (p - 1) / 2
+		residues := uint64(0)
+		for r, y := range first64 {
+			if uint64(first32[r]) != y && !(first32[r] == ^uint32(0) && y == ^uint64(0)) {
+				t.Fatalf("p=%d: T[%d] is %d in the uint32 table and %d in the uint64 one", p, r, first32[r], y)
+			}
+			if y == ^uint64(0) {
+				continue
+			}
+			if y > half || y*y%p != uint64(r) {
+				t.Fatalf("p=%d: T[%d] = %d, want the root of %d in [0, %d]", p, r, y, r, half)
+			}
+			residues++
+		}
+		// 0 and the (p-1)/2 nonzero squares
+		if residues != half+1 {
+			t.Fatalf("p=%d: %d entries hold a root, want %d", p, residues, half+1)
+		}
+	}
+}
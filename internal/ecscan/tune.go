@@ -0,0 +1,76 @@
+package ecscan
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"runtime"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// ------------------- --workers auto -------------------
+
+// workerTrial is how long --workers auto runs the scan at each candidate
+// worker count.
+const workerTrial = 300 * time.Millisecond
+
+// calibrateWorkers picks the worker count for --workers auto. It runs the
+// scan from x = 0 for workerTrial at half, one, two, four and eight times
+// GOMAXPROCS, formatting into io.Discard as out.Format would (or only
+// counting, for --count-only), and keeps the count with the best point
+// rate; a larger count has to beat a smaller one by 5% to be taken. table
+// is mode's table for p, built once by the caller. If a trial gets through
+// the whole scan, p is too small for the choice to matter and fallback is
+// returned.
+func calibrateWorkers(p, A, B uint64, mode Mode, table any, out outputSpec, countOnly bool, fallback int) int {
+	n := runtime.GOMAXPROCS(0)
+	cands := slices.Compact([]int{max(n/2, 1), n, 2 * n, 4 * n, 8 * n})
+
+	// the trials are not part of the scan the metrics describe
+	m := metrics
+	metrics = nil
+	defer func() { metrics = m }()
+
+	best, bestRate := fallback, 0.0
+	for _, w := range cands {
+		var pw pointWriter
+		if !countOnly {
+			var err error
+			if pw, err = newFormatWriter(footer{bw: bufio.NewWriterSize(io.Discard, defaultWriterBuffer)}, out, runMeta{P: strconv.FormatUint(p, 10)}); err != nil {
+				return fallback
+			}
+		}
+		var pts uint64
+		emit := func(pt PointU64) error {
+			pts++
+			if pw != nil {
+				return pw.WriteU64(pt)
+			}
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), workerTrial)
+		start := time.Now()
+		err := scanU64(ctx, p, A, B, mode, table, w, false, out.Pipe, []func(PointU64) error{emit})
+		elapsed := time.Since(start)
+		cancel()
+		switch {
+		case err == nil:
+			log.Printf("workers auto: p is scanned within one trial, keeping %d workers", fallback)
+			return fallback
+		case !errors.Is(err, context.DeadlineExceeded):
+			log.Printf("workers auto: trial failed (%v), keeping %d workers", err, fallback)
+			return fallback
+		}
+		rate := float64(pts) / elapsed.Seconds()
+		log.Printf("workers auto: %d workers, %.3g points/s", w, rate)
+		if rate > bestRate*1.05 {
+			best, bestRate = w, rate
+		}
+	}
+	log.Printf("workers auto => %d", best)
+	return best
+}
@@ -0,0 +1,256 @@
+package ecscan
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// ------------------- two-limb arithmetic (2^63 ≤ p < 2^127) -------------------
+
+// u128 is an unsigned 128-bit integer.
+type u128 struct{ hi, lo uint64 }
+
+func u128From(z *big.Int) u128 {
+	return u128{hi: new(big.Int).Rsh(z, 64).Uint64(), lo: z.Uint64()}
+}
+
+// into sets z to a, in z's own words where they suffice.
+func (a u128) into(z *big.Int) {
+	if bits.UintSize == 64 {
+		ws := z.Bits()
+		if cap(ws) < 2 {
+			ws = make([]big.Word, 2)
+		}
+		ws = ws[:2]
+		ws[0], ws[1] = big.Word(a.lo), big.Word(a.hi)
+		z.SetBits(ws)
+		return
+	}
+	z.Lsh(z.SetUint64(a.hi), 64).Add(z, new(big.Int).SetUint64(a.lo))
+}
+
+func (a u128) less(b u128) bool { return a.hi < b.hi || a.hi == b.hi && a.lo < b.lo }
+
+func (a u128) isZero() bool { return a.hi == 0 && a.lo == 0 }
+
+// add and sub wrap mod 2^128.
+func (a u128) add(b u128) u128 {
+	lo, c := bits.Add64(a.lo, b.lo, 0)
+	hi, _ := bits.Add64(a.hi, b.hi, c)
+	return u128{hi, lo}
+}
+
+func (a u128) sub(b u128) u128 {
+	lo, c := bits.Sub64(a.lo, b.lo, 0)
+	hi, _ := bits.Sub64(a.hi, b.hi, c)
+	return u128{hi, lo}
+}
+
+func (a u128) bitLen() int {
+	if a.hi != 0 {
+		return 64 + bits.Len64(a.hi)
+	}
+	return bits.Len64(a.lo)
+}
+
+func (a u128) bit(i int) bool {
+	if i >= 64 {
+		return a.hi>>(i-64)&1 == 1
+	}
+	return a.lo>>i&1 == 1
+}
+
+func (a u128) rsh1() u128 { return u128{a.hi >> 1, a.hi<<63 | a.lo>>1} }
+
+// mont128 is mont64 with R = 2^128, for odd p < 2^127: products go
+// through a two-word CIOS Montgomery multiplication, so the big-prime scan
+// loop runs without allocating.
+type mont128 struct {
+	p    u128
+	pinv uint64 // -p^-1 mod 2^64 (CIOS reduces a word at a time)
+	r2   u128   // R^2 mod p
+	one  u128   // R mod p
+	q    u128   // p - 1 = q·2^s
+	s    int
+	c    u128 // z^q for a non-residue z
+}
+
+func newMont128(P *big.Int) mont128 {
+	p := u128From(P)
+	inv := p.lo
+	for i := 0; i < 6; i++ {
+		inv *= 2 - p.lo*inv
+	}
+	R := new(big.Int).Lsh(big.NewInt(1), 128)
+	mt := mont128{
+		p:    p,
+		pinv: -inv,
+		one:  u128From(new(big.Int).Mod(R, P)),
+		r2:   u128From(new(big.Int).Mod(new(big.Int).Mul(R, R), P)),
+	}
+	mt.q, mt.s = p.sub(u128{0, 1}), 0
+	for mt.q.lo&1 == 0 {
+		mt.q = mt.q.rsh1()
+		mt.s++
+	}
+	z := mt.add(mt.one, mt.one)
+	for mt.legendre(z) != -1 {
+		z = mt.add(z, mt.one)
+	}
+	mt.c = mt.pow(z, mt.q)
+	return mt
+}
+
+// mul is REDC(a·b) by CIOS over two 64-bit words. a, b < p < 2^127 keep
+// each partial product's high word below 2^63, so the carries fit, and the
+// result below 2p.
+func (mt mont128) mul(a, b u128) u128 {
+	var t0, t1, t2 uint64
+	for _, bi := range [2]uint64{b.lo, b.hi} {
+		// t += a·bi
+		h0, l0 := bits.Mul64(a.lo, bi)
+		h1, l1 := bits.Mul64(a.hi, bi)
+		var c uint64
+		t0, c = bits.Add64(t0, l0, 0)
+		h0 += c
+		t1, c = bits.Add64(t1, l1, 0)
+		h1 += c
+		t1, c = bits.Add64(t1, h0, 0)
+		h1 += c
+		t2, c = bits.Add64(t2, h1, 0)
+		t3 := c
+		// t = (t + m·p) / 2^64, m making the low word vanish
+		m := t0 * mt.pinv
+		g0, k0 := bits.Mul64(m, mt.p.lo)
+		g1, k1 := bits.Mul64(m, mt.p.hi)
+		_, c = bits.Add64(t0, k0, 0)
+		g0 += c
+		t0, c = bits.Add64(t1, k1, 0)
+		g1 += c
+		t0, c = bits.Add64(t0, g0, 0)
+		g1 += c
+		t1, c = bits.Add64(t2, g1, 0)
+		t2 = t3 + c
+	}
+	r := u128{t1, t0}
+	if t2 != 0 || !r.less(mt.p) {
+		r = r.sub(mt.p)
+	}
+	return r
+}
+
+// add and sub need no carry out: both operands are below p < 2^127.
+func (mt mont128) add(a, b u128) u128 {
+	c := a.add(b)
+	if !c.less(mt.p) {
+		c = c.sub(mt.p)
+	}
+	return c
+}
+
+func (mt mont128) sub(a, b u128) u128 {
+	if b.less(a) || b == a {
+		return a.sub(b)
+	}
+	return a.add(mt.p).sub(b)
+}
+
+func (mt mont128) to(a u128) u128   { return mt.mul(a, mt.r2) }
+func (mt mont128) from(a u128) u128 { return mt.mul(a, u128{0, 1}) }
+
+// pow is left-to-right, from e's top bit, so a small exponent (p just
+// above 2^63) costs only its own length.
+func (mt mont128) pow(a, e u128) u128 {
+	r := mt.one
+	for i := e.bitLen() - 1; i >= 0; i-- {
+		r = mt.mul(r, r)
+		if e.bit(i) {
+			r = mt.mul(r, a)
+		}
+	}
+	return r
+}
+
+// legendre is mont64.legendre.
+func (mt mont128) legendre(a u128) int {
+	if a.isZero() {
+		return 0
+	}
+	switch mt.pow(a, mt.p.rsh1()) { // (p-1)/2, p odd
+	case mt.one:
+		return 1
+	case mt.p.sub(mt.one):
+		return -1
+	}
+	return 0
+}
+
+// sqrt is mont64.sqrt.
+func (mt mont128) sqrt(a u128) u128 {
+	c := mt.c
+	x := mt.pow(a, mt.q.add(u128{0, 1}).rsh1())
+	t := mt.pow(a, mt.q)
+	si := mt.s
+	for t != mt.one {
+		i := 1
+		t2i := mt.mul(t, t)
+		for t2i != mt.one {
+			t2i = mt.mul(t2i, t2i)
+			i++
+			if i == si {
+				panic("mont128.sqrt: non-residue")
+			}
+		}
+		b := c
+		for j := 0; j < si-i-1; j++ {
+			b = mt.mul(b, b)
+		}
+		x = mt.mul(x, b)
+		c = mt.mul(b, b)
+		t = mt.mul(t, c)
+		si = i
+	}
+	return x
+}
+
+// scanU128 is enumerateBig's inner loop for p < 2^127 over x in [x0, x1):
+// scanU64's finite differences on mont128 residues. Points are appended
+// to *buf as PointBig through nextPointBig (the writer path is shared);
+// flush hands it over once it holds batch points and reports false once
+// the scan is cancelled.
+func scanU128(mt mont128, x0, x1 u128, ordered bool, A, B *big.Int, batch int, buf *[]PointBig, flush func() bool) bool {
+	AM, BM := mt.to(u128From(A)), mt.to(u128From(B))
+	oneA := mt.add(mt.one, AM)
+	x := mt.to(x0)
+	x2 := mt.mul(x, x)
+	f := mt.add(mt.add(mt.mul(x2, x), mt.mul(AM, x)), BM)
+	var pt *PointBig
+	for xx := x0; xx.less(x1); xx = xx.add(u128{0, 1}) {
+		switch mt.legendre(f) {
+		case 1:
+			y := mt.from(mt.sqrt(f))
+			py := mt.p.sub(y)
+			if ordered && py.less(y) {
+				y, py = py, y
+			}
+			*buf, pt = nextPointBig(*buf)
+			xx.into(pt.X)
+			y.into(pt.Y)
+			*buf, pt = nextPointBig(*buf)
+			xx.into(pt.X)
+			py.into(pt.Y)
+		case 0:
+			*buf, pt = nextPointBig(*buf)
+			xx.into(pt.X)
+			pt.Y.SetInt64(0)
+		}
+		if len(*buf) >= batch && !flush() {
+			return false
+		}
+		t := mt.add(x2, x)
+		f = mt.add(f, mt.add(mt.add(t, mt.add(t, t)), oneA))
+		x2 = mt.add(x2, mt.add(mt.add(x, x), mt.one))
+		x = mt.add(x, mt.one)
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package ecscan
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// nextPrime is the least prime ≥ n.
+func nextPrime(n *big.Int) *big.Int {
+	p := new(big.Int).Set(n)
+	for !p.ProbablyPrime(32) {
+		p.Add(p, big.NewInt(1))
+	}
+	return p
+}
+
+func pow2(k uint) *big.Int { return new(big.Int).Lsh(big.NewInt(1), k) }
+
+func toBig(a u128) *big.Int {
+	z := new(big.Int)
+	a.into(z)
+	return z
+}
+
+// mont128Primes run from just above the uint64 path to 2^127 - 1, the
+// largest p mont128 takes, where a CIOS partial product's high word and the
+// final subtraction are tightest.
+func mont128Primes() []*big.Int {
+	return []*big.Int{
+		nextPrime(pow2(63)),
+		prevPrime(new(big.Int).Sub(pow2(64), big.NewInt(1))),
+		nextPrime(pow2(64)),
+		prevPrime(pow2(100)),
+		nextPrime(new(big.Int).Add(pow2(126), new(big.Int).Sub(pow2(64), big.NewInt(1)))), // low word near 2^64
+		prevPrime(new(big.Int).Sub(pow2(127), big.NewInt(2))),
+		new(big.Int).Sub(pow2(127), big.NewInt(1)), // M127
+	}
+}
+
+// TestMont128 checks the two-limb Montgomery arithmetic against math/big.
+func TestMont128(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	R := pow2(128)
+	for _, P := range mont128Primes() {
+		if !P.ProbablyPrime(32) || P.BitLen() > 127 {
+			t.Fatalf("%s is not a prime below 2^127", P)
+		}
+		mt := newMont128(P)
+		Rinv := new(big.Int).ModInverse(R, P)
+		vs := operands(P, rng)
+		for _, a := range vs {
+			au := u128From(a)
+			if toBig(au).Cmp(a) != 0 {
+				t.Fatalf("p=%s: %s does not survive u128From and into", P, a)
+			}
+			if got, want := toBig(mt.to(au)), new(big.Int).Mod(new(big.Int).Mul(a, R), P); got.Cmp(want) != 0 {
+				t.Fatalf("p=%s: to(%s) = %s, want %s", P, a, got, want)
+			}
+			if mt.from(mt.to(au)) != au {
+				t.Fatalf("p=%s: from(to(%s)) = %s", P, a, toBig(mt.from(mt.to(au))))
+			}
+			for _, b := range vs {
+				bu := u128From(b)
+				ab := new(big.Int).Mul(a, b)
+				if got, want := toBig(mt.mul(au, bu)), new(big.Int).Mod(new(big.Int).Mul(ab, Rinv), P); got.Cmp(want) != 0 {
+					t.Fatalf("p=%s: REDC(%s·%s) = %s, want %s", P, a, b, got, want)
+				}
+				if got, want := toBig(mt.from(mt.mul(mt.to(au), mt.to(bu)))), ab.Mod(ab, P); got.Cmp(want) != 0 {
+					t.Fatalf("p=%s: %s·%s = %s, want %s", P, a, b, got, want)
+				}
+				if got, want := toBig(mt.add(au, bu)), new(big.Int).Mod(new(big.Int).Add(a, b), P); got.Cmp(want) != 0 {
+					t.Fatalf("p=%s: %s + %s = %s, want %s", P, a, b, got, want)
+				}
+				if got, want := toBig(mt.sub(au, bu)), new(big.Int).Mod(new(big.Int).Sub(a, b), P); got.Cmp(want) != 0 {
+					t.Fatalf("p=%s: %s - %s = %s, want %s", P, a, b, got, want)
+				}
+			}
+			if got, want := toBig(mt.from(mt.pow(mt.to(au), u128From(a)))), new(big.Int).Exp(a, a, P); got.Cmp(want) != 0 {
+				t.Fatalf("p=%s: %s^%s = %s, want %s", P, a, a, got, want)
+			}
+			if a.Sign() == 0 {
+				continue
+			}
+			if got, want := mt.legendre(mt.to(au)), big.Jacobi(a, P); got != want {
+				t.Fatalf("p=%s: legendre(%s) = %d, want %d", P, a, got, want)
+			}
+			if mt.legendre(mt.to(au)) == 1 {
+				y := toBig(mt.from(mt.sqrt(mt.to(au))))
+				if y.Mul(y, y).Mod(y, P).Cmp(a) != 0 {
+					t.Fatalf("p=%s: sqrt(%s) does not square to it", P, a)
+				}
+			}
+		}
+	}
+}
+
+// TestScanU128MatchesBigScan runs the mont128 loop and bigScan over the
+// same x-ranges of a curve mod a ~2^100 prime, at both ends of [0, p).
+func TestScanU128MatchesBigScan(t *testing.T) {
+	P := prevPrime(pow2(100))
+	rng := rand.New(rand.NewSource(2))
+	A, B := new(big.Int).Rand(rng, P), new(big.Int).Rand(rng, P)
+	mt := newMont128(P)
+	sc := &bigScan{bigField: newBigField(P, A, B)}
+	const n = 2000
+	for _, x0 := range []*big.Int{big.NewInt(0), new(big.Int).Sub(P, big.NewInt(n))} {
+		x1 := new(big.Int).Add(x0, big.NewInt(n))
+		never := func() bool { t.Fatal("flushed below the batch size"); return false }
+		var got, want []PointBig
+		if !scanU128(mt, u128From(x0), u128From(x1), true, A, B, 2*n+1, &got, never) {
+			t.Fatal("scanU128 stopped")
+		}
+		if !sc.scan(x0, x1, true, 2*n+1, &want, never) {
+			t.Fatal("bigScan stopped")
+		}
+		if len(got) != len(want) {
+			t.Fatalf("x in [%s, %s): %d points from scanU128, %d from bigScan", x0, x1, len(got), len(want))
+		}
+		if len(got) == 0 {
+			t.Fatalf("x in [%s, %s): no points at all", x0, x1)
+		}
+		for i := range got {
+			if got[i].X.Cmp(want[i].X) != 0 || got[i].Y.Cmp(want[i].Y) != 0 {
+				t.Fatalf("point %d: (%s, %s) from scanU128, (%s, %s) from bigScan", i, got[i].X, got[i].Y, want[i].X, want[i].Y)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ecscan
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+
+	"ectorus/internal/count"
+)
+
+// ------------------- --verify: self-checks -------------------
+
+// verifyPointU64 checks y^2 ≡ x^3 + Ax + B mod p for a point about to be
+// written; it costs a handful of mulmods next to the sqrt that found y.
+func verifyPointU64(p, A, B uint64, pt PointU64) error {
+	m := mod64{p}
+	x, y := pt.X, pt.Y
+	if x >= p || y >= p || m.mul(y, y) != m.add(m.add(m.mul(m.mul(x, x), x), m.mul(A, x)), B) {
+		return fmt.Errorf("verify: (%d, %d) is not on y^2 = x^3 + %dx + %d mod %d", x, y, A, B, p)
+	}
+	return nil
+}
+
+func verifyPointBig(p, A, B *big.Int, pt PointBig) error {
+	m := modBig{p: p}
+	x, y := pt.X, pt.Y
+	if x.Sign() < 0 || x.Cmp(p) >= 0 || y.Sign() < 0 || y.Cmp(p) >= 0 ||
+		m.mul(y, y).Cmp(m.add(m.add(m.mul(m.mul(x, x), x), m.mul(A, x)), B)) != 0 {
+		return fmt.Errorf("verify: (%s, %s) is not on y^2 = x^3 + %sx + %s mod %s", x, y, A, B, p)
+	}
+	return nil
+}
+
+func verifyPointExt2(f fp2, A, B uint64, pt PointExt2) error {
+	x, y := elt2{pt.X0, pt.X1}, elt2{pt.Y0, pt.Y1}
+	if f.mul(y, y) != f.add(f.add(f.mul(f.mul(x, x), x), f.scale(A, x)), elt2{B, 0}) {
+		return fmt.Errorf("verify: (%d + %di, %d + %di) is not on y^2 = x^3 + %dx + %d over F_%d^2",
+			pt.X0, pt.X1, pt.Y0, pt.Y1, A, B, f.m.p)
+	}
+	return nil
+}
+
+// verifyCount compares the number of affine points a scan produced with
+// the Legendre sum from internal/count, which shares no code with the
+// enumeration. Over F_{p^2} it uses #E(F_{p^2}) = p^2 + 1 - (a_p^2 - 2p).
+// Primes above limit are skipped (the check costs about as much as the
+// scan itself).
+func verifyCount(p, A, B uint64, ext int, affine, limit uint64) error {
+	if p > limit {
+		log.Printf("verify: p > --verify-count-max %d, point count not cross-checked", limit)
+		return nil
+	}
+	N1 := count.LegendreU64(p, A%p, B%p)
+	want := new(big.Int).SetUint64(N1)
+	if ext == 2 {
+		P := new(big.Int).SetUint64(p)
+		ap := count.Trace(P, want)
+		want.Mul(P, P)
+		want.Add(want, big.NewInt(1))
+		want.Sub(want, new(big.Int).Mul(ap, ap))
+		want.Add(want, new(big.Int).Lsh(P, 1))
+	}
+	got := new(big.Int).SetUint64(affine)
+	got.Add(got, big.NewInt(1))
+	if got.Cmp(want) != 0 {
+		return fmt.Errorf("verify: scan found N = %s points but the Legendre sum gives %s", got, want)
+	}
+	log.Printf("verify: N = %s matches the Legendre sum", got)
+	return nil
+}
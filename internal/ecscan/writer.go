@@ -0,0 +1,660 @@
+package ecscan
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"ectorus/pkg/encoding"
+)
+
+// Format selects how enumerated points are encoded on the output stream.
+type Format string
+
+const (
+	FormatText   Format = "text"
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	// FormatCompressed is SEC1 point compression: x and the parity of y.
+	FormatCompressed Format = "compressed"
+	// FormatSage and FormatGP write a script that defines the curve and
+	// the points, and checks them when run.
+	FormatSage Format = "sage"
+	FormatGP   Format = "gp"
+)
+
+func parseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "text", "txt":
+		return FormatText, nil
+	case "csv":
+		return FormatCSV, nil
+	case "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	case "compressed", "sec1":
+		return FormatCompressed, nil
+	case "sage":
+		return FormatSage, nil
+	case "gp", "pari":
+		return FormatGP, nil
+	default:
+		return FormatText, fmt.Errorf("unknown format %q (want text|csv|ndjson|compressed|sage|gp)", s)
+	}
+}
+
+// Coords selects how text, csv and ndjson write a point: affine (x, y), or
+// as a triple with Z = 1 for tools that take projective (X:Y:Z, x = X/Z)
+// or Jacobian (x = X/Z^2, y = Y/Z^3) input. The two triples differ only
+// in O, (0:1:0) and (1:1:0), written in place of the inf record.
+type Coords string
+
+const (
+	CoordsAffine     Coords = "affine"
+	CoordsProjective Coords = "projective"
+	CoordsJacobian   Coords = "jacobian"
+)
+
+func parseCoords(s string) (Coords, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "affine":
+		return CoordsAffine, nil
+	case "projective", "proj":
+		return CoordsProjective, nil
+	case "jacobian", "jac":
+		return CoordsJacobian, nil
+	default:
+		return CoordsAffine, fmt.Errorf("unknown coords %q (want affine|projective|jacobian)", s)
+	}
+}
+
+// triple reports whether points are written with a Z coordinate.
+func (c Coords) triple() bool { return c == CoordsProjective || c == CoordsJacobian }
+
+// infXY is X and Y of O in c, whose Z is 0.
+func (c Coords) infXY() (string, string) {
+	if c == CoordsJacobian {
+		return "1", "1"
+	}
+	return "0", "1"
+}
+
+// Compression selects an optional streaming compressor wrapped around the output.
+type Compression string
+
+const (
+	CompressNone Compression = "none"
+	CompressGzip Compression = "gzip"
+	CompressZstd Compression = "zstd"
+)
+
+func parseCompression(s string) (Compression, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "none":
+		return CompressNone, nil
+	case "gzip", "gz":
+		return CompressGzip, nil
+	case "zstd", "zst":
+		return CompressZstd, nil
+	default:
+		return CompressNone, fmt.Errorf("unknown compression %q (want none|gzip|zstd)", s)
+	}
+}
+
+// outputSpec bundles where and how points are written.
+type outputSpec struct {
+	Path     string // "-" for stdout
+	Format   Format
+	Coords   Coords // --coords: affine pairs, or triples with Z = 1 (text, csv, ndjson)
+	Header   bool   // emit a metadata record before the points
+	Compress Compression
+	Ordered  bool // --ordered: points sorted by x, then y
+	Verify   bool // --verify: check every point is on the curve before writing it
+	// SplitSize / SplitEvery split the output into numbered parts, by size
+	// before compression or by x-range (see splitWriter).
+	SplitSize  uint64
+	SplitEvery uint64
+	// VerifyMax is the largest p whose final count --verify cross-checks
+	// against a Legendre sum.
+	VerifyMax uint64
+	// WriterBuffer is the size of the buffer in front of the output
+	// (--writer-buffer, 0 = defaultWriterBuffer); Pipe sizes the hand-off
+	// from the workers to the writer goroutine.
+	WriterBuffer int
+	Pipe         pipeSpec
+	// Writers > 1 writes from that many goroutines, each owning a share of
+	// the chunks, to parts of Path, merged into Path itself with Merge
+	// (see writerSet).
+	Writers int
+	Merge   bool
+	bare    bool // a part to be merged: points only, no header or column line
+}
+
+// outputVersion is the version in the --header record; bump it when the
+// layout of the header, points or footer changes. Version 2 closes the
+// points with a typed inf record where version 1 wrote a -1 or MaxUint64
+// sentinel point.
+const outputVersion = 2
+
+// runMeta describes the run so outputs can be self-describing.
+type runMeta struct {
+	P, A, B   string
+	Mode      Mode
+	Ext       int    // extension degree of the coordinate field (0/1 = F_p)
+	Poly      string // reduction polynomial of GF(2^m) as a hex mask ("" over F_p and F_{p^2})
+	Shard     string // "i/n" (just "i" with --split-size) for one part of several
+	Coords    Coords // set from outputSpec.Coords by newFormatWriter
+	Timestamp time.Time
+}
+
+// extTag renders the extension degree for text headers ("" over F_p).
+func (m runMeta) extTag(sep string) string {
+	if m.Ext <= 1 {
+		return ""
+	}
+	return fmt.Sprintf("%sext=%d", sep, m.Ext)
+}
+
+// polyTag is extTag for the GF(2^m) reduction polynomial.
+func (m runMeta) polyTag(sep string) string {
+	if m.Poly == "" {
+		return ""
+	}
+	return sep + "poly=" + m.Poly
+}
+
+// coordsTag is extTag for --coords ("" for affine).
+func (m runMeta) coordsTag(sep string) string {
+	if !m.Coords.triple() {
+		return ""
+	}
+	return sep + "coords=" + string(m.Coords)
+}
+
+// shardTag is extTag for the shard.
+func (m runMeta) shardTag(sep string) string {
+	if m.Shard == "" {
+		return ""
+	}
+	return sep + "shard=" + m.Shard
+}
+
+// footer is the trailer a --header output ends with: the affine point count
+// and a SHA-256 over every byte before it (header, points and O,
+// before compression), so a consumer can tell a truncated or altered file
+// from a complete one.
+type footer struct {
+	bw  *bufio.Writer
+	sum hash.Hash // fed under bw; nil without --header
+}
+
+// digest flushes bw so the hash has seen everything written so far.
+func (f footer) digest() (string, error) {
+	if err := f.bw.Flush(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(f.sum.Sum(nil)), nil
+}
+
+// writeComment is the text/csv footer: "# end points=N sha256=HEX", with
+// mark in place of '#' for formats that comment differently.
+func (f footer) writeComment(mark string, points uint64) error {
+	if f.sum == nil {
+		return nil
+	}
+	d, err := f.digest()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f.bw, "%s end points=%d sha256=%s\n", mark, points, d)
+	return err
+}
+
+// ------------------- writer -------------------
+
+type pointWriter interface {
+	WriteU64(p PointU64) error
+	WriteBig(p PointBig) error
+	WriteExt2(p PointExt2) error
+	// WriteInf writes the point at infinity, the record every output's
+	// points end with: "inf" in text and csv, {"inf":true} in ndjson, 00
+	// compressed, or the Z = 0 triple with --coords.
+	WriteInf() error
+	// WriteFooter ends the output after WriteInf; points is the number of
+	// affine points written.
+	WriteFooter(points uint64) error
+	Close() error
+}
+
+// newPointWriter opens out.Path and returns a writer for out.Format. If
+// out.Header is set the metadata record is written before returning, and
+// WriteFooter appends the checksum footer. An out.Path of the form
+// "sqlite:FILE" selects the SQLite sink instead, and out.SplitSize or
+// out.SplitEvery numbered parts.
+func newPointWriter(out outputSpec, meta runMeta) (pointWriter, func() error, error) {
+	if isSQLitePath(out.Path) {
+		return newSQLiteWriter(out.Path, meta)
+	}
+	if out.SplitSize > 0 || out.SplitEvery > 0 {
+		return newSplitWriter(out, meta)
+	}
+	w, _, closeFn, err := openStream(out, meta, nil)
+	return w, closeFn, err
+}
+
+// openStream is newPointWriter for a single file. It also returns the
+// buffered writer, and tee, if non-nil, sees the bytes it has flushed.
+func openStream(out outputSpec, meta runMeta, tee io.Writer) (pointWriter, *bufio.Writer, func() error, error) {
+	var sum hash.Hash
+	if out.Header {
+		sum = sha256.New()
+	}
+	bw, closeFn, err := openOutput(out.Path, out.Compress, out.WriterBuffer, sum, tee)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	w, err := newFormatWriter(footer{bw: bw, sum: sum}, out, meta)
+	if err != nil {
+		closeFn()
+		return nil, nil, nil, err
+	}
+	return w, bw, closeFn, nil
+}
+
+// newFormatWriter is the pointWriter for out.Format on ft.
+func newFormatWriter(ft footer, out outputSpec, meta runMeta) (pointWriter, error) {
+	meta.Coords = out.Coords
+	switch out.Format {
+	case FormatCSV:
+		if out.bare {
+			return newBareCSVWriter(ft, meta), nil
+		}
+		return newCSVWriter(ft, meta)
+	case FormatNDJSON:
+		return newNDJSONWriter(ft, meta)
+	case FormatCompressed:
+		return newCompressedWriter(ft, meta)
+	case FormatSage:
+		return newScriptWriter(ft, meta, sageDialect)
+	case FormatGP:
+		return newScriptWriter(ft, meta, gpDialect)
+	}
+	return newTextWriter(ft, meta)
+}
+
+// defaultWriterBuffer is the --writer-buffer default.
+const defaultWriterBuffer = 4 << 20
+
+// openOutput opens path ("-" = stdout) and layers an optional compressor
+// under a bufio buffer of size bytes (0 = defaultWriterBuffer). Compression
+// runs on the caller's goroutine, i.e. the single writer goroutine, while
+// workers keep enumerating. A non-nil sum or tee is fed the uncompressed
+// bytes as the buffer drains.
+func openOutput(path string, compress Compression, size int, sum hash.Hash, tee io.Writer) (*bufio.Writer, func() error, error) {
+	var f *os.File
+	var err error
+	if path == "-" {
+		f = os.Stdout
+	} else {
+		f, err = os.Create(path)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	var sink io.Writer = f
+	var zc io.Closer
+	switch compress {
+	case CompressGzip:
+		gz := gzip.NewWriter(f)
+		sink, zc = gz, gz
+	case CompressZstd:
+		zw, err := zstd.NewWriter(f)
+		if err != nil {
+			if f != os.Stdout {
+				f.Close()
+			}
+			return nil, nil, fmt.Errorf("zstd: %w", err)
+		}
+		sink, zc = zw, zw
+	}
+	if sum != nil {
+		sink = io.MultiWriter(sum, sink)
+	}
+	if tee != nil {
+		sink = io.MultiWriter(tee, sink)
+	}
+	if size <= 0 {
+		size = defaultWriterBuffer
+	}
+	w := bufio.NewWriterSize(sink, size)
+	// closeFn flushes and closes every layer, reporting the first error.
+	closeFn := func() error {
+		err := w.Flush()
+		if zc != nil {
+			if cerr := zc.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if f != os.Stdout {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}
+	return w, closeFn, nil
+}
+
+// --- text: "x y" per line ---
+//
+// Lines are formatted by strconv/big.Int appends into a reused buffer and
+// handed to bw in one Write, so a point costs no allocation and no fmt
+// parsing; bw's 4 MB buffer does the batching into large writes. O is
+// the line "inf". With --coords projective|jacobian each line is "x y 1",
+// and O "0 1 0" or "1 1 0".
+
+type textWriter struct {
+	footer
+	coords Coords
+	buf    []byte
+}
+
+func newTextWriter(ft footer, meta runMeta) (*textWriter, error) {
+	if ft.sum != nil {
+		if _, err := fmt.Fprintf(ft.bw, "# ecscan v=%d p=%s A=%s B=%s mode=%s%s%s%s%s timestamp=%s\n",
+			outputVersion, meta.P, meta.A, meta.B, meta.Mode, meta.extTag(" "), meta.polyTag(" "), meta.shardTag(" "), meta.coordsTag(" "), meta.Timestamp.Format(time.RFC3339)); err != nil {
+			return nil, err
+		}
+	}
+	return &textWriter{footer: ft, coords: meta.Coords, buf: make([]byte, 0, 64)}, nil
+}
+
+// line writes the values in w.buf, space-separated, as one line, with
+// Z = 1 appended for a triple.
+func (w *textWriter) line() error {
+	if w.coords.triple() {
+		w.buf = append(w.buf, '1', ' ')
+	}
+	w.buf[len(w.buf)-1] = '\n'
+	_, err := w.bw.Write(w.buf)
+	return err
+}
+
+func (w *textWriter) WriteInf() error {
+	if !w.coords.triple() {
+		_, err := w.bw.WriteString("inf\n")
+		return err
+	}
+	x, y := w.coords.infXY()
+	_, err := w.bw.WriteString(x + " " + y + " 0\n")
+	return err
+}
+
+func (w *textWriter) WriteU64(p PointU64) error {
+	w.buf = strconv.AppendUint(w.buf[:0], p.X, 10)
+	w.buf = append(w.buf, ' ')
+	w.buf = strconv.AppendUint(w.buf, p.Y, 10)
+	w.buf = append(w.buf, ' ')
+	return w.line()
+}
+func (w *textWriter) WriteBig(p PointBig) error {
+	w.buf = p.X.Append(w.buf[:0], 10)
+	w.buf = append(w.buf, ' ')
+	w.buf = p.Y.Append(w.buf, 10)
+	w.buf = append(w.buf, ' ')
+	return w.line()
+}
+func (w *textWriter) WriteExt2(p PointExt2) error {
+	w.buf = w.buf[:0]
+	for _, v := range [4]uint64{p.X0, p.X1, p.Y0, p.Y1} {
+		w.buf = strconv.AppendUint(w.buf, v, 10)
+		w.buf = append(w.buf, ' ')
+	}
+	return w.line()
+}
+func (w *textWriter) WriteFooter(points uint64) error { return w.writeComment("#", points) }
+func (w *textWriter) Close() error                    { return w.bw.Flush() }
+
+// --- csv: "x,y" column header, metadata as leading '#' comment lines ---
+//
+// Load with e.g. pandas.read_csv(path, comment="#") or DuckDB read_csv.
+// O is a row of "inf" in every column. --coords projective|jacobian adds a
+// z column.
+
+type csvWriter struct {
+	footer
+	coords Coords
+	cols   int // coordinate columns: 2, or 4 over F_{p^2}
+}
+
+func newCSVWriter(ft footer, meta runMeta) (*csvWriter, error) {
+	bw := ft.bw
+	if ft.sum != nil {
+		extra := ""
+		if meta.Ext > 1 {
+			extra += meta.extTag("# ") + "\n"
+		}
+		if meta.Poly != "" {
+			extra += meta.polyTag("# ") + "\n"
+		}
+		if meta.Shard != "" {
+			extra += meta.shardTag("# ") + "\n"
+		}
+		if meta.Coords.triple() {
+			extra += meta.coordsTag("# ") + "\n"
+		}
+		if _, err := fmt.Fprintf(bw, "# ecscan v=%d\n# p=%s\n# A=%s\n# B=%s\n# mode=%s\n%s# timestamp=%s\n",
+			outputVersion, meta.P, meta.A, meta.B, meta.Mode, extra, meta.Timestamp.Format(time.RFC3339)); err != nil {
+			return nil, err
+		}
+	}
+	cols := "x,y\n"
+	switch {
+	case meta.Ext == 2:
+		cols = "x0,x1,y0,y1\n"
+	case meta.Coords.triple():
+		cols = "x,y,z\n"
+	}
+	if _, err := bw.WriteString(cols); err != nil {
+		return nil, err
+	}
+	return newBareCSVWriter(ft, meta), nil
+}
+
+// newBareCSVWriter is the csvWriter without the header and column line.
+func newBareCSVWriter(ft footer, meta runMeta) *csvWriter {
+	w := &csvWriter{footer: ft, coords: meta.Coords, cols: 2}
+	if meta.Ext == 2 {
+		w.cols = 4
+	}
+	return w
+}
+func (w *csvWriter) WriteU64(p PointU64) error {
+	z := ""
+	if w.coords.triple() {
+		z = ",1"
+	}
+	_, err := w.bw.WriteString(fmt.Sprintf("%d,%d%s\n", p.X, p.Y, z))
+	return err
+}
+func (w *csvWriter) WriteBig(p PointBig) error {
+	z := ""
+	if w.coords.triple() {
+		z = ",1"
+	}
+	_, err := w.bw.WriteString(fmt.Sprintf("%s,%s%s\n", p.X.String(), p.Y.String(), z))
+	return err
+}
+func (w *csvWriter) WriteExt2(p PointExt2) error {
+	_, err := w.bw.WriteString(fmt.Sprintf("%d,%d,%d,%d\n", p.X0, p.X1, p.Y0, p.Y1))
+	return err
+}
+func (w *csvWriter) WriteInf() error {
+	if !w.coords.triple() {
+		_, err := w.bw.WriteString("inf" + strings.Repeat(",inf", w.cols-1) + "\n")
+		return err
+	}
+	x, y := w.coords.infXY()
+	_, err := w.bw.WriteString(x + "," + y + ",0\n")
+	return err
+}
+func (w *csvWriter) WriteFooter(points uint64) error { return w.writeComment("#", points) }
+func (w *csvWriter) Close() error                    { return w.bw.Flush() }
+
+// --- ndjson: one JSON object per line, {"x":..,"y":..} ---
+//
+// Coordinates are emitted as bare JSON integers (arbitrary precision in the
+// text); F_{p^2} coordinates as [real, imag] pairs. The point at infinity
+// becomes {"inf":true}, or with --coords projective|jacobian every point
+// gets a "z" and O is {"x":0,"y":1,"z":0} or {"x":1,"y":1,"z":0}.
+
+type ndjsonWriter struct {
+	footer
+	coords Coords
+}
+
+type ndjsonMeta struct {
+	Type      string `json:"type"`
+	Version   int    `json:"version"`
+	P         string `json:"p"`
+	A         string `json:"A"`
+	B         string `json:"B"`
+	Mode      Mode   `json:"mode"`
+	Ext       int    `json:"ext,omitempty"`
+	Poly      string `json:"poly,omitempty"`
+	Shard     string `json:"shard,omitempty"`
+	Coords    Coords `json:"coords,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// ndjsonEnd is the footer record.
+type ndjsonEnd struct {
+	Type   string `json:"type"`
+	Points uint64 `json:"points"`
+	SHA256 string `json:"sha256"`
+}
+
+func newNDJSONWriter(ft footer, meta runMeta) (*ndjsonWriter, error) {
+	if ft.sum != nil {
+		rec := ndjsonMeta{
+			Type: "meta", Version: outputVersion, P: meta.P, A: meta.A, B: meta.B, Mode: meta.Mode, Ext: meta.Ext,
+			Poly: meta.Poly, Shard: meta.Shard, Timestamp: meta.Timestamp.Format(time.RFC3339),
+		}
+		if meta.Coords.triple() {
+			rec.Coords = meta.Coords
+		}
+		if err := json.NewEncoder(ft.bw).Encode(rec); err != nil {
+			return nil, err
+		}
+	}
+	return &ndjsonWriter{footer: ft, coords: meta.Coords}, nil
+}
+
+// WriteInf writes O, as the flag object or a triple.
+func (w *ndjsonWriter) WriteInf() error {
+	if !w.coords.triple() {
+		_, err := w.bw.WriteString("{\"inf\":true}\n")
+		return err
+	}
+	x, y := w.coords.infXY()
+	_, err := w.bw.WriteString("{\"x\":" + x + ",\"y\":" + y + ",\"z\":0}\n")
+	return err
+}
+
+// point writes {"x":x,"y":y}, with "z":1 for a triple.
+func (w *ndjsonWriter) point(x, y string) error {
+	z := ""
+	if w.coords.triple() {
+		z = ",\"z\":1"
+	}
+	_, err := w.bw.WriteString("{\"x\":" + x + ",\"y\":" + y + z + "}\n")
+	return err
+}
+
+func (w *ndjsonWriter) WriteU64(p PointU64) error {
+	return w.point(strconv.FormatUint(p.X, 10), strconv.FormatUint(p.Y, 10))
+}
+func (w *ndjsonWriter) WriteBig(p PointBig) error {
+	return w.point(p.X.String(), p.Y.String())
+}
+func (w *ndjsonWriter) WriteExt2(p PointExt2) error {
+	_, err := w.bw.WriteString(fmt.Sprintf("{\"x\":[%d,%d],\"y\":[%d,%d]}\n", p.X0, p.X1, p.Y0, p.Y1))
+	return err
+}
+func (w *ndjsonWriter) WriteFooter(points uint64) error {
+	if w.sum == nil {
+		return nil
+	}
+	d, err := w.digest()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w.bw).Encode(ndjsonEnd{Type: "end", Points: points, SHA256: d})
+}
+func (w *ndjsonWriter) Close() error { return w.bw.Flush() }
+
+// --- compressed: one SEC1 compressed point per line, in hex ---
+//
+// "02" (y even) or "03" (y odd) followed by x, big-endian, in as many bytes
+// as p needs; the point at infinity is "00". Half the size of text, or
+// less; ecdecompress, or any ptfile reader that knows the curve, recovers
+// y by a square root.
+
+type compressedWriter struct {
+	footer
+	size int    // bytes of x
+	buf  []byte // the encoding, then its hex
+}
+
+func newCompressedWriter(ft footer, meta runMeta) (*compressedWriter, error) {
+	p, ok := new(big.Int).SetString(meta.P, 10)
+	if !ok {
+		return nil, fmt.Errorf("compressed: bad p %q", meta.P)
+	}
+	if ft.sum != nil {
+		if _, err := fmt.Fprintf(ft.bw, "# ecscan v=%d format=%s p=%s A=%s B=%s mode=%s%s timestamp=%s\n",
+			outputVersion, FormatCompressed, meta.P, meta.A, meta.B, meta.Mode, meta.shardTag(" "), meta.Timestamp.Format(time.RFC3339)); err != nil {
+			return nil, err
+		}
+	}
+	return &compressedWriter{footer: ft, size: encoding.FieldSize(p)}, nil
+}
+
+// write puts out the encoding in w.buf as a hex line.
+func (w *compressedWriter) write() error {
+	n := len(w.buf)
+	w.buf = hex.AppendEncode(w.buf, w.buf)
+	w.buf = append(w.buf, '\n')
+	_, err := w.bw.Write(w.buf[n:])
+	return err
+}
+
+func (w *compressedWriter) WriteU64(p PointU64) error {
+	w.buf = encoding.AppendCompressedU64(w.buf[:0], w.size, p.X, p.Y)
+	return w.write()
+}
+func (w *compressedWriter) WriteBig(p PointBig) error {
+	w.buf = encoding.AppendCompressed(w.buf[:0], w.size, p.X, p.Y)
+	return w.write()
+}
+func (w *compressedWriter) WriteExt2(p PointExt2) error {
+	return errors.New("--format compressed does not support --ext 2")
+}
+func (w *compressedWriter) WriteInf() error {
+	_, err := w.bw.WriteString("00\n")
+	return err
+}
+func (w *compressedWriter) WriteFooter(points uint64) error { return w.writeComment("#", points) }
+func (w *compressedWriter) Close() error                    { return w.bw.Flush() }
@@ -0,0 +1,152 @@
+package ecscan
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ------------------- --writers: parallel output writers -------------------
+
+// writerSet is where a scan writes: one pointWriter, or with --writers N
+// one per writer goroutine. Each of those is a complete output of its own
+// at partPath(out.Path, i), with shard=i/N in its header, unless
+// out.Merge is set: then the writers fill bare temporary files (points
+// only) next to out.Path, and finish copies them into the one output.
+type writerSet struct {
+	out    outputSpec
+	meta   runMeta
+	ws     []pointWriter
+	closes []func() error
+	tmp    []string // with out.Merge, writer i's temporary file
+	counts []uint64 // affine points per writer
+	done   bool
+}
+
+func openWriters(out outputSpec, meta runMeta) (*writerSet, error) {
+	s := &writerSet{out: out, meta: meta}
+	if out.Writers <= 1 {
+		w, closeFn, err := newPointWriter(out, meta)
+		if err != nil {
+			return nil, err
+		}
+		s.add(w, closeFn)
+		return s, nil
+	}
+	for i := 1; i <= out.Writers; i++ {
+		po, pm := out, meta
+		if out.Merge {
+			dir := ""
+			if out.Path != "-" {
+				dir = filepath.Dir(out.Path)
+			}
+			f, err := os.CreateTemp(dir, "ecscan-part-*")
+			if err != nil {
+				s.abort()
+				return nil, err
+			}
+			f.Close()
+			s.tmp = append(s.tmp, f.Name())
+			po = outputSpec{Path: f.Name(), Format: out.Format, Coords: out.Coords, WriterBuffer: out.WriterBuffer, bare: true}
+		} else {
+			po.Path = partPath(out.Path, uint64(i))
+			pm.Shard = strconv.Itoa(i) + "/" + strconv.Itoa(out.Writers)
+		}
+		w, _, closeFn, err := openStream(po, pm, nil)
+		if err != nil {
+			s.abort()
+			return nil, err
+		}
+		s.add(w, closeFn)
+	}
+	return s, nil
+}
+
+func (s *writerSet) add(w pointWriter, closeFn func() error) {
+	s.ws = append(s.ws, w)
+	s.closes = append(s.closes, closeFn)
+	s.counts = append(s.counts, 0)
+}
+
+// total is the number of affine points written so far.
+func (s *writerSet) total() uint64 {
+	var n uint64
+	for _, c := range s.counts {
+		n += c
+	}
+	return n
+}
+
+// abort closes whatever finish has not, after an error, and removes the
+// temporary files; it does nothing after finish.
+func (s *writerSet) abort() {
+	if s.done {
+		return
+	}
+	s.done = true
+	for _, c := range s.closes {
+		c()
+	}
+	for _, t := range s.tmp {
+		os.Remove(t)
+	}
+}
+
+// finish ends the output: every output gets O, then its footer. It
+// returns the number of affine points written.
+func (s *writerSet) finish() (n uint64, err error) {
+	n = s.total()
+	if s.tmp != nil {
+		return n, s.merge()
+	}
+	s.done = true
+	for i, w := range s.ws {
+		if err == nil {
+			if err = w.WriteInf(); err == nil {
+				err = w.WriteFooter(s.counts[i])
+			}
+		}
+		if cerr := s.closes[i](); err == nil {
+			err = cerr
+		}
+	}
+	return n, err
+}
+
+// merge is finish for out.Merge: it closes the bare parts and copies them,
+// in writer order, between the header and O of out.Path.
+func (s *writerSet) merge() (err error) {
+	defer s.abort()
+	for i, c := range s.closes {
+		s.closes[i] = func() error { return nil }
+		if err := c(); err != nil {
+			return err
+		}
+	}
+	w, bw, closeFn, err := openStream(s.out, s.meta, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := closeFn(); err == nil {
+			err = cerr
+		}
+	}()
+	for _, t := range s.tmp {
+		f, err := os.Open(t)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(bw, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("merge %s: %w", t, err)
+		}
+	}
+	if err := w.WriteInf(); err != nil {
+		return err
+	}
+	return w.WriteFooter(s.total())
+}
@@ -0,0 +1,209 @@
+// Package ecsearch backs cmd/ecsearch: walk (A, B) over a fixed p, count
+// each curve, and stop at the first whose group order is cofactor × prime.
+package ecsearch
+
+import (
+	crand "crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	mrand "math/rand"
+	"os"
+	"strings"
+
+	"ectorus/internal/count"
+	"ectorus/internal/ec"
+)
+
+type Config struct {
+	P, A, B        *big.Int // A, B: sequential starting point
+	Cofactor       *big.Int // --cofactor: accept N = cofactor·r, r prime
+	Strategy       string   // --strategy: random|sequential
+	Count          string   // --count: auto|legendre|bsgs|mestre
+	MaxTries       int      // --max-tries
+	Seed           int64    // --seed: 0 => crypto/rand
+	AllowAnomalous bool     // --allow-anomalous: accept r = p
+	JSON           bool     // --json
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("ecsearch", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	var (
+		pStr      = fs.String("p", "", "prime modulus p > 3 (decimal, 0x-hex or e.g. 2^61-1; required)")
+		AStr      = fs.String("A", "0", "sequential: fixed A")
+		BStr      = fs.String("B", "1", "sequential: first B (then B+1, B+2, ...)")
+		hStr      = fs.String("cofactor", "1", "accept #E = cofactor × prime")
+		strategy  = fs.String("strategy", "random", "random|sequential")
+		countWith = fs.String("count", "auto", "point counting: auto|legendre|bsgs|mestre")
+		maxTries  = fs.Int("max-tries", 100_000, "give up after this many curves")
+		seed      = fs.Int64("seed", 0, "seed for random A, B and points (0 = crypto/rand)")
+		anomalous = fs.Bool("allow-anomalous", false, "accept curves with prime order r = p")
+		jsonOut   = fs.Bool("json", false, "emit JSON instead of text")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(*pStr) == "" {
+		return nil, errors.New("missing required --p")
+	}
+	cfg := &Config{
+		Strategy: strings.ToLower(strings.TrimSpace(*strategy)),
+		Count:    strings.ToLower(strings.TrimSpace(*countWith)),
+		MaxTries: *maxTries, Seed: *seed, AllowAnomalous: *anomalous, JSON: *jsonOut,
+	}
+	var err error
+	if cfg.P, err = parseInt(*pStr, "p"); err != nil {
+		return nil, err
+	}
+	if cfg.P.Cmp(big.NewInt(3)) <= 0 || !cfg.P.ProbablyPrime(32) {
+		return nil, errors.New("--p must be a prime > 3")
+	}
+	if cfg.A, err = parseInt(*AStr, "A"); err != nil {
+		return nil, err
+	}
+	if cfg.B, err = parseInt(*BStr, "B"); err != nil {
+		return nil, err
+	}
+	if cfg.Cofactor, err = parseInt(*hStr, "cofactor"); err != nil {
+		return nil, err
+	}
+	if cfg.Cofactor.Sign() <= 0 {
+		return nil, errors.New("--cofactor must be positive")
+	}
+	if cfg.Strategy != "random" && cfg.Strategy != "sequential" {
+		return nil, fmt.Errorf("bad --strategy %q (want random|sequential)", *strategy)
+	}
+	switch cfg.Count {
+	case "auto", "legendre", "bsgs", "mestre":
+	default:
+		return nil, fmt.Errorf("bad --count %q (want auto|legendre|bsgs|mestre)", *countWith)
+	}
+	if cfg.MaxTries <= 0 {
+		return nil, errors.New("--max-tries must be positive")
+	}
+	return cfg, nil
+}
+
+// Result is the curve Search settled on.
+type Result struct {
+	P         string    `json:"p"`
+	A         string    `json:"A"`
+	B         string    `json:"B"`
+	N         string    `json:"pointCount"`
+	Cofactor  string    `json:"cofactor"`
+	R         string    `json:"order"` // prime order of the subgroup generated by G
+	G         [2]string `json:"generator"`
+	Tries     int       `json:"tries"`
+	CountedBy string    `json:"countedBy"` // "legendre" | "bsgs" | "mestre"
+}
+
+func Run(cfg *Config, w io.Writer) error {
+	r, err := Search(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(r)
+	}
+	_, err = fmt.Fprintf(w, "curve       y^2 = x^3 + %s x + %s over F_%s\n"+
+		"#E(F_p)     %s = %s × %s (%s)\n"+
+		"generator   (%s, %s) of order %s\n"+
+		"tries       %d\n",
+		r.A, r.B, r.P, r.N, r.Cofactor, r.R, r.CountedBy, r.G[0], r.G[1], r.R, r.Tries)
+	return err
+}
+
+// Search walks curves per cfg until one has #E = cofactor·r with r prime,
+// and returns it with a point G of order r.
+func Search(cfg *Config) (*Result, error) {
+	p := cfg.P
+	var rnd io.Reader = crand.Reader
+	if cfg.Seed != 0 {
+		rnd = mrand.New(mrand.NewSource(cfg.Seed))
+	}
+	A := ec.Mod(cfg.A, p)
+	B := ec.Mod(cfg.B, p)
+	for try := 1; try <= cfg.MaxTries; try++ {
+		if cfg.Strategy == "random" {
+			var err error
+			if A, err = crand.Int(rnd, p); err != nil {
+				return nil, err
+			}
+			if B, err = crand.Int(rnd, p); err != nil {
+				return nil, err
+			}
+		} else if try > 1 {
+			B = ec.AddM(B, big.NewInt(1), p)
+		}
+		c := ec.Curve{P: p, A: A, B: B}
+		if c.IsSingular() {
+			continue
+		}
+		N, by, err := count.Auto(c, cfg.Count, rnd)
+		if errors.Is(err, count.ErrAmbiguous) {
+			// exponent < 4√p: far from cyclic of (cofactor × large prime) order
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("count A=%s B=%s: %w", A, B, err)
+		}
+		r, rem := new(big.Int).QuoRem(N, cfg.Cofactor, new(big.Int))
+		if rem.Sign() != 0 || !r.ProbablyPrime(32) {
+			continue
+		}
+		if r.Cmp(p) == 0 && !cfg.AllowAnomalous {
+			log.Printf("skipping anomalous curve A=%s B=%s (#E = p)", A, B)
+			continue
+		}
+		G, err := generator(c, cfg.Cofactor, r, rnd)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{
+			P: p.String(), A: A.String(), B: B.String(), N: N.String(),
+			Cofactor: cfg.Cofactor.String(), R: r.String(),
+			G: [2]string{G.X.String(), G.Y.String()}, Tries: try, CountedBy: by,
+		}, nil
+	}
+	return nil, fmt.Errorf("no curve with #E = %s × prime in %d tries", cfg.Cofactor, cfg.MaxTries)
+}
+
+// generator returns h·P ≠ O for a random P, checking r·(h·P) = O.
+func generator(c ec.Curve, h, r *big.Int, rnd io.Reader) (ec.Point, error) {
+	for i := 0; i < 64; i++ {
+		P, err := c.RandomPoint(rnd)
+		if err != nil {
+			return ec.Point{}, err
+		}
+		G, err := c.ScalarMul(h, P)
+		if err != nil {
+			return ec.Point{}, err
+		}
+		if G.Inf {
+			continue
+		}
+		if O, err := c.ScalarMul(r, G); err != nil || !O.Inf {
+			return ec.Point{}, fmt.Errorf("r·G != O for r=%s (count is wrong?)", r)
+		}
+		return G, nil
+	}
+	return ec.Point{}, errors.New("no point of order r found")
+}
+
+// parseInt reads decimal, 0x-hex or an expression like 2^61-1 (ec.ParseBig).
+func parseInt(s, name string) (*big.Int, error) {
+	z, err := ec.ParseBig(s)
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %w", name, err)
+	}
+	return z, nil
+}
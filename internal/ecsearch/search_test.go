@@ -0,0 +1,53 @@
+package ecsearch
+
+import (
+	"math/big"
+	"testing"
+
+	"ectorus/internal/count"
+	"ectorus/internal/ec"
+)
+
+func TestSearchFindsCofactorTimesPrime(t *testing.T) {
+	for _, tc := range []struct {
+		strategy, count string
+		h               int64
+	}{
+		{"sequential", "legendre", 1},
+		{"random", "bsgs", 4},
+		{"random", "auto", 2},
+		{"random", "mestre", 1},
+	} {
+		cfg := &Config{
+			P: big.NewInt(100003), A: big.NewInt(-3), B: big.NewInt(1), Cofactor: big.NewInt(tc.h),
+			Strategy: tc.strategy, Count: tc.count, MaxTries: 10_000, Seed: 7,
+		}
+		r, err := Search(cfg)
+		if err != nil {
+			t.Fatalf("%+v: %v", tc, err)
+		}
+		A, _ := new(big.Int).SetString(r.A, 10)
+		B, _ := new(big.Int).SetString(r.B, 10)
+		c := ec.Curve{P: cfg.P, A: A, B: B}
+		N := count.Legendre(c)
+		if N.String() != r.N {
+			t.Fatalf("%+v: reported #E = %s, Legendre says %s", tc, r.N, N)
+		}
+		ord, _ := new(big.Int).SetString(r.R, 10)
+		if new(big.Int).Mul(ord, cfg.Cofactor).Cmp(N) != 0 || !ord.ProbablyPrime(20) {
+			t.Fatalf("%+v: #E = %s is not %d × prime %s", tc, N, tc.h, ord)
+		}
+		gx, _ := new(big.Int).SetString(r.G[0], 10)
+		gy, _ := new(big.Int).SetString(r.G[1], 10)
+		G := ec.Point{X: gx, Y: gy}
+		if !c.On(G) {
+			t.Fatalf("%+v: generator off the curve", tc)
+		}
+		if O, _ := c.ScalarMul(ord, G); !O.Inf {
+			t.Fatalf("%+v: r·G != O", tc)
+		}
+		if tc.strategy == "sequential" && A.Cmp(ec.Mod(cfg.A, cfg.P)) != 0 {
+			t.Fatalf("sequential search moved A to %s", A)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package ecverify
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"ectorus/internal/ec"
+)
+
+type Config struct {
+	Paths      []string // point dumps, checked as one set ("-" = stdin)
+	P, A, B    *big.Int // --p/--A/--B; nil = take the curve from the headers
+	Ext        int      // --ext; 0 = from the headers or the coordinate count
+	CountLimit uint64   // --count-limit: compare with a Legendre count when p ≤ this
+	MaxReport  int      // --max-report: problem lines listed per kind
+	JSON       bool     // --json
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("ecverify", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ecverify [flags] FILE...")
+		fs.PrintDefaults()
+	}
+
+	var (
+		pStr       = fs.String("p", "", "prime modulus p (decimal, 0x-hex or e.g. 2^61-1; default: from the dump's --header)")
+		AStr       = fs.String("A", "", "curve parameter A (default: from the header)")
+		BStr       = fs.String("B", "", "curve parameter B (default: from the header)")
+		ext        = fs.Int("ext", 0, "coordinate field degree 1|2 (default: from the header or the coordinate count)")
+		countLimit = fs.Uint64("count-limit", 100_000_000, "compare the point count with a Legendre scan only when p ≤ this")
+		maxReport  = fs.Int("max-report", 10, "list at most this many offending lines per kind of problem")
+		jsonOut    = fs.Bool("json", false, "emit JSON instead of text")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() == 0 {
+		return nil, errors.New("no point files given")
+	}
+	if *ext < 0 || *ext > 2 {
+		return nil, fmt.Errorf("bad --ext %d (want 1 or 2)", *ext)
+	}
+	cfg := &Config{Paths: fs.Args(), Ext: *ext, CountLimit: *countLimit, MaxReport: *maxReport, JSON: *jsonOut}
+	var err error
+	for _, f := range []struct {
+		s    string
+		name string
+		dst  **big.Int
+	}{{*pStr, "p", &cfg.P}, {*AStr, "A", &cfg.A}, {*BStr, "B", &cfg.B}} {
+		if strings.TrimSpace(f.s) == "" {
+			continue
+		}
+		if *f.dst, err = parseInt(f.s, f.name); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// parseInt reads decimal, 0x-hex or an expression like 2^61-1 (ec.ParseBig).
+func parseInt(s, name string) (*big.Int, error) {
+	z, err := ec.ParseBig(s)
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %w", name, err)
+	}
+	return z, nil
+}
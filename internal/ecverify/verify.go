@@ -0,0 +1,407 @@
+// Package ecverify checks point dumps behind cmd/ecverify: every point on
+// the stated curve, no duplicates, one point-at-infinity record per file
+// in last place, and (for small p) the right number of points — the checks
+// that matter once a dump has been stitched together from shards or from a
+// resumed run.
+package ecverify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"ectorus/internal/count"
+	"ectorus/internal/ec"
+	"ectorus/internal/ptfile"
+)
+
+type Report struct {
+	P   string `json:"p"`
+	A   string `json:"A"`
+	B   string `json:"B"`
+	Ext int    `json:"ext"`
+
+	Files   []FileReport `json:"files"`
+	Records uint64       `json:"records"`
+	Points  uint64       `json:"affinePoints"` // distinct, in range and on the curve
+
+	OffCurve   uint64 `json:"offCurve"`
+	OutOfRange uint64 `json:"outOfRange"` // a coordinate outside [0, p)
+	Malformed  uint64 `json:"malformed"`  // wrong number of coordinates for ext
+	Duplicates uint64 `json:"duplicates"`
+
+	N         string `json:"pointCount"` // affine points + O
+	Expected  string `json:"expectedCount,omitempty"`
+	CountedBy string `json:"countedBy,omitempty"` // "legendre"
+	CountOK   *bool  `json:"countOK,omitempty"`
+
+	Problems []string `json:"problems,omitempty"` // first --max-report of each kind
+	OK       bool     `json:"ok"`
+}
+
+type FileReport struct {
+	Path     string `json:"path"`
+	Format   string `json:"format"`
+	Header   bool   `json:"header"`
+	Records  uint64 `json:"records"`
+	Infinity string `json:"infinity"` // "ok", "missing", "not last" or "repeated"
+	// Footer is "ok", "missing" (a versioned header without a footer:
+	// truncated) or "mismatch"; empty for dumps written without one.
+	Footer string `json:"footer,omitempty"`
+}
+
+func Run(cfg *Config, w io.Writer) error {
+	r, err := Verify(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(r)
+	} else {
+		err = r.Print(w)
+	}
+	if err != nil {
+		return err
+	}
+	if !r.OK {
+		return errors.New("verification failed")
+	}
+	return nil
+}
+
+// checker holds the running state across all files of one Verify.
+type checker struct {
+	cfg     *Config
+	r       *Report
+	p, A, B *big.Int
+	f2      *fp2 // nil over F_p
+	seen    map[[16]byte]struct{}
+	listed  map[string]int
+}
+
+// Verify reads every file in cfg.Paths as one set of points.
+func Verify(cfg *Config) (*Report, error) {
+	c := &checker{cfg: cfg, r: &Report{}, seen: map[[16]byte]struct{}{}, listed: map[string]int{}}
+	for _, path := range cfg.Paths {
+		if err := c.file(path); err != nil {
+			return nil, err
+		}
+	}
+	if c.p == nil {
+		return nil, errors.New("no points and no curve: pass --p/--A/--B")
+	}
+	r := c.r
+	if r.Ext == 0 {
+		r.Ext = 1
+	}
+	N := new(big.Int).SetUint64(r.Points)
+	N.Add(N, big.NewInt(1))
+	r.N = N.String()
+	if c.p.IsUint64() && c.p.Uint64() <= cfg.CountLimit {
+		want := expectedCount(c.p, c.A, c.B, r.Ext)
+		ok := want.Cmp(N) == 0
+		r.Expected, r.CountedBy, r.CountOK = want.String(), "legendre", &ok
+		if !ok {
+			r.Problems = append(r.Problems, fmt.Sprintf("count: the files hold N = %s points, the Legendre scan gives %s", N, want))
+		}
+	}
+	r.OK = r.OffCurve == 0 && r.OutOfRange == 0 && r.Malformed == 0 && r.Duplicates == 0 &&
+		(r.CountOK == nil || *r.CountOK)
+	for _, f := range r.Files {
+		r.OK = r.OK && f.Infinity == "ok" && (f.Footer == "" || f.Footer == "ok")
+	}
+	return r, nil
+}
+
+func (c *checker) file(path string) (err error) {
+	rd, err := ptfile.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := rd.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	fr := FileReport{Path: path, Format: string(rd.Format()), Header: rd.Meta() != nil, Infinity: "missing"}
+	if err := c.curve(path, rd.Meta()); err != nil {
+		return err
+	}
+	if c.p != nil {
+		rd.SetCurve(c.p, c.A, c.B) // the flags win for compressed points too
+	}
+	infAt := 0
+	var affine uint64
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fr.Records++
+		c.r.Records++
+		if infAt > 0 && fr.Infinity == "ok" {
+			fr.Infinity = "not last"
+			c.problem("infinity", "%s:%d: point at infinity is not the last record", path, infAt)
+		}
+		if rec.Inf {
+			if infAt > 0 {
+				fr.Infinity = "repeated"
+				c.problem("infinity", "%s:%d: second point at infinity", path, rec.Line)
+			} else {
+				fr.Infinity = "ok"
+			}
+			infAt = rec.Line
+			continue
+		}
+		if c.p == nil {
+			return fmt.Errorf("%s: no curve: pass --p/--A/--B or write the dump with --header", path)
+		}
+		affine++
+		c.point(path, rec)
+	}
+	if fr.Infinity == "missing" {
+		c.problem("infinity", "%s: no point at infinity record (truncated file?)", path)
+	}
+	fr.Footer = c.footer(path, rd, affine)
+	c.r.Files = append(c.r.Files, fr)
+	return nil
+}
+
+// footer checks a file's footer against what was read: the point count,
+// the SHA-256 of the lines before it, and that nothing follows it.
+func (c *checker) footer(path string, rd *ptfile.Reader, affine uint64) string {
+	ft := rd.Footer()
+	if ft == nil {
+		if m := rd.Meta(); m != nil && m.Version >= 1 {
+			c.problem("footer", "%s: header has v=%d but there is no footer (truncated file?)", path, m.Version)
+			return "missing"
+		}
+		return ""
+	}
+	status := "ok"
+	if ft.Points != affine {
+		c.problem("footer", "%s: footer says %d points, the file holds %d", path, ft.Points, affine)
+		status = "mismatch"
+	}
+	if sum := rd.Sum(); sum != ft.SHA256 {
+		c.problem("footer", "%s: SHA-256 %s does not match the footer's %s", path, sum, ft.SHA256)
+		status = "mismatch"
+	}
+	if n := rd.DataAfterFooter(); n > 0 {
+		c.problem("footer", "%s: %d records after the footer", path, n)
+		status = "mismatch"
+	}
+	return status
+}
+
+// curve settles the curve from the flags or the first header, and checks
+// later headers against it.
+func (c *checker) curve(path string, m *ptfile.Meta) error {
+	cfg := c.cfg
+	if c.p != nil {
+		if m != nil && m.P != nil && (m.P.Cmp(c.p) != 0 ||
+			m.A != nil && ec.Mod(m.A, c.p).Cmp(c.A) != 0 || m.B != nil && ec.Mod(m.B, c.p).Cmp(c.B) != 0) {
+			return fmt.Errorf("%s: header curve (p=%s A=%s B=%s) differs from %s", path, m.P, m.A, m.B, c.describe())
+		}
+		return nil
+	}
+	p, A, B, ext := cfg.P, cfg.A, cfg.B, cfg.Ext
+	if m != nil {
+		p, A, B = pick(p, m.P), pick(A, m.A), pick(B, m.B)
+		if ext == 0 {
+			ext = m.Ext
+		}
+	}
+	if p == nil || A == nil || B == nil {
+		if m == nil {
+			return nil // a later file's header may name it
+		}
+		return fmt.Errorf("%s: header lacks p, A or B; pass --p/--A/--B", path)
+	}
+	if p.Cmp(big.NewInt(3)) <= 0 || !p.ProbablyPrime(32) {
+		return fmt.Errorf("p = %s is not a prime > 3", p)
+	}
+	c.p, c.A, c.B = p, ec.Mod(A, p), ec.Mod(B, p)
+	c.r.P, c.r.A, c.r.B = c.p.String(), c.A.String(), c.B.String()
+	c.setExt(ext)
+	return nil
+}
+
+// setExt fixes the coordinate field; 0 leaves it to the first point.
+func (c *checker) setExt(ext int) {
+	c.r.Ext = ext
+	if ext == 2 {
+		c.f2 = newFp2(c.p)
+	}
+}
+
+func pick(flag, header *big.Int) *big.Int {
+	if flag != nil {
+		return flag
+	}
+	return header
+}
+
+func (c *checker) describe() string {
+	return fmt.Sprintf("y^2 = x^3 + %sx + %s over F_%s", c.A, c.B, c.p)
+}
+
+func (c *checker) point(path string, rec ptfile.Record) {
+	r := c.r
+	if r.Ext == 0 {
+		c.setExt(len(rec.Coords) / 2)
+	}
+	want := 2 * r.Ext
+	if len(rec.Coords) != want {
+		r.Malformed++
+		c.problem("malformed", "%s:%d: %d coordinates, want %d for ext=%d", path, rec.Line, len(rec.Coords), want, r.Ext)
+		return
+	}
+	for _, z := range rec.Coords {
+		if z.Sign() < 0 || z.Cmp(c.p) >= 0 {
+			r.OutOfRange++
+			c.problem("range", "%s:%d: coordinate %s outside [0, p)", path, rec.Line, z)
+			return
+		}
+	}
+	if !c.onCurve(rec.Coords) {
+		r.OffCurve++
+		c.problem("curve", "%s:%d: %v is not on %s", path, rec.Line, rec.Coords, c.describe())
+		return
+	}
+	key := rec.Key()
+	if _, dup := c.seen[key]; dup {
+		r.Duplicates++
+		c.problem("duplicate", "%s:%d: duplicate point %v", path, rec.Line, rec.Coords)
+		return
+	}
+	c.seen[key] = struct{}{}
+	r.Points++
+}
+
+func (c *checker) onCurve(cs []*big.Int) bool {
+	p := c.p
+	if c.f2 == nil {
+		x, y := cs[0], cs[1]
+		rhs := ec.AddM(ec.AddM(ec.MulM(x, ec.MulM(x, x, p), p), ec.MulM(c.A, x, p), p), c.B, p)
+		return ec.MulM(y, y, p).Cmp(rhs) == 0
+	}
+	f := c.f2
+	x, y := elt2{cs[0], cs[1]}, elt2{cs[2], cs[3]}
+	rhs := f.add(f.add(f.mul(f.mul(x, x), x), f.mul(elt2{c.A, new(big.Int)}, x)), elt2{c.B, new(big.Int)})
+	return f.mul(y, y).eq(rhs)
+}
+
+// problem records a finding, listing at most cfg.MaxReport of each kind.
+func (c *checker) problem(kind, format string, args ...any) {
+	if c.listed[kind] >= c.cfg.MaxReport {
+		return
+	}
+	c.listed[kind]++
+	c.r.Problems = append(c.r.Problems, fmt.Sprintf(format, args...))
+}
+
+// expectedCount is #E(F_{p^ext}) from the Legendre scan; over F_{p^2} it
+// is p^2 + 1 - (a_p^2 - 2p).
+func expectedCount(p, A, B *big.Int, ext int) *big.Int {
+	N := count.Legendre(ec.Curve{P: p, A: A, B: B})
+	if ext != 2 {
+		return N
+	}
+	ap := count.Trace(p, N)
+	q := new(big.Int).Mul(p, p)
+	q.Add(q, big.NewInt(1))
+	q.Sub(q, new(big.Int).Mul(ap, ap))
+	return q.Add(q, new(big.Int).Lsh(p, 1))
+}
+
+// ------------------- F_{p^2} -------------------
+
+// fp2 is F_p[i]/(i^2 - n) for the least non-residue n, the same
+// presentation ecscan --ext 2 writes its coordinates in.
+type fp2 struct{ p, n *big.Int }
+
+type elt2 struct{ a, b *big.Int }
+
+func newFp2(p *big.Int) *fp2 {
+	n := big.NewInt(2)
+	for ec.Legendre(n, p) != -1 {
+		n.Add(n, big.NewInt(1))
+	}
+	return &fp2{p: p, n: n}
+}
+
+func (f *fp2) add(x, y elt2) elt2 { return elt2{ec.AddM(x.a, y.a, f.p), ec.AddM(x.b, y.b, f.p)} }
+
+// mul is (a+bi)(c+di) = (ac + n·bd) + (ad + bc)·i.
+func (f *fp2) mul(x, y elt2) elt2 {
+	p := f.p
+	re := ec.AddM(ec.MulM(x.a, y.a, p), ec.MulM(f.n, ec.MulM(x.b, y.b, p), p), p)
+	im := ec.AddM(ec.MulM(x.a, y.b, p), ec.MulM(x.b, y.a, p), p)
+	return elt2{re, im}
+}
+
+func (x elt2) eq(y elt2) bool { return x.a.Cmp(y.a) == 0 && x.b.Cmp(y.b) == 0 }
+
+// Print writes r as a human-readable summary.
+func (r *Report) Print(w io.Writer) error {
+	ew := &errWriter{w: w}
+	field := "F_" + r.P
+	if r.Ext == 2 {
+		field += "^2"
+	}
+	ew.printf("Curve: y^2 = x^3 + %s x + %s over %s\n", r.A, r.B, field)
+	for _, f := range r.Files {
+		hdr := "no header"
+		if f.Header {
+			hdr = "header"
+		}
+		ew.printf("  %s: %s, %s, %d records, infinity %s", f.Path, f.Format, hdr, f.Records, f.Infinity)
+		if f.Footer != "" {
+			ew.printf(", footer %s", f.Footer)
+		}
+		ew.printf("\n")
+	}
+	ew.printf("\nAffine points: %d (N = %s)\n", r.Points, r.N)
+	if r.CountOK != nil {
+		ew.printf("Expected N (%s): %s — %s\n", r.CountedBy, r.Expected, okWord(*r.CountOK))
+	}
+	ew.printf("Off curve: %d\nOut of range: %d\nMalformed: %d\nDuplicates: %d\n",
+		r.OffCurve, r.OutOfRange, r.Malformed, r.Duplicates)
+	if len(r.Problems) > 0 {
+		ew.printf("\nProblems:\n")
+		for _, p := range r.Problems {
+			ew.printf("  - %s\n", p)
+		}
+	}
+	ew.printf("\nResult: %s\n", okWord(r.OK))
+	return ew.err
+}
+
+func okWord(ok bool) string {
+	if ok {
+		return "OK"
+	}
+	return "FAIL"
+}
+
+// errWriter keeps the first write error so Print can stay linear.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = fmt.Fprintf(e.w, format, args...)
+}
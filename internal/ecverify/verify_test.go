@@ -0,0 +1,103 @@
+package ecverify
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"ectorus/internal/ecscan"
+)
+
+// dump writes the points of y^2 = x^3 + 2x + 3 over F_1009 as ecscan text
+// shards, the sentinel closing each one, and returns their paths.
+func dump(t *testing.T, shards int, edit func(lines []string) []string) []string {
+	t.Helper()
+	var lines []string
+	err := ecscan.Scan(context.Background(), ecscan.Params{P: 1009, A: 2, B: 3}, func(x, y uint64) error {
+		lines = append(lines, fmt.Sprintf("%d %d", x, y))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edit != nil {
+		lines = edit(lines)
+	}
+	dir := t.TempDir()
+	var paths []string
+	per := (len(lines) + shards - 1) / shards
+	for i := 0; i < shards; i++ {
+		part := slices.Clone(lines[min(i*per, len(lines)):min((i+1)*per, len(lines))])
+		body := strings.Join(append(part, "18446744073709551615 18446744073709551615"), "\n") + "\n"
+		path := filepath.Join(dir, fmt.Sprintf("shard%d.txt", i))
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func check(t *testing.T, paths []string) *Report {
+	t.Helper()
+	r, err := Verify(&Config{Paths: paths, P: big.NewInt(1009), A: big.NewInt(2), B: big.NewInt(3), CountLimit: 1 << 20, MaxReport: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestVerifyShards(t *testing.T) {
+	r := check(t, dump(t, 3, nil))
+	if !r.OK || r.Expected != r.N || len(r.Files) != 3 {
+		t.Fatalf("clean shards rejected: %+v", r)
+	}
+}
+
+func TestVerifyFindsProblems(t *testing.T) {
+	r := check(t, dump(t, 2, func(lines []string) []string {
+		lines[5] = lines[4] // one point lost, one duplicated
+		return append(lines, "1 1")
+	}))
+	if r.OK || r.Duplicates != 1 || r.OffCurve != 1 || *r.CountOK {
+		t.Fatalf("problems not found: %+v", r)
+	}
+}
+
+func TestVerifySentinel(t *testing.T) {
+	paths := dump(t, 1, nil)
+	body, _ := os.ReadFile(paths[0])
+	os.WriteFile(paths[0], append(body, "0 1009\n"...), 0o644) // a record after O
+	r := check(t, paths)
+	if r.OK || r.Files[0].Infinity != "not last" {
+		t.Fatalf("late sentinel not caught: %+v", r.Files)
+	}
+}
+
+func TestVerifyFooter(t *testing.T) {
+	paths := dump(t, 1, nil)
+	body, _ := os.ReadFile(paths[0])
+	body = append([]byte("# ecscan v=1 p=1009 A=2 B=3 mode=table timestamp=x\n"), body...)
+	sum := sha256.Sum256(body)
+	good := fmt.Sprintf("%s# end points=%d sha256=%x\n", body, len(strings.Split(string(body), "\n"))-3, sum)
+	os.WriteFile(paths[0], []byte(good), 0o644)
+	if r := check(t, paths); !r.OK || r.Files[0].Footer != "ok" {
+		t.Fatalf("good footer rejected: %+v", r)
+	}
+
+	os.WriteFile(paths[0], []byte(strings.Replace(good, "\n", "\n\n", 1)), 0o644) // altered, same points
+	if r := check(t, paths); r.OK || r.Files[0].Footer != "mismatch" {
+		t.Fatalf("altered file passed: %+v", r.Files)
+	}
+	cut := strings.LastIndexByte(string(body[:len(body)/2]), '\n') + 1
+	os.WriteFile(paths[0], body[:cut], 0o644) // truncated on a line boundary
+	if r := check(t, paths); r.OK || r.Files[0].Footer != "missing" {
+		t.Fatalf("truncated file passed: %+v", r.Files)
+	}
+}
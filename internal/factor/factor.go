@@ -0,0 +1,83 @@
+// Package factor factors group orders N = #E(F_p) into prime powers and
+// picks out what curve work needs from them: the largest prime r, the
+// order of the biggest prime subgroup, and its cofactor h = N / r. The
+// splitting is prime.Factor's trial division and Pollard–Brent rho.
+package factor
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"ectorus/internal/prime"
+)
+
+// PrimePower is one factor Q^E of N.
+type PrimePower struct {
+	Q *big.Int
+	E int
+}
+
+// Factorization is N as prime powers, ascending, times Rest: the composite
+// part rho could not split, 1 when N is fully factored.
+type Factorization struct {
+	N      *big.Int
+	Powers []PrimePower
+	Rest   *big.Int
+}
+
+// Of factors n ≥ 1.
+func Of(n *big.Int) Factorization {
+	fs, rest := prime.Factor(n)
+	f := Factorization{N: new(big.Int).Set(n), Rest: rest}
+	for _, q := range fs {
+		if k := len(f.Powers); k > 0 && f.Powers[k-1].Q.Cmp(q) == 0 {
+			f.Powers[k-1].E++
+			continue
+		}
+		f.Powers = append(f.Powers, PrimePower{Q: q, E: 1})
+	}
+	return f
+}
+
+// Complete reports whether every prime factor was found.
+func (f Factorization) Complete() bool { return f.Rest.Cmp(big.NewInt(1)) == 0 }
+
+// Largest returns the largest prime factor r of N, or nil when N = 1 or
+// the factorization is incomplete (the unsplit part hides larger primes).
+func (f Factorization) Largest() *big.Int {
+	if !f.Complete() || len(f.Powers) == 0 {
+		return nil
+	}
+	return f.Powers[len(f.Powers)-1].Q
+}
+
+// Cofactor returns h = N / r for the largest prime r, or nil with Largest.
+// The points of order r are then h·P for the P with h·P ≠ O.
+func (f Factorization) Cofactor() *big.Int {
+	r := f.Largest()
+	if r == nil {
+		return nil
+	}
+	return new(big.Int).Quo(f.N, r)
+}
+
+// String writes N as "2^2 · 3 · 89", with an unsplit part last in
+// brackets.
+func (f Factorization) String() string {
+	var parts []string
+	for _, pp := range f.Powers {
+		if pp.E > 1 {
+			parts = append(parts, fmt.Sprintf("%s^%d", pp.Q, pp.E))
+		} else {
+			parts = append(parts, pp.Q.String())
+		}
+	}
+	if !f.Complete() {
+		parts = append(parts, "["+f.Rest.String()+"]")
+	}
+	if len(parts) == 0 {
+		return "1"
+	}
+	return strings.Join(parts, " · ")
+}
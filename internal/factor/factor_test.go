@@ -0,0 +1,35 @@
+package factor
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	for _, tc := range []struct {
+		n, str, r, h string
+	}{
+		{"96", "2^5 · 3", "3", "32"},
+		{"1", "1", "", ""},
+		{"1009", "1009", "1009", "1"},
+		// two 33-bit primes: beyond the trial division, split by rho
+		{"73786976741514805843", "8589934609 · 8589934627", "8589934627", "8589934609"},
+		{"115792089237316195423570985008687907852837564279074904382605163141518161494337", "115792089237316195423570985008687907852837564279074904382605163141518161494337", "115792089237316195423570985008687907852837564279074904382605163141518161494337", "1"},
+	} {
+		n, _ := new(big.Int).SetString(tc.n, 10)
+		f := Of(n)
+		if f.String() != tc.str {
+			t.Fatalf("Of(%s) = %s, want %s", tc.n, f, tc.str)
+		}
+		r, h := f.Largest(), f.Cofactor()
+		if tc.r == "" {
+			if r != nil || h != nil {
+				t.Fatalf("Of(%s): largest %v, cofactor %v, want none", tc.n, r, h)
+			}
+			continue
+		}
+		if r == nil || r.String() != tc.r || h.String() != tc.h {
+			t.Fatalf("Of(%s): largest %v, cofactor %v, want %s, %s", tc.n, r, h, tc.r, tc.h)
+		}
+	}
+}
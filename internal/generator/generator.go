@@ -0,0 +1,333 @@
+// Package generator backs `asteroids gen`: find a base point on a curve
+// from its point count. N is factored, and cofactor multiplication of
+// random points gives either a point of maximal order (the group exponent,
+// N itself when E(F_p) is cyclic) or one of a requested prime order r | N.
+package generator
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	mrand "math/rand"
+	"os"
+	"strings"
+
+	"ectorus/internal/count"
+	"ectorus/internal/ec"
+	"ectorus/internal/factor"
+	"ectorus/internal/prime"
+	"ectorus/pkg/encoding"
+)
+
+// stallSamples is how many random points in a row must fail to raise the
+// order of a q-part before it is taken as the q-part of the exponent: a
+// random point of Z/q^a × Z/q^b has order below q^a with probability 1/q,
+// so a non-cyclic q-part is misjudged with probability at most 2^-20.
+const stallSamples = 20
+
+type Config struct {
+	P, A, B  *big.Int
+	N        *big.Int // --N: known #E(F_p), skips counting
+	Order    *big.Int // --order: a prime r | N; nil = maximal order
+	Count    string   // --count: auto|legendre|bsgs|mestre
+	MaxTries int      // --max-tries: random points to draw at most
+	Seed     int64    // --seed: 0 => crypto/rand
+	JSON     bool     // --json
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("asteroids gen", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: asteroids gen -p P -A A -B B [flags]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		pStr      = fs.String("p", "", "prime modulus p > 3 (decimal, 0x-hex or e.g. 2^61-1; required)")
+		AStr      = fs.String("A", "0", "curve parameter A")
+		BStr      = fs.String("B", "0", "curve parameter B")
+		NStr      = fs.String("N", "", "known point count #E(F_p) (skips counting)")
+		orderStr  = fs.String("order", "", "find a point of this prime order r | N instead of one of maximal order")
+		countWith = fs.String("count", "auto", "point counting: auto|legendre|bsgs|mestre")
+		maxTries  = fs.Int("max-tries", 1000, "give up after drawing this many random points")
+		seed      = fs.Int64("seed", 0, "seed for the counting and the random points (0 = crypto/rand)")
+		jsonOut   = fs.Bool("json", false, "emit JSON instead of text")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 0 {
+		return nil, fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	if strings.TrimSpace(*pStr) == "" {
+		return nil, errors.New("missing required --p")
+	}
+	cfg := &Config{
+		Count: strings.ToLower(strings.TrimSpace(*countWith)), MaxTries: *maxTries, Seed: *seed, JSON: *jsonOut,
+	}
+	var err error
+	if cfg.P, err = parseInt(*pStr, "p"); err != nil {
+		return nil, err
+	}
+	if cfg.P.Cmp(big.NewInt(3)) <= 0 || !cfg.P.ProbablyPrime(32) {
+		return nil, errors.New("--p must be a prime > 3")
+	}
+	if cfg.A, err = parseInt(*AStr, "A"); err != nil {
+		return nil, err
+	}
+	if cfg.B, err = parseInt(*BStr, "B"); err != nil {
+		return nil, err
+	}
+	if *NStr != "" {
+		if cfg.N, err = parseInt(*NStr, "N"); err != nil {
+			return nil, err
+		}
+	}
+	if *orderStr != "" {
+		if cfg.Order, err = parseInt(*orderStr, "order"); err != nil {
+			return nil, err
+		}
+		if cfg.Order.Sign() <= 0 || !prime.BPSW(cfg.Order) {
+			return nil, fmt.Errorf("--order %s is not a prime", cfg.Order)
+		}
+	}
+	switch cfg.Count {
+	case "auto", "legendre", "bsgs", "mestre":
+	default:
+		return nil, fmt.Errorf("bad --count %q (want auto|legendre|bsgs|mestre)", *countWith)
+	}
+	if cfg.MaxTries <= 0 {
+		return nil, errors.New("--max-tries must be positive")
+	}
+	return cfg, nil
+}
+
+// Result is the point found. Order is its order and Cofactor is N / Order;
+// Cyclic is set when the point has order N and so generates E(F_p).
+type Result struct {
+	P              string `json:"p"`
+	A              string `json:"A"`
+	B              string `json:"B"`
+	N              string `json:"pointCount"`
+	Factorization  string `json:"factorization"`
+	Mode           string `json:"mode"` // "maximal" or "prime"
+	X              string `json:"x"`
+	Y              string `json:"y"`
+	Order          string `json:"order"`
+	Cofactor       string `json:"cofactor"`
+	Cyclic         bool   `json:"cyclic"`
+	SEC1           string `json:"sec1"`           // 04‖X‖Y in hex
+	SEC1Compressed string `json:"sec1Compressed"` // 02/03‖X in hex
+	Samples        int    `json:"samples"`        // random points drawn
+}
+
+func Run(cfg *Config, w io.Writer) error {
+	r, err := Find(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(r)
+	}
+	ew := &errWriter{w: w}
+	ew.printf("curve       y^2 = x^3 + %s x + %s over F_%s\n", r.A, r.B, r.P)
+	ew.printf("#E          %s = %s\n", r.N, r.Factorization)
+	ew.printf("G           (%s, %s)\n", r.X, r.Y)
+	ew.printf("order       %s, cofactor %s", r.Order, r.Cofactor)
+	switch {
+	case r.Cyclic:
+		ew.printf(" (G generates E(F_p))")
+	case r.Mode == "maximal":
+		ew.printf(" (E(F_p) is not cyclic; this is its exponent)")
+	}
+	ew.printf("\n")
+	ew.printf("SEC1        %s\n", r.SEC1)
+	ew.printf("compressed  %s\n", r.SEC1Compressed)
+	ew.printf("samples     %d\n", r.Samples)
+	return ew.err
+}
+
+// Find counts and factors the curve (unless --N is given) and draws
+// random points until one has the wanted order.
+func Find(cfg *Config) (*Result, error) {
+	p := cfg.P
+	c := ec.Curve{P: p, A: ec.Mod(cfg.A, p), B: ec.Mod(cfg.B, p)}
+	if c.IsSingular() {
+		return nil, errors.New("curve is singular (4A^3 + 27B^2 ≡ 0 mod p)")
+	}
+	var rnd io.Reader = crand.Reader
+	if cfg.Seed != 0 {
+		rnd = mrand.New(mrand.NewSource(cfg.Seed))
+	}
+	N := cfg.N
+	if N == nil {
+		var err error
+		if N, _, err = count.Auto(c, cfg.Count, rnd); err != nil {
+			return nil, fmt.Errorf("count: %w", err)
+		}
+	}
+	fN := factor.Of(N)
+	res := &Result{P: p.String(), A: c.A.String(), B: c.B.String(), N: N.String(), Factorization: fN.String()}
+	var (
+		G       ec.Point
+		order   *big.Int
+		samples int
+		err     error
+	)
+	if cfg.Order != nil {
+		res.Mode = "prime"
+		if new(big.Int).Mod(N, cfg.Order).Sign() != 0 {
+			return nil, fmt.Errorf("--order %s does not divide #E = %s", cfg.Order, N)
+		}
+		order = cfg.Order
+		G, samples, err = primeOrderPoint(c, N, cfg.Order, cfg.MaxTries, rnd)
+	} else {
+		res.Mode = "maximal"
+		if !fN.Complete() {
+			return nil, fmt.Errorf("cannot factor #E = %s (left with %s); pass --order", N, fN.Rest)
+		}
+		G, order, samples, err = maximalOrderPoint(c, fN, cfg.MaxTries, rnd)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if R, err := c.ScalarMul(order, G); err != nil || !R.Inf {
+		return nil, fmt.Errorf("%s·G ≠ O: %s is not the order of the group", order, N)
+	}
+	res.X, res.Y = G.X.String(), G.Y.String()
+	res.Order = order.String()
+	res.Cofactor = new(big.Int).Quo(N, order).String()
+	res.Cyclic = order.Cmp(N) == 0
+	res.SEC1 = hex.EncodeToString(encoding.Marshal(p, G.X, G.Y))
+	res.SEC1Compressed = hex.EncodeToString(encoding.MarshalCompressed(p, G.X, G.Y))
+	res.Samples = samples
+	return res, nil
+}
+
+// primeOrderPoint returns (N/r)·R for the first random R where that is not
+// O. r·(N/r)·R = N·R = O, so it has order r.
+func primeOrderPoint(c ec.Curve, N, r *big.Int, tries int, rnd io.Reader) (ec.Point, int, error) {
+	h := new(big.Int).Quo(N, r)
+	for i := 1; i <= tries; i++ {
+		R, err := c.RandomPoint(rnd)
+		if err != nil {
+			return ec.Point{}, i, err
+		}
+		G, err := c.ScalarMul(h, R)
+		if err != nil {
+			return ec.Point{}, i, err
+		}
+		if !G.Inf {
+			return G, i, nil
+		}
+	}
+	return ec.Point{}, tries, fmt.Errorf("no point of order %s in %d random points", r, tries)
+}
+
+// qPart is the best q-primary component of the random points so far: C of
+// order q^f, in the q-part Z/q^a × Z/q^b (b ≤ a) of E(F_p), with a + b = e.
+type qPart struct {
+	q, cof *big.Int // cof = N / q^e
+	e, min int      // a ≥ min, as b ≤ v_q(p-1) by the Weil pairing
+	C      ec.Point
+	f      int
+	stall  int
+}
+
+func (qp *qPart) done() bool {
+	return qp.f == qp.e || (qp.f >= qp.min && qp.stall >= stallSamples)
+}
+
+// maximalOrderPoint builds a point of order the group exponent one prime at
+// a time: (N/q^e)·R is the q-component of R, and the sum over q of the
+// components of greatest order has the product of those orders. A q with
+// q ∤ p-1 has a cyclic q-part, so it waits for f = e; otherwise a q-part
+// is settled once stallSamples points in a row have not raised f.
+func maximalOrderPoint(c ec.Curve, fN factor.Factorization, tries int, rnd io.Reader) (ec.Point, *big.Int, int, error) {
+	pm1 := new(big.Int).Sub(c.P, big.NewInt(1))
+	parts := make([]*qPart, len(fN.Powers))
+	for i, pp := range fN.Powers {
+		qe := new(big.Int).Exp(pp.Q, big.NewInt(int64(pp.E)), nil)
+		v := 0
+		for m := new(big.Int).Set(pm1); v < pp.E && new(big.Int).Mod(m, pp.Q).Sign() == 0; v++ {
+			m.Quo(m, pp.Q)
+		}
+		parts[i] = &qPart{q: pp.Q, cof: new(big.Int).Quo(fN.N, qe), e: pp.E, min: pp.E - min(v, pp.E/2), C: ec.Point{Inf: true}}
+	}
+	samples := 0
+	for {
+		pending := false
+		for _, qp := range parts {
+			pending = pending || !qp.done()
+		}
+		if !pending {
+			break
+		}
+		if samples == tries {
+			return ec.Point{}, nil, samples, fmt.Errorf("no point of maximal order in %d random points", tries)
+		}
+		samples++
+		R, err := c.RandomPoint(rnd)
+		if err != nil {
+			return ec.Point{}, nil, samples, err
+		}
+		for _, qp := range parts {
+			if qp.done() {
+				continue
+			}
+			C, err := c.ScalarMul(qp.cof, R)
+			if err != nil {
+				return ec.Point{}, nil, samples, err
+			}
+			f := 0
+			for T := C; !T.Inf; f++ {
+				if T, err = c.ScalarMul(qp.q, T); err != nil {
+					return ec.Point{}, nil, samples, err
+				}
+			}
+			if f > qp.f {
+				qp.C, qp.f, qp.stall = C, f, 0
+			} else {
+				qp.stall++
+			}
+		}
+	}
+	G, order := ec.Point{Inf: true}, big.NewInt(1)
+	for _, qp := range parts {
+		var err error
+		if G, err = c.Add(G, qp.C); err != nil {
+			return ec.Point{}, nil, samples, err
+		}
+		order.Mul(order, new(big.Int).Exp(qp.q, big.NewInt(int64(qp.f)), nil))
+	}
+	return G, order, samples, nil
+}
+
+// parseInt reads decimal, 0x-hex or an expression like 2^61-1 (ec.ParseBig).
+func parseInt(s, name string) (*big.Int, error) {
+	z, err := ec.ParseBig(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %w", name, err)
+	}
+	return z, nil
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
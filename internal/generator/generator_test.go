@@ -0,0 +1,98 @@
+package generator
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"ectorus/internal/ec"
+	"ectorus/pkg/encoding"
+)
+
+// bruteOrder is the least k > 0 with kP = O, by repeated addition.
+func bruteOrder(t *testing.T, c ec.Curve, P ec.Point) int64 {
+	k, R := int64(1), P
+	for !R.Inf {
+		var err error
+		if R, err = c.Add(R, P); err != nil {
+			t.Fatal(err)
+		}
+		k++
+	}
+	return k
+}
+
+func TestMaximalOrderIsExponent(t *testing.T) {
+	p := big.NewInt(211)
+	for _, tc := range []struct{ A, B int64 }{
+		{-1, 0}, // supersingular, Z/2 × Z/106
+		{2, 3},
+		{5, 0},
+		{0, 7},
+		{17, 42},
+	} {
+		c := ec.Curve{P: p, A: ec.Mod(big.NewInt(tc.A), p), B: ec.Mod(big.NewInt(tc.B), p)}
+		// the exponent is the largest point order
+		exp, n := int64(1), int64(1)
+		for x := range p.Int64() {
+			for y := range p.Int64() {
+				P := ec.Point{X: big.NewInt(x), Y: big.NewInt(y)}
+				if c.On(P) {
+					n++
+					exp = max(exp, bruteOrder(t, c, P))
+				}
+			}
+		}
+		for seed := int64(1); seed <= 3; seed++ {
+			r, err := Find(&Config{P: p, A: c.A, B: c.B, Count: "legendre", MaxTries: 1000, Seed: seed})
+			if err != nil {
+				t.Fatalf("A=%d B=%d: %v", tc.A, tc.B, err)
+			}
+			G := ec.Point{X: parse(t, r.X), Y: parse(t, r.Y)}
+			if got := bruteOrder(t, c, G); got != exp || r.Order != big.NewInt(exp).String() {
+				t.Fatalf("A=%d B=%d seed %d: G of order %d (reported %s), exponent %d", tc.A, tc.B, seed, got, r.Order, exp)
+			}
+			if r.Cyclic != (exp == n) {
+				t.Fatalf("A=%d B=%d: cyclic %v with exponent %d of %d", tc.A, tc.B, r.Cyclic, exp, n)
+			}
+		}
+	}
+}
+
+func TestPrimeOrder(t *testing.T) {
+	p := big.NewInt(10007)
+	// #E = 9846 = 2 · 3^2 · 547
+	for _, r := range []int64{2, 3, 547} {
+		res, err := Find(&Config{P: p, A: big.NewInt(2), B: big.NewInt(3), Order: big.NewInt(r), Count: "auto", MaxTries: 100, Seed: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := ec.Curve{P: p, A: big.NewInt(2), B: big.NewInt(3)}
+		G := ec.Point{X: parse(t, res.X), Y: parse(t, res.Y)}
+		if got := bruteOrder(t, c, G); got != r {
+			t.Fatalf("asked for order %d, got %d", r, got)
+		}
+		// both SEC1 forms decode back to G
+		for _, s := range []string{res.SEC1, res.SEC1Compressed} {
+			b, err := hex.DecodeString(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			x, y, err := encoding.Unmarshal(p, c.A, c.B, b)
+			if err != nil || x.Cmp(G.X) != 0 || y.Cmp(G.Y) != 0 {
+				t.Fatalf("%s decodes to (%v, %v), %v; want (%s, %s)", s, x, y, err, res.X, res.Y)
+			}
+		}
+	}
+	if _, err := Find(&Config{P: p, A: big.NewInt(2), B: big.NewInt(3), Order: big.NewInt(5), Count: "auto", MaxTries: 100, Seed: 1}); err == nil {
+		t.Fatal("order 5 does not divide #E but was accepted")
+	}
+}
+
+func parse(t *testing.T, s string) *big.Int {
+	z, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		t.Fatalf("bad integer %q", s)
+	}
+	return z
+}
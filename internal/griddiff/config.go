@@ -0,0 +1,48 @@
+package griddiff
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+type Config struct {
+	PathA, PathB string
+	MaxReport    int    // --max: list at most this many differing cells (0 = all)
+	CountOnly    bool   // --count-only: totals, no cell list
+	JSON         bool   // --json
+	PNG          string // --png: write the overlay image here
+	PNGSize      int    // --png-size: the image's side, or p when smaller
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("griddiff", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: griddiff [flags] A.grid B.grid")
+		fs.PrintDefaults()
+	}
+
+	var (
+		maxReport = fs.Int("max", 50, "list at most this many differing cells (0 = all)")
+		countOnly = fs.Bool("count-only", false, "print only the totals")
+		jsonOut   = fs.Bool("json", false, "emit JSON instead of text")
+		pngPath   = fs.String("png", "", "write an overlay of the two grids as a PNG to this file")
+		pngSize   = fs.Int("png-size", 1024, "side of the --png image in pixels (one pixel per cell when p is smaller)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 2 {
+		return nil, errors.New("want exactly two grid files (ectorus -grid_out)")
+	}
+	if *maxReport < 0 {
+		return nil, fmt.Errorf("bad --max %d", *maxReport)
+	}
+	if *pngSize < 1 || *pngSize > 8192 {
+		return nil, fmt.Errorf("bad --png-size %d (want 1..8192)", *pngSize)
+	}
+	return &Config{PathA: fs.Arg(0), PathB: fs.Arg(1), MaxReport: *maxReport, CountOnly: *countOnly,
+		JSON: *jsonOut, PNG: *pngPath, PNGSize: *pngSize}, nil
+}
@@ -0,0 +1,252 @@
+// Package griddiff compares two ectorus grids cell by cell, behind
+// cmd/griddiff: e.g. the same curve walked from two seeds, or with
+// tangents only against the full walk. Both files are read in lockstep, a
+// chunk at a time, so memory does not grow with p.
+package griddiff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+
+	"ectorus/internal/gridfile"
+)
+
+// A cell's state in one grid. A cell both found and excluded counts as
+// found.
+const (
+	Unknown = iota
+	Found
+	Excluded
+	nStates
+)
+
+var stateNames = [nStates]string{"unknown", "found", "excluded"}
+
+type Result struct {
+	A     File `json:"a"`
+	B     File `json:"b"`
+	Cells int  `json:"cells"`
+	// Transitions counts the cells in each state of A (row) and of B
+	// (column), in the order unknown, found, excluded.
+	Transitions [nStates][nStates]uint64 `json:"transitions"`
+	Differing   uint64                   `json:"differing"`
+	OnlyA       uint64                   `json:"onlyA"`     // classified in A, unknown in B
+	OnlyB       uint64                   `json:"onlyB"`     // classified in B, unknown in A
+	Conflicts   uint64                   `json:"conflicts"` // found in one, excluded in the other
+	List        []Cell                   `json:"list,omitempty"`
+	Notes       []string                 `json:"notes,omitempty"`
+}
+
+type File struct {
+	Path string `json:"path"`
+	gridfile.Header
+	Found    int `json:"found"`
+	Excluded int `json:"excluded"` // and not found
+	Unknown  int `json:"unknown"`
+}
+
+// Cell is one differing cell and its state in each grid.
+type Cell struct {
+	X int    `json:"x"`
+	Y int    `json:"y"`
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+// Same reports whether both grids classify every cell alike.
+func (r *Result) Same() bool { return r.Differing == 0 }
+
+func Run(cfg *Config, w io.Writer) error {
+	r, err := Diff(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(r)
+	} else {
+		err = r.Print(w)
+	}
+	if err != nil {
+		return err
+	}
+	if !r.Same() {
+		return errors.New("grids differ")
+	}
+	return nil
+}
+
+// Diff reads both grids chunk by chunk, counting each pair of states and
+// listing (up to --max) and drawing (--png) the cells that differ.
+func Diff(cfg *Config) (*Result, error) {
+	fa, err := os.Open(cfg.PathA)
+	if err != nil {
+		return nil, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(cfg.PathB)
+	if err != nil {
+		return nil, err
+	}
+	defer fb.Close()
+	ra, err := gridfile.NewReader(fa)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.PathA, err)
+	}
+	rb, err := gridfile.NewReader(fb)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.PathB, err)
+	}
+	ha, hb := ra.Header(), rb.Header()
+	if ha.P != hb.P {
+		return nil, fmt.Errorf("the grids are for p = %d and p = %d; griddiff compares grids of one p", ha.P, hb.P)
+	}
+	p := ha.P
+	r := &Result{A: File{Path: cfg.PathA, Header: ha}, B: File{Path: cfg.PathB, Header: hb}, Cells: p * p}
+	if ha.A != hb.A || ha.B != hb.B || ha.Form != hb.Form {
+		r.Notes = append(r.Notes, fmt.Sprintf("the grids are of different curves (A=%s B=%s %s vs A=%s B=%s %s)", ha.A, ha.B, ha.Form, hb.A, hb.B, hb.Form))
+	}
+	var ov *overlay
+	if cfg.PNG != "" {
+		ov = newOverlay(p, cfg.PNGSize)
+	}
+	list := func() bool { return !cfg.CountOnly && (cfg.MaxReport == 0 || len(r.List) < cfg.MaxReport) }
+
+	var af, ae, bf, be [gridfile.ChunkWords]uint64
+	for ci := 0; ; ci++ {
+		errA, errB := ra.Next(af[:], ae[:]), rb.Next(bf[:], be[:])
+		if errA == io.EOF && errB == io.EOF {
+			break
+		}
+		if errA != nil && errA != io.EOF {
+			return nil, fmt.Errorf("%s: %w", cfg.PathA, errA)
+		}
+		if errB != nil && errB != io.EOF {
+			return nil, fmt.Errorf("%s: %w", cfg.PathB, errB)
+		}
+		base := ci * gridfile.ChunkCells
+		for j := range af {
+			at := base + 64*j
+			if at >= p*p {
+				break
+			}
+			valid := ^uint64(0)
+			if n := p*p - at; n < 64 {
+				valid = 1<<n - 1
+			}
+			ma := states(af[j], ae[j], valid)
+			mb := states(bf[j], be[j], valid)
+			for s := range nStates {
+				for t := range nStates {
+					m := ma[s] & mb[t]
+					if m == 0 {
+						continue
+					}
+					r.Transitions[s][t] += uint64(bits.OnesCount64(m))
+					if ov != nil {
+						ov.mark(at, m, kindOf(s, t))
+					}
+					if s == t {
+						continue
+					}
+					for ; m != 0 && list(); m &= m - 1 {
+						i := at + bits.TrailingZeros64(m)
+						r.List = append(r.List, Cell{X: i % p, Y: i / p, A: stateNames[s], B: stateNames[t]})
+					}
+				}
+			}
+		}
+	}
+	r.A.count(r, 0)
+	r.B.count(r, 1)
+	for s := range nStates {
+		for t := range nStates {
+			n := r.Transitions[s][t]
+			switch {
+			case s == t:
+				continue
+			case t == Unknown:
+				r.OnlyA += n
+			case s == Unknown:
+				r.OnlyB += n
+			default:
+				r.Conflicts += n
+			}
+			r.Differing += n
+		}
+	}
+	if ov != nil {
+		if err := ov.writePNG(cfg.PNG); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// states splits one word of a grid into its unknown, found and excluded
+// cells.
+func states(found, excl, valid uint64) [nStates]uint64 {
+	found &= valid
+	excl &= valid &^ found
+	return [nStates]uint64{valid &^ (found | excl), found, excl}
+}
+
+// count fills in f's totals from one side (0 = A) of the transitions.
+func (f *File) count(r *Result, side int) {
+	var n [nStates]uint64
+	for s := range nStates {
+		for t := range nStates {
+			if side == 0 {
+				n[s] += r.Transitions[s][t]
+			} else {
+				n[t] += r.Transitions[s][t]
+			}
+		}
+	}
+	f.Unknown, f.Found, f.Excluded = int(n[Unknown]), int(n[Found]), int(n[Excluded])
+}
+
+// Print lists the differing cells as "x y A-state B-state", followed by the
+// totals and the transition table.
+func (r *Result) Print(w io.Writer) error {
+	ew := &errWriter{w: w}
+	for _, c := range r.List {
+		ew.printf("%d %d %s %s\n", c.X, c.Y, c.A, c.B)
+	}
+	if shown := uint64(len(r.List)); shown > 0 && shown < r.Differing {
+		ew.printf("(%d of %d differing cells listed)\n", shown, r.Differing)
+	}
+	for _, f := range []File{r.A, r.B} {
+		ew.printf("# %s: p=%d A=%s B=%s %s, %d lines; found %d, excluded %d, unknown %d\n",
+			f.Path, f.P, f.Header.A, f.Header.B, f.Form, f.Lines, f.Found, f.Excluded, f.Unknown)
+	}
+	ew.printf("# %-9s %12s %12s %12s\n", "A \\ B", stateNames[0], stateNames[1], stateNames[2])
+	for s := range nStates {
+		ew.printf("# %-9s %12d %12d %12d\n", stateNames[s], r.Transitions[s][0], r.Transitions[s][1], r.Transitions[s][2])
+	}
+	ew.printf("# differing %d of %d cells: classified only in A %d, only in B %d, found/excluded conflicts %d\n",
+		r.Differing, r.Cells, r.OnlyA, r.OnlyB, r.Conflicts)
+	for _, n := range r.Notes {
+		ew.printf("# note: %s\n", n)
+	}
+	return ew.err
+}
+
+// errWriter keeps the first write error so Print can stay linear.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = fmt.Fprintf(e.w, format, args...)
+}
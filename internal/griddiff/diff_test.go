@@ -0,0 +1,100 @@
+package griddiff
+
+import (
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ectorus/internal/gridfile"
+)
+
+// writeGrid writes a p×p grid with the given found and excluded cells.
+func writeGrid(t *testing.T, name string, h gridfile.Header, found, excl [][2]int) string {
+	t.Helper()
+	p := h.P
+	f := make([]uint64, gridfile.Chunks(p)*gridfile.ChunkWords)
+	e := make([]uint64, len(f))
+	for _, c := range found {
+		i := c[1]*p + c[0]
+		f[i/64] |= 1 << (i % 64)
+	}
+	for _, c := range excl {
+		i := c[1]*p + c[0]
+		e[i/64] |= 1 << (i % 64)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	w, err := gridfile.NewWriter(out, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ci := range gridfile.Chunks(p) {
+		if err := w.Chunk(f[ci*gridfile.ChunkWords:][:gridfile.ChunkWords], e[ci*gridfile.ChunkWords:][:gridfile.ChunkWords]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDiff(t *testing.T) {
+	h := gridfile.Header{P: 101, A: "2", B: "3", Form: "weierstrass"}
+	a := writeGrid(t, "a.grid", h, [][2]int{{1, 41}, {1, 60}}, [][2]int{{0, 0}, {5, 5}, {100, 100}})
+	b := writeGrid(t, "b.grid", h, [][2]int{{1, 41}, {5, 5}}, [][2]int{{0, 0}, {1, 60}, {7, 9}})
+
+	img := filepath.Join(t.TempDir(), "d.png")
+	r, err := Diff(&Config{PathA: a, PathB: b, PNG: img, PNGSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Same() || r.Differing != 4 || r.Conflicts != 2 || r.OnlyA != 1 || r.OnlyB != 1 {
+		t.Fatalf("counts %+v", r)
+	}
+	if r.Transitions[Found][Found] != 1 || r.Transitions[Excluded][Excluded] != 1 || r.Transitions[Unknown][Unknown] != 101*101-6 {
+		t.Fatalf("transitions %v", r.Transitions)
+	}
+	if r.A.Found != 2 || r.A.Excluded != 3 || r.B.Unknown != 101*101-5 {
+		t.Fatalf("totals %+v / %+v", r.A, r.B)
+	}
+	want := []Cell{{5, 5, "excluded", "found"}, {7, 9, "unknown", "excluded"}, {1, 60, "found", "excluded"}, {100, 100, "excluded", "unknown"}}
+	if len(r.List) != len(want) {
+		t.Fatalf("list %v", r.List)
+	}
+	for i := range want {
+		if r.List[i] != want[i] {
+			t.Fatalf("list %v, want %v", r.List, want)
+		}
+	}
+
+	f, err := os.Open(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one pixel per cell, y growing upwards
+	if c := m.At(1, 100-60); c != kindColors[kindConflict] {
+		t.Fatalf("(1, 60) drawn as %v", c)
+	}
+	if c := m.At(100, 0); c != kindColors[kindOnlyA] {
+		t.Fatalf("(100, 100) drawn as %v", c)
+	}
+
+	if r, err := Diff(&Config{PathA: a, PathB: a}); err != nil || !r.Same() {
+		t.Fatalf("a grid differs from itself: %v %+v", err, r)
+	}
+	c := writeGrid(t, "c.grid", gridfile.Header{P: 103, A: "2", B: "3"}, nil, nil)
+	if _, err := Diff(&Config{PathA: a, PathB: c}); err == nil {
+		t.Fatal("grids of different p compared")
+	}
+}
@@ -0,0 +1,97 @@
+package griddiff
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math/bits"
+	"os"
+)
+
+// ----------- --png: the two grids overlaid ------------
+
+// Pixel kinds, most telling last: a pixel covering several cells takes the
+// highest kind among them.
+const (
+	kindUnknown  = iota // unknown in both
+	kindExcluded        // excluded in both
+	kindFound           // found in both
+	kindOnlyB           // classified in B only
+	kindOnlyA           // classified in A only
+	kindConflict        // found in one, excluded in the other
+)
+
+var kindColors = []color.RGBA{
+	kindUnknown:  {0, 0, 0, 255},
+	kindExcluded: {64, 64, 64, 255},
+	kindFound:    {255, 255, 255, 255},
+	kindOnlyB:    {249, 142, 9, 255},
+	kindOnlyA:    {40, 120, 230, 255},
+	kindConflict: {230, 30, 40, 255},
+}
+
+// kindOf is the pixel kind of a cell in state s in A and t in B.
+func kindOf(s, t int) uint8 {
+	switch {
+	case s == t && s == Found:
+		return kindFound
+	case s == t && s == Excluded:
+		return kindExcluded
+	case s == t:
+		return kindUnknown
+	case t == Unknown:
+		return kindOnlyA
+	case s == Unknown:
+		return kindOnlyB
+	}
+	return kindConflict
+}
+
+// overlay bins the p×p torus into a W×W image of kinds.
+type overlay struct {
+	p, W  int
+	kinds []uint8
+}
+
+func newOverlay(p, size int) *overlay {
+	W := min(p, size)
+	return &overlay{p: p, W: W, kinds: make([]uint8, W*W)}
+}
+
+// mark records kind k for the cells of mask m, which start at cell at. At
+// one pixel per cell every cell is drawn; when pixels cover several cells,
+// the cells both grids agree on are drawn at the ends of each word only,
+// as they are the bulk of a large grid and the least interesting part.
+func (o *overlay) mark(at int, m uint64, k uint8) {
+	if o.W < o.p && k <= kindFound {
+		o.set(at+bits.TrailingZeros64(m), k)
+		o.set(at+63-bits.LeadingZeros64(m), k)
+		return
+	}
+	for ; m != 0; m &= m - 1 {
+		o.set(at+bits.TrailingZeros64(m), k)
+	}
+}
+
+func (o *overlay) set(i int, k uint8) {
+	x, y := i%o.p*o.W/o.p, i/o.p*o.W/o.p
+	// image row 0 is the top, field y grows up
+	px := &o.kinds[(o.W-1-y)*o.W+x]
+	*px = max(*px, k)
+}
+
+func (o *overlay) writePNG(path string) error {
+	img := image.NewRGBA(image.Rect(0, 0, o.W, o.W))
+	for i, k := range o.kinds {
+		img.SetRGBA(i%o.W, i/o.W, kindColors[k])
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,351 @@
+// Package gridfile is the binary format of an ectorus grid: the FOUND and
+// EXCLUDED bitsets of the p×p torus, written by -grid_out, read back by
+// -grid_in and compared by griddiff.
+//
+// A file is the magic "ECGRID1\n", a JSON Header (its length as a uvarint
+// first), then the cells in chunks of ChunkCells: cell i = y*p + x is bit
+// i%64 of word i/64, ChunkWords words to a chunk, as in ectorus's dense
+// bitset. Each chunk stores its found set and then its excluded set, each
+// a tag byte and its data:
+//
+//	0 empty
+//	1 full (every cell of the chunk that lies on the torus)
+//	2 array: a uvarint count, then the offsets as uvarint gaps
+//	3 bitmap: ChunkWords little-endian uint64s
+//
+// The trailer is the found, excluded and classified (found or excluded)
+// cell counts as uvarints and a CRC-32 (IEEE) of every byte before it.
+// Chunks are interleaved so two files can be read side by side in
+// constant memory.
+package gridfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math/bits"
+)
+
+const magic = "ECGRID1\n"
+
+// ChunkCells is the span of one chunk; ChunkWords its size in uint64s.
+const (
+	ChunkCells = 1 << 12
+	ChunkWords = ChunkCells / 64
+)
+
+// arrayMax is the most offsets a chunk stores as an array; beyond it the
+// bitmap is smaller.
+const arrayMax = ChunkCells / 16
+
+const (
+	tagEmpty = iota
+	tagFull
+	tagArray
+	tagBitmap
+)
+
+// Header describes the grid's curve and walk.
+type Header struct {
+	P     int    `json:"p"`
+	A     string `json:"A"`
+	B     string `json:"B"`
+	Form  string `json:"form,omitempty"`
+	Lines int    `json:"linesProcessed"`
+	Note  string `json:"note,omitempty"` // free text, e.g. the walk's flags
+}
+
+// Counts are the trailer's cell counts.
+type Counts struct {
+	Found, Excluded, Classified int
+}
+
+// Chunks is the number of chunks of a p×p grid.
+func Chunks(p int) int { return (p*p + ChunkCells - 1) / ChunkCells }
+
+// cellsIn is how many cells of chunk ci lie on a p×p torus.
+func cellsIn(p, ci int) int { return min(ChunkCells, p*p-ci*ChunkCells) }
+
+// Writer writes a grid file chunk by chunk.
+type Writer struct {
+	bw     *bufio.Writer
+	crc    hash.Hash32
+	w      io.Writer // bw, through crc
+	p      int
+	ci     int
+	counts Counts
+	buf    []byte
+}
+
+// NewWriter writes the magic and h.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	if h.P < 2 {
+		return nil, fmt.Errorf("gridfile: p = %d", h.P)
+	}
+	hb, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	gw := &Writer{bw: bufio.NewWriter(w), crc: crc32.NewIEEE(), p: h.P}
+	gw.w = io.MultiWriter(gw.bw, gw.crc)
+	b := append([]byte(magic), binary.AppendUvarint(nil, uint64(len(hb)))...)
+	_, err = gw.w.Write(append(b, hb...))
+	return gw, err
+}
+
+// Chunk writes the next chunk's found and excluded words (ChunkWords
+// each; bits past the torus must be clear).
+func (w *Writer) Chunk(found, excl []uint64) error {
+	if w.ci >= Chunks(w.p) {
+		return errors.New("gridfile: more chunks than the grid has")
+	}
+	if len(found) != ChunkWords || len(excl) != ChunkWords {
+		return errors.New("gridfile: a chunk is ChunkWords words")
+	}
+	n := cellsIn(w.p, w.ci)
+	var nf, ne int
+	w.buf, nf = appendSet(w.buf[:0], found, n)
+	w.buf, ne = appendSet(w.buf, excl, n)
+	w.counts.Found += nf
+	w.counts.Excluded += ne
+	for i := range found {
+		w.counts.Classified += bits.OnesCount64(found[i] | excl[i])
+	}
+	w.ci++
+	_, err := w.w.Write(w.buf)
+	return err
+}
+
+// appendSet encodes one set of a chunk of n cells, returning its size.
+func appendSet(b []byte, set []uint64, n int) ([]byte, int) {
+	k := 0
+	for _, x := range set {
+		k += bits.OnesCount64(x)
+	}
+	switch {
+	case k == 0:
+		return append(b, tagEmpty), 0
+	case k == n:
+		return append(b, tagFull), k
+	case k <= arrayMax:
+		b = append(b, tagArray)
+		b = binary.AppendUvarint(b, uint64(k))
+		prev := -1
+		for i, x := range set {
+			for x != 0 {
+				off := i*64 + bits.TrailingZeros64(x)
+				b = binary.AppendUvarint(b, uint64(off-prev-1))
+				prev = off
+				x &= x - 1
+			}
+		}
+		return b, k
+	}
+	b = append(b, tagBitmap)
+	for _, x := range set {
+		b = binary.LittleEndian.AppendUint64(b, x)
+	}
+	return b, k
+}
+
+// Close writes the trailer once every chunk is in, and flushes.
+func (w *Writer) Close() error {
+	if w.ci != Chunks(w.p) {
+		return fmt.Errorf("gridfile: %d of %d chunks written", w.ci, Chunks(w.p))
+	}
+	var b []byte
+	for _, v := range []int{w.counts.Found, w.counts.Excluded, w.counts.Classified} {
+		b = binary.AppendUvarint(b, uint64(v))
+	}
+	if _, err := w.w.Write(b); err != nil {
+		return err
+	}
+	if _, err := w.bw.Write(binary.LittleEndian.AppendUint32(nil, w.crc.Sum32())); err != nil {
+		return err
+	}
+	return w.bw.Flush()
+}
+
+// Reader reads a grid file chunk by chunk.
+type Reader struct {
+	br     *bufio.Reader
+	crc    hash.Hash32
+	h      Header
+	ci     int
+	counts Counts
+	got    Counts // counted while reading
+}
+
+// crcReader feeds what it reads to the CRC.
+type crcReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (c crcReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.crc.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (c crcReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.crc.Write(p[:n])
+	return n, err
+}
+
+func (r *Reader) src() crcReader { return crcReader{r.br, r.crc} }
+
+// NewReader reads the magic and the header.
+func NewReader(rd io.Reader) (*Reader, error) {
+	r := &Reader{br: bufio.NewReader(rd), crc: crc32.NewIEEE()}
+	m := make([]byte, len(magic))
+	if _, err := io.ReadFull(r.src(), m); err != nil || string(m) != magic {
+		return nil, errors.New("not an ectorus grid file")
+	}
+	n, err := binary.ReadUvarint(r.src())
+	if err != nil || n > 1<<16 {
+		return nil, errors.New("gridfile: bad header")
+	}
+	hb := make([]byte, n)
+	if _, err := io.ReadFull(r.src(), hb); err != nil {
+		return nil, fmt.Errorf("gridfile: header: %w", err)
+	}
+	if err := json.Unmarshal(hb, &r.h); err != nil {
+		return nil, fmt.Errorf("gridfile: header: %w", err)
+	}
+	if r.h.P < 2 || r.h.P > 1<<20 {
+		return nil, fmt.Errorf("gridfile: header has p = %d", r.h.P)
+	}
+	return r, nil
+}
+
+// Header is the file's header.
+func (r *Reader) Header() Header { return r.h }
+
+// Counts is the trailer, once Next has returned io.EOF.
+func (r *Reader) Counts() Counts { return r.counts }
+
+// Next fills found and excluded (ChunkWords each) with the next chunk. It
+// returns io.EOF after the last chunk, once the trailer and CRC check out.
+func (r *Reader) Next(found, excl []uint64) error {
+	if r.ci == Chunks(r.h.P) {
+		return r.trailer()
+	}
+	if len(found) != ChunkWords || len(excl) != ChunkWords {
+		return errors.New("gridfile: a chunk is ChunkWords words")
+	}
+	n := cellsIn(r.h.P, r.ci)
+	nf, err := r.readSet(found, n)
+	if err != nil {
+		return fmt.Errorf("gridfile: chunk %d: %w", r.ci, err)
+	}
+	ne, err := r.readSet(excl, n)
+	if err != nil {
+		return fmt.Errorf("gridfile: chunk %d: %w", r.ci, err)
+	}
+	r.got.Found += nf
+	r.got.Excluded += ne
+	for i := range found {
+		r.got.Classified += bits.OnesCount64(found[i] | excl[i])
+	}
+	r.ci++
+	return nil
+}
+
+func (r *Reader) readSet(set []uint64, n int) (int, error) {
+	clear(set)
+	src := r.src()
+	tag, err := src.ReadByte()
+	if err != nil {
+		return 0, unexpected(err)
+	}
+	switch tag {
+	case tagEmpty:
+		return 0, nil
+	case tagFull:
+		for i := 0; i < n; i += 64 {
+			set[i/64] = ^uint64(0)
+		}
+		if n%64 != 0 {
+			set[n/64] = 1<<(n%64) - 1
+		}
+		return n, nil
+	case tagArray:
+		k, err := binary.ReadUvarint(src)
+		if err != nil || k > uint64(n) {
+			return 0, errors.New("bad array length")
+		}
+		off := -1
+		for range k {
+			gap, err := binary.ReadUvarint(src)
+			if err != nil {
+				return 0, unexpected(err)
+			}
+			if off += int(gap) + 1; gap >= uint64(n) || off >= n {
+				return 0, errors.New("offset off the torus")
+			}
+			set[off/64] |= 1 << (off % 64)
+		}
+		return int(k), nil
+	case tagBitmap:
+		var b [8 * ChunkWords]byte
+		if _, err := io.ReadFull(src, b[:]); err != nil {
+			return 0, unexpected(err)
+		}
+		k := 0
+		for i := range set {
+			set[i] = binary.LittleEndian.Uint64(b[8*i:])
+			k += bits.OnesCount64(set[i])
+		}
+		if n < ChunkCells {
+			tail := set[n/64] >> (n % 64)
+			for _, x := range set[n/64+1:] {
+				tail |= x
+			}
+			if tail != 0 {
+				return 0, errors.New("bits off the torus")
+			}
+		}
+		return k, nil
+	}
+	return 0, fmt.Errorf("bad tag %d", tag)
+}
+
+func (r *Reader) trailer() error {
+	var vs [3]int
+	for i := range vs {
+		v, err := binary.ReadUvarint(r.src())
+		if err != nil {
+			return fmt.Errorf("gridfile: trailer: %w", unexpected(err))
+		}
+		vs[i] = int(v)
+	}
+	sum := r.crc.Sum32()
+	var b [4]byte
+	if _, err := io.ReadFull(r.br, b[:]); err != nil {
+		return fmt.Errorf("gridfile: trailer: %w", unexpected(err))
+	}
+	if binary.LittleEndian.Uint32(b[:]) != sum {
+		return errors.New("gridfile: CRC mismatch")
+	}
+	r.counts = Counts{vs[0], vs[1], vs[2]}
+	if r.counts != r.got {
+		return fmt.Errorf("gridfile: trailer counts %+v, chunks hold %+v", r.counts, r.got)
+	}
+	return io.EOF
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package gridfile
+
+import (
+	"bytes"
+	"io"
+	"math/bits"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	const p = 101 // 10201 cells: two whole chunks and a partial one
+	rng := rand.New(rand.NewSource(1))
+	found := make([]uint64, Chunks(p)*ChunkWords)
+	excl := make([]uint64, len(found))
+	set := func(s []uint64, i int) { s[i/64] |= 1 << (i % 64) }
+	for range 40 { // sparse: arrays
+		set(found, rng.Intn(p*p))
+	}
+	for i := range ChunkCells { // chunk 0 full, chunk 1 a bitmap
+		set(excl, i)
+		if rng.Intn(3) == 0 {
+			set(excl, ChunkCells+i)
+		}
+	}
+	for i := 2 * ChunkCells; i < p*p; i++ { // a full partial chunk
+		set(excl, i)
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{P: p, A: "2", B: "3", Form: "weierstrass", Lines: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ci := range Chunks(p) {
+		if err := w.Chunk(found[ci*ChunkWords:][:ChunkWords], excl[ci*ChunkWords:][:ChunkWords]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 2000 {
+		t.Errorf("%d bytes; want the full chunks as tags", buf.Len())
+	}
+	data := buf.Bytes()
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Header(); h.P != p || h.A != "2" || h.Lines != 7 {
+		t.Fatalf("header %+v", h)
+	}
+	f, x := make([]uint64, ChunkWords), make([]uint64, ChunkWords)
+	for ci := 0; ; ci++ {
+		if err := r.Next(f, x); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if !equal(f, found[ci*ChunkWords:]) || !equal(x, excl[ci*ChunkWords:]) {
+			t.Fatalf("chunk %d differs", ci)
+		}
+	}
+	var want Counts
+	for i := range found {
+		want.Found += bits.OnesCount64(found[i])
+		want.Excluded += bits.OnesCount64(excl[i])
+		want.Classified += bits.OnesCount64(found[i] | excl[i])
+	}
+	if c := r.Counts(); c != want {
+		t.Fatalf("counts %+v, want %+v", c, want)
+	}
+
+	// a flipped bit anywhere past the header fails the CRC (or the decode)
+	bad := append([]byte(nil), data...)
+	bad[len(bad)-8] ^= 1
+	r, _ = NewReader(bytes.NewReader(bad))
+	var rerr error
+	for rerr == nil {
+		rerr = r.Next(f, x)
+	}
+	if rerr == io.EOF {
+		t.Fatal("corrupt file read cleanly")
+	}
+	if _, err := NewReader(strings.NewReader("# ecscan v=2\n")); err == nil {
+		t.Fatal("an ecscan dump read as a grid")
+	}
+}
+
+func equal(a, b []uint64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package pointsrpc
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+type Config struct {
+	Addr    string // --addr: listen address, e.g. :50051
+	MaxP    uint64 // --max-p: largest p a call may ask for
+	Workers int    // --workers: ecscan workers per call (0 = GOMAXPROCS*4)
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("asteroids grpc", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: asteroids grpc [flags]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		addr    = fs.String("addr", ":50051", "listen address for the gRPC service (plaintext HTTP/2)")
+		maxP    = fs.Uint64("max-p", 1<<32, "largest p a call may ask for (a call streams about p points)")
+		workers = fs.Int("workers", 0, "ecscan workers per call (0 = GOMAXPROCS*4)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 0 {
+		return nil, fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	if *maxP <= 3 || *maxP >= 1<<63 {
+		return nil, errors.New("--max-p must be in (3, 2^63)")
+	}
+	if *workers < 0 {
+		return nil, fmt.Errorf("bad --workers %d", *workers)
+	}
+	return &Config{Addr: *addr, MaxP: *maxP, Workers: *workers}, nil
+}
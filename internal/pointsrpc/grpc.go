@@ -0,0 +1,264 @@
+// Package pointsrpc is the gRPC service behind `asteroids grpc`: the
+// EnumeratePoints stream of points.proto, on top of ecscan's library API,
+// so clients in other languages get points without parsing ecscan's text
+// output.
+package pointsrpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"ectorus/internal/ecscan"
+)
+
+// ------------------- gRPC over HTTP/2 -------------------
+
+// A gRPC call is an HTTP/2 POST to /package.Service/Method with content
+// type application/grpc. Messages in both directions are framed as a
+// compressed flag byte and a 4-byte big-endian length, and the outcome
+// goes in the grpc-status and grpc-message trailers. net/http speaks
+// HTTP/2 without TLS (h2c) and sends trailers, so one server-streaming
+// method needs nothing more.
+
+const enumeratePath = "/asteroids.v1.Points/EnumeratePoints"
+
+// gRPC status codes used here.
+const (
+	codeOK               = 0
+	codeCanceled         = 1
+	codeInvalidArgument  = 3
+	codeDeadlineExceeded = 4
+	codeUnimplemented    = 12
+	codeInternal         = 13
+)
+
+// maxRequest caps the request message; a CurveParams is at most ~40 bytes.
+const maxRequest = 1 << 10
+
+// Stream batching: frames are gathered and flushed once flushBytes have
+// built up or flushEvery has passed, so a fast scan sends large DATA
+// frames and a slow one still trickles.
+const (
+	flushBytes = 32 << 10
+	flushEvery = 100 * time.Millisecond
+)
+
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+func errorf(code int, format string, args ...any) error {
+	return &statusError{code, fmt.Sprintf(format, args...)}
+}
+
+// Handler serves the Points service. Other methods answer UNIMPLEMENTED.
+func Handler(cfg *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http.Error(w, "gRPC needs HTTP/2", http.StatusHTTPVersionNotSupported)
+			return
+		}
+		if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			http.Error(w, "not a gRPC request", http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		var err error
+		if r.URL.Path == enumeratePath {
+			err = enumerate(w, r, cfg)
+		} else {
+			err = errorf(codeUnimplemented, "unknown method %s", r.URL.Path)
+		}
+		writeStatus(w, err)
+	})
+}
+
+// writeStatus ends the call with err's gRPC status as trailers.
+func writeStatus(w http.ResponseWriter, err error) {
+	code, msg := codeOK, ""
+	var se *statusError
+	switch {
+	case err == nil:
+	case errors.As(err, &se):
+		code, msg = se.code, se.msg
+	default:
+		code, msg = codeInternal, err.Error()
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", percentEncode(msg))
+	}
+}
+
+// percentEncode escapes a grpc-message as the spec asks: every byte
+// outside printable ASCII, and '%'.
+func percentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// parseTimeout reads a grpc-timeout header: up to 8 digits and a unit.
+func parseTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	unit, ok := map[byte]time.Duration{
+		'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond,
+	}[s[len(s)-1]]
+	return time.Duration(n) * unit, ok
+}
+
+// readMessage reads one length-prefixed request message.
+func readMessage(r io.Reader) ([]byte, error) {
+	var h [5]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return nil, errorf(codeInvalidArgument, "reading request: %v", err)
+	}
+	if h[0] != 0 {
+		return nil, errorf(codeUnimplemented, "compressed requests are not supported")
+	}
+	n := binary.BigEndian.Uint32(h[1:])
+	if n > maxRequest {
+		return nil, errorf(codeInvalidArgument, "request of %d bytes", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, errorf(codeInvalidArgument, "reading request: %v", err)
+	}
+	return b, nil
+}
+
+// check validates a request against cfg, as ecscan's own flags would.
+func (m CurveParams) check(cfg *Config) (CurveParams, error) {
+	if m.P <= 3 || m.P > cfg.MaxP || !new(big.Int).SetUint64(m.P).ProbablyPrime(32) {
+		return m, errorf(codeInvalidArgument, "p = %d is not a prime in (3, %d]", m.P, cfg.MaxP)
+	}
+	m.A, m.B = m.A%m.P, m.B%m.P
+	// 4A^3 + 27B^2 ≠ 0 mod p
+	a, b, p := new(big.Int).SetUint64(m.A), new(big.Int).SetUint64(m.B), new(big.Int).SetUint64(m.P)
+	d := new(big.Int).Mul(big.NewInt(4), new(big.Int).Exp(a, big.NewInt(3), nil))
+	d.Add(d, new(big.Int).Mul(big.NewInt(27), new(big.Int).Mul(b, b)))
+	if d.Mod(d, p).Sign() == 0 {
+		return m, errorf(codeInvalidArgument, "y^2 = x^3 + %dx + %d is singular mod %d", m.A, m.B, m.P)
+	}
+	return m, nil
+}
+
+// enumerate is EnumeratePoints: it decodes the CurveParams and streams a
+// Point message per affine point until the scan ends, the deadline
+// passes or the client cancels.
+func enumerate(w http.ResponseWriter, r *http.Request, cfg *Config) error {
+	ctx := r.Context()
+	if t := r.Header.Get("Grpc-Timeout"); t != "" {
+		d, ok := parseTimeout(t)
+		if !ok {
+			return errorf(codeInvalidArgument, "bad grpc-timeout %q", t)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	b, err := readMessage(r.Body)
+	if err != nil {
+		return err
+	}
+	req, err := unmarshalCurveParams(b)
+	if err != nil {
+		return errorf(codeInvalidArgument, "CurveParams: %v", err)
+	}
+	if req, err = req.check(cfg); err != nil {
+		return err
+	}
+
+	rc := http.NewResponseController(w)
+	w.WriteHeader(http.StatusOK)
+	buf := make([]byte, 0, flushBytes+32)
+	last := time.Now()
+	flush := func() error {
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		buf, last = buf[:0], time.Now()
+		return rc.Flush()
+	}
+	n := 0
+	start := time.Now()
+	err = ecscan.Scan(ctx, ecscan.Params{P: req.P, A: req.A, B: req.B, Workers: cfg.Workers, Ordered: req.Ordered}, func(x, y uint64) error {
+		// frame header, then the message; a Point is at most 22 bytes
+		at := len(buf)
+		buf = append(buf, 0, 0, 0, 0, 0)
+		buf = appendPoint(buf, x, y)
+		binary.BigEndian.PutUint32(buf[at+1:], uint32(len(buf)-at-5))
+		n++
+		if len(buf) >= flushBytes || time.Since(last) >= flushEvery {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	log.Printf("EnumeratePoints p=%d A=%d B=%d: %d points in %s", req.P, req.A, req.B, n, time.Since(start).Round(time.Millisecond))
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return errorf(codeDeadlineExceeded, "deadline exceeded after %d points", n)
+	case ctx.Err() != nil:
+		return errorf(codeCanceled, "cancelled after %d points", n)
+	}
+	return err
+}
+
+// Run serves the Points service on cfg.Addr, over HTTP/2 without TLS,
+// until interrupted.
+func Run(cfg *Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("--addr: %w", err)
+	}
+	var protos http.Protocols
+	protos.SetHTTP1(true) // so a stray curl gets an answer
+	protos.SetUnencryptedHTTP2(true)
+	srv := &http.Server{Handler: Handler(cfg), Protocols: &protos, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(sctx)
+	}()
+	log.Printf("gRPC Points service on %s (h2c)", ln.Addr())
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
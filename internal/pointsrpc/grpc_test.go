@@ -0,0 +1,121 @@
+package pointsrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ectorus/internal/ecscan"
+)
+
+// call makes an EnumeratePoints call over h2c with the request message
+// req and returns the points and the grpc-status trailer.
+func call(t *testing.T, url string, req []byte, timeout string) ([][2]uint64, string) {
+	t.Helper()
+	var protos http.Protocols
+	protos.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &protos}}
+	body := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(req)))
+	hreq, _ := http.NewRequest("POST", url+enumeratePath, bytes.NewReader(append(body, req...)))
+	hreq.Header.Set("Content-Type", "application/grpc")
+	hreq.Header.Set("TE", "trailers")
+	if timeout != "" {
+		hreq.Header.Set("Grpc-Timeout", timeout)
+	}
+	r, err := client.Do(hreq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+	var pts [][2]uint64
+	for {
+		var h [5]byte
+		if _, err := io.ReadFull(r.Body, h[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		msg := make([]byte, binary.BigEndian.Uint32(h[1:]))
+		if _, err := io.ReadFull(r.Body, msg); err != nil {
+			t.Fatal(err)
+		}
+		// a Point has the same fields 1 and 2 as CurveParams
+		m, err := unmarshalCurveParams(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pts = append(pts, [2]uint64{m.P, m.A})
+	}
+	return pts, r.Trailer.Get("Grpc-Status")
+}
+
+func curveParams(p, a, b uint64, ordered bool) []byte {
+	var m []byte
+	m = appendVarintField(m, 1, p)
+	m = appendVarintField(m, 2, a)
+	m = appendVarintField(m, 3, b)
+	if ordered {
+		m = appendVarintField(m, 4, 1)
+	}
+	return m
+}
+
+func TestEnumeratePoints(t *testing.T) {
+	srv := httptest.NewUnstartedServer(Handler(&Config{MaxP: 1 << 20}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	var want [][2]uint64
+	ecscan.Scan(context.Background(), ecscan.Params{P: 10007, A: 2, B: 3, Ordered: true}, func(x, y uint64) error {
+		want = append(want, [2]uint64{x, y})
+		return nil
+	})
+	// an unknown field 9 (bytes) must be skipped
+	req := append(curveParams(10007, 2, 3, true), 9<<3|wireBytes, 2, 'h', 'i')
+	got, status := call(t, srv.URL, req, "")
+	if status != "0" || len(got) != len(want) {
+		t.Fatalf("status %q, %d points, want %d", status, len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("point %d: %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	for _, tc := range []struct {
+		req    []byte
+		status string
+	}{
+		{curveParams(10001, 2, 3, false), "3"},   // not prime
+		{curveParams(1<<21+7, 2, 3, false), "3"}, // above --max-p
+		{curveParams(101, 0, 0, false), "3"},     // singular
+		{[]byte{0x08}, "3"},                      // truncated
+	} {
+		if pts, status := call(t, srv.URL, tc.req, ""); status != tc.status || len(pts) != 0 {
+			t.Errorf("%x: status %q, %d points", tc.req, status, len(pts))
+		}
+	}
+	if _, status := call(t, srv.URL, curveParams(1048573, 2, 3, false), "1n"); status != "4" {
+		t.Errorf("expired deadline: status %q", status)
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	for s, want := range map[string]time.Duration{"10S": 10 * time.Second, "250m": 250 * time.Millisecond, "1H": time.Hour} {
+		if d, ok := parseTimeout(s); !ok || d != want {
+			t.Errorf("%s: %v %v", s, d, ok)
+		}
+	}
+	for _, s := range []string{"", "S", "10", "10x", "123456789S"} {
+		if _, ok := parseTimeout(s); ok {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
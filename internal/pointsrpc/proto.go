@@ -0,0 +1,92 @@
+package pointsrpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ------------------- protobuf wire format -------------------
+
+// points.proto has two small messages of varint fields, so they are
+// encoded and decoded by hand rather than through generated code.
+
+// CurveParams is the request message of points.proto.
+type CurveParams struct {
+	P, A, B uint64
+	Ordered bool
+}
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated message")
+
+// unmarshalCurveParams decodes b, skipping fields it does not know, as
+// proto3 requires.
+func unmarshalCurveParams(b []byte) (CurveParams, error) {
+	var m CurveParams
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return m, errTruncated
+		}
+		b = b[n:]
+		field, wire := key>>3, key&7
+		var v uint64
+		switch wire {
+		case wireVarint:
+			if v, n = binary.Uvarint(b); n <= 0 {
+				return m, errTruncated
+			}
+			b = b[n:]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if wire == wireFixed32 {
+				size = 4
+			}
+			if len(b) < size {
+				return m, errTruncated
+			}
+			b = b[size:]
+			continue
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return m, errTruncated
+			}
+			b = b[n+int(l):]
+			continue
+		default:
+			return m, fmt.Errorf("field %d: unsupported wire type %d", field, wire)
+		}
+		switch field {
+		case 1:
+			m.P = v
+		case 2:
+			m.A = v
+		case 3:
+			m.B = v
+		case 4:
+			m.Ordered = v != 0
+		}
+	}
+	return m, nil
+}
+
+// appendPoint appends the Point message {x, y}.
+func appendPoint(b []byte, x, y uint64) []byte {
+	return appendVarintField(appendVarintField(b, 1, x), 2, y)
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
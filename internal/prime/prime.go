@@ -0,0 +1,139 @@
+// Package prime decides whether a curve modulus is prime: a BPSW test that
+// has no known counterexample (and none at all below 2^64), small-factor
+// search for composites, and Pocklington certificates that prove primality
+// outright.
+package prime
+
+import (
+	"math/big"
+	"sort"
+)
+
+var (
+	one = big.NewInt(1)
+	two = big.NewInt(2)
+)
+
+// trialBound is the limit of the trial division in Factor.
+const trialBound = 1 << 16
+
+// rhoSteps bounds each Pollard rho attempt in Factor; factors up to about
+// 2^40 fall out well within it.
+const rhoSteps = 1 << 20
+
+// BPSW is the Baillie–PSW test: a strong probable-prime test to base 2 and a
+// strong Lucas test. math/big's ProbablyPrime(0) is exactly that, and it is
+// proven correct for n < 2^64.
+func BPSW(n *big.Int) bool { return n.ProbablyPrime(0) }
+
+// Factor splits n > 1 by trial division below 2^16 and then Pollard–Brent
+// rho, returning the prime factors found (with multiplicity, ascending;
+// those above 2^64 are BPSW probable primes) and the composite part rho
+// could not split, 1 when n is fully factored.
+func Factor(n *big.Int) (factors []*big.Int, rest *big.Int) {
+	return factorUntil(n, nil)
+}
+
+// factorUntil is Factor, stopping as soon as enough(factors) holds; the
+// leftover then is whatever was not yet split.
+func factorUntil(n *big.Int, enough func([]*big.Int) bool) ([]*big.Int, *big.Int) {
+	var fs []*big.Int
+	done := func() bool { return enough != nil && enough(fs) }
+	m := new(big.Int).Set(n)
+	q, r := new(big.Int), new(big.Int)
+	for d := int64(2); d < trialBound && m.Cmp(one) > 0; d++ {
+		if d > 2 && d%2 == 0 {
+			continue
+		}
+		bd := big.NewInt(d)
+		if new(big.Int).Mul(bd, bd).Cmp(m) > 0 {
+			break
+		}
+		for {
+			q.QuoRem(m, bd, r)
+			if r.Sign() != 0 {
+				break
+			}
+			fs = append(fs, bd)
+			m.Set(q)
+		}
+		if done() {
+			return sorted(fs), m
+		}
+	}
+	// what is left is 1, a prime, or a product of primes ≥ trialBound
+	var rest []*big.Int
+	pending := []*big.Int{m}
+	for len(pending) > 0 && !done() {
+		x := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		switch {
+		case x.Cmp(one) == 0:
+		case BPSW(x):
+			fs = append(fs, x)
+		default:
+			if d := rho(x); d != nil {
+				pending = append(pending, d, new(big.Int).Quo(x, d))
+			} else {
+				rest = append(rest, x)
+			}
+		}
+	}
+	left := big.NewInt(1)
+	for _, x := range append(rest, pending...) {
+		left.Mul(left, x)
+	}
+	return sorted(fs), left
+}
+
+// rho looks for a nontrivial factor of the odd composite n by Brent's
+// variant of Pollard's rho, over a few polynomials x^2 + c; nil if none
+// turns up within rhoSteps each.
+func rho(n *big.Int) *big.Int {
+	for c := int64(1); c <= 4; c++ {
+		bc := big.NewInt(c)
+		f := func(x *big.Int) *big.Int {
+			x.Mul(x, x).Add(x, bc)
+			return x.Mod(x, n)
+		}
+		y, x, ys := big.NewInt(2), new(big.Int), new(big.Int)
+		g, q, t := big.NewInt(1), big.NewInt(1), new(big.Int)
+		const batch = 128 // gcd once per batch of products
+		steps := 0
+		for r := 1; g.Cmp(one) == 0 && steps < rhoSteps; r *= 2 {
+			x.Set(y)
+			for i := 0; i < r; i++ {
+				f(y)
+			}
+			for k := 0; k < r && g.Cmp(one) == 0; k += batch {
+				ys.Set(y)
+				for i := 0; i < batch && i < r-k; i++ {
+					f(y)
+					t.Sub(x, y)
+					q.Mul(q, t.Abs(t)).Mod(q, n)
+				}
+				g.GCD(nil, nil, q, n)
+				steps += batch
+			}
+		}
+		if g.Cmp(n) == 0 {
+			// the batch overshot: step one at a time from ys
+			for {
+				f(ys)
+				g.GCD(nil, nil, t.Abs(t.Sub(x, ys)), n)
+				if g.Cmp(one) > 0 {
+					break
+				}
+			}
+		}
+		if g.Cmp(one) > 0 && g.Cmp(n) < 0 {
+			return g
+		}
+	}
+	return nil
+}
+
+func sorted(fs []*big.Int) []*big.Int {
+	sort.Slice(fs, func(i, j int) bool { return fs[i].Cmp(fs[j]) < 0 })
+	return fs
+}
@@ -0,0 +1,73 @@
+package prime
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"ectorus/internal/ec"
+)
+
+func TestFactor(t *testing.T) {
+	for _, tc := range []struct {
+		n    string
+		want []string
+	}{
+		{"360", []string{"2", "2", "2", "3", "3", "5"}},
+		{"1000003", []string{"1000003"}},
+		// two primes above the trial bound: rho has to split them
+		{"1000003 * 1000033 * 65537^2", []string{"65537", "65537", "1000003", "1000033"}},
+		{"(2^61-1) * 1000003", []string{"1000003", "2305843009213693951"}},
+	} {
+		n, err := ec.ParseBig(tc.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs, rest := Factor(n)
+		if rest.Cmp(one) != 0 || len(fs) != len(tc.want) {
+			t.Fatalf("%s: %v rest %s", tc.n, fs, rest)
+		}
+		for i, f := range fs {
+			if f.String() != tc.want[i] {
+				t.Fatalf("%s: %v, want %v", tc.n, fs, tc.want)
+			}
+		}
+	}
+}
+
+func TestProve(t *testing.T) {
+	for _, s := range []string{"1000003", "2^61-1", "2^89-1", "2^127-1", "2^130-5"} {
+		n, _ := ec.ParseBig(s)
+		cert, err := Prove(n)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if err := cert.Verify(); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+	// 2^64+13 is prime; lying about a witness breaks the certificate
+	n, _ := ec.ParseBig("2^64+13")
+	cert, err := Prove(n)
+	if err != nil || cert.Method != "pocklington" {
+		t.Fatalf("2^64+13: %+v, %v", cert, err)
+	}
+	cert.Factors[0].Witness = "1"
+	if cert.Verify() == nil {
+		t.Fatal("forged witness verified")
+	}
+
+	// a Carmichael number, and a product of two primes
+	for _, s := range []string{"561", "(2^61-1) * (2^89-1)"} {
+		n, _ := ec.ParseBig(s)
+		if _, err := Prove(n); !errors.Is(err, ErrComposite) {
+			t.Fatalf("%s: %v, want ErrComposite", s, err)
+		}
+	}
+}
+
+func TestBPSW(t *testing.T) {
+	if BPSW(big.NewInt(561)) || !BPSW(big.NewInt(1000003)) {
+		t.Fatal("BPSW misclassified 561 or 1000003")
+	}
+}
@@ -0,0 +1,193 @@
+package prime
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// maxWitness bounds the bases Prove tries for each prime factor of n-1.
+const maxWitness = 1000
+
+// Certificate proves N prime. Below 2^64 BPSW is exact, so the leaf
+// method "bpsw" needs nothing more. Above it, method "pocklington" rests on
+// the Pocklington–Lehmer theorem: if n-1 = F·R with F > √n, and for every
+// prime q | F some a has a^(n-1) ≡ 1 and gcd(a^((n-1)/q) - 1, n) = 1 (mod
+// n), then n is prime. Method "bls" (Brillhart–Lehmer–Selfridge) makes do
+// with F ≥ ∛n: writing n = c2·F^2 + c1·F + 1 with 0 ≤ c1, c2 < F, n is then
+// prime iff c1^2 - 4c2 is not a square. Factors lists the q, each with its
+// witness a and, above 2^64, a certificate of its own.
+type Certificate struct {
+	N       string `json:"n"`
+	Method  string `json:"method"`
+	Factors []Step `json:"factors,omitempty"`
+}
+
+// Step is one prime power q^e of F with its Pocklington witness.
+type Step struct {
+	Q       string       `json:"q"`
+	E       int          `json:"e"`
+	Witness string       `json:"a"`
+	Proof   *Certificate `json:"proof,omitempty"`
+}
+
+// ErrComposite is returned by Prove when a base shows n composite.
+var ErrComposite = errors.New("prime: composite")
+
+// Prove builds a Certificate for n > 1, or fails: with ErrComposite when n
+// is composite, or with an error when too little of n-1 (or of some q-1 in
+// the recursion) factors for either theorem to apply. Factors of n-1 that
+// cannot be proven in turn are left out of F.
+func Prove(n *big.Int) (*Certificate, error) {
+	if n.Cmp(two) < 0 || !BPSW(n) {
+		return nil, fmt.Errorf("%s: %w", n, ErrComposite)
+	}
+	if n.BitLen() <= 64 {
+		return &Certificate{N: n.String(), Method: "bpsw"}, nil
+	}
+	nm1 := new(big.Int).Sub(n, one)
+	// stop factoring once F^2 > n, the simpler of the two theorems
+	fs, _ := factorUntil(nm1, func(fs []*big.Int) bool { return product(fs, 2).Cmp(n) > 0 })
+	var steps []Step
+	var inF []*big.Int
+	for i := 0; i < len(fs); {
+		q := fs[i]
+		e := 0
+		for ; i < len(fs) && fs[i].Cmp(q) == 0; i++ {
+			e++
+		}
+		step := Step{Q: q.String(), E: e}
+		if q.BitLen() > 64 {
+			var err error
+			if step.Proof, err = Prove(q); err != nil {
+				continue
+			}
+		}
+		steps = append(steps, step)
+		for ; e > 0; e-- {
+			inF = append(inF, q)
+		}
+	}
+	cert := &Certificate{N: n.String(), Method: "pocklington", Factors: steps}
+	switch {
+	case product(inF, 2).Cmp(n) > 0:
+	case product(inF, 3).Cmp(n) >= 0:
+		cert.Method = "bls"
+		if !blsHolds(n, product(inF, 1)) {
+			return nil, fmt.Errorf("%s: Brillhart–Lehmer–Selfridge square test: %w", n, ErrComposite)
+		}
+	default:
+		return nil, fmt.Errorf("prime: cannot prove %s: too little of n-1 factors", n)
+	}
+	for i := range cert.Factors {
+		q, _ := new(big.Int).SetString(cert.Factors[i].Q, 10)
+		a, err := witness(n, nm1, q)
+		if err != nil {
+			return nil, err
+		}
+		cert.Factors[i].Witness = a.String()
+	}
+	return cert, nil
+}
+
+// product returns (Π fs)^k.
+func product(fs []*big.Int, k int64) *big.Int {
+	F := big.NewInt(1)
+	for _, q := range fs {
+		F.Mul(F, q)
+	}
+	return F.Exp(F, big.NewInt(k), nil)
+}
+
+// blsHolds is the last step of the F ≥ ∛n theorem: with n = c2·F^2 +
+// c1·F + 1, c1^2 - 4c2 must not be a square.
+func blsHolds(n, F *big.Int) bool {
+	c2, c1 := new(big.Int).QuoRem(new(big.Int).Quo(new(big.Int).Sub(n, one), F), F, new(big.Int))
+	if c2.Cmp(F) >= 0 {
+		return false
+	}
+	d := new(big.Int).Sub(new(big.Int).Mul(c1, c1), new(big.Int).Lsh(c2, 2))
+	if d.Sign() < 0 {
+		return true
+	}
+	r := new(big.Int).Sqrt(d)
+	return r.Mul(r, r).Cmp(d) != 0
+}
+
+// witness finds a base a with a^(n-1) ≡ 1 and gcd(a^((n-1)/q) - 1, n) = 1.
+func witness(n, nm1, q *big.Int) (*big.Int, error) {
+	e := new(big.Int).Quo(nm1, q)
+	g, t := new(big.Int), new(big.Int)
+	for a := int64(2); a < maxWitness; a++ {
+		ba := big.NewInt(a)
+		if t.Exp(ba, nm1, n).Cmp(one) != 0 {
+			return nil, fmt.Errorf("%s: base %d: %w", n, a, ErrComposite)
+		}
+		t.Exp(ba, e, n).Sub(t, one)
+		if g.GCD(nil, nil, t, n).Cmp(one) == 0 {
+			return ba, nil
+		}
+	}
+	return nil, fmt.Errorf("prime: no Pocklington witness for q = %s below %d", q, maxWitness)
+}
+
+// Verify re-checks c from scratch, recursing into the factor proofs.
+func (c *Certificate) Verify() error {
+	n, ok := new(big.Int).SetString(c.N, 10)
+	if !ok || n.Cmp(two) < 0 {
+		return fmt.Errorf("certificate: bad n %q", c.N)
+	}
+	switch c.Method {
+	case "bpsw":
+		if n.BitLen() > 64 || !BPSW(n) {
+			return fmt.Errorf("certificate: bpsw does not prove %s", n)
+		}
+		return nil
+	case "pocklington", "bls":
+	default:
+		return fmt.Errorf("certificate: unknown method %q", c.Method)
+	}
+	nm1 := new(big.Int).Sub(n, one)
+	F := big.NewInt(1)
+	for _, f := range c.Factors {
+		q, ok1 := new(big.Int).SetString(f.Q, 10)
+		a, ok2 := new(big.Int).SetString(f.Witness, 10)
+		if !ok1 || !ok2 || f.E < 1 {
+			return fmt.Errorf("certificate for %s: bad factor %+v", n, f)
+		}
+		qe := new(big.Int).Exp(q, big.NewInt(int64(f.E)), nil)
+		if new(big.Int).Mod(nm1, new(big.Int).Mul(F, qe)).Sign() != 0 {
+			return fmt.Errorf("certificate for %s: %s^%d does not divide n-1", n, q, f.E)
+		}
+		F.Mul(F, qe)
+		switch {
+		case f.Proof != nil:
+			if f.Proof.N != f.Q {
+				return fmt.Errorf("certificate for %s: proof for %s attached to %s", n, f.Proof.N, f.Q)
+			}
+			if err := f.Proof.Verify(); err != nil {
+				return err
+			}
+		case q.BitLen() > 64 || !BPSW(q):
+			return fmt.Errorf("certificate for %s: %s is not shown prime", n, q)
+		}
+		t := new(big.Int).Exp(a, nm1, n)
+		if t.Cmp(one) != 0 {
+			return fmt.Errorf("certificate for %s: a^(n-1) ≠ 1 for a = %s", n, a)
+		}
+		t.Exp(a, new(big.Int).Quo(nm1, q), n).Sub(t, one)
+		if new(big.Int).GCD(nil, nil, t, n).Cmp(one) != 0 {
+			return fmt.Errorf("certificate for %s: a = %s is no witness for q = %s", n, a, q)
+		}
+	}
+	if c.Method == "bls" {
+		if new(big.Int).Exp(F, big.NewInt(3), nil).Cmp(n) < 0 || !blsHolds(n, F) {
+			return fmt.Errorf("certificate for %s: F = %s fails the Brillhart–Lehmer–Selfridge test", n, F)
+		}
+		return nil
+	}
+	if F.Mul(F, F).Cmp(n) <= 0 {
+		return fmt.Errorf("certificate for %s: factored part of n-1 is below √n", n)
+	}
+	return nil
+}
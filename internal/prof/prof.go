@@ -0,0 +1,88 @@
+// Package prof writes the standard Go profiles behind the commands'
+// --cpuprofile, --memprofile and --trace flags, for `go tool pprof` and
+// `go tool trace`.
+package prof
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// Files are the profile paths; empty ones are not written.
+type Files struct {
+	CPU   string // CPU profile, sampled over the whole run
+	Mem   string // heap profile, written when the run ends
+	Trace string // execution trace
+}
+
+// Start creates the files and begins the CPU profile and the trace. The
+// stop it returns ends them and writes the heap profile; it must run
+// before the process exits, or the files are left truncated.
+func Start(f Files) (stop func() error, err error) {
+	var closers []func() error
+	done := func() error {
+		var errs []error
+		for i := len(closers) - 1; i >= 0; i-- {
+			errs = append(errs, closers[i]())
+		}
+		return errors.Join(errs...)
+	}
+	defer func() {
+		if err != nil {
+			done()
+		}
+	}()
+	if f.Mem != "" {
+		w, err := os.Create(f.Mem)
+		if err != nil {
+			return nil, fmt.Errorf("memprofile: %w", err)
+		}
+		closers = append(closers, func() error { return writeHeap(w) })
+	}
+	if f.CPU != "" {
+		w, err := os.Create(f.CPU)
+		if err == nil {
+			if err = pprof.StartCPUProfile(w); err != nil {
+				w.Close()
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cpuprofile: %w", err)
+		}
+		closers = append(closers, func() error {
+			pprof.StopCPUProfile()
+			return w.Close()
+		})
+	}
+	if f.Trace != "" {
+		w, err := os.Create(f.Trace)
+		if err == nil {
+			if err = trace.Start(w); err != nil {
+				w.Close()
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("trace: %w", err)
+		}
+		closers = append(closers, func() error {
+			trace.Stop()
+			return w.Close()
+		})
+	}
+	return done, nil
+}
+
+// writeHeap writes the heap profile to w after a GC, so the in-use figures
+// are up to date.
+func writeHeap(w *os.File) error {
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(w); err != nil {
+		w.Close()
+		return fmt.Errorf("memprofile: %w", err)
+	}
+	return w.Close()
+}
@@ -0,0 +1,574 @@
+// Package ptfile reads the point dumps ecscan writes — text, csv, ndjson or
+// SEC1 compressed, optionally gzip or zstd compressed, with or without a
+// --header record — back into coordinates, so the checking tools need not
+// care how a file was produced.
+package ptfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"ectorus/pkg/encoding"
+)
+
+type Format string
+
+const (
+	FormatText   Format = "text"
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	// FormatCompressed holds one SEC1 compressed point per line in hex;
+	// Next recovers y, given the curve.
+	FormatCompressed Format = "compressed"
+)
+
+// Meta is the curve a dump describes in its header. Fields the header
+// does not carry are nil (Ext 0).
+type Meta struct {
+	P, A, B *big.Int
+	Ext     int
+	Mode    string
+	Version int    // output layout version; 0 before footers were written
+	Shard   string // "i/n", or "i" when n was not known, for one part of a split output
+	// Coords is "projective" or "jacobian" for a dump of (X, Y, Z) triples
+	// (ecscan --coords), "" for affine pairs.
+	Coords string
+}
+
+// Footer is the trailer of a versioned dump: the affine point count and
+// the SHA-256 of every byte before the footer line.
+type Footer struct {
+	Points uint64
+	SHA256 string
+}
+
+// Record is one point of a dump: two coordinates over F_p, four
+// (x0 x1 y0 y1) over F_{p^2}, or the point at infinity: an "inf" record,
+// or before v=2 a sentinel point of -1 or MaxUint64 coordinates. The
+// (X, Y, Z) triples of ecscan --coords projective|jacobian come back affine.
+type Record struct {
+	Line   int
+	Coords []*big.Int
+	Inf    bool
+}
+
+// Key is a 128-bit FNV hash of the coordinates. It stands in for the point
+// in sets over a whole dump, at a fixed 16 bytes per point.
+func (r Record) Key() [16]byte {
+	h := fnv.New128a()
+	if r.Inf {
+		h.Write([]byte("inf"))
+	}
+	for _, z := range r.Coords {
+		b := z.Bytes()
+		h.Write(b)
+		h.Write([]byte{0xff, byte(len(b)), byte(z.Sign() + 1)})
+	}
+	var key [16]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// Reader streams the records of one dump.
+type Reader struct {
+	sc     *bufio.Scanner
+	closer func() error
+	format Format
+	meta   *Meta
+	line   int
+	peeked *string // first data line, read while detecting the format
+
+	p, a, b *big.Int // the curve compressed points are decompressed on
+	triples bool     // points are (X, Y, Z), by a coords= header or an x,y,z column line
+
+	sum    hash.Hash // of the raw lines before the footer
+	footer *Footer
+	after  int // lines with data after the footer
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Open opens path ("-" = stdin) with NewReader.
+func Open(path string) (*Reader, error) {
+	if path == "-" {
+		return NewReader(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	closeInner := r.closer
+	r.closer = func() error {
+		err := closeInner()
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+	return r, nil
+}
+
+// NewReader detects compression from the magic bytes, then reads leading
+// header lines and the first data line to settle the format and Meta.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	var src io.Reader = br
+	closer := func() error { return nil }
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		src, closer = gz, gz.Close
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		src, closer = zr, func() error { zr.Close(); return nil }
+	}
+	sc := bufio.NewScanner(src)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	rd := &Reader{sc: sc, closer: closer, format: FormatText, sum: sha256.New()}
+	if err := rd.readHeader(); err != nil {
+		closer()
+		return nil, err
+	}
+	if m := rd.meta; m != nil && m.P != nil && m.A != nil && m.B != nil {
+		rd.SetCurve(m.P, m.A, m.B)
+	}
+	return rd, nil
+}
+
+// readHeader consumes '#' comment lines (key=value tokens), an ndjson meta
+// object and a csv column line, stopping at the first data line.
+func (r *Reader) readHeader() error {
+	for r.sc.Scan() {
+		r.line++
+		raw := r.sc.Text()
+		s := strings.TrimSpace(raw)
+		if _, ok, _ := parseFooter(s); ok {
+			r.peeked = &raw // a dump with no points; Next sorts it out
+			return nil
+		}
+		switch {
+		case s == "":
+			r.hash(raw)
+			continue
+		case strings.HasPrefix(s, "#"):
+			r.hash(raw)
+			if err := r.headerTokens(strings.Fields(strings.TrimPrefix(s, "#"))); err != nil {
+				return fmt.Errorf("line %d: %w", r.line, err)
+			}
+			continue
+		case strings.HasPrefix(s, "{"):
+			r.format = FormatNDJSON
+			var m struct {
+				Type    string `json:"type"`
+				Version int    `json:"version"`
+				P, A, B string
+				Mode    string `json:"mode"`
+				Ext     int    `json:"ext"`
+				Shard   string `json:"shard"`
+				Coords  string `json:"coords"`
+			}
+			if json.Unmarshal([]byte(s), &m) == nil && m.Type == "meta" {
+				r.hash(raw)
+				if err := r.headerTokens([]string{"v=" + strconv.Itoa(m.Version), "p=" + m.P, "A=" + m.A, "B=" + m.B,
+					"mode=" + m.Mode, "ext=" + strconv.Itoa(m.Ext), "shard=" + m.Shard, "coords=" + m.Coords}); err != nil {
+					return fmt.Errorf("line %d: %w", r.line, err)
+				}
+				continue
+			}
+		case s == "x,y" || s == "x0,x1,y0,y1":
+			r.hash(raw)
+			r.format = FormatCSV
+			continue
+		case s == "x,y,z":
+			r.hash(raw)
+			r.format = FormatCSV
+			r.triples = true
+			continue
+		case strings.Contains(s, ","):
+			r.format = FormatCSV
+		case isSEC1(s):
+			r.format = FormatCompressed
+		}
+		r.peeked = &raw
+		return nil
+	}
+	return r.sc.Err()
+}
+
+func (r *Reader) headerTokens(toks []string) error {
+	for _, t := range toks {
+		k, v, ok := strings.Cut(t, "=")
+		if !ok || v == "" {
+			continue
+		}
+		if r.meta == nil {
+			r.meta = &Meta{}
+		}
+		switch k {
+		case "p", "A", "B":
+			z, ok := new(big.Int).SetString(v, 10)
+			if !ok {
+				return fmt.Errorf("bad %s=%q in header", k, v)
+			}
+			switch k {
+			case "p":
+				r.meta.P = z
+			case "A":
+				r.meta.A = z
+			default:
+				r.meta.B = z
+			}
+		case "ext", "v":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("bad %s=%q in header", k, v)
+			}
+			if k == "v" {
+				r.meta.Version = n
+			} else {
+				r.meta.Ext = n
+			}
+		case "mode":
+			r.meta.Mode = v
+		case "format":
+			if v == string(FormatCompressed) {
+				r.format = FormatCompressed
+			}
+		case "shard":
+			r.meta.Shard = v
+		case "coords":
+			if v != "projective" && v != "jacobian" && v != "affine" {
+				return fmt.Errorf("bad coords=%q in header", v)
+			}
+			r.meta.Coords = v
+			r.triples = v != "affine"
+		}
+	}
+	return nil
+}
+
+// Format is the detected layout of the points.
+func (r *Reader) Format() Format { return r.format }
+
+// Meta is the header's curve, or nil for a dump written without --header.
+func (r *Reader) Meta() *Meta { return r.meta }
+
+// SetCurve sets the curve compressed points are decompressed on, for a
+// dump whose header does not name it.
+func (r *Reader) SetCurve(p, A, B *big.Int) { r.p, r.a, r.b = p, A, B }
+
+// Footer is the dump's footer once Next has returned io.EOF, or nil if it
+// had none.
+func (r *Reader) Footer() *Footer { return r.footer }
+
+// Sum is the hex SHA-256 of the raw lines before the footer (all of them
+// without one), to compare with Footer().SHA256.
+func (r *Reader) Sum() string { return hex.EncodeToString(r.sum.Sum(nil)) }
+
+// DataAfterFooter counts records that followed the footer, which a
+// complete dump never has; Next skips them.
+func (r *Reader) DataAfterFooter() int { return r.after }
+
+// hash adds a raw line to Sum, until the footer has been seen.
+func (r *Reader) hash(raw string) {
+	if r.footer == nil {
+		r.sum.Write([]byte(raw))
+		r.sum.Write([]byte{'\n'})
+	}
+}
+
+// Next returns the next record, or io.EOF after the last one.
+func (r *Reader) Next() (Record, error) {
+	for {
+		var raw string
+		if r.peeked != nil {
+			raw, r.peeked = *r.peeked, nil
+		} else {
+			if !r.sc.Scan() {
+				if err := r.sc.Err(); err != nil {
+					return Record{}, err
+				}
+				return Record{}, io.EOF
+			}
+			r.line++
+			raw = r.sc.Text()
+		}
+		s := strings.TrimSpace(raw)
+		f, isFooter, err := parseFooter(s)
+		if err != nil {
+			return Record{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		switch {
+		case r.footer != nil:
+			if s != "" && !strings.HasPrefix(s, "#") || isFooter {
+				r.after++
+			}
+			continue
+		case isFooter:
+			r.footer = f
+			continue
+		}
+		r.hash(raw)
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		rec, err := r.parse(s)
+		if err != nil {
+			return Record{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		rec.Line = r.line
+		return rec, nil
+	}
+}
+
+func (r *Reader) parse(s string) (Record, error) {
+	var fields []string
+	switch r.format {
+	case FormatNDJSON:
+		rec, z, err := parseNDJSON(s)
+		if err != nil || z == nil {
+			return rec, err
+		}
+		return r.affine(append(rec.Coords, z))
+	case FormatCompressed:
+		return r.parseCompressed(s)
+	case FormatCSV:
+		fields = strings.Split(s, ",")
+	default:
+		fields = strings.Fields(s)
+	}
+	if isInfRecord(fields) {
+		return Record{Inf: true}, nil
+	}
+	if r.triples && len(fields) != 3 {
+		return Record{}, fmt.Errorf("want x y z, got %d coordinates in %q", len(fields), s)
+	}
+	if len(fields) < 2 || len(fields) > 4 {
+		return Record{}, fmt.Errorf("want 2, 3 (x y z) or 4 coordinates, got %d in %q", len(fields), s)
+	}
+	rec := Record{Coords: make([]*big.Int, len(fields))}
+	for i, f := range fields {
+		z, ok := new(big.Int).SetString(strings.TrimSpace(f), 10)
+		if !ok {
+			return Record{}, fmt.Errorf("bad coordinate %q", f)
+		}
+		rec.Coords[i] = z
+	}
+	if len(rec.Coords) == 3 {
+		return r.affine(rec.Coords)
+	}
+	rec.Inf = isSentinel(rec.Coords)
+	if rec.Inf {
+		rec.Coords = nil
+	}
+	return rec, nil
+}
+
+// isSEC1 recognises a compressed point line: "00", or "02"/"03" and x.
+func isSEC1(s string) bool {
+	if s == "00" {
+		return true
+	}
+	if len(s) < 4 || len(s)%2 != 0 || s[0] != '0' || s[1] != '2' && s[1] != '3' {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func (r *Reader) parseCompressed(s string) (Record, error) {
+	if !isSEC1(s) {
+		return Record{}, fmt.Errorf("bad compressed point %q", s)
+	}
+	if s == "00" {
+		return Record{Inf: true}, nil
+	}
+	if r.p == nil {
+		return Record{}, errors.New("compressed points need the curve: a --header record, or SetCurve")
+	}
+	raw, _ := hex.DecodeString(s)
+	x, y, err := encoding.Unmarshal(r.p, r.a, r.b, raw)
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{Coords: []*big.Int{x, y}}, nil
+}
+
+// isInfRecord recognises O in text ("inf") and csv ("inf,inf", one per
+// column), as ecscan writes it from v=2.
+func isInfRecord(fields []string) bool {
+	for _, f := range fields {
+		if strings.TrimSpace(f) != "inf" {
+			return false
+		}
+	}
+	return true
+}
+
+// maxU64 is the v=1 sentinel coordinate of the uint64 enumerators; the
+// big.Int path wrote -1 instead.
+var maxU64 = new(big.Int).SetUint64(^uint64(0))
+
+func isSentinel(cs []*big.Int) bool {
+	for _, want := range []*big.Int{maxU64, big.NewInt(-1)} {
+		all := true
+		for _, c := range cs {
+			all = all && c.Cmp(want) == 0
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// affine takes a projective or Jacobian (X, Y, Z) to (x, y): O for Z = 0,
+// and Z = 1, which is all ecscan writes, as it stands. Any other Z needs
+// the curve's p and the header's coords.
+func (r *Reader) affine(cs []*big.Int) (Record, error) {
+	X, Y, Z := cs[0], cs[1], cs[2]
+	switch {
+	case Z.Sign() == 0:
+		return Record{Inf: true}, nil
+	case Z.Cmp(big.NewInt(1)) == 0:
+		return Record{Coords: []*big.Int{X, Y}}, nil
+	case r.p == nil || r.meta == nil || r.meta.Coords == "":
+		return Record{}, fmt.Errorf("Z = %s needs the curve and coords= from a header", Z)
+	}
+	zi := new(big.Int).ModInverse(Z, r.p)
+	if zi == nil {
+		return Record{}, fmt.Errorf("Z = %s is not invertible mod %s", Z, r.p)
+	}
+	zx, zy := zi, zi // projective: x = X/Z, y = Y/Z
+	if r.meta.Coords == "jacobian" {
+		zx = new(big.Int).Mul(zi, zi)
+		zy = new(big.Int).Mul(zx, zi)
+	}
+	x := new(big.Int).Mul(X, zx)
+	y := new(big.Int).Mul(Y, zy)
+	return Record{Coords: []*big.Int{x.Mod(x, r.p), y.Mod(y, r.p)}}, nil
+}
+
+// parseNDJSON also returns the "z" of a triple, nil for a pair.
+func parseNDJSON(s string) (Record, *big.Int, error) {
+	var obj struct {
+		X, Y, Z json.RawMessage
+		Inf     bool `json:"inf"`
+	}
+	if err := json.Unmarshal([]byte(s), &obj); err != nil {
+		return Record{}, nil, err
+	}
+	if obj.Inf {
+		return Record{Inf: true}, nil, nil
+	}
+	if obj.X == nil || obj.Y == nil {
+		return Record{}, nil, errors.New(`want {"x":..,"y":..} or {"inf":true}`)
+	}
+	var rec Record
+	for _, raw := range []json.RawMessage{obj.X, obj.Y} {
+		cs, err := jsonCoords(raw)
+		if err != nil {
+			return Record{}, nil, err
+		}
+		rec.Coords = append(rec.Coords, cs...)
+	}
+	if len(rec.Coords) != 2 && len(rec.Coords) != 4 {
+		return Record{}, nil, fmt.Errorf("mixed coordinate shapes in %q", s)
+	}
+	if obj.Z == nil {
+		return rec, nil, nil
+	}
+	z, err := jsonCoords(obj.Z)
+	if err != nil || len(z) != 1 || len(rec.Coords) != 2 {
+		return Record{}, nil, fmt.Errorf("bad z in %q", s)
+	}
+	return rec, z[0], nil
+}
+
+// jsonCoords reads a bare integer or an [real, imag] pair without going
+// through float64.
+func jsonCoords(raw json.RawMessage) ([]*big.Int, error) {
+	var parts []json.Number
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return nil, fmt.Errorf("bad coordinate %s", raw)
+		}
+		parts = []json.Number{n}
+	}
+	out := make([]*big.Int, len(parts))
+	for i, n := range parts {
+		z, ok := new(big.Int).SetString(n.String(), 10)
+		if !ok {
+			return nil, fmt.Errorf("bad coordinate %s", n)
+		}
+		out[i] = z
+	}
+	return out, nil
+}
+
+// parseFooter recognises "# end points=N sha256=HEX" and its ndjson form
+// {"type":"end","points":N,"sha256":"HEX"}.
+func parseFooter(s string) (*Footer, bool, error) {
+	switch {
+	case strings.HasPrefix(s, "# end "):
+		f := &Footer{}
+		for _, t := range strings.Fields(s[len("# end "):]) {
+			k, v, _ := strings.Cut(t, "=")
+			switch k {
+			case "points":
+				n, err := strconv.ParseUint(v, 10, 64)
+				if err != nil {
+					return nil, true, fmt.Errorf("bad footer %q", s)
+				}
+				f.Points = n
+			case "sha256":
+				f.SHA256 = v
+			}
+		}
+		return f, true, nil
+	case strings.HasPrefix(s, `{"type":"end"`):
+		var e struct {
+			Points uint64 `json:"points"`
+			SHA256 string `json:"sha256"`
+		}
+		if err := json.Unmarshal([]byte(s), &e); err != nil {
+			return nil, true, fmt.Errorf("bad footer: %w", err)
+		}
+		return &Footer{Points: e.Points, SHA256: e.SHA256}, true, nil
+	}
+	return nil, false, nil
+}
+
+// Close releases the decompressor and, for Open, the file.
+func (r *Reader) Close() error { return r.closer() }
@@ -0,0 +1,177 @@
+package ptfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, r io.Reader) (*Reader, []Record) {
+	t.Helper()
+	rd, err := NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recs []Record
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	return rd, recs
+}
+
+func TestFormatsAndHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name, in string
+		format   Format
+		ext      int
+		coords   int
+	}{
+		{"text", "# ecscan p=101 A=2 B=3 mode=table timestamp=x\n1 41\n1 60\n18446744073709551615 18446744073709551615\n", FormatText, 0, 2},
+		{"csv", "# p=101\n# A=2\n# B=3\n# mode=table\nx,y\n1,41\n1,60\n-1,-1\n", FormatCSV, 0, 2},
+		{"text v2", "# ecscan v=2 p=101 A=2 B=3 mode=table timestamp=x\n1 41\n1 60\ninf\n", FormatText, 0, 2},
+		{"csv v2", "# ecscan v=2\n# p=101\n# A=2\n# B=3\n# ext=2\nx0,x1,y0,y1\n1,0,41,0\n1,5,7,9\ninf,inf,inf,inf\n", FormatCSV, 2, 4},
+		{"ndjson", `{"type":"meta","p":"101","A":"2","B":"3","mode":"onthefly","ext":2,"timestamp":"x"}` + "\n" +
+			`{"x":[1,0],"y":[41,0]}` + "\n" + `{"x":[1,5],"y":[7,9]}` + "\n" + `{"inf":true}` + "\n", FormatNDJSON, 2, 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rd, recs := readAll(t, strings.NewReader(tc.in))
+			if rd.Format() != tc.format {
+				t.Fatalf("format %s, want %s", rd.Format(), tc.format)
+			}
+			m := rd.Meta()
+			if m == nil || m.P.Int64() != 101 || m.A.Int64() != 2 || m.B.Int64() != 3 || m.Ext != tc.ext {
+				t.Fatalf("meta %+v", m)
+			}
+			if len(recs) != 3 || !recs[2].Inf || recs[0].Inf || len(recs[0].Coords) != tc.coords {
+				t.Fatalf("records %+v", recs)
+			}
+			if recs[0].Coords[0].Int64() != 1 || recs[0].Line < 2 {
+				t.Fatalf("first record %+v", recs[0])
+			}
+		})
+	}
+}
+
+func TestTriples(t *testing.T) {
+	// y^2 = x^3 + 2x + 3 over F_101: (1, 39) and (3, 6)
+	for _, tc := range []struct{ name, in string }{
+		{"text", "# ecscan v=1 p=101 A=2 B=3 mode=table coords=projective timestamp=x\n1 39 1\n6 12 2\n0 1 0\n"},
+		{"csv", "# p=101\n# A=2\n# B=3\n# coords=jacobian\nx,y,z\n1,39,1\n12,48,2\n1,1,0\n"},
+		{"ndjson", `{"type":"meta","p":"101","A":"2","B":"3","mode":"table","coords":"projective","timestamp":"x"}` + "\n" +
+			`{"x":1,"y":39,"z":1}` + "\n" + `{"x":6,"y":12,"z":2}` + "\n" + `{"x":0,"y":1,"z":0}` + "\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rd, recs := readAll(t, strings.NewReader(tc.in))
+			if m := rd.Meta(); m == nil || m.Coords == "" || len(recs) != 3 || !recs[2].Inf {
+				t.Fatalf("meta %+v, records %+v", m, recs)
+			}
+			for i, want := range [][2]int64{{1, 39}, {3, 6}} {
+				if c := recs[i].Coords; len(c) != 2 || c[0].Int64() != want[0] || c[1].Int64() != want[1] {
+					t.Fatalf("record %d = %v, want %v", i, c, want)
+				}
+			}
+		})
+	}
+
+	// Z other than 0 or 1 needs the header
+	rd, err := NewReader(strings.NewReader("1 39 1\n6 12 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec, err := rd.Next(); err != nil || rec.Coords[1].Int64() != 39 {
+		t.Fatalf("1 39 1 → %+v, %v", rec, err)
+	}
+	if _, err := rd.Next(); err == nil {
+		t.Fatal("Z = 2 accepted without a header")
+	}
+}
+
+func TestGzipNoHeader(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	io.WriteString(zw, "3 4\n5 6\n")
+	zw.Close()
+	rd, recs := readAll(t, &buf)
+	if rd.Meta() != nil || rd.Format() != FormatText || len(recs) != 2 || recs[1].Coords[1].Int64() != 6 {
+		t.Fatalf("meta %+v, records %+v", rd.Meta(), recs)
+	}
+}
+
+func TestBadLine(t *testing.T) {
+	rd, err := NewReader(strings.NewReader("1 2\n1 2 3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rd.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rd.Next(); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("want a line 2 error, got %v", err)
+	}
+}
+
+func TestFooterChecksum(t *testing.T) {
+	body := "# ecscan v=1 p=101 A=2 B=3 mode=table shard=2/3 timestamp=x\n3 6\n18446744073709551615 18446744073709551615\n"
+	sum := sha256.Sum256([]byte(body))
+	in := body + "# end points=1 sha256=" + hex.EncodeToString(sum[:]) + "\n"
+	rd, recs := readAll(t, strings.NewReader(in))
+	if m := rd.Meta(); m.Version != 1 || m.Shard != "2/3" {
+		t.Fatalf("meta %+v", m)
+	}
+	f := rd.Footer()
+	if len(recs) != 2 || f == nil || f.Points != 1 || f.SHA256 != rd.Sum() || rd.DataAfterFooter() != 0 {
+		t.Fatalf("footer %+v, sum %s, records %d", f, rd.Sum(), len(recs))
+	}
+
+	// ndjson, with a record appended after the footer
+	body = `{"type":"meta","version":1,"p":"101","A":"2","B":"3","mode":"table","timestamp":"x"}` + "\n" + `{"inf":true}` + "\n"
+	sum = sha256.Sum256([]byte(body))
+	in = body + `{"type":"end","points":0,"sha256":"` + hex.EncodeToString(sum[:]) + `"}` + "\n" + `{"x":3,"y":6}` + "\n"
+	rd, recs = readAll(t, strings.NewReader(in))
+	if f := rd.Footer(); len(recs) != 1 || f == nil || f.SHA256 != rd.Sum() || rd.DataAfterFooter() != 1 {
+		t.Fatalf("ndjson footer %+v, sum %s, after %d", f, rd.Sum(), rd.DataAfterFooter())
+	}
+}
+
+func TestCompressed(t *testing.T) {
+	// y^2 = x^3 + 2x + 3 over F_101: (1, 39) and (1, 62), (3, 6)
+	in := "# ecscan v=1 format=compressed p=101 A=2 B=3 mode=table timestamp=x\n0301\n0201\n0203\n00\n"
+	rd, recs := readAll(t, strings.NewReader(in))
+	if rd.Format() != FormatCompressed || len(recs) != 4 || !recs[3].Inf {
+		t.Fatalf("format %s, records %+v", rd.Format(), recs)
+	}
+	for i, want := range [][2]int64{{1, 39}, {1, 62}, {3, 6}} {
+		if c := recs[i].Coords; c[0].Int64() != want[0] || c[1].Int64() != want[1] {
+			t.Fatalf("record %d = %v, want %v", i, c, want)
+		}
+	}
+
+	// without a header the reader needs the curve
+	rd, err := NewReader(strings.NewReader("0203\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rd.Next(); err == nil {
+		t.Fatal("decompressed without a curve")
+	}
+	rd, _ = NewReader(strings.NewReader("0303\n0202\n"))
+	rd.SetCurve(big.NewInt(101), big.NewInt(2), big.NewInt(3))
+	if rec, err := rd.Next(); err != nil || rec.Coords[1].Int64() != 95 {
+		t.Fatalf("0303 → %+v, %v", rec, err)
+	}
+	if _, err := rd.Next(); err == nil { // x = 2: 15 is not a square mod 101
+		t.Fatal("x with no point accepted")
+	}
+}
@@ -0,0 +1,161 @@
+package serve
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ------------------- REST API -------------------
+
+// Status is a job's state, as GET /jobs/{id} reports it.
+type Status struct {
+	ID             int     `json:"id"`
+	Job            JobSpec `json:"job"`
+	State          string  `json:"state"` // running|done|failed|cancelled
+	Seconds        float64 `json:"seconds"`
+	LinesProcessed int     `json:"linesProcessed,omitempty"`
+	PointsFound    int     `json:"pointsFound"` // affine
+	PointCount     string  `json:"pointCount,omitempty"`
+	Complete       bool    `json:"complete"`
+	Truncated      bool    `json:"truncated,omitempty"`
+	Error          string  `json:"error,omitempty"`
+	StatusURL      string  `json:"statusUrl"`
+	ResultURL      string  `json:"resultUrl"`
+}
+
+// Result is a finished job's output, from GET /jobs/{id}/result. A job
+// stopped by --max-seconds has a partial result, marked truncated.
+type Result struct {
+	ID             int             `json:"id"`
+	Job            JobSpec         `json:"job"`
+	PointCount     string          `json:"pointCount,omitempty"`
+	Complete       bool            `json:"complete"`
+	Truncated      bool            `json:"truncated,omitempty"`
+	LinesProcessed int             `json:"linesProcessed,omitempty"`
+	Found          [][2]uint64     `json:"found"`             // affine points, in the order found
+	Ectorus        json.RawMessage `json:"ectorus,omitempty"` // a /torus job: ectorus's own -json result
+}
+
+func (j *job) status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := Status{
+		ID: j.id, Job: j.spec, State: "running",
+		LinesProcessed: j.pending.LinesProcessed, PointsFound: j.pending.PointsFound,
+		StatusURL: fmt.Sprintf("/jobs/%d", j.id), ResultURL: fmt.Sprintf("/jobs/%d/result", j.id),
+	}
+	if f := j.final; f != nil {
+		st.Seconds = f.T
+		st.PointCount, st.Complete, st.Truncated, st.Error = f.PointCount, f.Complete, f.Truncated, f.Error
+		switch f.Error {
+		case "":
+			st.State = "done"
+		case "cancelled":
+			st.State = "cancelled"
+		default:
+			st.State = "failed"
+		}
+	} else {
+		st.Seconds = j.seconds()
+	}
+	return st
+}
+
+// submit returns the handler for POST /scan (tool ecscan) or POST /torus
+// (tool ectorus). The body is a JSON object with p, A and B, and for
+// /torus optionally grid; the answer is 202 with the job's Status.
+func (s *server) submit(tool string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var spec JobSpec
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&spec); err != nil {
+			httpError(w, http.StatusBadRequest, "bad job: "+err.Error())
+			return
+		}
+		if spec.Tool != "" && spec.Tool != tool {
+			httpError(w, http.StatusBadRequest, fmt.Sprintf("tool %q does not match %s", spec.Tool, r.URL.Path))
+			return
+		}
+		spec.Tool = tool
+		j, err := s.launch(spec)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/jobs/%d", j.id))
+		writeJSON(w, http.StatusAccepted, j.status())
+	}
+}
+
+func (s *server) list(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	jobs := append([]*job(nil), s.jobs...)
+	s.mu.Unlock()
+	sts := make([]Status, len(jobs))
+	for i, j := range jobs {
+		sts[i] = j.status()
+	}
+	writeJSON(w, http.StatusOK, sts)
+}
+
+func (s *server) statusOf(w http.ResponseWriter, r *http.Request) {
+	if j := s.job(w, r); j != nil {
+		writeJSON(w, http.StatusOK, j.status())
+	}
+}
+
+// result serves a finished job's Result as JSON, or with ?format=csv its
+// points as x,y rows. A job still running, failed or cancelled answers 409
+// with its Status.
+func (s *server) result(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q (want json|csv)", format))
+		return
+	}
+	if st := j.status(); st.State != "done" {
+		writeJSON(w, http.StatusConflict, st)
+		return
+	}
+	j.mu.Lock()
+	f := j.final
+	res := Result{
+		ID: j.id, Job: j.spec, PointCount: f.PointCount, Complete: f.Complete, Truncated: f.Truncated,
+		LinesProcessed: f.LinesProcessed, Found: j.points, Ectorus: j.ectorus,
+	}
+	j.mu.Unlock()
+	if res.Found == nil {
+		res.Found = [][2]uint64{}
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=job-%d.csv", j.id))
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"x", "y"})
+		for _, pt := range res.Found {
+			cw.Write([]string{strconv.FormatUint(pt[0], 10), strconv.FormatUint(pt[1], 10)})
+		}
+		cw.Flush()
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=job-%d.json", j.id))
+	writeJSON(w, http.StatusOK, res)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func httpError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,44 @@
+package serve
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+type Config struct {
+	Addr       string  // --addr: listen address, e.g. :8080
+	Ectorus    string  // --ectorus: the binary that runs ectorus jobs
+	MaxP       uint64  // --max-p: largest p a job may ask for
+	MaxSeconds float64 // --max-seconds: per-job wall-time budget (0 = none)
+}
+
+func ParseFlags(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("asteroids serve", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: asteroids serve [flags]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		addr       = fs.String("addr", ":8080", "listen address for the dashboard")
+		ectorus    = fs.String("ectorus", "./bin/ectorus", "path to the ectorus binary that runs ectorus jobs")
+		maxP       = fs.Uint64("max-p", 1024, "largest p a job may ask for (the page keeps the whole p×p torus and draws each line across it)")
+		maxSeconds = fs.Float64("max-seconds", 120, "stop each job after this many seconds, with a truncated result (0 = no limit)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 0 {
+		return nil, fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	if *maxP <= 3 || *maxP > 1<<16 {
+		return nil, errors.New("--max-p must be in (3, 65536]")
+	}
+	if *maxSeconds < 0 {
+		return nil, fmt.Errorf("bad --max-seconds %v", *maxSeconds)
+	}
+	return &Config{Addr: *addr, Ectorus: *ectorus, MaxP: *maxP, MaxSeconds: *maxSeconds}, nil
+}
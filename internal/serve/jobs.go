@@ -0,0 +1,360 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ectorus/internal/ecscan"
+)
+
+// JobSpec is what the page asks to run: a curve y^2 = x^3 + Ax + B over
+// F_p, walked by ectorus or enumerated by ecscan.
+type JobSpec struct {
+	Tool string `json:"tool"` // ectorus|ecscan
+	P    uint64 `json:"p"`
+	A    uint64 `json:"A"`
+	B    uint64 `json:"B"`
+	Grid bool   `json:"grid,omitempty"` // ectorus -grid
+}
+
+func (s JobSpec) check(maxP uint64) (JobSpec, error) {
+	if s.Tool != "ectorus" && s.Tool != "ecscan" {
+		return s, fmt.Errorf("unknown tool %q (want ectorus|ecscan)", s.Tool)
+	}
+	if s.P <= 3 || s.P > maxP || !new(big.Int).SetUint64(s.P).ProbablyPrime(32) {
+		return s, fmt.Errorf("p = %d is not a prime in (3, %d]", s.P, maxP)
+	}
+	s.A, s.B = s.A%s.P, s.B%s.P
+	// 4A^3 + 27B^2 ≠ 0 mod p
+	a, b, p := new(big.Int).SetUint64(s.A), new(big.Int).SetUint64(s.B), new(big.Int).SetUint64(s.P)
+	d := new(big.Int).Mul(big.NewInt(4), new(big.Int).Exp(a, big.NewInt(3), nil))
+	d.Add(d, new(big.Int).Mul(big.NewInt(27), new(big.Int).Mul(b, b)))
+	if d.Mod(d, p).Sign() == 0 {
+		return s, fmt.Errorf("y^2 = x^3 + %dx + %d is singular mod %d", s.A, s.B, s.P)
+	}
+	return s, nil
+}
+
+// message is one WebSocket message to the page. A job's stream is a "job"
+// message, "update"s and a final "done"; updates only carry what is new
+// since the previous one, plus running totals.
+type message struct {
+	Type string   `json:"type"` // job|update|done
+	Job  *JobSpec `json:"job,omitempty"`
+	ID   int      `json:"id,omitempty"`
+
+	T              float64     `json:"t"` // seconds since the job started
+	LinesProcessed int         `json:"linesProcessed"`
+	PointsFound    int         `json:"pointsFound"`          // affine
+	Found          [][2]uint64 `json:"found,omitempty"`      // new points
+	Lines          [][2]uint64 `json:"lines,omitempty"`      // new lines y = m x + c, as [m, c]
+	Verticals      []uint64    `json:"verticals,omitempty"`  // new vertical lines x = const
+	PointCount     string      `json:"pointCount,omitempty"` // done: #E(F_p), O included, when known
+	Complete       bool        `json:"complete,omitempty"`   // done
+	Truncated      bool        `json:"truncated,omitempty"`  // done: --max-seconds ran out
+	Error          string      `json:"error,omitempty"`      // done
+}
+
+// updateEvery is how often a running job's updates go out, so a fast walk
+// sends a few large messages rather than one per point.
+const updateEvery = 100 * time.Millisecond
+
+// job is one run and everything it has sent, so a page that connects late
+// (or reloads) replays it from the start.
+type job struct {
+	id     int
+	spec   JobSpec
+	start  time.Time
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	msgs [][]byte
+	done bool
+	wake chan struct{} // closed and replaced by every publish
+
+	pending message // the update being gathered
+	dirty   bool
+
+	points  [][2]uint64     // every point found so far
+	final   *message        // the done message, once there is one
+	ectorus json.RawMessage // an ectorus job's own JSON result
+}
+
+func newJob(id int, spec JobSpec, cancel context.CancelFunc) *job {
+	j := &job{id: id, spec: spec, start: time.Now(), cancel: cancel, wake: make(chan struct{})}
+	j.publish(message{Type: "job", ID: id, Job: &spec}, false)
+	return j
+}
+
+func (j *job) publish(m message, last bool) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		panic(err) // message has nothing json can reject
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.msgs = append(j.msgs, b)
+	if last {
+		j.done, j.final = true, &m
+	}
+	close(j.wake)
+	j.wake = make(chan struct{})
+}
+
+// since returns the messages from the i-th on, whether the job is over,
+// and a channel that is closed when there is more.
+func (j *job) since(i int) ([][]byte, bool, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.msgs[i:], j.done, j.wake
+}
+
+// add changes the pending update under the lock.
+func (j *job) add(fn func(u *message)) {
+	j.mu.Lock()
+	fn(&j.pending)
+	j.dirty = true
+	j.mu.Unlock()
+}
+
+func (j *job) seconds() float64 { return time.Since(j.start).Seconds() }
+
+// flush publishes the pending update, if anything changed.
+func (j *job) flush() {
+	j.mu.Lock()
+	u, dirty := j.pending, j.dirty
+	j.points = append(j.points, u.Found...)
+	j.pending.Found, j.pending.Lines, j.pending.Verticals = nil, nil, nil
+	j.dirty = false
+	j.mu.Unlock()
+	if dirty {
+		u.Type = "update"
+		u.T = j.seconds()
+		j.publish(u, false)
+	}
+}
+
+// run runs the job to its end, sending updates every updateEvery and then
+// the done message.
+func (j *job) run(ctx context.Context, cfg *Config) {
+	defer j.cancel()
+	stop := make(chan struct{})
+	ticked := make(chan struct{})
+	go func() {
+		defer close(ticked)
+		t := time.NewTicker(updateEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				j.flush()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	var done message
+	var err error
+	if j.spec.Tool == "ectorus" {
+		done, err = j.runEctorus(ctx, cfg)
+	} else {
+		done, err = j.runEcscan(ctx, cfg)
+	}
+	close(stop)
+	<-ticked
+	j.flush()
+	done.Type = "done"
+	done.T = j.seconds()
+	j.mu.Lock()
+	done.LinesProcessed, done.PointsFound = j.pending.LinesProcessed, j.pending.PointsFound
+	j.mu.Unlock()
+	if errors.Is(ctx.Err(), context.Canceled) {
+		err = errors.New("cancelled")
+	}
+	if err != nil {
+		done.Error = err.Error()
+	}
+	j.publish(done, true)
+}
+
+func (j *job) runEcscan(ctx context.Context, cfg *Config) (message, error) {
+	s := j.spec
+	if cfg.MaxSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.MaxSeconds*float64(time.Second)))
+		defer cancel()
+	}
+	n := 0
+	err := ecscan.Scan(ctx, ecscan.Params{P: s.P, A: s.A, B: s.B}, func(x, y uint64) error {
+		n++
+		j.add(func(u *message) {
+			u.Found = append(u.Found, [2]uint64{x, y})
+			u.PointsFound = n
+		})
+		return nil
+	})
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return message{Truncated: true}, nil
+	case err != nil:
+		return message{}, err
+	}
+	return message{Complete: true, PointCount: strconv.Itoa(n + 1)}, nil
+}
+
+// ectorusEvent is the part of an ectorus -events record the view uses.
+type ectorusEvent struct {
+	Event string `json:"event"`
+	Lines int    `json:"lines"`
+	Found int    `json:"found"`
+	Point *struct {
+		X   string `json:"x"`
+		Y   string `json:"y"`
+		Inf bool   `json:"inf"`
+	} `json:"point"`
+	M string `json:"m"`
+	C string `json:"c"`
+	X string `json:"x"`
+}
+
+// runEctorus walks the curve with the ectorus binary, reading its -events
+// stream from stdout and its JSON result from a file once it exits.
+func (j *job) runEctorus(ctx context.Context, cfg *Config) (message, error) {
+	s := j.spec
+	dir, err := os.MkdirTemp("", "asteroids-job")
+	if err != nil {
+		return message{}, err
+	}
+	defer os.RemoveAll(dir)
+	resPath := filepath.Join(dir, "result.json")
+	args := []string{
+		"-p", strconv.FormatUint(s.P, 10), "-A", strconv.FormatUint(s.A, 10), "-B", strconv.FormatUint(s.B, 10),
+		"-count_first", "-events", "-", "-format", "json", "-out", resPath,
+	}
+	if s.Grid {
+		args = append(args, "-grid")
+	}
+	if cfg.MaxSeconds > 0 {
+		args = append(args, "-max_seconds", strconv.FormatFloat(cfg.MaxSeconds, 'g', -1, 64))
+	}
+	cmd := exec.CommandContext(ctx, cfg.Ectorus, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return message{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return message{}, err
+	}
+	dec := json.NewDecoder(stdout)
+	var decErr error
+	for {
+		var ev ectorusEvent
+		if decErr = dec.Decode(&ev); decErr != nil {
+			break
+		}
+		if decErr = j.ectorusEvent(ev); decErr != nil {
+			break
+		}
+	}
+	if decErr == io.EOF {
+		decErr = nil
+	}
+	if decErr != nil {
+		cmd.Process.Kill()
+	}
+	if err := cmd.Wait(); err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return message{}, err
+	}
+	if decErr != nil {
+		return message{}, fmt.Errorf("reading ectorus events: %w", decErr)
+	}
+	b, err := os.ReadFile(resPath)
+	if err != nil {
+		return message{}, err
+	}
+	var out struct {
+		PointCount string `json:"pointCount"`
+		Complete   bool   `json:"complete"`
+		Truncated  bool   `json:"truncated"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return message{}, fmt.Errorf("ectorus result: %w", err)
+	}
+	j.mu.Lock()
+	j.ectorus = b
+	j.mu.Unlock()
+	return message{PointCount: out.PointCount, Complete: out.Complete, Truncated: out.Truncated}, nil
+}
+
+// ectorusEvent folds one -events record into the pending update.
+func (j *job) ectorusEvent(ev ectorusEvent) error {
+	var pt *[2]uint64
+	var line *[2]uint64
+	vertical := -1
+	var err error
+	switch ev.Event {
+	case "point":
+		if ev.Point == nil {
+			return errors.New("point event without a point")
+		}
+		if !ev.Point.Inf {
+			var x, y uint64
+			if x, err = strconv.ParseUint(ev.Point.X, 10, 64); err == nil {
+				y, err = strconv.ParseUint(ev.Point.Y, 10, 64)
+			}
+			pt = &[2]uint64{x, y}
+		}
+	case "line":
+		if ev.X != "" {
+			var x uint64
+			x, err = strconv.ParseUint(ev.X, 10, 64)
+			vertical = int(x)
+		} else {
+			var m, c uint64
+			if m, err = strconv.ParseUint(ev.M, 10, 64); err == nil {
+				c, err = strconv.ParseUint(ev.C, 10, 64)
+			}
+			line = &[2]uint64{m, c}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s event: %w", ev.Event, err)
+	}
+	j.add(func(u *message) {
+		u.LinesProcessed, u.PointsFound = ev.Lines, ev.Found
+		if pt != nil {
+			u.Found = append(u.Found, *pt)
+		}
+		if line != nil {
+			u.Lines = append(u.Lines, *line)
+		}
+		if vertical >= 0 {
+			u.Verticals = append(u.Verticals, uint64(vertical))
+		}
+	})
+	return nil
+}
+
+// lastLine is the last non-empty line of s, which for a failed ectorus is
+// its error.
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	return s[strings.LastIndexByte(s, '\n')+1:]
+}
@@ -0,0 +1,167 @@
+// Package serve is the live dashboard behind `asteroids serve`: it runs
+// ectorus walks and ecscan scans as jobs and pushes what they find (and,
+// for a walk, the lines that exclude the rest of the torus) to a page
+// that draws the p×p grid as it fills in. The same jobs can be submitted,
+// polled and downloaded through a small REST API.
+package serve
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+type server struct {
+	cfg  *Config
+	ctx  context.Context // cancelled on shutdown, taking the jobs with it
+	mu   sync.Mutex
+	jobs []*job // job i+1 is jobs[i]
+}
+
+// Handler returns the dashboard's routes and the REST API:
+//
+//	GET  /                 the page
+//	POST /jobs             start a job from a JSON JobSpec; answers {"id": n}
+//	GET  /jobs/{id}/ws     the job's messages over a WebSocket, from its start
+//	POST /jobs/{id}/cancel stop a running job
+//	POST /scan, /torus     start an ecscan or ectorus job from {"p", "A", "B"}; answers 202 and its Status
+//	GET  /jobs             every job's Status
+//	GET  /jobs/{id}        one job's Status
+//	GET  /jobs/{id}/result its Result once done (?format=csv: the points as x,y rows)
+//
+// Jobs run until they end or ctx is done.
+func Handler(ctx context.Context, cfg *Config) http.Handler {
+	s := &server{cfg: cfg, ctx: ctx}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(indexHTML)
+	})
+	mux.HandleFunc("POST /jobs", s.start)
+	mux.HandleFunc("GET /jobs/{id}/ws", s.watch)
+	mux.HandleFunc("POST /jobs/{id}/cancel", s.cancel)
+	mux.HandleFunc("POST /scan", s.submit("ecscan"))
+	mux.HandleFunc("POST /torus", s.submit("ectorus"))
+	mux.HandleFunc("GET /jobs", s.list)
+	mux.HandleFunc("GET /jobs/{id}", s.statusOf)
+	mux.HandleFunc("GET /jobs/{id}/result", s.result)
+	return mux
+}
+
+// start is POST /jobs, which the page uses: a JobSpec with its tool.
+func (s *server) start(w http.ResponseWriter, r *http.Request) {
+	var spec JobSpec
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		httpError(w, http.StatusBadRequest, "bad job: "+err.Error())
+		return
+	}
+	j, err := s.launch(spec)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]int{"id": j.id})
+}
+
+// launch checks spec and starts it as a new job.
+func (s *server) launch(spec JobSpec) (*job, error) {
+	spec, err := spec.check(s.cfg.MaxP)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.mu.Lock()
+	j := newJob(len(s.jobs)+1, spec, cancel)
+	s.jobs = append(s.jobs, j)
+	s.mu.Unlock()
+	go j.run(ctx, s.cfg)
+	log.Printf("job %d: %s p=%d A=%d B=%d", j.id, spec.Tool, spec.P, spec.A, spec.B)
+	return j, nil
+}
+
+func (s *server) job(w http.ResponseWriter, r *http.Request) *job {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil || id < 1 || id > len(s.jobs) {
+		httpError(w, http.StatusNotFound, "no such job")
+		return nil
+	}
+	return s.jobs[id-1]
+}
+
+// watch replays a job's messages over a WebSocket and follows it until it
+// is done (or the page goes away).
+func (s *server) watch(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	c, err := upgrade(w, r)
+	if err != nil {
+		log.Printf("job %d: %v", j.id, err)
+		return
+	}
+	defer c.close()
+	for i := 0; ; {
+		msgs, done, more := j.since(i)
+		for _, m := range msgs {
+			if err := c.writeText(m); err != nil {
+				return
+			}
+		}
+		i += len(msgs)
+		if done {
+			return
+		}
+		select {
+		case <-more:
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (s *server) cancel(w http.ResponseWriter, r *http.Request) {
+	if j := s.job(w, r); j != nil {
+		j.cancel()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Run serves the dashboard on cfg.Addr until interrupted.
+func Run(cfg *Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("--addr: %w", err)
+	}
+	srv := &http.Server{Handler: Handler(ctx, cfg), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(sctx)
+	}()
+	log.Printf("dashboard on http://%s/", ln.Addr())
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
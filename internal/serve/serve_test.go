@@ -0,0 +1,210 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ectorus/internal/ecscan"
+)
+
+func TestWSAccept(t *testing.T) {
+	// the example handshake of RFC 6455, section 1.3
+	if got := wsAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("accept %q", got)
+	}
+}
+
+func TestJobSpecCheck(t *testing.T) {
+	for _, bad := range []JobSpec{
+		{Tool: "sage", P: 101},
+		{Tool: "ecscan", P: 100},
+		{Tool: "ecscan", P: 1031},
+		{Tool: "ectorus", P: 101, A: 0, B: 0},
+	} {
+		if _, err := bad.check(1024); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+	s, err := JobSpec{Tool: "ecscan", P: 101, A: 103, B: 3}.check(1024)
+	if err != nil || s.A != 2 {
+		t.Fatalf("%+v, %v", s, err)
+	}
+}
+
+// readWS reads the server's text messages until its close frame.
+func readWS(t *testing.T, br *bufio.Reader) []message {
+	var msgs []message
+	for {
+		var h [2]byte
+		if _, err := io.ReadFull(br, h[:]); err != nil {
+			t.Fatal(err)
+		}
+		n := uint64(h[1] & 0x7F)
+		switch n {
+		case 126:
+			var b [2]byte
+			io.ReadFull(br, b[:])
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			io.ReadFull(br, b[:])
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			t.Fatal(err)
+		}
+		if h[0]&0x0F == opClose {
+			return msgs
+		}
+		var m message
+		if err := json.Unmarshal(payload, &m); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m)
+	}
+}
+
+func TestEcscanJob(t *testing.T) {
+	srv := httptest.NewServer(Handler(context.Background(), &Config{MaxP: 1024}))
+	defer srv.Close()
+
+	r, err := http.Post(srv.URL+"/jobs", "application/json", strings.NewReader(`{"tool":"ecscan","p":1009,"A":2,"B":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created struct{ ID int }
+	json.NewDecoder(r.Body).Decode(&created)
+	r.Body.Close()
+	if r.StatusCode != http.StatusCreated || created.ID != 1 {
+		t.Fatalf("status %d, id %d", r.StatusCode, created.ID)
+	}
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /jobs/1/ws HTTP/1.1\r\nHost: x\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake: %s %v", resp.Status, resp.Header)
+	}
+
+	msgs := readWS(t, br)
+	if len(msgs) < 2 || msgs[0].Type != "job" || msgs[len(msgs)-1].Type != "done" {
+		t.Fatalf("%d messages, first %+v", len(msgs), msgs[0])
+	}
+	seen := map[[2]uint64]bool{}
+	for _, m := range msgs {
+		for _, pt := range m.Found {
+			seen[pt] = true
+		}
+	}
+	want := 0
+	ecscan.Scan(context.Background(), ecscan.Params{P: 1009, A: 2, B: 3}, func(x, y uint64) error {
+		if !seen[[2]uint64{x, y}] {
+			t.Errorf("(%d, %d) not sent", x, y)
+		}
+		want++
+		return nil
+	})
+	done := msgs[len(msgs)-1]
+	if len(seen) != want || done.PointsFound != want || !done.Complete || done.Error != "" {
+		t.Fatalf("%d points sent, %d on the curve; done %+v", len(seen), want, done)
+	}
+
+	r, err = http.Post(srv.URL+"/jobs", "application/json", strings.NewReader(`{"tool":"ecscan","p":2003}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusBadRequest {
+		t.Fatalf("p above --max-p: status %d", r.StatusCode)
+	}
+}
+
+func TestRESTJobs(t *testing.T) {
+	srv := httptest.NewServer(Handler(context.Background(), &Config{MaxP: 1024}))
+	defer srv.Close()
+	get := func(path string, v any) int {
+		t.Helper()
+		r, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Body.Close()
+		if v != nil {
+			if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return r.StatusCode
+	}
+
+	r, err := http.Post(srv.URL+"/scan", "application/json", strings.NewReader(`{"p":1009,"A":2,"B":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st Status
+	json.NewDecoder(r.Body).Decode(&st)
+	r.Body.Close()
+	if r.StatusCode != http.StatusAccepted || r.Header.Get("Location") != st.StatusURL || st.Job.Tool != "ecscan" {
+		t.Fatalf("status %d, location %q, %+v", r.StatusCode, r.Header.Get("Location"), st)
+	}
+	for deadline := time.Now().Add(10 * time.Second); st.State == "running"; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("job still running")
+		}
+		get(st.StatusURL, &st)
+	}
+	if st.State != "done" || !st.Complete || st.PointCount != strconv.Itoa(st.PointsFound+1) {
+		t.Fatalf("%+v", st)
+	}
+
+	var res Result
+	if code := get(st.ResultURL, &res); code != http.StatusOK || len(res.Found) != st.PointsFound || res.PointCount != st.PointCount {
+		t.Fatalf("status %d, %d points, result %s; %+v", code, len(res.Found), res.PointCount, st)
+	}
+	r, err = http.Get(srv.URL + st.ResultURL + "?format=csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(r.Body).ReadAll()
+	r.Body.Close()
+	if err != nil || len(rows) != st.PointsFound+1 || rows[0][0] != "x" {
+		t.Fatalf("%d csv rows, %v", len(rows), err)
+	}
+
+	var all []Status
+	if get("/jobs", &all); len(all) != 1 || all[0].ID != st.ID {
+		t.Fatalf("jobs %+v", all)
+	}
+	if code := get("/jobs/2", nil); code != http.StatusNotFound {
+		t.Fatalf("unknown job: status %d", code)
+	}
+	r, err = http.Post(srv.URL+"/torus", "application/json", strings.NewReader(`{"tool":"ecscan","p":101,"A":2,"B":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusBadRequest {
+		t.Fatalf("tool mismatch: status %d", r.StatusCode)
+	}
+}
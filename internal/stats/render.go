@@ -0,0 +1,52 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteASCII draws the histogram with bars of '#' scaled to width columns
+// and a '|' where the semicircle law puts each bin, then the moments and χ².
+func (r *Report) WriteASCII(w io.Writer, width int) error {
+	peak := 0.0
+	for _, b := range r.Bins {
+		peak = max(peak, float64(b.Observed), b.Expected)
+	}
+	scale := float64(width) / peak
+	for _, b := range r.Bins {
+		bar := []byte(strings.Repeat("#", int(float64(b.Observed)*scale+0.5)))
+		if e := int(b.Expected*scale + 0.5); e < len(bar) {
+			bar[e] = '|'
+		} else {
+			bar = append(bar, []byte(strings.Repeat(" ", e-len(bar)))...)
+			bar = append(bar, '|')
+		}
+		if _, err := fmt.Fprintf(w, "[%+.2f,%+.2f) %6d %s\n", b.Lo, b.Hi, b.Observed, bar); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "\nn = %d, mean x = %.4f\n", r.N, r.Mean); err != nil {
+		return err
+	}
+	for _, m := range r.Moments {
+		if _, err := fmt.Fprintf(w, "E[(a_p/√p)^%d] = %8.4f  (Sato–Tate %g)\n", m.K, m.Observed, m.Expected); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "χ² = %.2f on %d dof, p-value %.4g\n", r.Chi2, r.DoF, r.PValue)
+	return err
+}
+
+// WriteCSV writes one row per bin.
+func (r *Report) WriteCSV(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "lo,hi,observed,expected"); err != nil {
+		return err
+	}
+	for _, b := range r.Bins {
+		if _, err := fmt.Fprintf(w, "%.6f,%.6f,%d,%.6f\n", b.Lo, b.Hi, b.Observed, b.Expected); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+// Package stats compares streams of Frobenius traces a_p with the
+// Sato–Tate law: for a non-CM curve the normalized traces x = a_p / 2√p
+// become distributed on [-1, 1] with density (2/π)√(1 - x²).
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Normalize returns a_p / 2√p.
+func Normalize(p, ap *big.Int) float64 {
+	pf, _ := new(big.Float).SetInt(p).Float64()
+	af, _ := new(big.Float).SetInt(ap).Float64()
+	return af / (2 * math.Sqrt(pf))
+}
+
+// ReadSamples reads normalized traces from apscan CSV ("p,a_p,..." rows;
+// "#" comments and the column header are skipped) or from ecscan
+// --summary NDJSON records ({"type":"summary","p":..,"trace":..}), which
+// may be mixed freely in one stream.
+func ReadSamples(r io.Reader) ([]float64, error) {
+	var xs []float64
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || strings.HasPrefix(s, "#") || strings.HasPrefix(s, "p,") {
+			continue
+		}
+		var pStr, apStr string
+		if strings.HasPrefix(s, "{") {
+			var rec struct {
+				Type  string `json:"type"`
+				P     string `json:"p"`
+				Trace string `json:"trace"`
+				Ext   int    `json:"ext"`
+			}
+			if err := json.Unmarshal([]byte(s), &rec); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if rec.Type != "summary" || rec.Ext > 1 {
+				continue // only F_p counts carry an a_p
+			}
+			pStr, apStr = rec.P, rec.Trace
+		} else {
+			f := strings.Split(s, ",")
+			if len(f) < 2 {
+				return nil, fmt.Errorf("line %d: want p,a_p,...: %q", line, s)
+			}
+			pStr, apStr = f[0], f[1]
+		}
+		p, ok1 := new(big.Int).SetString(strings.TrimSpace(pStr), 10)
+		ap, ok2 := new(big.Int).SetString(strings.TrimSpace(apStr), 10)
+		if !ok1 || !ok2 || p.Sign() <= 0 {
+			return nil, fmt.Errorf("line %d: bad p or a_p: %q", line, s)
+		}
+		xs = append(xs, Normalize(p, ap))
+	}
+	return xs, sc.Err()
+}
+
+// SemicircleCDF is the Sato–Tate distribution function on [-1, 1]:
+// F(x) = 1/2 + (x√(1-x²) + arcsin x)/π.
+func SemicircleCDF(x float64) float64 {
+	switch {
+	case x <= -1:
+		return 0
+	case x >= 1:
+		return 1
+	}
+	return 0.5 + (x*math.Sqrt(1-x*x)+math.Asin(x))/math.Pi
+}
+
+// Moment compares E[t^k] for t = a_p/√p = 2x with the Sato–Tate value,
+// which is 0 for odd k and the Catalan number C_{k/2} for even k.
+type Moment struct {
+	K        int     `json:"k"`
+	Observed float64 `json:"observed"`
+	Expected float64 `json:"expected"`
+}
+
+// Bin is one histogram cell [Lo, Hi) of x.
+type Bin struct {
+	Lo       float64 `json:"lo"`
+	Hi       float64 `json:"hi"`
+	Observed int     `json:"observed"`
+	Expected float64 `json:"expected"`
+}
+
+type Report struct {
+	N       int      `json:"n"`
+	Mean    float64  `json:"mean"` // of x
+	Moments []Moment `json:"moments"`
+	Bins    []Bin    `json:"bins"`
+	Chi2    float64  `json:"chi2"`
+	DoF     int      `json:"dof"`
+	PValue  float64  `json:"pValue"`
+}
+
+// Analyze bins xs into bins equal-width cells of [-1, 1] and computes the
+// first maxK moments and a χ² goodness-of-fit against the semicircle law.
+func Analyze(xs []float64, bins, maxK int) (*Report, error) {
+	if len(xs) == 0 {
+		return nil, errors.New("no samples")
+	}
+	if bins < 2 {
+		return nil, errors.New("need at least 2 bins")
+	}
+	r := &Report{N: len(xs), DoF: bins - 1}
+	n := float64(len(xs))
+
+	for _, x := range xs {
+		r.Mean += x
+	}
+	r.Mean /= n
+
+	for k := 1; k <= maxK; k++ {
+		var sum float64
+		for _, x := range xs {
+			sum += math.Pow(2*x, float64(k))
+		}
+		m := Moment{K: k, Observed: sum / n}
+		if k%2 == 0 {
+			m.Expected = catalan(k / 2)
+		}
+		r.Moments = append(r.Moments, m)
+	}
+
+	w := 2.0 / float64(bins)
+	r.Bins = make([]Bin, bins)
+	for i := range r.Bins {
+		lo, hi := -1+float64(i)*w, -1+float64(i+1)*w
+		r.Bins[i] = Bin{Lo: lo, Hi: hi, Expected: n * (SemicircleCDF(hi) - SemicircleCDF(lo))}
+	}
+	for _, x := range xs {
+		i := int((x + 1) / w)
+		if i < 0 {
+			i = 0
+		}
+		if i >= bins {
+			i = bins - 1 // x = 1 exactly
+		}
+		r.Bins[i].Observed++
+	}
+	for _, b := range r.Bins {
+		d := float64(b.Observed) - b.Expected
+		r.Chi2 += d * d / b.Expected
+	}
+	r.PValue = chi2Survival(r.Chi2, r.DoF)
+	return r, nil
+}
+
+func catalan(n int) float64 {
+	c := 1.0
+	for i := 0; i < n; i++ {
+		c = c * 2 * float64(2*i+1) / float64(i+2)
+	}
+	return c
+}
+
+// chi2Survival is P[χ²_k ≥ x] = Q(k/2, x/2), the regularized upper
+// incomplete gamma function (series below a+1, continued fraction above).
+func chi2Survival(x float64, k int) float64 {
+	a, z := float64(k)/2, x/2
+	if z <= 0 {
+		return 1
+	}
+	lg, _ := math.Lgamma(a)
+	if z < a+1 {
+		sum, term := 1/a, 1/a
+		for n := 1; n < 1000; n++ {
+			term *= z / (a + float64(n))
+			sum += term
+			if term < sum*1e-15 {
+				break
+			}
+		}
+		return 1 - sum*math.Exp(-z+a*math.Log(z)-lg)
+	}
+	// Lentz's method
+	const tiny = 1e-300
+	b := z + 1 - a
+	c, d := 1/tiny, 1/b
+	h := d
+	for i := 1; i < 1000; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-15 {
+			break
+		}
+	}
+	return math.Exp(-z+a*math.Log(z)-lg) * h
+}
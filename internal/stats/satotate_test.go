@@ -0,0 +1,92 @@
+package stats
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestChi2Survival(t *testing.T) {
+	// k = 2 is exponential: P[χ² ≥ x] = e^{-x/2}
+	for _, x := range []float64{0.5, 2, 7, 30} {
+		if got, want := chi2Survival(x, 2), math.Exp(-x/2); math.Abs(got-want) > 1e-10 {
+			t.Fatalf("chi2Survival(%g, 2) = %g, want %g", x, got, want)
+		}
+	}
+	// median of χ²_10 is ≈ 9.3418
+	if got := chi2Survival(9.3418, 10); math.Abs(got-0.5) > 1e-4 {
+		t.Fatalf("chi2Survival(9.3418, 10) = %g, want 0.5", got)
+	}
+}
+
+func TestCatalan(t *testing.T) {
+	for n, want := range []float64{1, 1, 2, 5, 14, 42} {
+		if got := catalan(n); math.Abs(got-want) > 1e-9 {
+			t.Fatalf("catalan(%d) = %g, want %g", n, got, want)
+		}
+	}
+}
+
+// quantiles returns n points spread by the inverse semicircle CDF.
+func quantiles(n int) []float64 {
+	xs := make([]float64, n)
+	for i := range xs {
+		u := (float64(i) + 0.5) / float64(n)
+		lo, hi := -1.0, 1.0
+		for j := 0; j < 60; j++ {
+			mid := (lo + hi) / 2
+			if SemicircleCDF(mid) < u {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		xs[i] = lo
+	}
+	return xs
+}
+
+func TestAnalyzeSemicircleFits(t *testing.T) {
+	r, err := Analyze(quantiles(10000), 20, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.PValue < 0.99 {
+		t.Fatalf("semicircle quantiles rejected: χ² = %g, p = %g", r.Chi2, r.PValue)
+	}
+	for _, m := range r.Moments {
+		if math.Abs(m.Observed-m.Expected) > 0.01 {
+			t.Fatalf("moment %d = %g, want %g", m.K, m.Observed, m.Expected)
+		}
+	}
+
+	uniform := make([]float64, 10000)
+	for i := range uniform {
+		uniform[i] = -1 + 2*(float64(i)+0.5)/10000
+	}
+	if r, _ = Analyze(uniform, 20, 0); r.PValue > 1e-6 {
+		t.Fatalf("uniform sample accepted: p = %g", r.PValue)
+	}
+}
+
+func TestReadSamples(t *testing.T) {
+	in := `# A=-1
+p,a_p,x,theta
+5,-2,-0.447214,2.034444
+{"type":"summary","p":"101","A":"2","B":"3","trace":"6"}
+{"type":"summary","p":"103","ext":2,"trace":"-206"}
+`
+	xs, err := ReadSamples(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{-2 / (2 * math.Sqrt(5)), 6 / (2 * math.Sqrt(101))}
+	if len(xs) != len(want) {
+		t.Fatalf("got %v, want %v", xs, want)
+	}
+	for i := range want {
+		if math.Abs(xs[i]-want[i]) > 1e-12 {
+			t.Fatalf("got %v, want %v", xs, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Package ecscan lets Go programs outside this module enumerate the affine
+// points of y^2 = x^3 + Ax + B over F_p in-process, with the worker pool of
+// the ecscan command and no file in between. It re-exports the library API
+// of the command's internal package, so both always scan the same way.
+package ecscan
+
+import (
+	"context"
+
+	scan "ectorus/internal/ecscan"
+)
+
+// Params is a curve y^2 = x^3 + Ax + B over F_P, P a prime in (3, 2^63),
+// with the --mode, --max-mem, --workers and --ordered of the command.
+type Params = scan.Params
+
+// Point is an affine point (X, Y).
+type Point = scan.PointU64
+
+// Mode is how the square roots are found, as --mode.
+type Mode = scan.Mode
+
+// The modes of Params.Mode; "" is ModeAuto.
+const (
+	ModeAuto     = scan.ModeAuto
+	ModeTable    = scan.ModeTable
+	ModeHybrid   = scan.ModeHybrid
+	ModeOnTheFly = scan.ModeOnTheFly
+)
+
+// Scan calls fn for every affine point of the curve, from one goroutine at
+// a time and in no particular order unless prm.Ordered is set. O is not
+// passed. It returns fn's first error, or ctx.Err() if ctx is done before
+// the scan completes.
+func Scan(ctx context.Context, prm Params, fn func(x, y uint64) error) error {
+	return scan.Scan(ctx, prm, fn)
+}
+
+// Points streams the affine points of the curve on a channel that is
+// closed when the scan ends; the returned func waits for that and reports
+// the scan's error. A consumer that stops reading early must cancel ctx,
+// and the func then returns ctx.Err().
+func Points(ctx context.Context, prm Params) (<-chan Point, func() error) {
+	return scan.Points(ctx, prm)
+}
@@ -0,0 +1,65 @@
+package ecscan
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPointsMatchesScan(t *testing.T) {
+	prm := Params{P: 10007, A: 2, B: 3, Workers: 4}
+	want := map[Point]bool{}
+	if err := Scan(context.Background(), prm, func(x, y uint64) error {
+		want[Point{X: x, Y: y}] = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// #E = 9846, O included
+	if len(want) != 9845 {
+		t.Fatalf("Scan: %d affine points, want 9845", len(want))
+	}
+	ch, wait := Points(context.Background(), prm)
+	n := 0
+	for pt := range ch {
+		if !want[pt] {
+			t.Fatalf("Points sent %v, which Scan did not", pt)
+		}
+		n++
+	}
+	if err := wait(); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(want) {
+		t.Fatalf("Points: %d points, Scan: %d", n, len(want))
+	}
+}
+
+func TestPointsCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	// large enough that the scan is still running when the consumer leaves
+	ch, wait := Points(ctx, Params{P: 1000003, A: 2, B: 3, Mode: ModeOnTheFly, Workers: 4})
+	for range 10 {
+		if _, ok := <-ch; !ok {
+			t.Fatal("channel closed after fewer than 10 points")
+		}
+	}
+	cancel()
+	if err := wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wait after cancel = %v, want %v", err, context.Canceled)
+	}
+	// the channel is closed once wait returns, whatever was left in it
+	for range ch {
+	}
+	// and the scan's goroutines are gone
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running, %d before Points", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
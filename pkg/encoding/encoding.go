@@ -0,0 +1,142 @@
+// Package encoding implements the SEC 1 octet-string encoding of points on
+// y^2 = x^3 + Ax + B over F_p (SEC 1 v2, §2.3.3–2.3.4): 0x00 for the point
+// at infinity, 0x04‖X‖Y uncompressed and 0x02/0x03‖X compressed (the low
+// bit of y in the prefix), with X and Y big-endian in FieldSize(p) bytes.
+// The ASN.1 ECPoint is an OCTET STRING holding one of these; MarshalASN1
+// and UnmarshalASN1 wrap it. These are the bytes OpenSSL's
+// EC_POINT_point2oct and EC_POINT_oct2point produce and accept.
+package encoding
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"ectorus/internal/ec"
+)
+
+// Prefix bytes of the three forms.
+const (
+	TagInfinity     = 0x00
+	TagCompressed   = 0x02 // | 1 when y is odd
+	TagUncompressed = 0x04
+)
+
+// FieldSize is the length in bytes of a coordinate in F_p.
+func FieldSize(p *big.Int) int { return (p.BitLen() + 7) / 8 }
+
+// Infinity returns the encoding of the point at infinity.
+func Infinity() []byte { return []byte{TagInfinity} }
+
+// Marshal returns the uncompressed encoding 0x04‖X‖Y.
+func Marshal(p, x, y *big.Int) []byte {
+	size := FieldSize(p)
+	out := make([]byte, 1+2*size)
+	out[0] = TagUncompressed
+	x.FillBytes(out[1 : 1+size])
+	y.FillBytes(out[1+size:])
+	return out
+}
+
+// MarshalCompressed returns the compressed encoding 0x02‖X or 0x03‖X.
+func MarshalCompressed(p, x, y *big.Int) []byte {
+	return AppendCompressed(nil, FieldSize(p), x, y)
+}
+
+// AppendCompressed appends the compressed encoding of (x, y), with x in
+// size bytes, to dst. Writers that emit many points compute size once.
+func AppendCompressed(dst []byte, size int, x, y *big.Int) []byte {
+	dst = append(dst, TagCompressed|byte(y.Bit(0)))
+	n := len(dst)
+	dst = append(dst, make([]byte, size)...)
+	x.FillBytes(dst[n:])
+	return dst
+}
+
+// AppendCompressedU64 is AppendCompressed for p < 2^64 (size ≤ 8), without
+// allocating.
+func AppendCompressedU64(dst []byte, size int, x, y uint64) []byte {
+	dst = append(dst, TagCompressed|byte(y&1))
+	for i := size - 1; i >= 0; i-- {
+		dst = append(dst, byte(x>>(8*i)))
+	}
+	return dst
+}
+
+// Unmarshal decodes any of the three forms for the curve (p, A, B) and
+// checks the result lies on it. The point at infinity comes back as
+// x = y = nil.
+func Unmarshal(p, A, B *big.Int, data []byte) (x, y *big.Int, err error) {
+	size := FieldSize(p)
+	if len(data) == 0 {
+		return nil, nil, errors.New("sec1: empty encoding")
+	}
+	switch tag := data[0]; {
+	case tag == TagInfinity && len(data) == 1:
+		return nil, nil, nil
+	case tag == TagUncompressed && len(data) == 1+2*size:
+		x = new(big.Int).SetBytes(data[1 : 1+size])
+		y = new(big.Int).SetBytes(data[1+size:])
+		if x.Cmp(p) >= 0 || y.Cmp(p) >= 0 {
+			return nil, nil, errors.New("sec1: coordinate not below p")
+		}
+		lhs := new(big.Int).Mul(y, y)
+		if lhs.Sub(lhs, rhs(p, A, B, x)).Mod(lhs, p).Sign() != 0 {
+			return nil, nil, fmt.Errorf("sec1: (%s, %s) is not on the curve", x, y)
+		}
+		return x, y, nil
+	case tag&^1 == TagCompressed && len(data) == 1+size:
+		x = new(big.Int).SetBytes(data[1:])
+		y, err = Decompress(p, A, B, x, tag == TagCompressed|1)
+		if err != nil {
+			return nil, nil, err
+		}
+		return x, y, nil
+	default:
+		return nil, nil, fmt.Errorf("sec1: bad encoding: tag 0x%02x, %d bytes for a %d-byte field", tag, len(data), size)
+	}
+}
+
+// Decompress returns the y with y^2 = x^3 + Ax + B mod p and the given
+// parity, by a Tonelli–Shanks square root.
+func Decompress(p, A, B, x *big.Int, odd bool) (*big.Int, error) {
+	if x.Sign() < 0 || x.Cmp(p) >= 0 {
+		return nil, fmt.Errorf("sec1: x = %s is not in [0, p)", x)
+	}
+	y, err := ec.SqrtModP(rhs(p, A, B, x), p)
+	if err != nil {
+		return nil, fmt.Errorf("sec1: x = %s is not the x of a point", x)
+	}
+	if y.Bit(0) == 1 != odd {
+		if y.Sign() == 0 {
+			return nil, fmt.Errorf("sec1: x = %s has y = 0, which is even", x)
+		}
+		y.Sub(p, y)
+	}
+	return y, nil
+}
+
+// rhs is x^3 + Ax + B mod p.
+func rhs(p, A, B, x *big.Int) *big.Int {
+	r := new(big.Int).Mul(x, x)
+	r.Add(r, A).Mul(r, x).Add(r, B)
+	return r.Mod(r, p)
+}
+
+// MarshalASN1 wraps an encoding as the DER OCTET STRING of an ASN.1
+// ECPoint.
+func MarshalASN1(enc []byte) ([]byte, error) { return asn1.Marshal(enc) }
+
+// UnmarshalASN1 unwraps a DER ECPoint to the encoding it holds.
+func UnmarshalASN1(der []byte) ([]byte, error) {
+	var enc []byte
+	rest, err := asn1.Unmarshal(der, &enc)
+	if err != nil {
+		return nil, fmt.Errorf("sec1: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("sec1: trailing data after the ECPoint")
+	}
+	return enc, nil
+}
@@ -0,0 +1,80 @@
+package encoding
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// p256 is NIST P-256 as (p, A, B), with its generator.
+func p256() (p, A, B, gx, gy *big.Int) {
+	c := elliptic.P256().Params()
+	return c.P, big.NewInt(-3), c.B, c.Gx, c.Gy
+}
+
+func TestP256Generator(t *testing.T) {
+	p, A, B, gx, gy := p256()
+	comp := MarshalCompressed(p, gx, gy)
+	if want := "036b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296"; hex.EncodeToString(comp) != want {
+		t.Fatalf("compressed G = %x, want %s", comp, want)
+	}
+	if want := elliptic.MarshalCompressed(elliptic.P256(), gx, gy); !bytes.Equal(comp, want) {
+		t.Fatalf("compressed G differs from crypto/elliptic: %x vs %x", comp, want)
+	}
+	for _, enc := range [][]byte{comp, Marshal(p, gx, gy)} {
+		x, y, err := Unmarshal(p, A, B, enc)
+		if err != nil || x.Cmp(gx) != 0 || y.Cmp(gy) != 0 {
+			t.Fatalf("Unmarshal(%x) = %v, %v, %v", enc, x, y, err)
+		}
+	}
+}
+
+func TestSmallCurve(t *testing.T) {
+	// y^2 = x^3 + 2x + 3 over F_101: (3, 6) and (3, 95)
+	p, A, B := big.NewInt(101), big.NewInt(2), big.NewInt(3)
+	for _, y := range []int64{6, 95} {
+		enc := MarshalCompressed(p, big.NewInt(3), big.NewInt(y))
+		if u := AppendCompressedU64(nil, FieldSize(p), 3, uint64(y)); !bytes.Equal(u, enc) {
+			t.Fatalf("AppendCompressedU64 %x, AppendCompressed %x", u, enc)
+		}
+		if _, got, err := Unmarshal(p, A, B, enc); err != nil || got.Int64() != y {
+			t.Fatalf("y = %d: decoded %v, %v", y, got, err)
+		}
+	}
+	if x, y, err := Unmarshal(p, A, B, Infinity()); x != nil || y != nil || err != nil {
+		t.Fatalf("infinity decoded as %v, %v, %v", x, y, err)
+	}
+	for _, bad := range [][]byte{
+		{},
+		{0x02, 2},          // 15 is not a square mod 101
+		{0x04, 3, 7},       // off the curve
+		{0x02, 3, 0},       // too long
+		{0x05, 3},          // unknown tag
+		{0x04, 3, 101 + 6}, // y ≥ p
+	} {
+		if _, _, err := Unmarshal(p, A, B, bad); err == nil {
+			t.Errorf("Unmarshal(%x) accepted", bad)
+		}
+	}
+}
+
+func TestASN1(t *testing.T) {
+	p, _, _, gx, gy := p256()
+	enc := MarshalCompressed(p, gx, gy)
+	der, err := MarshalASN1(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if der[0] != 0x04 || int(der[1]) != len(enc) {
+		t.Fatalf("DER %x is not an OCTET STRING of %d bytes", der, len(enc))
+	}
+	back, err := UnmarshalASN1(der)
+	if err != nil || !bytes.Equal(back, enc) {
+		t.Fatalf("round trip: %x, %v", back, err)
+	}
+	if _, err := UnmarshalASN1(append(der, 0)); err == nil {
+		t.Fatal("trailing byte accepted")
+	}
+}